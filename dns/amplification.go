@@ -0,0 +1,325 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/grafana/sobek"
+	"github.com/miekg/dns"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/promises"
+	"go.k6.io/k6/metrics"
+)
+
+// defaultAmplificationUDPSize is the EDNS UDP payload size advertised by the
+// queries of the measureAmplification function, unless specified otherwise, as
+// large as the ones of amplification attacks.
+const defaultAmplificationUDPSize = 4096
+
+// defaultAmplificationTypes lists the record types the measureAmplification
+// function queries, unless specified otherwise, starting with the ones yielding
+// the largest responses.
+var defaultAmplificationTypes = []uint16{ //nolint:gochecknoglobals
+	dns.TypeANY,
+	dns.TypeDNSKEY,
+	dns.TypeTXT,
+	dns.TypeMX,
+	dns.TypeNS,
+	dns.TypeSOA,
+	dns.TypeA,
+	dns.TypeAAAA,
+}
+
+// amplificationOptions holds the options that can be passed to the
+// measureAmplification function.
+type amplificationOptions struct {
+	resolveOptions
+
+	// Names holds the names queried, which default to the zone's apex.
+	Names []string
+
+	// Types holds the record types each name is queried for.
+	Types []uint16
+
+	// DNSSEC indicates whether the DNSSEC OK bit of the queries is set, for the
+	// responses to include the signatures of the records.
+	DNSSEC bool
+
+	// UDPSize holds the EDNS UDP payload size the queries advertise.
+	UDPSize uint16
+}
+
+// parseAmplificationOptions parses the options object passed to the
+// measureAmplification function.
+//
+// It accepts the same options as the resolve function, along with the names,
+// types, dnssec and udpSize options.
+func parseAmplificationOptions(rt *sobek.Runtime, value sobek.Value) (amplificationOptions, error) {
+	resolveOpts, err := parseResolveOptions(rt, value)
+	if err != nil {
+		return amplificationOptions{}, err
+	}
+
+	opts := amplificationOptions{
+		resolveOptions: resolveOpts,
+		Types:          defaultAmplificationTypes,
+		DNSSEC:         true,
+		UDPSize:        defaultAmplificationUDPSize,
+	}
+
+	// Amplification attacks rely on spoofed UDP queries, which responses over
+	// other protocols are not exposed to
+	opts.Protocol = protocolUDP
+
+	if common.IsNullish(value) {
+		return opts, nil
+	}
+
+	params := value.ToObject(rt)
+
+	if v := params.Get("names"); !common.IsNullish(v) {
+		var names []string
+		if err := rt.ExportTo(v, &names); err != nil || len(names) == 0 {
+			return opts, fmt.Errorf("names option must be a non-empty array of domain names; got %v instead", v)
+		}
+
+		opts.Names = make([]string, 0, len(names))
+		for _, name := range names {
+			asciiName, err := ToASCII(name)
+			if err != nil {
+				return opts, fmt.Errorf("names option holds invalid name %q; reason: %w", name, err)
+			}
+
+			opts.Names = append(opts.Names, asciiName)
+		}
+	}
+
+	if v := params.Get("types"); !common.IsNullish(v) {
+		var types []string
+		if err := rt.ExportTo(v, &types); err != nil || len(types) == 0 {
+			return opts, fmt.Errorf("types option must be a non-empty array of record types; got %v instead", v)
+		}
+
+		opts.Types = make([]uint16, 0, len(types))
+		for _, name := range types {
+			qtype, ok := dns.StringToType[strings.ToUpper(name)]
+			if !ok {
+				return opts, fmt.Errorf("unknown record type %q", name)
+			}
+
+			opts.Types = append(opts.Types, qtype)
+		}
+	}
+
+	if v := params.Get("dnssec"); !common.IsNullish(v) {
+		opts.DNSSEC = v.ToBoolean()
+	}
+
+	if v := params.Get("udpSize"); !common.IsNullish(v) {
+		var udpSize int64
+		if err := rt.ExportTo(v, &udpSize); err != nil || udpSize < dns.MinMsgSize || udpSize > math.MaxUint16 {
+			return opts, fmt.Errorf(
+				"udpSize option must be an integer between %d and %d; got %v instead",
+				dns.MinMsgSize, math.MaxUint16, v,
+			)
+		}
+
+		opts.UDPSize = uint16(udpSize)
+	}
+
+	return opts, nil
+}
+
+// amplificationResult is the object the measureAmplification function resolves
+// to.
+type amplificationResult struct {
+	// Zone holds the zone which was measured.
+	Zone string `js:"zone"`
+
+	// Nameserver holds the address of the nameserver which was queried.
+	Nameserver string `js:"nameserver"`
+
+	// MaxFactor holds the largest amplification factor measured, or 0 if every
+	// query failed.
+	MaxFactor float64 `js:"maxFactor"`
+
+	// Max holds the measurement of the largest amplification factor, or nil if
+	// every query failed.
+	Max interface{} `js:"max"`
+
+	// Measurements holds the measurement of each name and record type, in order.
+	Measurements []*amplificationMeasurement `js:"measurements"`
+}
+
+// amplificationMeasurement holds the sizes of a query and of its response.
+type amplificationMeasurement struct {
+	// Name holds the name which was queried.
+	Name string `js:"name"`
+
+	// Type holds the record type which was queried.
+	Type string `js:"type"`
+
+	// QuerySize holds the size of the query, in bytes.
+	QuerySize int `js:"querySize"`
+
+	// ResponseSize holds the size of the response, in bytes, or 0 if the query
+	// failed.
+	ResponseSize int `js:"responseSize"`
+
+	// Factor holds the ratio of the size of the response to the size of the query.
+	Factor float64 `js:"factor"`
+
+	// Truncated indicates whether the response was truncated, because it did not
+	// fit within the advertised UDP payload size.
+	Truncated bool `js:"truncated"`
+
+	// Rcode holds the name of the response code of the response, if any.
+	Rcode string `js:"rcode"`
+
+	// Error holds the error the query failed with, if any.
+	Error *Error `js:"error"`
+}
+
+// MeasureAmplification queries the nameserver for each of the record types of the
+// names of a zone, over UDP, and resolves to the ratio of the size of the
+// responses to the size of the queries, which quantifies how much the zone
+// exposes its nameservers to being used for amplification attacks.
+//
+// The queries are only sent to the nameserver, from the load generator, so that
+// measuring the amplification factor of a zone one owns is safe.
+//
+// The promise is not rejected when queries fail, which is instead reported in
+// the measurements.
+func (mi *ModuleInstance) MeasureAmplification(zone, nameserverAddr, options sobek.Value) *sobek.Promise {
+	promise, resolve, reject := promises.New(mi.vu)
+
+	if mi.vu.State() == nil {
+		reject(errors.New("measureAmplification can not be used in the init context"))
+		return promise
+	}
+
+	zoneStr, err := exportDomainName(mi.vu.Runtime(), zone, "zone")
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	nameserver, err := mi.exportNameserver(nameserverAddr)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	opts, err := parseAmplificationOptions(mi.vu.Runtime(), options)
+	if err != nil {
+		reject(fmt.Errorf("invalid measureAmplification options: %w", err))
+		return promise
+	}
+
+	if opts.Names == nil {
+		opts.Names = []string{zoneStr}
+	}
+
+	ctx, cancel, err := mi.withAbortSignal(opts.Signal)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer cancel()
+
+		resolve(mi.measureAmplification(ctx, zoneStr, nameserver, opts))
+	}()
+
+	return promise
+}
+
+// measureAmplification queries each name for each record type in turn, and
+// measures the sizes of the queries and of their responses.
+func (mi *ModuleInstance) measureAmplification(
+	ctx context.Context,
+	zone string,
+	nameserver Nameserver,
+	opts amplificationOptions,
+) *amplificationResult {
+	result := &amplificationResult{
+		Zone:         strings.TrimSuffix(zone, "."),
+		Nameserver:   nameserver.Addr(),
+		Measurements: make([]*amplificationMeasurement, 0, len(opts.Names)*len(opts.Types)),
+	}
+
+	for _, name := range opts.Names {
+		for _, qtype := range opts.Types {
+			if ctx.Err() != nil {
+				return result
+			}
+
+			msg := new(dns.Msg)
+			msg.SetQuestion(dns.Fqdn(name), qtype)
+			msg.RecursionDesired = !opts.NoRecursion
+			msg.SetEdns0(opts.UDPSize, opts.DNSSEC)
+
+			response, _, err := mi.sendMessage(ctx, msg, nameserver, opts.QueryOptions)
+
+			measurement := &amplificationMeasurement{
+				Name:  strings.TrimSuffix(name, "."),
+				Type:  dns.TypeToString[qtype],
+				Error: asError(err),
+			}
+
+			if response != nil {
+				measurement.QuerySize = len(response.RawRequest)
+			}
+
+			if err == nil && response.msg != nil {
+				measurement.ResponseSize = response.Size
+				measurement.Truncated = response.msg.Truncated
+				measurement.Rcode = response.Rcode
+
+				if measurement.QuerySize > 0 {
+					measurement.Factor = float64(measurement.ResponseSize) / float64(measurement.QuerySize)
+				}
+
+				mi.emitAmplificationMetrics(mi.vu.Context(), measurement, nameserver)
+
+				if measurement.Factor > result.MaxFactor {
+					result.MaxFactor = measurement.Factor
+					result.Max = measurement
+				}
+			}
+
+			result.Measurements = append(result.Measurements, measurement)
+		}
+	}
+
+	return result
+}
+
+// emitAmplificationMetrics emits the amplification factor of the measurement.
+func (mi *ModuleInstance) emitAmplificationMetrics(
+	ctx context.Context,
+	measurement *amplificationMeasurement,
+	nameserver Nameserver,
+) {
+	state := mi.vu.State()
+
+	tags := state.Tags.GetCurrentValues().Tags
+	tags = tags.With("query", measurement.Name)
+	tags = tags.With("recordType", measurement.Type)
+	tags = tags.With("nameserver", nameserver.Addr())
+
+	metrics.PushIfNotDone(ctx, state.Samples, metrics.Sample{
+		TimeSeries: metrics.TimeSeries{
+			Metric: mi.metrics.DNSAmplificationFactor,
+			Tags:   tags,
+		},
+		Time:     time.Now(),
+		Value:    measurement.Factor,
+		Metadata: nil,
+	})
+}
@@ -0,0 +1,91 @@
+package dns
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+)
+
+func TestMeasureAmplification(t *testing.T) {
+	t.Parallel()
+
+	t.Run("The largest amplification factor should be reported", func(t *testing.T) {
+		t.Parallel()
+
+		runtime, err := newConfiguredRuntime(t)
+		require.NoError(t, err)
+
+		_, err = runtime.VU.Runtime().RunString(`
+			const server = dns.startServer([
+				"k6.test. 60 IN A 203.0.113.1",
+				'k6.test. 60 IN TXT "` + strings.Repeat("v=spf1 include:k6.test ", 10) + `"',
+				'k6.test. 60 IN TXT "` + strings.Repeat("verification=0123456789 ", 10) + `"',
+			]);
+		`)
+		require.NoError(t, err)
+
+		samples := make(chan metrics.SampleContainer, 1024)
+		runtime.MoveToVUContext(&lib.State{
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+			Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+			Samples:        samples,
+		})
+
+		_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+			const result = await dns.measureAmplification("k6.test", server.address, { types: ["A", "TXT"] });
+
+			const [a, txt] = result.measurements;
+			if (result.zone !== "k6.test" || result.measurements.length !== 2 || a.type !== "A" || txt.type !== "TXT") {
+				throw new Error("unexpected result: " + JSON.stringify(result));
+			}
+
+			if (txt.factor <= a.factor || txt.factor < 5 || result.max.type !== "TXT" || result.maxFactor !== txt.factor) {
+				throw new Error("unexpected factors: " + JSON.stringify(result));
+			}
+
+			if (a.querySize !== txt.querySize || a.responseSize / a.querySize !== a.factor || a.rcode !== "NOERROR") {
+				throw new Error("unexpected sizes: " + JSON.stringify(a));
+			}
+		`))
+		assert.NoError(t, err)
+
+		close(samples)
+
+		factors := map[string]float64{}
+		for container := range samples {
+			for _, sample := range container.GetSamples() {
+				if sample.Metric.Name != "dns_amplification_factor" {
+					continue
+				}
+
+				recordType, _ := sample.Tags.Get("recordType")
+				factors[recordType] = sample.Value
+			}
+		}
+
+		assert.Len(t, factors, 2)
+		assert.Greater(t, factors["TXT"], factors["A"])
+	})
+
+	t.Run("Unknown record types should be rejected", func(t *testing.T) {
+		t.Parallel()
+
+		runtime, err := newConfiguredRuntime(t)
+		require.NoError(t, err)
+
+		runtime.MoveToVUContext(&lib.State{
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+			Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+			Samples:        make(chan metrics.SampleContainer, 1024),
+		})
+
+		_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+			await dns.measureAmplification("k6.test", "127.0.0.1:53", { types: ["BOGUS"] });
+		`))
+		assert.ErrorContains(t, err, `unknown record type "BOGUS"`)
+	})
+}
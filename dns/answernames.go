@@ -0,0 +1,86 @@
+package dns
+
+import (
+	"github.com/miekg/dns"
+)
+
+// unrelatedAnswers returns the number of records of the answer section whose owner
+// name does not correspond to the query name, nor to a name the CNAME and DNAME
+// records of the section lead to from it. Such records do not answer the query,
+// and may have been injected into the response, e.g. by a cache poisoning attempt.
+//
+// Names are compared case-insensitively, so that the randomized case of query
+// names does not matter.
+func unrelatedAnswers(qname string, answers []dns.RR) int {
+	related := map[string]bool{dns.CanonicalName(qname): true}
+
+	// Records may appear in any order, so the chain is followed until it no longer
+	// leads to new names
+	for grown := true; grown; {
+		grown = false
+
+		for _, rr := range answers {
+			owner := dns.CanonicalName(rr.Header().Name)
+
+			var target string
+			switch rr := rr.(type) {
+			case *dns.CNAME:
+				if !related[owner] {
+					continue
+				}
+
+				target = dns.CanonicalName(rr.Target)
+			case *dns.DNAME:
+				target = dns.CanonicalName(rr.Target)
+
+				// A DNAME record redirects the names below its owner name
+				for name := range related {
+					if name != owner && dns.IsSubDomain(owner, name) {
+						substituted := name[:len(name)-len(owner)] + target
+						if !related[substituted] {
+							related[substituted] = true
+							grown = true
+						}
+					}
+				}
+
+				continue
+			default:
+				continue
+			}
+
+			if !related[target] {
+				related[target] = true
+				grown = true
+			}
+		}
+	}
+
+	count := 0
+	for _, rr := range answers {
+		owner := dns.CanonicalName(rr.Header().Name)
+		if related[owner] {
+			continue
+		}
+
+		// DNAME records are owned by an ancestor of the names they redirect
+		if _, ok := rr.(*dns.DNAME); ok && relatedSubDomain(owner, related) {
+			continue
+		}
+
+		count++
+	}
+
+	return count
+}
+
+// relatedSubDomain indicates whether any of the related names is below the name.
+func relatedSubDomain(name string, related map[string]bool) bool {
+	for relatedName := range related {
+		if relatedName != name && dns.IsSubDomain(name, relatedName) {
+			return true
+		}
+	}
+
+	return false
+}
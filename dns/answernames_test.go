@@ -0,0 +1,184 @@
+package dns
+
+import (
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+)
+
+func Test_unrelatedAnswers(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		qname   string
+		answers []string
+		want    int
+	}{
+		{
+			name:    "no answers",
+			qname:   "k6.test.",
+			answers: nil,
+			want:    0,
+		},
+		{
+			name:    "answers owned by the query name",
+			qname:   "k6.test.",
+			answers: []string{"k6.test. 60 IN A 203.0.113.1", "k6.test. 60 IN A 203.0.113.2"},
+			want:    0,
+		},
+		{
+			name:    "names compared case-insensitively",
+			qname:   "K6.tEsT.",
+			answers: []string{"k6.test. 60 IN A 203.0.113.1"},
+			want:    0,
+		},
+		{
+			name:  "chain of CNAME records",
+			qname: "www.k6.test.",
+			answers: []string{
+				"www.k6.test. 60 IN CNAME edge.k6.test.",
+				"edge.k6.test. 60 IN CNAME cdn.example.",
+				"cdn.example. 60 IN A 203.0.113.1",
+			},
+			want: 0,
+		},
+		{
+			name:  "chain of CNAME records out of order",
+			qname: "www.k6.test.",
+			answers: []string{
+				"cdn.example. 60 IN A 203.0.113.1",
+				"edge.k6.test. 60 IN CNAME cdn.example.",
+				"www.k6.test. 60 IN CNAME edge.k6.test.",
+			},
+			want: 0,
+		},
+		{
+			name:  "DNAME record with its synthesized CNAME record",
+			qname: "www.old.test.",
+			answers: []string{
+				"old.test. 60 IN DNAME k6.test.",
+				"www.old.test. 60 IN CNAME www.k6.test.",
+				"www.k6.test. 60 IN A 203.0.113.1",
+			},
+			want: 0,
+		},
+		{
+			name:  "DNAME record without its synthesized CNAME record",
+			qname: "www.old.test.",
+			answers: []string{
+				"old.test. 60 IN DNAME k6.test.",
+				"www.k6.test. 60 IN A 203.0.113.1",
+			},
+			want: 0,
+		},
+		{
+			name:  "injected records",
+			qname: "k6.test.",
+			answers: []string{
+				"k6.test. 60 IN A 203.0.113.1",
+				"bank.example. 60 IN A 198.51.100.1",
+				"other.test. 60 IN CNAME k6.test.",
+			},
+			want: 2,
+		},
+		{
+			name:  "records of a CNAME record not part of the chain",
+			qname: "www.k6.test.",
+			answers: []string{
+				"www.k6.test. 60 IN CNAME k6.test.",
+				"k6.test. 60 IN A 203.0.113.1",
+				"bank.example. 60 IN CNAME attacker.example.",
+				"attacker.example. 60 IN A 198.51.100.1",
+			},
+			want: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			answers := make([]dns.RR, 0, len(tt.answers))
+			for _, answer := range tt.answers {
+				rr, err := dns.NewRR(answer)
+				require.NoError(t, err)
+
+				answers = append(answers, rr)
+			}
+
+			assert.Equal(t, tt.want, unrelatedAnswers(tt.qname, answers))
+		})
+	}
+}
+
+func TestModuleInstance_Resolve_verifyAnswerNames(t *testing.T) {
+	t.Parallel()
+
+	// The nameserver injects a record of an unrelated name in the answers of the
+	// names of the injected.k6.test zone
+	handler := dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		response := new(dns.Msg)
+		response.SetReply(req)
+
+		name := req.Question[0].Name
+		response.Answer = append(response.Answer, &dns.A{
+			Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+			A:   net.ParseIP("203.0.113.1"),
+		})
+
+		if dns.IsSubDomain("injected.k6.test.", dns.CanonicalName(name)) {
+			response.Answer = append(response.Answer, &dns.A{
+				Hdr: dns.RR_Header{Name: "bank.example.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+				A:   net.ParseIP("198.51.100.1"),
+			})
+		}
+
+		_ = w.WriteMsg(response)
+	})
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := &dns.Server{PacketConn: conn, Handler: handler}
+	go func() { _ = server.ActivateAndServe() }()
+	t.Cleanup(func() { _ = server.Shutdown() })
+
+	runtime, err := newConfiguredRuntime(t)
+	require.NoError(t, err)
+	require.NoError(t, runtime.VU.Runtime().Set("address", conn.LocalAddr().String()))
+
+	samples := make(chan metrics.SampleContainer, 1024)
+	runtime.MoveToVUContext(&lib.State{
+		BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+		Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+		Samples:        samples,
+	})
+
+	_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+		await dns.resolve("www.k6.test", "A", address, { verifyAnswerNames: true, randomizeCase: true });
+		await dns.resolve("www.injected.k6.test", "A", address, { verifyAnswerNames: true });
+
+		// Answer names are only verified when instructed to
+		await dns.resolve("www.injected.k6.test", "A", address);
+	`))
+	require.NoError(t, err)
+
+	var values []float64
+	for len(samples) > 0 {
+		for _, sample := range (<-samples).GetSamples() {
+			if sample.Metric.Name == "dns_unrelated_answers" {
+				values = append(values, sample.Value)
+			}
+		}
+	}
+
+	assert.Equal(t, []float64{0, 1}, values)
+}
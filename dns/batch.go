@@ -0,0 +1,477 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/grafana/sobek"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/promises"
+)
+
+// defaultBatchConcurrency is the default number of queries a batch resolution
+// performs concurrently.
+const defaultBatchConcurrency = 10
+
+// batchQuery represents a single query of a batch resolution.
+type batchQuery struct {
+	// Name holds the domain name to resolve.
+	Name string
+
+	// Type holds the record type to resolve.
+	Type string
+}
+
+// batchOptions holds the options that can be passed to the resolveBatch function.
+type batchOptions struct {
+	resolveOptions
+
+	// Concurrency holds the maximum number of queries performed concurrently.
+	Concurrency int
+}
+
+// ResolveBatch resolves many queries against a nameserver, performing them
+// concurrently with a bounded pool of workers.
+//
+// It returns an array holding one result object per query, in the order the
+// queries were provided. The promise is never rejected because of a failed query,
+// the failures are instead reported in the results.
+func (mi *ModuleInstance) ResolveBatch(queries, nameserverAddr, options sobek.Value) *sobek.Promise {
+	promise, resolve, reject := promises.New(mi.vu)
+
+	if mi.vu.State() == nil {
+		reject(errors.New("resolveBatch can not be used in the init context"))
+		return promise
+	}
+
+	batch, err := exportBatchQueries(mi.vu.Runtime(), queries)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	nameserver, err := mi.exportNameserver(nameserverAddr)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	opts, err := parseBatchOptions(mi.vu.Runtime(), options)
+	if err != nil {
+		reject(fmt.Errorf("invalid resolveBatch options: %w", err))
+		return promise
+	}
+
+	ctx, cancel, err := mi.withAbortSignal(opts.Signal)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer cancel()
+
+		resolve(mi.resolveBatch(ctx, batch, nameserver, opts))
+	}()
+
+	return promise
+}
+
+// resolveBatch performs the batch's queries against the nameserver, with at most
+// opts.Concurrency of them in flight at any time.
+func (mi *ModuleInstance) resolveBatch(
+	ctx context.Context,
+	batch []batchQuery,
+	nameserver Nameserver,
+	opts batchOptions,
+) []*resolveResult {
+	results := make([]*resolveResult, len(batch))
+
+	mi.runBatch(ctx, batch, nameserver, opts, func(i int, result *resolveResult) {
+		results[i] = result
+	})
+
+	return results
+}
+
+// runBatch performs the batch's queries against the nameserver, with at most
+// opts.Concurrency of them in flight at any time, and calls emit with the index
+// and result of each query as it completes, from the goroutine which performed it.
+func (mi *ModuleInstance) runBatch(
+	ctx context.Context,
+	batch []batchQuery,
+	nameserver Nameserver,
+	opts batchOptions,
+	emit func(i int, result *resolveResult),
+) {
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < opts.Concurrency && worker < len(batch); worker++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range indexes {
+				query := batch[i]
+				response, duration, err := mi.resolveQuery(ctx, query.Name, query.Type, nameserver, opts.resolveOptions)
+				result := newResolveResult(query.Name, query.Type, response, err, duration)
+				result.limitAnswers(opts.MaxAnswers)
+				if opts.Raw {
+					result.Raw = newRawMessages(response)
+				}
+
+				if opts.Sections {
+					result.includeSections(response)
+				}
+
+				emit(i, result)
+			}
+		}()
+	}
+
+	for i := range batch {
+		indexes <- i
+	}
+	close(indexes)
+
+	wg.Wait()
+}
+
+// ResolveBatchStream resolves many queries against a nameserver, as ResolveBatch
+// does, but returns an async iterator yielding the result of each query as it
+// completes, so that large query sets can be processed incrementally.
+//
+// Queries are only performed as fast as the results are consumed, at most
+// opts.Concurrency results being held at once, and the return method of the
+// iterator stops the remaining queries.
+func (mi *ModuleInstance) ResolveBatchStream(queries, nameserverAddr, options sobek.Value) *sobek.Object {
+	if mi.vu.State() == nil {
+		mi.throw(errors.New("resolveBatchStream can not be used in the init context"))
+	}
+
+	batch, err := exportBatchQueries(mi.vu.Runtime(), queries)
+	if err != nil {
+		mi.throw(err)
+	}
+
+	nameserver, err := mi.exportNameserver(nameserverAddr)
+	if err != nil {
+		mi.throw(err)
+	}
+
+	opts, err := parseBatchOptions(mi.vu.Runtime(), options)
+	if err != nil {
+		mi.throw(fmt.Errorf("invalid resolveBatchStream options: %w", err))
+	}
+
+	ctx, cancel, err := mi.withAbortSignal(opts.Signal)
+	if err != nil {
+		mi.throw(err)
+	}
+
+	results := make(chan *resolveResult, opts.Concurrency)
+
+	go func() {
+		defer cancel()
+		defer close(results)
+
+		// Results are dropped once the iterator returned, or the signal aborted
+		mi.runBatch(ctx, batch, nameserver, opts, func(_ int, result *resolveResult) {
+			select {
+			case results <- result:
+			case <-ctx.Done():
+			}
+		})
+	}()
+
+	return mi.newAsyncIterator(results, cancel)
+}
+
+// newAsyncIterator creates an object implementing the async iterator protocol,
+// whose next method resolves to the values received from the channel until it
+// is closed, and whose return method calls stop.
+func (mi *ModuleInstance) newAsyncIterator(values <-chan *resolveResult, stop func()) *sobek.Object {
+	rt := mi.vu.Runtime()
+	iterator := rt.NewObject()
+
+	next := func() *sobek.Promise {
+		promise, resolve, _ := promises.New(mi.vu)
+
+		go func() {
+			value, ok := <-values
+			if !ok {
+				resolve(map[string]interface{}{"value": nil, "done": true})
+				return
+			}
+
+			resolve(map[string]interface{}{"value": value, "done": false})
+		}()
+
+		return promise
+	}
+
+	stopIteration := func() *sobek.Promise {
+		promise, resolve, _ := promises.New(mi.vu)
+
+		stop()
+		resolve(map[string]interface{}{"value": nil, "done": true})
+
+		return promise
+	}
+
+	if err := iterator.Set("next", next); err != nil {
+		mi.throw(err)
+	}
+
+	if err := iterator.Set("return", stopIteration); err != nil {
+		mi.throw(err)
+	}
+
+	// The iterator is its own async iterable, so that for await...of loops can
+	// consume it
+	asyncIterator, err := asyncIteratorSymbol(rt)
+	if err != nil {
+		mi.throw(err)
+	}
+
+	self := func(sobek.FunctionCall) sobek.Value { return iterator }
+	if err := iterator.SetSymbol(asyncIterator, self); err != nil {
+		mi.throw(err)
+	}
+
+	return iterator
+}
+
+// asyncIteratorSymbol returns the Symbol.asyncIterator well-known symbol. On
+// runtimes predating it, it returns the Symbol.for("Symbol.asyncIterator")
+// registered symbol instead, which is the one for await...of loops transpiled
+// to older JavaScript versions look up.
+func asyncIteratorSymbol(rt *sobek.Runtime) (*sobek.Symbol, error) {
+	symbolCtor := rt.Get("Symbol").ToObject(rt)
+
+	if symbol, ok := symbolCtor.Get("asyncIterator").(*sobek.Symbol); ok {
+		return symbol, nil
+	}
+
+	symbolFor, ok := sobek.AssertFunction(symbolCtor.Get("for"))
+	if !ok {
+		return nil, errors.New("Symbol.for is not a function")
+	}
+
+	value, err := symbolFor(symbolCtor, rt.ToValue("Symbol.asyncIterator"))
+	if err != nil {
+		return nil, err
+	}
+
+	symbol, ok := value.(*sobek.Symbol)
+	if !ok {
+		return nil, fmt.Errorf("Symbol.for returned a %s instead of a symbol", value.ExportType())
+	}
+
+	return symbol, nil
+}
+
+// exportBatchQueries exports the JS array of {name, type} objects describing the
+// queries of a batch resolution.
+func exportBatchQueries(rt *sobek.Runtime, value sobek.Value) ([]batchQuery, error) {
+	if common.IsNullish(value) {
+		return nil, errors.New("queries argument must be provided")
+	}
+
+	// Query lists are resolved in order, regardless of their weights
+	if list, ok := value.Export().(*QueryList); ok && list != nil {
+		return list.batch(), nil
+	}
+
+	var exported []map[string]interface{}
+	if err := rt.ExportTo(value, &exported); err != nil {
+		return nil, fmt.Errorf("queries must be an array of {name, type} objects; got %v instead", value)
+	}
+
+	batch := make([]batchQuery, 0, len(exported))
+	for i, query := range exported {
+		name, ok := query["name"].(string)
+		if !ok {
+			return nil, fmt.Errorf("query %d's name must be a string; got %v instead", i, query["name"])
+		}
+
+		recordType, ok := query["type"].(string)
+		if !ok {
+			return nil, fmt.Errorf("query %d's type must be a string; got %v instead", i, query["type"])
+		}
+
+		asciiName, err := ToASCII(name)
+		if err != nil {
+			return nil, err
+		}
+
+		batch = append(batch, batchQuery{Name: asciiName, Type: recordType})
+	}
+
+	return batch, nil
+}
+
+// parseBatchOptions parses the options object passed to the resolveBatch function.
+//
+// It accepts the same options as the resolve function, along with the concurrency
+// option.
+func parseBatchOptions(rt *sobek.Runtime, value sobek.Value) (batchOptions, error) {
+	resolveOpts, err := parseResolveOptions(rt, value)
+	if err != nil {
+		return batchOptions{}, err
+	}
+
+	opts := batchOptions{
+		resolveOptions: resolveOpts,
+		Concurrency:    defaultBatchConcurrency,
+	}
+
+	if common.IsNullish(value) {
+		return opts, nil
+	}
+
+	if v := value.ToObject(rt).Get("concurrency"); !common.IsNullish(v) {
+		var concurrency int64
+		if err := rt.ExportTo(v, &concurrency); err != nil || concurrency < 1 {
+			return opts, fmt.Errorf("concurrency option must be a strictly positive integer; got %v instead", v)
+		}
+
+		opts.Concurrency = int(concurrency)
+	}
+
+	return opts, nil
+}
+
+// defaultResolveAllTypes holds the record types the resolveAll function queries
+// when none are explicitly provided.
+var defaultResolveAllTypes = []string{ //nolint:gochecknoglobals
+	RecordTypeA.String(),
+	RecordTypeAAAA.String(),
+	RecordTypeMX.String(),
+	RecordTypeTXT.String(),
+	RecordTypeNS.String(),
+}
+
+// resolveAllOptions holds the options that can be passed to the resolveAll function.
+type resolveAllOptions struct {
+	resolveOptions
+
+	// Types holds the record types to query.
+	Types []string
+}
+
+// ResolveAll resolves a domain name against a nameserver for multiple record types,
+// performing the queries concurrently.
+//
+// It returns an object mapping each record type to the data of the records found.
+// When instructed not to throw, each record type is instead mapped to a result object.
+func (mi *ModuleInstance) ResolveAll(query, nameserverAddr, options sobek.Value) *sobek.Promise {
+	promise, resolve, reject := promises.New(mi.vu)
+
+	if mi.vu.State() == nil {
+		reject(errors.New("resolveAll can not be used in the init context"))
+		return promise
+	}
+
+	queryStr, err := exportDomainName(mi.vu.Runtime(), query, "query")
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	nameserver, err := mi.exportNameserver(nameserverAddr)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	opts, err := parseResolveAllOptions(mi.vu.Runtime(), options)
+	if err != nil {
+		reject(fmt.Errorf("invalid resolveAll options: %w", err))
+		return promise
+	}
+
+	ctx, cancel, err := mi.withAbortSignal(opts.Signal)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	batch := make([]batchQuery, 0, len(opts.Types))
+	for _, recordType := range opts.Types {
+		batch = append(batch, batchQuery{Name: queryStr, Type: recordType})
+	}
+
+	go func() {
+		defer cancel()
+
+		results := mi.resolveBatch(ctx, batch, nameserver, batchOptions{
+			resolveOptions: opts.resolveOptions,
+			Concurrency:    len(batch),
+		})
+
+		if !opts.Throw {
+			byType := make(map[string]*resolveResult, len(results))
+			for _, result := range results {
+				byType[result.Type] = result
+			}
+
+			resolve(byType)
+			return
+		}
+
+		byType := make(map[string][]string, len(results))
+		for _, result := range results {
+			if result.Error != nil {
+				reject(result.Error)
+				return
+			}
+
+			byType[result.Type] = result.Answers
+		}
+
+		resolve(byType)
+	}()
+
+	return promise
+}
+
+// parseResolveAllOptions parses the options object passed to the resolveAll function.
+//
+// It accepts the same options as the resolve function, along with the types option.
+func parseResolveAllOptions(rt *sobek.Runtime, value sobek.Value) (resolveAllOptions, error) {
+	resolveOpts, err := parseResolveOptions(rt, value)
+	if err != nil {
+		return resolveAllOptions{}, err
+	}
+
+	opts := resolveAllOptions{
+		resolveOptions: resolveOpts,
+		Types:          defaultResolveAllTypes,
+	}
+
+	if common.IsNullish(value) {
+		return opts, nil
+	}
+
+	if v := value.ToObject(rt).Get("types"); !common.IsNullish(v) {
+		var types []string
+		if err := rt.ExportTo(v, &types); err != nil || len(types) == 0 {
+			return opts, fmt.Errorf("types option must be a non-empty array of record types; got %v instead", v)
+		}
+
+		for _, recordType := range types {
+			if _, err := RecordTypeString(recordType); err != nil {
+				return opts, fmt.Errorf("types option holds an unsupported record type %s", recordType)
+			}
+		}
+
+		opts.Types = types
+	}
+
+	return opts, nil
+}
@@ -0,0 +1,340 @@
+package dns
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+)
+
+func TestModuleInstance_ResolveBatch(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		script  string
+		wantErr string
+	}{
+		{
+			name: "Results should be returned in the order of the queries",
+			script: `
+				const queries = [];
+				for (let i = 0; i < 20; i++) {
+					queries.push({ name: "q" + i + ".k6.test", type: "A" });
+				}
+
+				const results = await dns.resolveBatch(queries, address, { concurrency: 4 });
+				if (results.length !== queries.length) {
+					throw new Error("expected " + queries.length + " results; got " + results.length);
+				}
+
+				results.forEach((result, i) => {
+					if (result.name !== queries[i].name || result.type !== "A" || result.answers[0] !== "203.0.113." + i) {
+						throw new Error("unexpected result " + i + ": " + JSON.stringify(result));
+					}
+				});
+			`,
+		},
+		{
+			name: "No more queries than the concurrency should be in flight",
+			script: `
+				const queries = [];
+				for (let i = 0; i < 20; i++) {
+					queries.push({ name: "q" + i + ".k6.test", type: "A" });
+				}
+
+				await dns.resolveBatch(queries, address, { concurrency: 3 });
+				if (maxInFlight() !== 3) {
+					throw new Error("expected at most 3 queries in flight; got " + maxInFlight());
+				}
+			`,
+		},
+		{
+			name: "Failed queries should be reported in their result",
+			script: `
+				const results = await dns.resolveBatch([
+					{ name: "q1.k6.test", type: "A" },
+					{ name: "missing.k6.test", type: "A" },
+					{ name: "q2.k6.test", type: "A" },
+				], address);
+
+				if (results[0].error !== null || results[0].answers[0] !== "203.0.113.1" ||
+					results[2].error !== null || results[2].answers[0] !== "203.0.113.2") {
+					throw new Error("unexpected results: " + JSON.stringify(results));
+				}
+
+				if (results[1].error === null || results[1].error.name !== "NonExistingDomain" ||
+					results[1].answers.length !== 0) {
+					throw new Error("unexpected failed result: " + JSON.stringify(results[1]));
+				}
+			`,
+		},
+		{
+			name:    "Invalid concurrency options should be rejected",
+			script:  `await dns.resolveBatch([{ name: "q1.k6.test", type: "A" }], address, { concurrency: 0 });`,
+			wantErr: "invalid resolveBatch options: concurrency option must be a strictly positive integer",
+		},
+		{
+			name:    "Invalid resolve options should be rejected",
+			script:  `await dns.resolveBatch([{ name: "q1.k6.test", type: "A" }], address, { protocol: "carrier-pigeon" });`,
+			wantErr: "invalid resolveBatch options",
+		},
+		{
+			name:    "Invalid queries should be rejected",
+			script:  `await dns.resolveBatch([{ name: "q1.k6.test" }], address);`,
+			wantErr: "query 0's type must be a string",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+			require.NoError(t, err)
+
+			// The server answers q<i>.k6.test with 203.0.113.<i>, the first
+			// queries being the slowest, so that they complete out of order
+			var inFlight, maxInFlight atomic.Int64
+			server := &dns.Server{PacketConn: conn, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+				n := inFlight.Add(1)
+				defer inFlight.Add(-1)
+
+				for current := maxInFlight.Load(); n > current; current = maxInFlight.Load() {
+					if maxInFlight.CompareAndSwap(current, n) {
+						break
+					}
+				}
+
+				response := new(dns.Msg)
+				label, _, _ := strings.Cut(req.Question[0].Name, ".")
+				i, err := strconv.Atoi(strings.TrimPrefix(label, "q"))
+				if err != nil {
+					_ = w.WriteMsg(response.SetRcode(req, dns.RcodeNameError))
+					return
+				}
+
+				time.Sleep(time.Duration(40-2*i) * time.Millisecond)
+
+				rr, _ := dns.NewRR(req.Question[0].Name + " 60 IN A 203.0.113." + strconv.Itoa(i))
+				response.SetReply(req)
+				response.Answer = append(response.Answer, rr)
+				_ = w.WriteMsg(response)
+			})}
+			go func() { _ = server.ActivateAndServe() }()
+			t.Cleanup(func() { _ = server.Shutdown() })
+
+			runtime, err := newConfiguredRuntime(t)
+			require.NoError(t, err)
+			require.NoError(t, runtime.VU.Runtime().Set("address", conn.LocalAddr().String()))
+			require.NoError(t, runtime.VU.Runtime().Set("maxInFlight", maxInFlight.Load))
+
+			runtime.MoveToVUContext(&lib.State{
+				BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+				Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+				Samples:        make(chan metrics.SampleContainer, 1024),
+			})
+
+			_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(tt.script))
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestModuleInstance_ResolveBatchStream(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		script  string
+		wantErr string
+	}{
+		{
+			name: "The iterator should yield the result of each query",
+			script: `
+				const queries = [];
+				for (let i = 0; i < 20; i++) {
+					queries.push({ name: i % 2 === 0 ? "k6.test" : "missing.k6.test", type: "A" });
+				}
+
+				const results = dns.resolveBatchStream(queries, server.address, { concurrency: 4, throw: false });
+
+				let resolved = 0;
+				let failed = 0;
+				for (let r = await results.next(); !r.done; r = await results.next()) {
+					if (r.value.error === null && r.value.answers[0] === "203.0.113.1") {
+						resolved++;
+					} else if (r.value.error !== null && r.value.error.name === "NonExistingDomain") {
+						failed++;
+					}
+				}
+
+				if (resolved !== 10 || failed !== 10) {
+					throw new Error("unexpected results: " + resolved + " resolved, " + failed + " failed");
+				}
+
+				if (!(await results.next()).done) {
+					throw new Error("expected the iterator to remain done");
+				}
+			`,
+		},
+		{
+			name: "The iterator should be consumable with for await",
+			script: `
+				const queries = [];
+				for (let i = 0; i < 10; i++) {
+					queries.push({ name: "k6.test", type: "A" });
+				}
+
+				const results = dns.resolveBatchStream(queries, server.address, { concurrency: 2 });
+
+				const asyncIterator = Symbol.asyncIterator || Symbol.for("Symbol.asyncIterator");
+				if (results[asyncIterator]() !== results) {
+					throw new Error("expected the iterator to be its own async iterable");
+				}
+
+				// Runtimes which can not parse for await loops get the protocol such
+				// loops are transpiled to exercised instead
+				let consume;
+				try {
+					const AsyncFunction = (async function () {}).constructor;
+					consume = new AsyncFunction("results", ` + "`" + `
+						let count = 0;
+						for await (const result of results) {
+							if (result.answers[0] === "203.0.113.1") {
+								count++;
+							}
+						}
+						return count;
+					` + "`" + `);
+				} catch (e) {
+					if (!(e instanceof SyntaxError)) {
+						throw e;
+					}
+
+					consume = async (results) => {
+						const iterator = results[asyncIterator]();
+						let count = 0;
+						for (let r = await iterator.next(); !r.done; r = await iterator.next()) {
+							if (r.value.answers[0] === "203.0.113.1") {
+								count++;
+							}
+						}
+						return count;
+					};
+				}
+
+				const count = await consume(results);
+				if (count !== queries.length) {
+					throw new Error("expected " + queries.length + " results; got " + count);
+				}
+			`,
+		},
+		{
+			name: "Returning from the iterator should stop the remaining queries",
+			script: `
+				const queries = [];
+				for (let i = 0; i < 50; i++) {
+					queries.push({ name: "k6.test", type: "A" });
+				}
+
+				const results = dns.resolveBatchStream(queries, server.address, { concurrency: 1 });
+
+				const first = await results.next();
+				if (first.done || first.value.answers[0] !== "203.0.113.1") {
+					throw new Error("unexpected first result: " + JSON.stringify(first));
+				}
+
+				const returned = await results.return();
+				if (!returned.done) {
+					throw new Error("expected return to complete the iterator");
+				}
+
+				while (!(await results.next()).done) {}
+
+				if (server.log().length >= queries.length) {
+					throw new Error("expected the remaining queries not to be sent; got " + server.log().length);
+				}
+			`,
+		},
+		{
+			name: "The maxAnswers option should bound the answers of each result",
+			script: `
+				const results = dns.resolveBatchStream([{ name: "rr.k6.test", type: "A" }], server.address, {
+					protocol: "tcp",
+					maxAnswers: 5,
+					throw: false,
+				});
+
+				const { value } = await results.next();
+				if (value.answers.length !== 5 || value.records.length !== 5 || !value.answersTruncated ||
+					value.answerCount !== 50) {
+					throw new Error("unexpected result: " + JSON.stringify(value));
+				}
+
+				const answers = await dns.resolve("rr.k6.test", "A", server.address, {
+					protocol: "tcp",
+					maxAnswers: 3,
+				});
+				if (answers.length !== 3) {
+					throw new Error("expected 3 answers; got " + answers);
+				}
+			`,
+		},
+		{
+			name:    "Invalid queries should throw",
+			script:  `dns.resolveBatchStream([{ name: "k6.test" }], server.address);`,
+			wantErr: "type must be a string",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			runtime, err := newConfiguredRuntime(t)
+			require.NoError(t, err)
+
+			_, err = runtime.VU.Runtime().RunString(`
+				const records = ["k6.test. 60 IN A 203.0.113.1"];
+				for (let i = 1; i <= 50; i++) {
+					records.push("rr.k6.test. 60 IN A 198.51.100." + i);
+				}
+
+				const server = dns.startServer(records);
+			`)
+			require.NoError(t, err)
+
+			runtime.MoveToVUContext(&lib.State{
+				BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+				Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+				Samples:        make(chan metrics.SampleContainer, 1024),
+			})
+
+			_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(tt.script))
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,624 @@
+package dns
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/grafana/sobek"
+	"github.com/miekg/dns"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/promises"
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/metrics"
+)
+
+// defaultBenchmarkDuration is the duration of a benchmark, when neither its
+// duration nor its number of queries is specified.
+const defaultBenchmarkDuration = 10 * time.Second
+
+// PackedQueries holds DNS queries packed once into their wire format, so that
+// benchmarks can send them repeatedly without packing them again.
+type PackedQueries struct {
+	// Count holds the number of queries.
+	Count int `js:"count"`
+
+	messages [][]byte
+}
+
+// PackQueries packs the queries, an array of {name, type} objects, into their
+// wire format. It can be used in the init context, so that queries are packed
+// once per VU rather than once per iteration.
+func (mi *ModuleInstance) PackQueries(queries, options sobek.Value) (*PackedQueries, error) {
+	batch, err := exportBatchQueries(mi.vu.Runtime(), queries)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(batch) == 0 {
+		return nil, errors.New("queries must hold at least one query")
+	}
+
+	recursionDesired := true
+	if !common.IsNullish(options) {
+		if v := options.ToObject(mi.vu.Runtime()).Get("recursionDesired"); !common.IsNullish(v) {
+			recursionDesired = v.ToBoolean()
+		}
+	}
+
+	return packQueries(batch, recursionDesired)
+}
+
+// packQueries packs the queries into their wire format.
+func packQueries(batch []batchQuery, recursionDesired bool) (*PackedQueries, error) {
+	packed := &PackedQueries{Count: len(batch), messages: make([][]byte, 0, len(batch))}
+
+	for _, query := range batch {
+		recordType, err := RecordTypeString(query.Type)
+		if err != nil {
+			return nil, fmt.Errorf("%w, %s is an invalid DNS record type", ErrUnsupportedRecordType, query.Type)
+		}
+
+		msg := new(dns.Msg)
+		msg.SetQuestion(dns.Fqdn(query.Name), uint16(recordType))
+		msg.RecursionDesired = recursionDesired
+
+		message, err := msg.Pack()
+		if err != nil {
+			return nil, fmt.Errorf("packing the query for %s failed; reason: %w", query.Name, err)
+		}
+
+		packed.messages = append(packed.messages, message)
+	}
+
+	return packed, nil
+}
+
+// benchmarkOptions holds the options that can be passed to the benchmark function.
+type benchmarkOptions struct {
+	// Duration holds the duration of the benchmark, unless Count is set.
+	Duration time.Duration
+
+	// Count holds the number of queries to send, if set.
+	Count int64
+
+	// Concurrency holds the number of queries in flight at any time.
+	Concurrency int
+
+	// Timeout holds the duration after which a query is considered lost.
+	Timeout time.Duration
+
+	// Signal holds an AbortSignal-like object, which allows aborting the benchmark.
+	Signal *sobek.Object
+}
+
+// parseBenchmarkOptions parses the options object passed to the benchmark function.
+func parseBenchmarkOptions(rt *sobek.Runtime, value sobek.Value) (benchmarkOptions, error) {
+	opts := benchmarkOptions{
+		Duration:    defaultBenchmarkDuration,
+		Concurrency: defaultBatchConcurrency,
+		Timeout:     defaultAttemptTimeout,
+	}
+
+	if common.IsNullish(value) {
+		return opts, nil
+	}
+
+	params := value.ToObject(rt)
+
+	for _, duration := range []struct {
+		option string
+		value  *time.Duration
+	}{
+		{option: "duration", value: &opts.Duration},
+		{option: "timeout", value: &opts.Timeout},
+	} {
+		v := params.Get(duration.option)
+		if common.IsNullish(v) {
+			continue
+		}
+
+		d, err := types.GetDurationValue(v.Export())
+		if err != nil {
+			return opts, fmt.Errorf("%s option is invalid; reason: %w", duration.option, err)
+		}
+
+		if d <= 0 {
+			return opts, fmt.Errorf("%s option must be a strictly positive duration; got %v instead", duration.option, v)
+		}
+
+		*duration.value = d
+	}
+
+	if v := params.Get("count"); !common.IsNullish(v) {
+		if err := rt.ExportTo(v, &opts.Count); err != nil || opts.Count < 1 {
+			return opts, fmt.Errorf("count option must be a strictly positive integer; got %v instead", v)
+		}
+	}
+
+	if v := params.Get("concurrency"); !common.IsNullish(v) {
+		var concurrency int64
+		if err := rt.ExportTo(v, &concurrency); err != nil || concurrency < 1 {
+			return opts, fmt.Errorf("concurrency option must be a strictly positive integer; got %v instead", v)
+		}
+
+		opts.Concurrency = int(concurrency)
+	}
+
+	if v := params.Get("signal"); !common.IsNullish(v) {
+		signal, ok := v.(*sobek.Object)
+		if !ok {
+			return opts, fmt.Errorf("signal option must be an AbortSignal; got %v instead", v)
+		}
+
+		opts.Signal = signal
+	}
+
+	return opts, nil
+}
+
+// benchmarkResult is the object the benchmark function resolves to.
+type benchmarkResult struct {
+	// Sent holds the number of queries which were sent, and either answered, lost
+	// or failed, before the benchmark ended.
+	Sent int64 `js:"sent"`
+
+	// Received holds the number of queries which were answered.
+	Received int64 `js:"received"`
+
+	// Lost holds the number of queries which were not answered in time.
+	Lost int64 `js:"lost"`
+
+	// Errors holds the number of queries which could not be sent, or whose response
+	// could not be read.
+	Errors int64 `js:"errors"`
+
+	// Rcodes holds the number of responses, by response code name.
+	Rcodes map[string]int64 `js:"rcodes"`
+
+	// Duration holds the duration of the benchmark, in milliseconds.
+	Duration float64 `js:"duration"`
+
+	// QPS holds the number of queries answered per second.
+	QPS float64 `js:"qps"`
+
+	// Latency holds the distribution of the latency of the answered queries.
+	Latency benchmarkLatency `js:"latency"`
+}
+
+// benchmarkLatency describes the distribution of the latency of the queries
+// answered during a benchmark, in milliseconds.
+type benchmarkLatency struct {
+	Min float64 `js:"min"`
+	Avg float64 `js:"avg"`
+	Med float64 `js:"med"`
+	P90 float64 `js:"p90"`
+	P95 float64 `js:"p95"`
+	P99 float64 `js:"p99"`
+	Max float64 `js:"max"`
+}
+
+// Benchmark sends the packed queries to the nameserver as fast as it answers
+// them, with a fixed number of queries in flight, for a duration or a number of
+// queries, and resolves to the statistics of the run.
+//
+// To sustain high rates, queries are sent over UDP out of pooled buffers, only
+// the header of responses is read, and metrics are emitted once the benchmark
+// ends rather than for each query.
+func (mi *ModuleInstance) Benchmark(queries, nameserverAddr, options sobek.Value) *sobek.Promise {
+	promise, resolve, reject := promises.New(mi.vu)
+
+	if mi.vu.State() == nil {
+		reject(errors.New("benchmark can not be used in the init context"))
+		return promise
+	}
+
+	packed, ok := exportPackedQueries(queries)
+	if !ok {
+		reject(fmt.Errorf("queries must be packed with packQueries(); got %v instead", queries))
+		return promise
+	}
+
+	nameserver, err := mi.exportNameserver(nameserverAddr)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	opts, err := parseBenchmarkOptions(mi.vu.Runtime(), options)
+	if err != nil {
+		reject(fmt.Errorf("invalid benchmark options: %w", err))
+		return promise
+	}
+
+	ctx, cancel, err := mi.withAbortSignal(opts.Signal)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer cancel()
+
+		result, err := mi.benchmark(ctx, packed, nameserver, opts)
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(result)
+	}()
+
+	return promise
+}
+
+// exportPackedQueries exports the queries packed by PackQueries from the JS runtime.
+func exportPackedQueries(value sobek.Value) (*PackedQueries, bool) {
+	if common.IsNullish(value) {
+		return nil, false
+	}
+
+	packed, ok := value.Export().(*PackedQueries)
+
+	return packed, ok && packed != nil
+}
+
+// benchmarkStats holds the statistics of a benchmark, as gathered by one of its
+// workers.
+type benchmarkStats struct {
+	received int64
+	lost     int64
+	errors   int64
+	rcodes   [16]int64
+	latency  latencyHistogram
+}
+
+// benchmark runs the benchmark of the packed queries against the nameserver, and
+// emits its metrics.
+func (mi *ModuleInstance) benchmark(
+	ctx context.Context,
+	packed *PackedQueries,
+	nameserver Nameserver,
+	opts benchmarkOptions,
+) (*benchmarkResult, error) {
+	result, err := runBenchmark(ctx, packed, nameserver, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	mi.emitBenchmarkMetrics(nameserver, result)
+
+	return result, nil
+}
+
+// runBenchmark runs the benchmark of the packed queries against the nameserver.
+func runBenchmark(
+	ctx context.Context,
+	packed *PackedQueries,
+	nameserver Nameserver,
+	opts benchmarkOptions,
+) (*benchmarkResult, error) {
+	if opts.Count == 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Duration)
+		defer cancel()
+	}
+
+	conns := make([]net.Conn, 0, opts.Concurrency)
+	defer func() {
+		for _, conn := range conns {
+			_ = conn.Close()
+		}
+	}()
+
+	var dialer net.Dialer
+	for len(conns) < opts.Concurrency {
+		conn, err := dialer.DialContext(ctx, "udp", nameserver.Addr())
+		if err != nil {
+			return nil, withNameserver(newExchangeError(err, "querying the DNS nameserver failed"), nameserver)
+		}
+
+		conns = append(conns, conn)
+	}
+
+	var sent atomic.Int64
+	next := func() (int, bool) {
+		n := sent.Add(1) - 1
+		if opts.Count > 0 && n >= opts.Count {
+			return 0, false
+		}
+
+		return int(n % int64(len(packed.messages))), true
+	}
+
+	start := time.Now()
+	stats := make([]benchmarkStats, len(conns))
+
+	var wg sync.WaitGroup
+	for i, conn := range conns {
+		wg.Add(1)
+
+		go func(conn net.Conn, stats *benchmarkStats) {
+			defer wg.Done()
+
+			runBenchmarkWorker(ctx, conn, packed.messages, next, opts.Timeout, stats)
+		}(conn, &stats[i])
+	}
+
+	wg.Wait()
+
+	return newBenchmarkResult(stats, time.Since(start)), nil
+}
+
+// runBenchmarkWorker sends the messages next designates over the connection, one
+// at a time, until next tells it to stop or the context is done, and gathers the
+// statistics of the exchanges.
+func runBenchmarkWorker(
+	ctx context.Context,
+	conn net.Conn,
+	messages [][]byte,
+	next func() (int, bool),
+	timeout time.Duration,
+	stats *benchmarkStats,
+) {
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	// Sending queries and reading responses does not allocate
+	query := getBuffer(dns.MaxMsgSize)
+	response := getBuffer(dns.MaxMsgSize)
+	defer putBuffer(query)
+	defer putBuffer(response)
+
+	id := uint16(dns.Id())
+
+	for ctx.Err() == nil {
+		i, ok := next()
+		if !ok {
+			return
+		}
+
+		// Each query gets its own ID, so that late responses are told apart
+		id++
+		n := copy(*query, messages[i])
+		binary.BigEndian.PutUint16((*query)[:2], id)
+
+		sentAt := time.Now()
+		_ = conn.SetDeadline(sentAt.Add(timeout))
+
+		rcode, err := exchangeBenchmarkQuery(conn, (*query)[:n], *response, id)
+
+		switch {
+		case ctx.Err() != nil:
+			// The query was cut short by the end of the benchmark, and is not accounted for
+			return
+		case err == nil:
+			stats.received++
+			stats.rcodes[rcode]++
+			stats.latency.record(time.Since(sentAt))
+		case isTimeout(err):
+			stats.lost++
+		default:
+			stats.errors++
+		}
+	}
+}
+
+// exchangeBenchmarkQuery writes the query to the connection, and reads responses
+// into the buffer until one with the ID is received. It returns the response
+// code of the response, as found in its header.
+func exchangeBenchmarkQuery(conn net.Conn, query, buf []byte, id uint16) (int, error) {
+	if _, err := conn.Write(query); err != nil {
+		return 0, err
+	}
+
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return 0, err
+		}
+
+		// Late responses to earlier queries, and anything but responses, are skipped
+		const headerSize = 12
+		if n < headerSize || binary.BigEndian.Uint16(buf[:2]) != id || buf[2]&0x80 == 0 {
+			continue
+		}
+
+		return int(buf[3] & 0x0f), nil
+	}
+}
+
+// newBenchmarkResult creates a benchmarkResult out of the statistics gathered by
+// the workers of a benchmark which lasted for the duration.
+func newBenchmarkResult(stats []benchmarkStats, duration time.Duration) *benchmarkResult {
+	result := &benchmarkResult{
+		Rcodes:   make(map[string]int64),
+		Duration: float64(duration) / float64(time.Millisecond),
+	}
+
+	var latency latencyHistogram
+	for i := range stats {
+		result.Received += stats[i].received
+		result.Lost += stats[i].lost
+		result.Errors += stats[i].errors
+
+		for rcode, count := range stats[i].rcodes {
+			if count > 0 {
+				result.Rcodes[dns.RcodeToString[rcode]] += count
+			}
+		}
+
+		latency.merge(&stats[i].latency)
+	}
+
+	result.Sent = result.Received + result.Lost + result.Errors
+	if duration > 0 {
+		result.QPS = float64(result.Received) / duration.Seconds()
+	}
+
+	result.Latency = latency.summary()
+
+	return result
+}
+
+// latencyHistogramGrowth is the growth factor of the bounds of the buckets of a
+// latencyHistogram, bounding the error of its percentiles to 1%.
+const latencyHistogramGrowth = 1.01
+
+// latencyHistogramBuckets is the number of buckets of a latencyHistogram, covering
+// latencies from 1 microsecond to over a minute.
+const latencyHistogramBuckets = 1900
+
+// latencyHistogram records latencies, in milliseconds, into buckets of
+// exponentially growing bounds, so that its size does not grow with the number of
+// latencies recorded.
+type latencyHistogram struct {
+	buckets [latencyHistogramBuckets]int64
+	count   int64
+	sum     float64
+	min     float64
+	max     float64
+}
+
+// record records the latency.
+func (h *latencyHistogram) record(latency time.Duration) {
+	ms := float64(latency) / float64(time.Millisecond)
+
+	if h.count == 0 || ms < h.min {
+		h.min = ms
+	}
+
+	if ms > h.max {
+		h.max = ms
+	}
+
+	h.count++
+	h.sum += ms
+	h.buckets[latencyBucket(latency)]++
+}
+
+// latencyBucket returns the index of the bucket the latency falls into.
+func latencyBucket(latency time.Duration) int {
+	us := float64(latency) / float64(time.Microsecond)
+	if us <= 1 {
+		return 0
+	}
+
+	bucket := int(math.Log(us) / math.Log(latencyHistogramGrowth))
+
+	return min(bucket, latencyHistogramBuckets-1)
+}
+
+// merge merges the latencies recorded by the other histogram into the histogram.
+func (h *latencyHistogram) merge(other *latencyHistogram) {
+	if other.count == 0 {
+		return
+	}
+
+	if h.count == 0 || other.min < h.min {
+		h.min = other.min
+	}
+
+	if other.max > h.max {
+		h.max = other.max
+	}
+
+	h.count += other.count
+	h.sum += other.sum
+
+	for i, count := range other.buckets {
+		h.buckets[i] += count
+	}
+}
+
+// mean returns the mean of the latencies, or zero if none was recorded.
+func (h *latencyHistogram) mean() float64 {
+	if h.count == 0 {
+		return 0
+	}
+
+	return h.sum / float64(h.count)
+}
+
+// percentile returns an estimate of the percentile of the latencies, bounded by
+// their minimum and maximum, or zero if none was recorded.
+func (h *latencyHistogram) percentile(p float64) float64 {
+	if h.count == 0 {
+		return 0
+	}
+
+	rank := int64(math.Ceil(p / 100 * float64(h.count)))
+
+	var seen int64
+	for i, count := range h.buckets {
+		seen += count
+		if seen < rank {
+			continue
+		}
+
+		// The upper bound of the bucket, in milliseconds
+		estimate := math.Pow(latencyHistogramGrowth, float64(i+1)) / 1000
+
+		return math.Max(h.min, math.Min(h.max, estimate))
+	}
+
+	return h.max
+}
+
+// summary describes the distribution of the recorded latencies.
+func (h *latencyHistogram) summary() benchmarkLatency {
+	return benchmarkLatency{
+		Min: h.min,
+		Avg: h.mean(),
+		Med: h.percentile(50),
+		P90: h.percentile(90),
+		P95: h.percentile(95),
+		P99: h.percentile(99),
+		Max: h.max,
+	}
+}
+
+// emitBenchmarkMetrics emits the number of queries sent during a benchmark, by
+// outcome: the name of the response code, "timeout" or "error".
+func (mi *ModuleInstance) emitBenchmarkMetrics(nameserver Nameserver, result *benchmarkResult) {
+	state := mi.vu.State()
+	if state == nil {
+		return
+	}
+
+	tags := state.Tags.GetCurrentValues().Tags
+	tags = tags.With("nameserver", nameserver.Addr())
+
+	outcomes := make(map[string]int64, len(result.Rcodes)+2)
+	for rcode, count := range result.Rcodes {
+		outcomes[rcode] = count
+	}
+
+	outcomes["timeout"] = result.Lost
+	outcomes["error"] = result.Errors
+
+	now := time.Now()
+	for outcome, count := range outcomes {
+		if count == 0 {
+			continue
+		}
+
+		metrics.PushIfNotDone(mi.vu.Context(), state.Samples, metrics.Sample{
+			TimeSeries: metrics.TimeSeries{
+				Metric: mi.metrics.DNSBenchmarkQueries,
+				Tags:   tags.With("outcome", outcome),
+			},
+			Time:     now,
+			Value:    float64(count),
+			Metadata: nil,
+		})
+	}
+}
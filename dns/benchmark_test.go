@@ -0,0 +1,106 @@
+package dns
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+)
+
+func TestBenchmark(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Benchmarking a started server should succeed", func(t *testing.T) {
+		t.Parallel()
+
+		runtime, err := newConfiguredRuntime(t)
+		require.NoError(t, err)
+
+		_, err = runtime.VU.Runtime().RunString(`
+			const server = dns.startServer(["k6.test. 60 IN A 203.0.113.1"]);
+			const queries = dns.packQueries([
+				{ name: "k6.test", type: "A" },
+				{ name: "missing.k6.test", type: "A" },
+			]);
+		`)
+		require.NoError(t, err)
+
+		runtime.MoveToVUContext(&lib.State{
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+			Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+			Samples:        make(chan metrics.SampleContainer, 1024),
+		})
+
+		_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+			const result = await dns.benchmark(queries, server.address, { count: 100, concurrency: 4 });
+
+			if (result.sent !== 100 || result.received !== 100) {
+				throw "expected 100 queries to be sent and answered; got " + JSON.stringify(result);
+			}
+
+			if (result.rcodes.NOERROR !== 50 || result.rcodes.NXDOMAIN !== 50) {
+				throw "expected queries to be sent in turn; got " + JSON.stringify(result.rcodes);
+			}
+
+			if (server.queries() !== 100) {
+				throw "expected the server to receive 100 queries; got " + server.queries();
+			}
+		`))
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("Benchmarking in the init context should fail", func(t *testing.T) {
+		t.Parallel()
+
+		runtime, err := newConfiguredRuntime(t)
+		require.NoError(t, err)
+
+		_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+			await dns.benchmark(dns.packQueries([{ name: "k6.test", type: "A" }]), "127.0.0.1:53");
+		`))
+
+		assert.Error(t, err)
+	})
+}
+
+func Test_packQueries(t *testing.T) {
+	t.Parallel()
+
+	packed, err := packQueries([]batchQuery{{Name: "k6.test", Type: "AAAA"}}, false)
+	require.NoError(t, err)
+	require.Len(t, packed.messages, 1)
+
+	msg := new(dns.Msg)
+	require.NoError(t, msg.Unpack(packed.messages[0]))
+
+	assert.Equal(t, "k6.test.", msg.Question[0].Name)
+	assert.Equal(t, dns.TypeAAAA, msg.Question[0].Qtype)
+	assert.False(t, msg.RecursionDesired)
+
+	_, err = packQueries([]batchQuery{{Name: "k6.test", Type: "BOGUS"}}, true)
+	assert.ErrorIs(t, err, ErrUnsupportedRecordType)
+}
+
+func Test_latencyHistogram(t *testing.T) {
+	t.Parallel()
+
+	var histogram latencyHistogram
+	for i := 1; i <= 100; i++ {
+		histogram.record(time.Duration(i) * time.Millisecond)
+	}
+
+	var merged latencyHistogram
+	merged.merge(&histogram)
+
+	assert.InDelta(t, 1, merged.min, 0.001)
+	assert.InDelta(t, 100, merged.max, 0.001)
+	assert.InDelta(t, 50.5, merged.mean(), 0.001)
+	assert.InEpsilon(t, 50, merged.percentile(50), 0.01)
+	assert.InEpsilon(t, 99, merged.percentile(99), 0.01)
+	assert.Zero(t, new(latencyHistogram).percentile(50))
+}
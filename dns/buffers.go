@@ -0,0 +1,105 @@
+package dns
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// headerSize is the size of the header of DNS messages, in bytes.
+const headerSize = 12
+
+// bufferSizes holds the sizes of the buffers messages are read into, from the
+// smallest to the largest: the payload size every DNS message fits in over UDP,
+// the payload size recommended by the DNS flag day 2020, the size EDNS payloads
+// are commonly advertised with, and the maximum size of a DNS message.
+var bufferSizes = [...]int{dns.MinMsgSize, 1232, dns.DefaultMsgSize, dns.MaxMsgSize} //nolint:gochecknoglobals
+
+// buffers holds a pool of buffers for each of the bufferSizes, so that reading
+// messages does not allocate.
+var buffers [len(bufferSizes)]sync.Pool //nolint:gochecknoglobals
+
+// getBuffer returns a pooled buffer of at least the size, which should be
+// returned to the pool with putBuffer once it is no longer used.
+func getBuffer(size int) *[]byte {
+	for i, bufferSize := range bufferSizes {
+		if size > bufferSize {
+			continue
+		}
+
+		if buf, ok := buffers[i].Get().(*[]byte); ok {
+			return buf
+		}
+
+		buf := make([]byte, bufferSize)
+
+		return &buf
+	}
+
+	buf := make([]byte, size)
+
+	return &buf
+}
+
+// putBuffer returns a buffer obtained from getBuffer to the pool.
+func putBuffer(buf *[]byte) {
+	for i, bufferSize := range bufferSizes {
+		if len(*buf) == bufferSize {
+			buffers[i].Put(buf)
+			return
+		}
+	}
+}
+
+// readMsg reads a DNS message from the connection into a pooled buffer: a single
+// datagram of up to udpSize bytes over UDP, or a message prefixed by its length
+// over TCP and TLS, which might span multiple reads. It returns the message along
+// with the buffer holding it, which should be returned to the pool with putBuffer
+// once the message is no longer used.
+func readMsg(conn net.Conn, udpSize uint16) ([]byte, *[]byte, error) {
+	if _, isPacketConn := conn.(net.PacketConn); isPacketConn {
+		buf := getBuffer(max(int(udpSize), dns.MinMsgSize))
+
+		n, err := conn.Read(*buf)
+		if err != nil {
+			putBuffer(buf)
+			return nil, nil, err
+		}
+
+		return checkMsg((*buf)[:n], buf)
+	}
+
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, nil, err
+	}
+
+	buf := getBuffer(int(binary.BigEndian.Uint16(length[:])))
+	msg := (*buf)[:binary.BigEndian.Uint16(length[:])]
+
+	if _, err := io.ReadFull(conn, msg); err != nil {
+		putBuffer(buf)
+		return nil, nil, err
+	}
+
+	return checkMsg(msg, buf)
+}
+
+// checkMsg returns the message read into the buffer, or dns.ErrShortRead if it is
+// too short to hold a header, in which case the buffer is returned to the pool.
+func checkMsg(msg []byte, buf *[]byte) ([]byte, *[]byte, error) {
+	if len(msg) < headerSize {
+		putBuffer(buf)
+		return nil, nil, dns.ErrShortRead
+	}
+
+	return msg, buf, nil
+}
+
+// msgID returns the ID of the DNS message read by readMsg.
+func msgID(msg []byte) uint16 {
+	return binary.BigEndian.Uint16(msg)
+}
@@ -0,0 +1,122 @@
+package dns
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_readMsg(t *testing.T) {
+	t.Parallel()
+
+	// A response larger than the default buffer sizes, as DNSSEC-signed ones are
+	response := new(dns.Msg)
+	response.SetQuestion("k6.test.", dns.TypeTXT)
+	response.Id = 4242
+	for i := 0; i < 40; i++ {
+		rr, err := dns.NewRR("k6.test. 60 IN TXT \"" + string(make([]byte, 200)) + "\"")
+		require.NoError(t, err)
+		response.Answer = append(response.Answer, rr)
+	}
+
+	packed, err := response.Pack()
+	require.NoError(t, err)
+	require.Greater(t, len(packed), dns.DefaultMsgSize)
+
+	t.Run("length-prefixed message spanning multiple reads", func(t *testing.T) {
+		t.Parallel()
+
+		client, server := net.Pipe()
+		t.Cleanup(func() { _ = client.Close() })
+
+		go func() {
+			defer server.Close() //nolint:errcheck
+
+			framed := append([]byte{byte(len(packed) >> 8), byte(len(packed))}, packed...)
+			for len(framed) > 0 {
+				n := min(len(framed), 100)
+				if _, err := server.Write(framed[:n]); err != nil {
+					return
+				}
+
+				framed = framed[n:]
+			}
+		}()
+
+		msg, buf, err := readMsg(client, 0)
+		require.NoError(t, err)
+		defer putBuffer(buf)
+
+		assert.Equal(t, packed, msg)
+		assert.Equal(t, uint16(4242), msgID(msg))
+	})
+
+	t.Run("datagram sized from the advertised EDNS payload size", func(t *testing.T) {
+		t.Parallel()
+
+		client, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = client.Close() })
+
+		server, err := net.DialUDP("udp", nil, client.LocalAddr().(*net.UDPAddr)) //nolint:forcetypeassert
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = server.Close() })
+
+		_, err = server.Write(packed)
+		require.NoError(t, err)
+
+		msg, buf, err := readMsg(client, dns.MaxMsgSize)
+		require.NoError(t, err)
+		defer putBuffer(buf)
+
+		assert.Equal(t, packed, msg)
+	})
+
+	t.Run("message shorter than a header", func(t *testing.T) {
+		t.Parallel()
+
+		client, server := net.Pipe()
+		t.Cleanup(func() { _ = client.Close() })
+
+		go func() {
+			defer server.Close() //nolint:errcheck
+
+			_, _ = server.Write([]byte{0, 2, 0x10, 0x92})
+		}()
+
+		_, _, err := readMsg(client, 0)
+		assert.ErrorIs(t, err, dns.ErrShortRead)
+	})
+}
+
+func Test_getBuffer(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		size int
+		want int
+	}{
+		{size: 0, want: dns.MinMsgSize},
+		{size: dns.MinMsgSize, want: dns.MinMsgSize},
+		{size: 1000, want: 1232},
+		{size: 1400, want: dns.DefaultMsgSize},
+		{size: 5000, want: dns.MaxMsgSize},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(fmt.Sprintf("%d bytes", tt.size), func(t *testing.T) {
+			t.Parallel()
+
+			buf := getBuffer(tt.size)
+			defer putBuffer(buf)
+
+			assert.Len(t, *buf, tt.want)
+		})
+	}
+}
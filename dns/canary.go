@@ -0,0 +1,296 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/grafana/sobek"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/promises"
+	"go.k6.io/k6/metrics"
+)
+
+// canaryOptions holds the options that can be passed to the canary function.
+type canaryOptions struct {
+	resolveOptions
+
+	// Trusted holds the address of the trusted resolver the UDP answers are
+	// checked against.
+	Trusted Nameserver
+
+	// TrustedOptions holds the options of the query sent to the trusted resolver,
+	// over an authenticated protocol.
+	TrustedOptions resolveOptions
+
+	// Match holds how the UDP answers are compared to the trusted ones, one of
+	// recordMatchExact, or recordMatchContains for the trusted answers to hold at
+	// least the UDP ones, e.g. for names whose answers vary across queries.
+	Match string
+}
+
+// parseCanaryOptions parses the options object passed to the canary function.
+//
+// It accepts the same options as the resolve function, along with the required
+// trusted option, and the match option.
+func parseCanaryOptions(rt *sobek.Runtime, value sobek.Value) (canaryOptions, error) {
+	resolveOpts, err := parseResolveOptions(rt, value)
+	if err != nil {
+		return canaryOptions{}, err
+	}
+
+	// Names which do not exist simply hold no records
+	resolveOpts.NXDomainAsEmpty = true
+
+	opts := canaryOptions{
+		resolveOptions: resolveOpts,
+		TrustedOptions: resolveOpts,
+		Match:          recordMatchExact,
+	}
+
+	opts.Protocol = protocolUDP
+	opts.TrustedOptions.Protocol = protocolDoT
+
+	if common.IsNullish(value) {
+		return opts, errors.New("trusted option is required")
+	}
+
+	params := value.ToObject(rt)
+
+	v := params.Get("trusted")
+	if common.IsNullish(v) {
+		return opts, errors.New("trusted option is required")
+	}
+
+	trusted := v.ToObject(rt)
+
+	addr := trusted.Get("nameserver")
+	if common.IsNullish(addr) {
+		return opts, errors.New("trusted option must hold the nameserver of the trusted resolver")
+	}
+
+	if opts.Trusted, err = parseNameserverAddr(addr.String()); err != nil {
+		return opts, fmt.Errorf("trusted nameserver is invalid; reason: %w", err)
+	}
+
+	if err := parseTransportOptions(rt, trusted, &opts.TrustedOptions.QueryOptions); err != nil {
+		return opts, fmt.Errorf("trusted option is invalid; reason: %w", err)
+	}
+
+	switch opts.TrustedOptions.Protocol {
+	case protocolDoT, protocolDoH:
+	default:
+		return opts, fmt.Errorf(
+			"trusted protocol must be one of 'dot' or 'doh'; got %q instead",
+			opts.TrustedOptions.Protocol,
+		)
+	}
+
+	if v := params.Get("match"); !common.IsNullish(v) {
+		if opts.Match, err = parseRecordMatch(v); err != nil {
+			return opts, err
+		}
+	}
+
+	return opts, nil
+}
+
+// canaryResult is the object the canary function resolves to.
+type canaryResult struct {
+	// Name holds the name which was resolved.
+	Name string `js:"name"`
+
+	// Type holds the record type which was resolved.
+	Type string `js:"type"`
+
+	// Checked indicates whether both resolutions succeeded, and their answers
+	// could thus be compared.
+	Checked bool `js:"checked"`
+
+	// Mismatch indicates whether the UDP answers, or response code, differ from
+	// the trusted resolver's.
+	Mismatch bool `js:"mismatch"`
+
+	// Answers holds the answers received over UDP.
+	Answers []string `js:"answers"`
+
+	// TrustedAnswers holds the answers of the trusted resolver.
+	TrustedAnswers []string `js:"trustedAnswers"`
+
+	// Unexpected holds the answers received over UDP which the trusted resolver
+	// did not answer with.
+	Unexpected []string `js:"unexpected"`
+
+	// Rcode holds the name of the response code received over UDP, if any.
+	Rcode string `js:"rcode"`
+
+	// TrustedRcode holds the name of the response code of the trusted resolver,
+	// if any.
+	TrustedRcode string `js:"trustedRcode"`
+
+	// Error holds the error the UDP resolution failed with, if any.
+	Error *Error `js:"error"`
+
+	// TrustedError holds the error the trusted resolution failed with, if any.
+	TrustedError *Error `js:"trustedError"`
+}
+
+// Canary resolves a domain name over UDP against a nameserver, and over an
+// authenticated channel against a trusted resolver, and resolves to whether
+// their answers match, which acts as a canary for cache poisoning and spoofed
+// responses during long soak tests.
+//
+// The promise is not rejected when resolutions fail, which is instead reported in
+// the result.
+func (mi *ModuleInstance) Canary(query, recordType, nameserverAddr, options sobek.Value) *sobek.Promise {
+	promise, resolve, reject := promises.New(mi.vu)
+
+	if mi.vu.State() == nil {
+		reject(errors.New("canary can not be used in the init context"))
+		return promise
+	}
+
+	queryStr, err := exportDomainName(mi.vu.Runtime(), query, "query")
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	var recordTypeStr string
+	if err := mi.vu.Runtime().ExportTo(recordType, &recordTypeStr); err != nil {
+		reject(fmt.Errorf("recordType must be a string; got %v instead", recordType))
+		return promise
+	}
+
+	if _, err := RecordTypeString(recordTypeStr); err != nil {
+		reject(fmt.Errorf("%w, %s is an invalid DNS record type", ErrUnsupportedRecordType, recordTypeStr))
+		return promise
+	}
+
+	nameserver, err := mi.exportNameserver(nameserverAddr)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	opts, err := parseCanaryOptions(mi.vu.Runtime(), options)
+	if err != nil {
+		reject(fmt.Errorf("invalid canary options: %w", err))
+		return promise
+	}
+
+	ctx, cancel, err := mi.withAbortSignal(opts.Signal)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer cancel()
+
+		resolve(mi.canary(ctx, queryStr, recordTypeStr, nameserver, opts))
+	}()
+
+	return promise
+}
+
+// canary resolves the query over UDP and against the trusted resolver
+// concurrently, so that the answers are as close in time as possible, and
+// compares them.
+func (mi *ModuleInstance) canary(
+	ctx context.Context,
+	query, recordType string,
+	nameserver Nameserver,
+	opts canaryOptions,
+) *canaryResult {
+	result := &canaryResult{
+		Name:           query,
+		Type:           recordType,
+		Answers:        []string{},
+		TrustedAnswers: []string{},
+		Unexpected:     []string{},
+	}
+
+	var wg sync.WaitGroup
+	var trusted *Response
+	var trustedErr error
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		trusted, _, trustedErr = mi.resolveQuery(ctx, query, recordType, opts.Trusted, opts.TrustedOptions)
+	}()
+
+	response, _, err := mi.resolveQuery(ctx, query, recordType, nameserver, opts.resolveOptions)
+
+	wg.Wait()
+
+	result.Error = asError(err)
+	result.TrustedError = asError(trustedErr)
+
+	if response != nil {
+		result.Rcode = response.Rcode
+		if err == nil && response.Answers != nil {
+			result.Answers = response.Answers
+		}
+	}
+
+	if trusted != nil {
+		result.TrustedRcode = trusted.Rcode
+		if trustedErr == nil && trusted.Answers != nil {
+			result.TrustedAnswers = trusted.Answers
+		}
+	}
+
+	if err != nil || trustedErr != nil {
+		return result
+	}
+
+	result.compare(opts.Match)
+
+	mi.emitCanaryMetrics(mi.vu.Context(), query, recordType, nameserver, result.Mismatch)
+
+	return result
+}
+
+// compare compares the UDP answers to the trusted ones, in the match mode, and
+// records whether they differ in the result.
+func (r *canaryResult) compare(match string) {
+	r.Checked = true
+	r.Unexpected = diffAnswers(r.Answers, r.TrustedAnswers)
+	r.Mismatch = r.Rcode != r.TrustedRcode || !answersMatch(r.TrustedAnswers, r.Answers, match)
+}
+
+// emitCanaryMetrics emits whether the UDP answers of the nameserver differ from
+// the trusted resolver's.
+func (mi *ModuleInstance) emitCanaryMetrics(
+	ctx context.Context,
+	query, recordType string,
+	nameserver Nameserver,
+	mismatch bool,
+) {
+	state := mi.vu.State()
+
+	tags := state.Tags.GetCurrentValues().Tags
+	tags = tags.With("query", query)
+	tags = tags.With("recordType", recordType)
+	tags = tags.With("nameserver", nameserver.Addr())
+
+	var value float64
+	if mismatch {
+		value = 1
+	}
+
+	metrics.PushIfNotDone(ctx, state.Samples, metrics.Sample{
+		TimeSeries: metrics.TimeSeries{
+			Metric: mi.metrics.DNSCanaryMismatch,
+			Tags:   tags,
+		},
+		Time:     time.Now(),
+		Value:    value,
+		Metadata: nil,
+	})
+}
@@ -0,0 +1,157 @@
+package dns
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+)
+
+func Test_canaryResult_compare(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		answers        []string
+		trustedAnswers []string
+		rcode          string
+		trustedRcode   string
+		match          string
+		wantMismatch   bool
+		wantUnexpected []string
+	}{
+		{
+			name:           "same answers in another order and case",
+			answers:        []string{"ns2.k6.test.", "NS1.k6.test."},
+			trustedAnswers: []string{"ns1.k6.test", "ns2.k6.test"},
+			match:          recordMatchExact,
+			wantUnexpected: []string{},
+		},
+		{
+			name:           "spoofed answer",
+			answers:        []string{"198.51.100.66"},
+			trustedAnswers: []string{"203.0.113.1"},
+			match:          recordMatchExact,
+			wantMismatch:   true,
+			wantUnexpected: []string{"198.51.100.66"},
+		},
+		{
+			name:           "subset of rotating answers",
+			answers:        []string{"203.0.113.1"},
+			trustedAnswers: []string{"203.0.113.1", "203.0.113.2"},
+			match:          recordMatchContains,
+			wantUnexpected: []string{},
+		},
+		{
+			name:           "subset of answers in the exact mode",
+			answers:        []string{"203.0.113.1"},
+			trustedAnswers: []string{"203.0.113.1", "203.0.113.2"},
+			match:          recordMatchExact,
+			wantMismatch:   true,
+			wantUnexpected: []string{},
+		},
+		{
+			name:           "spoofed name error",
+			answers:        []string{},
+			trustedAnswers: []string{},
+			rcode:          "NXDOMAIN",
+			trustedRcode:   "NOERROR",
+			match:          recordMatchExact,
+			wantMismatch:   true,
+			wantUnexpected: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			result := &canaryResult{
+				Answers:        tt.answers,
+				TrustedAnswers: tt.trustedAnswers,
+				Rcode:          tt.rcode,
+				TrustedRcode:   tt.trustedRcode,
+			}
+
+			result.compare(tt.match)
+
+			assert.True(t, result.Checked)
+			assert.Equal(t, tt.wantMismatch, result.Mismatch)
+			assert.Equal(t, tt.wantUnexpected, result.Unexpected)
+		})
+	}
+}
+
+func TestCanary(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Failed trusted resolutions should leave the answers unchecked", func(t *testing.T) {
+		t.Parallel()
+
+		runtime, err := newConfiguredRuntime(t)
+		require.NoError(t, err)
+
+		_, err = runtime.VU.Runtime().RunString(`
+			const server = dns.startServer(["k6.test. 60 IN A 203.0.113.1"]);
+		`)
+		require.NoError(t, err)
+
+		runtime.MoveToVUContext(&lib.State{
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+			Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+			Samples:        make(chan metrics.SampleContainer, 1024),
+		})
+
+		_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+			// The test server does not speak TLS
+			const result = await dns.canary("k6.test", "A", server.address, {
+				trusted: { nameserver: server.address },
+				timeout: "500ms",
+				retries: 0,
+			});
+
+			if (result.checked || result.mismatch || result.error !== null || result.trustedError === null) {
+				throw new Error("unexpected result: " + JSON.stringify(result));
+			}
+
+			if (result.answers.join() !== "203.0.113.1" || result.rcode !== "NOERROR") {
+				throw new Error("unexpected UDP answers: " + JSON.stringify(result));
+			}
+
+			const logged = server.log().map((entry) => entry.protocol);
+			if (!logged.includes("udp")) {
+				throw new Error("unexpected queries: " + JSON.stringify(server.log()));
+			}
+		`))
+		assert.NoError(t, err)
+	})
+
+	t.Run("Invalid options should be rejected", func(t *testing.T) {
+		t.Parallel()
+
+		tests := map[string]string{
+			`undefined`:                        "trusted option is required",
+			`{ trusted: { protocol: "doh" } }`: "must hold the nameserver",
+			`{ trusted: { nameserver: "1.1.1.1:53", protocol: "udp" } }`: "trusted protocol must be one of",
+		}
+
+		for options, want := range tests {
+			runtime, err := newConfiguredRuntime(t)
+			require.NoError(t, err)
+
+			runtime.MoveToVUContext(&lib.State{
+				BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+				Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+				Samples:        make(chan metrics.SampleContainer, 1024),
+			})
+
+			_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+				await dns.canary("k6.test", "A", "127.0.0.1:53", ` + options + `);
+			`))
+			assert.ErrorContains(t, err, want, options)
+		}
+	})
+}
@@ -0,0 +1,293 @@
+package dns
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grafana/sobek"
+	"github.com/miekg/dns"
+	"go.k6.io/k6/js/promises"
+)
+
+// checkZoneTypes holds the record types of the apex of a zone checkZone queries,
+// in order.
+var checkZoneTypes = []uint16{ //nolint:gochecknoglobals
+	dns.TypeSOA,
+	dns.TypeNS,
+	dns.TypeA,
+	dns.TypeAAAA,
+	dns.TypeMX,
+	dns.TypeDNSKEY,
+}
+
+// checkZoneResult is the object the checkZone function resolves to, as a health
+// report of the zone.
+type checkZoneResult struct {
+	// Zone holds the zone which was checked.
+	Zone string `js:"zone"`
+
+	// Healthy indicates whether no problem was found.
+	Healthy bool `js:"healthy"`
+
+	// Problems holds the descriptions of the problems found, e.g. "no NS records".
+	Problems []string `js:"problems"`
+
+	// Rcodes holds the response code the records of each type were queried with,
+	// by type, e.g. "NOERROR".
+	Rcodes map[string]string `js:"rcodes"`
+
+	// SOA holds the SOA record of the zone, or is nil if none was found.
+	SOA *zoneSOA `js:"soa"`
+
+	// NS holds the names of the nameservers of the zone.
+	NS []string `js:"ns"`
+
+	// A and AAAA hold the IP addresses the apex of the zone resolves to.
+	A    []string `js:"a"`
+	AAAA []string `js:"aaaa"`
+
+	// MX holds the mail exchangers of the zone, by order of preference.
+	MX []zoneMX `js:"mx"`
+
+	// DNSSEC describes the DNSSEC status of the zone.
+	DNSSEC zoneDNSSEC `js:"dnssec"`
+}
+
+// zoneSOA describes the SOA record of a zone.
+type zoneSOA struct {
+	// Mname holds the name of the primary nameserver of the zone.
+	Mname string `js:"mname"`
+
+	// Rname holds the mailbox of the person responsible for the zone, as a name.
+	Rname string `js:"rname"`
+
+	// Serial holds the serial number of the zone.
+	Serial uint32 `js:"serial"`
+
+	// Refresh, Retry, Expire and Minimum hold the timers of the zone, in seconds.
+	Refresh uint32 `js:"refresh"`
+	Retry   uint32 `js:"retry"`
+	Expire  uint32 `js:"expire"`
+	Minimum uint32 `js:"minimum"`
+}
+
+// zoneMX describes an MX record of a zone.
+type zoneMX struct {
+	// Preference holds the preference of the mail exchanger, the lowest first.
+	Preference uint16 `js:"preference"`
+
+	// Exchange holds the name of the mail exchanger.
+	Exchange string `js:"exchange"`
+}
+
+// zoneDNSSEC describes the DNSSEC status of a zone.
+type zoneDNSSEC struct {
+	// Signed indicates whether the zone publishes DNSKEY records.
+	Signed bool `js:"signed"`
+
+	// Authenticated indicates whether the nameserver set the AD bit of all its
+	// responses, meaning it validated them using DNSSEC.
+	Authenticated bool `js:"authenticated"`
+
+	// Algorithms holds the names of the algorithms of the DNSKEY records, e.g.
+	// "ECDSAP256SHA256".
+	Algorithms []string `js:"algorithms"`
+
+	// ExpiresIn holds the duration until the earliest signature of the apex records
+	// expires, in milliseconds, or nil if no signature was found. It is negative
+	// for signatures which already expired.
+	ExpiresIn *float64 `js:"expiresIn"`
+}
+
+// newCheckZoneResult creates a checkZoneResult out of the responses received for
+// each of the checkZoneTypes of the zone.
+func newCheckZoneResult(zone string, responses []*dns.Msg, now time.Time) *checkZoneResult {
+	result := &checkZoneResult{
+		Zone:     zone,
+		Problems: []string{},
+		Rcodes:   make(map[string]string, len(checkZoneTypes)),
+		NS:       []string{},
+		A:        []string{},
+		AAAA:     []string{},
+		MX:       []zoneMX{},
+		DNSSEC:   zoneDNSSEC{Authenticated: true, Algorithms: []string{}},
+	}
+
+	apex := dns.CanonicalName(zone)
+
+	// Unsigned records are only a problem in signed zones, which is known once the
+	// DNSKEY records are
+	var unsigned []string
+
+	for i, qtype := range checkZoneTypes {
+		response := responses[i]
+		typeName := dns.TypeToString[qtype]
+
+		result.Rcodes[typeName] = dns.RcodeToString[response.Rcode]
+		result.DNSSEC.Authenticated = result.DNSSEC.Authenticated && response.AuthenticatedData
+
+		if response.Rcode != dns.RcodeSuccess {
+			result.Problems = append(result.Problems, fmt.Sprintf(
+				"querying the %s records failed with %s", typeName, dns.RcodeToString[response.Rcode],
+			))
+			continue
+		}
+
+		answered, signed := false, false
+		for _, rr := range response.Answer {
+			// Records of other names, such as the target of a CNAME record, are not
+			// records of the apex
+			if dns.CanonicalName(rr.Header().Name) != apex {
+				continue
+			}
+
+			switch rr := rr.(type) {
+			case *dns.SOA:
+				result.SOA = &zoneSOA{
+					Mname:   strings.TrimSuffix(rr.Ns, "."),
+					Rname:   strings.TrimSuffix(rr.Mbox, "."),
+					Serial:  rr.Serial,
+					Refresh: rr.Refresh,
+					Retry:   rr.Retry,
+					Expire:  rr.Expire,
+					Minimum: rr.Minttl,
+				}
+			case *dns.NS:
+				result.NS = append(result.NS, strings.TrimSuffix(rr.Ns, "."))
+			case *dns.A:
+				result.A = append(result.A, rr.A.String())
+			case *dns.AAAA:
+				result.AAAA = append(result.AAAA, rr.AAAA.String())
+			case *dns.MX:
+				result.MX = append(result.MX, zoneMX{Preference: rr.Preference, Exchange: strings.TrimSuffix(rr.Mx, ".")})
+			case *dns.DNSKEY:
+				result.DNSSEC.Signed = true
+				result.DNSSEC.Algorithms = appendUnique(result.DNSSEC.Algorithms, dns.AlgorithmToString[rr.Algorithm])
+			case *dns.RRSIG:
+				if rr.TypeCovered != qtype {
+					continue
+				}
+
+				signed = true
+
+				expiresIn := float64(rrsigTime(rr.Expiration, now).Sub(now)) / float64(time.Millisecond)
+				if result.DNSSEC.ExpiresIn == nil || expiresIn < *result.DNSSEC.ExpiresIn {
+					result.DNSSEC.ExpiresIn = &expiresIn
+				}
+
+				continue
+			}
+
+			if rr.Header().Rrtype == qtype {
+				answered = true
+			}
+		}
+
+		if answered && !signed {
+			unsigned = append(unsigned, typeName)
+		}
+	}
+
+	if result.SOA == nil && result.Rcodes["SOA"] == dns.RcodeToString[dns.RcodeSuccess] {
+		result.Problems = append(result.Problems, "no SOA record, the name may not be the apex of a zone")
+	}
+
+	if len(result.NS) == 0 && result.Rcodes["NS"] == dns.RcodeToString[dns.RcodeSuccess] {
+		result.Problems = append(result.Problems, "no NS records")
+	}
+
+	if result.DNSSEC.Signed {
+		for _, typeName := range unsigned {
+			result.Problems = append(result.Problems, fmt.Sprintf("the %s records are not signed", typeName))
+		}
+	}
+
+	if result.DNSSEC.ExpiresIn != nil && *result.DNSSEC.ExpiresIn <= 0 {
+		result.Problems = append(result.Problems, "signatures of the apex records expired")
+	}
+
+	sort.SliceStable(result.MX, func(i, j int) bool {
+		return result.MX[i].Preference < result.MX[j].Preference
+	})
+
+	result.Healthy = len(result.Problems) == 0
+
+	return result
+}
+
+// appendUnique appends the value to the values, unless they already hold it.
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+
+	return append(values, value)
+}
+
+// CheckZone queries the nameserver for the SOA, NS, A, AAAA, MX and DNSKEY records
+// of the apex of the zone, along with their signatures, and resolves to a health
+// report of the zone.
+func (mi *ModuleInstance) CheckZone(zone, nameserverAddr, options sobek.Value) *sobek.Promise {
+	promise, resolve, reject := promises.New(mi.vu)
+
+	if mi.vu.State() == nil {
+		reject(errors.New("checkZone can not be used in the init context"))
+		return promise
+	}
+
+	zoneStr, err := exportDomainName(mi.vu.Runtime(), zone, "zone")
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	nameserver, err := mi.exportNameserver(nameserverAddr)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	opts, err := parseResolveOptions(mi.vu.Runtime(), options)
+	if err != nil {
+		reject(fmt.Errorf("invalid checkZone options: %w", err))
+		return promise
+	}
+
+	ctx, cancel, err := mi.withAbortSignal(opts.Signal)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer cancel()
+
+		responses := make([]*dns.Msg, 0, len(checkZoneTypes))
+		for _, qtype := range checkZoneTypes {
+			// Request the signatures by setting the DNSSEC OK bit, and their validation
+			// by setting the AD bit
+			msg := new(dns.Msg)
+			msg.SetQuestion(dns.Fqdn(zoneStr), qtype)
+			msg.RecursionDesired = !opts.NoRecursion
+			msg.AuthenticatedData = true
+			msg.SetEdns0(dns.DefaultMsgSize, true)
+
+			response, _, err := mi.sendMessage(ctx, msg, nameserver, opts.QueryOptions)
+			if err != nil {
+				reject(err)
+				return
+			}
+
+			responses = append(responses, response.msg)
+		}
+
+		resolve(newCheckZoneResult(zoneStr, responses, time.Now()))
+	}()
+
+	return promise
+}
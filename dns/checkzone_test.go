@@ -0,0 +1,234 @@
+package dns
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+)
+
+func Test_newCheckZoneResult(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	// response returns a response holding the records, in the order of checkZoneTypes
+	response := func(rcode int, records ...string) *dns.Msg {
+		t.Helper()
+
+		msg := &dns.Msg{MsgHdr: dns.MsgHdr{Rcode: rcode, AuthenticatedData: true}}
+		for _, record := range records {
+			rr, err := dns.NewRR(record)
+			require.NoError(t, err)
+
+			msg.Answer = append(msg.Answer, rr)
+		}
+
+		return msg
+	}
+
+	t.Run("signed zone", func(t *testing.T) {
+		t.Parallel()
+
+		result := newCheckZoneResult("k6.io", []*dns.Msg{
+			response(dns.RcodeSuccess,
+				"k6.io. 60 IN SOA ns1.k6.io. hostmaster.k6.io. 42 7200 3600 1209600 300",
+				"k6.io. 60 IN RRSIG SOA 13 2 60 20240615000000 20240525000000 1234 k6.io. dGVzdA==",
+			),
+			response(dns.RcodeSuccess,
+				"k6.io. 60 IN NS ns1.k6.io.",
+				"k6.io. 60 IN NS ns2.k6.io.",
+				"k6.io. 60 IN RRSIG NS 13 2 60 20240610000000 20240525000000 1234 k6.io. dGVzdA==",
+			),
+			response(dns.RcodeSuccess,
+				"k6.io. 60 IN A 203.0.113.1",
+				"k6.io. 60 IN RRSIG A 13 2 60 20240615000000 20240525000000 1234 k6.io. dGVzdA==",
+			),
+			response(dns.RcodeSuccess),
+			response(dns.RcodeSuccess,
+				"k6.io. 60 IN MX 20 mx2.k6.io.",
+				"k6.io. 60 IN MX 10 mx1.k6.io.",
+				"k6.io. 60 IN RRSIG MX 13 2 60 20240615000000 20240525000000 1234 k6.io. dGVzdA==",
+			),
+			response(dns.RcodeSuccess,
+				"k6.io. 60 IN DNSKEY 257 3 13 dGVzdA==",
+				"k6.io. 60 IN DNSKEY 256 3 13 dGVzdA==",
+				"k6.io. 60 IN RRSIG DNSKEY 13 2 60 20240615000000 20240525000000 4321 k6.io. dGVzdA==",
+			),
+		}, now)
+
+		assert.True(t, result.Healthy)
+		assert.Empty(t, result.Problems)
+		assert.Equal(t, &zoneSOA{
+			Mname:   "ns1.k6.io",
+			Rname:   "hostmaster.k6.io",
+			Serial:  42,
+			Refresh: 7200,
+			Retry:   3600,
+			Expire:  1209600,
+			Minimum: 300,
+		}, result.SOA)
+		assert.Equal(t, []string{"ns1.k6.io", "ns2.k6.io"}, result.NS)
+		assert.Equal(t, []string{"203.0.113.1"}, result.A)
+		assert.Empty(t, result.AAAA)
+		assert.Equal(t, []zoneMX{{Preference: 10, Exchange: "mx1.k6.io"}, {Preference: 20, Exchange: "mx2.k6.io"}}, result.MX)
+		assert.Equal(t, "NOERROR", result.Rcodes["AAAA"])
+
+		assert.True(t, result.DNSSEC.Signed)
+		assert.True(t, result.DNSSEC.Authenticated)
+		assert.Equal(t, []string{"ECDSAP256SHA256"}, result.DNSSEC.Algorithms)
+		require.NotNil(t, result.DNSSEC.ExpiresIn)
+		assert.Equal(t, float64(9*24*time.Hour/time.Millisecond), *result.DNSSEC.ExpiresIn)
+	})
+
+	t.Run("signed zone with unsigned and expired records", func(t *testing.T) {
+		t.Parallel()
+
+		result := newCheckZoneResult("k6.io", []*dns.Msg{
+			response(dns.RcodeSuccess,
+				"k6.io. 60 IN SOA ns1.k6.io. hostmaster.k6.io. 42 7200 3600 1209600 300",
+				"k6.io. 60 IN RRSIG SOA 13 2 60 20240530000000 20240525000000 1234 k6.io. dGVzdA==",
+			),
+			response(dns.RcodeSuccess, "k6.io. 60 IN NS ns1.k6.io."),
+			response(dns.RcodeSuccess),
+			response(dns.RcodeSuccess),
+			response(dns.RcodeSuccess),
+			response(dns.RcodeSuccess, "k6.io. 60 IN DNSKEY 257 3 8 dGVzdA=="),
+		}, now)
+
+		assert.False(t, result.Healthy)
+		assert.Equal(t, []string{
+			"the NS records are not signed",
+			"the DNSKEY records are not signed",
+			"signatures of the apex records expired",
+		}, result.Problems)
+		assert.Equal(t, []string{"RSASHA256"}, result.DNSSEC.Algorithms)
+	})
+
+	t.Run("unsigned zone without NS records", func(t *testing.T) {
+		t.Parallel()
+
+		responses := []*dns.Msg{
+			response(dns.RcodeSuccess, "k6.io. 60 IN SOA ns1.k6.io. hostmaster.k6.io. 42 7200 3600 1209600 300"),
+			response(dns.RcodeSuccess),
+			response(dns.RcodeSuccess, "k6.io. 60 IN A 203.0.113.1"),
+			response(dns.RcodeServerFailure),
+			response(dns.RcodeSuccess),
+			response(dns.RcodeSuccess),
+		}
+		responses[0].AuthenticatedData = false
+
+		result := newCheckZoneResult("k6.io", responses, now)
+
+		assert.False(t, result.Healthy)
+		assert.Equal(t, []string{"querying the AAAA records failed with SERVFAIL", "no NS records"}, result.Problems)
+		assert.False(t, result.DNSSEC.Signed)
+		assert.False(t, result.DNSSEC.Authenticated)
+		assert.Nil(t, result.DNSSEC.ExpiresIn)
+	})
+
+	t.Run("name which is not the apex of a zone", func(t *testing.T) {
+		t.Parallel()
+
+		result := newCheckZoneResult("www.k6.io", []*dns.Msg{
+			response(dns.RcodeSuccess, "www.k6.io. 60 IN CNAME k6.io.", "k6.io. 60 IN SOA ns1.k6.io. hostmaster.k6.io. 42 7200 3600 1209600 300"),
+			response(dns.RcodeSuccess, "www.k6.io. 60 IN CNAME k6.io.", "k6.io. 60 IN NS ns1.k6.io."),
+			response(dns.RcodeSuccess, "www.k6.io. 60 IN CNAME k6.io.", "k6.io. 60 IN A 203.0.113.1"),
+			response(dns.RcodeSuccess, "www.k6.io. 60 IN CNAME k6.io."),
+			response(dns.RcodeSuccess, "www.k6.io. 60 IN CNAME k6.io."),
+			response(dns.RcodeSuccess, "www.k6.io. 60 IN CNAME k6.io."),
+		}, now)
+
+		assert.Nil(t, result.SOA)
+		assert.Empty(t, result.A)
+		assert.Equal(t, []string{"no SOA record, the name may not be the apex of a zone", "no NS records"}, result.Problems)
+	})
+}
+
+func TestModuleInstance_CheckZone(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		script  string
+		wantErr string
+	}{
+		{
+			name: "Checking a zone should report its apex records",
+			script: `
+				const report = await dns.checkZone("k6.test", server.address);
+				if (!report.healthy || report.problems.length !== 0) {
+					throw new Error("expected the zone to be healthy; got " + JSON.stringify(report.problems));
+				}
+
+				if (report.soa.serial !== 2024060101 || report.soa.mname !== "ns1.k6.test" ||
+					report.ns.join() !== "ns1.k6.test,ns2.k6.test" || report.a.join() !== "203.0.113.1" ||
+					report.aaaa.length !== 0 || report.mx[0].exchange !== "mx.k6.test" || report.mx[0].preference !== 10) {
+					throw new Error("unexpected report: " + JSON.stringify(report));
+				}
+
+				if (report.dnssec.signed || report.dnssec.expiresIn !== null) {
+					throw new Error("expected the zone not to be signed; got " + JSON.stringify(report.dnssec));
+				}
+
+				if (server.log().length !== 6 || !server.log().every(query => query.edns.do)) {
+					throw new Error("expected six queries requesting signatures; got " + JSON.stringify(server.log()));
+				}
+			`,
+		},
+		{
+			name: "Checking a missing zone should report it",
+			script: `
+				const report = await dns.checkZone("missing.k6.test", server.address);
+				if (report.healthy || report.rcodes.SOA !== "NXDOMAIN" || report.soa !== null) {
+					throw new Error("unexpected report: " + JSON.stringify(report));
+				}
+			`,
+		},
+		{
+			name:    "Invalid nameservers should fail",
+			script:  `await dns.checkZone("k6.test", "not-an-address");`,
+			wantErr: "parsing nameserver address failed",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			runtime, err := newConfiguredRuntime(t)
+			require.NoError(t, err)
+
+			_, err = runtime.VU.Runtime().RunString(`
+				const server = dns.startServer([
+					"k6.test. 60 IN SOA ns1.k6.test. hostmaster.k6.test. 2024060101 7200 3600 1209600 300",
+					"k6.test. 60 IN NS ns1.k6.test.",
+					"k6.test. 60 IN NS ns2.k6.test.",
+					"k6.test. 60 IN A 203.0.113.1",
+					"k6.test. 60 IN MX 10 mx.k6.test.",
+				]);
+			`)
+			require.NoError(t, err)
+
+			runtime.MoveToVUContext(&lib.State{
+				BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+				Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+				Samples:        make(chan metrics.SampleContainer, 1024),
+			})
+
+			_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(tt.script))
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -2,16 +2,23 @@ package dns
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/miekg/dns"
 )
 
 // Resolver is the interface that wraps the Resolve method.
 //
-// Resolve resolves a domain name to an IP address. It returns a slice of IP
-// addresses as strings.
+// Resolve resolves a domain name to the data of the records of the given type,
+// e.g. IP addresses for A and AAAA records. It returns a slice of strings.
 type Resolver interface {
 	Resolve(ctx context.Context, query, recordType string, nameserver Nameserver) ([]string, error)
 }
@@ -24,15 +31,80 @@ type Resolver interface {
 // Lookup resolves a domain name to an IP address. It returns a slice of IP
 // addresses as strings.
 type Lookuper interface {
-	Lookup(ctx context.Context, hostname string) ([]string, error)
+	Lookup(ctx context.Context, hostname string, opts LookupOptions) ([]string, error)
 }
 
+// AddrLookuper is the interface that wraps the LookupAddr method.
+//
+// LookupAddr performs a reverse lookup of an IP address using the system's
+// default resolver. It returns a slice of hostnames.
+type AddrLookuper interface {
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+}
+
+// ServiceLookuper is the interface that wraps the LookupService method.
+//
+// LookupService looks up the endpoints of a service, as advertised by its SRV
+// records, along with the IP addresses of their targets, using the system's
+// default resolver.
+type ServiceLookuper interface {
+	LookupService(ctx context.Context, name string, opts LookupOptions) ([]ServiceEndpoint, error)
+}
+
+// defaultAttemptTimeout is the maximum duration of a single query attempt, when
+// no timeout is specified.
+const defaultAttemptTimeout = 2 * time.Second
+
 // Client is a DNS resolver that uses the `miekg/dns` package under the hood.
 //
 // It implements the Resolver interface.
 type Client struct {
 	// client is the DNS client used to resolve queries.
 	client dns.Client
+
+	// openConnections tracks the number of connections to nameservers currently
+	// open. It can be shared between multiple clients.
+	openConnections *atomic.Int64
+
+	// conns holds the persistent connections of the client.
+	conns *connections
+
+	// sharedConns holds the persistent connections shared between multiple clients,
+	// which the client sends queries over instead of its own when shared returns
+	// true, if set.
+	sharedConns *connections
+	shared      func() bool
+
+	// tlsConfig returns the TLS configuration DoT connections are established with,
+	// if set.
+	tlsConfig func() *tls.Config
+
+	// limiter limits the queries sent by the client. It can be shared between
+	// multiple clients.
+	limiter *queryLimiter
+
+	// recorder is called with each query sent by the client, once it was answered
+	// or failed, if set.
+	recorder func(nameserver Nameserver, result *Response)
+
+	// defaults returns the transport options applying to queries which do not set
+	// them, if set.
+	defaults func() QueryOptions
+
+	// outstanding tracks the queries being sent by the client.
+	outstanding outstandingQueries
+
+	// ednsSizes holds the UDP payload sizes negotiated with nameservers.
+	ednsSizes ednsSizes
+
+	// transports holds the transports registered with RegisterTransport, by
+	// protocol.
+	transportsMu sync.RWMutex
+	transports   map[string]Transport
+
+	// interceptors holds the interceptors added with AddInterceptor, in order.
+	interceptorsMu sync.RWMutex
+	interceptors   []Interceptor
 }
 
 // Ensure our Client implements the Resolver interface
@@ -41,20 +113,328 @@ var _ Resolver = &Client{}
 // Ensure our Client implements the Lookuper interface
 var _ Lookuper = &Client{}
 
+// Ensure our Client implements the ServiceLookuper interface
+var _ ServiceLookuper = &Client{}
+
+// Ensure our Client implements the AddrLookuper interface
+var _ AddrLookuper = &Client{}
+
 // NewDNSClient creates a new Client.
 func NewDNSClient() *Client {
 	return &Client{
-		client: dns.Client{},
+		client:          dns.Client{},
+		openConnections: new(atomic.Int64),
+		conns:           newConnections(),
+		limiter:         newQueryLimiter(),
 	}
 }
 
-// Resolve resolves a domain name to a slice of IP addresses using the given nameserver.
-// It returns a slice of IP addresses as strings.
+// Response holds the outcome of a DNS query performed against a nameserver.
+type Response struct {
+	// Answers holds the data of the records of the queried type found in the answer
+	// section of the response, e.g. the IP addresses of A records.
+	Answers []string
+
+	// IPs holds the IP addresses found in the answer section of the response.
+	IPs []string
+
+	// Records holds all the records found in the answer section of the response,
+	// including those not matching the queried type, such as CNAME records.
+	Records []Record
+
+	// Rcode holds the name of the response code returned by the nameserver,
+	// e.g. "NOERROR" or "NXDOMAIN". It is empty if no response was received.
+	Rcode string
+
+	// Size holds the size of the response message, in bytes.
+	Size int
+
+	// AnswerCount holds the number of records found in the answer section of the response.
+	AnswerCount int
+
+	// Protocol holds the protocol the query was sent over: "udp", "tcp", "dot",
+	// "doh", or a protocol registered with RegisterTransport.
+	Protocol string
+
+	// HTTPVersion holds the version of HTTP negotiated with the nameserver over
+	// DoH, e.g. "HTTP/2.0".
+	HTTPVersion string
+
+	// HTTPStatus holds the status code of the last HTTP response of the nameserver
+	// over DoH, if any.
+	HTTPStatus int
+
+	// HTTPCache describes the caching of the last HTTP response of the nameserver
+	// over DoH by HTTP caches, if any.
+	HTTPCache *HTTPCacheInfo
+
+	// TLS describes the TLS connection the query was last sent over, with DoT and
+	// DoH, if any.
+	TLS *TLSInfo
+
+	// Nameserver holds the address of the nameserver which answered the query.
+	Nameserver string
+
+	// Attempts holds the number of times the query was sent to the nameserver.
+	Attempts int
+
+	// Attempt holds the number of the attempt the nameserver answered, starting
+	// from 1, or 0 if it did not answer.
+	Attempt int
+
+	// Timeouts holds the number of attempts which timed out.
+	Timeouts int
+
+	// Throttled holds the duration the query waited for before being sent, to be
+	// sent within the client's limits.
+	Throttled time.Duration
+
+	// ReusedConnections holds the number of attempts which were sent over an
+	// already open connection, rather than a newly dialed one.
+	ReusedConnections int
+
+	// OpenConnections holds the number of connections to nameservers which were
+	// open when the query was first sent.
+	OpenConnections int64
+
+	// IDMismatches holds the number of responses which were dropped because their
+	// ID did not match the query's.
+	IDMismatches int
+
+	// DuplicateResponses holds the number of duplicate responses to the query which
+	// were received over UDP, once the query was retransmitted.
+	DuplicateResponses int
+
+	// CaseRandomized indicates whether the case of the query name was randomized.
+	CaseRandomized bool
+
+	// CaseMismatch indicates whether the query name echoed in the response did not
+	// match the randomized one exactly, if the case of the query name was randomized.
+	CaseMismatch bool
+
+	// AnswerNamesVerified indicates whether the owner names of the records of the
+	// answer section were checked against the query name.
+	AnswerNamesVerified bool
+
+	// UnrelatedAnswers holds the number of records of the answer section whose
+	// owner name did not correspond to the query name, nor to a name the CNAME
+	// records of the section led to, if answer names were verified.
+	UnrelatedAnswers int
+
+	// EDNSDowngrade holds the response code of the response which led the query to
+	// be sent again without EDNS, if it was.
+	EDNSDowngrade string
+
+	// CNAMEChain holds the names the CNAME records which were followed pointed
+	// to, in order, if CNAME records were followed.
+	CNAMEChain []string
+
+	// RawRequest holds the wire format of the message sent to the nameserver.
+	RawRequest []byte
+
+	// RawResponse holds the wire format of the message received from the
+	// nameserver, if any.
+	RawResponse []byte
+
+	// msg holds the raw DNS message received from the nameserver, if any.
+	msg *dns.Msg
+
+	// QueryName holds the name which was queried, when a random label was
+	// prepended to the resolved name.
+	QueryName string
+
+	// queried holds the name the response answers to, when it differs from the
+	// original query because CNAME records were followed.
+	queried string
+
+	// localAddr holds the address the query was sent from, if known.
+	localAddr net.Addr
+
+	// sentAt holds the time the query was first sent at.
+	sentAt time.Time
+
+	// receivedAt holds the time the response was received at, if any.
+	receivedAt time.Time
+}
+
+// QueryOptions holds the options influencing how a query is performed.
+type QueryOptions struct {
+	// Timeout holds the maximum duration of a single query attempt. When zero,
+	// defaultAttemptTimeout is used.
+	Timeout time.Duration
+
+	// Retries holds the number of times a query is retransmitted to the
+	// nameserver after an attempt timed out.
+	Retries int
+
+	// FollowCNAME indicates whether CNAME records should be followed until
+	// records of the queried type are found.
+	FollowCNAME bool
+
+	// MaxCNAMEDepth holds the maximum number of CNAME records followed. When
+	// zero, defaultMaxCNAMEDepth is used.
+	MaxCNAMEDepth int
+
+	// NoRecursion indicates whether the recursion desired flag of queries should
+	// be cleared, e.g. to query authoritative nameservers directly.
+	NoRecursion bool
+
+	// RandomizeCase indicates whether the case of the letters of query names
+	// should be randomized, and checked against the names echoed in responses.
+	RandomizeCase bool
+
+	// VerifyAnswerNames indicates whether the owner names of the records of answer
+	// sections should be checked against query names, following CNAME records.
+	VerifyAnswerNames bool
+
+	// RandomLabel indicates whether a random label should be prepended to query
+	// names, so that queries miss the caches of recursive resolvers.
+	RandomLabel bool
+
+	// TSIG holds the key queries should be signed with, if any, in which case the
+	// signature of responses is verified too.
+	TSIG *TSIGKey
+
+	// EDNSSize holds the UDP payload size queries advertise in an EDNS0 OPT record,
+	// which bounds the size of the responses nameservers send over UDP. When zero,
+	// queries only hold an OPT record if they need one, advertising dns.DefaultMsgSize.
+	EDNSSize uint16
+
+	// SourcePort holds the port queries sent over UDP are sent from, if fixed. When
+	// zero, the operating system assigns an ephemeral port to each query, unless
+	// RandomizeSourcePort is set.
+	SourcePort int
+
+	// RandomizeSourcePort indicates whether queries sent over UDP are sent from a
+	// port picked at random, rather than assigned by the operating system.
+	RandomizeSourcePort bool
+
+	// NegotiateEDNSSize indicates whether the UDP payload size queries advertise is
+	// negotiated with each nameserver instead, starting from the largest one and
+	// lowered as long as attempts time out, as responses too large for the path to
+	// the nameserver are fragmented, and fragments are often dropped.
+	NegotiateEDNSSize bool
+
+	// EDNSFallback indicates whether queries carrying an EDNS0 OPT record which are
+	// answered with FORMERR or BADVERS are sent again without it, over TCP rather
+	// than UDP, as nameservers or middleboxes not supporting EDNS answer them so.
+	EDNSFallback bool
+
+	// ClientSubnet holds the subnet conveyed to nameservers through the EDNS Client
+	// Subnet option of queries, if any, for them to tailor their answers to it.
+	ClientSubnet *net.IPNet
+
+	// Protocol holds the protocol queries are sent over: "udp", the default when
+	// empty, "tcp", "dot", "doh", or a protocol registered with RegisterTransport.
+	// Queries sent over TCP and DoT are pipelined over persistent connections, and
+	// queries sent over DoH are multiplexed over HTTP/2 when nameservers support it.
+	Protocol string
+
+	// NetworkFamily holds the family of the network queries are sent over. Queries
+	// to nameservers of the other family fail, rather than being sent, so that the
+	// reachability of dual-stack resolvers can be validated over each family
+	// separately. When AddressFamilyAny, nameservers of either family are queried.
+	NetworkFamily AddressFamily
+
+	// TLSServerName holds the name the certificate of nameservers is verified
+	// against over DoT and DoH, and the host DoH requests are addressed to. When
+	// empty, their IP address is.
+	TLSServerName string
+
+	// DoHPath holds the path DoH requests are sent to. When empty, defaultDoHPath
+	// is used.
+	DoHPath string
+
+	// DoHHeaders holds the headers added to DoH requests, e.g. the credentials
+	// managed resolvers require, if any.
+	DoHHeaders http.Header
+
+	// DoHMethod holds the HTTP method DoH requests are sent with: GET, for the
+	// responses to be cacheable by HTTP caches such as CDNs, or POST. When empty,
+	// POST is used.
+	DoHMethod string
+
+	// HTTPVersion holds the version of HTTP DoH requests are sent over: "1.1", or
+	// "2", in which case nameservers not supporting it fail the queries. When
+	// empty, HTTP/2 is negotiated when nameservers support it.
+	HTTPVersion string
+
+	// PoolSize holds the maximum number of persistent connections opened to a
+	// nameserver over TCP and DoT. When zero, defaultPoolSize is used.
+	PoolSize int
+
+	// IdleTimeout holds the duration after which persistent connections without
+	// outstanding queries are closed. When zero, defaultIdleTimeout is used.
+	IdleTimeout time.Duration
+
+	// Debug holds the fraction of queries, between 0 and 1, whose request and
+	// response are logged, if set. It is only honored by the k6 module, and when
+	// nil, the configured one is used.
+	Debug *float64
+
+	// explicit records the transport options set explicitly, which are honored
+	// over the configured ones even when set to their zero value.
+	explicit explicitOptions
+}
+
+// Resolve resolves a domain name to the data of the records of the given type,
+// e.g. IP addresses for A and AAAA records, using the given nameserver.
 func (r *Client) Resolve(
 	ctx context.Context,
 	query, recordType string,
 	nameserver Nameserver,
 ) ([]string, error) {
+	response, err := r.Query(ctx, query, recordType, nameserver, QueryOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Answers, nil
+}
+
+// Query resolves a domain name using the given nameserver, and returns the
+// resulting Response.
+//
+// Queries that time out are retransmitted up to opts.Retries times. If
+// opts.FollowCNAME is set, CNAME records are followed until records of the
+// queried type are found.
+//
+// Note that once the nameserver has been queried, a Response is returned even
+// alongside an error, so that callers can still inspect what happened.
+func (r *Client) Query(
+	ctx context.Context,
+	query, recordType string,
+	nameserver Nameserver,
+	opts QueryOptions,
+) (*Response, error) {
+	if opts.RandomLabel {
+		label, err := randomLabel("", defaultRandomLabelEntropy)
+		if err != nil {
+			return nil, err
+		}
+
+		query = label + "." + query
+	}
+
+	response, err := r.query(ctx, query, recordType, nameserver, opts)
+	if err == nil && opts.FollowCNAME && recordType != RecordTypeCNAME.String() {
+		response, err = r.followCNAME(ctx, response, query, recordType, nameserver, opts)
+	}
+
+	if response != nil && opts.RandomLabel {
+		response.QueryName = query
+	}
+
+	return response, err
+}
+
+// query performs a single DNS query against the given nameserver.
+func (r *Client) query(
+	ctx context.Context,
+	query, recordType string,
+	nameserver Nameserver,
+	opts QueryOptions,
+) (*Response, error) {
 	concreteType, err := RecordTypeString(recordType)
 	if err != nil {
 		return nil, fmt.Errorf(
@@ -70,45 +450,499 @@ func (r *Client) Resolve(
 	// uint16 values for the record type, and we don't want to leak that
 	// to our public API, we need to convert our RecordType to the
 	// corresponding uint16 value.
+	qname := query + "."
+	if opts.RandomizeCase {
+		qname = randomizeCase(qname)
+	}
+
 	message := dns.Msg{}
-	message.SetQuestion(query+".", uint16(concreteType))
+	message.SetQuestion(qname, uint16(concreteType))
+	message.RecursionDesired = !opts.NoRecursion
+
+	// Advertising a UDP payload size lets nameservers send responses larger than
+	// 512 bytes over UDP, rather than truncating them
+	ednsSize, negotiate := opts.EDNSSize, opts.NegotiateEDNSSize
+	if ednsSize == 0 && !negotiate && r.defaults != nil {
+		defaults := r.defaults()
+		ednsSize, negotiate = defaults.EDNSSize, defaults.NegotiateEDNSSize
+	}
+
+	if negotiate {
+		ednsSize = r.ednsSizes.size(nameserver)
+	}
+
+	if ednsSize > 0 || opts.ClientSubnet != nil {
+		udpSize := ednsSize
+		if udpSize == 0 {
+			udpSize = dns.DefaultMsgSize
+		}
+
+		message.SetEdns0(udpSize, false)
+	}
+
+	if opts.ClientSubnet != nil {
+		opt := message.IsEdns0()
+		opt.Option = append(opt.Option, newClientSubnetOption(opts.ClientSubnet))
+	}
 
-	// Query the nameserver
-	response, _, err := r.client.ExchangeContext(ctx, &message, nameserver.Addr())
+	// Query the nameserver, retransmitting the query as long as attempts time out
+	result, err := r.Send(ctx, &message, nameserver, opts)
 	if err != nil {
-		return nil, fmt.Errorf("querying the DNS nameserver failed: %w", err)
+		return result, err
 	}
 
-	if response.Rcode != dns.RcodeSuccess {
-		return nil, newDNSError(response.Rcode, "DNS query failed")
+	if opts.EDNSFallback && rejectsEDNS(&message, result.msg) {
+		if result, err = r.downgradeEDNS(ctx, &message, nameserver, opts, result); err != nil {
+			return result, err
+		}
+	}
+
+	// Nameservers are expected to echo the query name exactly as it was sent
+	if opts.RandomizeCase {
+		result.CaseRandomized = true
+		result.CaseMismatch = len(result.msg.Question) == 0 || result.msg.Question[0].Name != qname
+	}
+
+	// Records owned by names unrelated to the query name do not answer it
+	if opts.VerifyAnswerNames {
+		result.AnswerNamesVerified = true
+		result.UnrelatedAnswers = unrelatedAnswers(qname, result.msg.Answer)
 	}
 
-	var ips []string
-	for _, a := range response.Answer {
-		switch t := a.(type) {
+	if result.msg.Rcode != dns.RcodeSuccess {
+		return result, withNameserver(newDNSError(result.msg.Rcode, "DNS query failed"), nameserver)
+	}
+
+	result.Answers = []string{}
+	for _, rr := range result.msg.Answer {
+		if rr.Header().Rrtype == uint16(concreteType) {
+			result.Answers = append(result.Answers, recordData(rr))
+		}
+	}
+
+	return result, nil
+}
+
+// Send sends an arbitrary DNS message to the given nameserver, and returns the
+// resulting Response.
+//
+// As opposed to Query, it does not treat response codes other than NOERROR as
+// errors, leaving it to callers to inspect the response.
+//
+// Multicast nameservers, such as the multicast DNS group, are sent one-shot
+// multicast DNS queries, whose responses are merged.
+//
+// The message goes through the interceptors added with AddInterceptor first.
+func (r *Client) Send(
+	ctx context.Context,
+	message *dns.Msg,
+	nameserver Nameserver,
+	opts QueryOptions,
+) (*Response, error) {
+	ctx, done := r.outstanding.track(ctx)
+	defer done()
+
+	response, err := r.intercepted(r.send)(ctx, message, nameserver, opts)
+	if err == nil && (response == nil || response.msg == nil) {
+		return response, withNameserver(
+			newExchangeError(errNoInterceptedResponse, "querying the DNS nameserver failed"),
+			nameserver,
+		)
+	}
+
+	return response, err
+}
+
+// send sends the message to the nameserver, as Send does, once it went through
+// the interceptors.
+func (r *Client) send(
+	ctx context.Context,
+	message *dns.Msg,
+	nameserver Nameserver,
+	opts QueryOptions,
+) (*Response, error) {
+	// Multicast groups are queried as multicast DNS responders
+	if nameserver.IP.IsMulticast() {
+		return r.multicast(ctx, message, nameserver, opts)
+	}
+
+	result := &Response{}
+	response, err := r.exchange(ctx, message, nameserver, opts, result)
+	if err != nil {
+		return result, withNameserver(newExchangeError(err, "querying the DNS nameserver failed"), nameserver)
+	}
+
+	result.setMessage(response)
+	result.Size = len(result.RawResponse)
+
+	return result, nil
+}
+
+// setMessage sets the message received from the nameserver, along with the
+// properties derived from it.
+func (r *Response) setMessage(response *dns.Msg) {
+	r.msg = response
+	r.Rcode = dns.RcodeToString[response.Rcode]
+	r.AnswerCount = len(response.Answer)
+
+	for _, rr := range response.Answer {
+		r.Records = append(r.Records, newRecord(rr))
+
+		switch t := rr.(type) {
 		case *dns.A:
-			ips = append(ips, t.A.String())
+			r.IPs = append(r.IPs, t.A.String())
 		case *dns.AAAA:
-			ips = append(ips, t.AAAA.String())
-		default:
-			return nil, fmt.Errorf(
-				"resolve operation failed with %w: unhandled DNS answer type %T",
-				ErrUnsupportedRecordType,
-				a,
-			)
+			r.IPs = append(r.IPs, t.AAAA.String())
 		}
 	}
+}
 
-	return ips, nil
+// exchange sends the message to the nameserver, and retransmits it up to
+// opts.Retries times if the attempts time out. It records the number of attempts
+// and timeouts, as well as the exchanged messages' wire format, in the provided
+// Response.
+func (r *Client) exchange(
+	ctx context.Context,
+	message *dns.Msg,
+	nameserver Nameserver,
+	opts QueryOptions,
+	result *Response,
+) (*dns.Msg, error) {
+	if r.defaults != nil {
+		opts = opts.withDefaults(r.defaults())
+	}
+
+	// Nameservers outside of the enforced network family are not reachable over it
+	if nameserver.Socket == "" && !opts.NetworkFamily.contains(nameserver.IP) {
+		network := "IPv4"
+		if opts.NetworkFamily == AddressFamilyIPv6 {
+			network = "IPv6"
+		}
+
+		return nil, &Error{
+			Name:    NetworkUnreachable.String(),
+			Message: fmt.Sprintf("nameserver %s can not be reached over %s", nameserver.Addr(), network),
+			Kind:    NetworkUnreachable,
+		}
+	}
+
+	throttleStart := time.Now()
+
+	release, err := r.limiter.wait(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	result.Throttled = time.Since(throttleStart)
+
+	var packed []byte
+	var requestMAC string
+	if opts.TSIG != nil {
+		packed, requestMAC, err = opts.TSIG.sign(message, time.Now())
+	} else {
+		packed, err = message.Pack()
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	result.RawRequest = packed
+	result.sentAt = time.Now()
+
+	// Exchanges over Unix domain sockets have no network addresses to record
+	if r.recorder != nil && nameserver.Socket == "" {
+		defer r.recorder(nameserver, result)
+	}
+
+	var response *dns.Msg
+	var raw []byte
+	transport, custom := r.transport(opts.Protocol)
+
+	switch {
+	case nameserver.Socket != "" && !custom:
+		// Unix domain sockets are streams, over which messages are framed as over TCP
+		if opts.Protocol == protocolDoT || opts.Protocol == protocolDoH {
+			return nil, fmt.Errorf("protocol %s can not be used with Unix domain socket nameservers", opts.Protocol)
+		}
+
+		result.Protocol = protocolUnix
+		response, raw, err = r.exchangeStream(ctx, message.Id, packed, nameserver, opts, result)
+	case opts.Protocol == protocolTCP || opts.Protocol == protocolDoT:
+		result.Protocol = opts.Protocol
+		response, raw, err = r.exchangeStream(ctx, message.Id, packed, nameserver, opts, result)
+	case opts.Protocol == protocolDoH:
+		result.Protocol = opts.Protocol
+		response, raw, err = r.exchangeHTTPS(ctx, message.Id, packed, nameserver, opts, result)
+	case custom:
+		result.Protocol = strings.ToLower(opts.Protocol)
+		response, raw, err = r.exchangeTransport(ctx, transport, packed, nameserver, opts, result)
+	default:
+		result.Protocol = protocolUDP
+		response, raw, err = r.exchangeDatagram(ctx, message, packed, nameserver, opts, result)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	result.RawResponse = raw
+	result.receivedAt = time.Now()
+	result.Nameserver = nameserver.Addr()
+	result.Attempt = result.Attempts
+
+	if opts.TSIG != nil {
+		if err := opts.TSIG.verify(response, raw, requestMAC); err != nil {
+			return nil, err
+		}
+	}
+
+	return response, nil
+}
+
+// exchangeDatagram sends the packed message to the nameserver over UDP, and
+// retransmits it up to opts.Retries times if the attempts time out. It returns the
+// response along with its wire format.
+func (r *Client) exchangeDatagram(
+	ctx context.Context,
+	message *dns.Msg,
+	packed []byte,
+	nameserver Nameserver,
+	opts QueryOptions,
+	result *Response,
+) (*dns.Msg, []byte, error) {
+	var conn *dns.Conn
+	defer func() {
+		if conn != nil {
+			r.closeConn(conn)
+		}
+	}()
+
+	for {
+		if conn == nil {
+			var err error
+			if conn, err = r.dial(ctx, nameserver, opts); err != nil {
+				return nil, nil, err
+			}
+
+			// Advertise the UDP payload size the message allows for, if any
+			if opt := message.IsEdns0(); opt != nil && opt.UDPSize() >= dns.MinMsgSize {
+				conn.UDPSize = opt.UDPSize()
+			}
+
+			result.localAddr = conn.LocalAddr()
+
+			result.OpenConnections = r.openConnections.Load()
+		} else {
+			result.ReusedConnections++
+		}
+
+		result.Attempts++
+		response, raw, err := r.attempt(ctx, conn, message.Id, packed, opts.Timeout, result)
+		if err == nil {
+			// Retransmitted queries are likely to be answered more than once
+			if result.Attempts > 1 {
+				drainDuplicates(conn, message.Id, result)
+			}
+
+			return response, raw, nil
+		}
+
+		if !isTimeout(err) {
+			return nil, nil, err
+		}
+
+		result.Timeouts++
+		if result.Attempts > opts.Retries || ctx.Err() != nil {
+			return nil, nil, err
+		}
+
+		// Responses might have been too large to reach us unfragmented, in which case
+		// the query is retransmitted advertising a smaller UDP payload size
+		if opts.NegotiateEDNSSize && opts.TSIG == nil {
+			if packed, err = r.lowerEDNSSize(message, packed, nameserver); err != nil {
+				return nil, nil, err
+			}
+
+			result.RawRequest = packed
+		}
+	}
+}
+
+// attempt sends the packed message over the connection once, and reads the
+// response to it, bounding the exchange to the provided timeout if it is greater
+// than zero, or to defaultAttemptTimeout otherwise. It returns the response
+// along with its wire format.
+func (r *Client) attempt(
+	ctx context.Context,
+	conn *dns.Conn,
+	id uint16,
+	packed []byte,
+	timeout time.Duration,
+	result *Response,
+) (*dns.Msg, []byte, error) {
+	if timeout <= 0 {
+		timeout = defaultAttemptTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Reading and writing are bounded to the context's deadline, and we interrupt
+	// them ourselves as soon as the context is done.
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	response, raw, err := roundTrip(conn, id, packed, result)
+	if err != nil && errors.Is(ctx.Err(), context.Canceled) {
+		return nil, nil, ctx.Err()
+	}
+
+	return response, raw, err
+}
+
+// roundTrip writes the packed message to the connection, and reads the response
+// to it, along with its wire format.
+//
+// Responses with a mismatched ID are dropped, as they might be late responses to
+// earlier queries, or spoofed ones, and accounted for in the provided Response.
+func roundTrip(conn *dns.Conn, id uint16, packed []byte, result *Response) (*dns.Msg, []byte, error) {
+	if _, err := conn.Write(packed); err != nil {
+		return nil, nil, err
+	}
+
+	for {
+		msg, buf, err := readMsg(conn.Conn, conn.UDPSize)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if msgID(msg) != id {
+			putBuffer(buf)
+			result.IDMismatches++
+
+			continue
+		}
+
+		// The wire format outlives the pooled buffer it was read into
+		raw := append([]byte(nil), msg...)
+		putBuffer(buf)
+
+		response := new(dns.Msg)
+		if err := response.Unpack(raw); err != nil {
+			return nil, nil, err
+		}
+
+		return response, raw, nil
+	}
+}
+
+// duplicatesDrainWindow is the duration drainDuplicates waits for each response
+// already received over the connection.
+const duplicatesDrainWindow = 50 * time.Microsecond
+
+// drainDuplicates reads the responses already received over a UDP connection,
+// once the response to the query was read, and accounts for the duplicates of that
+// response, as well as responses with a mismatched ID, in the provided Response.
+func drainDuplicates(conn *dns.Conn, id uint16, result *Response) {
+	if _, isPacketConn := conn.Conn.(net.PacketConn); !isPacketConn {
+		return
+	}
+
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(duplicatesDrainWindow))
+
+		msg, buf, err := readMsg(conn.Conn, conn.UDPSize)
+		if err != nil {
+			return
+		}
+
+		duplicate := msgID(msg) == id
+		putBuffer(buf)
+
+		if duplicate {
+			result.DuplicateResponses++
+		} else {
+			result.IDMismatches++
+		}
+	}
+}
+
+// dial opens a connection to the nameserver, from the source port the options
+// tell, and accounts for it in the client's open connections count.
+func (r *Client) dial(ctx context.Context, nameserver Nameserver, opts QueryOptions) (*dns.Conn, error) {
+	conn, err := r.dialSourcePort(ctx, nameserver, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	r.openConnections.Add(1)
+
+	return conn, nil
+}
+
+// closeConn closes a connection opened by dial, and accounts for it in the
+// client's open connections count.
+func (r *Client) closeConn(conn *dns.Conn) {
+	r.openConnections.Add(-1)
+
+	// Closing a connection we are done with can only fail if it was already
+	// closed, in which case there is nothing left for us to do.
+	_ = conn.Close()
+}
+
+// isTimeout returns true if the error is the result of a network operation timing out.
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
 }
 
 // Lookup resolves a domain name to a slice of IP addresses using the system's
 // default resolver.
-func (r *Client) Lookup(ctx context.Context, hostname string) ([]string, error) {
-	ips, err := net.DefaultResolver.LookupHost(ctx, hostname)
+func (r *Client) Lookup(ctx context.Context, hostname string, opts LookupOptions) ([]string, error) {
+	addrs, err := net.DefaultResolver.LookupIP(ctx, opts.Family.network(), hostname)
 	if err != nil {
 		return nil, fmt.Errorf("lookup of %s failed: %w", hostname, err)
 	}
 
+	sortAddresses(addrs, opts.Order)
+
+	ips := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		ips = append(ips, addr.String())
+	}
+
 	return ips, nil
 }
+
+// LookupService looks up the endpoints of the service name, e.g. `_http._tcp.k6.io`,
+// along with the IP addresses of their targets, using the system's default resolver.
+func (r *Client) LookupService(ctx context.Context, name string, opts LookupOptions) ([]ServiceEndpoint, error) {
+	return lookupService(ctx, net.DefaultResolver, name, opts, r)
+}
+
+// LookupAddr performs a reverse lookup of the IP address using the system's default
+// resolver, and returns the hostnames it maps to, without their trailing dot.
+func (r *Client) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	names, err := net.DefaultResolver.LookupAddr(ctx, addr)
+	if err != nil {
+		return nil, fmt.Errorf("reverse lookup of %s failed: %w", addr, err)
+	}
+
+	hostnames := make([]string, 0, len(names))
+	for _, name := range names {
+		hostnames = append(hostnames, strings.TrimSuffix(name, "."))
+	}
+
+	return hostnames, nil
+}
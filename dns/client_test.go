@@ -0,0 +1,54 @@
+package dns
+
+import (
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_roundTrip(t *testing.T) {
+	t.Parallel()
+
+	client, server := net.Pipe()
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = server.Close()
+	})
+
+	query := new(dns.Msg)
+	query.SetQuestion("k6.io.", dns.TypeA)
+	query.Id = 4242
+
+	packed, err := query.Pack()
+	require.NoError(t, err)
+
+	// The nameserver answers with a mismatched ID first, then with the query's
+	go func() {
+		serverConn := &dns.Conn{Conn: server}
+
+		request, err := serverConn.ReadMsg()
+		if err != nil {
+			return
+		}
+
+		response := new(dns.Msg)
+		response.SetReply(request)
+
+		response.Id = request.Id + 1
+		_ = serverConn.WriteMsg(response)
+
+		response.Id = request.Id
+		_ = serverConn.WriteMsg(response)
+	}()
+
+	result := &Response{}
+	response, raw, err := roundTrip(&dns.Conn{Conn: client}, query.Id, packed, result)
+	require.NoError(t, err)
+
+	assert.Equal(t, query.Id, response.Id)
+	assert.NotEmpty(t, raw)
+	assert.Equal(t, 1, result.IDMismatches)
+}
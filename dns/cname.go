@@ -0,0 +1,121 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// defaultMaxCNAMEDepth is the default maximum number of CNAME records followed
+// when resolving a query.
+const defaultMaxCNAMEDepth = 8
+
+// followCNAME follows the CNAME records found in the response to the query, until
+// records of the queried type are found.
+//
+// The CNAME records found in a response are followed first, as nameservers often
+// include the whole chain in their answer. If the chain ends without any record of
+// the queried type, the name it ends with is queried in turn.
+//
+// It fails with a CNAMELoop error if a name of the chain was already visited, and
+// with a MaxDepthExceeded error if the chain is longer than opts.MaxCNAMEDepth.
+func (r *Client) followCNAME(
+	ctx context.Context,
+	response *Response,
+	query, recordType string,
+	nameserver Nameserver,
+	opts QueryOptions,
+) (*Response, error) {
+	maxDepth := opts.MaxCNAMEDepth
+	if maxDepth <= 0 {
+		maxDepth = defaultMaxCNAMEDepth
+	}
+
+	visited := map[string]bool{strings.ToLower(query): true}
+	chain := []string{}
+	current := query
+
+	for {
+		// Walk through the chain as far as the current response allows us to
+		for {
+			target, ok := cnameTarget(response.Records, current)
+			if !ok {
+				break
+			}
+
+			if visited[strings.ToLower(target)] {
+				response.CNAMEChain = chain
+				return response, newCNAMEError(CNAMELoop, fmt.Sprintf("CNAME loop detected at %s", target), nameserver)
+			}
+
+			if len(chain) >= maxDepth {
+				response.CNAMEChain = chain
+				return response, newCNAMEError(
+					MaxDepthExceeded,
+					fmt.Sprintf("CNAME chain of %s is longer than %d", query, maxDepth),
+					nameserver,
+				)
+			}
+
+			visited[strings.ToLower(target)] = true
+			chain = append(chain, target)
+			current = target
+		}
+
+		// We're done once we either found the records we were looking for, or
+		// the last name we queried is not an alias
+		if len(response.Answers) > 0 || len(chain) == 0 || strings.EqualFold(current, lastQueried(query, response)) {
+			response.CNAMEChain = chain
+			return response, nil
+		}
+
+		next, err := r.query(ctx, current, recordType, nameserver, opts)
+		if next == nil {
+			response.CNAMEChain = chain
+			return response, err
+		}
+
+		next.queried = current
+		next.Attempts += response.Attempts
+		next.Timeouts += response.Timeouts
+		next.Throttled += response.Throttled
+		next.ReusedConnections += response.ReusedConnections
+		next.Records = append(response.Records, next.Records...) //nolint:gocritic
+		response = next
+
+		if err != nil {
+			response.CNAMEChain = chain
+			return response, err
+		}
+	}
+}
+
+// cnameTarget returns the name the CNAME record owned by name, if any, points to.
+func cnameTarget(records []Record, name string) (string, bool) {
+	for _, record := range records {
+		if record.Type == RecordTypeCNAME.String() && strings.EqualFold(record.Name, name) {
+			return record.Data, true
+		}
+	}
+
+	return "", false
+}
+
+// lastQueried returns the name the response answers to.
+func lastQueried(query string, response *Response) string {
+	if response.queried != "" {
+		return response.queried
+	}
+
+	return query
+}
+
+// newCNAMEError creates a new DNSError related to following CNAME records.
+func newCNAMEError(kind errorKind, message string, nameserver Nameserver) *Error {
+	return &Error{
+		Name:       kind.String(),
+		Message:    message,
+		Kind:       kind,
+		Nameserver: nameserver.Addr(),
+	}
+}
@@ -0,0 +1,58 @@
+package dns
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_cnameTarget(t *testing.T) {
+	t.Parallel()
+
+	records := []Record{
+		{Name: "www.k6.io", Type: "CNAME", TTL: 60, Data: "k6.io"},
+		{Name: "k6.io", Type: "A", TTL: 60, Data: "1.2.3.4"},
+	}
+
+	tests := []struct {
+		name       string
+		owner      string
+		wantTarget string
+		wantOK     bool
+	}{
+		{
+			name:       "CNAME record owned by name",
+			owner:      "www.k6.io",
+			wantTarget: "k6.io",
+			wantOK:     true,
+		},
+		{
+			name:       "owner name matched case insensitively",
+			owner:      "WWW.k6.IO",
+			wantTarget: "k6.io",
+			wantOK:     true,
+		},
+		{
+			name:   "name owning records of another type",
+			owner:  "k6.io",
+			wantOK: false,
+		},
+		{
+			name:   "name owning no record",
+			owner:  "grafana.com",
+			wantOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			gotTarget, gotOK := cnameTarget(records, tt.owner)
+			assert.Equal(t, tt.wantOK, gotOK)
+			assert.Equal(t, tt.wantTarget, gotTarget)
+		})
+	}
+}
@@ -0,0 +1,291 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/sobek"
+	"go.k6.io/k6/js/promises"
+	"go.k6.io/k6/metrics"
+)
+
+// compareResult is the object the compare function resolves to.
+type compareResult struct {
+	// Name holds the name which was resolved.
+	Name string `js:"name"`
+
+	// Type holds the record type which was resolved.
+	Type string `js:"type"`
+
+	// Consistent indicates whether all the nameservers answered with the same records.
+	Consistent bool `js:"consistent"`
+
+	// Answers holds the answers most nameservers agreed on, which the answers of
+	// each nameserver are compared to.
+	Answers []string `js:"answers"`
+
+	// Nameservers holds the outcome of the resolution for each nameserver, in the
+	// order they were provided.
+	Nameservers []*compareNameserverResult `js:"nameservers"`
+}
+
+// compareNameserverResult holds the outcome of the resolution against a single
+// nameserver of a comparison.
+type compareNameserverResult struct {
+	// Nameserver holds the address of the nameserver.
+	Nameserver string `js:"nameserver"`
+
+	// Consistent indicates whether the nameserver answered with the records most
+	// nameservers agreed on.
+	Consistent bool `js:"consistent"`
+
+	// Answers holds the answers of the nameserver.
+	Answers []string `js:"answers"`
+
+	// Missing holds the answers most nameservers agreed on which the nameserver
+	// did not answer with.
+	Missing []string `js:"missing"`
+
+	// Extra holds the answers of the nameserver most nameservers did not agree on.
+	Extra []string `js:"extra"`
+
+	// Rcode holds the name of the response code returned by the nameserver, if any.
+	Rcode string `js:"rcode"`
+
+	// RTT holds the duration of the resolution, in milliseconds.
+	RTT float64 `js:"rtt"`
+
+	// Error holds the error the resolution failed with, if any.
+	Error *Error `js:"error"`
+}
+
+// Compare resolves a domain name against several nameservers concurrently, and
+// resolves to the differences between their answers, along with their latency.
+//
+// The promise is not rejected when the nameservers disagree, or when resolutions
+// fail, which is instead reported in the result.
+func (mi *ModuleInstance) Compare(query, recordType, nameserverAddrs, options sobek.Value) *sobek.Promise {
+	promise, resolve, reject := promises.New(mi.vu)
+
+	if mi.vu.State() == nil {
+		reject(errors.New("compare can not be used in the init context"))
+		return promise
+	}
+
+	queryStr, err := exportDomainName(mi.vu.Runtime(), query, "query")
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	var recordTypeStr string
+	if err := mi.vu.Runtime().ExportTo(recordType, &recordTypeStr); err != nil {
+		reject(fmt.Errorf("recordType must be a string; got %v instead", recordType))
+		return promise
+	}
+
+	if _, err := RecordTypeString(recordTypeStr); err != nil {
+		reject(fmt.Errorf("%w, %s is an invalid DNS record type", ErrUnsupportedRecordType, recordTypeStr))
+		return promise
+	}
+
+	nameservers, err := exportNameservers(mi.vu.Runtime(), nameserverAddrs)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	opts, err := parseResolveOptions(mi.vu.Runtime(), options)
+	if err != nil {
+		reject(fmt.Errorf("invalid compare options: %w", err))
+		return promise
+	}
+
+	// Names which do not exist simply hold no records
+	opts.NXDomainAsEmpty = true
+
+	ctx, cancel, err := mi.withAbortSignal(opts.Signal)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer cancel()
+
+		resolve(mi.compare(ctx, queryStr, recordTypeStr, nameservers, opts))
+	}()
+
+	return promise
+}
+
+// compare resolves the query against each of the nameservers concurrently, and
+// compares their answers.
+func (mi *ModuleInstance) compare(
+	ctx context.Context,
+	query, recordType string,
+	nameservers []Nameserver,
+	opts resolveOptions,
+) *compareResult {
+	results := make([]*compareNameserverResult, len(nameservers))
+
+	var wg sync.WaitGroup
+	for i, nameserver := range nameservers {
+		wg.Add(1)
+
+		go func(i int, nameserver Nameserver) {
+			defer wg.Done()
+
+			response, duration, err := mi.resolveQuery(ctx, query, recordType, nameserver, opts)
+
+			result := &compareNameserverResult{
+				Nameserver: nameserver.Addr(),
+				Answers:    []string{},
+				RTT:        float64(duration) / float64(time.Millisecond),
+				Error:      asError(err),
+			}
+
+			if response != nil {
+				result.Rcode = response.Rcode
+				if err == nil && response.Answers != nil {
+					result.Answers = response.Answers
+				}
+			}
+
+			results[i] = result
+		}(i, nameserver)
+	}
+
+	wg.Wait()
+
+	result := compareAnswers(query, recordType, results)
+
+	for i, nameserver := range nameservers {
+		mi.emitConsistencyMetrics(mi.vu.Context(), query, recordType, nameserver, results[i].Consistent)
+	}
+
+	return result
+}
+
+// compareAnswers compares the answers of the nameservers to the answers most of
+// them agreed on, ignoring the nameservers whose resolution failed.
+func compareAnswers(query, recordType string, results []*compareNameserverResult) *compareResult {
+	// Find the most common answer set, the earliest one winning ties
+	counts := make(map[string]int, len(results))
+	var majority []string
+	majorityCount := 0
+
+	for _, result := range results {
+		if result.Error != nil {
+			continue
+		}
+
+		key := answerSetKey(result.Answers)
+		counts[key]++
+
+		if counts[key] > majorityCount {
+			majority = result.Answers
+			majorityCount = counts[key]
+		}
+	}
+
+	compared := &compareResult{
+		Name:        query,
+		Type:        recordType,
+		Consistent:  len(results) > 0,
+		Answers:     []string{},
+		Nameservers: results,
+	}
+
+	if majority != nil {
+		compared.Answers = majority
+	}
+
+	for _, result := range results {
+		result.Missing = diffAnswers(compared.Answers, result.Answers)
+		result.Extra = diffAnswers(result.Answers, compared.Answers)
+
+		if result.Error != nil {
+			// A failed resolution holds no answers to compare
+			result.Missing = []string{}
+		}
+
+		result.Consistent = result.Error == nil && len(result.Missing) == 0 && len(result.Extra) == 0
+		compared.Consistent = compared.Consistent && result.Consistent
+	}
+
+	return compared
+}
+
+// answerSetKey returns a key identifying the set of answers, regardless of their
+// order, duplicates, case and trailing dot.
+func answerSetKey(answers []string) string {
+	set := make(map[string]bool, len(answers))
+	for _, answer := range answers {
+		set[normalizeAnswer(answer)] = true
+	}
+
+	normalized := make([]string, 0, len(set))
+	for answer := range set {
+		normalized = append(normalized, answer)
+	}
+
+	sort.Strings(normalized)
+
+	return strings.Join(normalized, "\n")
+}
+
+// diffAnswers returns the answers which are not among the reference answers,
+// compared regardless of their case and trailing dot.
+func diffAnswers(answers, reference []string) []string {
+	known := make(map[string]bool, len(reference))
+	for _, answer := range reference {
+		known[normalizeAnswer(answer)] = true
+	}
+
+	diff := []string{}
+	for _, answer := range answers {
+		if !known[normalizeAnswer(answer)] {
+			diff = append(diff, answer)
+			known[normalizeAnswer(answer)] = true
+		}
+	}
+
+	return diff
+}
+
+// emitConsistencyMetrics emits the metric tracking whether a nameserver answered
+// with the records most nameservers agreed on.
+func (mi *ModuleInstance) emitConsistencyMetrics(
+	ctx context.Context,
+	query, recordType string,
+	nameserver Nameserver,
+	consistent bool,
+) {
+	state := mi.vu.State()
+
+	tags := state.Tags.GetCurrentValues().Tags
+	tags = tags.With("query", query)
+	tags = tags.With("recordType", recordType)
+	tags = tags.With("nameserver", nameserver.Addr())
+
+	var value float64
+	if consistent {
+		value = 1
+	}
+
+	metrics.PushIfNotDone(ctx, state.Samples, metrics.Sample{
+		TimeSeries: metrics.TimeSeries{
+			Metric: mi.metrics.DNSConsistency,
+			Tags:   tags,
+		},
+		Time:     time.Now(),
+		Value:    value,
+		Metadata: nil,
+	})
+}
@@ -0,0 +1,97 @@
+package dns
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_compareAnswers(t *testing.T) {
+	t.Parallel()
+
+	timeout := &Error{Name: "Timeout", Kind: Timeout}
+
+	tests := []struct {
+		name           string
+		results        []*compareNameserverResult
+		wantConsistent bool
+		wantAnswers    []string
+		wantMissing    [][]string
+		wantExtra      [][]string
+	}{
+		{
+			name: "consistent regardless of order and case",
+			results: []*compareNameserverResult{
+				{Answers: []string{"192.0.2.1", "192.0.2.2"}},
+				{Answers: []string{"192.0.2.2", "192.0.2.1"}},
+				{Answers: []string{"192.0.2.1", "192.0.2.2"}},
+			},
+			wantConsistent: true,
+			wantAnswers:    []string{"192.0.2.1", "192.0.2.2"},
+			wantMissing:    [][]string{{}, {}, {}},
+			wantExtra:      [][]string{{}, {}, {}},
+		},
+		{
+			name: "stale nameserver",
+			results: []*compareNameserverResult{
+				{Answers: []string{"192.0.2.1"}},
+				{Answers: []string{"192.0.2.2"}},
+				{Answers: []string{"192.0.2.2"}},
+			},
+			wantConsistent: false,
+			wantAnswers:    []string{"192.0.2.2"},
+			wantMissing:    [][]string{{"192.0.2.2"}, {}, {}},
+			wantExtra:      [][]string{{"192.0.2.1"}, {}, {}},
+		},
+		{
+			name: "earliest answers win ties",
+			results: []*compareNameserverResult{
+				{Answers: []string{"192.0.2.1"}},
+				{Answers: []string{"192.0.2.2"}},
+			},
+			wantConsistent: false,
+			wantAnswers:    []string{"192.0.2.1"},
+			wantMissing:    [][]string{{}, {"192.0.2.1"}},
+			wantExtra:      [][]string{{}, {"192.0.2.2"}},
+		},
+		{
+			name: "failed resolution",
+			results: []*compareNameserverResult{
+				{Answers: []string{}, Error: timeout},
+				{Answers: []string{"192.0.2.1"}},
+			},
+			wantConsistent: false,
+			wantAnswers:    []string{"192.0.2.1"},
+			wantMissing:    [][]string{{}, {}},
+			wantExtra:      [][]string{{}, {}},
+		},
+		{
+			name: "all resolutions failed",
+			results: []*compareNameserverResult{
+				{Answers: []string{}, Error: timeout},
+			},
+			wantConsistent: false,
+			wantAnswers:    []string{},
+			wantMissing:    [][]string{{}},
+			wantExtra:      [][]string{{}},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := compareAnswers("k6.io", "A", tt.results)
+
+			assert.Equal(t, tt.wantConsistent, got.Consistent)
+			assert.Equal(t, tt.wantAnswers, got.Answers)
+
+			for i, result := range got.Nameservers {
+				assert.Equal(t, tt.wantMissing[i], result.Missing, "nameserver %d missing answers", i)
+				assert.Equal(t, tt.wantExtra[i], result.Extra, "nameserver %d extra answers", i)
+			}
+		})
+	}
+}
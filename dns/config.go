@@ -0,0 +1,420 @@
+package dns
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/sobek"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/types"
+)
+
+// configExtKey is the key of the client's configuration in the ext options of
+// the test.
+const configExtKey = "dns"
+
+// Environment variables overriding the client's configuration.
+const (
+	envNameserver      = "K6_DNS_NAMESERVER"
+	envProtocol        = "K6_DNS_PROTOCOL"
+	envTimeout         = "K6_DNS_TIMEOUT"
+	envRetries         = "K6_DNS_RETRIES"
+	envTLSServerName   = "K6_DNS_TLS_SERVER_NAME"
+	envDebug           = "K6_DNS_DEBUG"
+	envNetworkFamily   = "K6_DNS_NETWORK_FAMILY"
+	envEDNSSize        = "K6_DNS_EDNS_SIZE"
+	envInstancing      = "K6_DNS_INSTANCING"
+	envDurationPerType = "K6_DNS_DURATION_PER_TYPE"
+)
+
+// envAliasPrefix is prepended to the names of the environment variables overriding
+// the client's configuration to form their aliases, e.g. XK6_DNS_TIMEOUT, as other
+// extensions name theirs. The original names take precedence over their aliases.
+const envAliasPrefix = "X"
+
+// errNoNameserver is returned when a nameserver is neither provided to a
+// function nor configured.
+var errNoNameserver = errors.New(
+	"nameserver argument must be provided, or configured in options.ext.dns or " + envNameserver,
+)
+
+// clientConfig holds the configuration of the DNS client, as found in the
+// options.ext.dns object of the test, or in its scenarios property for a given
+// scenario.
+type clientConfig struct {
+	Nameserver    string             `json:"nameserver"`
+	Protocol      string             `json:"protocol"`
+	Timeout       types.NullDuration `json:"timeout"`
+	Retries       *int64             `json:"retries"`
+	TLSServerName string             `json:"tlsServerName"`
+	NetworkFamily string             `json:"networkFamily"`
+
+	// EDNSSize holds the UDP payload size queries advertise, either as a number, or
+	// as "auto" for it to be negotiated with each nameserver.
+	EDNSSize interface{} `json:"ednsSize"`
+
+	// Instancing holds whether each VU's client opens its own persistent
+	// connections, as "vu", or those of all the VUs are shared, as "shared".
+	Instancing string `json:"instancing"`
+
+	// Debug holds the fraction of queries whose request and response are logged,
+	// either as a number between 0 and 1, or as a boolean.
+	Debug interface{} `json:"debug"`
+
+	// DurationPerType holds whether the duration of resolutions is also emitted to
+	// a trend metric of their record type, e.g. dns_resolution_duration_txt.
+	DurationPerType *bool `json:"durationPerType"`
+
+	// Scenarios holds the configuration overriding the above for each scenario,
+	// by name.
+	Scenarios map[string]clientConfig `json:"scenarios"`
+}
+
+// scenarioConfig holds the configuration applying to the queries of a VU within
+// its current scenario.
+type scenarioConfig struct {
+	// nameserver holds the nameserver queried when none is provided, if any.
+	nameserver *Nameserver
+
+	// defaults holds the transport options applying to queries which do not set
+	// them.
+	defaults QueryOptions
+
+	// shared holds whether queries are sent over the connections shared between the
+	// VUs, rather than over those of the VU's client.
+	shared bool
+
+	// durationPerType holds whether the duration of resolutions is also emitted to
+	// a trend metric of their record type.
+	durationPerType bool
+}
+
+// apply overrides the configuration with the properties set in config.
+func (c *scenarioConfig) apply(config clientConfig) error {
+	if config.Nameserver != "" {
+		nameserver, err := parseNameserverAddr(config.Nameserver)
+		if err != nil {
+			return fmt.Errorf("nameserver is invalid; reason: %w", err)
+		}
+
+		c.nameserver = &nameserver
+	}
+
+	if config.Protocol != "" {
+		switch protocol := strings.ToLower(config.Protocol); protocol {
+		case protocolUDP, protocolTCP, protocolDoT, protocolDoH:
+			c.defaults.Protocol = protocol
+		default:
+			return fmt.Errorf("protocol must be one of 'udp', 'tcp', 'dot' or 'doh'; got %q instead", config.Protocol)
+		}
+	}
+
+	if config.Timeout.Valid {
+		if config.Timeout.Duration < 0 {
+			return fmt.Errorf("timeout must be a positive duration; got %v instead", config.Timeout.Duration)
+		}
+
+		c.defaults.Timeout = time.Duration(config.Timeout.Duration)
+	}
+
+	if config.Retries != nil {
+		if *config.Retries < 0 {
+			return fmt.Errorf("retries must be a positive integer; got %d instead", *config.Retries)
+		}
+
+		c.defaults.Retries = int(*config.Retries)
+	}
+
+	if config.TLSServerName != "" {
+		c.defaults.TLSServerName = config.TLSServerName
+	}
+
+	if config.NetworkFamily != "" {
+		family, ok := addressFamilyString(config.NetworkFamily)
+		if !ok {
+			return fmt.Errorf(
+				"networkFamily must be one of 'any', 'ipv4' or 'ipv6'; got %q instead", config.NetworkFamily,
+			)
+		}
+
+		c.defaults.NetworkFamily = family
+	}
+
+	if config.EDNSSize != nil {
+		size, negotiate, err := parseEDNSSize(config.EDNSSize)
+		if err != nil {
+			return err
+		}
+
+		c.defaults.EDNSSize, c.defaults.NegotiateEDNSSize = size, negotiate
+	}
+
+	if config.Instancing != "" {
+		switch strings.ToLower(config.Instancing) {
+		case instancingVU:
+			c.shared = false
+		case instancingShared:
+			c.shared = true
+		default:
+			return fmt.Errorf("instancing must be one of 'vu' or 'shared'; got %q instead", config.Instancing)
+		}
+	}
+
+	if config.DurationPerType != nil {
+		c.durationPerType = *config.DurationPerType
+	}
+
+	if config.Debug != nil {
+		sample, err := parseDebugSample(config.Debug)
+		if err != nil {
+			return err
+		}
+
+		c.defaults.Debug = &sample
+	}
+
+	return nil
+}
+
+// explicitOptions records the transport options of QueryOptions set explicitly,
+// so that those set to their zero value still take precedence over defaults.
+type explicitOptions uint8
+
+const (
+	explicitTimeout explicitOptions = 1 << iota
+	explicitRetries
+	explicitTLSServerName
+	explicitNetworkFamily
+)
+
+// withDefaults returns the options, in which the transport options left unset
+// are set to those of defaults. Options are unset when they hold their zero
+// value, unless they were set explicitly, e.g. through the options of a call.
+func (opts QueryOptions) withDefaults(defaults QueryOptions) QueryOptions {
+	if opts.Protocol == "" {
+		opts.Protocol = defaults.Protocol
+	}
+
+	if opts.Timeout == 0 && opts.explicit&explicitTimeout == 0 {
+		opts.Timeout = defaults.Timeout
+	}
+
+	if opts.Retries == 0 && opts.explicit&explicitRetries == 0 {
+		opts.Retries = defaults.Retries
+	}
+
+	if opts.TLSServerName == "" && opts.explicit&explicitTLSServerName == 0 {
+		opts.TLSServerName = defaults.TLSServerName
+	}
+
+	if opts.Debug == nil {
+		opts.Debug = defaults.Debug
+	}
+
+	if opts.NetworkFamily == AddressFamilyAny && opts.explicit&explicitNetworkFamily == 0 {
+		opts.NetworkFamily = defaults.NetworkFamily
+	}
+
+	if opts.EDNSSize == 0 && !opts.NegotiateEDNSSize {
+		opts.EDNSSize, opts.NegotiateEDNSSize = defaults.EDNSSize, defaults.NegotiateEDNSSize
+	}
+
+	return opts
+}
+
+// loadConfig builds the configuration of the VU within its current scenario.
+// The options.ext.dns object applies first, then its entry for the scenario,
+// and finally the environment variables, including those of the scenario.
+//
+// It interacts with the runtime, and thus must be called from the event loop.
+func (mi *ModuleInstance) loadConfig() (scenarioConfig, error) {
+	var config scenarioConfig
+
+	state := mi.vu.State()
+	if state == nil {
+		return config, nil
+	}
+
+	var ext clientConfig
+	if raw, ok := state.Options.External[configExtKey]; ok {
+		if err := json.Unmarshal(raw, &ext); err != nil {
+			return config, fmt.Errorf("options.ext.%s is invalid; reason: %w", configExtKey, err)
+		}
+	}
+
+	if err := config.apply(ext); err != nil {
+		return config, fmt.Errorf("options.ext.%s is invalid; reason: %w", configExtKey, err)
+	}
+
+	if scenario := lib.GetScenarioState(mi.vu.Context()); scenario != nil {
+		if err := config.apply(ext.Scenarios[scenario.Name]); err != nil {
+			return config, fmt.Errorf(
+				"options.ext.%s.scenarios.%s is invalid; reason: %w", configExtKey, scenario.Name, err,
+			)
+		}
+	}
+
+	env, err := mi.configFromEnv()
+	if err != nil {
+		return config, err
+	}
+
+	if err := config.apply(env); err != nil {
+		return config, fmt.Errorf("the K6_DNS_* or XK6_DNS_* environment variables are invalid; reason: %w", err)
+	}
+
+	return config, nil
+}
+
+// configFromEnv returns the configuration set by the environment variables of
+// the VU, as exposed to the script by __ENV.
+func (mi *ModuleInstance) configFromEnv() (clientConfig, error) {
+	var config clientConfig
+
+	value := mi.vu.Runtime().Get("__ENV")
+	if common.IsNullish(value) {
+		return config, nil
+	}
+
+	var env map[string]string
+	if err := mi.vu.Runtime().ExportTo(value, &env); err != nil {
+		return config, nil //nolint:nilerr // a script replacing __ENV does not configure the client
+	}
+
+	// getenv returns the value of the environment variable, or of its alias
+	getenv := func(name string) string {
+		if v := env[name]; v != "" {
+			return v
+		}
+
+		return env[envAliasPrefix+name]
+	}
+
+	config.Nameserver = getenv(envNameserver)
+	config.Protocol = getenv(envProtocol)
+	config.TLSServerName = getenv(envTLSServerName)
+	config.NetworkFamily = getenv(envNetworkFamily)
+	config.Instancing = getenv(envInstancing)
+
+	if v := getenv(envTimeout); v != "" {
+		timeout, err := types.GetDurationValue(v)
+		if err != nil {
+			return config, fmt.Errorf("%s is invalid; reason: %w", envTimeout, err)
+		}
+
+		config.Timeout = types.NullDurationFrom(timeout)
+	}
+
+	if v := getenv(envRetries); v != "" {
+		retries, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return config, fmt.Errorf("%s must be an integer; got %q instead", envRetries, v)
+		}
+
+		config.Retries = &retries
+	}
+
+	if v := getenv(envEDNSSize); v != "" {
+		config.EDNSSize = v
+		if ednsSize, err := strconv.ParseInt(v, 10, 64); err == nil {
+			config.EDNSSize = ednsSize
+		}
+	}
+
+	if v := getenv(envDurationPerType); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return config, fmt.Errorf("%s must be a boolean; got %q instead", envDurationPerType, v)
+		}
+
+		config.DurationPerType = &enabled
+	}
+
+	if v := getenv(envDebug); v != "" {
+		if enabled, err := strconv.ParseBool(v); err == nil {
+			config.Debug = enabled
+		} else if sample, err := strconv.ParseFloat(v, 64); err == nil {
+			config.Debug = sample
+		} else {
+			return config, fmt.Errorf("%s must be a boolean or a number between 0 and 1; got %q instead", envDebug, v)
+		}
+	}
+
+	return config, nil
+}
+
+// config returns the configuration of the VU within its current scenario. It is
+// loaded once per VU activation, as scenarios and their environment variables
+// only change between them, and published to the client as its defaults.
+//
+// It interacts with the runtime, and thus must be called from the event loop.
+func (mi *ModuleInstance) config() (scenarioConfig, error) {
+	mi.configMu.Lock()
+	defer mi.configMu.Unlock()
+
+	ctx := mi.vu.Context()
+	if ctx != nil && ctx == mi.configCtx {
+		return mi.currentConfig, mi.configErr
+	}
+
+	mi.configCtx = ctx
+	mi.currentConfig, mi.configErr = mi.loadConfig()
+
+	return mi.currentConfig, mi.configErr
+}
+
+// queryDefaults returns the transport options of the current configuration.
+//
+// It does not interact with the runtime, and thus can be called from any goroutine.
+func (mi *ModuleInstance) queryDefaults() QueryOptions {
+	mi.configMu.Lock()
+	defer mi.configMu.Unlock()
+
+	return mi.currentConfig.defaults
+}
+
+// sharedInstancing returns whether the current configuration has the VU send its
+// queries over the connections shared between the VUs.
+//
+// It does not interact with the runtime, and thus can be called from any goroutine.
+func (mi *ModuleInstance) sharedInstancing() bool {
+	mi.configMu.Lock()
+	defer mi.configMu.Unlock()
+
+	return mi.currentConfig.shared
+}
+
+// durationPerType returns whether the current configuration has the duration of
+// resolutions also emitted to a trend metric of their record type.
+//
+// It does not interact with the runtime, and thus can be called from any goroutine.
+func (mi *ModuleInstance) durationPerType() bool {
+	mi.configMu.Lock()
+	defer mi.configMu.Unlock()
+
+	return mi.currentConfig.durationPerType
+}
+
+// exportNameserver converts the value into a Nameserver, or returns the
+// configured nameserver when the value is undefined or null.
+func (mi *ModuleInstance) exportNameserver(value sobek.Value) (Nameserver, error) {
+	config, err := mi.config()
+	if err != nil {
+		return Nameserver{}, err
+	}
+
+	if !common.IsNullish(value) {
+		return exportNameserver(mi.vu.Runtime(), value)
+	}
+
+	if config.nameserver == nil {
+		return Nameserver{}, errNoNameserver
+	}
+
+	return *config.nameserver, nil
+}
@@ -0,0 +1,418 @@
+package dns
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+)
+
+func TestModuleInstance_config(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		scenario string
+		env      map[string]string
+		ext      string
+		script   string
+		wantErr  string
+	}{
+		{
+			name:     "The nameserver and protocol of options.ext.dns should be used when omitted",
+			scenario: "default",
+			ext:      `{"nameserver": "{first}", "protocol": "tcp", "scenarios": {"other": {"nameserver": "{second}"}}}`,
+			script: `
+				const answers = await dns.resolve("k6.test", "A");
+				if (answers[0] !== "203.0.113.1") {
+					throw new Error("unexpected answers: " + answers);
+				}
+
+				const queries = first.log();
+				if (queries.length !== 1 || queries[0].protocol !== "tcp") {
+					throw new Error("unexpected queries: " + JSON.stringify(queries));
+				}
+			`,
+		},
+		{
+			name:     "The configuration of the scenario should override options.ext.dns",
+			scenario: "other",
+			ext:      `{"nameserver": "{first}", "protocol": "tcp", "scenarios": {"other": {"nameserver": "{second}"}}}`,
+			script: `
+				const answers = await dns.resolve("k6.test", "A");
+				if (answers[0] !== "203.0.113.2") {
+					throw new Error("unexpected answers: " + answers);
+				}
+
+				if (second.log()[0].protocol !== "tcp") {
+					throw new Error("the protocol of options.ext.dns should still apply");
+				}
+			`,
+		},
+		{
+			name:     "The environment variables should override options.ext.dns",
+			scenario: "default",
+			env:      map[string]string{"K6_DNS_PROTOCOL": "udp"},
+			ext:      `{"nameserver": "{second}", "protocol": "tcp"}`,
+			script: `
+				await dns.resolve("k6.test", "A");
+
+				if (second.log()[0].protocol !== "udp") {
+					throw new Error("the protocol of K6_DNS_PROTOCOL should apply");
+				}
+			`,
+		},
+		{
+			name:     "The options of the call should override the configuration",
+			scenario: "default",
+			ext:      `{"nameserver": "{second}", "protocol": "tcp"}`,
+			script: `
+				const answers = await dns.resolve("k6.test", "A", first.address, { protocol: "udp" });
+				if (answers[0] !== "203.0.113.1" || first.log()[0].protocol !== "udp") {
+					throw new Error("the options of the call should apply");
+				}
+			`,
+		},
+		{
+			name:     "The protocol of each call should override the configured one",
+			scenario: "default",
+			ext:      `{"nameserver": "{first}", "protocol": "tcp"}`,
+			script: `
+				for (const protocol of ["udp", "tcp", undefined]) {
+					await dns.resolve("k6.test", "A", null, { protocol });
+				}
+
+				const protocols = first.log().map((query) => query.protocol).join();
+				if (protocols !== "udp,tcp,tcp") {
+					throw new Error("unexpected protocols: " + protocols);
+				}
+			`,
+		},
+		{
+			name:     "Nameservers outside of the configured network family should not be queried",
+			scenario: "default",
+			env:      map[string]string{"K6_DNS_NETWORK_FAMILY": "ipv6"},
+			ext:      `{"nameserver": "{first}", "networkFamily": "ipv4"}`,
+			script: `
+				const result = await dns.resolve("k6.test", "A", null, { throw: false });
+				if (result.error === null || result.error.name !== "NetworkUnreachable" ||
+					!result.error.message.includes("can not be reached over IPv6")) {
+					throw new Error("unexpected result: " + JSON.stringify(result));
+				}
+
+				if (first.log().length !== 0) {
+					throw new Error("expected no query to be sent");
+				}
+
+				const answers = await dns.resolve("k6.test", "A", null, { networkFamily: "ipv4" });
+				if (answers[0] !== "203.0.113.1") {
+					throw new Error("the network family of the call should apply");
+				}
+			`,
+		},
+		{
+			name:     "A network family of any set by the call should override the configured one",
+			scenario: "default",
+			ext:      `{"nameserver": "{first}", "networkFamily": "ipv6"}`,
+			script: `
+				const answers = await dns.resolve("k6.test", "A", null, { networkFamily: "any" });
+				if (answers[0] !== "203.0.113.1") {
+					throw new Error("the network family of the call should apply");
+				}
+			`,
+		},
+		{
+			name:     "Nameservers within the configured network family should be queried",
+			scenario: "default",
+			ext:      `{"nameserver": "{first}", "networkFamily": "ipv4"}`,
+			script: `
+				const answers = await dns.resolve("k6.test", "A");
+				if (answers[0] !== "203.0.113.1") {
+					throw new Error("unexpected answers: " + answers);
+				}
+			`,
+		},
+		{
+			name:     "The aliases of the environment variables should configure the client",
+			scenario: "default",
+			env: map[string]string{
+				"XK6_DNS_NAMESERVER": "{first}",
+				"XK6_DNS_PROTOCOL":   "udp",
+				"K6_DNS_PROTOCOL":    "tcp",
+				"XK6_DNS_EDNS_SIZE":  "1232",
+			},
+			script: `
+				await dns.resolve("k6.test", "A");
+
+				const queries = first.log();
+				if (queries[0].protocol !== "tcp") {
+					throw new Error("K6_DNS_PROTOCOL should take precedence over its alias");
+				}
+
+				if (queries[0].edns === null || queries[0].edns.udpSize !== 1232) {
+					throw new Error("unexpected EDNS parameters: " + JSON.stringify(queries[0].edns));
+				}
+			`,
+		},
+		{
+			name:     "The EDNS size of the call should override the configured one",
+			scenario: "default",
+			ext:      `{"nameserver": "{first}", "ednsSize": 1232}`,
+			script: `
+				await dns.resolve("k6.test", "A", null, { ednsSize: 4096 });
+				if (first.log()[0].edns.udpSize !== 4096) {
+					throw new Error("unexpected EDNS parameters: " + JSON.stringify(first.log()[0].edns));
+				}
+			`,
+		},
+		{
+			name:     "A negotiated EDNS size should start from the largest one",
+			scenario: "default",
+			env:      map[string]string{"K6_DNS_NAMESERVER": "{first}", "K6_DNS_EDNS_SIZE": "auto"},
+			script: `
+				await dns.resolve("k6.test", "A");
+				if (first.log()[0].edns.udpSize !== 4096) {
+					throw new Error("unexpected EDNS parameters: " + JSON.stringify(first.log()[0].edns));
+				}
+			`,
+		},
+		{
+			name:     "Queries should not advertise an EDNS size by default",
+			scenario: "default",
+			ext:      `{"nameserver": "{first}"}`,
+			script: `
+				await dns.resolve("k6.test", "A");
+				if (first.log()[0].edns !== null) {
+					throw new Error("unexpected EDNS parameters: " + JSON.stringify(first.log()[0].edns));
+				}
+			`,
+		},
+		{
+			name:     "An invalid EDNS size should fail",
+			scenario: "default",
+			env:      map[string]string{"K6_DNS_EDNS_SIZE": "100"},
+			script:   `await dns.resolve("k6.test", "A", first.address);`,
+			wantErr:  "ednsSize option must be 'auto' or an integer between 512 and 65535",
+		},
+		{
+			name:     "An invalid per-type duration setting should fail",
+			scenario: "default",
+			env:      map[string]string{"K6_DNS_DURATION_PER_TYPE": "sometimes"},
+			script:   `await dns.resolve("k6.test", "A", first.address);`,
+			wantErr:  "K6_DNS_DURATION_PER_TYPE must be a boolean",
+		},
+		{
+			name:     "An invalid instancing mode should fail",
+			scenario: "default",
+			ext:      `{"instancing": "global"}`,
+			script:   `await dns.resolve("k6.test", "A", first.address);`,
+			wantErr:  "instancing must be one of 'vu' or 'shared'",
+		},
+		{
+			name:     "Omitting the nameserver without configuring one should fail",
+			scenario: "default",
+			script:   `await dns.resolve("k6.test", "A");`,
+			wantErr:  "nameserver argument must be provided",
+		},
+		{
+			name:     "An invalid configuration should fail",
+			scenario: "default",
+			ext:      `{"protocol": "quic"}`,
+			script:   `await dns.resolve("k6.test", "A", first.address);`,
+			wantErr:  "options.ext.dns is invalid",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			runtime, err := newConfiguredRuntime(t)
+			require.NoError(t, err)
+
+			addressesValue, err := runtime.VU.Runtime().RunString(`
+				const first = dns.startServer(["k6.test. 60 IN A 203.0.113.1"]);
+				const second = dns.startServer(["k6.test. 60 IN A 203.0.113.2"]);
+				[first.address, second.address];
+			`)
+			require.NoError(t, err)
+
+			var addresses []string
+			require.NoError(t, runtime.VU.Runtime().ExportTo(addressesValue, &addresses))
+
+			env := make(map[string]string, len(tt.env))
+			for name, value := range tt.env {
+				env[name] = strings.NewReplacer("{first}", addresses[0], "{second}", addresses[1]).Replace(value)
+			}
+			require.NoError(t, runtime.VU.Runtime().Set("__ENV", env))
+
+			state := &lib.State{
+				BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+				Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+				Samples:        make(chan metrics.SampleContainer, 1024),
+			}
+			if tt.ext != "" {
+				state.Options.External = map[string]json.RawMessage{
+					"dns": json.RawMessage(strings.NewReplacer("{first}", addresses[0], "{second}", addresses[1]).Replace(tt.ext)),
+				}
+			}
+
+			runtime.VU.CtxField = lib.WithScenarioState(runtime.VU.CtxField, &lib.ScenarioState{Name: tt.scenario})
+			runtime.MoveToVUContext(state)
+
+			_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(tt.script))
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestModuleInstance_config_durationPerType(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		ext  string
+		want map[string]int
+	}{
+		{
+			name: "The duration should only be emitted to the overall trend by default",
+			want: map[string]int{"dns_resolution_duration": 3},
+		},
+		{
+			name: "The duration should be emitted to the trend of each record type when configured to",
+			ext:  `{"durationPerType": true}`,
+			want: map[string]int{
+				"dns_resolution_duration":     3,
+				"dns_resolution_duration_a":   2,
+				"dns_resolution_duration_txt": 1,
+			},
+		},
+		{
+			name: "The environment variables should override options.ext.dns",
+			env:  map[string]string{"K6_DNS_DURATION_PER_TYPE": "false"},
+			ext:  `{"durationPerType": true}`,
+			want: map[string]int{"dns_resolution_duration": 3},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			runtime, err := newConfiguredRuntime(t)
+			require.NoError(t, err)
+			require.NoError(t, runtime.VU.Runtime().Set("__ENV", tt.env))
+
+			samples := make(chan metrics.SampleContainer, 1024)
+			state := &lib.State{
+				BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+				Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+				Samples:        samples,
+			}
+			if tt.ext != "" {
+				state.Options.External = map[string]json.RawMessage{"dns": json.RawMessage(tt.ext)}
+			}
+
+			runtime.MoveToVUContext(state)
+
+			_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+				const server = dns.startServer(["k6.test. 60 IN A 203.0.113.1", "k6.test. 60 IN TXT \"k6\""]);
+
+				await dns.resolve("k6.test", "A", server.address);
+				await dns.resolve("k6.test", "TXT", server.address);
+				await dns.resolve("k6.test", "A", server.address);
+
+				server.close();
+			`))
+			require.NoError(t, err)
+
+			got := map[string]int{}
+			for len(samples) > 0 {
+				for _, sample := range (<-samples).GetSamples() {
+					if !strings.HasPrefix(sample.Metric.Name, "dns_resolution_duration") {
+						continue
+					}
+
+					// Duration samples are tagged by record type, whichever trend they go to
+					recordType, ok := sample.Tags.Get("recordType")
+					assert.True(t, ok)
+					assert.NotEmpty(t, recordType)
+
+					got[sample.Metric.Name]++
+				}
+			}
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestQueryOptions_withDefaults(t *testing.T) {
+	t.Parallel()
+
+	defaults := QueryOptions{
+		Protocol:      protocolTCP,
+		Timeout:       5 * time.Second,
+		Retries:       3,
+		TLSServerName: "dns.k6.test",
+		NetworkFamily: AddressFamilyIPv6,
+	}
+
+	tests := []struct {
+		name string
+		opts QueryOptions
+		want QueryOptions
+	}{
+		{
+			name: "Unset options should fall back to the defaults",
+			opts: QueryOptions{},
+			want: defaults,
+		},
+		{
+			name: "Set options should override the defaults",
+			opts: QueryOptions{Protocol: protocolUDP, Retries: 1, NetworkFamily: AddressFamilyIPv4},
+			want: QueryOptions{
+				Protocol:      protocolUDP,
+				Timeout:       5 * time.Second,
+				Retries:       1,
+				TLSServerName: "dns.k6.test",
+				NetworkFamily: AddressFamilyIPv4,
+			},
+		},
+		{
+			name: "Options explicitly set to their zero value should override the defaults",
+			opts: QueryOptions{
+				explicit: explicitTimeout | explicitRetries | explicitTLSServerName | explicitNetworkFamily,
+			},
+			want: QueryOptions{
+				Protocol: protocolTCP,
+				explicit: explicitTimeout | explicitRetries | explicitTLSServerName | explicitNetworkFamily,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.want, tt.opts.withDefaults(defaults))
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package dns
+
+import (
+	"net/http"
+	"sync"
+)
+
+// Instancing modes of the clients of the VUs, as configured through the instancing
+// property of options.ext.dns.
+const (
+	// instancingVU has each VU's client open its own persistent connections.
+	instancingVU = "vu"
+
+	// instancingShared has the clients of all the VUs send their queries over the
+	// same persistent connections.
+	instancingShared = "shared"
+)
+
+// connections holds the persistent connections queries are sent over with TCP,
+// DoT, DoH and to Unix domain sockets.
+type connections struct {
+	// pool holds the persistent connections queries are sent over with TCP and DoT.
+	pool *connPool
+
+	// httpTransports holds the transports DoH queries are sent over, by the server
+	// name the certificate of nameservers is verified against and HTTP version.
+	httpTransportsMu sync.Mutex
+	httpTransports   map[httpTransportKey]*http.Transport
+}
+
+// newConnections creates an empty set of connections.
+func newConnections() *connections {
+	return &connections{
+		pool:           newConnPool(),
+		httpTransports: make(map[httpTransportKey]*http.Transport),
+	}
+}
+
+// close closes the connections of the pool, failing the queries outstanding over
+// them, along with the idle connections of the DoH transports.
+func (c *connections) close() error {
+	c.httpTransportsMu.Lock()
+	for _, transport := range c.httpTransports {
+		transport.CloseIdleConnections()
+	}
+	c.httpTransportsMu.Unlock()
+
+	return c.pool.closeAll()
+}
+
+// connections returns the connections the client sends its queries over.
+func (r *Client) connections() *connections {
+	if r.sharedConns != nil && r.shared != nil && r.shared() {
+		return r.sharedConns
+	}
+
+	return r.conns
+}
+
+// sharedConnections holds the connections shared by the clients of the VUs whose
+// instancing mode is shared, and closes them once none of these VUs is active.
+type sharedConnections struct {
+	*connections
+
+	mu     sync.Mutex
+	active int
+}
+
+// newSharedConnections creates an empty set of shared connections.
+func newSharedConnections() *sharedConnections {
+	return &sharedConnections{connections: newConnections()}
+}
+
+// acquire accounts for a VU sending queries over the shared connections, until
+// the returned function is called.
+func (s *sharedConnections) acquire() (release func() error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.active++
+
+	var once sync.Once
+	return func() error {
+		var err error
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+
+			s.active--
+			if s.active == 0 {
+				err = s.close()
+			}
+		})
+
+		return err
+	}
+}
@@ -0,0 +1,111 @@
+package dns
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/js/compiler"
+	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+)
+
+func TestModuleInstance_instancing(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name            string
+		ext             string
+		wantConnections int64
+	}{
+		{
+			name:            "Each VU should open its own connections by default",
+			ext:             `{}`,
+			wantConnections: 2,
+		},
+		{
+			name:            "VUs should open their own connections when instanced per VU",
+			ext:             `{"instancing": "vu"}`,
+			wantConnections: 2,
+		},
+		{
+			name:            "VUs should share their connections when instanced as shared",
+			ext:             `{"instancing": "shared"}`,
+			wantConnections: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rootModule := New()
+
+			var address string
+			cancels := make([]context.CancelFunc, 0, 2)
+			for i := 0; i < 2; i++ {
+				runtime := modulestest.NewRuntime(t)
+				require.NoError(t, runtime.SetupModuleSystem(
+					map[string]interface{}{"k6/x/dns": rootModule},
+					nil,
+					compiler.New(runtime.VU.InitEnv().Logger),
+				))
+
+				_, err := runtime.VU.Runtime().RunString(initGlobals)
+				require.NoError(t, err)
+
+				// The first VU serves the queries of both
+				if address == "" {
+					v, err := runtime.VU.Runtime().RunString(`
+						const server = dns.startServer(["k6.test. 60 IN A 203.0.113.1"]);
+						server.address;
+					`)
+					require.NoError(t, err)
+
+					address = v.String()
+				}
+
+				require.NoError(t, runtime.VU.Runtime().Set("address", address))
+
+				ctx, cancel := context.WithCancel(runtime.VU.CtxField)
+				t.Cleanup(cancel)
+				cancels = append(cancels, cancel)
+
+				runtime.VU.CtxField = ctx
+				runtime.MoveToVUContext(&lib.State{
+					Options: lib.Options{
+						External: map[string]json.RawMessage{"dns": json.RawMessage(tt.ext)},
+					},
+					BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+					Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+					Samples:        make(chan metrics.SampleContainer, 1024),
+				})
+
+				_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+					await dns.resolve("k6.test", "A", address, { protocol: "tcp" });
+				`))
+				require.NoError(t, err)
+			}
+
+			assert.Equal(t, tt.wantConnections, rootModule.openConnections.Load())
+
+			// Connections are closed once all the VUs using them are done, so that
+			// the second VU keeps its own or the shared one open
+			cancels[0]()
+			assert.Eventually(t, func() bool {
+				return rootModule.openConnections.Load() == 1
+			}, time.Second, 10*time.Millisecond)
+
+			cancels[1]()
+			assert.Eventually(t, func() bool {
+				return rootModule.openConnections.Load() == 0
+			}, time.Second, 10*time.Millisecond)
+		})
+	}
+}
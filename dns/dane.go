@@ -0,0 +1,410 @@
+package dns
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/sobek"
+	"github.com/miekg/dns"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/promises"
+	"go.k6.io/k6/lib"
+)
+
+// TLSA certificate usages, as defined by RFC 6698.
+const (
+	tlsaUsagePKIXTA = 0
+	tlsaUsagePKIXEE = 1
+	tlsaUsageDANETA = 2
+	tlsaUsageDANEEE = 3
+)
+
+// TLSAMatch describes the outcome of verifying a TLSA record against a
+// certificate chain.
+type TLSAMatch struct {
+	// Record holds the verified TLSA record.
+	Record *dns.TLSA
+
+	// Matched indicates whether the record matched a certificate of the chain.
+	Matched bool
+
+	// Certificate holds the certificate of the chain the record matched, if any.
+	Certificate *x509.Certificate
+
+	// Err holds the reason why the record could not be verified, if any.
+	Err error
+}
+
+// verifyTLSA verifies each of the TLSA records against the certificate chain,
+// leaf certificate first, as described by RFC 6698 and RFC 7671.
+//
+// Records with an end entity usage are matched against the leaf certificate,
+// while records with a trust anchor usage are matched against the other
+// certificates of the chain. Records with a PKIX usage only match if the chain
+// passed PKIX validation, that is, if pkixErr is nil.
+func verifyTLSA(records []*dns.TLSA, chain []*x509.Certificate, pkixErr error) []TLSAMatch {
+	matches := make([]TLSAMatch, 0, len(records))
+
+	for _, record := range records {
+		match := TLSAMatch{Record: record}
+
+		var candidates []*x509.Certificate
+		switch record.Usage {
+		case tlsaUsagePKIXEE, tlsaUsageDANEEE:
+			candidates = chain[:min(1, len(chain))]
+		case tlsaUsagePKIXTA, tlsaUsageDANETA:
+			candidates = chain[min(1, len(chain)):]
+		default:
+			match.Err = fmt.Errorf("unsupported certificate usage %d", record.Usage)
+		}
+
+		for _, cert := range candidates {
+			association, err := dns.CertificateToDANE(record.Selector, record.MatchingType, cert)
+			if err != nil {
+				match.Err = fmt.Errorf("unsupported selector %d or matching type %d", record.Selector, record.MatchingType)
+				break
+			}
+
+			if strings.EqualFold(association, record.Certificate) {
+				match.Matched = true
+				match.Certificate = cert
+				break
+			}
+		}
+
+		isPKIX := record.Usage == tlsaUsagePKIXTA || record.Usage == tlsaUsagePKIXEE
+		if match.Matched && isPKIX && pkixErr != nil {
+			match.Matched = false
+			match.Certificate = nil
+			match.Err = fmt.Errorf("certificate matched, but failed PKIX validation: %w", pkixErr)
+		}
+
+		matches = append(matches, match)
+	}
+
+	return matches
+}
+
+// verifyPKIX validates the certificate chain, leaf certificate first, for the
+// hostname, against the system's trusted roots.
+func verifyPKIX(hostname string, chain []*x509.Certificate) error {
+	if len(chain) == 0 {
+		return errors.New("no certificate to validate")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := chain[0].Verify(x509.VerifyOptions{
+		DNSName:       hostname,
+		Intermediates: intermediates,
+	})
+
+	return err
+}
+
+// parseCertificateChain parses the PEM encoded certificates, leaf certificate first.
+func parseCertificateChain(data string) ([]*x509.Certificate, error) {
+	var chain []*x509.Certificate
+
+	rest := []byte(data)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing certificate failed: %w", err)
+		}
+
+		chain = append(chain, cert)
+	}
+
+	if len(chain) == 0 {
+		return nil, errors.New("no PEM encoded certificate found")
+	}
+
+	return chain, nil
+}
+
+// verifyTLSAResult is the object the verifyTLSA function resolves to.
+type verifyTLSAResult struct {
+	// Name holds the name the TLSA records were queried for, e.g. "_443._tcp.k6.io".
+	Name string `js:"name"`
+
+	// Valid indicates whether at least one of the TLSA records matched the certificates.
+	Valid bool `js:"valid"`
+
+	// Authenticated indicates whether the nameserver set the authenticated data flag
+	// of the response, meaning it validated the TLSA records using DNSSEC.
+	Authenticated bool `js:"authenticated"`
+
+	// PKIXValid indicates whether the certificate chain passed PKIX validation.
+	PKIXValid bool `js:"pkixValid"`
+
+	// Records holds the outcome of verifying each of the TLSA records.
+	Records []tlsaRecordResult `js:"records"`
+}
+
+// tlsaRecordResult describes the outcome of verifying a TLSA record.
+type tlsaRecordResult struct {
+	// Usage holds the certificate usage of the record.
+	Usage uint8 `js:"usage"`
+
+	// Selector holds the selector of the record.
+	Selector uint8 `js:"selector"`
+
+	// MatchingType holds the matching type of the record.
+	MatchingType uint8 `js:"matchingType"`
+
+	// Data holds the certificate association data of the record, hex encoded.
+	Data string `js:"data"`
+
+	// Matched indicates whether the record matched a certificate.
+	Matched bool `js:"matched"`
+
+	// Certificate holds the subject of the certificate the record matched, if any.
+	Certificate string `js:"certificate"`
+
+	// Error holds the reason why the record could not be verified, if any.
+	Error string `js:"error"`
+}
+
+// newVerifyTLSAResult creates a verifyTLSAResult out of the outcome of a verification.
+func newVerifyTLSAResult(name string, response *Response, matches []TLSAMatch, pkixErr error) *verifyTLSAResult {
+	result := &verifyTLSAResult{
+		Name:          name,
+		Authenticated: response.msg.AuthenticatedData,
+		PKIXValid:     pkixErr == nil,
+		Records:       make([]tlsaRecordResult, 0, len(matches)),
+	}
+
+	for _, match := range matches {
+		record := tlsaRecordResult{
+			Usage:        match.Record.Usage,
+			Selector:     match.Record.Selector,
+			MatchingType: match.Record.MatchingType,
+			Data:         strings.ToLower(match.Record.Certificate),
+			Matched:      match.Matched,
+		}
+
+		if match.Certificate != nil {
+			record.Certificate = match.Certificate.Subject.String()
+		}
+
+		if match.Err != nil {
+			record.Error = match.Err.Error()
+		}
+
+		result.Valid = result.Valid || match.Matched
+		result.Records = append(result.Records, record)
+	}
+
+	return result
+}
+
+// verifyTLSAOptions holds the options that can be passed to the verifyTLSA function.
+type verifyTLSAOptions struct {
+	resolveOptions
+
+	// Protocol holds the transport protocol of the service, e.g. "tcp".
+	Protocol string
+
+	// Certificate holds the PEM encoded certificate chain to verify. When empty,
+	// the chain is retrieved by connecting to the service.
+	Certificate string
+
+	// Address holds the address to connect to in order to retrieve the certificate
+	// chain. When empty, the service's hostname and port are used.
+	Address string
+}
+
+// parseVerifyTLSAOptions parses the options object passed to the verifyTLSA function.
+//
+// It accepts the same options as the resolve function, along with the protocol,
+// certificate and address options.
+func parseVerifyTLSAOptions(rt *sobek.Runtime, value sobek.Value) (verifyTLSAOptions, error) {
+	resolveOpts, err := parseResolveOptions(rt, value)
+	if err != nil {
+		return verifyTLSAOptions{}, err
+	}
+
+	opts := verifyTLSAOptions{
+		resolveOptions: resolveOpts,
+		Protocol:       "tcp",
+	}
+
+	if common.IsNullish(value) {
+		return opts, nil
+	}
+
+	params := value.ToObject(rt)
+
+	if v := params.Get("protocol"); !common.IsNullish(v) {
+		switch v.String() {
+		case "tcp", "udp", "sctp":
+			opts.Protocol = v.String()
+		default:
+			return opts, fmt.Errorf("protocol option must be one of 'tcp', 'udp' or 'sctp'; got %v instead", v)
+		}
+	}
+
+	if v := params.Get("certificate"); !common.IsNullish(v) {
+		opts.Certificate = v.String()
+	}
+
+	if v := params.Get("address"); !common.IsNullish(v) {
+		opts.Address = v.String()
+	}
+
+	return opts, nil
+}
+
+// VerifyTLSA fetches the TLSA records of a service, and verifies them against the
+// certificate chain the service presents, as a DANE client would.
+func (mi *ModuleInstance) VerifyTLSA(hostname, port, nameserverAddr, options sobek.Value) *sobek.Promise {
+	promise, resolve, reject := promises.New(mi.vu)
+
+	if mi.vu.State() == nil {
+		reject(errors.New("verifyTLSA can not be used in the init context"))
+		return promise
+	}
+
+	hostnameStr, err := exportDomainName(mi.vu.Runtime(), hostname, "hostname")
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	var portNum int64
+	if err := mi.vu.Runtime().ExportTo(port, &portNum); err != nil || portNum < 1 || portNum > 65535 {
+		reject(fmt.Errorf("port must be an integer between 1 and 65535; got %v instead", port))
+		return promise
+	}
+
+	nameserver, err := mi.exportNameserver(nameserverAddr)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	opts, err := parseVerifyTLSAOptions(mi.vu.Runtime(), options)
+	if err != nil {
+		reject(fmt.Errorf("invalid verifyTLSA options: %w", err))
+		return promise
+	}
+
+	var chain []*x509.Certificate
+	if opts.Certificate != "" {
+		if chain, err = parseCertificateChain(opts.Certificate); err != nil {
+			reject(fmt.Errorf("certificate option is invalid; reason: %w", err))
+			return promise
+		}
+	}
+
+	address := opts.Address
+	if address == "" {
+		address = net.JoinHostPort(hostnameStr, strconv.FormatInt(portNum, 10))
+	}
+
+	// Connect using k6's dialer, so that the network restrictions of the test apply
+	var dialer lib.DialContexter = &net.Dialer{}
+	if state := mi.vu.State(); state.Dialer != nil {
+		dialer = state.Dialer
+	}
+
+	ctx, cancel, err := mi.withAbortSignal(opts.Signal)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer cancel()
+
+		// Query the TLSA records, requesting DNSSEC validation from the nameserver
+		name := fmt.Sprintf("_%d._%s.%s", portNum, opts.Protocol, hostnameStr)
+
+		msg := new(dns.Msg)
+		msg.SetQuestion(dns.Fqdn(name), dns.TypeTLSA)
+		msg.AuthenticatedData = true
+		msg.SetEdns0(dns.DefaultMsgSize, true)
+
+		response, _, err := mi.sendMessage(ctx, msg, nameserver, opts.QueryOptions)
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		if response.msg.Rcode != dns.RcodeSuccess && response.msg.Rcode != dns.RcodeNameError {
+			reject(withNameserver(newDNSError(response.msg.Rcode, "querying the TLSA records failed"), nameserver))
+			return
+		}
+
+		var records []*dns.TLSA
+		for _, rr := range response.msg.Answer {
+			if tlsa, ok := rr.(*dns.TLSA); ok {
+				records = append(records, tlsa)
+			}
+		}
+
+		// Retrieve the certificate chain from the service itself, unless provided
+		if chain == nil {
+			if chain, err = fetchCertificateChain(ctx, dialer, address, hostnameStr); err != nil {
+				reject(err)
+				return
+			}
+		}
+
+		pkixErr := verifyPKIX(hostnameStr, chain)
+		resolve(newVerifyTLSAResult(name, response, verifyTLSA(records, chain, pkixErr), pkixErr))
+	}()
+
+	return promise
+}
+
+// fetchCertificateChain connects to the address using the dialer, and returns the
+// certificate chain presented for the hostname, leaf certificate first.
+func fetchCertificateChain(
+	ctx context.Context,
+	dialer lib.DialContexter,
+	address, hostname string,
+) ([]*x509.Certificate, error) {
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to %s failed: %w", address, err)
+	}
+
+	// The chain is verified against the TLSA records and validated separately,
+	// as DANE allows for certificates which would not pass PKIX validation.
+	tlsConn := tls.Client(conn, &tls.Config{
+		ServerName:         hostname,
+		InsecureSkipVerify: true, //nolint:gosec
+		MinVersion:         tls.VersionTLS12,
+	})
+	defer func() {
+		_ = tlsConn.Close()
+	}()
+
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return nil, fmt.Errorf("TLS handshake with %s failed: %w", address, err)
+	}
+
+	return tlsConn.ConnectionState().PeerCertificates, nil
+}
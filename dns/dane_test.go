@@ -0,0 +1,155 @@
+package dns
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_verifyTLSA(t *testing.T) {
+	t.Parallel()
+
+	leaf := newTestCertificate(t, "k6.io")
+	issuer := newTestCertificate(t, "k6 CA")
+	chain := []*x509.Certificate{leaf, issuer}
+
+	tlsa := func(usage, selector, matchingType uint8, cert *x509.Certificate) *dns.TLSA {
+		t.Helper()
+
+		association, err := dns.CertificateToDANE(selector, matchingType, cert)
+		require.NoError(t, err)
+
+		return &dns.TLSA{Usage: usage, Selector: selector, MatchingType: matchingType, Certificate: association}
+	}
+
+	tests := []struct {
+		name        string
+		record      *dns.TLSA
+		pkixErr     error
+		wantMatched bool
+		wantCert    *x509.Certificate
+		wantErr     bool
+	}{
+		{
+			name:        "DANE-EE matching the leaf certificate",
+			record:      tlsa(3, 1, 1, leaf),
+			wantMatched: true,
+			wantCert:    leaf,
+		},
+		{
+			name:   "DANE-EE matching the issuer certificate",
+			record: tlsa(3, 1, 1, issuer),
+		},
+		{
+			name:        "DANE-TA matching the issuer certificate",
+			record:      tlsa(2, 0, 2, issuer),
+			wantMatched: true,
+			wantCert:    issuer,
+		},
+		{
+			name:        "DANE-EE ignores PKIX validation",
+			record:      tlsa(3, 0, 1, leaf),
+			pkixErr:     errors.New("unknown authority"),
+			wantMatched: true,
+			wantCert:    leaf,
+		},
+		{
+			name:        "PKIX-EE with a valid chain",
+			record:      tlsa(1, 1, 1, leaf),
+			wantMatched: true,
+			wantCert:    leaf,
+		},
+		{
+			name:    "PKIX-EE with an invalid chain",
+			record:  tlsa(1, 1, 1, leaf),
+			pkixErr: errors.New("unknown authority"),
+			wantErr: true,
+		},
+		{
+			name:    "PKIX-TA with an invalid chain",
+			record:  tlsa(0, 1, 1, issuer),
+			pkixErr: errors.New("unknown authority"),
+			wantErr: true,
+		},
+		{
+			name:    "unsupported usage",
+			record:  &dns.TLSA{Usage: 4, Selector: 1, MatchingType: 1, Certificate: "00"},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported matching type",
+			record:  &dns.TLSA{Usage: 3, Selector: 1, MatchingType: 3, Certificate: "00"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := verifyTLSA([]*dns.TLSA{tt.record}, chain, tt.pkixErr)
+			require.Len(t, got, 1)
+
+			assert.Equal(t, tt.wantMatched, got[0].Matched)
+			assert.Equal(t, tt.wantCert, got[0].Certificate)
+			assert.Equal(t, tt.wantErr, got[0].Err != nil)
+		})
+	}
+}
+
+func Test_parseCertificateChain(t *testing.T) {
+	t.Parallel()
+
+	leaf := newTestCertificate(t, "k6.io")
+	issuer := newTestCertificate(t, "k6 CA")
+
+	data := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf.Raw})) +
+		string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("ignored")})) +
+		string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: issuer.Raw}))
+
+	got, err := parseCertificateChain(data)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.True(t, got[0].Equal(leaf))
+	assert.True(t, got[1].Equal(issuer))
+
+	_, err = parseCertificateChain("not a certificate")
+	assert.Error(t, err)
+}
+
+// newTestCertificate creates a self-signed certificate for the common name.
+func newTestCertificate(t *testing.T, commonName string) *x509.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return cert
+}
@@ -0,0 +1,204 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grafana/sobek"
+	"github.com/miekg/dns"
+	"go.k6.io/k6/js/promises"
+)
+
+// resolverArpaName is the name unencrypted resolvers serve the SVCB records of
+// the encrypted resolvers they designate under, as defined by RFC 9462.
+const resolverArpaName = "_dns.resolver.arpa."
+
+// designatedProtocols maps the ALPN identifiers of designated resolvers to the
+// encrypted protocols they are reached over.
+var designatedProtocols = map[string]string{ //nolint:gochecknoglobals
+	"dot": protocolDoT,
+	"doq": "doq",
+	"h2":  protocolDoH,
+	"h3":  protocolDoH,
+}
+
+// designatedResolver describes an encrypted resolver designated by an
+// unencrypted one, out of its SVCB record.
+type designatedResolver struct {
+	// Priority holds the priority of the SVCB record, lower values first.
+	Priority uint16 `js:"priority"`
+
+	// Target holds the name of the designated resolver, its certificate is
+	// verified against.
+	Target string `js:"target"`
+
+	// ALPN holds the ALPN identifiers the designated resolver supports, e.g. "dot"
+	// or "h2".
+	ALPN []string `js:"alpn"`
+
+	// Protocols holds the protocols the designated resolver can be queried over,
+	// e.g. "dot" or "doh", derived from its ALPN identifiers.
+	Protocols []string `js:"protocols"`
+
+	// Port holds the port the designated resolver listens on, if advertised, or
+	// nil otherwise.
+	Port interface{} `js:"port"`
+
+	// DoHPath holds the URI template of DoH queries, e.g. "/dns-query{?dns}", if
+	// the designated resolver supports DoH.
+	DoHPath string `js:"dohPath"`
+
+	// IPv4Hint and IPv6Hint hold the addresses the designated resolver can be
+	// reached at, if advertised.
+	IPv4Hint []string `js:"ipv4Hint"`
+	IPv6Hint []string `js:"ipv6Hint"`
+}
+
+// newDesignatedResolver creates a designatedResolver out of the SVCB record.
+func newDesignatedResolver(svcb *dns.SVCB) designatedResolver {
+	resolver := designatedResolver{
+		Priority:  svcb.Priority,
+		Target:    strings.TrimSuffix(svcb.Target, "."),
+		ALPN:      []string{},
+		Protocols: []string{},
+		IPv4Hint:  []string{},
+		IPv6Hint:  []string{},
+	}
+
+	for _, value := range svcb.Value {
+		switch v := value.(type) {
+		case *dns.SVCBAlpn:
+			resolver.ALPN = append(resolver.ALPN, v.Alpn...)
+		case *dns.SVCBPort:
+			resolver.Port = v.Port
+		case *dns.SVCBDoHPath:
+			resolver.DoHPath = v.Template
+		case *dns.SVCBIPv4Hint:
+			for _, ip := range v.Hint {
+				resolver.IPv4Hint = append(resolver.IPv4Hint, ip.String())
+			}
+		case *dns.SVCBIPv6Hint:
+			for _, ip := range v.Hint {
+				resolver.IPv6Hint = append(resolver.IPv6Hint, ip.String())
+			}
+		}
+	}
+
+	seen := make(map[string]bool, len(resolver.ALPN))
+	for _, alpn := range resolver.ALPN {
+		protocol, ok := designatedProtocols[alpn]
+		if !ok || seen[protocol] {
+			continue
+		}
+
+		seen[protocol] = true
+		resolver.Protocols = append(resolver.Protocols, protocol)
+	}
+
+	return resolver
+}
+
+// resolversResult is the object the discoverResolvers function resolves to.
+type resolversResult struct {
+	// Detected indicates whether the nameserver designates encrypted resolvers.
+	Detected bool `js:"detected"`
+
+	// Resolvers holds the designated resolvers, by ascending priority.
+	Resolvers []designatedResolver `js:"resolvers"`
+
+	// RTT holds the duration of the resolution, in milliseconds.
+	RTT float64 `js:"rtt"`
+}
+
+// DiscoverResolvers discovers the encrypted resolvers a nameserver designates, as
+// defined by RFC 9462, by resolving the SVCB records of the _dns.resolver.arpa
+// name.
+func (mi *ModuleInstance) DiscoverResolvers(nameserverAddr, options sobek.Value) *sobek.Promise {
+	promise, resolve, reject := promises.New(mi.vu)
+
+	if mi.vu.State() == nil {
+		reject(errors.New("discoverResolvers can not be used in the init context"))
+		return promise
+	}
+
+	nameserver, err := mi.exportNameserver(nameserverAddr)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	opts, err := parseResolveOptions(mi.vu.Runtime(), options)
+	if err != nil {
+		reject(fmt.Errorf("invalid discoverResolvers options: %w", err))
+		return promise
+	}
+
+	ctx, cancel, err := mi.withAbortSignal(opts.Signal)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer cancel()
+
+		result, err := mi.discoverResolvers(ctx, nameserver, opts)
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(result)
+	}()
+
+	return promise
+}
+
+// discoverResolvers resolves the SVCB records of the _dns.resolver.arpa name
+// against the nameserver, and describes the designated resolvers they hold.
+func (mi *ModuleInstance) discoverResolvers(
+	ctx context.Context,
+	nameserver Nameserver,
+	opts resolveOptions,
+) (*resolversResult, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(resolverArpaName, dns.TypeSVCB)
+	msg.RecursionDesired = !opts.NoRecursion
+
+	response, duration, err := mi.sendMessage(ctx, msg, nameserver, opts.QueryOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &resolversResult{
+		Resolvers: []designatedResolver{},
+		RTT:       float64(duration) / float64(time.Millisecond),
+	}
+
+	// Nameservers not designating encrypted resolvers answer that the name does
+	// not exist, or without records
+	switch response.msg.Rcode {
+	case dns.RcodeSuccess, dns.RcodeNameError:
+	default:
+		return nil, withNameserver(newDNSError(response.msg.Rcode, "discovering designated resolvers failed"), nameserver)
+	}
+
+	for _, rr := range response.msg.Answer {
+		// Records in the AliasMode do not designate resolvers themselves
+		if svcb, ok := rr.(*dns.SVCB); ok && svcb.Priority > 0 {
+			result.Resolvers = append(result.Resolvers, newDesignatedResolver(svcb))
+		}
+	}
+
+	sort.SliceStable(result.Resolvers, func(i, j int) bool {
+		return result.Resolvers[i].Priority < result.Resolvers[j].Priority
+	})
+
+	result.Detected = len(result.Resolvers) > 0
+
+	return result, nil
+}
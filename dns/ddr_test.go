@@ -0,0 +1,83 @@
+package dns
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+)
+
+func TestDiscoverResolvers(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Designated resolvers should be described by ascending priority", func(t *testing.T) {
+		t.Parallel()
+
+		runtime, err := newConfiguredRuntime(t)
+		require.NoError(t, err)
+
+		_, err = runtime.VU.Runtime().RunString(`
+			const server = dns.startServer([
+				'_dns.resolver.arpa. 300 IN SVCB 2 doh.k6.test. alpn="h2,h3" dohpath="/dns-query{?dns}" ipv6hint=2001:db8::53',
+				'_dns.resolver.arpa. 300 IN SVCB 1 dot.k6.test. alpn="dot" port=853 ipv4hint=192.0.2.53',
+				'_dns.resolver.arpa. 300 IN SVCB 0 resolver.k6.test.',
+			]);
+		`)
+		require.NoError(t, err)
+
+		runtime.MoveToVUContext(&lib.State{
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+			Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+			Samples:        make(chan metrics.SampleContainer, 1024),
+		})
+
+		_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+			const result = await dns.discoverResolvers(server.address);
+
+			if (!result.detected || result.resolvers.length !== 2) {
+				throw new Error("unexpected resolvers: " + JSON.stringify(result));
+			}
+
+			const [dot, doh] = result.resolvers;
+			if (dot.target !== "dot.k6.test" || dot.protocols.join() !== "dot" || dot.port !== 853 ||
+				dot.ipv4Hint.join() !== "192.0.2.53") {
+				throw new Error("unexpected DoT resolver: " + JSON.stringify(dot));
+			}
+
+			if (doh.target !== "doh.k6.test" || doh.protocols.join() !== "doh" || doh.port !== null ||
+				doh.dohPath !== "/dns-query{?dns}" || doh.ipv6Hint.join() !== "2001:db8::53") {
+				throw new Error("unexpected DoH resolver: " + JSON.stringify(doh));
+			}
+		`))
+		assert.NoError(t, err)
+	})
+
+	t.Run("Nameservers not designating resolvers should not be detected", func(t *testing.T) {
+		t.Parallel()
+
+		runtime, err := newConfiguredRuntime(t)
+		require.NoError(t, err)
+
+		_, err = runtime.VU.Runtime().RunString(`
+			const server = dns.startServer(["k6.test. 60 IN A 203.0.113.1"]);
+		`)
+		require.NoError(t, err)
+
+		runtime.MoveToVUContext(&lib.State{
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+			Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+			Samples:        make(chan metrics.SampleContainer, 1024),
+		})
+
+		_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+			const result = await dns.discoverResolvers(server.address);
+
+			if (result.detected || result.resolvers.length !== 0) {
+				throw new Error("unexpected resolvers: " + JSON.stringify(result));
+			}
+		`))
+		assert.NoError(t, err)
+	})
+}
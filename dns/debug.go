@@ -0,0 +1,131 @@
+package dns
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/miekg/dns"
+	"golang.org/x/time/rate"
+)
+
+// defaultDebugRate is the maximum number of exchanges logged per second, across
+// all the VUs, so that logging a fraction of the queries of a large load does not
+// flood the output.
+const defaultDebugRate = 10
+
+// parseDebugSample parses the value of the debug option, either a boolean, or the
+// fraction of queries to log, between 0 and 1.
+func parseDebugSample(value interface{}) (float64, error) {
+	var sample float64
+
+	switch v := value.(type) {
+	case bool:
+		if v {
+			sample = 1
+		}
+	case int64:
+		sample = float64(v)
+	case float64:
+		sample = v
+	default:
+		return 0, fmt.Errorf("debug option must be a boolean or a number between 0 and 1; got %v instead", value)
+	}
+
+	if math.IsNaN(sample) || sample < 0 || sample > 1 {
+		return 0, fmt.Errorf("debug option must be a boolean or a number between 0 and 1; got %v instead", value)
+	}
+
+	return sample, nil
+}
+
+// debugLog limits the rate at which the exchanges sampled by the debug option are
+// logged. It is shared by all the VUs, and safe for concurrent use.
+type debugLog struct {
+	limiter *rate.Limiter
+
+	// suppressed holds the number of sampled exchanges which were not logged
+	// since the last one which was.
+	suppressed atomic.Int64
+}
+
+// newDebugLog creates a debugLog logging at most limit exchanges per second.
+func newDebugLog(limit float64) *debugLog {
+	return &debugLog{limiter: rate.NewLimiter(rate.Limit(limit), int(math.Max(1, limit)))}
+}
+
+// allow reports whether a sampled exchange can be logged within the rate limit,
+// along with the number of sampled exchanges which were suppressed since the last
+// one which was logged.
+func (l *debugLog) allow() (int64, bool) {
+	if !l.limiter.Allow() {
+		l.suppressed.Add(1)
+		return 0, false
+	}
+
+	return l.suppressed.Swap(0), true
+}
+
+// logExchange logs the request sent to the nameserver and the response to it, in
+// dig format, if the exchange is sampled by the debug option of the query, or by
+// the configured one, and the rate limit allows it.
+//
+// It does not interact with the runtime, and thus can be called from any goroutine.
+func (mi *ModuleInstance) logExchange(
+	opts QueryOptions,
+	nameserver Nameserver,
+	response *Response,
+	duration time.Duration,
+	exchangeErr error,
+) {
+	sample := opts.withDefaults(mi.queryDefaults()).Debug
+	if sample == nil || *sample <= 0 || rand.Float64() >= *sample { //nolint:gosec // sampling needs no secure randomness
+		return
+	}
+
+	state := mi.vu.State()
+	if state == nil {
+		return
+	}
+
+	suppressed, ok := mi.debugLog.allow()
+	if !ok {
+		return
+	}
+
+	var b strings.Builder
+
+	if response != nil && response.RawRequest != nil {
+		request := new(dns.Msg)
+		if err := request.Unpack(response.RawRequest); err == nil {
+			b.WriteString(";; Sent query:\n")
+			b.WriteString(request.String())
+			b.WriteString("\n")
+		}
+	}
+
+	if response != nil && response.msg != nil {
+		result := newMessageResult(response.msg, duration)
+		result.Size = response.Size
+		result.Server = newServerInfo(response)
+
+		formatted, _ := result.Format(messageFormatDig)
+		b.WriteString(formatted)
+	}
+
+	entry := state.Logger.WithField("nameserver", nameserver.Addr()).
+		WithField("rtt", float64(duration)/float64(time.Millisecond))
+
+	if suppressed > 0 {
+		entry = entry.WithField("suppressed", suppressed)
+	}
+
+	if exchangeErr != nil {
+		entry = entry.WithError(exchangeErr)
+	}
+
+	entry.Info("DNS exchange\n" + b.String())
+}
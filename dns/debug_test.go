@@ -0,0 +1,155 @@
+package dns
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+)
+
+func Test_parseDebugSample(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    float64
+		wantErr bool
+	}{
+		{name: "enabled", value: true, want: 1},
+		{name: "disabled", value: false, want: 0},
+		{name: "integer", value: int64(1), want: 1},
+		{name: "fraction", value: 0.1, want: 0.1},
+		{name: "above one", value: 1.5, wantErr: true},
+		{name: "negative", value: -0.1, wantErr: true},
+		{name: "string", value: "always", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseDebugSample(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_debugLog_allow(t *testing.T) {
+	t.Parallel()
+
+	log := newDebugLog(2)
+
+	for i := 0; i < 2; i++ {
+		suppressed, ok := log.allow()
+		assert.True(t, ok)
+		assert.Zero(t, suppressed)
+	}
+
+	for i := 0; i < 3; i++ {
+		_, ok := log.allow()
+		assert.False(t, ok)
+	}
+
+	assert.Equal(t, int64(3), log.suppressed.Load())
+}
+
+func TestModuleInstance_logExchange(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		script  string
+		wantLog int
+	}{
+		{
+			name:    "The debug option should log the exchange",
+			script:  `await dns.resolve("k6.test", "A", server.address, { debug: true });`,
+			wantLog: 1,
+		},
+		{
+			name:    "Exchanges should not be logged by default",
+			script:  `await dns.resolve("k6.test", "A", server.address);`,
+			wantLog: 0,
+		},
+		{
+			name:    "A zero sample should not log any exchange",
+			script:  `await dns.resolve("k6.test", "A", server.address, { debug: 0 });`,
+			wantLog: 0,
+		},
+		{
+			name:    "The configured sample should apply to calls not setting one",
+			env:     map[string]string{envDebug: "1"},
+			script:  `await dns.sendMessage(dns.newMessage().addQuestion("k6.test", "A"), server.address);`,
+			wantLog: 1,
+		},
+		{
+			name:    "The debug option of the call should override the configured sample",
+			env:     map[string]string{envDebug: "true"},
+			script:  `await dns.resolve("k6.test", "A", server.address, { debug: false });`,
+			wantLog: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			runtime, err := newConfiguredRuntime(t)
+			require.NoError(t, err)
+
+			_, err = runtime.VU.Runtime().RunString(`
+				const server = dns.startServer(["k6.test. 60 IN A 203.0.113.1"]);
+			`)
+			require.NoError(t, err)
+
+			env := tt.env
+			if env == nil {
+				env = map[string]string{}
+			}
+			require.NoError(t, runtime.VU.Runtime().Set("__ENV", env))
+
+			logger, hook := logtest.NewNullLogger()
+
+			runtime.MoveToVUContext(&lib.State{
+				BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+				Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+				Samples:        make(chan metrics.SampleContainer, 1024),
+				Logger:         logger,
+			})
+
+			_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(tt.script))
+			require.NoError(t, err)
+
+			entries := hook.AllEntries()
+			require.Len(t, entries, tt.wantLog)
+
+			if tt.wantLog == 0 {
+				return
+			}
+
+			entry := entries[0]
+			assert.Equal(t, logrus.InfoLevel, entry.Level)
+			assert.Contains(t, entry.Message, ";; Sent query:")
+			assert.Contains(t, entry.Message, ";; ->>HEADER<<- opcode: QUERY, status: NOERROR")
+			assert.True(t, strings.Contains(entry.Message, "203.0.113.1"), entry.Message)
+			assert.NotEmpty(t, entry.Data["nameserver"])
+		})
+	}
+}
@@ -0,0 +1,495 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grafana/sobek"
+	"github.com/miekg/dns"
+	"go.k6.io/k6/js/promises"
+)
+
+// Delegation describes the delegation of a zone by its parent zone, along with
+// what the nameservers it is delegated to serve.
+type Delegation struct {
+	// Zone holds the zone which is delegated.
+	Zone string
+
+	// Parent holds the parent zone which delegates the zone, e.g. "io." for
+	// "k6.io".
+	Parent string
+
+	// ParentNameserver holds the nameserver of the parent zone which referred to
+	// the zone.
+	ParentNameserver Nameserver
+
+	// ParentNS holds the names of the nameservers the parent zone delegates the
+	// zone to.
+	ParentNS []string
+
+	// Glue holds the addresses found in the glue records of the referral, by name
+	// of the nameserver they belong to.
+	Glue map[string][]Nameserver
+
+	// Nameservers holds the outcome of querying each of the nameservers the zone
+	// is delegated to for its NS records.
+	Nameservers []DelegatedNameserver
+}
+
+// DelegatedNameserver describes how a nameserver a zone is delegated to answered
+// the query for the NS records of the zone.
+type DelegatedNameserver struct {
+	// Name holds the name of the nameserver, as found in the referral.
+	Name string
+
+	// Nameserver holds the address the nameserver was queried on, or is the zero
+	// value if none could be found.
+	Nameserver Nameserver
+
+	// Response holds the response to the query, if the nameserver was queried.
+	Response *Response
+
+	// Duration holds the duration of the query.
+	Duration time.Duration
+
+	// Err holds the error finding the address of the nameserver, or the query, failed
+	// with, if any.
+	Err error
+}
+
+// CheckDelegation traces the NS records of the zone to find the referral its
+// parent zone delegates it with, and queries each of the nameservers it refers to,
+// on the same port as the referring nameserver, for the NS records of the zone.
+// It returns the delegation along with the queries which were sent, the tracing
+// ones first.
+//
+// The nameservers the zone is delegated to are queried on the addresses found
+// in the glue records of the referral's additional section, so that each of them
+// is checked to respond, or on those the system's default resolver looks them up
+// to when the referral holds no glue for them.
+func (r *Client) CheckDelegation(
+	ctx context.Context,
+	zone string,
+	opts TraceOptions,
+) (*Delegation, []TraceStep, error) {
+	// The trace fails when none of the nameservers the zone is delegated to answer,
+	// which is only known once each of them is queried
+	_, steps, traceErr := r.Trace(ctx, zone, RecordTypeNS.String(), opts)
+
+	fqdn := dns.Fqdn(zone)
+
+	var referral *TraceStep
+	for i := range steps {
+		if strings.EqualFold(steps[i].Referral, fqdn) {
+			referral = &steps[i]
+		}
+	}
+
+	if referral == nil {
+		if traceErr != nil {
+			return nil, steps, fmt.Errorf("tracing the delegation of %s failed: %w", zone, traceErr)
+		}
+
+		return nil, steps, fmt.Errorf("no referral to %s was found, it may not be delegated", zone)
+	}
+
+	_, targets := findReferral(referral.Response.msg, referral.Zone, dns.Fqdn(referral.Query))
+
+	delegation := &Delegation{
+		Zone:             zone,
+		Parent:           referral.Zone,
+		ParentNameserver: referral.Nameserver,
+		Glue:             make(map[string][]Nameserver),
+	}
+
+	queryOpts := opts.QueryOptions
+	queryOpts.NoRecursion = true
+	queryOpts.FollowCNAME = false
+
+	for _, target := range targets {
+		name := strings.ToLower(strings.TrimSuffix(target, "."))
+		delegation.ParentNS = append(delegation.ParentNS, name)
+
+		servers := glueAddresses(referral.Response.msg, target, referral.Nameserver.Port)
+		if len(servers) > 0 {
+			delegation.Glue[name] = servers
+		} else {
+			var err error
+			if servers, err = r.lookupNameserver(ctx, target, referral.Nameserver.Port); err != nil {
+				delegation.Nameservers = append(delegation.Nameservers, DelegatedNameserver{Name: name, Err: err})
+				continue
+			}
+		}
+
+		for _, server := range servers {
+			start := time.Now()
+			response, err := r.query(ctx, strings.TrimSuffix(fqdn, "."), RecordTypeNS.String(), server, queryOpts)
+			duration := time.Since(start)
+
+			steps = append(steps, TraceStep{
+				Zone:       fqdn,
+				Nameserver: server,
+				Query:      strings.TrimSuffix(fqdn, "."),
+				Type:       RecordTypeNS.String(),
+				Duration:   duration,
+				Response:   response,
+				Err:        err,
+			})
+
+			delegation.Nameservers = append(delegation.Nameservers, DelegatedNameserver{
+				Name:       name,
+				Nameserver: server,
+				Response:   response,
+				Duration:   duration,
+				Err:        err,
+			})
+		}
+	}
+
+	return delegation, steps, nil
+}
+
+// glueAddresses returns the addresses of the target nameserver, on the given port,
+// as found in the glue records of the referral, IPv4 addresses first.
+func glueAddresses(referral *dns.Msg, target string, port uint16) []Nameserver {
+	var v4, v6 []Nameserver
+	for _, rr := range referral.Extra {
+		if !strings.EqualFold(rr.Header().Name, dns.Fqdn(target)) {
+			continue
+		}
+
+		switch t := rr.(type) {
+		case *dns.A:
+			v4 = append(v4, Nameserver{IP: t.A, Port: port})
+		case *dns.AAAA:
+			v6 = append(v6, Nameserver{IP: t.AAAA, Port: port})
+		}
+	}
+
+	return append(v4, v6...)
+}
+
+// lookupNameserver returns the addresses of the target nameserver, on the given
+// port, as looked up using the system's default resolver.
+func (r *Client) lookupNameserver(ctx context.Context, target string, port uint16) ([]Nameserver, error) {
+	ips, err := r.Lookup(ctx, strings.TrimSuffix(target, "."), LookupOptions{Order: AddressOrderIPv4First})
+	if err != nil {
+		return nil, fmt.Errorf("no address found for the nameserver: %w", err)
+	}
+
+	servers := make([]Nameserver, 0, len(ips))
+	for _, ip := range ips {
+		servers = append(servers, Nameserver{IP: net.ParseIP(ip), Port: port})
+	}
+
+	return servers, nil
+}
+
+// checkDelegationResult is the object the checkDelegation function resolves to.
+type checkDelegationResult struct {
+	// Zone holds the zone whose delegation was checked.
+	Zone string `js:"zone"`
+
+	// Parent holds the parent zone which delegates the zone.
+	Parent string `js:"parent"`
+
+	// ParentNameserver holds the address of the nameserver of the parent zone which
+	// referred to the zone.
+	ParentNameserver string `js:"parentNameserver"`
+
+	// Consistent indicates whether the NS records of the parent and child zones
+	// match, and none of the nameservers the zone is delegated to is lame.
+	Consistent bool `js:"consistent"`
+
+	// ParentNS holds the names of the nameservers the parent zone delegates the
+	// zone to, sorted.
+	ParentNS []string `js:"parentNs"`
+
+	// ChildNS holds the names of the nameservers found in the NS records served
+	// by the nameservers the zone is delegated to, sorted.
+	ChildNS []string `js:"childNs"`
+
+	// MissingFromChild holds the names of the nameservers the parent zone delegates
+	// the zone to, but which the child zone does not list.
+	MissingFromChild []string `js:"missingFromChild"`
+
+	// MissingFromParent holds the names of the nameservers the child zone lists,
+	// but which the parent zone does not delegate the zone to.
+	MissingFromParent []string `js:"missingFromParent"`
+
+	// Lame holds the addresses, or names when none could be found, of the
+	// nameservers the zone is delegated to which do not serve it.
+	Lame []string `js:"lame"`
+
+	// Nameservers holds how each of the nameservers the zone is delegated to
+	// answered.
+	Nameservers []delegatedNameserver `js:"nameservers"`
+
+	// Glue holds the validation of the glue records of each of the nameservers the
+	// parent zone delegates the zone to, in the order of ParentNS.
+	Glue []glueCheck `js:"glue"`
+}
+
+// glueCheck describes the glue records a referral holds for a nameserver.
+type glueCheck struct {
+	// Name holds the name of the nameserver.
+	Name string `js:"name"`
+
+	// InBailiwick indicates whether the name of the nameserver is within the
+	// delegated zone, in which case it can only be resolved through glue records.
+	InBailiwick bool `js:"inBailiwick"`
+
+	// Addresses holds the addresses found in the glue records of the nameserver.
+	Addresses []string `js:"addresses"`
+
+	// Missing indicates whether the nameserver is in-bailiwick, but the referral
+	// holds no glue record for it.
+	Missing bool `js:"missing"`
+
+	// Unresponsive holds the glue addresses on which the nameserver did not respond.
+	Unresponsive []string `js:"unresponsive"`
+
+	// Valid indicates whether the glue records are not missing, and the nameserver
+	// responded on all their addresses.
+	Valid bool `js:"valid"`
+}
+
+// delegatedNameserver describes how a nameserver a zone is delegated to answered.
+type delegatedNameserver struct {
+	// Name holds the name of the nameserver.
+	Name string `js:"name"`
+
+	// Address holds the address the nameserver was queried on, or is empty if
+	// none could be found.
+	Address string `js:"address"`
+
+	// Rcode holds the name of the response code returned by the nameserver, if any.
+	Rcode string `js:"rcode"`
+
+	// Authoritative indicates whether the nameserver answered authoritatively.
+	Authoritative bool `js:"authoritative"`
+
+	// NS holds the names of the nameservers found in the NS records the nameserver
+	// served, sorted.
+	NS []string `js:"ns"`
+
+	// Lame indicates whether the nameserver does not serve the zone.
+	Lame bool `js:"lame"`
+
+	// Reason holds why the nameserver is lame, if it is.
+	Reason string `js:"reason"`
+
+	// Error holds the error querying the nameserver failed with, if any.
+	Error *Error `js:"error"`
+
+	// RTT holds the duration of the query, in milliseconds.
+	RTT float64 `js:"rtt"`
+}
+
+// newCheckDelegationResult creates a checkDelegationResult out of a delegation,
+// comparing the NS records of the parent and child zones.
+func newCheckDelegationResult(delegation *Delegation) *checkDelegationResult {
+	result := &checkDelegationResult{
+		Zone:              delegation.Zone,
+		Parent:            delegation.Parent,
+		ParentNameserver:  delegation.ParentNameserver.Addr(),
+		ParentNS:          sortedNames(delegation.ParentNS),
+		ChildNS:           []string{},
+		MissingFromChild:  []string{},
+		MissingFromParent: []string{},
+		Lame:              []string{},
+		Nameservers:       make([]delegatedNameserver, 0, len(delegation.Nameservers)),
+		Glue:              make([]glueCheck, 0, len(delegation.ParentNS)),
+	}
+
+	apex := dns.CanonicalName(delegation.Zone)
+	childNS := map[string]bool{}
+	agreeing := true
+
+	var served []string
+	for _, ns := range delegation.Nameservers {
+		outcome := delegatedNameserver{
+			Name:  ns.Name,
+			Error: asError(ns.Err),
+			RTT:   float64(ns.Duration) / float64(time.Millisecond),
+		}
+		if ns.Nameserver.IP != nil {
+			outcome.Address = ns.Nameserver.Addr()
+		}
+
+		var names []string
+		if ns.Response != nil && ns.Response.msg != nil {
+			msg := ns.Response.msg
+			outcome.Rcode = ns.Response.Rcode
+			outcome.Authoritative = msg.Authoritative
+
+			for _, rr := range msg.Answer {
+				if record, ok := rr.(*dns.NS); ok && dns.CanonicalName(record.Hdr.Name) == apex {
+					names = append(names, strings.ToLower(strings.TrimSuffix(record.Ns, ".")))
+				}
+			}
+		}
+
+		outcome.NS = sortedNames(names)
+
+		switch {
+		case ns.Response == nil || ns.Response.msg == nil:
+			outcome.Reason = "no response"
+		case ns.Response.msg.Rcode != dns.RcodeSuccess:
+			outcome.Reason = "answered with " + ns.Response.Rcode
+		case !ns.Response.msg.Authoritative:
+			outcome.Reason = "not authoritative"
+		case len(names) == 0:
+			outcome.Reason = "no NS records"
+		}
+
+		if outcome.Reason != "" {
+			outcome.Lame = true
+
+			lame := outcome.Address
+			if lame == "" {
+				lame = outcome.Name
+			}
+			result.Lame = append(result.Lame, lame)
+		} else {
+			for _, name := range outcome.NS {
+				childNS[name] = true
+			}
+
+			// The nameservers serving the zone should all serve the same NS records
+			if served != nil && !slices.Equal(served, outcome.NS) {
+				agreeing = false
+			}
+			served = outcome.NS
+		}
+
+		result.Nameservers = append(result.Nameservers, outcome)
+	}
+
+	for name := range childNS {
+		result.ChildNS = append(result.ChildNS, name)
+	}
+	sort.Strings(result.ChildNS)
+
+	parentNS := make(map[string]bool, len(result.ParentNS))
+	for _, name := range result.ParentNS {
+		parentNS[name] = true
+		if !childNS[name] {
+			result.MissingFromChild = append(result.MissingFromChild, name)
+		}
+	}
+
+	for _, name := range result.ChildNS {
+		if !parentNS[name] {
+			result.MissingFromParent = append(result.MissingFromParent, name)
+		}
+	}
+
+	validGlue := true
+	for _, name := range result.ParentNS {
+		check := newGlueCheck(delegation, name)
+		validGlue = validGlue && check.Valid
+
+		result.Glue = append(result.Glue, check)
+	}
+
+	result.Consistent = agreeing && validGlue && len(result.ChildNS) > 0 && len(result.MissingFromChild) == 0 &&
+		len(result.MissingFromParent) == 0 && len(result.Lame) == 0
+
+	return result
+}
+
+// newGlueCheck validates the glue records of the named nameserver the delegation's
+// referral holds, against the responses of the nameserver on their addresses.
+func newGlueCheck(delegation *Delegation, name string) glueCheck {
+	check := glueCheck{
+		Name:         name,
+		InBailiwick:  dns.IsSubDomain(dns.Fqdn(delegation.Zone), dns.Fqdn(name)),
+		Addresses:    []string{},
+		Unresponsive: []string{},
+	}
+
+	responded := make(map[string]bool)
+	for _, ns := range delegation.Nameservers {
+		if ns.Name == name && ns.Response != nil && ns.Response.msg != nil {
+			responded[ns.Nameserver.Addr()] = true
+		}
+	}
+
+	for _, server := range delegation.Glue[name] {
+		check.Addresses = append(check.Addresses, server.Addr())
+		if !responded[server.Addr()] {
+			check.Unresponsive = append(check.Unresponsive, server.Addr())
+		}
+	}
+
+	check.Missing = check.InBailiwick && len(check.Addresses) == 0
+	check.Valid = !check.Missing && len(check.Unresponsive) == 0
+
+	return check
+}
+
+// sortedNames returns the unique names, sorted.
+func sortedNames(names []string) []string {
+	sorted := []string{}
+	for _, name := range names {
+		sorted = appendUnique(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	return sorted
+}
+
+// CheckDelegation compares the NS records the parent zone of a zone delegates it
+// with, as found by tracing it, with those served by the nameservers it is
+// delegated to, and resolves to the mismatches and lame delegations found.
+func (mi *ModuleInstance) CheckDelegation(zone, options sobek.Value) *sobek.Promise {
+	promise, resolve, reject := promises.New(mi.vu)
+
+	if mi.vu.State() == nil {
+		reject(errors.New("checkDelegation can not be used in the init context"))
+		return promise
+	}
+
+	zoneStr, err := exportDomainName(mi.vu.Runtime(), zone, "zone")
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	opts, err := parseTraceOptions(mi.vu.Runtime(), options)
+	if err != nil {
+		reject(fmt.Errorf("invalid checkDelegation options: %w", err))
+		return promise
+	}
+
+	ctx, cancel, err := mi.withAbortSignal(opts.Signal)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer cancel()
+
+		delegation, steps, err := mi.dnsClient.CheckDelegation(ctx, zoneStr, opts.TraceOptions)
+
+		// Emit the metrics of each query which was sent, regardless of the result
+		mi.emitTraceMetrics(steps)
+
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(newCheckDelegationResult(delegation))
+	}()
+
+	return promise
+}
@@ -0,0 +1,314 @@
+package dns
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+)
+
+func Test_newCheckDelegationResult(t *testing.T) {
+	t.Parallel()
+
+	// answer returns a response holding the NS records of k6.test
+	answer := func(rcode int, authoritative bool, names ...string) *Response {
+		t.Helper()
+
+		msg := &dns.Msg{MsgHdr: dns.MsgHdr{Rcode: rcode, Authoritative: authoritative}}
+		for _, name := range names {
+			rr, err := dns.NewRR("k6.test. 60 IN NS " + name)
+			require.NoError(t, err)
+
+			msg.Answer = append(msg.Answer, rr)
+		}
+
+		return &Response{msg: msg, Rcode: dns.RcodeToString[rcode]}
+	}
+
+	nameserver := func(addr string) Nameserver {
+		t.Helper()
+
+		ns, err := parseNameserverAddr(addr)
+		require.NoError(t, err)
+
+		return ns
+	}
+
+	// glue holds the glue records of the nameservers, unless a test specifies its own
+	glue := map[string][]Nameserver{
+		"ns1.k6.test": {nameserver("192.0.2.1")},
+		"ns2.k6.test": {nameserver("192.0.2.2")},
+	}
+
+	tests := []struct {
+		name              string
+		nameservers       []DelegatedNameserver
+		glue              map[string][]Nameserver
+		wantConsistent    bool
+		wantChildNS       []string
+		wantMissingChild  []string
+		wantMissingParent []string
+		wantLame          []string
+		wantGlue          []glueCheck
+	}{
+		{
+			name: "consistent delegation",
+			nameservers: []DelegatedNameserver{
+				{Name: "ns1.k6.test", Nameserver: nameserver("192.0.2.1"), Response: answer(dns.RcodeSuccess, true, "NS2.k6.test.", "ns1.k6.test.")},
+				{Name: "ns2.k6.test", Nameserver: nameserver("192.0.2.2"), Response: answer(dns.RcodeSuccess, true, "ns1.k6.test.", "ns2.k6.test.")},
+			},
+			wantConsistent:    true,
+			wantChildNS:       []string{"ns1.k6.test", "ns2.k6.test"},
+			wantMissingChild:  []string{},
+			wantMissingParent: []string{},
+			wantLame:          []string{},
+		},
+		{
+			name: "mismatched NS records",
+			nameservers: []DelegatedNameserver{
+				{Name: "ns1.k6.test", Nameserver: nameserver("192.0.2.1"), Response: answer(dns.RcodeSuccess, true, "ns1.k6.test.", "ns3.k6.test.")},
+				{Name: "ns2.k6.test", Nameserver: nameserver("192.0.2.2"), Response: answer(dns.RcodeSuccess, true, "ns1.k6.test.", "ns3.k6.test.")},
+			},
+			wantChildNS:       []string{"ns1.k6.test", "ns3.k6.test"},
+			wantMissingChild:  []string{"ns2.k6.test"},
+			wantMissingParent: []string{"ns3.k6.test"},
+			wantLame:          []string{},
+		},
+		{
+			name: "nameservers serving different NS records",
+			nameservers: []DelegatedNameserver{
+				{Name: "ns1.k6.test", Nameserver: nameserver("192.0.2.1"), Response: answer(dns.RcodeSuccess, true, "ns1.k6.test.", "ns2.k6.test.")},
+				{Name: "ns2.k6.test", Nameserver: nameserver("192.0.2.2"), Response: answer(dns.RcodeSuccess, true, "ns1.k6.test.")},
+			},
+			wantChildNS:       []string{"ns1.k6.test", "ns2.k6.test"},
+			wantMissingChild:  []string{},
+			wantMissingParent: []string{},
+			wantLame:          []string{},
+		},
+		{
+			name: "lame delegations",
+			nameservers: []DelegatedNameserver{
+				{Name: "ns1.k6.test", Nameserver: nameserver("192.0.2.1"), Response: answer(dns.RcodeSuccess, true, "ns1.k6.test.", "ns2.k6.test.")},
+				{Name: "ns2.k6.test", Nameserver: nameserver("192.0.2.2"), Response: answer(dns.RcodeRefused, false)},
+				{Name: "ns2.k6.test", Nameserver: nameserver("192.0.2.4"), Response: answer(dns.RcodeSuccess, false, "ns1.k6.test.")},
+				{Name: "ns2.k6.test", Nameserver: nameserver("192.0.2.3"), Err: errors.New("i/o timeout")},
+				{Name: "ns2.k6.test", Err: errors.New("no address found for the nameserver")},
+			},
+			wantChildNS:       []string{"ns1.k6.test", "ns2.k6.test"},
+			wantMissingChild:  []string{},
+			wantMissingParent: []string{},
+			wantLame:          []string{"192.0.2.2:53", "192.0.2.4:53", "192.0.2.3:53", "ns2.k6.test"},
+		},
+		{
+			name: "missing and unresponsive glue",
+			nameservers: []DelegatedNameserver{
+				{Name: "ns1.k6.test", Nameserver: nameserver("192.0.2.1"), Response: answer(dns.RcodeSuccess, true, "ns1.k6.test.", "ns2.k6.test.")},
+				{Name: "ns1.k6.test", Nameserver: nameserver("192.0.2.5"), Err: errors.New("i/o timeout")},
+				{Name: "ns2.k6.test", Nameserver: nameserver("192.0.2.2"), Response: answer(dns.RcodeSuccess, true, "ns1.k6.test.", "ns2.k6.test.")},
+			},
+			glue: map[string][]Nameserver{
+				"ns1.k6.test": {nameserver("192.0.2.1"), nameserver("192.0.2.5")},
+			},
+			wantChildNS:       []string{"ns1.k6.test", "ns2.k6.test"},
+			wantMissingChild:  []string{},
+			wantMissingParent: []string{},
+			wantLame:          []string{"192.0.2.5:53"},
+			wantGlue: []glueCheck{
+				{
+					Name:         "ns1.k6.test",
+					InBailiwick:  true,
+					Addresses:    []string{"192.0.2.1:53", "192.0.2.5:53"},
+					Unresponsive: []string{"192.0.2.5:53"},
+				},
+				{
+					Name:         "ns2.k6.test",
+					InBailiwick:  true,
+					Addresses:    []string{},
+					Missing:      true,
+					Unresponsive: []string{},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			delegation := &Delegation{
+				Zone:             "k6.test",
+				Parent:           "test.",
+				ParentNameserver: nameserver("192.0.2.53"),
+				ParentNS:         []string{"ns2.k6.test", "ns1.k6.test"},
+				Nameservers:      tt.nameservers,
+				Glue:             glue,
+			}
+			if tt.glue != nil {
+				delegation.Glue = tt.glue
+			}
+
+			result := newCheckDelegationResult(delegation)
+
+			assert.Equal(t, tt.wantConsistent, result.Consistent)
+			assert.Equal(t, []string{"ns1.k6.test", "ns2.k6.test"}, result.ParentNS)
+			assert.Equal(t, tt.wantChildNS, result.ChildNS)
+			assert.Equal(t, tt.wantMissingChild, result.MissingFromChild)
+			assert.Equal(t, tt.wantMissingParent, result.MissingFromParent)
+			assert.Equal(t, tt.wantLame, result.Lame)
+			if tt.wantGlue != nil {
+				assert.Equal(t, tt.wantGlue, result.Glue)
+			}
+		})
+	}
+}
+
+func TestModuleInstance_CheckDelegation(t *testing.T) {
+	t.Parallel()
+
+	// The parent nameserver delegates k6.test to three nameservers, which are
+	// queried on its port: the first serves the zone with different NS records,
+	// the second refuses to, and nothing listens on the address of the third
+	parentConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	port := parentConn.LocalAddr().(*net.UDPAddr).Port
+
+	serve := func(conn net.PacketConn, handler dns.HandlerFunc) {
+		server := &dns.Server{PacketConn: conn, Handler: handler}
+		go func() { _ = server.ActivateAndServe() }()
+		t.Cleanup(func() { _ = server.Shutdown() })
+	}
+
+	rr := func(record string) dns.RR {
+		parsed, err := dns.NewRR(record)
+		require.NoError(t, err)
+
+		return parsed
+	}
+
+	serve(parentConn, func(w dns.ResponseWriter, req *dns.Msg) {
+		response := new(dns.Msg)
+		response.SetReply(req)
+
+		if dns.IsSubDomain("k6.test.", req.Question[0].Name) {
+			response.Ns = []dns.RR{
+				rr("k6.test. 60 IN NS ns1.k6.test."),
+				rr("k6.test. 60 IN NS ns2.k6.test."),
+				rr("k6.test. 60 IN NS ns3.k6.test."),
+			}
+			response.Extra = []dns.RR{
+				rr("ns1.k6.test. 60 IN A 127.0.0.2"),
+				rr("ns2.k6.test. 60 IN A 127.0.0.3"),
+				rr("ns3.k6.test. 60 IN A 127.0.0.4"),
+			}
+		} else {
+			response.Rcode = dns.RcodeNameError
+		}
+
+		_ = w.WriteMsg(response)
+	})
+
+	childConn, err := net.ListenPacket("udp", net.JoinHostPort("127.0.0.2", strconv.Itoa(port)))
+	require.NoError(t, err)
+
+	serve(childConn, func(w dns.ResponseWriter, req *dns.Msg) {
+		response := new(dns.Msg)
+		response.SetReply(req)
+		response.Authoritative = true
+		response.Answer = []dns.RR{
+			rr("k6.test. 60 IN NS ns1.k6.test."),
+			rr("k6.test. 60 IN NS ns2.k6.test."),
+			rr("k6.test. 60 IN NS ns4.k6.test."),
+		}
+
+		_ = w.WriteMsg(response)
+	})
+
+	lameConn, err := net.ListenPacket("udp", net.JoinHostPort("127.0.0.3", strconv.Itoa(port)))
+	require.NoError(t, err)
+
+	serve(lameConn, func(w dns.ResponseWriter, req *dns.Msg) {
+		response := new(dns.Msg)
+		response.SetRcode(req, dns.RcodeRefused)
+
+		_ = w.WriteMsg(response)
+	})
+
+	tests := []struct {
+		name    string
+		script  string
+		wantErr string
+	}{
+		{
+			name: "Mismatched NS records and lame delegations should be reported",
+			script: `
+				const result = await dns.checkDelegation("k6.test", { roots: [root], timeout: "100ms", retries: 0 });
+				if (result.consistent || result.parent !== "." || result.parentNameserver !== root) {
+					throw new Error("unexpected result: " + JSON.stringify(result));
+				}
+
+				if (result.parentNs.join() !== "ns1.k6.test,ns2.k6.test,ns3.k6.test" ||
+					result.childNs.join() !== "ns1.k6.test,ns2.k6.test,ns4.k6.test" ||
+					result.missingFromChild.join() !== "ns3.k6.test" ||
+					result.missingFromParent.join() !== "ns4.k6.test") {
+					throw new Error("unexpected NS records: " + JSON.stringify(result));
+				}
+
+				const port = root.split(":")[1];
+				if (result.lame.join() !== "127.0.0.3:" + port + ",127.0.0.4:" + port) {
+					throw new Error("unexpected lame delegations: " + JSON.stringify(result.lame));
+				}
+
+				const glue = result.glue.map(g => g.name + ":" + g.inBailiwick + ":" + g.valid + ":" + g.unresponsive.length);
+				if (glue.join() !== "ns1.k6.test:true:true:0,ns2.k6.test:true:true:0,ns3.k6.test:true:false:1") {
+					throw new Error("unexpected glue: " + JSON.stringify(result.glue));
+				}
+
+				const [served, refused, unreachable] = result.nameservers;
+				if (served.lame || !served.authoritative || refused.reason !== "answered with REFUSED" ||
+					unreachable.reason !== "no response" || unreachable.error === null) {
+					throw new Error("unexpected nameservers: " + JSON.stringify(result.nameservers));
+				}
+			`,
+		},
+		{
+			name:    "Zones which are not delegated should fail",
+			script:  `await dns.checkDelegation("www.k6.test", { roots: [root], maxQueries: 2 });`,
+			wantErr: "no referral to www.k6.test was found",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			runtime, err := newConfiguredRuntime(t)
+			require.NoError(t, err)
+			require.NoError(t, runtime.VU.Runtime().Set("root", parentConn.LocalAddr().String()))
+
+			runtime.MoveToVUContext(&lib.State{
+				BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+				Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+				Samples:        make(chan metrics.SampleContainer, 1024),
+			})
+
+			_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(tt.script))
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,314 @@
+package dns
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/grafana/sobek"
+	"go.k6.io/k6/js/common"
+)
+
+// Sections of a DNS message records are diffed in.
+const (
+	sectionAnswer     = "answer"
+	sectionAuthority  = "authority"
+	sectionAdditional = "additional"
+)
+
+// sectionOrder holds the order of the sections within a DNS message.
+var sectionOrder = map[string]int{ //nolint:gochecknoglobals
+	sectionAnswer:     0,
+	sectionAuthority:  1,
+	sectionAdditional: 2,
+}
+
+// diffedResponse holds the parts of a response which are diffed.
+type diffedResponse struct {
+	// Rcode holds the name of the response code of the response, if known.
+	Rcode string
+
+	// Sections holds the records of the response, by section.
+	Sections map[string][]Record
+}
+
+// diffOptions holds the options of the diff function.
+type diffOptions struct {
+	// Sections holds the sections whose records are diffed.
+	Sections []string
+
+	// CompareTTL indicates whether records whose TTLs differ should be reported
+	// as changed.
+	CompareTTL bool
+
+	// TTLTolerance holds the number of seconds TTLs may differ by without the
+	// records being reported as changed, as TTLs decrease while records are cached.
+	TTLTolerance uint32
+}
+
+// recordDiff describes a record which differs between two responses.
+type recordDiff struct {
+	// Section holds the section of the responses the record is found in.
+	Section string `js:"section"`
+
+	// Name, Type and Data hold the owner name, type and data of the record.
+	Name string `js:"name"`
+	Type string `js:"type"`
+	Data string `js:"data"`
+
+	// TTL holds the TTL of the record in the response it is found in, or in the
+	// second response for changed records.
+	TTL uint32 `js:"ttl"`
+
+	// PreviousTTL holds the TTL of the record in the first response, for changed
+	// records.
+	PreviousTTL uint32 `js:"previousTTL"`
+}
+
+// diffResult is the object the diff function returns.
+type diffResult struct {
+	// Equal indicates whether the responses hold the same records and response
+	// code.
+	Equal bool `js:"equal"`
+
+	// Added holds the records found in the second response only.
+	Added []recordDiff `js:"added"`
+
+	// Removed holds the records found in the first response only.
+	Removed []recordDiff `js:"removed"`
+
+	// Changed holds the records found in both responses, but whose TTLs differ by
+	// more than the tolerated number of seconds, if TTLs are compared.
+	Changed []recordDiff `js:"changed"`
+
+	// Rcode holds the response codes of both responses if they differ, or nil
+	// otherwise.
+	Rcode interface{} `js:"rcode"`
+}
+
+// Diff reports the records added, removed or changed between two responses, be
+// they results of resolve or sendMessage, or their serialization.
+//
+// Records are compared regardless of their order, and of the case and trailing
+// dot of their names and data.
+func (mi *ModuleInstance) Diff(a, b, options sobek.Value) (*diffResult, error) {
+	rt := mi.vu.Runtime()
+
+	first, err := exportDiffedResponse(rt, a)
+	if err != nil {
+		return nil, fmt.Errorf("invalid first response: %w", err)
+	}
+
+	second, err := exportDiffedResponse(rt, b)
+	if err != nil {
+		return nil, fmt.Errorf("invalid second response: %w", err)
+	}
+
+	opts, err := parseDiffOptions(rt, options)
+	if err != nil {
+		return nil, fmt.Errorf("invalid diff options: %w", err)
+	}
+
+	return diffResponses(first, second, opts), nil
+}
+
+// parseDiffOptions parses the options object passed to the diff function.
+func parseDiffOptions(rt *sobek.Runtime, value sobek.Value) (diffOptions, error) {
+	opts := diffOptions{
+		Sections: []string{sectionAnswer, sectionAuthority, sectionAdditional},
+	}
+
+	if common.IsNullish(value) {
+		return opts, nil
+	}
+
+	params := value.ToObject(rt)
+
+	if v := params.Get("sections"); !common.IsNullish(v) {
+		if err := rt.ExportTo(v, &opts.Sections); err != nil || len(opts.Sections) == 0 {
+			return opts, fmt.Errorf("sections option must be a non-empty array of sections; got %v instead", v)
+		}
+
+		for _, section := range opts.Sections {
+			switch section {
+			case sectionAnswer, sectionAuthority, sectionAdditional:
+			default:
+				return opts, fmt.Errorf("sections option holds unknown section %q; must be one of answer, authority or additional", section)
+			}
+		}
+	}
+
+	if v := params.Get("compareTTL"); !common.IsNullish(v) {
+		opts.CompareTTL = v.ToBoolean()
+	}
+
+	if v := params.Get("ttlTolerance"); !common.IsNullish(v) {
+		var tolerance int64
+		if err := rt.ExportTo(v, &tolerance); err != nil || tolerance < 0 {
+			return opts, fmt.Errorf("ttlTolerance option must be a positive number of seconds; got %v instead", v)
+		}
+
+		opts.TTLTolerance = uint32(tolerance) //nolint:gosec
+	}
+
+	return opts, nil
+}
+
+// exportDiffedResponse exports the parts of a response which are diffed out of
+// the result of resolve or sendMessage, or of an object holding their records
+// in answer(s), authority and additional properties, such as the serialization
+// of a response.
+func exportDiffedResponse(rt *sobek.Runtime, value sobek.Value) (diffedResponse, error) {
+	if common.IsNullish(value) {
+		return diffedResponse{}, fmt.Errorf("a response must be provided; got %v instead", value)
+	}
+
+	switch response := value.Export().(type) {
+	case *messageResult:
+		return diffedResponse{
+			Rcode: response.Rcode,
+			Sections: map[string][]Record{
+				sectionAnswer:     response.Answers,
+				sectionAuthority:  response.Authority,
+				sectionAdditional: response.Additional,
+			},
+		}, nil
+	case *resolveResult:
+		return diffedResponse{
+			Rcode:    response.Rcode,
+			Sections: map[string][]Record{sectionAnswer: response.Records},
+		}, nil
+	}
+
+	obj := value.ToObject(rt)
+	response := diffedResponse{Sections: make(map[string][]Record, 3)}
+
+	if v := obj.Get("rcode"); !common.IsNullish(v) {
+		response.Rcode = v.String()
+	}
+
+	// Results of resolve hold the records of the answer section in their records
+	// property, and those of sendMessage in their answers property
+	properties := map[string][]string{
+		sectionAnswer:     {"answer", "records", "answers"},
+		sectionAuthority:  {"authority"},
+		sectionAdditional: {"additional"},
+	}
+
+	for section, names := range properties {
+		for _, name := range names {
+			v := obj.Get(name)
+			if common.IsNullish(v) {
+				continue
+			}
+
+			var records []Record
+			if err := rt.ExportTo(v, &records); err != nil {
+				return response, fmt.Errorf("%s must be an array of records; got %v instead", name, v)
+			}
+
+			response.Sections[section] = records
+
+			break
+		}
+	}
+
+	return response, nil
+}
+
+// diffResponses reports the records added, removed or changed between the
+// responses, in the provided sections.
+func diffResponses(a, b diffedResponse, opts diffOptions) *diffResult {
+	result := &diffResult{
+		Added:   []recordDiff{},
+		Removed: []recordDiff{},
+		Changed: []recordDiff{},
+	}
+
+	for _, section := range opts.Sections {
+		before := indexRecords(a.Sections[section])
+		after := indexRecords(b.Sections[section])
+
+		for key, record := range after {
+			previous, ok := before[key]
+			if !ok {
+				result.Added = append(result.Added, newRecordDiff(section, record))
+				continue
+			}
+
+			if opts.CompareTTL && ttlDistance(previous.TTL, record.TTL) > opts.TTLTolerance {
+				changed := newRecordDiff(section, record)
+				changed.PreviousTTL = previous.TTL
+				result.Changed = append(result.Changed, changed)
+			}
+		}
+
+		for key, record := range before {
+			if _, ok := after[key]; !ok {
+				result.Removed = append(result.Removed, newRecordDiff(section, record))
+			}
+		}
+	}
+
+	for _, diffs := range [][]recordDiff{result.Added, result.Removed, result.Changed} {
+		sortRecordDiffs(diffs)
+	}
+
+	if a.Rcode != "" && b.Rcode != "" && a.Rcode != b.Rcode {
+		result.Rcode = []string{a.Rcode, b.Rcode}
+	}
+
+	result.Equal = len(result.Added) == 0 && len(result.Removed) == 0 && len(result.Changed) == 0 &&
+		result.Rcode == nil
+
+	return result
+}
+
+// indexRecords indexes the records by their normalized name, type and data, the
+// first of duplicate records winning.
+func indexRecords(records []Record) map[string]Record {
+	index := make(map[string]Record, len(records))
+	for _, record := range records {
+		key := normalizeAnswer(record.Name) + " " + strings.ToUpper(record.Type) + " " + normalizeAnswer(record.Data)
+		if _, ok := index[key]; !ok {
+			index[key] = record
+		}
+	}
+
+	return index
+}
+
+// newRecordDiff creates a recordDiff out of a record found in the section.
+func newRecordDiff(section string, record Record) recordDiff {
+	return recordDiff{
+		Section: section,
+		Name:    record.Name,
+		Type:    record.Type,
+		Data:    record.Data,
+		TTL:     record.TTL,
+	}
+}
+
+// sortRecordDiffs sorts the diffs by section, in message order, then by name, type and data, so that they
+// are reported in a stable order.
+func sortRecordDiffs(diffs []recordDiff) {
+	sort.Slice(diffs, func(i, j int) bool {
+		a, b := diffs[i], diffs[j]
+
+		if a.Section != b.Section {
+			return sectionOrder[a.Section] < sectionOrder[b.Section]
+		}
+
+		return strings.Join([]string{normalizeAnswer(a.Name), a.Type, normalizeAnswer(a.Data)}, " ") <
+			strings.Join([]string{normalizeAnswer(b.Name), b.Type, normalizeAnswer(b.Data)}, " ")
+	})
+}
+
+// ttlDistance returns the absolute difference between two TTLs.
+func ttlDistance(a, b uint32) uint32 {
+	if a > b {
+		return a - b
+	}
+
+	return b - a
+}
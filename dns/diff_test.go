@@ -0,0 +1,152 @@
+package dns
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+)
+
+func Test_diffResponses(t *testing.T) {
+	t.Parallel()
+
+	before := diffedResponse{
+		Rcode: "NOERROR",
+		Sections: map[string][]Record{
+			sectionAnswer: {
+				{Name: "k6.io", Type: "A", TTL: 300, Data: "192.0.2.1"},
+				{Name: "k6.io", Type: "A", TTL: 300, Data: "192.0.2.2"},
+			},
+			sectionAuthority: {{Name: "k6.io", Type: "NS", TTL: 3600, Data: "ns1.k6.io"}},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		after   diffedResponse
+		opts    diffOptions
+		want    *diffResult
+		wantTTL bool
+	}{
+		{
+			name: "reordered records with TTL jitter",
+			after: diffedResponse{
+				Rcode: "NOERROR",
+				Sections: map[string][]Record{
+					sectionAnswer: {
+						{Name: "K6.io.", Type: "A", TTL: 297, Data: "192.0.2.2"},
+						{Name: "k6.io", Type: "A", TTL: 297, Data: "192.0.2.1"},
+					},
+					sectionAuthority: {{Name: "k6.io", Type: "NS", TTL: 3600, Data: "NS1.k6.io."}},
+				},
+			},
+			opts: diffOptions{Sections: []string{sectionAnswer, sectionAuthority}},
+			want: &diffResult{Equal: true, Added: []recordDiff{}, Removed: []recordDiff{}, Changed: []recordDiff{}},
+		},
+		{
+			name: "TTLs beyond the tolerance",
+			after: diffedResponse{
+				Rcode: "NOERROR",
+				Sections: map[string][]Record{
+					sectionAnswer: {
+						{Name: "k6.io", Type: "A", TTL: 297, Data: "192.0.2.1"},
+						{Name: "k6.io", Type: "A", TTL: 60, Data: "192.0.2.2"},
+					},
+				},
+			},
+			opts: diffOptions{Sections: []string{sectionAnswer}, CompareTTL: true, TTLTolerance: 5},
+			want: &diffResult{
+				Added:   []recordDiff{},
+				Removed: []recordDiff{},
+				Changed: []recordDiff{
+					{Section: sectionAnswer, Name: "k6.io", Type: "A", Data: "192.0.2.2", TTL: 60, PreviousTTL: 300},
+				},
+			},
+		},
+		{
+			name: "added, removed and rcode",
+			after: diffedResponse{
+				Rcode: "NXDOMAIN",
+				Sections: map[string][]Record{
+					sectionAnswer: {
+						{Name: "k6.io", Type: "A", TTL: 300, Data: "192.0.2.3"},
+					},
+				},
+			},
+			opts: diffOptions{Sections: []string{sectionAnswer, sectionAuthority}},
+			want: &diffResult{
+				Added: []recordDiff{
+					{Section: sectionAnswer, Name: "k6.io", Type: "A", Data: "192.0.2.3", TTL: 300},
+				},
+				Removed: []recordDiff{
+					{Section: sectionAnswer, Name: "k6.io", Type: "A", Data: "192.0.2.1", TTL: 300},
+					{Section: sectionAnswer, Name: "k6.io", Type: "A", Data: "192.0.2.2", TTL: 300},
+					{Section: sectionAuthority, Name: "k6.io", Type: "NS", Data: "ns1.k6.io", TTL: 3600},
+				},
+				Changed: []recordDiff{},
+				Rcode:   []string{"NOERROR", "NXDOMAIN"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.want, diffResponses(before, tt.after, tt.opts))
+		})
+	}
+}
+
+func TestModuleInstance_Diff(t *testing.T) {
+	t.Parallel()
+
+	runtime, err := newConfiguredRuntime(t)
+	require.NoError(t, err)
+
+	_, err = runtime.VU.Runtime().RunString(`
+		const server = dns.startServer(["k6.test. 60 IN A 203.0.113.1", "k6.test. 60 IN A 203.0.113.2"]);
+	`)
+	require.NoError(t, err)
+
+	runtime.MoveToVUContext(&lib.State{
+		BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+		Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+		Samples:        make(chan metrics.SampleContainer, 1024),
+	})
+
+	_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+		const query = () => dns.sendMessage(dns.newMessage().addQuestion("k6.test", "A"), server.address);
+
+		const before = await query();
+		const saved = JSON.parse(JSON.stringify(before));
+
+		server.removeRecords("k6.test", "A").addRecord("k6.test. 60 IN A 203.0.113.3");
+
+		const after = await query();
+		const diff = dns.diff(saved, after);
+		if (diff.equal || diff.added.map((r) => r.data).join() !== "203.0.113.3" ||
+			diff.removed.map((r) => r.data).join() !== "203.0.113.1,203.0.113.2" || diff.rcode !== null) {
+			throw new Error("unexpected diff: " + JSON.stringify(diff));
+		}
+
+		const resolved = await dns.resolve("k6.test", "A", server.address, { throw: false });
+		if (!dns.diff(after, JSON.parse(JSON.stringify(resolved))).equal) {
+			throw new Error("expected the resolution to match the message: " + JSON.stringify(resolved));
+		}
+
+		try {
+			dns.diff(before, after, { sections: ["question"] });
+			throw new Error("expected the question section to be rejected");
+		} catch (e) {
+			if (!String(e).includes("unknown section")) {
+				throw e;
+			}
+		}
+	`))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,214 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/grafana/sobek"
+	"go.k6.io/k6/js/promises"
+)
+
+// ipv4OnlyName is the name DNS64 nameservers synthesize AAAA records for, out of
+// its well-known IPv4 addresses, allowing to discover the NAT64 prefix, as defined
+// by RFC 7050.
+const ipv4OnlyName = "ipv4only.arpa"
+
+// wellKnownIPv4s holds the IPv4 addresses of the ipv4only.arpa name.
+var wellKnownIPv4s = []net.IP{ //nolint:gochecknoglobals
+	net.IPv4(192, 0, 0, 170),
+	net.IPv4(192, 0, 0, 171),
+}
+
+// nat64PrefixLengths holds the lengths of the prefixes IPv4 addresses can be
+// embedded into IPv6 addresses with, as defined by RFC 6052, in the order they
+// are looked for.
+var nat64PrefixLengths = []int{96, 64, 56, 48, 40, 32} //nolint:gochecknoglobals
+
+// extractIPv4 extracts the IPv4 address embedded into the IPv6 address by a
+// prefix of the length, as defined by RFC 6052. Bits 64 to 71 of the IPv6 address
+// are reserved, and thus skipped.
+func extractIPv4(ip net.IP, length int) net.IP {
+	ip16 := ip.To16()
+	if ip16 == nil || ip.To4() != nil {
+		return nil
+	}
+
+	ip4 := make(net.IP, 0, net.IPv4len)
+	for i := length / 8; len(ip4) < net.IPv4len && i < net.IPv6len; i++ {
+		if i == 8 {
+			continue
+		}
+
+		ip4 = append(ip4, ip16[i])
+	}
+
+	return ip4
+}
+
+// nat64Prefix returns the NAT64 prefix the IPv6 address was synthesized with, out
+// of one of the well-known IPv4 addresses, or nil if it was not.
+func nat64Prefix(ip net.IP) *net.IPNet {
+	for _, length := range nat64PrefixLengths {
+		embedded := extractIPv4(ip, length)
+
+		for _, wellKnown := range wellKnownIPv4s {
+			if embedded.Equal(wellKnown) {
+				mask := net.CIDRMask(length, 8*net.IPv6len)
+				return &net.IPNet{IP: ip.Mask(mask), Mask: mask}
+			}
+		}
+	}
+
+	return nil
+}
+
+// nat64PrefixResult is the object the discoverNAT64Prefix function resolves to.
+type nat64PrefixResult struct {
+	// Detected indicates whether the nameserver synthesized AAAA records, and thus
+	// is a DNS64 nameserver.
+	Detected bool `js:"detected"`
+
+	// Prefixes holds the NAT64 prefixes which were discovered, in the CIDR notation.
+	Prefixes []string `js:"prefixes"`
+
+	// Addresses holds the AAAA records' addresses of the ipv4only.arpa name.
+	Addresses []string `js:"addresses"`
+
+	// RTT holds the duration of the resolution, in milliseconds.
+	RTT float64 `js:"rtt"`
+}
+
+// newNAT64PrefixResult creates a nat64PrefixResult out of the AAAA records'
+// addresses of the ipv4only.arpa name.
+func newNAT64PrefixResult(addresses []string) *nat64PrefixResult {
+	result := &nat64PrefixResult{
+		Prefixes:  []string{},
+		Addresses: addresses,
+	}
+
+	seen := make(map[string]bool, len(addresses))
+	for _, address := range addresses {
+		prefix := nat64Prefix(net.ParseIP(address))
+		if prefix == nil || seen[prefix.String()] {
+			continue
+		}
+
+		seen[prefix.String()] = true
+		result.Prefixes = append(result.Prefixes, prefix.String())
+	}
+
+	result.Detected = len(result.Prefixes) > 0
+
+	return result
+}
+
+// DiscoverNAT64Prefix discovers the NAT64 prefixes a DNS64 nameserver synthesizes
+// AAAA records with, as defined by RFC 7050, by resolving the AAAA records of the
+// ipv4only.arpa name.
+func (mi *ModuleInstance) DiscoverNAT64Prefix(nameserverAddr, options sobek.Value) *sobek.Promise {
+	promise, resolve, reject := promises.New(mi.vu)
+
+	if mi.vu.State() == nil {
+		reject(errors.New("discoverNAT64Prefix can not be used in the init context"))
+		return promise
+	}
+
+	nameserver, err := mi.exportNameserver(nameserverAddr)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	opts, err := parseResolveOptions(mi.vu.Runtime(), options)
+	if err != nil {
+		reject(fmt.Errorf("invalid discoverNAT64Prefix options: %w", err))
+		return promise
+	}
+
+	// Nameservers which are not DNS64 nameservers answer that the name does not exist
+	opts.NXDomainAsEmpty = true
+
+	ctx, cancel, err := mi.withAbortSignal(opts.Signal)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer cancel()
+
+		result, err := mi.discoverNAT64Prefix(ctx, nameserver, opts)
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(result)
+	}()
+
+	return promise
+}
+
+// discoverNAT64Prefix resolves the AAAA records of the ipv4only.arpa name against
+// the nameserver, and discovers the NAT64 prefixes they were synthesized with.
+func (mi *ModuleInstance) discoverNAT64Prefix(
+	ctx context.Context,
+	nameserver Nameserver,
+	opts resolveOptions,
+) (*nat64PrefixResult, error) {
+	response, duration, err := mi.resolveQuery(ctx, ipv4OnlyName, RecordTypeAAAA.String(), nameserver, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := response.Answers
+	if addresses == nil {
+		addresses = []string{}
+	}
+
+	result := newNAT64PrefixResult(addresses)
+	result.RTT = float64(duration) / float64(time.Millisecond)
+
+	return result, nil
+}
+
+// ExtractIPv4 returns the IPv4 address embedded into the IPv6 address by the NAT64
+// prefix, in the CIDR notation, as synthesized by DNS64 nameservers. It returns
+// null if the IPv6 address does not fall within the prefix.
+func ExtractIPv4(address, prefix string) (interface{}, error) {
+	ip := net.ParseIP(address)
+	if ip == nil || ip.To4() != nil {
+		return nil, fmt.Errorf("address must be an IPv6 address; got %s instead", address)
+	}
+
+	_, network, err := net.ParseCIDR(prefix)
+	if err != nil || network.IP.To4() != nil {
+		return nil, fmt.Errorf("prefix must be an IPv6 prefix in the CIDR notation; got %s instead", prefix)
+	}
+
+	length, _ := network.Mask.Size()
+	if !isNAT64PrefixLength(length) {
+		return nil, fmt.Errorf("prefix length must be one of 32, 40, 48, 56, 64 or 96; got %d instead", length)
+	}
+
+	if !network.Contains(ip) {
+		return nil, nil //nolint:nilnil
+	}
+
+	return extractIPv4(ip, length).String(), nil
+}
+
+// isNAT64PrefixLength returns whether IPv4 addresses can be embedded into IPv6
+// addresses with a prefix of the length.
+func isNAT64PrefixLength(length int) bool {
+	for _, l := range nat64PrefixLengths {
+		if l == length {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,71 @@
+package dns
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractIPv4(t *testing.T) {
+	t.Parallel()
+
+	// Examples of RFC 6052, section 2.4, embedding 192.0.2.33
+	tests := []struct {
+		name    string
+		address string
+		prefix  string
+		want    interface{}
+		wantErr bool
+	}{
+		{name: "32 bits prefix", address: "2001:db8:c000:221::", prefix: "2001:db8::/32", want: "192.0.2.33"},
+		{name: "40 bits prefix", address: "2001:db8:1c0:2:21::", prefix: "2001:db8:100::/40", want: "192.0.2.33"},
+		{name: "48 bits prefix", address: "2001:db8:122:c000:2:2100::", prefix: "2001:db8:122::/48", want: "192.0.2.33"},
+		{name: "56 bits prefix", address: "2001:db8:122:3c0:0:221::", prefix: "2001:db8:122:300::/56", want: "192.0.2.33"},
+		{name: "64 bits prefix", address: "2001:db8:122:344:c0:2:2100:0", prefix: "2001:db8:122:344::/64", want: "192.0.2.33"},
+		{name: "96 bits prefix", address: "2001:db8:122:344::192.0.2.33", prefix: "2001:db8:122:344::/96", want: "192.0.2.33"},
+		{name: "well-known prefix", address: "64:ff9b::c000:221", prefix: "64:ff9b::/96", want: "192.0.2.33"},
+		{name: "outside the prefix", address: "2001:db8::1", prefix: "64:ff9b::/96", want: nil},
+		{name: "IPv4 address", address: "192.0.2.33", prefix: "64:ff9b::/96", wantErr: true},
+		{name: "invalid prefix length", address: "64:ff9b::c000:221", prefix: "64:ff9b::/80", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ExtractIPv4(tt.address, tt.prefix)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_newNAT64PrefixResult(t *testing.T) {
+	t.Parallel()
+
+	t.Run("DNS64 nameserver", func(t *testing.T) {
+		t.Parallel()
+
+		got := newNAT64PrefixResult([]string{"64:ff9b::c000:aa", "64:ff9b::c000:ab", "2001:db8:1c0:0:aa::"})
+
+		assert.True(t, got.Detected)
+		assert.Equal(t, []string{"64:ff9b::/96", "2001:db8:100::/40"}, got.Prefixes)
+	})
+
+	t.Run("regular nameserver", func(t *testing.T) {
+		t.Parallel()
+
+		got := newNAT64PrefixResult([]string{})
+
+		assert.False(t, got.Detected)
+		assert.Empty(t, got.Prefixes)
+	})
+}
@@ -0,0 +1,365 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/sobek"
+	"github.com/miekg/dns"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/promises"
+)
+
+// browseResult is the object the browse function resolves to.
+type browseResult struct {
+	// Service holds the service type which was browsed, e.g. "_http._tcp.local".
+	Service string `js:"service"`
+
+	// Instances holds the instances of the service which were discovered.
+	Instances []*serviceInstance `js:"instances"`
+
+	// Elapsed holds the duration of the browsing, in milliseconds.
+	Elapsed float64 `js:"elapsed"`
+}
+
+// serviceInstance describes an instance of a service discovered through DNS-based
+// service discovery.
+type serviceInstance struct {
+	// Name holds the name of the instance, e.g. "Printer._http._tcp.local".
+	Name string `js:"name"`
+
+	// Instance holds the unescaped instance label of the name, e.g. "Printer".
+	Instance string `js:"instance"`
+
+	// Target holds the host the instance runs on, as found in its SRV record.
+	Target string `js:"target"`
+
+	// Port holds the port the instance listens on, as found in its SRV record.
+	Port uint16 `js:"port"`
+
+	// Priority holds the priority of the instance's SRV record.
+	Priority uint16 `js:"priority"`
+
+	// Weight holds the weight of the instance's SRV record.
+	Weight uint16 `js:"weight"`
+
+	// TXT holds the key/value pairs of the instance's TXT record, by lowercase key.
+	// Keys without a value are mapped to an empty string.
+	TXT map[string]string `js:"txt"`
+
+	// Addresses holds the IP addresses of the target.
+	Addresses []string `js:"addresses"`
+
+	// Error holds the error resolving the instance's records failed with, if any.
+	Error *Error `js:"error"`
+}
+
+// Browse discovers the instances of a service through DNS-based service discovery,
+// as defined by RFC 6763, along with the details of their SRV and TXT records.
+//
+// The service is browsed using multicast DNS, as defined by RFC 6762, unless a
+// nameserver is provided.
+func (mi *ModuleInstance) Browse(service, nameserverAddr, options sobek.Value) *sobek.Promise {
+	promise, resolve, reject := promises.New(mi.vu)
+
+	if mi.vu.State() == nil {
+		reject(errors.New("browse can not be used in the init context"))
+		return promise
+	}
+
+	serviceStr, err := exportDomainName(mi.vu.Runtime(), service, "service")
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	nameserver := mdnsNameserver
+	if !common.IsNullish(nameserverAddr) {
+		if nameserver, err = exportNameserver(mi.vu.Runtime(), nameserverAddr); err != nil {
+			reject(err)
+			return promise
+		}
+	}
+
+	opts, err := parseResolveOptions(mi.vu.Runtime(), options)
+	if err != nil {
+		reject(fmt.Errorf("invalid browse options: %w", err))
+		return promise
+	}
+
+	ctx, cancel, err := mi.withAbortSignal(opts.Signal)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer cancel()
+
+		result, err := mi.browse(ctx, serviceStr, nameserver, opts)
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(result)
+	}()
+
+	return promise
+}
+
+// browse resolves the PTR records of the service against the nameserver, and the
+// SRV, TXT and address records of its instances which were not part of the
+// responses, concurrently.
+func (mi *ModuleInstance) browse(
+	ctx context.Context,
+	service string,
+	nameserver Nameserver,
+	opts resolveOptions,
+) (*browseResult, error) {
+	start := time.Now()
+	records := newRecordPool()
+
+	query := func(name string, qtype uint16) error {
+		msg := new(dns.Msg)
+		msg.SetQuestion(dns.Fqdn(name), qtype)
+		msg.RecursionDesired = !opts.NoRecursion && !nameserver.IP.IsMulticast()
+
+		response, _, err := mi.sendMessage(ctx, msg, nameserver, opts.QueryOptions)
+		if err != nil {
+			return err
+		}
+
+		rcode := response.msg.Rcode
+		if rcode != dns.RcodeSuccess && rcode != dns.RcodeNameError {
+			return withNameserver(newDNSError(rcode, "DNS query failed"), nameserver)
+		}
+
+		records.add(response.msg.Answer, response.msg.Ns, response.msg.Extra)
+
+		return nil
+	}
+
+	if err := query(service, dns.TypePTR); err != nil {
+		return nil, err
+	}
+
+	names := records.ptrTargets(service)
+	instances := make([]*serviceInstance, len(names))
+
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+
+		go func(i int, name string) {
+			defer wg.Done()
+
+			instances[i] = resolveServiceInstance(name, service, records, query)
+		}(i, name)
+	}
+
+	wg.Wait()
+
+	return &browseResult{
+		Service:   service,
+		Instances: instances,
+		Elapsed:   float64(time.Since(start)) / float64(time.Millisecond),
+	}, nil
+}
+
+// resolveServiceInstance describes the instance of the service, querying its SRV,
+// TXT and address records if they are not already known.
+func resolveServiceInstance(
+	name, service string,
+	records *recordPool,
+	query func(name string, qtype uint16) error,
+) *serviceInstance {
+	instance := &serviceInstance{
+		Name:      strings.TrimSuffix(name, "."),
+		Instance:  instanceLabel(name, service),
+		Addresses: []string{},
+	}
+
+	var errs []error
+	for _, qtype := range []uint16{dns.TypeSRV, dns.TypeTXT} {
+		if !records.has(name, qtype) {
+			errs = append(errs, query(name, qtype))
+		}
+	}
+
+	if srv := records.srv(name); srv != nil {
+		instance.Target = strings.TrimSuffix(srv.Target, ".")
+		instance.Port = srv.Port
+		instance.Priority = srv.Priority
+		instance.Weight = srv.Weight
+
+		if !records.has(srv.Target, dns.TypeA) && !records.has(srv.Target, dns.TypeAAAA) {
+			errs = append(errs, query(srv.Target, dns.TypeA), query(srv.Target, dns.TypeAAAA))
+		}
+
+		instance.Addresses = records.addresses(srv.Target)
+	}
+
+	instance.TXT = records.txt(name)
+	instance.Error = asError(errors.Join(errs...))
+
+	return instance
+}
+
+// instanceLabel returns the unescaped instance label of the service instance's name.
+func instanceLabel(name, service string) string {
+	label := strings.TrimSuffix(dns.Fqdn(name), "."+dns.Fqdn(service))
+
+	var unescaped strings.Builder
+	for i := 0; i < len(label); i++ {
+		if label[i] != '\\' || i+1 == len(label) {
+			unescaped.WriteByte(label[i])
+			continue
+		}
+
+		// Escaped bytes are either in the \DDD decimal form, or the \X form
+		if i+3 < len(label) {
+			if b, err := strconv.ParseUint(label[i+1:i+4], 10, 8); err == nil {
+				unescaped.WriteByte(byte(b))
+				i += 3
+
+				continue
+			}
+		}
+
+		unescaped.WriteByte(label[i+1])
+		i++
+	}
+
+	return unescaped.String()
+}
+
+// recordPool holds the records received while browsing a service, by name and
+// type, safe for concurrent use.
+type recordPool struct {
+	mu      sync.Mutex
+	records map[recordKey][]dns.RR
+}
+
+// recordKey identifies the records of a name and type.
+type recordKey struct {
+	name  string
+	rtype uint16
+}
+
+// newRecordPool creates an empty recordPool.
+func newRecordPool() *recordPool {
+	return &recordPool{records: make(map[recordKey][]dns.RR)}
+}
+
+// newRecordKey creates the recordKey of the name and type, regardless of the
+// name's case.
+func newRecordKey(name string, rtype uint16) recordKey {
+	return recordKey{name: strings.ToLower(dns.Fqdn(name)), rtype: rtype}
+}
+
+// add adds the records of the sections to the pool.
+func (p *recordPool) add(sections ...[]dns.RR) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, section := range sections {
+		for _, rr := range section {
+			key := newRecordKey(rr.Header().Name, rr.Header().Rrtype)
+			p.records[key] = append(p.records[key], rr)
+		}
+	}
+}
+
+// get returns the records of the name and type.
+func (p *recordPool) get(name string, rtype uint16) []dns.RR {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Return a copy, as the records might be added to concurrently
+	return append([]dns.RR(nil), p.records[newRecordKey(name, rtype)]...)
+}
+
+// has returns whether the pool holds records of the name and type.
+func (p *recordPool) has(name string, rtype uint16) bool {
+	return len(p.get(name, rtype)) > 0
+}
+
+// ptrTargets returns the distinct names the PTR records of the name point to, in
+// the order they were received.
+func (p *recordPool) ptrTargets(name string) []string {
+	targets := []string{}
+	seen := make(map[string]bool)
+
+	for _, rr := range p.get(name, dns.TypePTR) {
+		ptr, ok := rr.(*dns.PTR)
+		if !ok || seen[strings.ToLower(ptr.Ptr)] {
+			continue
+		}
+
+		seen[strings.ToLower(ptr.Ptr)] = true
+		targets = append(targets, ptr.Ptr)
+	}
+
+	return targets
+}
+
+// srv returns the first SRV record of the name, if any.
+func (p *recordPool) srv(name string) *dns.SRV {
+	for _, rr := range p.get(name, dns.TypeSRV) {
+		if srv, ok := rr.(*dns.SRV); ok {
+			return srv
+		}
+	}
+
+	return nil
+}
+
+// txt returns the key/value pairs of the TXT records of the name, as defined by
+// RFC 6763 section 6, by lowercase key. Only the first occurrence of a key counts.
+func (p *recordPool) txt(name string) map[string]string {
+	pairs := map[string]string{}
+
+	for _, rr := range p.get(name, dns.TypeTXT) {
+		txt, ok := rr.(*dns.TXT)
+		if !ok {
+			continue
+		}
+
+		for _, str := range txt.Txt {
+			key, value, _ := strings.Cut(str, "=")
+			key = strings.ToLower(key)
+
+			if _, exists := pairs[key]; key == "" || exists {
+				continue
+			}
+
+			pairs[key] = value
+		}
+	}
+
+	return pairs
+}
+
+// addresses returns the IP addresses of the A and AAAA records of the name.
+func (p *recordPool) addresses(name string) []string {
+	addresses := []string{}
+
+	for _, rtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		for _, rr := range p.get(name, rtype) {
+			switch t := rr.(type) {
+			case *dns.A:
+				addresses = append(addresses, t.A.String())
+			case *dns.AAAA:
+				addresses = append(addresses, t.AAAA.String())
+			}
+		}
+	}
+
+	return addresses
+}
@@ -0,0 +1,63 @@
+package dns
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_instanceLabel(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		instance string
+		want     string
+	}{
+		{name: "plain label", instance: "printer._ipp._tcp.local.", want: "printer"},
+		{name: "escaped space", instance: `My\ Printer._ipp._tcp.local.`, want: "My Printer"},
+		{name: "decimal escape", instance: `Web\046Server._ipp._tcp.local`, want: "Web.Server"},
+		{name: "escaped backslash", instance: `back\\slash._ipp._tcp.local.`, want: `back\slash`},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.want, instanceLabel(tt.instance, "_ipp._tcp.local"))
+		})
+	}
+}
+
+func Test_recordPool(t *testing.T) {
+	t.Parallel()
+
+	rrs := make([]dns.RR, 0, 6)
+	for _, record := range []string{
+		`_ipp._tcp.local. 120 IN PTR printer._ipp._tcp.local.`,
+		`_ipp._tcp.local. 120 IN PTR Printer._ipp._tcp.local.`,
+		`_ipp._tcp.local. 120 IN PTR scanner._ipp._tcp.local.`,
+		`printer._ipp._tcp.local. 120 IN TXT "txtvers=1" "Color=T" "color=F" "duplex" "=ignored"`,
+		`printer.local. 120 IN A 192.0.2.1`,
+		`PRINTER.local. 120 IN AAAA 2001:db8::1`,
+	} {
+		rr, err := dns.NewRR(record)
+		require.NoError(t, err)
+
+		rrs = append(rrs, rr)
+	}
+
+	pool := newRecordPool()
+	pool.add(rrs[:3], rrs[3:])
+
+	assert.Equal(t, []string{"printer._ipp._tcp.local.", "scanner._ipp._tcp.local."}, pool.ptrTargets("_ipp._tcp.local"))
+	assert.Equal(t, map[string]string{"txtvers": "1", "color": "T", "duplex": ""}, pool.txt("Printer._ipp._tcp.local."))
+	assert.Equal(t, []string{"192.0.2.1", "2001:db8::1"}, pool.addresses("printer.local"))
+	assert.True(t, pool.has("printer._ipp._tcp.local", dns.TypeTXT))
+	assert.False(t, pool.has("printer._ipp._tcp.local", dns.TypeSRV))
+	assert.Nil(t, pool.srv("printer._ipp._tcp.local"))
+}
@@ -0,0 +1,325 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Defaults of the queries sent over DoH.
+const (
+	// defaultDoHPath is the path of the URI template queries are sent to over DoH,
+	// as used by most public resolvers.
+	defaultDoHPath = "/dns-query"
+
+	// dohMediaType is the media type of DNS messages exchanged over DoH.
+	dohMediaType = "application/dns-message"
+)
+
+// Versions of HTTP DoH requests can be sent over.
+const (
+	httpVersion1 = "1.1"
+	httpVersion2 = "2"
+)
+
+// httpTransportKey identifies the transports DoH queries are sent over.
+type httpTransportKey struct {
+	serverName string
+	version    string
+}
+
+// exchangeHTTPS sends the packed message to the nameserver over DoH, as described
+// by RFC 8484, and retransmits it up to opts.Retries times if the attempts time
+// out. It returns the response along with its wire format.
+func (r *Client) exchangeHTTPS(
+	ctx context.Context,
+	id uint16,
+	packed []byte,
+	nameserver Nameserver,
+	opts QueryOptions,
+	result *Response,
+) (*dns.Msg, []byte, error) {
+	serverName := r.tlsServerName(nameserver, opts)
+	transport := r.httpTransport(httpTransportKey{serverName: serverName, version: opts.HTTPVersion}, opts.IdleTimeout)
+
+	path := opts.DoHPath
+	if path == "" {
+		path = defaultDoHPath
+	}
+
+	// Requests are sent to the address of the nameserver, on behalf of the server
+	// name its certificate is verified against
+	target := url.URL{Scheme: "https", Host: nameserver.Addr(), Path: path}
+
+	// As per RFC 8484, GET requests carry a zero ID, for the responses to identical
+	// queries to be cached regardless of the ID they were sent with. Signed queries
+	// keep theirs, as their signature covers it.
+	if opts.DoHMethod == http.MethodGet && opts.TSIG == nil {
+		packed = append([]byte(nil), packed...)
+		packed[0], packed[1] = 0, 0
+		id = 0
+	}
+
+	for {
+		result.Attempts++
+
+		raw, err := r.attemptHTTPS(ctx, transport, target.String(), serverName, packed, opts, result)
+		if err == nil {
+			response := new(dns.Msg)
+			if err := response.Unpack(raw); err != nil {
+				return nil, nil, err
+			}
+
+			if response.Id != id {
+				return nil, nil, dns.ErrId
+			}
+
+			return response, raw, nil
+		}
+
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return nil, nil, ctx.Err()
+		}
+
+		if !isTimeout(err) {
+			return nil, nil, err
+		}
+
+		result.Timeouts++
+		if result.Attempts > opts.Retries || ctx.Err() != nil {
+			return nil, nil, err
+		}
+	}
+}
+
+// attemptHTTPS sends the packed query in a request of the opts.DoHMethod method
+// once, and reads the response to it, bounding the exchange to opts.Timeout if it is greater than
+// zero, or to defaultAttemptTimeout otherwise.
+func (r *Client) attemptHTTPS(
+	ctx context.Context,
+	transport *http.Transport,
+	target, serverName string,
+	packed []byte,
+	opts QueryOptions,
+	result *Response,
+) ([]byte, error) {
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = defaultAttemptTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				result.ReusedConnections++
+			}
+
+			result.localAddr = info.Conn.LocalAddr()
+			result.OpenConnections = r.openConnections.Load()
+		},
+	})
+
+	request, err := newDoHRequest(ctx, opts.DoHMethod, target, packed)
+	if err != nil {
+		return nil, err
+	}
+
+	request.Host = serverName
+	request.Header.Set("Accept", dohMediaType)
+
+	// Custom headers, e.g. the credentials of managed resolvers, take precedence
+	for name, values := range opts.DoHHeaders {
+		request.Header[name] = values
+	}
+
+	response, err := transport.RoundTrip(request)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = response.Body.Close() }()
+
+	result.HTTPVersion = response.Proto
+	result.HTTPCache = newHTTPCacheInfo(response.Header)
+	if response.TLS != nil {
+		result.TLS = newTLSInfo(*response.TLS)
+	}
+
+	// Nameservers may not negotiate HTTP/2, in which case HTTP/1.1 is used instead
+	if opts.HTTPVersion == httpVersion2 && response.ProtoMajor != 2 {
+		return nil, fmt.Errorf("the DoH nameserver does not support HTTP/2, and answered over %s", response.Proto)
+	}
+
+	result.HTTPStatus = response.StatusCode
+	if response.StatusCode/100 != 2 {
+		return nil, newHTTPStatusError(response)
+	}
+
+	if mediaType := response.Header.Get("Content-Type"); !strings.HasPrefix(mediaType, dohMediaType) {
+		return nil, fmt.Errorf("the DoH nameserver answered with the %q content type", mediaType)
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(response.Body, dns.MaxMsgSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(raw) > dns.MaxMsgSize {
+		return nil, fmt.Errorf("the DoH response exceeds the %d bytes of DNS messages", dns.MaxMsgSize)
+	}
+
+	return raw, nil
+}
+
+// newDoHRequest returns the request carrying the packed query to the target, either
+// encoded in the dns parameter of its URL for GET requests, or as the body of
+// POST requests otherwise.
+func newDoHRequest(ctx context.Context, method, target string, packed []byte) (*http.Request, error) {
+	if method == http.MethodGet {
+		return http.NewRequestWithContext(
+			ctx, http.MethodGet, target+"?dns="+base64.RawURLEncoding.EncodeToString(packed), nil,
+		)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(packed))
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Content-Type", dohMediaType)
+
+	return request, nil
+}
+
+// HTTPCacheInfo describes the caching of a DoH response by HTTP caches, such as
+// the CDNs DoH nameservers are fronted by.
+type HTTPCacheInfo struct {
+	// Age holds the number of seconds the response was held in a cache for, as
+	// found in its Age header, or is nil if it has none, as when it was not served
+	// out of a cache.
+	Age *int64 `js:"age"`
+
+	// CacheControl holds the Cache-Control header of the response, e.g.
+	// "max-age=300", if any.
+	CacheControl string `js:"cacheControl"`
+}
+
+// newHTTPCacheInfo creates an HTTPCacheInfo out of the headers of a DoH response.
+// Invalid Age headers are ignored.
+func newHTTPCacheInfo(header http.Header) *HTTPCacheInfo {
+	info := &HTTPCacheInfo{CacheControl: header.Get("Cache-Control")}
+
+	if age, err := strconv.ParseInt(strings.TrimSpace(header.Get("Age")), 10, 64); err == nil && age >= 0 {
+		info.Age = &age
+	}
+
+	return info
+}
+
+// httpTransport returns the HTTP transport DoH queries are sent over to the
+// nameservers whose certificate is verified against the server name of the key,
+// over its version of HTTP, creating it if need be. Transports keep their
+// connections open, and negotiate HTTP/2 when nameservers support it unless the
+// version is HTTP/1.1.
+func (r *Client) httpTransport(key httpTransportKey, idleTimeout time.Duration) *http.Transport {
+	conns := r.connections()
+
+	conns.httpTransportsMu.Lock()
+	defer conns.httpTransportsMu.Unlock()
+
+	if transport, ok := conns.httpTransports[key]; ok {
+		return transport
+	}
+
+	if idleTimeout <= 0 {
+		idleTimeout = defaultIdleTimeout
+	}
+
+	config := r.newTLSConfig()
+	config.ServerName = key.serverName
+
+	transport := &http.Transport{
+		DialContext:       r.dialHTTPS,
+		TLSClientConfig:   config,
+		ForceAttemptHTTP2: true,
+		IdleConnTimeout:   idleTimeout,
+	}
+
+	switch key.version {
+	case httpVersion1:
+		// A non-nil map of upgrades disables HTTP/2
+		config.NextProtos = []string{"http/1.1"}
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	case httpVersion2:
+		config.NextProtos = []string{"h2"}
+	}
+
+	conns.httpTransports[key] = transport
+
+	return transport
+}
+
+// dialHTTPS opens a TCP connection DoH queries are sent over, and accounts for it
+// in the client's open connections count until it is closed.
+func (r *Client) dialHTTPS(ctx context.Context, network, addr string) (net.Conn, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	r.openConnections.Add(1)
+
+	return &countedConn{Conn: conn, openConnections: r.openConnections}, nil
+}
+
+// rateLimitHeaders holds the prefixes of the names of the response headers
+// describing the rate limits of DoH nameservers, as lowercase names.
+var rateLimitHeaders = []string{"retry-after", "ratelimit", "x-ratelimit"}
+
+// httpStatusError is returned when a DoH nameserver answers with a non-2xx HTTP
+// status.
+type httpStatusError struct {
+	status  int
+	text    string
+	headers map[string]string
+}
+
+// newHTTPStatusError returns the error describing the DoH response, along with
+// its rate limiting headers.
+func newHTTPStatusError(response *http.Response) *httpStatusError {
+	err := &httpStatusError{status: response.StatusCode, text: response.Status, headers: map[string]string{}}
+
+	for name, values := range response.Header {
+		name = strings.ToLower(name)
+		for _, prefix := range rateLimitHeaders {
+			if strings.HasPrefix(name, prefix) && len(values) > 0 {
+				err.headers[name] = values[0]
+				break
+			}
+		}
+	}
+
+	return err
+}
+
+// Error returns the error message.
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("the DoH nameserver answered with the %s HTTP status", e.text)
+}
@@ -0,0 +1,243 @@
+package dns
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_exchangeHTTPS(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Queries over DoH should be sent with the custom headers", func(t *testing.T) {
+		t.Parallel()
+
+		requests := make(chan *http.Request, 2)
+		nameserver, client := newTestDoHServer(t, true, func(w http.ResponseWriter, r *http.Request) {
+			requests <- r
+		})
+
+		opts := QueryOptions{
+			Protocol:      protocolDoH,
+			TLSServerName: "doh.k6.test",
+			DoHPath:       "/resolve",
+			DoHHeaders:    http.Header{"Authorization": {"Bearer token"}, "User-Agent": {"k6-test"}},
+		}
+
+		response, err := client.Query(context.Background(), "k6.test", "A", nameserver, opts)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"203.0.113.1"}, response.Answers)
+		assert.Equal(t, protocolDoH, response.Protocol)
+		assert.Equal(t, "HTTP/2.0", response.HTTPVersion)
+		require.NotNil(t, response.TLS)
+		assert.Equal(t, "h2", response.TLS.ALPN)
+		assert.Equal(t, "doh.k6.test", response.TLS.ServerName)
+
+		request := <-requests
+		assert.Equal(t, http.MethodPost, request.Method)
+		assert.Equal(t, "/resolve", request.URL.Path)
+		assert.Equal(t, "doh.k6.test", request.Host)
+		assert.Equal(t, "HTTP/2.0", request.Proto)
+		assert.Equal(t, dohMediaType, request.Header.Get("Content-Type"))
+		assert.Equal(t, "Bearer token", request.Header.Get("Authorization"))
+		assert.Equal(t, "k6-test", request.Header.Get("User-Agent"))
+
+		// Queries are multiplexed over the same connection
+		response, err = client.Query(context.Background(), "k6.test", "A", nameserver, opts)
+		require.NoError(t, err)
+		assert.Equal(t, 1, response.ReusedConnections)
+	})
+
+	t.Run("Queries over DoH should be cacheable when sent with the GET method", func(t *testing.T) {
+		t.Parallel()
+
+		requests := make(chan *http.Request, 2)
+		nameserver, client := newTestDoHServer(t, true, func(w http.ResponseWriter, r *http.Request) {
+			requests <- r
+
+			w.Header().Set("Cache-Control", "max-age=60")
+			if len(requests) == 2 {
+				w.Header().Set("Age", "12")
+			}
+		})
+
+		opts := QueryOptions{
+			Protocol:      protocolDoH,
+			TLSServerName: "doh.k6.test",
+			DoHMethod:     http.MethodGet,
+			DoHHeaders:    http.Header{"Cache-Control": {"no-cache"}},
+		}
+
+		response, err := client.Query(context.Background(), "k6.test", "A", nameserver, opts)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"203.0.113.1"}, response.Answers)
+		require.NotNil(t, response.HTTPCache)
+		assert.Nil(t, response.HTTPCache.Age)
+		assert.Equal(t, "max-age=60", response.HTTPCache.CacheControl)
+
+		response, err = client.Query(context.Background(), "k6.test", "A", nameserver, opts)
+		require.NoError(t, err)
+		require.NotNil(t, response.HTTPCache)
+		require.NotNil(t, response.HTTPCache.Age)
+		assert.Equal(t, int64(12), *response.HTTPCache.Age)
+
+		// Identical queries are sent to the same URL, as their ID is zero
+		first, second := <-requests, <-requests
+		assert.Equal(t, http.MethodGet, first.Method)
+		assert.Equal(t, first.URL.String(), second.URL.String())
+		assert.Empty(t, first.Header.Get("Content-Type"))
+		assert.Equal(t, dohMediaType, first.Header.Get("Accept"))
+		assert.Equal(t, "no-cache", first.Header.Get("Cache-Control"))
+
+		packed, err := base64.RawURLEncoding.DecodeString(first.URL.Query().Get("dns"))
+		require.NoError(t, err)
+
+		query := new(dns.Msg)
+		require.NoError(t, query.Unpack(packed))
+		assert.Equal(t, uint16(0), query.Id)
+	})
+
+	t.Run("Queries over DoH should be sent over the requested version of HTTP", func(t *testing.T) {
+		t.Parallel()
+
+		requests := make(chan *http.Request, 1)
+		nameserver, client := newTestDoHServer(t, true, func(w http.ResponseWriter, r *http.Request) {
+			requests <- r
+		})
+
+		opts := QueryOptions{Protocol: protocolDoH, TLSServerName: "doh.k6.test", HTTPVersion: httpVersion1}
+
+		response, err := client.Query(context.Background(), "k6.test", "A", nameserver, opts)
+		require.NoError(t, err)
+		assert.Equal(t, "HTTP/1.1", response.HTTPVersion)
+		assert.Equal(t, "HTTP/1.1", (<-requests).Proto)
+	})
+
+	t.Run("Requiring HTTP/2 from nameservers not supporting it should fail", func(t *testing.T) {
+		t.Parallel()
+
+		nameserver, client := newTestDoHServer(t, false, func(http.ResponseWriter, *http.Request) {})
+
+		opts := QueryOptions{Protocol: protocolDoH, TLSServerName: "doh.k6.test", HTTPVersion: httpVersion2}
+
+		_, err := client.Query(context.Background(), "k6.test", "A", nameserver, opts)
+		assert.ErrorContains(t, err, "does not support HTTP/2")
+	})
+
+	t.Run("Responses with an error status should fail with an HTTPError", func(t *testing.T) {
+		t.Parallel()
+
+		nameserver, client := newTestDoHServer(t, true, func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		})
+
+		opts := QueryOptions{Protocol: protocolDoH, TLSServerName: "doh.k6.test"}
+
+		response, err := client.Query(context.Background(), "k6.test", "A", nameserver, opts)
+		assert.ErrorContains(t, err, "401 Unauthorized")
+		assert.Equal(t, http.StatusUnauthorized, response.HTTPStatus)
+
+		var dnsErr *Error
+		require.ErrorAs(t, err, &dnsErr)
+		assert.Equal(t, HTTPError, dnsErr.Kind)
+		assert.Equal(t, http.StatusUnauthorized, dnsErr.HTTPStatus)
+	})
+
+	t.Run("Responses with the too many requests status should fail with a RateLimited error", func(t *testing.T) {
+		t.Parallel()
+
+		nameserver, client := newTestDoHServer(t, true, func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Retry-After", "30")
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("Server", "doh.k6.test")
+			w.WriteHeader(http.StatusTooManyRequests)
+		})
+
+		opts := QueryOptions{Protocol: protocolDoH, TLSServerName: "doh.k6.test"}
+
+		_, err := client.Query(context.Background(), "k6.test", "A", nameserver, opts)
+
+		var dnsErr *Error
+		require.ErrorAs(t, err, &dnsErr)
+		assert.Equal(t, "RateLimited", dnsErr.Name)
+		assert.Equal(t, http.StatusTooManyRequests, dnsErr.HTTPStatus)
+		assert.Equal(t, map[string]string{"retry-after": "30", "x-ratelimit-remaining": "0"}, dnsErr.HTTPHeaders)
+	})
+}
+
+// newTestDoHServer starts a DoH server answering out of an in-process server's
+// records, once the inspect function returns without writing a response itself,
+// and returns its address along with a client trusting its certificate. The
+// server supports HTTP/2 if enableHTTP2 is true.
+func newTestDoHServer(t *testing.T, enableHTTP2 bool, inspect func(w http.ResponseWriter, r *http.Request)) (Nameserver, *Client) {
+	t.Helper()
+
+	server := newServer(noServerFaults(), 1)
+	_, err := server.AddRecord("k6.test. 60 IN A 203.0.113.1")
+	require.NoError(t, err)
+
+	cert, roots := newTestTLSCertificate(t, "doh.k6.test")
+
+	dohServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := httptest.NewRecorder()
+		inspect(recorder, r)
+		for name, values := range recorder.Header() {
+			w.Header()[name] = values
+		}
+
+		if recorder.Code != http.StatusOK {
+			w.WriteHeader(recorder.Code)
+			return
+		}
+
+		var body []byte
+		var err error
+		if r.Method == http.MethodGet {
+			body, err = base64.RawURLEncoding.DecodeString(r.URL.Query().Get("dns"))
+		} else {
+			body, err = io.ReadAll(r.Body)
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		query := new(dns.Msg)
+		if err := query.Unpack(body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		packed, err := server.answer(query).Pack()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", dohMediaType)
+		_, _ = w.Write(packed)
+	}))
+	dohServer.TLS = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	dohServer.EnableHTTP2 = enableHTTP2
+	dohServer.StartTLS()
+	t.Cleanup(dohServer.Close)
+
+	nameserver, err := parseNameserverAddr(dohServer.Listener.Addr().String())
+	require.NoError(t, err)
+
+	client := NewDNSClient()
+	client.tlsConfig = func() *tls.Config {
+		return &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}
+	}
+	t.Cleanup(func() { _ = client.Close() })
+
+	return nameserver, client
+}
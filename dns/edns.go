@@ -0,0 +1,175 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// negotiatedEDNSSizes holds the UDP payload sizes negotiated with nameservers, from
+// the largest to the smallest: the common maximum, the largest one avoiding
+// fragmentation over paths with a 1500 bytes MTU, over both IPv4 and IPv6, the one
+// avoiding it over any IPv6 path, as recommended by the DNS Flag Day 2020, and the
+// one of messages without EDNS.
+var negotiatedEDNSSizes = []uint16{4096, 1452, 1232, dns.MinMsgSize} //nolint:gochecknoglobals
+
+// ednsProbeInterval is the duration after which the UDP payload size negotiated with
+// a nameserver is probed again from the largest one, as timeouts might have been
+// caused by a transient loss rather than by fragmentation.
+const ednsProbeInterval = 10 * time.Minute
+
+// parseEDNSSize parses the value of the ednsSize option: either "auto", for the
+// size to be negotiated with each nameserver, or a UDP payload size.
+func parseEDNSSize(value interface{}) (size uint16, negotiate bool, err error) {
+	switch v := value.(type) {
+	case string:
+		if strings.EqualFold(v, "auto") {
+			return 0, true, nil
+		}
+	case int64:
+		if v >= dns.MinMsgSize && v <= math.MaxUint16 {
+			return uint16(v), false, nil
+		}
+	case float64:
+		if v >= dns.MinMsgSize && v <= math.MaxUint16 && v == math.Trunc(v) {
+			return uint16(v), false, nil
+		}
+	}
+
+	return 0, false, fmt.Errorf(
+		"ednsSize option must be 'auto' or an integer between %d and %d; got %v instead",
+		dns.MinMsgSize, math.MaxUint16, value,
+	)
+}
+
+// ednsSizes holds the UDP payload sizes negotiated with nameservers, by address. Its
+// zero value is ready to use, and it is safe for concurrent use.
+type ednsSizes struct {
+	mu    sync.Mutex
+	paths map[string]ednsPath
+}
+
+// ednsPath describes the UDP payload size negotiated with a nameserver.
+type ednsPath struct {
+	size    uint16
+	lowered time.Time
+}
+
+// size returns the UDP payload size to advertise to the nameserver: the one it was
+// lowered to, unless it was lowered more than ednsProbeInterval ago, or the largest
+// one otherwise.
+func (s *ednsSizes) size(nameserver Nameserver) uint16 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if path, ok := s.paths[nameserver.Addr()]; ok && time.Since(path.lowered) < ednsProbeInterval {
+		return path.size
+	}
+
+	return negotiatedEDNSSizes[0]
+}
+
+// lower lowers the UDP payload size negotiated with the nameserver below the size
+// an attempt timed out with, and returns the size to advertise from then on. Sizes
+// already lowered by concurrent attempts are not lowered further.
+func (s *ednsSizes) lower(nameserver Nameserver, timedOut uint16) uint16 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.paths == nil {
+		s.paths = make(map[string]ednsPath)
+	}
+
+	addr := nameserver.Addr()
+	if path, ok := s.paths[addr]; ok && path.size < timedOut && time.Since(path.lowered) < ednsProbeInterval {
+		return path.size
+	}
+
+	for _, size := range negotiatedEDNSSizes {
+		if size < timedOut {
+			s.paths[addr] = ednsPath{size: size, lowered: time.Now()}
+			return size
+		}
+	}
+
+	return timedOut
+}
+
+// lowerEDNSSize lowers the UDP payload size the message advertises, once an attempt
+// to send it timed out, and returns its wire format.
+func (r *Client) lowerEDNSSize(message *dns.Msg, packed []byte, nameserver Nameserver) ([]byte, error) {
+	opt := message.IsEdns0()
+	if opt == nil {
+		return packed, nil
+	}
+
+	size := r.ednsSizes.lower(nameserver, opt.UDPSize())
+	if size == opt.UDPSize() {
+		return packed, nil
+	}
+
+	opt.SetUDPSize(size)
+
+	return message.Pack()
+}
+
+// rejectsEDNS indicates whether the response tells the nameserver, or a middlebox
+// on the way to it, rejected the EDNS0 OPT record of the query: nameservers not
+// supporting EDNS answer with FORMERR, and those not supporting its version with
+// BADVERS.
+func rejectsEDNS(query, response *dns.Msg) bool {
+	if query.IsEdns0() == nil || response == nil {
+		return false
+	}
+
+	return response.Rcode == dns.RcodeFormatError || response.Rcode == dns.RcodeBadVers
+}
+
+// downgradeEDNS sends the query again without its EDNS0 OPT record, as resilient
+// stub resolvers do once it was rejected, as told by the rejected response. As
+// responses to queries without EDNS are bounded to 512 bytes over UDP, queries
+// sent over UDP are sent over TCP instead.
+func (r *Client) downgradeEDNS(
+	ctx context.Context,
+	query *dns.Msg,
+	nameserver Nameserver,
+	opts QueryOptions,
+	rejected *Response,
+) (*Response, error) {
+	downgraded := query.Copy()
+	downgraded.Id = dns.Id()
+
+	extra := downgraded.Extra[:0]
+	for _, rr := range downgraded.Extra {
+		if rr.Header().Rrtype != dns.TypeOPT {
+			extra = append(extra, rr)
+		}
+	}
+	downgraded.Extra = extra
+
+	protocol := opts.Protocol
+	if protocol == "" && r.defaults != nil {
+		protocol = r.defaults().Protocol
+	}
+
+	if protocol == "" || protocol == protocolUDP {
+		opts.Protocol = protocolTCP
+	}
+
+	result, err := r.Send(ctx, downgraded, nameserver, opts)
+	if result != nil {
+		// The extended BADVERS response code shares its value with the BADSIG TSIG
+		// error, which miekg/dns names it after
+		result.EDNSDowngrade = rejected.Rcode
+		if rejected.msg.Rcode == dns.RcodeBadVers {
+			result.EDNSDowngrade = "BADVERS"
+		}
+	}
+
+	return result, err
+}
@@ -0,0 +1,197 @@
+package dns
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+)
+
+func TestModuleInstance_Resolve_ednsFallback(t *testing.T) {
+	t.Parallel()
+
+	// The nameserver rejects queries carrying an OPT record, with FORMERR, or with
+	// BADVERS for the names of the badvers.k6.test zone
+	handler := dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		response := new(dns.Msg)
+		response.SetReply(req)
+
+		name := req.Question[0].Name
+		switch {
+		case req.IsEdns0() != nil && dns.IsSubDomain("badvers.k6.test.", name):
+			response.SetEdns0(dns.DefaultMsgSize, false)
+			response.Rcode = dns.RcodeBadVers
+		case req.IsEdns0() != nil:
+			response.Rcode = dns.RcodeFormatError
+		default:
+			response.Answer = append(response.Answer, &dns.A{
+				Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+				A:   net.ParseIP("203.0.113.1"),
+			})
+		}
+
+		_ = w.WriteMsg(response)
+	})
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	listener, err := net.Listen("tcp", conn.LocalAddr().String())
+	require.NoError(t, err)
+
+	udpServer := &dns.Server{PacketConn: conn, Handler: handler}
+	go func() { _ = udpServer.ActivateAndServe() }()
+	t.Cleanup(func() { _ = udpServer.Shutdown() })
+
+	tcpServer := &dns.Server{Listener: listener, Handler: handler}
+	go func() { _ = tcpServer.ActivateAndServe() }()
+	t.Cleanup(func() { _ = tcpServer.Shutdown() })
+
+	runtime, err := newConfiguredRuntime(t)
+	require.NoError(t, err)
+	require.NoError(t, runtime.VU.Runtime().Set("address", conn.LocalAddr().String()))
+
+	samples := make(chan metrics.SampleContainer, 1024)
+	runtime.MoveToVUContext(&lib.State{
+		BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+		Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+		Samples:        samples,
+	})
+
+	_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+		const options = { ednsSize: 1232, ednsFallback: true, throw: false };
+
+		let result = await dns.resolve("k6.test", "A", address, options);
+		if (result.error !== null || result.answers[0] !== "203.0.113.1" ||
+			result.server.ednsDowngrade !== "FORMERR" || result.server.protocol !== "tcp") {
+			throw new Error("unexpected result: " + JSON.stringify(result));
+		}
+
+		result = await dns.resolve("www.badvers.k6.test", "A", address, options);
+		if (result.error !== null || result.server.ednsDowngrade !== "BADVERS") {
+			throw new Error("unexpected result: " + JSON.stringify(result));
+		}
+
+		// Queries are only sent again when instructed to
+		result = await dns.resolve("k6.test", "A", address, { ednsSize: 1232, throw: false });
+		if (result.rcode !== "FORMERR" || result.server.ednsDowngrade !== "") {
+			throw new Error("unexpected result: " + JSON.stringify(result));
+		}
+
+		// Queries without EDNS are not downgraded
+		result = await dns.resolve("k6.test", "A", address, { ednsFallback: true });
+		if (result[0] !== "203.0.113.1") {
+			throw new Error("unexpected answers: " + result);
+		}
+	`))
+	require.NoError(t, err)
+
+	var reasons []string
+	for len(samples) > 0 {
+		for _, sample := range (<-samples).GetSamples() {
+			if sample.Metric.Name == "dns_edns_downgrades" {
+				reason, _ := sample.Tags.Get("reason")
+				reasons = append(reasons, reason)
+			}
+		}
+	}
+
+	assert.Equal(t, []string{"FORMERR", "BADVERS"}, reasons)
+}
+
+func Test_ednsSizes(t *testing.T) {
+	t.Parallel()
+
+	nameserver, err := parseNameserverAddr("192.0.2.53:53")
+	require.NoError(t, err)
+
+	var sizes ednsSizes
+	assert.Equal(t, uint16(4096), sizes.size(nameserver))
+
+	assert.Equal(t, uint16(1452), sizes.lower(nameserver, 4096))
+	assert.Equal(t, uint16(1452), sizes.size(nameserver))
+
+	// Concurrent attempts which timed out with the same size lower it once
+	assert.Equal(t, uint16(1452), sizes.lower(nameserver, 4096))
+
+	assert.Equal(t, uint16(1232), sizes.lower(nameserver, 1452))
+	assert.Equal(t, uint16(512), sizes.lower(nameserver, 1232))
+	assert.Equal(t, uint16(512), sizes.lower(nameserver, 512))
+
+	// Sizes are probed again from the largest one once lowered for long enough
+	sizes.paths[nameserver.Addr()] = ednsPath{size: 512, lowered: time.Now().Add(-ednsProbeInterval)}
+	assert.Equal(t, uint16(4096), sizes.size(nameserver))
+	assert.Equal(t, uint16(1452), sizes.lower(nameserver, 4096))
+}
+
+func TestModuleInstance_Resolve_negotiateEDNSSize(t *testing.T) {
+	t.Parallel()
+
+	// The path to the nameserver drops the responses to the queries advertising a
+	// UDP payload size over 1232 bytes, as it would drop fragments
+	var mu sync.Mutex
+	var advertised []uint16
+	handler := dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		size := uint16(dns.MinMsgSize)
+		if opt := req.IsEdns0(); opt != nil {
+			size = opt.UDPSize()
+		}
+
+		mu.Lock()
+		advertised = append(advertised, size)
+		mu.Unlock()
+
+		if size > 1232 {
+			return
+		}
+
+		response := new(dns.Msg)
+		response.SetReply(req)
+		response.Answer = append(response.Answer, &dns.A{
+			Hdr: dns.RR_Header{Name: req.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+			A:   net.ParseIP("203.0.113.1"),
+		})
+
+		_ = w.WriteMsg(response)
+	})
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := &dns.Server{PacketConn: conn, Handler: handler}
+	go func() { _ = server.ActivateAndServe() }()
+	t.Cleanup(func() { _ = server.Shutdown() })
+
+	runtime, err := newConfiguredRuntime(t)
+	require.NoError(t, err)
+	require.NoError(t, runtime.VU.Runtime().Set("address", conn.LocalAddr().String()))
+
+	runtime.MoveToVUContext(&lib.State{
+		BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+		Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+		Samples:        make(chan metrics.SampleContainer, 1024),
+	})
+
+	_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+		const options = { ednsSize: "auto", timeout: "100ms", retries: 3 };
+
+		for (let i = 0; i < 2; i++) {
+			const answers = await dns.resolve("k6.test", "A", address, options);
+			if (answers[0] !== "203.0.113.1") {
+				throw new Error("unexpected answers: " + answers);
+			}
+		}
+	`))
+	require.NoError(t, err)
+
+	// The size negotiated by the first query is remembered by the second one
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []uint16{4096, 1452, 1232, 1232}, advertised)
+}
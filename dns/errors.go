@@ -1,6 +1,14 @@
 package dns
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"syscall"
+
+	"github.com/miekg/dns"
+)
 
 // ErrUnsupportedRecordType is an error that is returned when a record type is not supported by
 // the module.
@@ -19,6 +27,24 @@ type Error struct {
 	//
 	// [specification]: https://www.iana.org/assignments/dns-parameters/dns-parameters.xhtml#dns-parameters-6).
 	Kind errorKind `json:"kind"`
+
+	// Rcode holds the name of the response code returned by the nameserver, e.g.
+	// "SERVFAIL". It is empty if the error did not originate from a DNS response.
+	Rcode string `json:"rcode"`
+
+	// Nameserver holds the address of the nameserver the error originated from, if any.
+	Nameserver string `json:"nameserver"`
+
+	// HTTPStatus holds the HTTP status code a DoH nameserver answered with, if the
+	// error originated from one.
+	HTTPStatus int `json:"httpStatus" js:"httpStatus"`
+
+	// HTTPHeaders holds the rate limiting headers of the DoH response the error
+	// originated from, such as Retry-After, by lowercase name.
+	HTTPHeaders map[string]string `json:"httpHeaders" js:"httpHeaders"`
+
+	// err holds the underlying error, if any.
+	err error
 }
 
 // Ensure our DNSError implements the error interface
@@ -32,16 +58,88 @@ func newDNSError(rcode int, message string) *Error {
 		Name:    kind.String(),
 		Message: message,
 		Kind:    kind,
+		Rcode:   dns.RcodeToString[rcode],
+	}
+}
+
+// newExchangeError creates a new DNSError from an error which occurred while
+// exchanging messages with a nameserver.
+//
+// If the error does not match any of the known error kinds, it is wrapped in a
+// plain error holding the message instead.
+func newExchangeError(err error, message string) error {
+	// Errors already describing their kind, such as TSIG failures, are kept as is
+	var dnsErr *Error
+	if errors.As(err, &dnsErr) {
+		return dnsErr
+	}
+
+	var kind errorKind
+	var parseErr *dns.Error
+	var statusErr *httpStatusError
+
+	switch {
+	case errors.As(err, &statusErr):
+		kind = HTTPError
+		if statusErr.status == http.StatusTooManyRequests {
+			kind = RateLimited
+		}
+
+		return &Error{
+			Name:        kind.String(),
+			Message:     message + ": " + err.Error(),
+			Kind:        kind,
+			HTTPStatus:  statusErr.status,
+			HTTPHeaders: statusErr.headers,
+			err:         err,
+		}
+	case errors.Is(err, context.Canceled):
+		kind = Aborted
+	case isTimeout(err):
+		kind = Timeout
+	case errors.Is(err, syscall.ENETUNREACH),
+		errors.Is(err, syscall.EHOSTUNREACH),
+		errors.Is(err, syscall.ECONNREFUSED):
+		kind = NetworkUnreachable
+	case errors.As(err, &parseErr):
+		kind = ParseError
+	default:
+		return fmt.Errorf("%s: %w", message, err)
+	}
+
+	return &Error{
+		Name:    kind.String(),
+		Message: message + ": " + err.Error(),
+		Kind:    kind,
+		err:     err,
 	}
 }
 
+// withNameserver attaches the nameserver's address to the error, if it is an Error.
+func withNameserver(err error, nameserver Nameserver) error {
+	var dnsErr *Error
+	if errors.As(err, &dnsErr) {
+		dnsErr.Nameserver = nameserver.Addr()
+	}
+
+	return err
+}
+
 // Error returns the error message.
 func (e *Error) Error() string {
-	return e.Kind.String() + ": " + e.Message
+	return e.Name + ": " + e.Message
+}
+
+// Unwrap returns the underlying error, if any.
+func (e *Error) Unwrap() error {
+	return e.err
 }
 
 // errorKind represents a DNS error kind, based on DNS Response Codes.
 //
+// Error kinds which do not originate from a DNS response, such as timeouts, use
+// values outside the range of the DNS Response Codes.
+//
 //go:generate enumer -type=errorKind -output errors_gen.go
 type errorKind uint8
 
@@ -160,4 +258,49 @@ const (
 	//
 	// [RFC7873]: https://www.iana.org/go/rfc7873
 	BadCookie errorKind = 23
+
+	// Timeout is a DNS error kind that represents a query which did not receive
+	// any response from the nameserver in time.
+	Timeout errorKind = 128
+
+	// NetworkUnreachable is a DNS error kind that represents a nameserver which could
+	// not be reached, e.g. because the network or host is unreachable, or because the
+	// nameserver's port is closed.
+	NetworkUnreachable errorKind = 129
+
+	// ParseError is a DNS error kind that represents a DNS message which could not be
+	// packed or unpacked, e.g. a malformed response received from the nameserver.
+	ParseError errorKind = 130
+
+	// Aborted is a DNS error kind that represents a query which was cancelled before
+	// completing, e.g. because its abort signal was triggered, or the test was stopped.
+	Aborted errorKind = 131
+
+	// BlockedHostname is a DNS error kind that represents a hostname which could not be
+	// looked up, because it matches k6's blockHostnames option.
+	BlockedHostname errorKind = 132
+
+	// BlacklistedIP is a DNS error kind that represents a hostname whose IP addresses
+	// were all filtered out, because they match k6's blacklistIPs option.
+	BlacklistedIP errorKind = 133
+
+	// CNAMELoop is a DNS error kind that represents a chain of CNAME records
+	// pointing back to a name of the chain.
+	CNAMELoop errorKind = 134
+
+	// MaxDepthExceeded is a DNS error kind that represents a chain of CNAME records
+	// longer than the maximum depth allowed.
+	MaxDepthExceeded errorKind = 135
+
+	// HTTPError is a DNS error kind that represents a DoH nameserver answering with
+	// a non-2xx HTTP status.
+	HTTPError errorKind = 136
+
+	// RateLimited is a DNS error kind that represents a DoH nameserver answering
+	// with the 429 Too Many Requests HTTP status.
+	RateLimited errorKind = 137
+
+	// NotAuthoritative is a DNS error kind that represents a response lacking the
+	// authoritative answer flag, when one was required.
+	NotAuthoritative errorKind = 138
 )
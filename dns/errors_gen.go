@@ -9,11 +9,13 @@ import (
 const (
 	_errorKindName_0 = "FormatErrorServerFailureNonExistingDomainNotImplementedRefusedYXDomainYXRrsetNXRrsetNotAuthNotZone"
 	_errorKindName_1 = "BadVersBadKeyBadTimeBadModeBadNameBadAlgBadTruncBadCookie"
+	_errorKindName_2 = "TimeoutNetworkUnreachableParseErrorAbortedBlockedHostnameBlacklistedIPCNAMELoopMaxDepthExceededHTTPErrorRateLimitedNotAuthoritative"
 )
 
 var (
 	_errorKindIndex_0 = [...]uint8{0, 11, 24, 41, 55, 62, 70, 77, 84, 91, 98}
 	_errorKindIndex_1 = [...]uint8{0, 7, 13, 20, 27, 34, 40, 48, 57}
+	_errorKindIndex_2 = [...]uint8{0, 7, 25, 35, 42, 57, 70, 79, 95, 104, 115, 131}
 )
 
 func (i errorKind) String() string {
@@ -24,32 +26,46 @@ func (i errorKind) String() string {
 	case 16 <= i && i <= 23:
 		i -= 16
 		return _errorKindName_1[_errorKindIndex_1[i]:_errorKindIndex_1[i+1]]
+	case 128 <= i && i <= 138:
+		i -= 128
+		return _errorKindName_2[_errorKindIndex_2[i]:_errorKindIndex_2[i+1]]
 	default:
 		return fmt.Sprintf("errorKind(%d)", i)
 	}
 }
 
-var _errorKindValues = []errorKind{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 16, 17, 18, 19, 20, 21, 22, 23}
+var _errorKindValues = []errorKind{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 16, 17, 18, 19, 20, 21, 22, 23, 128, 129, 130, 131, 132, 133, 134, 135, 136, 137, 138}
 
 var _errorKindNameToValueMap = map[string]errorKind{
-	_errorKindName_0[0:11]:  1,
-	_errorKindName_0[11:24]: 2,
-	_errorKindName_0[24:41]: 3,
-	_errorKindName_0[41:55]: 4,
-	_errorKindName_0[55:62]: 5,
-	_errorKindName_0[62:70]: 6,
-	_errorKindName_0[70:77]: 7,
-	_errorKindName_0[77:84]: 8,
-	_errorKindName_0[84:91]: 9,
-	_errorKindName_0[91:98]: 10,
-	_errorKindName_1[0:7]:   16,
-	_errorKindName_1[7:13]:  17,
-	_errorKindName_1[13:20]: 18,
-	_errorKindName_1[20:27]: 19,
-	_errorKindName_1[27:34]: 20,
-	_errorKindName_1[34:40]: 21,
-	_errorKindName_1[40:48]: 22,
-	_errorKindName_1[48:57]: 23,
+	_errorKindName_0[0:11]:    1,
+	_errorKindName_0[11:24]:   2,
+	_errorKindName_0[24:41]:   3,
+	_errorKindName_0[41:55]:   4,
+	_errorKindName_0[55:62]:   5,
+	_errorKindName_0[62:70]:   6,
+	_errorKindName_0[70:77]:   7,
+	_errorKindName_0[77:84]:   8,
+	_errorKindName_0[84:91]:   9,
+	_errorKindName_0[91:98]:   10,
+	_errorKindName_1[0:7]:     16,
+	_errorKindName_1[7:13]:    17,
+	_errorKindName_1[13:20]:   18,
+	_errorKindName_1[20:27]:   19,
+	_errorKindName_1[27:34]:   20,
+	_errorKindName_1[34:40]:   21,
+	_errorKindName_1[40:48]:   22,
+	_errorKindName_1[48:57]:   23,
+	_errorKindName_2[0:7]:     128,
+	_errorKindName_2[7:25]:    129,
+	_errorKindName_2[25:35]:   130,
+	_errorKindName_2[35:42]:   131,
+	_errorKindName_2[42:57]:   132,
+	_errorKindName_2[57:70]:   133,
+	_errorKindName_2[70:79]:   134,
+	_errorKindName_2[79:95]:   135,
+	_errorKindName_2[95:104]:  136,
+	_errorKindName_2[104:115]: 137,
+	_errorKindName_2[115:131]: 138,
 }
 
 // errorKindString retrieves an enum value from the enum constants string name.
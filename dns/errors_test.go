@@ -0,0 +1,148 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+)
+
+func Test_newExchangeError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		err      error
+		wantKind errorKind
+		wantDNS  bool
+	}{
+		{
+			name:     "context deadline exceeded",
+			err:      context.DeadlineExceeded,
+			wantKind: Timeout,
+			wantDNS:  true,
+		},
+		{
+			name:     "context canceled",
+			err:      context.Canceled,
+			wantKind: Aborted,
+			wantDNS:  true,
+		},
+		{
+			name:     "network read timeout",
+			err:      &net.OpError{Op: "read", Net: "udp", Err: os.ErrDeadlineExceeded},
+			wantKind: Timeout,
+			wantDNS:  true,
+		},
+		{
+			name:     "connection refused",
+			err:      &net.OpError{Op: "read", Net: "udp", Err: os.NewSyscallError("read", syscall.ECONNREFUSED)},
+			wantKind: NetworkUnreachable,
+			wantDNS:  true,
+		},
+		{
+			name:     "network unreachable",
+			err:      &net.OpError{Op: "dial", Net: "udp", Err: os.NewSyscallError("connect", syscall.ENETUNREACH)},
+			wantKind: NetworkUnreachable,
+			wantDNS:  true,
+		},
+		{
+			name:     "malformed message",
+			err:      dns.ErrShortRead,
+			wantKind: ParseError,
+			wantDNS:  true,
+		},
+		{
+			name:    "unknown error",
+			err:     errors.New("something went wrong"),
+			wantDNS: false,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := newExchangeError(tt.err, "querying failed")
+			assert.ErrorIs(t, got, tt.err)
+
+			var dnsErr *Error
+			if !assert.Equal(t, tt.wantDNS, errors.As(got, &dnsErr)) || !tt.wantDNS {
+				return
+			}
+
+			assert.Equal(t, tt.wantKind, dnsErr.Kind)
+			assert.Equal(t, tt.wantKind.String(), dnsErr.Name)
+		})
+	}
+}
+
+func TestModuleInstance_Resolve_requireAuthoritative(t *testing.T) {
+	t.Parallel()
+
+	// The nameserver is only authoritative for the names of the auth.k6.test zone
+	handler := dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		response := new(dns.Msg)
+		response.SetReply(req)
+		response.Authoritative = strings.HasSuffix(req.Question[0].Name, "auth.k6.test.")
+		response.Answer = append(response.Answer, &dns.A{
+			Hdr: dns.RR_Header{Name: req.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+			A:   net.ParseIP("203.0.113.1"),
+		})
+		_ = w.WriteMsg(response)
+	})
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := &dns.Server{PacketConn: conn, Handler: handler}
+	go func() { _ = server.ActivateAndServe() }()
+	t.Cleanup(func() { _ = server.Shutdown() })
+
+	runtime, err := newConfiguredRuntime(t)
+	require.NoError(t, err)
+	require.NoError(t, runtime.VU.Runtime().Set("address", conn.LocalAddr().String()))
+
+	runtime.MoveToVUContext(&lib.State{
+		BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+		Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+		Samples:        make(chan metrics.SampleContainer, 1024),
+	})
+
+	_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+		const answers = await dns.resolve("www.auth.k6.test", "A", address, { requireAuthoritative: true });
+		if (answers[0] !== "203.0.113.1") {
+			throw new Error("unexpected answers: " + answers);
+		}
+
+		// Responses lacking the AA flag are accepted unless required
+		await dns.resolve("www.k6.test", "A", address);
+
+		const result = await dns.resolve("www.k6.test", "A", address, { requireAuthoritative: true, throw: false });
+		if (result.error === null || result.error.name !== "NotAuthoritative" || result.error.rcode !== "NOERROR" ||
+			result.answers.length !== 0 || result.flags.aa) {
+			throw new Error("unexpected result: " + JSON.stringify(result));
+		}
+
+		try {
+			await dns.resolve("www.k6.test", "A", address, { requireAuthoritative: true });
+			throw new Error("expected the resolution to fail");
+		} catch (e) {
+			if (e.name !== "NotAuthoritative") {
+				throw e;
+			}
+		}
+	`))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,241 @@
+package dns
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/grafana/sobek"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/metrics"
+)
+
+// Expectation holds assertions about the answers of a resolution, which can be
+// chained, e.g. expect(result).toContainIP("192.0.2.1").withTTLAtLeast(60), and
+// whose outcome is reported by its pass and failures properties, suitable for
+// being used in k6 checks.
+type Expectation struct {
+	// Pass indicates whether all the assertions made so far passed.
+	Pass bool `js:"pass"`
+
+	// Failures holds a description of each assertion which failed so far.
+	Failures []string `js:"failures"`
+
+	mi *ModuleInstance
+
+	resolution
+}
+
+// resolution holds the outcome of a resolution, as passed to the expect and
+// checkGeo functions.
+type resolution struct {
+	// name and recordType hold the domain name and record type which were resolved,
+	// if known.
+	name       string
+	recordType string
+
+	// answers holds the data of the records of the resolved type.
+	answers []string
+
+	// records holds all the records of the answer section, or nil if they are unknown.
+	records []Record
+
+	// rcode holds the name of the response code, if known.
+	rcode string
+}
+
+// exportResolution exports the outcome of a resolution passed to the function, out
+// of either the array of answers resolve resolves to, a result object resolve
+// resolves to when instructed not to throw, or a message result sendMessage
+// resolves to.
+func exportResolution(rt *sobek.Runtime, value sobek.Value, function string) (resolution, error) {
+	invalid := fmt.Errorf("%s's argument must be a resolution result or an array of answers; got %v instead", function, value)
+
+	if common.IsNullish(value) {
+		return resolution{}, invalid
+	}
+
+	switch result := value.Export().(type) {
+	case *resolveResult:
+		return resolution{
+			name:       result.Name,
+			recordType: result.Type,
+			answers:    result.Answers,
+			records:    result.Records,
+			rcode:      result.Rcode,
+		}, nil
+	case *messageResult:
+		answers := make([]string, 0, len(result.Answers))
+		for _, record := range result.Answers {
+			answers = append(answers, record.Data)
+		}
+
+		return resolution{answers: answers, records: result.Answers, rcode: result.Rcode}, nil
+	default:
+		var answers []string
+		if err := rt.ExportTo(value, &answers); err != nil {
+			return resolution{}, invalid
+		}
+
+		return resolution{answers: answers}, nil
+	}
+}
+
+// Expect creates an Expectation about the answers of a resolution, out of either
+// the array of answers resolve resolves to, a result object resolve resolves to
+// when instructed not to throw, or a message result sendMessage resolves to.
+//
+// Assertions about TTLs and response codes require a result object.
+func (mi *ModuleInstance) Expect(value sobek.Value) (*Expectation, error) {
+	resolved, err := exportResolution(mi.vu.Runtime(), value, "expect")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Expectation{
+		Pass:       true,
+		Failures:   []string{},
+		mi:         mi,
+		resolution: resolved,
+	}, nil
+}
+
+// ToContainIP asserts that the answers hold the IP address.
+func (e *Expectation) ToContainIP(ip string) *Expectation {
+	expected := net.ParseIP(ip)
+
+	found := false
+	for _, answer := range e.answers {
+		if expected != nil && expected.Equal(net.ParseIP(answer)) {
+			found = true
+			break
+		}
+	}
+
+	return e.assert("toContainIP", found, "expected the answers to contain the IP address %s; got %v", ip, e.answers)
+}
+
+// ToContain asserts that the answers hold the record data, compared regardless of
+// its case and trailing dot.
+func (e *Expectation) ToContain(data string) *Expectation {
+	return e.assert(
+		"toContain",
+		answersMatch(e.answers, []string{data}, recordMatchContains),
+		"expected the answers to contain %s; got %v", data, e.answers,
+	)
+}
+
+// ToEqual asserts that the answers hold the record data and nothing else, compared
+// regardless of their order, case and trailing dot.
+func (e *Expectation) ToEqual(data []string) *Expectation {
+	return e.assert(
+		"toEqual",
+		answersMatch(e.answers, data, recordMatchExact),
+		"expected the answers to equal %v; got %v", data, e.answers,
+	)
+}
+
+// ToBeEmpty asserts that there are no answers.
+func (e *Expectation) ToBeEmpty() *Expectation {
+	return e.assert("toBeEmpty", len(e.answers) == 0, "expected no answers; got %v", e.answers)
+}
+
+// ToHaveRcode asserts that the response holds the response code, e.g. "NOERROR".
+func (e *Expectation) ToHaveRcode(rcode string) *Expectation {
+	if e.rcode == "" {
+		return e.assert("toHaveRcode", false, "expected the %s response code; the response code is unknown", rcode)
+	}
+
+	return e.assert(
+		"toHaveRcode",
+		strings.EqualFold(e.rcode, rcode),
+		"expected the %s response code; got %s", strings.ToUpper(rcode), e.rcode,
+	)
+}
+
+// WithTTLAtLeast asserts that all the records of the answer section have a TTL
+// greater than or equal to the number of seconds.
+func (e *Expectation) WithTTLAtLeast(seconds int64) *Expectation {
+	return e.assertTTL("withTTLAtLeast", func(ttl uint32) bool {
+		return int64(ttl) >= seconds
+	}, "at least %d seconds", seconds)
+}
+
+// WithTTLAtMost asserts that all the records of the answer section have a TTL
+// lower than or equal to the number of seconds.
+func (e *Expectation) WithTTLAtMost(seconds int64) *Expectation {
+	return e.assertTTL("withTTLAtMost", func(ttl uint32) bool {
+		return int64(ttl) <= seconds
+	}, "at most %d seconds", seconds)
+}
+
+// assertTTL asserts that the TTL of all the records of the answer section
+// satisfies the condition, described by the format and arguments.
+func (e *Expectation) assertTTL(assertion string, condition func(ttl uint32) bool, format string, args ...interface{}) *Expectation {
+	description := fmt.Sprintf(format, args...)
+
+	if e.records == nil {
+		return e.assert(assertion, false, "expected a TTL of %s; the TTLs are unknown, resolve with throw set to false", description)
+	}
+
+	if len(e.records) == 0 {
+		return e.assert(assertion, false, "expected a TTL of %s; got no records", description)
+	}
+
+	for _, record := range e.records {
+		if !condition(record.TTL) {
+			return e.assert(
+				assertion, false,
+				"expected a TTL of %s; got %d seconds for the %s record %s", description, record.TTL, record.Type, record.Data,
+			)
+		}
+	}
+
+	return e.assert(assertion, true, "")
+}
+
+// assert records the outcome of the assertion, described by the format and
+// arguments when it failed, and emits the answer mismatch metric.
+func (e *Expectation) assert(assertion string, passed bool, format string, args ...interface{}) *Expectation {
+	if !passed {
+		e.Pass = false
+		e.Failures = append(e.Failures, fmt.Sprintf(format, args...))
+	}
+
+	e.mi.emitAnswerMismatchMetrics(assertion, e.name, e.recordType, !passed)
+
+	return e
+}
+
+// emitAnswerMismatchMetrics emits the metric tracking whether an assertion about
+// the answers of a resolution failed.
+func (mi *ModuleInstance) emitAnswerMismatchMetrics(assertion, query, recordType string, mismatch bool) {
+	state := mi.vu.State()
+	if state == nil {
+		return
+	}
+
+	tags := state.Tags.GetCurrentValues().Tags
+	tags = tags.With("assertion", assertion)
+
+	if query != "" {
+		tags = tags.With("query", query)
+		tags = tags.With("recordType", recordType)
+	}
+
+	var value float64
+	if mismatch {
+		value = 1
+	}
+
+	metrics.PushIfNotDone(mi.vu.Context(), state.Samples, metrics.Sample{
+		TimeSeries: metrics.TimeSeries{
+			Metric: mi.metrics.DNSAnswerMismatch,
+			Tags:   tags,
+		},
+		Time:     time.Now(),
+		Value:    value,
+		Metadata: nil,
+	})
+}
@@ -0,0 +1,116 @@
+package dns
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.k6.io/k6/js/modulestest"
+)
+
+func TestExpectation(t *testing.T) {
+	t.Parallel()
+
+	newExpectation := func(t *testing.T, answers []string, records []Record) *Expectation {
+		t.Helper()
+
+		return &Expectation{
+			Pass:     true,
+			Failures: []string{},
+			mi:       &ModuleInstance{vu: modulestest.NewRuntime(t).VU},
+			resolution: resolution{
+				name:       "k6.io",
+				recordType: "A",
+				answers:    answers,
+				records:    records,
+				rcode:      "NOERROR",
+			},
+		}
+	}
+
+	records := []Record{
+		{Name: "k6.io", Type: "CNAME", TTL: 300, Data: "k6.example"},
+		{Name: "k6.example", Type: "A", TTL: 60, Data: "192.0.2.1"},
+	}
+
+	tests := []struct {
+		name         string
+		answers      []string
+		records      []Record
+		assert       func(e *Expectation) *Expectation
+		wantPass     bool
+		wantFailures int
+	}{
+		{
+			name:     "contains IP with TTL at least",
+			answers:  []string{"192.0.2.1"},
+			records:  records,
+			assert:   func(e *Expectation) *Expectation { return e.ToContainIP("192.0.2.1").WithTTLAtLeast(60) },
+			wantPass: true,
+		},
+		{
+			name:     "contains IPv6 regardless of its notation",
+			answers:  []string{"2001:db8::1"},
+			assert:   func(e *Expectation) *Expectation { return e.ToContainIP("2001:0db8:0::1") },
+			wantPass: true,
+		},
+		{
+			name:         "missing IP and TTL too low",
+			answers:      []string{"192.0.2.1"},
+			records:      records,
+			assert:       func(e *Expectation) *Expectation { return e.ToContainIP("192.0.2.2").WithTTLAtLeast(120) },
+			wantFailures: 2,
+		},
+		{
+			name:     "TTL at most",
+			answers:  []string{"192.0.2.1"},
+			records:  records,
+			assert:   func(e *Expectation) *Expectation { return e.WithTTLAtMost(300) },
+			wantPass: true,
+		},
+		{
+			name:         "unknown TTLs",
+			answers:      []string{"192.0.2.1"},
+			assert:       func(e *Expectation) *Expectation { return e.WithTTLAtLeast(60) },
+			wantFailures: 1,
+		},
+		{
+			name:    "contains and equals regardless of case and trailing dot",
+			answers: []string{"10 Mail.k6.io.", "20 backup.k6.io"},
+			assert: func(e *Expectation) *Expectation {
+				return e.ToContain("10 mail.k6.io").ToEqual([]string{"20 backup.k6.io.", "10 mail.k6.io"})
+			},
+			wantPass: true,
+		},
+		{
+			name:         "not equal",
+			answers:      []string{"192.0.2.1", "192.0.2.2"},
+			assert:       func(e *Expectation) *Expectation { return e.ToEqual([]string{"192.0.2.1"}) },
+			wantFailures: 1,
+		},
+		{
+			name:     "empty with response code",
+			answers:  []string{},
+			assert:   func(e *Expectation) *Expectation { return e.ToBeEmpty().ToHaveRcode("noerror") },
+			wantPass: true,
+		},
+		{
+			name:         "not empty",
+			answers:      []string{"192.0.2.1"},
+			assert:       func(e *Expectation) *Expectation { return e.ToBeEmpty().ToHaveRcode("NXDOMAIN") },
+			wantFailures: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := tt.assert(newExpectation(t, tt.answers, tt.records))
+
+			assert.Equal(t, tt.wantPass, got.Pass)
+			assert.Len(t, got.Failures, tt.wantFailures)
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/grafana/sobek"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/promises"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/types"
+)
+
+// defaultFastestTimeout is the maximum duration of the lookup, and of the connection
+// probe, of each address family, when no timeout is specified.
+const defaultFastestTimeout = 5 * time.Second
+
+// lookupFastestOptions holds the options that can be passed to the lookupFastest
+// function.
+type lookupFastestOptions struct {
+	// Port holds the TCP port connections are probed on, once the addresses of
+	// each family are looked up. When zero, connections are not probed.
+	Port int
+
+	// Timeout holds the maximum duration of the lookup, and of the connection
+	// probe, of each address family.
+	Timeout time.Duration
+
+	// Signal holds an AbortSignal-like object, which allows aborting the lookups.
+	Signal *sobek.Object
+}
+
+// parseLookupFastestOptions parses the options object passed to the lookupFastest
+// function.
+//
+// Undefined or null options result in the default options being returned.
+func parseLookupFastestOptions(rt *sobek.Runtime, value sobek.Value) (lookupFastestOptions, error) {
+	opts := lookupFastestOptions{Timeout: defaultFastestTimeout}
+
+	if common.IsNullish(value) {
+		return opts, nil
+	}
+
+	params := value.ToObject(rt)
+
+	if v := params.Get("port"); !common.IsNullish(v) {
+		var port int64
+		if err := rt.ExportTo(v, &port); err != nil || port < 1 || port > 65535 {
+			return opts, fmt.Errorf("port option must be an integer between 1 and 65535; got %v instead", v)
+		}
+
+		opts.Port = int(port)
+	}
+
+	if v := params.Get("timeout"); !common.IsNullish(v) {
+		timeout, err := types.GetDurationValue(v.Export())
+		if err != nil {
+			return opts, fmt.Errorf("timeout option is invalid; reason: %w", err)
+		}
+
+		if timeout <= 0 {
+			return opts, fmt.Errorf("timeout option must be a strictly positive duration; got %v instead", v)
+		}
+
+		opts.Timeout = timeout
+	}
+
+	if v := params.Get("signal"); !common.IsNullish(v) {
+		signal, ok := v.(*sobek.Object)
+		if !ok {
+			return opts, fmt.Errorf("signal option must be an AbortSignal; got %v instead", v)
+		}
+
+		opts.Signal = signal
+	}
+
+	return opts, nil
+}
+
+// familyOutcome describes how an address family of a host performed, as raced
+// by the lookupFastest function.
+type familyOutcome struct {
+	// Addresses holds the IP addresses of the family the host was looked up to.
+	Addresses []string `js:"addresses"`
+
+	// LookupRTT holds the duration of the lookup, in milliseconds.
+	LookupRTT float64 `js:"lookupRtt"`
+
+	// ConnectRTT holds the duration of the connection probe, in milliseconds, or
+	// is nil if connections were not probed, or the probe failed.
+	ConnectRTT *float64 `js:"connectRtt"`
+
+	// Error holds the error the lookup, or the connection probe, failed with, if any.
+	Error *Error `js:"error"`
+
+	// duration holds the total duration of the lookup and the connection probe.
+	duration time.Duration
+}
+
+// lookupFastestResult is the object the lookupFastest function resolves to.
+type lookupFastestResult struct {
+	// Family holds the address family which worked fastest, either "ipv4" or "ipv6".
+	Family string `js:"family"`
+
+	// Address holds the first address of the fastest family, which connections
+	// were probed on, if they were.
+	Address string `js:"address"`
+
+	// IPv4 and IPv6 describe how each address family performed.
+	IPv4 *familyOutcome `js:"ipv4"`
+	IPv6 *familyOutcome `js:"ipv6"`
+}
+
+// raceFamily looks up the addresses of the family of the host, and probes a TCP
+// connection to the first one if opts.Port is set, measuring how long both took.
+func (mi *ModuleInstance) raceFamily(
+	ctx context.Context,
+	lookuper Lookuper,
+	dialer lib.DialContexter,
+	hostname string,
+	family AddressFamily,
+	opts lookupFastestOptions,
+) *familyOutcome {
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	outcome := &familyOutcome{Addresses: []string{}}
+
+	lookupStartTime := time.Now()
+	addresses, err := lookuper.Lookup(ctx, hostname, LookupOptions{Family: family})
+	lookupDuration := time.Since(lookupStartTime)
+
+	outcome.LookupRTT = float64(lookupDuration) / float64(time.Millisecond)
+	outcome.duration = lookupDuration
+
+	mi.emitLookupMetrics(mi.vu.Context(), lookupDuration.Milliseconds(), hostname, err)
+
+	if err == nil && len(addresses) == 0 {
+		err = fmt.Errorf("lookup of %s found no %s address", hostname, family.network())
+	}
+
+	if err != nil {
+		outcome.Error = asError(err)
+		return outcome
+	}
+
+	outcome.Addresses = addresses
+
+	if opts.Port == 0 {
+		return outcome
+	}
+
+	connectStartTime := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(addresses[0], strconv.Itoa(opts.Port)))
+	connectDuration := time.Since(connectStartTime)
+	if err != nil {
+		outcome.Error = asError(fmt.Errorf("connecting to %s failed: %w", addresses[0], err))
+		return outcome
+	}
+	_ = conn.Close()
+
+	connectRTT := float64(connectDuration) / float64(time.Millisecond)
+	outcome.ConnectRTT = &connectRTT
+	outcome.duration += connectDuration
+
+	return outcome
+}
+
+// LookupFastest races the lookups of the IPv4 and IPv6 addresses of a host, along
+// with TCP connections to them if a port is provided, in the spirit of the Happy
+// Eyeballs algorithm, and resolves to the address family which worked fastest.
+func (mi *ModuleInstance) LookupFastest(hostname, options sobek.Value) *sobek.Promise {
+	promise, resolve, reject := promises.New(mi.vu)
+
+	if mi.vu.State() == nil {
+		reject(errors.New("lookupFastest can not be used in the init context"))
+		return promise
+	}
+
+	hostnameStr, err := exportDomainName(mi.vu.Runtime(), hostname, "hostname")
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	opts, err := parseLookupFastestOptions(mi.vu.Runtime(), options)
+	if err != nil {
+		reject(fmt.Errorf("invalid lookupFastest options: %w", err))
+		return promise
+	}
+
+	// Lookups and connections are subject to the same network restrictions as
+	// the rest of k6
+	lookuper := newRestrictedLookuper(mi.dnsClient, mi.vu.State().Options)
+
+	var dialer lib.DialContexter = &net.Dialer{}
+	if state := mi.vu.State(); state.Dialer != nil {
+		dialer = state.Dialer
+	}
+
+	ctx, cancel, err := mi.withAbortSignal(opts.Signal)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer cancel()
+
+		ipv4 := make(chan *familyOutcome, 1)
+		go func() { ipv4 <- mi.raceFamily(ctx, lookuper, dialer, hostnameStr, AddressFamilyIPv4, opts) }()
+
+		result := &lookupFastestResult{
+			IPv6: mi.raceFamily(ctx, lookuper, dialer, hostnameStr, AddressFamilyIPv6, opts),
+			IPv4: <-ipv4,
+		}
+
+		// As per the Happy Eyeballs algorithm, IPv6 is preferred when both families
+		// worked equally fast
+		switch {
+		case result.IPv6.Error == nil && (result.IPv4.Error != nil || result.IPv6.duration <= result.IPv4.duration):
+			result.Family = "ipv6"
+			result.Address = result.IPv6.Addresses[0]
+		case result.IPv4.Error == nil:
+			result.Family = "ipv4"
+			result.Address = result.IPv4.Addresses[0]
+		default:
+			reject(fmt.Errorf(
+				"none of the address families of %s worked: %w",
+				hostnameStr,
+				errors.Join(result.IPv4.Error, result.IPv6.Error),
+			))
+			return
+		}
+
+		resolve(result)
+	}()
+
+	return promise
+}
@@ -0,0 +1,100 @@
+package dns
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+)
+
+func TestModuleInstance_LookupFastest(t *testing.T) {
+	t.Parallel()
+
+	// The service is only reachable over IPv4
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			_ = conn.Close()
+		}
+	}()
+
+	tests := []struct {
+		name    string
+		script  string
+		wantErr string
+	}{
+		{
+			name: "The family connections could be established over should win",
+			script: `
+				const result = await dns.lookupFastest("localhost", { port: port });
+				if (result.family !== "ipv4" || result.address !== "127.0.0.1") {
+					throw new Error("unexpected result: " + JSON.stringify(result));
+				}
+
+				if (result.ipv4.error !== null || result.ipv4.connectRtt === null || result.ipv4.lookupRtt < 0) {
+					throw new Error("unexpected IPv4 outcome: " + JSON.stringify(result.ipv4));
+				}
+
+				if (result.ipv6.error === null || result.ipv6.connectRtt !== null) {
+					throw new Error("unexpected IPv6 outcome: " + JSON.stringify(result.ipv6));
+				}
+			`,
+		},
+		{
+			name: "Connections should not be probed without a port",
+			script: `
+				const result = await dns.lookupFastest("localhost");
+				if (!result.ipv4.addresses.includes("127.0.0.1") || result.ipv4.connectRtt !== null) {
+					throw new Error("unexpected IPv4 outcome: " + JSON.stringify(result.ipv4));
+				}
+			`,
+		},
+		{
+			name:    "Hosts which could not be looked up should fail",
+			script:  `await dns.lookupFastest("missing.invalid");`,
+			wantErr: "none of the address families of missing.invalid worked",
+		},
+		{
+			name:    "Invalid ports should fail",
+			script:  `await dns.lookupFastest("localhost", { port: 0 });`,
+			wantErr: "port option must be an integer between 1 and 65535",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			runtime, err := newConfiguredRuntime(t)
+			require.NoError(t, err)
+			require.NoError(t, runtime.VU.Runtime().Set("port", listener.Addr().(*net.TCPAddr).Port))
+
+			runtime.MoveToVUContext(&lib.State{
+				BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+				Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+				Samples:        make(chan metrics.SampleContainer, 1024),
+			})
+
+			_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(tt.script))
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package dns
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/grafana/sobek"
+	"github.com/miekg/dns"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/lib/types"
+)
+
+// Latency distributions the jitter of a Server's answers can be drawn from.
+const (
+	distributionUniform     = "uniform"
+	distributionNormal      = "normal"
+	distributionExponential = "exponential"
+)
+
+// serverFaults holds the faults a Server injects into its answers, allowing the
+// retry and backoff logic of clients to be validated.
+type serverFaults struct {
+	// Delay holds the duration the server waits for before answering each query.
+	Delay time.Duration
+
+	// Jitter holds the scale of the random duration added to Delay, as drawn from
+	// Distribution: the upper bound of the uniform distribution, the standard
+	// deviation of the normal distribution, or the mean of the exponential one.
+	Jitter time.Duration
+
+	// Distribution holds the distribution the jitter is drawn from.
+	Distribution string
+
+	// DropRate holds the probability, between 0 and 1, for a query to be left
+	// unanswered.
+	DropRate float64
+
+	// Truncate indicates whether responses sent over UDP should be truncated, that
+	// is, stripped of their records and with the TC flag set.
+	Truncate bool
+
+	// Rcode holds the response code to answer queries with regardless of the
+	// records, or a negative value if there is none.
+	Rcode int
+
+	// RcodeRate holds the probability, between 0 and 1, for a query to be answered
+	// with Rcode.
+	RcodeRate float64
+}
+
+// noServerFaults returns the serverFaults injecting no faults.
+func noServerFaults() serverFaults {
+	return serverFaults{Distribution: distributionUniform, Rcode: -1, RcodeRate: 1}
+}
+
+// parseServerFaults parses the fault properties of the object passed to the
+// startServer function, or to a server's setFaults method.
+func parseServerFaults(rt *sobek.Runtime, params *sobek.Object) (serverFaults, error) {
+	faults := noServerFaults()
+
+	for _, duration := range []struct {
+		option string
+		value  *time.Duration
+	}{
+		{option: "delay", value: &faults.Delay},
+		{option: "jitter", value: &faults.Jitter},
+	} {
+		v := params.Get(duration.option)
+		if common.IsNullish(v) {
+			continue
+		}
+
+		d, err := types.GetDurationValue(v.Export())
+		if err != nil {
+			return faults, fmt.Errorf("%s option is invalid; reason: %w", duration.option, err)
+		}
+
+		if d < 0 {
+			return faults, fmt.Errorf("%s option must be a positive duration; got %v instead", duration.option, v)
+		}
+
+		*duration.value = d
+	}
+
+	if v := params.Get("distribution"); !common.IsNullish(v) {
+		switch distribution := strings.ToLower(v.String()); distribution {
+		case distributionUniform, distributionNormal, distributionExponential:
+			faults.Distribution = distribution
+		default:
+			return faults, fmt.Errorf(
+				"distribution option must be one of 'uniform', 'normal' or 'exponential'; got %v instead", v,
+			)
+		}
+	}
+
+	for _, rate := range []struct {
+		option string
+		value  *float64
+	}{
+		{option: "dropRate", value: &faults.DropRate},
+		{option: "rcodeRate", value: &faults.RcodeRate},
+	} {
+		v := params.Get(rate.option)
+		if common.IsNullish(v) {
+			continue
+		}
+
+		var r float64
+		if err := rt.ExportTo(v, &r); err != nil || math.IsNaN(r) || r < 0 || r > 1 {
+			return faults, fmt.Errorf("%s option must be a number between 0 and 1; got %v instead", rate.option, v)
+		}
+
+		*rate.value = r
+	}
+
+	if v := params.Get("truncate"); !common.IsNullish(v) {
+		faults.Truncate = v.ToBoolean()
+	}
+
+	if v := params.Get("rcode"); !common.IsNullish(v) {
+		rcode, ok := dns.StringToRcode[strings.ToUpper(v.String())]
+		if !ok {
+			return faults, fmt.Errorf("rcode option must be a response code; got %v instead", v)
+		}
+
+		faults.Rcode = rcode
+	}
+
+	return faults, nil
+}
+
+// SetFaults replaces the faults the server injects into its answers, taking the
+// same properties as the options of the startServer function. Passing no faults
+// stops injecting any.
+func (s *Server) SetFaults(faults sobek.Value) (*Server, error) {
+	parsed := noServerFaults()
+
+	if !common.IsNullish(faults) {
+		var err error
+		if parsed, err = parseServerFaults(s.rt, faults.ToObject(s.rt)); err != nil {
+			return nil, fmt.Errorf("invalid faults: %w", err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = parsed
+
+	return s, nil
+}
+
+// injectFaults injects the server's faults into the response, to be sent over
+// UDP or not. It returns whether the response should be dropped, and otherwise
+// the duration to wait for before sending it.
+func (s *Server) injectFaults(response *dns.Msg, udp bool) (bool, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	faults := s.faults
+
+	if faults.DropRate > 0 && s.rng.Float64() < faults.DropRate {
+		return true, 0
+	}
+
+	if faults.Rcode >= 0 && s.rng.Float64() < faults.RcodeRate {
+		response.Rcode = faults.Rcode
+		response.Answer, response.Ns, response.Extra = nil, nil, nil
+	}
+
+	if faults.Truncate && udp {
+		response.Truncated = true
+		response.Answer, response.Ns, response.Extra = nil, nil, nil
+	}
+
+	delay := faults.Delay
+	if faults.Jitter > 0 {
+		var jitter float64
+
+		switch faults.Distribution {
+		case distributionNormal:
+			jitter = math.Abs(s.rng.NormFloat64())
+		case distributionExponential:
+			jitter = s.rng.ExpFloat64()
+		default:
+			jitter = s.rng.Float64()
+		}
+
+		delay += time.Duration(jitter * float64(faults.Jitter))
+	}
+
+	return false, delay
+}
+
+// isUDP returns whether the address is the address of a UDP peer.
+func isUDP(addr net.Addr) bool {
+	_, ok := addr.(*net.UDPAddr)
+	return ok
+}
@@ -0,0 +1,189 @@
+package dns
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/grafana/sobek"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseServerFaults(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		faults  string
+		want    func(faults *serverFaults)
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "no faults",
+			faults:  `({})`,
+			want:    func(*serverFaults) {},
+			wantErr: assert.NoError,
+		},
+		{
+			name:   "latency distribution",
+			faults: `({delay: "10ms", jitter: 5, distribution: "Exponential"})`,
+			want: func(faults *serverFaults) {
+				faults.Delay = 10 * time.Millisecond
+				faults.Jitter = 5 * time.Millisecond
+				faults.Distribution = distributionExponential
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:   "drops, truncation and response code",
+			faults: `({dropRate: 0.25, truncate: true, rcode: "servfail", rcodeRate: 0.5})`,
+			want: func(faults *serverFaults) {
+				faults.DropRate = 0.25
+				faults.Truncate = true
+				faults.Rcode = dns.RcodeServerFailure
+				faults.RcodeRate = 0.5
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "negative jitter",
+			faults:  `({jitter: -1})`,
+			wantErr: assert.Error,
+		},
+		{
+			name:    "unknown distribution",
+			faults:  `({jitter: 1, distribution: "pareto"})`,
+			wantErr: assert.Error,
+		},
+		{
+			name:    "drop rate out of range",
+			faults:  `({dropRate: 1.5})`,
+			wantErr: assert.Error,
+		},
+		{
+			name:    "unknown response code",
+			faults:  `({rcode: "OOPS"})`,
+			wantErr: assert.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rt := sobek.New()
+			value, err := rt.RunString(tt.faults)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := parseServerFaults(rt, value.ToObject(rt))
+			if !tt.wantErr(t, err, fmt.Sprintf("parseServerFaults(%v)", tt.faults)) {
+				return
+			}
+
+			if err == nil {
+				want := noServerFaults()
+				tt.want(&want)
+
+				assert.Equalf(t, want, got, "parseServerFaults(%v)", tt.faults)
+			}
+		})
+	}
+}
+
+func TestServer_injectFaults(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		faults        func(faults *serverFaults)
+		udp           bool
+		wantDrop      bool
+		wantRcode     int
+		wantTruncated bool
+		wantAnswers   int
+		wantDelay     func(t *testing.T, delay time.Duration)
+	}{
+		{
+			name:        "no faults",
+			faults:      func(*serverFaults) {},
+			udp:         true,
+			wantAnswers: 1,
+		},
+		{
+			name:     "dropped",
+			faults:   func(faults *serverFaults) { faults.DropRate = 1 },
+			udp:      true,
+			wantDrop: true,
+		},
+		{
+			name:      "response code override",
+			faults:    func(faults *serverFaults) { faults.Rcode = dns.RcodeServerFailure },
+			wantRcode: dns.RcodeServerFailure,
+		},
+		{
+			name:          "truncated over UDP",
+			faults:        func(faults *serverFaults) { faults.Truncate = true },
+			udp:           true,
+			wantTruncated: true,
+		},
+		{
+			name:        "not truncated over TCP",
+			faults:      func(faults *serverFaults) { faults.Truncate = true },
+			wantAnswers: 1,
+		},
+		{
+			name: "uniform jitter",
+			faults: func(faults *serverFaults) {
+				faults.Delay = 10 * time.Millisecond
+				faults.Jitter = 5 * time.Millisecond
+			},
+			wantAnswers: 1,
+			wantDelay: func(t *testing.T, delay time.Duration) {
+				t.Helper()
+
+				assert.GreaterOrEqual(t, delay, 10*time.Millisecond)
+				assert.Less(t, delay, 15*time.Millisecond)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			faults := noServerFaults()
+			tt.faults(&faults)
+
+			server := newServer(faults, 1)
+
+			req := new(dns.Msg)
+			req.SetQuestion("k6.test.", dns.TypeA)
+
+			response := new(dns.Msg)
+			response.SetReply(req)
+			response.Answer = []dns.RR{&dns.A{Hdr: dns.RR_Header{Name: "k6.test.", Rrtype: dns.TypeA, Class: dns.ClassINET}}}
+
+			drop, delay := server.injectFaults(response, tt.udp)
+
+			assert.Equal(t, tt.wantDrop, drop)
+			if drop {
+				return
+			}
+
+			assert.Equal(t, tt.wantRcode, response.Rcode)
+			assert.Equal(t, tt.wantTruncated, response.Truncated)
+			assert.Len(t, response.Answer, tt.wantAnswers)
+
+			if tt.wantDelay != nil {
+				tt.wantDelay(t, delay)
+			}
+		})
+	}
+}
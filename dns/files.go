@@ -0,0 +1,76 @@
+package dns
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"go.k6.io/k6/lib/fsext"
+)
+
+// sharedFiles holds what was parsed out of files read in the init context, by
+// key, so that each file is read and parsed once, and shared, read-only, by all
+// the VUs.
+type sharedFiles[T any] struct {
+	mu     sync.Mutex
+	parsed map[string]T
+}
+
+// newSharedFiles creates an empty sharedFiles.
+func newSharedFiles[T any]() *sharedFiles[T] {
+	return &sharedFiles[T]{parsed: make(map[string]T)}
+}
+
+// load returns what was parsed out of the file of the key, reading it with read
+// and parsing it with parse if it was not already loaded.
+func (f *sharedFiles[T]) load(key string, read func() ([]byte, error), parse func(data []byte) (T, error)) (T, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if parsed, ok := f.parsed[key]; ok {
+		return parsed, nil
+	}
+
+	var zero T
+
+	data, err := read()
+	if err != nil {
+		return zero, err
+	}
+
+	parsed, err := parse(data)
+	if err != nil {
+		return zero, err
+	}
+
+	f.parsed[key] = parsed
+
+	return parsed, nil
+}
+
+// initFile returns the absolute path of the file at the path, relative to the
+// script, along with a function reading it, as long as it is called in the init
+// context by the function of the name.
+func (mi *ModuleInstance) initFile(function, path string) (string, func() ([]byte, error), error) {
+	initEnv := mi.vu.InitEnv()
+	if initEnv == nil {
+		return "", nil, fmt.Errorf("%s can only be used in the init context", function)
+	}
+
+	if path == "" {
+		return "", nil, errors.New("path argument must be provided")
+	}
+
+	path = initEnv.GetAbsFilePath(path)
+
+	read := func() ([]byte, error) {
+		data, err := fsext.ReadFile(initEnv.FileSystems["file"], path)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s failed; reason: %w", path, err)
+		}
+
+		return data, nil
+	}
+
+	return path, read, nil
+}
@@ -0,0 +1,560 @@
+package dns
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/grafana/sobek"
+	"github.com/miekg/dns"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/promises"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/types"
+)
+
+// Outcomes of a malformed message sent by the fuzz function.
+const (
+	// fuzzOutcomeResponse marks a message the nameserver answered with a valid
+	// response, e.g. a FORMERR one.
+	fuzzOutcomeResponse = "response"
+
+	// fuzzOutcomeMalformed marks a message the nameserver answered with a response
+	// which could not be unpacked.
+	fuzzOutcomeMalformed = "malformed"
+
+	// fuzzOutcomeTimeout marks a message the nameserver did not answer in time,
+	// which is how many nameservers deal with messages they can not parse.
+	fuzzOutcomeTimeout = "timeout"
+
+	// fuzzOutcomeError marks a message whose exchange failed otherwise, e.g.
+	// because the nameserver closed the connection or is unreachable.
+	fuzzOutcomeError = "error"
+)
+
+// fuzzCase describes a malformed message the fuzz function sends.
+type fuzzCase struct {
+	// name identifies the case in the options and results.
+	name string
+
+	// description describes how the message is malformed.
+	description string
+
+	// build returns the malformed message, given a valid packed query.
+	build func(query []byte) []byte
+}
+
+// dnsHeaderSize is the size of the header of DNS messages, in bytes.
+const dnsHeaderSize = 12
+
+// fuzzCases lists the malformed messages the fuzz function sends, in order.
+var fuzzCases = []fuzzCase{ //nolint:gochecknoglobals
+	{
+		name:        "emptyMessage",
+		description: "a message holding no bytes at all",
+		build:       func([]byte) []byte { return []byte{} },
+	},
+	{
+		name:        "truncatedHeader",
+		description: "a message cut in the middle of its header",
+		build:       func(query []byte) []byte { return cloneBytes(query[:dnsHeaderSize/2]) },
+	},
+	{
+		name:        "missingQuestion",
+		description: "a header announcing a question the message does not hold",
+		build:       func(query []byte) []byte { return cloneBytes(query[:dnsHeaderSize]) },
+	},
+	{
+		name:        "truncatedQuestion",
+		description: "a question cut before its class",
+		build:       func(query []byte) []byte { return cloneBytes(query[:len(query)-2]) },
+	},
+	{
+		name:        "questionCountOverflow",
+		description: "a header announcing 65535 questions, while the message holds one",
+		build:       func(query []byte) []byte { return withCount(query, 4, 0xFFFF) },
+	},
+	{
+		name:        "answerCountOverflow",
+		description: "a header announcing 65535 answers, while the message holds none",
+		build:       func(query []byte) []byte { return withCount(query, 6, 0xFFFF) },
+	},
+	{
+		name:        "labelLengthOverflow",
+		description: "a label whose length runs past the end of the message",
+		build: func(query []byte) []byte {
+			message := cloneBytes(query[:len(query)-4])
+			message[dnsHeaderSize] = 63
+
+			return message
+		},
+	},
+	{
+		name:        "reservedLabelType",
+		description: "a label whose length uses the reserved 0b01 extended label type",
+		build: func(query []byte) []byte {
+			message := cloneBytes(query)
+			message[dnsHeaderSize] |= 0x40
+
+			return message
+		},
+	},
+	{
+		name:        "compressionLoop",
+		description: "a name made of a compression pointer pointing to itself",
+		build: func(query []byte) []byte {
+			return withQuestionName(query, []byte{0xC0, dnsHeaderSize})
+		},
+	},
+	{
+		name:        "compressionOutOfBounds",
+		description: "a name made of a compression pointer pointing past the end of the message",
+		build: func(query []byte) []byte {
+			return withQuestionName(query, []byte{0xFF, 0xFF})
+		},
+	},
+	{
+		name:        "nameTooLong",
+		description: "a name of more than 255 octets, made of five 63-octet labels",
+		build: func(query []byte) []byte {
+			label := append([]byte{63}, []byte(strings.Repeat("a", 63))...)
+
+			var name []byte
+			for i := 0; i < 5; i++ {
+				name = append(name, label...)
+			}
+
+			return withQuestionName(query, append(name, 0))
+		},
+	},
+	{
+		name:        "truncatedRdata",
+		description: "an OPT record whose RDATA length runs past the end of the message",
+		build: func(query []byte) []byte {
+			message := withCount(query, 10, 1)
+
+			// Root name, OPT type, 1232 bytes UDP payload, no extended flags, and 100
+			// bytes of announced RDATA out of the 4 which follow
+			message = append(message, 0, 0, 41, 0x04, 0xD0, 0, 0, 0, 0, 0, 100)
+
+			return append(message, 0, 10, 0, 8)
+		},
+	},
+	{
+		name:        "trailingGarbage",
+		description: "a valid query followed by 32 random bytes",
+		build: func(query []byte) []byte {
+			return append(cloneBytes(query), randomBytes(32)...)
+		},
+	},
+	{
+		name:        "randomQuestion",
+		description: "a header announcing a question, followed by 32 random bytes",
+		build: func(query []byte) []byte {
+			return append(cloneBytes(query[:dnsHeaderSize]), randomBytes(32)...)
+		},
+	},
+}
+
+// cloneBytes returns a copy of the bytes, so that cases do not alter the query.
+func cloneBytes(b []byte) []byte {
+	return append([]byte{}, b...)
+}
+
+// withCount returns a copy of the message whose header count at the offset, such
+// as the question count at offset 4, is set to the count.
+func withCount(query []byte, offset int, count uint16) []byte {
+	message := cloneBytes(query)
+	binary.BigEndian.PutUint16(message[offset:], count)
+
+	return message
+}
+
+// withQuestionName returns a copy of the query whose question name is replaced
+// by the wire format name.
+func withQuestionName(query, name []byte) []byte {
+	message := cloneBytes(query[:dnsHeaderSize])
+	message = append(message, name...)
+
+	return append(message, query[len(query)-4:]...)
+}
+
+// randomBytes returns n random bytes.
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+
+	return b
+}
+
+// fuzzOptions holds the options that can be passed to the fuzz function.
+type fuzzOptions struct {
+	// Query holds the name of the valid query the malformed messages derive from,
+	// and which checks whether the nameserver is still alive.
+	Query string
+
+	// Type holds the record type of the valid query.
+	Type uint16
+
+	// Cases holds the cases to send, in order.
+	Cases []fuzzCase
+
+	// Protocol holds the protocol the messages are sent over, udp or tcp.
+	Protocol string
+
+	// Timeout holds the duration after which a message is considered unanswered.
+	Timeout time.Duration
+
+	// Signal holds an AbortSignal-like object, which allows aborting the fuzzing.
+	Signal *sobek.Object
+}
+
+// parseFuzzOptions parses the options object passed to the fuzz function.
+func parseFuzzOptions(rt *sobek.Runtime, value sobek.Value) (fuzzOptions, error) {
+	opts := fuzzOptions{
+		Query:    ".",
+		Type:     dns.TypeNS,
+		Cases:    fuzzCases,
+		Protocol: protocolUDP,
+		Timeout:  defaultAttemptTimeout,
+	}
+
+	if common.IsNullish(value) {
+		return opts, nil
+	}
+
+	params := value.ToObject(rt)
+
+	if v := params.Get("query"); !common.IsNullish(v) {
+		name, err := exportDomainName(rt, v, "query option")
+		if err != nil {
+			return opts, err
+		}
+
+		opts.Query = name
+	}
+
+	if v := params.Get("type"); !common.IsNullish(v) {
+		recordType, err := RecordTypeString(v.String())
+		if err != nil {
+			return opts, fmt.Errorf("%w, %s is an invalid DNS record type", ErrUnsupportedRecordType, v)
+		}
+
+		opts.Type = uint16(recordType)
+	}
+
+	if v := params.Get("cases"); !common.IsNullish(v) {
+		var names []string
+		if err := rt.ExportTo(v, &names); err != nil || len(names) == 0 {
+			return opts, fmt.Errorf("cases option must be a non-empty array of case names; got %v instead", v)
+		}
+
+		opts.Cases = make([]fuzzCase, 0, len(names))
+		for _, name := range names {
+			c, ok := findFuzzCase(name)
+			if !ok {
+				return opts, fmt.Errorf("cases option holds unknown case %q", name)
+			}
+
+			opts.Cases = append(opts.Cases, c)
+		}
+	}
+
+	if v := params.Get("protocol"); !common.IsNullish(v) {
+		switch protocol := strings.ToLower(v.String()); protocol {
+		case protocolUDP, protocolTCP:
+			opts.Protocol = protocol
+		default:
+			return opts, fmt.Errorf("protocol option must be one of 'udp' or 'tcp'; got %v instead", v)
+		}
+	}
+
+	if v := params.Get("timeout"); !common.IsNullish(v) {
+		timeout, err := types.GetDurationValue(v.Export())
+		if err != nil {
+			return opts, fmt.Errorf("timeout option is invalid; reason: %w", err)
+		}
+
+		if timeout <= 0 {
+			return opts, fmt.Errorf("timeout option must be a strictly positive duration; got %v instead", v)
+		}
+
+		opts.Timeout = timeout
+	}
+
+	if v := params.Get("signal"); !common.IsNullish(v) {
+		signal, ok := v.(*sobek.Object)
+		if !ok {
+			return opts, fmt.Errorf("signal option must be an AbortSignal; got %v instead", v)
+		}
+
+		opts.Signal = signal
+	}
+
+	return opts, nil
+}
+
+// findFuzzCase returns the case of the name, if any.
+func findFuzzCase(name string) (fuzzCase, bool) {
+	for _, c := range fuzzCases {
+		if c.name == name {
+			return c, true
+		}
+	}
+
+	return fuzzCase{}, false
+}
+
+// fuzzResult is the object the fuzz function resolves to.
+type fuzzResult struct {
+	// Nameserver holds the address of the nameserver the messages were sent to.
+	Nameserver string `js:"nameserver"`
+
+	// Protocol holds the protocol the messages were sent over.
+	Protocol string `js:"protocol"`
+
+	// Alive indicates whether the nameserver still answered a valid query after
+	// the last malformed message.
+	Alive bool `js:"alive"`
+
+	// Cases holds the behavior of the nameserver for each malformed message, in the
+	// order they were sent.
+	Cases []*fuzzCaseResult `js:"cases"`
+}
+
+// fuzzCaseResult describes the behavior of the nameserver for a malformed message.
+type fuzzCaseResult struct {
+	// Case holds the name of the case.
+	Case string `js:"case"`
+
+	// Description describes how the message was malformed.
+	Description string `js:"description"`
+
+	// Size holds the size of the malformed message, in bytes.
+	Size int `js:"size"`
+
+	// Outcome holds how the nameserver reacted to the message, one of "response",
+	// "malformed", "timeout" or "error".
+	Outcome string `js:"outcome"`
+
+	// Rcode holds the name of the response code of the response, if any.
+	Rcode string `js:"rcode"`
+
+	// RTT holds the duration until the response, or until the exchange failed, in
+	// milliseconds.
+	RTT float64 `js:"rtt"`
+
+	// Alive indicates whether the nameserver answered a valid query sent right
+	// after the malformed message.
+	Alive bool `js:"alive"`
+
+	// Error holds the error the exchange failed with, if any.
+	Error *Error `js:"error"`
+}
+
+// Fuzz sends systematically malformed DNS messages to a nameserver, and resolves
+// to how it reacted to each of them, along with whether it kept answering valid
+// queries, for robustness testing of the DNS servers one operates.
+//
+// The promise is only rejected when the options are invalid, or when the fuzzing
+// is aborted.
+func (mi *ModuleInstance) Fuzz(nameserverAddr, options sobek.Value) *sobek.Promise {
+	promise, resolve, reject := promises.New(mi.vu)
+
+	if mi.vu.State() == nil {
+		reject(errors.New("fuzz can not be used in the init context"))
+		return promise
+	}
+
+	nameserver, err := mi.exportNameserver(nameserverAddr)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	opts, err := parseFuzzOptions(mi.vu.Runtime(), options)
+	if err != nil {
+		reject(fmt.Errorf("invalid fuzz options: %w", err))
+		return promise
+	}
+
+	// Connect using k6's dialer, so that the network restrictions of the test apply
+	var dialer lib.DialContexter = &net.Dialer{}
+	if state := mi.vu.State(); state.Dialer != nil {
+		dialer = state.Dialer
+	}
+
+	ctx, cancel, err := mi.withAbortSignal(opts.Signal)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer cancel()
+
+		result, err := fuzz(ctx, dialer, nameserver, opts)
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(result)
+	}()
+
+	return promise
+}
+
+// fuzz sends the malformed messages of each case to the nameserver in turn, each
+// followed by a valid query checking whether the nameserver is still alive.
+func fuzz(ctx context.Context, dialer lib.DialContexter, nameserver Nameserver, opts fuzzOptions) (*fuzzResult, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(opts.Query), opts.Type)
+
+	query, err := msg.Pack()
+	if err != nil {
+		return nil, fmt.Errorf("packing the query for %s failed; reason: %w", opts.Query, err)
+	}
+
+	result := &fuzzResult{
+		Nameserver: nameserver.Addr(),
+		Protocol:   opts.Protocol,
+		Alive:      true,
+		Cases:      make([]*fuzzCaseResult, 0, len(opts.Cases)),
+	}
+
+	for _, c := range opts.Cases {
+		message := c.build(query)
+
+		caseResult := &fuzzCaseResult{
+			Case:        c.name,
+			Description: c.description,
+			Size:        len(message),
+		}
+
+		start := time.Now()
+		response, err := exchangeRaw(ctx, dialer, nameserver, opts.Protocol, message, opts.Timeout)
+		caseResult.RTT = float64(time.Since(start)) / float64(time.Millisecond)
+
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		caseResult.Outcome, caseResult.Rcode = classifyFuzzResponse(response, err)
+		if err != nil {
+			caseResult.Error = asError(withNameserver(newExchangeError(err, "sending the malformed message failed"), nameserver))
+		}
+
+		// Each query gets its own ID, so that late responses to the malformed
+		// message are told apart
+		msg.Id = dns.Id()
+		check, _ := msg.Pack()
+
+		response, err = exchangeRaw(ctx, dialer, nameserver, opts.Protocol, check, opts.Timeout)
+		caseResult.Alive = err == nil && isResponseTo(response, msg.Id)
+
+		result.Alive = caseResult.Alive
+		result.Cases = append(result.Cases, caseResult)
+	}
+
+	return result, nil
+}
+
+// classifyFuzzResponse returns the outcome of the exchange of a malformed
+// message, and the name of the response code of its response, if any.
+func classifyFuzzResponse(response []byte, err error) (string, string) {
+	switch {
+	case isTimeout(err):
+		return fuzzOutcomeTimeout, ""
+	case err != nil:
+		return fuzzOutcomeError, ""
+	}
+
+	reply := new(dns.Msg)
+	if err := reply.Unpack(response); err != nil {
+		// The response code can still be read from a complete header
+		if len(response) >= dnsHeaderSize {
+			return fuzzOutcomeMalformed, dns.RcodeToString[int(response[3]&0x0F)]
+		}
+
+		return fuzzOutcomeMalformed, ""
+	}
+
+	return fuzzOutcomeResponse, dns.RcodeToString[reply.Rcode]
+}
+
+// isResponseTo returns whether the message is a response to the query of the ID.
+func isResponseTo(response []byte, id uint16) bool {
+	reply := new(dns.Msg)
+	return reply.Unpack(response) == nil && reply.Response && reply.Id == id
+}
+
+// exchangeRaw sends the raw message to the nameserver over the protocol, and
+// returns the first message it answers with, before the timeout.
+//
+// Over TCP, the message is framed with its length, as usual, and sent over a new
+// connection, so that the connection state left by a message does not affect the
+// next ones.
+func exchangeRaw(
+	ctx context.Context,
+	dialer lib.DialContexter,
+	nameserver Nameserver,
+	protocol string,
+	message []byte,
+	timeout time.Duration,
+) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	conn, err := dialer.DialContext(ctx, protocol, nameserver.Addr())
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	if protocol == protocolUDP {
+		if _, err := conn.Write(message); err != nil {
+			return nil, err
+		}
+
+		buf := make([]byte, dns.MaxMsgSize)
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+
+		return buf[:n], nil
+	}
+
+	framed := make([]byte, 2, 2+len(message))
+	binary.BigEndian.PutUint16(framed, uint16(len(message))) //nolint:gosec
+	if _, err := conn.Write(append(framed, message...)); err != nil {
+		return nil, err
+	}
+
+	var length uint16
+	if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+		return nil, err
+	}
+
+	response := make([]byte, length)
+	if _, err := io.ReadFull(conn, response); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
@@ -0,0 +1,140 @@
+package dns
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+)
+
+func TestFuzz(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Malformed messages should be sent over UDP", func(t *testing.T) {
+		t.Parallel()
+
+		runtime, err := newConfiguredRuntime(t)
+		require.NoError(t, err)
+
+		_, err = runtime.VU.Runtime().RunString(`
+			const server = dns.startServer(["k6.test. 60 IN A 203.0.113.1"]);
+		`)
+		require.NoError(t, err)
+
+		runtime.MoveToVUContext(&lib.State{
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+			Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+			Samples:        make(chan metrics.SampleContainer, 1024),
+		})
+
+		_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+			const result = await dns.fuzz(server.address, { query: "k6.test", type: "A", timeout: "200ms" });
+
+			if (!result.alive || result.protocol !== "udp" || result.cases.length !== 14) {
+				throw new Error("unexpected result: " + JSON.stringify(result));
+			}
+
+			const cases = Object.fromEntries(result.cases.map((c) => [c.case, c]));
+			if (cases.emptyMessage.outcome !== "timeout" || cases.emptyMessage.size !== 0 ||
+				cases.emptyMessage.error === null) {
+				throw new Error("unexpected empty message outcome: " + JSON.stringify(cases.emptyMessage));
+			}
+
+			if (cases.compressionLoop.outcome !== "response" || cases.compressionLoop.rcode !== "FORMERR") {
+				throw new Error("unexpected compression loop outcome: " + JSON.stringify(cases.compressionLoop));
+			}
+
+			if (!result.cases.every((c) => c.alive)) {
+				throw new Error("unexpected dead nameserver: " + JSON.stringify(result));
+			}
+		`))
+		assert.NoError(t, err)
+	})
+
+	t.Run("Malformed messages should be sent over TCP", func(t *testing.T) {
+		t.Parallel()
+
+		runtime, err := newConfiguredRuntime(t)
+		require.NoError(t, err)
+
+		_, err = runtime.VU.Runtime().RunString(`
+			const server = dns.startServer(["k6.test. 60 IN A 203.0.113.1"]);
+		`)
+		require.NoError(t, err)
+
+		runtime.MoveToVUContext(&lib.State{
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+			Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+			Samples:        make(chan metrics.SampleContainer, 1024),
+		})
+
+		_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+			const result = await dns.fuzz(server.address, {
+				cases: ["nameTooLong", "truncatedRdata"],
+				protocol: "tcp",
+				timeout: "200ms",
+			});
+
+			const outcomes = result.cases.map((c) => c.case + "=" + c.outcome + "/" + c.rcode);
+			if (!result.alive || outcomes.join() !== "nameTooLong=response/FORMERR,truncatedRdata=response/FORMERR") {
+				throw new Error("unexpected result: " + JSON.stringify(result));
+			}
+
+			if (!server.log().every((entry) => entry.protocol === "tcp")) {
+				throw new Error("unexpected queries: " + JSON.stringify(server.log()));
+			}
+		`))
+		assert.NoError(t, err)
+	})
+
+	t.Run("Unreachable nameservers should not be reported alive", func(t *testing.T) {
+		t.Parallel()
+
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		address := conn.LocalAddr().String()
+		require.NoError(t, conn.Close())
+
+		runtime, err := newConfiguredRuntime(t)
+		require.NoError(t, err)
+		require.NoError(t, runtime.VU.Runtime().Set("address", address))
+
+		runtime.MoveToVUContext(&lib.State{
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+			Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+			Samples:        make(chan metrics.SampleContainer, 1024),
+		})
+
+		_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+			const result = await dns.fuzz(address, { cases: ["compressionLoop"], timeout: "200ms" });
+
+			const [loop] = result.cases;
+			if (result.alive || loop.alive || loop.outcome === "response" || loop.error === null) {
+				throw new Error("unexpected result: " + JSON.stringify(result));
+			}
+		`))
+		assert.NoError(t, err)
+	})
+
+	t.Run("Unknown cases should be rejected", func(t *testing.T) {
+		t.Parallel()
+
+		runtime, err := newConfiguredRuntime(t)
+		require.NoError(t, err)
+
+		runtime.MoveToVUContext(&lib.State{
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+			Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+			Samples:        make(chan metrics.SampleContainer, 1024),
+		})
+
+		_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+			await dns.fuzz("127.0.0.1:53", { cases: ["bitFlip"] });
+		`))
+		assert.ErrorContains(t, err, `unknown case "bitFlip"`)
+	})
+}
@@ -0,0 +1,198 @@
+package dns
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/grafana/sobek"
+	"github.com/miekg/dns"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/metrics"
+)
+
+// parseClientSubnet parses a client subnet, either in the CIDR notation, or as a
+// bare IP address, standing for a subnet holding this address only.
+func parseClientSubnet(subnet string) (*net.IPNet, error) {
+	if ip := net.ParseIP(subnet); ip != nil {
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+
+	_, ipNet, err := net.ParseCIDR(subnet)
+	if err != nil {
+		return nil, fmt.Errorf("invalid client subnet %s, expected an IP address or a CIDR prefix", subnet)
+	}
+
+	return ipNet, nil
+}
+
+// newClientSubnetOption creates the EDNS Client Subnet option, as defined by
+// RFC 7871, conveying the subnet to the nameserver.
+func newClientSubnetOption(subnet *net.IPNet) *dns.EDNS0_SUBNET {
+	ones, _ := subnet.Mask.Size()
+
+	option := &dns.EDNS0_SUBNET{
+		Code:          dns.EDNS0SUBNET,
+		SourceNetmask: uint8(ones), //nolint:gosec
+	}
+
+	if ip4 := subnet.IP.To4(); ip4 != nil {
+		option.Family = 1
+		option.Address = ip4.Mask(subnet.Mask)
+	} else {
+		option.Family = 2
+		option.Address = subnet.IP.Mask(subnet.Mask)
+	}
+
+	return option
+}
+
+// checkGeoOptions holds the options that can be passed to the checkGeo function.
+type checkGeoOptions struct {
+	// Region holds the name of the region the answers are expected for, if any.
+	Region string
+}
+
+// parseCheckGeoOptions parses the options object passed to the checkGeo function.
+func parseCheckGeoOptions(rt *sobek.Runtime, value sobek.Value) (checkGeoOptions, error) {
+	opts := checkGeoOptions{}
+
+	if common.IsNullish(value) {
+		return opts, nil
+	}
+
+	if v := value.ToObject(rt).Get("region"); !common.IsNullish(v) {
+		if err := rt.ExportTo(v, &opts.Region); err != nil {
+			return opts, fmt.Errorf("region option must be a string; got %v instead", v)
+		}
+	}
+
+	return opts, nil
+}
+
+// checkGeoResult is the value the checkGeo function returns.
+type checkGeoResult struct {
+	// Pass indicates whether all the resolved IP addresses fall within the
+	// expected prefixes.
+	Pass bool `js:"pass"`
+
+	// IPs holds the resolved IP addresses.
+	IPs []string `js:"ips"`
+
+	// Outside holds the resolved IP addresses which fall outside the expected prefixes.
+	Outside []string `js:"outside"`
+}
+
+// CheckGeo checks whether the IP addresses of a resolution fall within the expected
+// prefixes, e.g. those of the points of presence a CDN is expected to steer the
+// clients of a region to, and emits the geo mismatch metric.
+//
+// The resolution is either the array of answers resolve resolves to, a result
+// object resolve resolves to when instructed not to throw, or a message result
+// sendMessage resolves to. A resolution holding no IP addresses does not pass.
+func (mi *ModuleInstance) CheckGeo(result, prefixes, options sobek.Value) (*checkGeoResult, error) {
+	resolved, err := exportResolution(mi.vu.Runtime(), result, "checkGeo")
+	if err != nil {
+		return nil, err
+	}
+
+	var prefixStrs []string
+	if err := mi.vu.Runtime().ExportTo(prefixes, &prefixStrs); err != nil || len(prefixStrs) == 0 {
+		return nil, fmt.Errorf("prefixes must be a non-empty array of CIDR prefixes; got %v instead", prefixes)
+	}
+
+	networks := make([]*net.IPNet, 0, len(prefixStrs))
+	for _, prefix := range prefixStrs {
+		_, network, err := net.ParseCIDR(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("invalid prefix %s: %w", prefix, err)
+		}
+
+		networks = append(networks, network)
+	}
+
+	opts, err := parseCheckGeoOptions(mi.vu.Runtime(), options)
+	if err != nil {
+		return nil, fmt.Errorf("invalid checkGeo options: %w", err)
+	}
+
+	checked := checkGeo(resolved.answers, networks)
+
+	mi.emitGeoMismatchMetrics(resolved.name, resolved.recordType, opts.Region, !checked.Pass)
+
+	return checked, nil
+}
+
+// checkGeo checks whether the IP addresses among the answers fall within the
+// networks. Answers which are not IP addresses, such as CNAME targets, are ignored.
+func checkGeo(answers []string, networks []*net.IPNet) *checkGeoResult {
+	result := &checkGeoResult{
+		IPs:     []string{},
+		Outside: []string{},
+	}
+
+	for _, answer := range answers {
+		ip := net.ParseIP(answer)
+		if ip == nil {
+			continue
+		}
+
+		result.IPs = append(result.IPs, answer)
+
+		within := false
+		for _, network := range networks {
+			if network.Contains(ip) {
+				within = true
+				break
+			}
+		}
+
+		if !within {
+			result.Outside = append(result.Outside, answer)
+		}
+	}
+
+	result.Pass = len(result.IPs) > 0 && len(result.Outside) == 0
+
+	return result
+}
+
+// emitGeoMismatchMetrics emits the metric tracking whether the IP addresses of a
+// resolution fell outside the expected prefixes.
+func (mi *ModuleInstance) emitGeoMismatchMetrics(query, recordType, region string, mismatch bool) {
+	state := mi.vu.State()
+	if state == nil {
+		return
+	}
+
+	tags := state.Tags.GetCurrentValues().Tags
+
+	if query != "" {
+		tags = tags.With("query", query)
+		tags = tags.With("recordType", recordType)
+	}
+
+	if region != "" {
+		tags = tags.With("region", region)
+	}
+
+	var value float64
+	if mismatch {
+		value = 1
+	}
+
+	metrics.PushIfNotDone(mi.vu.Context(), state.Samples, metrics.Sample{
+		TimeSeries: metrics.TimeSeries{
+			Metric: mi.metrics.DNSGeoMismatch,
+			Tags:   tags,
+		},
+		Time:     time.Now(),
+		Value:    value,
+		Metadata: nil,
+	})
+}
@@ -0,0 +1,119 @@
+package dns
+
+import (
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_newClientSubnetOption(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		subnet string
+		want   *dns.EDNS0_SUBNET
+	}{
+		{
+			name:   "IPv4 prefix",
+			subnet: "198.51.100.17/24",
+			want: &dns.EDNS0_SUBNET{
+				Code: dns.EDNS0SUBNET, Family: 1, SourceNetmask: 24, Address: net.ParseIP("198.51.100.0").To4(),
+			},
+		},
+		{
+			name:   "IPv4 address",
+			subnet: "198.51.100.17",
+			want: &dns.EDNS0_SUBNET{
+				Code: dns.EDNS0SUBNET, Family: 1, SourceNetmask: 32, Address: net.ParseIP("198.51.100.17").To4(),
+			},
+		},
+		{
+			name:   "IPv6 prefix",
+			subnet: "2001:db8:1234:5678::1/56",
+			want: &dns.EDNS0_SUBNET{
+				Code: dns.EDNS0SUBNET, Family: 2, SourceNetmask: 56, Address: net.ParseIP("2001:db8:1234:5600::"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			subnet, err := parseClientSubnet(tt.subnet)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.want, newClientSubnetOption(subnet))
+		})
+	}
+
+	t.Run("invalid subnet", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := parseClientSubnet("198.51.100.0/33")
+		assert.Error(t, err)
+	})
+}
+
+func Test_checkGeo(t *testing.T) {
+	t.Parallel()
+
+	networks := make([]*net.IPNet, 0, 2)
+	for _, prefix := range []string{"192.0.2.0/24", "2001:db8::/32"} {
+		_, network, err := net.ParseCIDR(prefix)
+		require.NoError(t, err)
+
+		networks = append(networks, network)
+	}
+
+	tests := []struct {
+		name        string
+		answers     []string
+		wantPass    bool
+		wantOutside []string
+	}{
+		{
+			name:        "all within",
+			answers:     []string{"192.0.2.1", "2001:db8::1"},
+			wantPass:    true,
+			wantOutside: []string{},
+		},
+		{
+			name:        "some outside",
+			answers:     []string{"192.0.2.1", "198.51.100.1"},
+			wantPass:    false,
+			wantOutside: []string{"198.51.100.1"},
+		},
+		{
+			name:        "non IP answers are ignored",
+			answers:     []string{"cdn.k6.io", "192.0.2.1"},
+			wantPass:    true,
+			wantOutside: []string{},
+		},
+		{
+			name:        "no IP addresses",
+			answers:     []string{},
+			wantPass:    false,
+			wantOutside: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := checkGeo(tt.answers, networks)
+
+			assert.Equal(t, tt.wantPass, got.Pass)
+			assert.Equal(t, tt.wantOutside, got.Outside)
+		})
+	}
+}
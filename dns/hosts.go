@@ -0,0 +1,136 @@
+package dns
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/grafana/sobek"
+	"go.k6.io/k6/lib/netext"
+	"go.k6.io/k6/lib/types"
+)
+
+// PinHost overrides the resolution of the hostname by the VU's k6/http requests,
+// and other k6 modules dialing through the VU, to the address, as k6's hosts
+// option does. The address is either an IP address, optionally along with a
+// port, or an array of IP addresses, such as the result of resolve, whose first
+// one is used.
+//
+// Pinned hostnames are specific to the VU, and remain pinned across its
+// iterations, until unpinned.
+//
+// As k6's dialer reads the hosts it overrides without synchronization, pinHost
+// and unpinHost must only be called between requests, and not while the VU has
+// requests in flight, e.g. ones made with http.asyncRequest which were not
+// awaited yet.
+func (mi *ModuleInstance) PinHost(hostname string, address sobek.Value) error {
+	if mi.vu.State() == nil {
+		return errors.New("pinHost can not be used in the init context")
+	}
+
+	if hostname == "" {
+		return errors.New("hostname argument must be provided")
+	}
+
+	addr, err := exportHostAddress(mi.vu.Runtime(), address)
+	if err != nil {
+		return err
+	}
+
+	if mi.pinnedHosts == nil {
+		mi.pinnedHosts = make(map[string]string)
+	}
+
+	mi.pinnedHosts[strings.ToLower(hostname)] = addr
+
+	if err := mi.applyPinnedHosts(); err != nil {
+		delete(mi.pinnedHosts, strings.ToLower(hostname))
+		return fmt.Errorf("pinning %s failed; reason: %w", hostname, err)
+	}
+
+	return nil
+}
+
+// UnpinHost restores the resolution of the hostname pinned with PinHost. As with
+// PinHost, it must only be called between requests.
+func (mi *ModuleInstance) UnpinHost(hostname string) error {
+	if mi.vu.State() == nil {
+		return errors.New("unpinHost can not be used in the init context")
+	}
+
+	delete(mi.pinnedHosts, strings.ToLower(hostname))
+
+	return mi.applyPinnedHosts()
+}
+
+// exportHostAddress exports the address a hostname is pinned to, either an IP
+// address, optionally along with a port, or an array of IP addresses, whose first
+// one is returned.
+func exportHostAddress(rt *sobek.Runtime, value sobek.Value) (string, error) {
+	var addresses []string
+	if address, ok := value.Export().(string); ok {
+		addresses = []string{address}
+	} else if err := rt.ExportTo(value, &addresses); err != nil {
+		return "", errors.New("address argument must be an IP address, or an array of IP addresses")
+	}
+
+	if len(addresses) == 0 {
+		return "", errors.New("address argument must hold at least one IP address")
+	}
+
+	address := addresses[0]
+	if net.ParseIP(address) != nil {
+		return address, nil
+	}
+
+	if host, _, err := net.SplitHostPort(address); err == nil && net.ParseIP(host) != nil {
+		return address, nil
+	}
+
+	return "", fmt.Errorf("address argument must be an IP address; got %q instead", address)
+}
+
+// applyPinnedHosts overrides the hosts the VU's dialer resolves hostnames with,
+// with the ones of k6's hosts option and the pinned ones, the latter taking
+// precedence.
+func (mi *ModuleInstance) applyPinnedHosts() error {
+	state := mi.vu.State()
+
+	dialer, ok := state.Dialer.(*netext.Dialer)
+	if !ok {
+		return errors.New("the VU's dialer does not support overriding hostnames")
+	}
+
+	// The hosts option can only be read back in its JSON form
+	source := make(map[string]string)
+	if state.Options.Hosts.Valid {
+		data, err := json.Marshal(state.Options.Hosts)
+		if err != nil {
+			return err
+		}
+
+		if err := json.Unmarshal(data, &source); err != nil {
+			return err
+		}
+	}
+
+	for hostname, address := range mi.pinnedHosts {
+		source[hostname] = address
+	}
+
+	data, err := json.Marshal(source)
+	if err != nil {
+		return err
+	}
+
+	var hosts types.NullHosts
+	if err := hosts.UnmarshalJSON(data); err != nil {
+		return err
+	}
+
+	dialer.Hosts = hosts.Trie
+
+	return nil
+}
@@ -0,0 +1,172 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/js/compiler"
+	k6http "go.k6.io/k6/js/modules/k6/http"
+	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/netext"
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/metrics"
+)
+
+func TestPinHost(t *testing.T) {
+	t.Parallel()
+
+	// newPinningRuntime returns a runtime whose VU dials through a k6 dialer,
+	// resolving other.k6.test through k6's hosts option
+	newPinningRuntime := func(t *testing.T) (*netext.Dialer, func(string) error) {
+		t.Helper()
+
+		runtime, err := newConfiguredRuntime(t)
+		require.NoError(t, err)
+
+		hosts, err := types.NewNullHosts(map[string]types.Host{"other.k6.test": {IP: net.ParseIP("192.0.2.1")}})
+		require.NoError(t, err)
+
+		dialer := netext.NewDialer(net.Dialer{}, netext.NewResolver(net.LookupIP, 0, types.DNSfirst, types.DNSpreferIPv4))
+		dialer.Hosts = hosts.Trie
+
+		runtime.MoveToVUContext(&lib.State{
+			Options:        lib.Options{Hosts: hosts},
+			Dialer:         dialer,
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+			Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+			Samples:        make(chan metrics.SampleContainer, 1024),
+		})
+
+		return dialer, func(script string) error {
+			_, err := runtime.RunOnEventLoop(wrapInAsyncLambda(script))
+			return err
+		}
+	}
+
+	t.Run("Pinned hostnames should be dialed at their address", func(t *testing.T) {
+		t.Parallel()
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = listener.Close() })
+
+		dialer, run := newPinningRuntime(t)
+
+		require.NoError(t, run(`
+			const server = dns.startServer(["k6.test. 60 IN A 127.0.0.1"]);
+			dns.pinHost("K6.test", await dns.resolve("k6.test", "A", server.address));
+		`))
+
+		_, port, err := net.SplitHostPort(listener.Addr().String())
+		require.NoError(t, err)
+
+		conn, err := dialer.DialContext(context.Background(), "tcp", "k6.test:"+port)
+		require.NoError(t, err)
+		_ = conn.Close()
+
+		// The hosts option still applies
+		assert.Equal(t, "192.0.2.1", dialer.Hosts.Match("other.k6.test").IP.String())
+	})
+
+	t.Run("Unpinned hostnames should no longer be overridden", func(t *testing.T) {
+		t.Parallel()
+
+		dialer, run := newPinningRuntime(t)
+
+		require.NoError(t, run(`
+			dns.pinHost("k6.test", "203.0.113.1:8443");
+			dns.unpinHost("k6.test");
+		`))
+
+		assert.Nil(t, dialer.Hosts.Match("k6.test"))
+		assert.NotNil(t, dialer.Hosts.Match("other.k6.test"))
+	})
+
+	t.Run("Pinning between async requests should not race with them", func(t *testing.T) {
+		t.Parallel()
+
+		newHTTPServer := func(body string) string {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write([]byte(body))
+			}))
+			t.Cleanup(server.Close)
+
+			return server.Listener.Addr().String()
+		}
+
+		runtime := modulestest.NewRuntime(t)
+		require.NoError(t, runtime.SetupModuleSystem(
+			map[string]interface{}{"k6/x/dns": New(), "k6/http": k6http.New()},
+			nil,
+			compiler.New(runtime.VU.InitEnv().Logger),
+		))
+		_, err := runtime.VU.Runtime().RunString(`
+			globalThis.dns = require("k6/x/dns");
+			globalThis.http = require("k6/http");
+		`)
+		require.NoError(t, err)
+
+		require.NoError(t, runtime.VU.Runtime().Set("first", newHTTPServer("first")))
+		require.NoError(t, runtime.VU.Runtime().Set("second", newHTTPServer("second")))
+
+		dialer := netext.NewDialer(net.Dialer{}, netext.NewResolver(net.LookupIP, 0, types.DNSfirst, types.DNSpreferIPv4))
+		registry := metrics.NewRegistry()
+		runtime.MoveToVUContext(&lib.State{
+			Dialer:         dialer,
+			Transport:      &http.Transport{DialContext: dialer.DialContext, DisableKeepAlives: true},
+			BufferPool:     lib.NewBufferPool(),
+			Logger:         runtime.VU.InitEnv().Logger,
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(registry),
+			Tags:           lib.NewVUStateTags(registry.RootTagSet()),
+			Samples:        make(chan metrics.SampleContainer, 1024),
+		})
+
+		// The requests are in flight concurrently, on other goroutines than the
+		// event loop's, and the hostname is pinned again once they all completed
+		_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+			for (const [address, body] of [[first, "first"], [second, "second"]]) {
+				dns.pinHost("pinned.k6.test", address);
+
+				const responses = await Promise.all([
+					http.asyncRequest("GET", "http://pinned.k6.test/"),
+					http.asyncRequest("GET", "http://pinned.k6.test/"),
+				]);
+
+				for (const response of responses) {
+					if (response.error || response.body !== body) {
+						throw new Error("expected " + body + "; got " + response.body + response.error);
+					}
+				}
+			}
+		`))
+		assert.NoError(t, err)
+	})
+
+	tests := []struct {
+		name    string
+		address string
+	}{
+		{name: "No addresses", address: "[]"},
+		{name: "Not an IP address", address: `"k6.io"`},
+		{name: "Not an array of IP addresses", address: "{}"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(fmt.Sprintf("Pinning to invalid addresses should fail: %s", tt.name), func(t *testing.T) {
+			t.Parallel()
+
+			_, run := newPinningRuntime(t)
+
+			assert.Error(t, run(fmt.Sprintf(`dns.pinHost("k6.test", %s);`, tt.address)))
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package dns
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// ToASCII converts an internationalized domain name, e.g. `bücher.example`, to its
+// ASCII form, as defined by [IDNA2008], e.g. `xn--bcher-kva.example`.
+//
+// Domain names consisting only of ASCII characters are returned as is, so that names
+// which are valid in DNS but not as hostnames, such as service names, are preserved.
+//
+// [IDNA2008]: https://datatracker.ietf.org/doc/html/rfc5891
+func ToASCII(name string) (string, error) {
+	if isASCII(name) {
+		return name, nil
+	}
+
+	ascii, err := idna.Lookup.ToASCII(name)
+	if err != nil {
+		return "", fmt.Errorf("invalid internationalized domain name %s: %w", name, err)
+	}
+
+	return ascii, nil
+}
+
+// ToUnicode converts the ASCII form of an internationalized domain name, e.g.
+// `xn--bcher-kva.example`, back to its Unicode form, e.g. `bücher.example`.
+func ToUnicode(name string) (string, error) {
+	unicode, err := idna.Punycode.ToUnicode(name)
+	if err != nil {
+		return "", fmt.Errorf("invalid internationalized domain name %s: %w", name, err)
+	}
+
+	return unicode, nil
+}
+
+// isASCII returns true if the string consists only of ASCII characters.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,47 @@
+package dns
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToASCII(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "ASCII name", in: "k6.io", want: "k6.io"},
+		{name: "service name", in: "_http._tcp.k6.io", want: "_http._tcp.k6.io"},
+		{name: "internationalized name", in: "bücher.example", want: "xn--bcher-kva.example"},
+		{name: "mixed case internationalized name", in: "Bücher.example", want: "xn--bcher-kva.example"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ToASCII(tt.in)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestToUnicode(t *testing.T) {
+	t.Parallel()
+
+	got, err := ToUnicode("xn--bcher-kva.example")
+	require.NoError(t, err)
+	assert.Equal(t, "bücher.example", got)
+
+	got, err = ToUnicode("k6.io")
+	require.NoError(t, err)
+	assert.Equal(t, "k6.io", got)
+}
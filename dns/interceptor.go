@@ -0,0 +1,78 @@
+package dns
+
+import (
+	"context"
+	"errors"
+
+	"github.com/miekg/dns"
+)
+
+// errNoInterceptedResponse is returned when an interceptor neither returns an
+// error nor a Response holding a message.
+var errNoInterceptedResponse = errors.New("an interceptor returned no response")
+
+// SendFunc sends a message to a nameserver, and returns the resulting Response, as
+// Client.Send does.
+type SendFunc func(ctx context.Context, msg *dns.Msg, nameserver Nameserver, opts QueryOptions) (*Response, error)
+
+// Interceptor is called with each message a Client sends, along with the function
+// sending it onward, through the next interceptors. It can modify the message, the
+// nameserver it is sent to and its options before calling next, e.g. to add EDNS
+// options or DoH headers, and inspect or replace the Response afterwards, e.g. to
+// collect custom statistics, or not call next at all, e.g. to answer from a cache,
+// in which case it returns a Response created with NewResponse.
+type Interceptor func(
+	ctx context.Context,
+	msg *dns.Msg,
+	nameserver Nameserver,
+	opts QueryOptions,
+	next SendFunc,
+) (*Response, error)
+
+// NewResponse creates a Response out of a message, for interceptors to answer
+// messages without sending them.
+func NewResponse(msg *dns.Msg) (*Response, error) {
+	raw, err := msg.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &Response{RawResponse: raw, Size: len(raw)}
+	response.setMessage(msg)
+
+	return response, nil
+}
+
+// AddInterceptor adds an interceptor the messages sent by the client go through,
+// including the queries of Resolve and Query. Interceptors are called in the order
+// they were added, the first one being called first.
+//
+// Queries are retransmitted, signed and limited within the innermost interceptor,
+// which is thus called once per query rather than once per attempt.
+func (r *Client) AddInterceptor(interceptor Interceptor) {
+	if interceptor == nil {
+		return
+	}
+
+	r.interceptorsMu.Lock()
+	defer r.interceptorsMu.Unlock()
+
+	r.interceptors = append(r.interceptors, interceptor)
+}
+
+// intercepted returns a SendFunc calling the interceptors of the client in turn,
+// and the send function last.
+func (r *Client) intercepted(send SendFunc) SendFunc {
+	r.interceptorsMu.RLock()
+	interceptors := r.interceptors
+	r.interceptorsMu.RUnlock()
+
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], send
+		send = func(ctx context.Context, msg *dns.Msg, nameserver Nameserver, opts QueryOptions) (*Response, error) {
+			return interceptor(ctx, msg, nameserver, opts, next)
+		}
+	}
+
+	return send
+}
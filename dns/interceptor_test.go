@@ -0,0 +1,124 @@
+package dns
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_AddInterceptor(t *testing.T) {
+	t.Parallel()
+
+	nameserver := Nameserver{IP: net.ParseIP("192.0.2.53"), Port: 53}
+
+	// newClient creates a client whose custom transport answers with an A record,
+	// and reports the messages it was sent
+	newClient := func(sent chan<- *dns.Msg) *Client {
+		client := NewDNSClient()
+		require.NoError(t, client.RegisterTransport("custom", TransportFunc(
+			func(_ context.Context, msg *dns.Msg, _ Nameserver) (*dns.Msg, error) {
+				sent <- msg
+
+				response := new(dns.Msg)
+				response.SetReply(msg)
+				response.Answer = append(response.Answer, &dns.A{
+					Hdr: dns.RR_Header{Name: msg.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+					A:   net.ParseIP("203.0.113.1"),
+				})
+
+				return response, nil
+			},
+		)))
+
+		return client
+	}
+
+	t.Run("Interceptors should be called in the order they were added", func(t *testing.T) {
+		t.Parallel()
+
+		sent := make(chan *dns.Msg, 1)
+		client := newClient(sent)
+
+		var calls []string
+		for _, name := range []string{"first", "second"} {
+			name := name
+			client.AddInterceptor(func(
+				ctx context.Context, msg *dns.Msg, nameserver Nameserver, opts QueryOptions, next SendFunc,
+			) (*Response, error) {
+				calls = append(calls, name+" before")
+				response, err := next(ctx, msg, nameserver, opts)
+				calls = append(calls, name+" after")
+
+				return response, err
+			})
+		}
+
+		_, err := client.Query(context.Background(), "k6.test", "A", nameserver, QueryOptions{Protocol: "custom"})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"first before", "second before", "second after", "first after"}, calls)
+	})
+
+	t.Run("Interceptors should be able to modify messages and their options", func(t *testing.T) {
+		t.Parallel()
+
+		sent := make(chan *dns.Msg, 1)
+		client := newClient(sent)
+
+		client.AddInterceptor(func(
+			ctx context.Context, msg *dns.Msg, nameserver Nameserver, opts QueryOptions, next SendFunc,
+		) (*Response, error) {
+			msg.CheckingDisabled = true
+			opts.Protocol = "custom"
+
+			return next(ctx, msg, nameserver, opts)
+		})
+
+		response, err := client.Query(context.Background(), "k6.test", "A", nameserver, QueryOptions{})
+		require.NoError(t, err)
+
+		assert.Equal(t, "custom", response.Protocol)
+		assert.True(t, (<-sent).CheckingDisabled)
+	})
+
+	t.Run("Interceptors should be able to answer without sending messages", func(t *testing.T) {
+		t.Parallel()
+
+		sent := make(chan *dns.Msg, 1)
+		client := newClient(sent)
+
+		client.AddInterceptor(func(
+			_ context.Context, msg *dns.Msg, _ Nameserver, _ QueryOptions, _ SendFunc,
+		) (*Response, error) {
+			response := new(dns.Msg)
+			response.SetRcode(msg, dns.RcodeNameError)
+
+			return NewResponse(response)
+		})
+
+		response, err := client.Query(context.Background(), "k6.test", "A", nameserver, QueryOptions{Protocol: "custom"})
+		require.Error(t, err)
+
+		assert.Equal(t, "NXDOMAIN", response.Rcode)
+		assert.NotZero(t, response.Size)
+		assert.Empty(t, sent)
+	})
+
+	t.Run("Interceptors returning no response should fail the query", func(t *testing.T) {
+		t.Parallel()
+
+		sent := make(chan *dns.Msg, 1)
+		client := newClient(sent)
+
+		client.AddInterceptor(func(context.Context, *dns.Msg, Nameserver, QueryOptions, SendFunc) (*Response, error) {
+			return &Response{}, nil
+		})
+
+		_, err := client.Query(context.Background(), "k6.test", "A", nameserver, QueryOptions{Protocol: "custom"})
+		require.ErrorIs(t, err, errNoInterceptedResponse)
+	})
+}
@@ -0,0 +1,223 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/grafana/sobek"
+	"go.k6.io/k6/js/common"
+	"golang.org/x/time/rate"
+)
+
+// ClientLimits holds the limits a Client sends queries within, so that scripts
+// can pace the load they put on nameservers, rather than every VU sending queries
+// as fast as it can.
+type ClientLimits struct {
+	// MaxConcurrent holds the maximum number of queries outstanding at once, or
+	// zero if there is no such limit.
+	MaxConcurrent int
+
+	// QPS holds the maximum number of queries sent per second, or zero if there
+	// is no such limit.
+	QPS float64
+
+	// Burst holds the number of queries which can be sent at once, above QPS, after
+	// the client was idle. Defaults to 1, which paces queries evenly.
+	Burst int
+}
+
+// SetLimits sets the limits the client, and the clients sharing its limiter, send
+// queries within. Queries already outstanding count against the new limits, and
+// the token bucket pacing queries is adjusted rather than refilled, so that limits
+// can be set again, e.g. from the init context of each VU, without letting more
+// queries through.
+func (r *Client) SetLimits(limits ClientLimits) {
+	r.limiter.setLimits(limits)
+}
+
+// queryLimiter limits the number of queries outstanding at once, and the rate at
+// which they are sent, using a token bucket. It can be shared between clients.
+type queryLimiter struct {
+	mu sync.Mutex
+
+	// limits holds the limits queries are sent within
+	limits ClientLimits
+
+	// outstanding holds the number of outstanding queries, which is tracked even
+	// if it is not limited, for the queries to count against a later limit
+	outstanding int
+
+	// released is closed when a query is no longer outstanding, or the limits
+	// change, to wake up the queries waiting for the number of outstanding
+	// queries to go below the limit. It is nil if no query is waiting.
+	released chan struct{}
+
+	// rate paces the queries, if their rate is limited
+	rate *rate.Limiter
+}
+
+// newQueryLimiter creates a queryLimiter with no limits.
+func newQueryLimiter() *queryLimiter {
+	return &queryLimiter{}
+}
+
+// setLimits replaces the limits of the limiter. Setting the same limits again
+// does nothing, and the token bucket pacing queries is adjusted rather than
+// replaced, so that it does not refill.
+func (l *queryLimiter) setLimits(limits ClientLimits) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if limits == l.limits {
+		return
+	}
+
+	l.limits = limits
+
+	switch {
+	case limits.QPS <= 0:
+		l.rate = nil
+	case l.rate == nil:
+		l.rate = rate.NewLimiter(rate.Limit(limits.QPS), max(limits.Burst, 1))
+	default:
+		l.rate.SetBurst(max(limits.Burst, 1))
+		l.rate.SetLimit(rate.Limit(limits.QPS))
+	}
+
+	// Waiting queries might fit within a higher limit
+	l.wake()
+}
+
+// wait waits until a query can be sent within the limits, or the context is done.
+// It returns a function to call once the query is no longer outstanding.
+func (l *queryLimiter) wait(ctx context.Context) (func(), error) {
+	limiter, err := l.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	release := l.release
+	if limiter == nil {
+		return release, nil
+	}
+
+	if err := limiter.Wait(ctx); err != nil {
+		release()
+
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		// The query could not be sent before the context's deadline
+		return nil, context.DeadlineExceeded
+	}
+
+	return release, nil
+}
+
+// acquire waits until the number of outstanding queries is below the limit, if
+// any, or the context is done, and accounts for a new outstanding query. It
+// returns the token bucket the query should then be paced by, if any.
+func (l *queryLimiter) acquire(ctx context.Context) (*rate.Limiter, error) {
+	for {
+		l.mu.Lock()
+		if l.limits.MaxConcurrent <= 0 || l.outstanding < l.limits.MaxConcurrent {
+			l.outstanding++
+			limiter := l.rate
+			l.mu.Unlock()
+
+			return limiter, nil
+		}
+
+		if l.released == nil {
+			l.released = make(chan struct{})
+		}
+		released := l.released
+		l.mu.Unlock()
+
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// release accounts for an outstanding query being done.
+func (l *queryLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.outstanding--
+	l.wake()
+}
+
+// wake wakes up the queries waiting for the number of outstanding queries to go
+// below the limit. It must be called with the mutex held.
+func (l *queryLimiter) wake() {
+	if l.released != nil {
+		close(l.released)
+		l.released = nil
+	}
+}
+
+// Configure sets the limits the VUs send queries within, across all of them:
+// the maximum number of queries outstanding at once, and the maximum number of
+// queries sent per second.
+func (mi *ModuleInstance) Configure(options sobek.Value) error {
+	limits, err := parseClientLimits(mi.vu.Runtime(), options)
+	if err != nil {
+		return fmt.Errorf("invalid configure options: %w", err)
+	}
+
+	mi.dnsClient.SetLimits(limits)
+
+	return nil
+}
+
+// parseClientLimits parses the options object passed to the configure function.
+//
+// Undefined or null options result in no limits.
+func parseClientLimits(rt *sobek.Runtime, value sobek.Value) (ClientLimits, error) {
+	limits := ClientLimits{Burst: 1}
+
+	if common.IsNullish(value) {
+		return limits, nil
+	}
+
+	params := value.ToObject(rt)
+
+	for _, count := range []struct {
+		option string
+		value  *int
+		min    int64
+	}{
+		{option: "maxConcurrent", value: &limits.MaxConcurrent, min: 0},
+		{option: "burst", value: &limits.Burst, min: 1},
+	} {
+		v := params.Get(count.option)
+		if common.IsNullish(v) {
+			continue
+		}
+
+		var n int64
+		if err := rt.ExportTo(v, &n); err != nil || n < count.min {
+			return limits, fmt.Errorf("%s option must be an integer of at least %d; got %v instead", count.option, count.min, v)
+		}
+
+		*count.value = int(n)
+	}
+
+	if v := params.Get("qps"); !common.IsNullish(v) {
+		var qps float64
+		if err := rt.ExportTo(v, &qps); err != nil || math.IsNaN(qps) || math.IsInf(qps, 0) || qps < 0 {
+			return limits, fmt.Errorf("qps option must be a positive number; got %v instead", v)
+		}
+
+		limits.QPS = qps
+	}
+
+	return limits, nil
+}
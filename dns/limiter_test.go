@@ -0,0 +1,295 @@
+package dns
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grafana/sobek"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+)
+
+func Test_parseClientLimits(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		options string
+		want    ClientLimits
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "undefined options",
+			options: `undefined`,
+			want:    ClientLimits{Burst: 1},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "concurrency and rate",
+			options: `({maxConcurrent: 50, qps: 1000, burst: 10})`,
+			want:    ClientLimits{MaxConcurrent: 50, QPS: 1000, Burst: 10},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "fractional rate",
+			options: `({qps: 0.5})`,
+			want:    ClientLimits{QPS: 0.5, Burst: 1},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "negative concurrency",
+			options: `({maxConcurrent: -1})`,
+			wantErr: assert.Error,
+		},
+		{
+			name:    "negative rate",
+			options: `({qps: -10})`,
+			wantErr: assert.Error,
+		},
+		{
+			name:    "empty burst",
+			options: `({qps: 10, burst: 0})`,
+			wantErr: assert.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rt := sobek.New()
+			options, err := rt.RunString(tt.options)
+			require.NoError(t, err)
+
+			got, err := parseClientLimits(rt, options)
+			tt.wantErr(t, err)
+
+			if err == nil {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func Test_queryLimiter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("outstanding queries should be capped", func(t *testing.T) {
+		t.Parallel()
+
+		limiter := newQueryLimiter()
+		limiter.setLimits(ClientLimits{MaxConcurrent: 2})
+
+		first, err := limiter.wait(context.Background())
+		require.NoError(t, err)
+
+		second, err := limiter.wait(context.Background())
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		_, err = limiter.wait(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+		first()
+
+		third, err := limiter.wait(context.Background())
+		require.NoError(t, err)
+
+		second()
+		third()
+	})
+
+	t.Run("queries should be paced", func(t *testing.T) {
+		t.Parallel()
+
+		limiter := newQueryLimiter()
+		limiter.setLimits(ClientLimits{QPS: 100, Burst: 1})
+
+		start := time.Now()
+		for i := 0; i < 6; i++ {
+			release, err := limiter.wait(context.Background())
+			require.NoError(t, err)
+			release()
+		}
+
+		// The first query is sent right away, and the others every 10ms
+		assert.GreaterOrEqual(t, time.Since(start), 45*time.Millisecond)
+	})
+
+	t.Run("queries which can not be sent before the deadline should fail", func(t *testing.T) {
+		t.Parallel()
+
+		limiter := newQueryLimiter()
+		limiter.setLimits(ClientLimits{QPS: 1, Burst: 1})
+
+		release, err := limiter.wait(context.Background())
+		require.NoError(t, err)
+		release()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		_, err = limiter.wait(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("setting limits again should not release outstanding queries", func(t *testing.T) {
+		t.Parallel()
+
+		limiter := newQueryLimiter()
+		limiter.setLimits(ClientLimits{MaxConcurrent: 2})
+
+		first, err := limiter.wait(context.Background())
+		require.NoError(t, err)
+
+		second, err := limiter.wait(context.Background())
+		require.NoError(t, err)
+
+		limiter.setLimits(ClientLimits{MaxConcurrent: 2})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		_, err = limiter.wait(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+		// Raising the limit lets one more query through, the outstanding ones
+		// still counting against it
+		limiter.setLimits(ClientLimits{MaxConcurrent: 3})
+
+		third, err := limiter.wait(context.Background())
+		require.NoError(t, err)
+
+		ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		_, err = limiter.wait(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+		first()
+		second()
+		third()
+	})
+
+	t.Run("setting limits again should not refill the token bucket", func(t *testing.T) {
+		t.Parallel()
+
+		limiter := newQueryLimiter()
+		limiter.setLimits(ClientLimits{QPS: 1, Burst: 1})
+
+		release, err := limiter.wait(context.Background())
+		require.NoError(t, err)
+		release()
+
+		for _, limits := range []ClientLimits{{QPS: 1, Burst: 1}, {QPS: 2, Burst: 1}} {
+			limiter.setLimits(limits)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			_, err = limiter.wait(ctx)
+			cancel()
+
+			assert.ErrorIs(t, err, context.DeadlineExceeded)
+		}
+	})
+
+	t.Run("no limits by default", func(t *testing.T) {
+		t.Parallel()
+
+		limiter := newQueryLimiter()
+
+		for i := 0; i < 100; i++ {
+			_, err := limiter.wait(context.Background())
+			require.NoError(t, err)
+		}
+	})
+}
+
+func TestModuleInstance_Configure(t *testing.T) {
+	t.Parallel()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	var inFlight, maxInFlight atomic.Int64
+	server := &dns.Server{PacketConn: conn, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+
+		for current := maxInFlight.Load(); n > current; current = maxInFlight.Load() {
+			if maxInFlight.CompareAndSwap(current, n) {
+				break
+			}
+		}
+
+		time.Sleep(30 * time.Millisecond)
+
+		response := new(dns.Msg)
+		response.SetReply(req)
+		_ = w.WriteMsg(response)
+	})}
+	go func() { _ = server.ActivateAndServe() }()
+	t.Cleanup(func() { _ = server.Shutdown() })
+
+	// Both VUs share the limiter of the root module
+	rootModule := New()
+	running := newResolverRuntime(t, rootModule)
+	initializing := newResolverRuntime(t, rootModule)
+	require.NoError(t, running.VU.Runtime().Set("nameserver", conn.LocalAddr().String()))
+
+	_, err = running.VU.Runtime().RunString(`dns.configure({ maxConcurrent: 2 });`)
+	require.NoError(t, err)
+
+	running.MoveToVUContext(&lib.State{
+		BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+		Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+		Samples:        make(chan metrics.SampleContainer, 1024),
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := running.RunOnEventLoop(wrapInAsyncLambda(`
+			const queries = [];
+			for (let i = 0; i < 10; i++) {
+				queries.push({ name: "q" + i + ".k6.test", type: "A" });
+			}
+
+			await dns.resolveBatch(queries, nameserver, { concurrency: 10 });
+		`))
+		done <- err
+	}()
+
+	// Another VU is initialized while the queries of the first one are in flight,
+	// and configures the same limits, as arrival-rate executors do mid-test,
+	// before sending queries of its own
+	time.Sleep(50 * time.Millisecond)
+	_, err = initializing.VU.Runtime().RunString(`dns.configure({ maxConcurrent: 2 });`)
+	require.NoError(t, err)
+
+	require.NoError(t, initializing.VU.Runtime().Set("nameserver", conn.LocalAddr().String()))
+	initializing.MoveToVUContext(&lib.State{
+		BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+		Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+		Samples:        make(chan metrics.SampleContainer, 1024),
+	})
+
+	_, err = initializing.RunOnEventLoop(wrapInAsyncLambda(`
+		await dns.resolveBatch([
+			{ name: "k6.test", type: "A" },
+			{ name: "k6.test", type: "AAAA" },
+		], nameserver);
+	`))
+	require.NoError(t, err)
+
+	require.NoError(t, <-done)
+	assert.Equal(t, int64(2), maxInFlight.Load())
+}
@@ -0,0 +1,181 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// AddressFamily represents the family of the IP addresses a lookup returns.
+type AddressFamily uint8
+
+const (
+	// AddressFamilyAny indicates that both IPv4 and IPv6 addresses should be returned.
+	AddressFamilyAny AddressFamily = iota
+
+	// AddressFamilyIPv4 indicates that only IPv4 addresses should be returned.
+	AddressFamilyIPv4
+
+	// AddressFamilyIPv6 indicates that only IPv6 addresses should be returned.
+	AddressFamilyIPv6
+)
+
+// network returns the network name the net package expects for the address family.
+func (f AddressFamily) network() string {
+	switch f {
+	case AddressFamilyIPv4:
+		return "ip4"
+	case AddressFamilyIPv6:
+		return "ip6"
+	default:
+		return "ip"
+	}
+}
+
+// contains indicates whether the IP address belongs to the address family. IPv4-mapped
+// IPv6 addresses belong to the IPv4 family, as they are reached over IPv4.
+func (f AddressFamily) contains(ip net.IP) bool {
+	switch f {
+	case AddressFamilyIPv4:
+		return ip.To4() != nil
+	case AddressFamilyIPv6:
+		return ip.To4() == nil
+	default:
+		return true
+	}
+}
+
+// addressFamilyString returns the address family of the name, either "any",
+// "ipv4" or "ipv6", and whether the name is valid.
+func addressFamilyString(name string) (AddressFamily, bool) {
+	switch name {
+	case "any":
+		return AddressFamilyAny, true
+	case "ipv4":
+		return AddressFamilyIPv4, true
+	case "ipv6":
+		return AddressFamilyIPv6, true
+	default:
+		return AddressFamilyAny, false
+	}
+}
+
+// AddressOrder represents the order in which the IP addresses a lookup returns are sorted.
+type AddressOrder uint8
+
+const (
+	// AddressOrderSystem indicates that the addresses are returned in the order the system's
+	// resolver sorted them, that is following the [RFC 6724] destination address selection rules.
+	//
+	// [RFC 6724]: https://datatracker.ietf.org/doc/html/rfc6724
+	AddressOrderSystem AddressOrder = iota
+
+	// AddressOrderIPv4First indicates that IPv4 addresses are returned before IPv6 addresses.
+	AddressOrderIPv4First
+
+	// AddressOrderIPv6First indicates that IPv6 addresses are returned before IPv4 addresses.
+	AddressOrderIPv6First
+)
+
+// LookupOptions holds the options influencing how a lookup is performed.
+type LookupOptions struct {
+	// Family holds the family of the IP addresses the lookup should return.
+	Family AddressFamily
+
+	// Order holds the order in which the IP addresses should be returned.
+	Order AddressOrder
+}
+
+// sortAddresses sorts the IP addresses in the requested order. The relative order of
+// the addresses of a same family, as established by the system's resolver, is preserved.
+func sortAddresses(ips []net.IP, order AddressOrder) {
+	if order == AddressOrderSystem {
+		return
+	}
+
+	sort.SliceStable(ips, func(i, j int) bool {
+		iIsIPv4, jIsIPv4 := ips[i].To4() != nil, ips[j].To4() != nil
+		if order == AddressOrderIPv4First {
+			return iIsIPv4 && !jIsIPv4
+		}
+
+		return !iIsIPv4 && jIsIPv4
+	})
+}
+
+// ServiceEndpoint represents an endpoint of a service, as advertised by a SRV record,
+// along with the IP addresses its target resolves to.
+type ServiceEndpoint struct {
+	// Target holds the hostname of the endpoint.
+	Target string `js:"target"`
+
+	// Port holds the port the service is exposed on.
+	Port uint16 `js:"port"`
+
+	// Priority holds the priority of the endpoint. Endpoints with lower values should
+	// be preferred.
+	Priority uint16 `js:"priority"`
+
+	// Weight holds the relative weight of the endpoint among the endpoints of a same priority.
+	Weight uint16 `js:"weight"`
+
+	// Addresses holds the IP addresses the endpoint's target resolves to.
+	Addresses []string `js:"addresses"`
+}
+
+// lookupService looks up the SRV records of the service name, using the provided
+// resolver, and then looks up the IP addresses of their targets using the provided
+// Lookuper.
+//
+// Endpoints are returned sorted by priority, and randomized by weight within a same
+// priority, as per [RFC 2782]. Records whose target is "." indicate that the service
+// is not available at the domain, and are omitted. Endpoints whose target could not
+// be looked up are omitted as well, unless none of them could be, in which case an
+// error is returned.
+//
+// [RFC 2782]: https://datatracker.ietf.org/doc/html/rfc2782
+func lookupService(
+	ctx context.Context,
+	resolver *net.Resolver,
+	name string,
+	opts LookupOptions,
+	lookuper Lookuper,
+) ([]ServiceEndpoint, error) {
+	_, records, err := resolver.LookupSRV(ctx, "", "", name)
+	if err != nil {
+		return nil, fmt.Errorf("service lookup of %s failed: %w", name, err)
+	}
+
+	var lookupErrs []error
+	endpoints := make([]ServiceEndpoint, 0, len(records))
+	for _, record := range records {
+		if record.Target == "." {
+			continue
+		}
+
+		target := strings.TrimSuffix(record.Target, ".")
+
+		addresses, err := lookuper.Lookup(ctx, target, opts)
+		if err != nil {
+			lookupErrs = append(lookupErrs, err)
+			continue
+		}
+
+		endpoints = append(endpoints, ServiceEndpoint{
+			Target:    target,
+			Port:      record.Port,
+			Priority:  record.Priority,
+			Weight:    record.Weight,
+			Addresses: addresses,
+		})
+	}
+
+	if len(endpoints) == 0 && len(lookupErrs) > 0 {
+		return nil, fmt.Errorf("none of the targets of service %s could be looked up: %w", name, errors.Join(lookupErrs...))
+	}
+
+	return endpoints, nil
+}
@@ -0,0 +1,108 @@
+package dns
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_lookupService(t *testing.T) {
+	t.Parallel()
+
+	resolver := newSRVResolver(t,
+		"_http._tcp.k6.test. 60 IN SRV 20 0 8080 backup.k6.test.",
+		"_http._tcp.k6.test. 60 IN SRV 10 0 80 light.k6.test.",
+		"_http._tcp.k6.test. 60 IN SRV 10 100 80 heavy.k6.test.",
+		"_http._tcp.k6.test. 60 IN SRV 30 0 80 missing.k6.test.",
+		"_http._tcp.missing.test. 60 IN SRV 10 0 80 missing.k6.test.",
+		"_http._tcp.unavailable.test. 60 IN SRV 0 0 0 .",
+	)
+
+	lookuper := staticLookuper{
+		"backup.k6.test": {"198.51.100.3"},
+		"light.k6.test":  {"198.51.100.2"},
+		"heavy.k6.test":  {"198.51.100.1", "2001:db8::1"},
+	}
+
+	t.Run("endpoints are sorted by priority and weight", func(t *testing.T) {
+		t.Parallel()
+
+		endpoints, err := lookupService(context.Background(), resolver, "_http._tcp.k6.test", LookupOptions{}, lookuper)
+		require.NoError(t, err)
+
+		// The heavy target always comes first among the targets of priority 10,
+		// as the light one has a zero weight
+		assert.Equal(t, []ServiceEndpoint{
+			{Target: "heavy.k6.test", Port: 80, Priority: 10, Weight: 100, Addresses: []string{"198.51.100.1", "2001:db8::1"}},
+			{Target: "light.k6.test", Port: 80, Priority: 10, Weight: 0, Addresses: []string{"198.51.100.2"}},
+			{Target: "backup.k6.test", Port: 8080, Priority: 20, Weight: 0, Addresses: []string{"198.51.100.3"}},
+		}, endpoints)
+	})
+
+	t.Run("services whose targets all fail to resolve fail", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := lookupService(context.Background(), resolver, "_http._tcp.missing.test", LookupOptions{}, lookuper)
+		assert.ErrorContains(t, err, "none of the targets of service _http._tcp.missing.test could be looked up")
+	})
+
+	t.Run("services not available at the domain have no endpoints", func(t *testing.T) {
+		t.Parallel()
+
+		endpoints, err := lookupService(context.Background(), resolver, "_http._tcp.unavailable.test", LookupOptions{}, lookuper)
+		require.NoError(t, err)
+		assert.Empty(t, endpoints)
+	})
+
+	t.Run("services without SRV records fail", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := lookupService(context.Background(), resolver, "_http._tcp.unknown.test", LookupOptions{}, lookuper)
+		assert.ErrorContains(t, err, "service lookup of _http._tcp.unknown.test failed")
+	})
+}
+
+// newSRVResolver returns a resolver sending its queries to a server answering
+// them with the provided records.
+func newSRVResolver(t *testing.T, records ...string) *net.Resolver {
+	t.Helper()
+
+	zone := make(map[string][]dns.RR)
+	for _, record := range records {
+		rr, err := dns.NewRR(record)
+		require.NoError(t, err)
+
+		zone[rr.Header().Name] = append(zone[rr.Header().Name], rr)
+	}
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := &dns.Server{PacketConn: conn, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		response := new(dns.Msg)
+		response.SetReply(req)
+		response.Authoritative = true
+
+		answers, ok := zone[req.Question[0].Name]
+		if !ok {
+			response.Rcode = dns.RcodeNameError
+		}
+
+		response.Answer = answers
+		_ = w.WriteMsg(response)
+	})}
+	go func() { _ = server.ActivateAndServe() }()
+	t.Cleanup(func() { _ = server.Shutdown() })
+
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "udp", conn.LocalAddr().String())
+		},
+	}
+}
@@ -0,0 +1,138 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// mdnsNameserver is the address of the IPv4 multicast DNS group, as defined by
+// RFC 6762.
+var mdnsNameserver = Nameserver{IP: net.IPv4(224, 0, 0, 251), Port: 5353} //nolint:gochecknoglobals
+
+// defaultMulticastWindow is the default duration responses to multicast queries
+// are collected for.
+const defaultMulticastWindow = time.Second
+
+// multicast sends the message to the multicast group as a one-shot multicast DNS
+// query, as defined by RFC 6762 section 5.1, and collects the responses received
+// within opts.Timeout, or defaultMulticastWindow if it is zero.
+//
+// As any number of responders might answer, the returned Response holds a message
+// merging the records of all the responses, and no response is not an error.
+func (r *Client) multicast(
+	ctx context.Context,
+	message *dns.Msg,
+	group Nameserver,
+	opts QueryOptions,
+) (*Response, error) {
+	result := &Response{Protocol: protocolUDP, Attempts: 1}
+
+	packed, err := message.Pack()
+	if err != nil {
+		return result, withNameserver(newExchangeError(err, "querying the DNS nameserver failed"), group)
+	}
+
+	result.RawRequest = packed
+
+	window := opts.Timeout
+	if window <= 0 {
+		window = defaultMulticastWindow
+	}
+
+	merged, size, err := collectMulticast(ctx, packed, message.Id, group, window, result)
+	if err != nil {
+		return result, withNameserver(newExchangeError(err, "querying the DNS nameserver failed"), group)
+	}
+
+	merged.SetReply(message)
+	merged.Answer = dns.Dedup(merged.Answer, nil)
+	merged.Ns = dns.Dedup(merged.Ns, nil)
+	merged.Extra = dns.Dedup(merged.Extra, nil)
+
+	result.setMessage(merged)
+	result.Size = size
+
+	return result, nil
+}
+
+// collectMulticast sends the packed message to the multicast group, and merges
+// the records of the responses received within the window. It returns the merged
+// message, along with the overall size of the responses.
+//
+// Responses are expected to echo the ID of the message, as legacy unicast responses
+// do, or to hold a zero ID, as multicast responses do. Others are dropped, and
+// accounted for in the provided Response.
+func collectMulticast(
+	ctx context.Context,
+	packed []byte,
+	id uint16,
+	group Nameserver,
+	window time.Duration,
+	result *Response,
+) (*dns.Msg, int, error) {
+	network := "udp4"
+	if group.IP.To4() == nil {
+		network = "udp6"
+	}
+
+	var lc net.ListenConfig
+	conn, err := lc.ListenPacket(ctx, network, ":0")
+	if err != nil {
+		return nil, 0, err
+	}
+	defer conn.Close() //nolint:errcheck
+
+	ctx, cancel := context.WithTimeout(ctx, window)
+	defer cancel()
+
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	if _, err := conn.WriteTo(packed, &net.UDPAddr{IP: group.IP, Port: int(group.Port)}); err != nil {
+		return nil, 0, err
+	}
+
+	merged := new(dns.Msg)
+	size := 0
+	buf := make([]byte, dns.MaxMsgSize)
+
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(ctx.Err(), context.Canceled) {
+				return nil, 0, ctx.Err()
+			}
+
+			// The end of the window is the end of the query, rather than a failure
+			if isTimeout(err) {
+				return merged, size, nil
+			}
+
+			return nil, 0, err
+		}
+
+		response := new(dns.Msg)
+		if err := response.Unpack(buf[:n]); err != nil {
+			continue
+		}
+
+		if response.Id != id && response.Id != 0 {
+			result.IDMismatches++
+			continue
+		}
+
+		size += n
+		merged.Answer = append(merged.Answer, response.Answer...)
+		merged.Ns = append(merged.Ns, response.Ns...)
+		merged.Extra = append(merged.Extra, response.Extra...)
+	}
+}
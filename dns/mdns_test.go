@@ -0,0 +1,72 @@
+package dns
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_collectMulticast(t *testing.T) {
+	t.Parallel()
+
+	// The responder answers as three responders would, one of them twice, and
+	// another one to a different query.
+	responder, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = responder.Close() })
+
+	go func() {
+		buf := make([]byte, dns.MaxMsgSize)
+
+		n, addr, err := responder.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+
+		query := new(dns.Msg)
+		if err := query.Unpack(buf[:n]); err != nil {
+			return
+		}
+
+		for _, answer := range []struct {
+			id     uint16
+			record string
+		}{
+			{id: query.Id, record: "printer.local. 120 IN A 192.0.2.1"},
+			{id: query.Id, record: "printer.local. 120 IN A 192.0.2.1"},
+			{id: 0, record: "printer.local. 120 IN A 192.0.2.2"},
+			{id: query.Id + 1, record: "printer.local. 120 IN A 192.0.2.3"},
+		} {
+			response := new(dns.Msg)
+			response.SetReply(query)
+			response.Id = answer.id
+
+			rr, _ := dns.NewRR(answer.record)
+			response.Answer = append(response.Answer, rr)
+
+			packed, _ := response.Pack()
+			_, _ = responder.WriteTo(packed, addr)
+		}
+	}()
+
+	query := new(dns.Msg)
+	query.SetQuestion("printer.local.", dns.TypeA)
+
+	packed, err := query.Pack()
+	require.NoError(t, err)
+
+	group := Nameserver{IP: net.IPv4(127, 0, 0, 1), Port: uint16(responder.LocalAddr().(*net.UDPAddr).Port)} //nolint:forcetypeassert,gosec
+	result := &Response{}
+
+	merged, size, err := collectMulticast(context.Background(), packed, query.Id, group, 200*time.Millisecond, result)
+	require.NoError(t, err)
+
+	assert.Len(t, dns.Dedup(merged.Answer, nil), 2)
+	assert.Positive(t, size)
+	assert.Equal(t, 1, result.IDMismatches)
+}
@@ -0,0 +1,697 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/sobek"
+	"github.com/miekg/dns"
+	"go.k6.io/k6/js/promises"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Message is a DNS message under construction, which allows crafting arbitrary
+// queries, such as queries holding multiple questions, unusual flags or records.
+//
+// Its setters return the message itself, so that calls can be chained.
+type Message struct {
+	msg dns.Msg
+}
+
+// NewMessage creates a new Message, with a random ID and the recursion desired
+// flag set, as is the case for the queries sent by resolve.
+func NewMessage() *Message {
+	message := &Message{}
+	message.msg.Id = dns.Id()
+	message.msg.RecursionDesired = true
+
+	return message
+}
+
+// SetID sets the ID of the message.
+func (m *Message) SetID(id int64) (*Message, error) {
+	if id < 0 || id > math.MaxUint16 {
+		return nil, fmt.Errorf("message ID must be between 0 and %d; got %d instead", math.MaxUint16, id)
+	}
+
+	m.msg.Id = uint16(id)
+
+	return m, nil
+}
+
+// maxOpcode is the largest opcode the 4 bits of the opcode field of the header of
+// DNS messages can hold.
+const maxOpcode = 15
+
+// SetOpcode sets the opcode of the message, either by name, e.g. "QUERY",
+// "STATUS", "NOTIFY" or "UPDATE", or by number, from 0 to 15, including the
+// unassigned ones, e.g. 3 or "OPCODE3", so that conformance tests can check how
+// nameservers handle them.
+func (m *Message) SetOpcode(opcode string) (*Message, error) {
+	name := strings.ToUpper(opcode)
+
+	if value, ok := dns.StringToOpcode[name]; ok {
+		m.msg.Opcode = value
+		return m, nil
+	}
+
+	value, err := strconv.Atoi(strings.TrimPrefix(name, "OPCODE"))
+	if err != nil || value < 0 || value > maxOpcode {
+		return nil, fmt.Errorf("unknown opcode %q; must be a name or a number between 0 and %d", opcode, maxOpcode)
+	}
+
+	m.msg.Opcode = value
+
+	return m, nil
+}
+
+// opcodeString returns the name of the opcode, or "OPCODE" followed by its number
+// for the unassigned ones, as dig names them.
+func opcodeString(opcode int) string {
+	if name, ok := dns.OpcodeToString[opcode]; ok {
+		return name
+	}
+
+	return "OPCODE" + strconv.Itoa(opcode)
+}
+
+// SetRcode sets the response code of the message, e.g. "NOERROR".
+func (m *Message) SetRcode(rcode string) (*Message, error) {
+	value, ok := dns.StringToRcode[strings.ToUpper(rcode)]
+	if !ok {
+		return nil, fmt.Errorf("unknown response code %q", rcode)
+	}
+
+	m.msg.Rcode = value
+
+	return m, nil
+}
+
+// SetFlag sets or clears one of the header flags of the message, one of "qr",
+// "aa", "tc", "rd", "ra", "z", "ad" or "cd".
+func (m *Message) SetFlag(flag string, value bool) (*Message, error) {
+	switch strings.ToLower(flag) {
+	case "qr":
+		m.msg.Response = value
+	case "aa":
+		m.msg.Authoritative = value
+	case "tc":
+		m.msg.Truncated = value
+	case "rd":
+		m.msg.RecursionDesired = value
+	case "ra":
+		m.msg.RecursionAvailable = value
+	case "z":
+		m.msg.Zero = value
+	case "ad":
+		m.msg.AuthenticatedData = value
+	case "cd":
+		m.msg.CheckingDisabled = value
+	default:
+		return nil, fmt.Errorf("unknown flag %q", flag)
+	}
+
+	return m, nil
+}
+
+// AddQuestion adds a question for the name and record type to the message. The
+// class of the question defaults to "IN".
+//
+// As opposed to resolve, any record type known to the DNS protocol can be used,
+// and several questions can be added, which nameservers usually reject.
+func (m *Message) AddQuestion(name, recordType, class string) (*Message, error) {
+	asciiName, err := ToASCII(name)
+	if err != nil {
+		return nil, fmt.Errorf("question name %q is invalid; reason: %w", name, err)
+	}
+
+	qtype, ok := dns.StringToType[strings.ToUpper(recordType)]
+	if !ok {
+		return nil, fmt.Errorf("unknown record type %q", recordType)
+	}
+
+	if class == "" {
+		class = "IN"
+	}
+
+	qclass, ok := dns.StringToClass[strings.ToUpper(class)]
+	if !ok {
+		return nil, fmt.Errorf("unknown class %q", class)
+	}
+
+	m.msg.Question = append(m.msg.Question, dns.Question{
+		Name:   dns.Fqdn(asciiName),
+		Qtype:  qtype,
+		Qclass: qclass,
+	})
+
+	return m, nil
+}
+
+// AddRecord adds a record, in presentation format (e.g. "k6.io. 60 IN A 1.2.3.4"),
+// to a section of the message, one of "answer", "authority" or "additional".
+func (m *Message) AddRecord(section, record string) (*Message, error) {
+	rr, err := parseRecord(record)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(section) {
+	case "answer":
+		m.msg.Answer = append(m.msg.Answer, rr)
+	case "authority":
+		m.msg.Ns = append(m.msg.Ns, rr)
+	case "additional":
+		m.msg.Extra = append(m.msg.Extra, rr)
+	default:
+		return nil, fmt.Errorf("section must be one of 'answer', 'authority' or 'additional'; got %q instead", section)
+	}
+
+	return m, nil
+}
+
+// parseRecord parses a record in presentation format, e.g. "k6.io. 60 IN A 1.2.3.4".
+func parseRecord(record string) (dns.RR, error) {
+	rr, err := dns.NewRR(record)
+	if err != nil {
+		return nil, fmt.Errorf("record %q is invalid; reason: %w", record, err)
+	}
+
+	if rr == nil {
+		return nil, fmt.Errorf("record %q is empty", record)
+	}
+
+	return rr, nil
+}
+
+// SetEDNS adds an EDNS0 OPT record to the message, advertising the UDP payload
+// size, and whether DNSSEC records are requested.
+func (m *Message) SetEDNS(udpSize int64, dnssecOK bool) (*Message, error) {
+	if udpSize < 0 || udpSize > math.MaxUint16 {
+		return nil, fmt.Errorf("EDNS UDP payload size must be between 0 and %d; got %d instead", math.MaxUint16, udpSize)
+	}
+
+	m.msg.SetEdns0(uint16(udpSize), dnssecOK)
+
+	return m, nil
+}
+
+// messageResult is the object the sendMessage function resolves to, describing
+// the message received from the nameserver.
+type messageResult struct {
+	// ID holds the ID of the message.
+	ID uint16 `js:"id"`
+
+	// Opcode holds the name of the opcode of the message, e.g. "QUERY".
+	Opcode string `js:"opcode"`
+
+	// Rcode holds the name of the response code of the message, e.g. "NOERROR".
+	Rcode string `js:"rcode"`
+
+	// Flags holds the header flags of the message, by name.
+	Flags map[string]bool `js:"flags"`
+
+	// Questions holds the questions of the message.
+	Questions []messageQuestion `js:"questions"`
+
+	// Answers holds the records of the answer section of the message.
+	Answers []Record `js:"answers"`
+
+	// Authority holds the records of the authority section of the message.
+	Authority []Record `js:"authority"`
+
+	// Additional holds the records of the additional section of the message,
+	// except for the EDNS0 OPT and TSIG pseudo-records.
+	Additional []Record `js:"additional"`
+
+	// EDNS holds the EDNS0 parameters of the message, if it has an OPT record.
+	EDNS *messageEDNS `js:"edns"`
+
+	// Size holds the size of the message, in bytes.
+	Size int `js:"size"`
+
+	// RTT holds the duration of the exchange, in milliseconds.
+	RTT float64 `js:"rtt"`
+
+	// Raw holds the wire format of the exchanged messages, if requested.
+	Raw *rawMessages `js:"raw"`
+
+	// TLS describes the TLS connection the message was sent over, with DoT and
+	// DoH, or is nil otherwise.
+	TLS *TLSInfo `js:"tls"`
+
+	// HTTPCache describes the caching of the response by HTTP caches, with DoH, or
+	// is nil otherwise.
+	HTTPCache *HTTPCacheInfo `js:"httpCache"`
+
+	// Server describes the nameserver which answered the message.
+	Server *serverInfo `js:"server"`
+
+	// msg holds the message, for it to be formatted.
+	msg *dns.Msg
+}
+
+// messageQuestion describes a question of a DNS message.
+type messageQuestion struct {
+	// Name holds the name the question is about, without its trailing dot.
+	Name string `js:"name"`
+
+	// Type holds the record type the question is about, e.g. "A".
+	Type string `js:"type"`
+
+	// Class holds the class the question is about, e.g. "IN".
+	Class string `js:"class"`
+}
+
+// messageEDNS describes the EDNS0 parameters of a DNS message.
+type messageEDNS struct {
+	// UDPSize holds the advertised UDP payload size.
+	UDPSize uint16 `js:"udpSize"`
+
+	// DNSSECOK indicates whether the DO bit is set.
+	DNSSECOK bool `js:"do"`
+
+	// Version holds the EDNS version.
+	Version uint8 `js:"version"`
+
+	// Options holds the EDNS0 options of the OPT record.
+	Options []ednsOption `js:"options"`
+}
+
+// ednsOption describes an EDNS0 option of an OPT record.
+type ednsOption struct {
+	// Code holds the code of the option, e.g. 8 for client subnet options.
+	Code uint16 `js:"code"`
+
+	// Name holds the name of the option, e.g. "ECS", or an empty string if the
+	// option is unknown.
+	Name string `js:"name"`
+
+	// Data holds the option's data, in presentation format.
+	Data string `js:"data"`
+}
+
+// ednsOptionNames holds the names of the known EDNS0 options, by code.
+var ednsOptionNames = map[uint16]string{ //nolint:gochecknoglobals
+	dns.EDNS0LLQ:          "LLQ",
+	dns.EDNS0UL:           "UL",
+	dns.EDNS0NSID:         "NSID",
+	dns.EDNS0ESU:          "ESU",
+	dns.EDNS0DAU:          "DAU",
+	dns.EDNS0DHU:          "DHU",
+	dns.EDNS0N3U:          "N3U",
+	dns.EDNS0SUBNET:       "ECS",
+	dns.EDNS0EXPIRE:       "EXPIRE",
+	dns.EDNS0COOKIE:       "COOKIE",
+	dns.EDNS0TCPKEEPALIVE: "TCP-KEEPALIVE",
+	dns.EDNS0PADDING:      "PADDING",
+	dns.EDNS0EDE:          "EDE",
+}
+
+// newMessageEDNS creates a messageEDNS out of the OPT record of a message.
+func newMessageEDNS(opt *dns.OPT) *messageEDNS {
+	edns := &messageEDNS{
+		UDPSize:  opt.UDPSize(),
+		DNSSECOK: opt.Do(),
+		Version:  opt.Version(),
+		Options:  make([]ednsOption, 0, len(opt.Option)),
+	}
+
+	for _, option := range opt.Option {
+		edns.Options = append(edns.Options, ednsOption{
+			Code: option.Option(),
+			Name: ednsOptionNames[option.Option()],
+			Data: option.String(),
+		})
+	}
+
+	return edns
+}
+
+// newMessageResult creates a messageResult out of a message received from a nameserver.
+func newMessageResult(msg *dns.Msg, duration time.Duration) *messageResult {
+	result := &messageResult{
+		ID:         msg.Id,
+		Opcode:     opcodeString(msg.Opcode),
+		Rcode:      dns.RcodeToString[msg.Rcode],
+		Flags:      messageFlags(msg),
+		Questions:  make([]messageQuestion, 0, len(msg.Question)),
+		Answers:    newRecords(msg.Answer),
+		Authority:  newRecords(msg.Ns),
+		Additional: additionalRecords(msg),
+		RTT:        float64(duration) / float64(time.Millisecond),
+		msg:        msg,
+	}
+
+	for _, question := range msg.Question {
+		result.Questions = append(result.Questions, messageQuestion{
+			Name:  strings.TrimSuffix(question.Name, "."),
+			Type:  dns.TypeToString[question.Qtype],
+			Class: dns.ClassToString[question.Qclass],
+		})
+	}
+
+	if opt := msg.IsEdns0(); opt != nil {
+		result.EDNS = newMessageEDNS(opt)
+	}
+
+	return result
+}
+
+// additionalRecords returns the records of the additional section of the message,
+// leaving out the EDNS0 OPT and TSIG pseudo-records.
+func additionalRecords(msg *dns.Msg) []Record {
+	records := make([]Record, 0, len(msg.Extra))
+	for _, rr := range msg.Extra {
+		switch rr.(type) {
+		case *dns.OPT:
+			continue
+		case *dns.TSIG:
+			// The signature of the message is verified when it is received
+			continue
+		}
+
+		records = append(records, newRecord(rr))
+	}
+
+	return records
+}
+
+// messageFlags returns the header flags of the message, by name.
+func messageFlags(msg *dns.Msg) map[string]bool {
+	return map[string]bool{
+		"qr": msg.Response,
+		"aa": msg.Authoritative,
+		"tc": msg.Truncated,
+		"rd": msg.RecursionDesired,
+		"ra": msg.RecursionAvailable,
+		"z":  msg.Zero,
+		"ad": msg.AuthenticatedData,
+		"cd": msg.CheckingDisabled,
+	}
+}
+
+// messageSchemaVersion is the version of the schema messages are serialized to
+// by ToJSON, bumped whenever it changes incompatibly.
+const messageSchemaVersion = 1
+
+// messageJSON is the stable serialization of a message, holding its content but
+// none of the details of its exchange, so that messages can be diffed offline.
+type messageJSON struct {
+	// Schema holds the version of the schema the message is serialized to.
+	Schema int `js:"schema"`
+
+	// Opcode and Rcode hold the names of the opcode and response code of the
+	// message.
+	Opcode string `js:"opcode"`
+	Rcode  string `js:"rcode"`
+
+	// Flags holds the header flags of the message.
+	Flags messageFlagsJSON `js:"flags"`
+
+	// Question holds the questions of the message.
+	Question []messageQuestion `js:"question"`
+
+	// Answer, Authority and Additional hold the records of the sections of the
+	// message, sorted by name, type and data.
+	Answer     []Record `js:"answer"`
+	Authority  []Record `js:"authority"`
+	Additional []Record `js:"additional"`
+
+	// EDNS holds the EDNS0 parameters of the message, or nil if it has none.
+	EDNS *messageEDNS `js:"edns"`
+}
+
+// messageFlagsJSON holds the header flags of a message, in a fixed order.
+type messageFlagsJSON struct {
+	QR bool `js:"qr"`
+	AA bool `js:"aa"`
+	TC bool `js:"tc"`
+	RD bool `js:"rd"`
+	RA bool `js:"ra"`
+	Z  bool `js:"z"`
+	AD bool `js:"ad"`
+	CD bool `js:"cd"`
+}
+
+// ToJSON serializes the message to a stable schema, which JSON.stringify relies
+// on. The ID of the message and the details of its exchange, such as its RTT,
+// are left out, and records are sorted, so that responses only differ when their
+// content does.
+func (r *messageResult) ToJSON() messageJSON {
+	return messageJSON{
+		Schema: messageSchemaVersion,
+		Opcode: r.Opcode,
+		Rcode:  r.Rcode,
+		Flags: messageFlagsJSON{
+			QR: r.Flags["qr"],
+			AA: r.Flags["aa"],
+			TC: r.Flags["tc"],
+			RD: r.Flags["rd"],
+			RA: r.Flags["ra"],
+			Z:  r.Flags["z"],
+			AD: r.Flags["ad"],
+			CD: r.Flags["cd"],
+		},
+		Question:   r.Questions,
+		Answer:     sortedRecords(r.Answers),
+		Authority:  sortedRecords(r.Authority),
+		Additional: sortedRecords(r.Additional),
+		EDNS:       r.EDNS,
+	}
+}
+
+// Formats messages can be formatted to by Format.
+const (
+	// messageFormatDig formats messages as dig does.
+	messageFormatDig = "dig"
+
+	// messageFormatShort formats messages as dig +short does, holding the data of
+	// the records of the answer section only.
+	messageFormatShort = "short"
+)
+
+// ToString formats the message as dig does, for it to be logged.
+func (r *messageResult) ToString() string {
+	formatted, _ := r.Format(messageFormatDig)
+
+	return formatted
+}
+
+// Format formats the message in the provided format, either "dig" or "short".
+func (r *messageResult) Format(format string) (string, error) {
+	switch strings.ToLower(format) {
+	case messageFormatDig:
+		var b strings.Builder
+
+		// The dns package formats messages as dig does, short of its header marker,
+		// and of the names of unassigned opcodes
+		formatted := strings.Replace(r.msg.String(), ";; opcode: ,", ";; opcode: "+r.Opcode+",", 1)
+
+		b.WriteString(";; Got answer:\n")
+		b.WriteString(strings.Replace(formatted, ";; opcode:", ";; ->>HEADER<<- opcode:", 1))
+		fmt.Fprintf(&b, "\n;; Query time: %d msec\n", int64(math.Round(r.RTT)))
+
+		if r.Server != nil {
+			host, port, _ := net.SplitHostPort(r.Server.Address)
+			fmt.Fprintf(&b, ";; SERVER: %s#%s(%s) (%s)\n", host, port, host, strings.ToUpper(r.Server.Protocol))
+		}
+
+		fmt.Fprintf(&b, ";; MSG SIZE  rcvd: %d\n", r.Size)
+
+		return b.String(), nil
+	case messageFormatShort:
+		var b strings.Builder
+
+		for _, record := range r.Answers {
+			b.WriteString(record.Data)
+			b.WriteByte('\n')
+		}
+
+		return b.String(), nil
+	default:
+		return "", fmt.Errorf("unsupported format %q; must be one of %s or %s", format, messageFormatDig, messageFormatShort)
+	}
+}
+
+// sortedRecords returns a copy of the records sorted by name, type and data,
+// case-insensitively, so that the rotation of round-robin records does not
+// affect their serialization.
+func sortedRecords(records []Record) []Record {
+	sorted := append([]Record{}, records...)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+
+		if name := strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)); name != 0 {
+			return name < 0
+		}
+
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+
+		return strings.ToLower(a.Data) < strings.ToLower(b.Data)
+	})
+
+	return sorted
+}
+
+// newRecords creates Records out of resource records of the dns package.
+func newRecords(rrs []dns.RR) []Record {
+	records := make([]Record, 0, len(rrs))
+	for _, rr := range rrs {
+		records = append(records, newRecord(rr))
+	}
+
+	return records
+}
+
+// SendMessage sends a message built with NewMessage to the nameserver, and resolves
+// to the message received in response.
+//
+// As opposed to Resolve, the promise is not rejected when the response holds a
+// response code other than NOERROR, so that it can be inspected.
+func (mi *ModuleInstance) SendMessage(message, nameserverAddr, options sobek.Value) *sobek.Promise {
+	promise, resolve, reject := promises.New(mi.vu)
+
+	if mi.vu.State() == nil {
+		reject(errors.New("sendMessage can not be used in the init context"))
+		return promise
+	}
+
+	msg, ok := exportMessage(message)
+	if !ok {
+		reject(fmt.Errorf("message must be built with newMessage(); got %v instead", message))
+		return promise
+	}
+
+	// Only queries require a question, as opposed to e.g. STATUS messages
+	if len(msg.Question) == 0 && msg.Opcode == dns.OpcodeQuery {
+		reject(errors.New("message must hold at least one question"))
+		return promise
+	}
+
+	nameserver, err := mi.exportNameserver(nameserverAddr)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	opts, err := parseResolveOptions(mi.vu.Runtime(), options)
+	if err != nil {
+		reject(fmt.Errorf("invalid sendMessage options: %w", err))
+		return promise
+	}
+
+	ctx, cancel, err := mi.withAbortSignal(opts.Signal)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer cancel()
+
+		response, duration, err := mi.sendMessage(ctx, msg, nameserver, opts.QueryOptions)
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		result := newMessageResult(response.msg, duration)
+		result.Size = response.Size
+		result.TLS = response.TLS
+		result.HTTPCache = response.HTTPCache
+		result.Server = newServerInfo(response)
+		if opts.Raw {
+			result.Raw = newRawMessages(response)
+		}
+
+		resolve(result)
+	}()
+
+	return promise
+}
+
+// sendMessage sends the message to the nameserver, and accounts for it in the
+// metrics and the end-of-test summary, as a resolution of its first question, if
+// it has any.
+func (mi *ModuleInstance) sendMessage(
+	ctx context.Context,
+	msg *dns.Msg,
+	nameserver Nameserver,
+	opts QueryOptions,
+) (*Response, time.Duration, error) {
+	attrs := []attribute.KeyValue{attrNameserver.String(nameserver.Addr())}
+	if len(msg.Question) > 0 {
+		attrs = append(attrs,
+			attrQuestionName.String(strings.TrimSuffix(msg.Question[0].Name, ".")),
+			attrQuestionType.String(dns.TypeToString[msg.Question[0].Qtype]),
+		)
+	}
+
+	ctx, span := mi.startSpan(ctx, "dns.sendMessage", attrs...)
+
+	// Start timer for the exchange
+	startTime := time.Now()
+
+	// Send the message
+	response, err := mi.dnsClient.Send(ctx, msg, nameserver, opts)
+
+	endExchangeSpan(span, opts.withDefaults(mi.queryDefaults()).Protocol, response, err)
+
+	// Stop the timer for the exchange, which excludes the time spent waiting for
+	// the client's limits
+	duration := time.Since(startTime)
+	if response != nil {
+		duration -= response.Throttled
+	}
+
+	// Emit the metrics, regardless of the result, tagged with the first question
+	// of the message if it has any
+	var query, recordType string
+	if len(msg.Question) > 0 {
+		query = strings.TrimSuffix(msg.Question[0].Name, ".")
+		recordType = dns.TypeToString[msg.Question[0].Qtype]
+	}
+
+	mi.emitResolutionMetrics(
+		mi.vu.Context(),
+		duration.Milliseconds(),
+		query,
+		recordType,
+		nameserver,
+		response,
+		err,
+	)
+
+	// Account for the exchange in the end-of-test summary
+	mi.summary.record(nameserver.Addr(), duration, response, err != nil)
+
+	mi.logExchange(opts, nameserver, response, duration, err)
+
+	return response, duration, err
+}
+
+// exportMessage exports the DNS message built by a Message from the JS runtime.
+func exportMessage(value sobek.Value) (*dns.Msg, bool) {
+	if value == nil {
+		return nil, false
+	}
+
+	message, ok := value.Export().(*Message)
+	if !ok || message == nil {
+		return nil, false
+	}
+
+	// Copy the message, so that later changes to it do not affect this exchange
+	return message.msg.Copy(), true
+}
@@ -0,0 +1,345 @@
+package dns
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+)
+
+func TestMessage(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		build   func(m *Message) (*Message, error)
+		check   func(t *testing.T, msg *dns.Msg)
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:  "defaults",
+			build: func(m *Message) (*Message, error) { return m, nil },
+			check: func(t *testing.T, msg *dns.Msg) {
+				assert.True(t, msg.RecursionDesired)
+				assert.Equal(t, dns.OpcodeQuery, msg.Opcode)
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:  "ID",
+			build: func(m *Message) (*Message, error) { return m.SetID(4242) },
+			check: func(t *testing.T, msg *dns.Msg) {
+				assert.Equal(t, uint16(4242), msg.Id)
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "out of range ID",
+			build:   func(m *Message) (*Message, error) { return m.SetID(65536) },
+			wantErr: assert.Error,
+		},
+		{
+			name:  "opcode",
+			build: func(m *Message) (*Message, error) { return m.SetOpcode("notify") },
+			check: func(t *testing.T, msg *dns.Msg) {
+				assert.Equal(t, dns.OpcodeNotify, msg.Opcode)
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "unknown opcode",
+			build:   func(m *Message) (*Message, error) { return m.SetOpcode("PING") },
+			wantErr: assert.Error,
+		},
+		{
+			name:  "numeric opcode",
+			build: func(m *Message) (*Message, error) { return m.SetOpcode("2") },
+			check: func(t *testing.T, msg *dns.Msg) {
+				assert.Equal(t, dns.OpcodeStatus, msg.Opcode)
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:  "unassigned opcode",
+			build: func(m *Message) (*Message, error) { return m.SetOpcode("opcode15") },
+			check: func(t *testing.T, msg *dns.Msg) {
+				assert.Equal(t, 15, msg.Opcode)
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "out of range opcode",
+			build:   func(m *Message) (*Message, error) { return m.SetOpcode("16") },
+			wantErr: assert.Error,
+		},
+		{
+			name:  "flags",
+			build: func(m *Message) (*Message, error) { return m.SetFlag("rd", false) },
+			check: func(t *testing.T, msg *dns.Msg) {
+				assert.False(t, msg.RecursionDesired)
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "unknown flag",
+			build:   func(m *Message) (*Message, error) { return m.SetFlag("xx", true) },
+			wantErr: assert.Error,
+		},
+		{
+			name:  "question with default class",
+			build: func(m *Message) (*Message, error) { return m.AddQuestion("k6.io", "https", "") },
+			check: func(t *testing.T, msg *dns.Msg) {
+				require.Len(t, msg.Question, 1)
+				assert.Equal(t, dns.Question{Name: "k6.io.", Qtype: dns.TypeHTTPS, Qclass: dns.ClassINET}, msg.Question[0])
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "question with unknown record type",
+			build:   func(m *Message) (*Message, error) { return m.AddQuestion("k6.io", "BOGUS", "") },
+			wantErr: assert.Error,
+		},
+		{
+			name:  "additional record",
+			build: func(m *Message) (*Message, error) { return m.AddRecord("additional", "k6.io. 60 IN A 1.2.3.4") },
+			check: func(t *testing.T, msg *dns.Msg) {
+				assert.Len(t, msg.Extra, 1)
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "record in unknown section",
+			build:   func(m *Message) (*Message, error) { return m.AddRecord("question", "k6.io. 60 IN A 1.2.3.4") },
+			wantErr: assert.Error,
+		},
+		{
+			name:    "invalid record",
+			build:   func(m *Message) (*Message, error) { return m.AddRecord("answer", "k6.io. 60 IN A nope") },
+			wantErr: assert.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := tt.build(NewMessage())
+			if !tt.wantErr(t, err) || err != nil {
+				return
+			}
+
+			tt.check(t, &got.msg)
+		})
+	}
+}
+
+func Test_newMessageResult(t *testing.T) {
+	t.Parallel()
+
+	message, err := NewMessage().SetID(1)
+	require.NoError(t, err)
+
+	_, err = message.AddQuestion("k6.io", "A", "")
+	require.NoError(t, err)
+
+	_, err = message.AddRecord("additional", "ns1.k6.io. 60 IN A 1.2.3.4")
+	require.NoError(t, err)
+
+	_, err = message.SetEDNS(1232, true)
+	require.NoError(t, err)
+
+	got := newMessageResult(&message.msg, 2*time.Millisecond)
+
+	assert.Equal(t, uint16(1), got.ID)
+	assert.Equal(t, "QUERY", got.Opcode)
+	assert.Equal(t, "NOERROR", got.Rcode)
+	assert.True(t, got.Flags["rd"])
+	assert.Equal(t, []messageQuestion{{Name: "k6.io", Type: "A", Class: "IN"}}, got.Questions)
+	assert.Equal(t, []Record{{Name: "ns1.k6.io", Type: "A", TTL: 60, Data: "1.2.3.4"}}, got.Additional)
+	assert.Equal(t, &messageEDNS{UDPSize: 1232, DNSSECOK: true, Options: []ednsOption{}}, got.EDNS)
+	assert.InDelta(t, 2.0, got.RTT, 0.001)
+}
+
+func Test_messageResult_ToJSON(t *testing.T) {
+	t.Parallel()
+
+	message, err := NewMessage().SetID(1)
+	require.NoError(t, err)
+
+	_, err = message.AddQuestion("k6.io", "A", "")
+	require.NoError(t, err)
+
+	for _, record := range []string{"k6.io. 60 IN A 192.0.2.2", "K6.io. 60 IN A 192.0.2.1"} {
+		_, err = message.AddRecord("answer", record)
+		require.NoError(t, err)
+	}
+
+	got := newMessageResult(&message.msg, 2*time.Millisecond).ToJSON()
+
+	assert.Equal(t, messageJSON{
+		Schema:   messageSchemaVersion,
+		Opcode:   "QUERY",
+		Rcode:    "NOERROR",
+		Flags:    messageFlagsJSON{RD: true},
+		Question: []messageQuestion{{Name: "k6.io", Type: "A", Class: "IN"}},
+		Answer: []Record{
+			{Name: "K6.io", Type: "A", TTL: 60, Data: "192.0.2.1"},
+			{Name: "k6.io", Type: "A", TTL: 60, Data: "192.0.2.2"},
+		},
+		Authority:  []Record{},
+		Additional: []Record{},
+	}, got)
+}
+
+func TestModuleInstance_SendMessage(t *testing.T) {
+	t.Parallel()
+
+	runtime, err := newConfiguredRuntime(t)
+	require.NoError(t, err)
+
+	_, err = runtime.VU.Runtime().RunString(`
+		const server = dns.startServer(["k6.test. 60 IN A 203.0.113.2", "k6.test. 60 IN A 203.0.113.1"]);
+	`)
+	require.NoError(t, err)
+
+	runtime.MoveToVUContext(&lib.State{
+		BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+		Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+		Samples:        make(chan metrics.SampleContainer, 1024),
+	})
+
+	_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+		const response = await dns.sendMessage(dns.newMessage().addQuestion("k6.test", "A"), server.address);
+
+		const serialized = JSON.stringify(response);
+		if (serialized !== JSON.stringify(response.toJSON())) {
+			throw new Error("expected JSON.stringify to rely on toJSON; got " + serialized);
+		}
+
+		const json = JSON.parse(serialized);
+		if (Object.keys(json).join() !== "schema,opcode,rcode,flags,question,answer,authority,additional,edns" ||
+			Object.keys(json.flags).join() !== "qr,aa,tc,rd,ra,z,ad,cd" || "id" in json || "rtt" in json) {
+			throw new Error("unexpected schema: " + serialized);
+		}
+
+		if (json.answer.map((record) => record.data).join() !== "203.0.113.1,203.0.113.2") {
+			throw new Error("expected the answers to be sorted; got " + serialized);
+		}
+
+		const dig = response.toString();
+		if (!dig.includes(";; ->>HEADER<<- opcode: QUERY, status: NOERROR") ||
+			!dig.includes(";; SERVER: " + server.address.replace(":", "#")) || !dig.includes("(UDP)") ||
+			!dig.includes(";; MSG SIZE  rcvd: " + response.size) || String(response) !== dig) {
+			throw new Error("unexpected dig output: " + dig);
+		}
+
+		if (response.format("short") !== "203.0.113.2\n203.0.113.1\n") {
+			throw new Error("unexpected short output: " + response.format("short"));
+		}
+
+		try {
+			response.format("yaml");
+			throw new Error("expected the yaml format to be unsupported");
+		} catch (e) {
+			if (!String(e).includes("unsupported format")) {
+				throw e;
+			}
+		}
+	`))
+	assert.NoError(t, err)
+}
+
+func TestModuleInstance_SendMessage_multipleQuestions(t *testing.T) {
+	t.Parallel()
+
+	runtime, err := newConfiguredRuntime(t)
+	require.NoError(t, err)
+
+	_, err = runtime.VU.Runtime().RunString(`
+		const server = dns.startServer(["k6.test. 60 IN A 203.0.113.1", "k6.test. 60 IN AAAA 2001:db8::1"]);
+	`)
+	require.NoError(t, err)
+
+	runtime.MoveToVUContext(&lib.State{
+		BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+		Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+		Samples:        make(chan metrics.SampleContainer, 1024),
+	})
+
+	_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+		const message = dns.newMessage().addQuestion("k6.test", "A").addQuestion("k6.test", "AAAA");
+		const response = await dns.sendMessage(message, server.address, { raw: true });
+
+		// The question count of the request's header announces both questions
+		if (response.raw.request.substring(8, 12) !== "0002") {
+			throw new Error("expected the request to hold two questions; got " + response.raw.request);
+		}
+
+		if (response.rcode !== "FORMERR") {
+			throw new Error("expected multi-question messages to be rejected; got " + response.rcode);
+		}
+	`))
+	assert.NoError(t, err)
+}
+
+func TestModuleInstance_SendMessage_opcodes(t *testing.T) {
+	t.Parallel()
+
+	// The nameserver does not implement any opcode other than QUERY
+	handler := dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		response := new(dns.Msg)
+		response.SetRcode(req, dns.RcodeNotImplemented)
+		_ = w.WriteMsg(response)
+	})
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := &dns.Server{PacketConn: conn, Handler: handler}
+	go func() { _ = server.ActivateAndServe() }()
+	t.Cleanup(func() { _ = server.Shutdown() })
+
+	runtime, err := newConfiguredRuntime(t)
+	require.NoError(t, err)
+	require.NoError(t, runtime.VU.Runtime().Set("address", conn.LocalAddr().String()))
+
+	runtime.MoveToVUContext(&lib.State{
+		BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+		Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+		Samples:        make(chan metrics.SampleContainer, 1024),
+	})
+
+	_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+		// Messages other than queries need not hold a question
+		const status = await dns.sendMessage(dns.newMessage().setOpcode("STATUS"), address);
+		if (status.opcode !== "STATUS" || status.rcode !== "NOTIMP" || status.questions.length !== 0) {
+			throw new Error("unexpected STATUS response: " + JSON.stringify(status));
+		}
+
+		const unassigned = await dns.sendMessage(dns.newMessage().setOpcode(7).addQuestion("k6.test", "A"), address);
+		if (unassigned.opcode !== "OPCODE7" || unassigned.toJSON().opcode !== "OPCODE7") {
+			throw new Error("unexpected unassigned opcode response: " + JSON.stringify(unassigned));
+		}
+
+		if (!unassigned.toString().includes(";; ->>HEADER<<- opcode: OPCODE7, status: NOTIMP")) {
+			throw new Error("unexpected dig output: " + unassigned.toString());
+		}
+
+		try {
+			await dns.sendMessage(dns.newMessage(), address);
+			throw new Error("expected queries without questions to be rejected");
+		} catch (e) {
+			if (!String(e).includes("at least one question")) {
+				throw e;
+			}
+		}
+	`))
+	assert.NoError(t, err)
+}
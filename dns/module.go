@@ -3,8 +3,14 @@ package dns
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.k6.io/k6/js/common"
@@ -13,17 +19,72 @@ import (
 	"go.k6.io/k6/metrics"
 
 	"github.com/grafana/sobek"
+	"github.com/miekg/dns"
 )
 
 type (
 	// RootModule is the module that will be registered with the runtime.
-	RootModule struct{}
+	RootModule struct {
+		// openConnections tracks the number of connections to nameservers
+		// currently open, across all the VUs.
+		openConnections atomic.Int64
+
+		// summary aggregates the outcome of the resolutions performed by all the VUs.
+		summary *summary
+
+		// limiter limits the queries sent by all the VUs.
+		limiter *queryLimiter
+
+		// queryLists holds the query lists loaded by all the VUs.
+		queryLists *sharedFiles[*QueryList]
+
+		// captures holds the captures loaded by all the VUs.
+		captures *sharedFiles[*Capture]
+
+		// recorders holds the files the VUs record their traffic to.
+		recorders *trafficRecorders
+
+		// debugLog limits the rate at which all the VUs log exchanges.
+		debugLog *debugLog
+
+		// sharedConns holds the connections the VUs whose instancing mode is shared
+		// send their queries over.
+		sharedConns *sharedConnections
+
+		// sourcePorts holds the distinct ports all the VUs sent queries from over UDP.
+		sourcePorts *portSet
+
+		// instances holds the module instance of each VU, which the VUResolvers
+		// created for the VU share.
+		instancesMu sync.Mutex
+		instances   map[modules.VU]*ModuleInstance
+
+		// clients holds the clients of all the VUs, which are shut down at the end
+		// of the test.
+		clientsMu   sync.Mutex
+		clients     []*Client
+		testEndOnce sync.Once
+	}
 
 	// ModuleInstance is the module instance that will be created for each VU.
 	ModuleInstance struct {
-		vu        modules.VU
-		dnsClient *Client
-		metrics   *moduleInstanceMetrics
+		vu            modules.VU
+		dnsClient     *Client
+		metrics       *moduleInstanceMetrics
+		summary       *summary
+		queryLists    *sharedFiles[*QueryList]
+		captures      *sharedFiles[*Capture]
+		recorders     *trafficRecorders
+		debugLog      *debugLog
+		sharedConns   *sharedConnections
+		sourcePorts   *portSet
+		teardownMu    sync.Mutex
+		teardown      context.Context
+		pinnedHosts   map[string]string
+		configMu      sync.Mutex
+		configCtx     context.Context
+		currentConfig scenarioConfig
+		configErr     error
 	}
 )
 
@@ -35,33 +96,151 @@ var (
 
 // New creates a new RootModule instance.
 func New() *RootModule {
-	return &RootModule{}
+	return &RootModule{
+		summary:     newSummary(),
+		limiter:     newQueryLimiter(),
+		queryLists:  newSharedFiles[*QueryList](),
+		captures:    newSharedFiles[*Capture](),
+		recorders:   newTrafficRecorders(),
+		debugLog:    newDebugLog(defaultDebugRate),
+		sharedConns: newSharedConnections(),
+		sourcePorts: new(portSet),
+		instances:   make(map[modules.VU]*ModuleInstance),
+	}
 }
 
-// NewModuleInstance creates a new instance of the module for a specific VU.
+// NewModuleInstance creates a new instance of the module for a specific VU, or
+// returns the one a VUResolver created for it already.
 func (rm *RootModule) NewModuleInstance(vu modules.VU) modules.Instance {
+	return rm.instance(vu)
+}
+
+// instance returns the module instance of the VU, creating it on first use, so
+// that the VU's script and the VUResolvers created for it share their client.
+func (rm *RootModule) instance(vu modules.VU) *ModuleInstance {
+	rm.instancesMu.Lock()
+	defer rm.instancesMu.Unlock()
+
+	if mi, ok := rm.instances[vu]; ok {
+		return mi
+	}
+
+	mi := rm.newModuleInstance(vu)
+	rm.instances[vu] = mi
+
+	return mi
+}
+
+// newModuleInstance creates a new instance of the module for the VU.
+func (rm *RootModule) newModuleInstance(vu modules.VU) *ModuleInstance {
 	instanceMetrics, err := registerMetrics(metrics.NewRegistry())
 	if err != nil {
 		common.Throw(vu.Runtime(), fmt.Errorf("failed to register dns module instance's metrics; reason: %w", err))
 	}
 
-	return &ModuleInstance{
-		vu:        vu,
-		dnsClient: NewDNSClient(),
-		metrics:   instanceMetrics,
+	dnsClient := NewDNSClient()
+	dnsClient.openConnections = &rm.openConnections
+	dnsClient.limiter = rm.limiter
+	dnsClient.sharedConns = rm.sharedConns.connections
+
+	// DoT connections honor the TLS options of k6, e.g. insecureSkipTLSVerify
+	dnsClient.tlsConfig = func() *tls.Config {
+		if state := vu.State(); state != nil && state.TLSConfig != nil {
+			return state.TLSConfig.Clone()
+		}
+
+		return nil
+	}
+
+	mi := &ModuleInstance{
+		vu:          vu,
+		dnsClient:   dnsClient,
+		metrics:     instanceMetrics,
+		summary:     rm.summary,
+		queryLists:  rm.queryLists,
+		captures:    rm.captures,
+		recorders:   rm.recorders,
+		debugLog:    rm.debugLog,
+		sharedConns: rm.sharedConns,
+		sourcePorts: rm.sourcePorts,
 	}
+
+	// Queries which do not set their transport options use those configured
+	// through options.ext.dns and the environment
+	dnsClient.defaults = mi.queryDefaults
+	dnsClient.shared = mi.sharedInstancing
+
+	rm.register(vu, dnsClient)
+
+	return mi
 }
 
 // Exports returns the module exports, that will be available in the runtime.
 func (mi *ModuleInstance) Exports() modules.Exports {
 	return modules.Exports{Named: map[string]interface{}{
-		"resolve": mi.Resolve,
-		"lookup":  mi.Lookup,
+		"resolve":              mi.Resolve,
+		"resolveSync":          mi.ResolveSync,
+		"resolveBatch":         mi.ResolveBatch,
+		"resolveBatchStream":   mi.ResolveBatchStream,
+		"resolveAll":           mi.ResolveAll,
+		"resolveWordlist":      mi.ResolveWordlist,
+		"monitorTTL":           mi.MonitorTTL,
+		"trace":                mi.Trace,
+		"checkDelegation":      mi.CheckDelegation,
+		"lookup":               mi.Lookup,
+		"lookupSync":           mi.LookupSync,
+		"lookupService":        mi.LookupService,
+		"lookupAddr":           mi.LookupAddr,
+		"lookupFastest":        mi.LookupFastest,
+		"verifyTLSA":           mi.VerifyTLSA,
+		"signatureExpiry":      mi.SignatureExpiry,
+		"checkZone":            mi.CheckZone,
+		"walkZone":             mi.WalkZone,
+		"newMessage":           NewMessage,
+		"sendMessage":          mi.SendMessage,
+		"newUpdate":            NewUpdate,
+		"update":               mi.Update,
+		"notify":               mi.Notify,
+		"waitForSerial":        mi.WaitForSerial,
+		"waitForRecord":        mi.WaitForRecord,
+		"compare":              mi.Compare,
+		"compareTransports":    mi.CompareTransports,
+		"canary":               mi.Canary,
+		"fuzz":                 mi.Fuzz,
+		"probeRRL":             mi.ProbeRRL,
+		"probeOpenResolver":    mi.ProbeOpenResolver,
+		"measureAmplification": mi.MeasureAmplification,
+		"diff":                 mi.Diff,
+		"randomName":           mi.RandomName,
+		"checkPropagation":     mi.CheckPropagation,
+		"publicResolvers":      publicResolvers(),
+		"expect":               mi.Expect,
+		"checkGeo":             mi.CheckGeo,
+		"discoverNAT64Prefix":  mi.DiscoverNAT64Prefix,
+		"discoverResolvers":    mi.DiscoverResolvers,
+		"extractIPv4":          ExtractIPv4,
+		"browse":               mi.Browse,
+		"startServer":          mi.StartServer,
+		"loadQueryList":        mi.LoadQueryList,
+		"loadPcap":             mi.LoadPcap,
+		"replay":               mi.Replay,
+		"recordTraffic":        mi.RecordTraffic,
+		"packQueries":          mi.PackQueries,
+		"benchmark":            mi.Benchmark,
+		"profile":              mi.Profile,
+		"constantRate":         mi.ConstantRate,
+		"configure":            mi.Configure,
+		"pinHost":              mi.PinHost,
+		"unpinHost":            mi.UnpinHost,
+		"toASCII":              ToASCII,
+		"toUnicode":            ToUnicode,
+		"summary":              mi.Summary,
+		"textSummary":          mi.TextSummary,
 	}}
 }
 
 // Resolve resolves a domain name to an IP address.
-func (mi *ModuleInstance) Resolve(query, recordType, nameserverAddr sobek.Value) *sobek.Promise {
+func (mi *ModuleInstance) Resolve(query, recordType, nameserverAddr, options sobek.Value) *sobek.Promise {
 	promise, resolve, reject := promises.New(mi.vu)
 
 	if mi.vu.State() == nil {
@@ -69,88 +248,397 @@ func (mi *ModuleInstance) Resolve(query, recordType, nameserverAddr sobek.Value)
 		return promise
 	}
 
-	if nameserverAddr == nil {
-		reject(errors.New("nameserver argument must be provided"))
+	args, err := mi.parseResolveArgs(query, recordType, nameserverAddr, options)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, cancel, err := mi.withAbortSignal(args.opts.Signal)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer cancel()
+
+		result, err := mi.resolve(ctx, args)
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(result)
+	}()
+
+	return promise
+}
+
+// ResolveSync is the synchronous counterpart of Resolve. It blocks until the
+// resolution completes, and returns its result or throws its error directly.
+func (mi *ModuleInstance) ResolveSync(query, recordType, nameserverAddr, options sobek.Value) interface{} {
+	if mi.vu.State() == nil {
+		mi.throw(errors.New("resolveSync can not be used in the init context"))
+	}
+
+	args, err := mi.parseResolveArgs(query, recordType, nameserverAddr, options)
+	if err != nil {
+		mi.throw(err)
+	}
+
+	ctx, cancel, err := mi.withAbortSignal(args.opts.Signal)
+	if err != nil {
+		mi.throw(err)
+	}
+	defer cancel()
+
+	result, err := mi.resolve(ctx, args)
+	if err != nil {
+		mi.throw(err)
+	}
+
+	return result
+}
+
+// resolveArgs holds the arguments of a resolve call, once exported from the JS runtime.
+type resolveArgs struct {
+	query      string
+	recordType string
+	nameserver Nameserver
+	opts       resolveOptions
+}
+
+// parseResolveArgs exports and validates the arguments of a resolve call.
+func (mi *ModuleInstance) parseResolveArgs(
+	query, recordType, nameserverAddr, options sobek.Value,
+) (resolveArgs, error) {
+	args := resolveArgs{}
+
+	var err error
+	if args.query, err = exportDomainName(mi.vu.Runtime(), query, "query"); err != nil {
+		return args, err
+	}
+
+	if err := mi.vu.Runtime().ExportTo(recordType, &args.recordType); err != nil {
+		return args, fmt.Errorf("recordType must be a string; got %v instead", recordType)
+	}
+
+	if args.nameserver, err = mi.exportNameserver(nameserverAddr); err != nil {
+		return args, err
+	}
+
+	if args.opts, err = parseResolveOptions(mi.vu.Runtime(), options); err != nil {
+		return args, fmt.Errorf("invalid resolve options: %w", err)
+	}
+
+	return args, nil
+}
+
+// resolve performs the resolution described by args, and returns the value a
+// resolve call should result in.
+func (mi *ModuleInstance) resolve(ctx context.Context, args resolveArgs) (interface{}, error) {
+	response, resolutionDuration, resolveErr := mi.resolveQuery(ctx, args.query, args.recordType, args.nameserver, args.opts)
+
+	// When instructed not to throw, the outcome of the resolution is returned as is
+	if !args.opts.Throw {
+		result := newResolveResult(args.query, args.recordType, response, resolveErr, resolutionDuration)
+		result.limitAnswers(args.opts.MaxAnswers)
+		if args.opts.Raw {
+			result.Raw = newRawMessages(response)
+		}
+
+		if args.opts.Sections {
+			result.includeSections(response)
+		}
+
+		return result, nil
+	}
+
+	// Handle the resolution failure only now that we have emitted the metrics
+	if resolveErr != nil {
+		return nil, resolveErr
+	}
+
+	if args.opts.MaxAnswers > 0 && len(response.Answers) > args.opts.MaxAnswers {
+		return response.Answers[:args.opts.MaxAnswers], nil
+	}
+
+	return response.Answers, nil
+}
+
+// resolveQuery resolves the query against the nameserver, emits the resolution's metrics,
+// and accounts for it in the end-of-test summary. It returns the response along
+// with the resolution's duration.
+//
+// It does not interact with the runtime, and thus can be called from any goroutine.
+func (mi *ModuleInstance) resolveQuery(
+	ctx context.Context,
+	query, recordType string,
+	nameserver Nameserver,
+	opts resolveOptions,
+) (*Response, time.Duration, error) {
+	ctx, span := mi.startSpan(ctx, "dns.resolve",
+		attrQuestionName.String(query),
+		attrQuestionType.String(recordType),
+		attrNameserver.String(nameserver.Addr()),
+	)
+
+	// Start timer for resolution
+	resolutionStartTime := time.Now()
+
+	// Resolve the query
+	response, resolveErr := mi.dnsClient.Query(ctx, query, recordType, nameserver, opts.QueryOptions)
+
+	// Stop the timer for resolution, which excludes the time spent waiting for the
+	// client's limits
+	resolutionDuration := time.Since(resolutionStartTime)
+	if response != nil {
+		resolutionDuration -= response.Throttled
+	}
+
+	// Treat non-existing domains as resolving to no answers, if instructed to
+	if opts.NXDomainAsEmpty && response != nil && response.Rcode == dns.RcodeToString[dns.RcodeNameError] {
+		response.Answers = []string{}
+		resolveErr = nil
+	}
+
+	// Treat responses which are not authoritative as errors, if instructed to
+	if opts.RequireAuthoritative && resolveErr == nil && response != nil && response.msg != nil &&
+		!response.msg.Authoritative {
+		resolveErr = &Error{
+			Name:       NotAuthoritative.String(),
+			Message:    fmt.Sprintf("the response of %s for %s is not authoritative", nameserver.Addr(), query),
+			Kind:       NotAuthoritative,
+			Rcode:      response.Rcode,
+			Nameserver: nameserver.Addr(),
+		}
+	}
+
+	endExchangeSpan(span, opts.withDefaults(mi.queryDefaults()).Protocol, response, resolveErr)
+
+	// Emit the metrics, regardless of the result
+	mi.emitResolutionMetrics(
+		mi.vu.Context(),
+		resolutionDuration.Milliseconds(),
+		query,
+		recordType,
+		nameserver,
+		response,
+		resolveErr,
+	)
+
+	// Account for the resolution in the end-of-test summary
+	mi.summary.record(nameserver.Addr(), resolutionDuration, response, resolveErr != nil)
+
+	mi.logExchange(opts.QueryOptions, nameserver, response, resolutionDuration, resolveErr)
+
+	return response, resolutionDuration, resolveErr
+}
+
+// Lookup resolves a domain name to an IP address using the default system nameservers.
+func (mi *ModuleInstance) Lookup(hostname, options sobek.Value) *sobek.Promise {
+	promise, resolve, reject := promises.New(mi.vu)
+
+	if mi.vu.State() == nil {
+		reject(fmt.Errorf("lookup can not be used in the init context"))
 		return promise
 	}
 
-	var queryStr string
-	if err := mi.vu.Runtime().ExportTo(query, &queryStr); err != nil {
-		reject(fmt.Errorf("query must be a string; got %v instead", query))
+	hostnameStr, opts, err := mi.parseLookupArgs(hostname, options)
+	if err != nil {
+		reject(err)
 		return promise
 	}
 
-	var recordTypeStr string
-	if err := mi.vu.Runtime().ExportTo(recordType, &recordTypeStr); err != nil {
-		reject(fmt.Errorf("recordType must be a string; got %v instead", recordType))
+	// Lookups are subject to the same network restrictions as the rest of k6
+	lookuper := newRestrictedLookuper(mi.dnsClient, mi.vu.State().Options)
+
+	go func() {
+		result, err := mi.lookup(mi.vu.Context(), lookuper, hostnameStr, opts)
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(result)
+	}()
+
+	return promise
+}
+
+// LookupSync is the synchronous counterpart of Lookup. It blocks until the
+// lookup completes, and returns its result or throws its error directly.
+func (mi *ModuleInstance) LookupSync(hostname, options sobek.Value) interface{} {
+	if mi.vu.State() == nil {
+		mi.throw(errors.New("lookupSync can not be used in the init context"))
+	}
+
+	hostnameStr, opts, err := mi.parseLookupArgs(hostname, options)
+	if err != nil {
+		mi.throw(err)
+	}
+
+	// Lookups are subject to the same network restrictions as the rest of k6
+	lookuper := newRestrictedLookuper(mi.dnsClient, mi.vu.State().Options)
+
+	result, err := mi.lookup(mi.vu.Context(), lookuper, hostnameStr, opts)
+	if err != nil {
+		mi.throw(err)
+	}
+
+	return result
+}
+
+// parseLookupArgs exports and validates the arguments of a lookup call.
+func (mi *ModuleInstance) parseLookupArgs(hostname, options sobek.Value) (string, lookupOptions, error) {
+	hostnameStr, err := exportDomainName(mi.vu.Runtime(), hostname, "hostname")
+	if err != nil {
+		return "", lookupOptions{}, err
+	}
+
+	opts, err := parseLookupOptions(mi.vu.Runtime(), options)
+	if err != nil {
+		return "", lookupOptions{}, fmt.Errorf("invalid lookup options: %w", err)
+	}
+
+	return hostnameStr, opts, nil
+}
+
+// lookup performs the lookup of the hostname with the lookuper, and returns the
+// value a lookup call should result in.
+//
+// As it is called off the event loop, the lookuper enforcing k6's network
+// restrictions is created by the caller, out of the VU state.
+func (mi *ModuleInstance) lookup(
+	ctx context.Context,
+	lookuper Lookuper,
+	hostname string,
+	opts lookupOptions,
+) (interface{}, error) {
+	spanCtx, span := mi.startSpan(ctx, "dns.lookup", attrQuestionName.String(hostname))
+
+	// Start the timer for the lookup
+	lookupStartTime := time.Now()
+
+	// Perform the lookup
+	ips, lookupErr := lookuper.Lookup(spanCtx, hostname, opts.LookupOptions)
+
+	// Stop the timer for the lookup
+	sinceLookupStart := time.Since(lookupStartTime).Milliseconds()
+
+	if lookupErr == nil {
+		span.SetAttributes(attrAnswers.Int(len(ips)))
+	}
+
+	endSpan(span, lookupErr)
+
+	// Emit the metrics, regardless of the result
+	mi.emitLookupMetrics(
+		ctx,
+		sinceLookupStart,
+		hostname,
+		lookupErr,
+	)
+
+	// Handle the lookup failure only now that we have emitted the metrics
+	if lookupErr != nil {
+		return nil, lookupErr
+	}
+
+	// Only the preferred address is returned, unless all of them were requested
+	if !opts.All && len(ips) > 0 {
+		return ips[0], nil
+	}
+
+	return ips, nil
+}
+
+// LookupService looks up the endpoints of a service, as advertised by its SRV records,
+// along with the IP addresses of their targets, using the default system nameservers.
+func (mi *ModuleInstance) LookupService(name, options sobek.Value) *sobek.Promise {
+	promise, resolve, reject := promises.New(mi.vu)
+
+	if mi.vu.State() == nil {
+		reject(errors.New("lookupService can not be used in the init context"))
 		return promise
 	}
 
-	var nameserverAddrStr string
-	if err := mi.vu.Runtime().ExportTo(nameserverAddr, &nameserverAddrStr); err != nil {
-		reject(fmt.Errorf("nameserver must be a string; got %v instead", nameserverAddr))
+	nameStr, err := exportDomainName(mi.vu.Runtime(), name, "name")
+	if err != nil {
+		reject(err)
 		return promise
 	}
 
-	nameserver, err := parseNameserverAddr(nameserverAddrStr)
+	opts, err := parseLookupOptions(mi.vu.Runtime(), options)
 	if err != nil {
-		reject(fmt.Errorf("parsing nameserver address failed: %w", err))
+		reject(fmt.Errorf("invalid lookupService options: %w", err))
 		return promise
 	}
 
+	// Lookups are subject to the same network restrictions as the rest of k6
+	lookuper := newRestrictedLookuper(mi.dnsClient, mi.vu.State().Options)
+
 	go func() {
-		// Start timer for resolution
-		resolutionStartTime := time.Now()
+		// Start the timer for the lookup
+		lookupStartTime := time.Now()
 
-		// Resolve the query
-		fetchedIPs, resolveErr := mi.dnsClient.Resolve(mi.vu.Context(), queryStr, recordTypeStr, nameserver)
+		// Perform the lookup
+		endpoints, lookupErr := lookuper.LookupService(mi.vu.Context(), nameStr, opts.LookupOptions)
 
-		// Stop the timer for resolution
-		sinceResolutionStart := time.Since(resolutionStartTime).Milliseconds()
+		// Stop the timer for the lookup
+		sinceLookupStart := time.Since(lookupStartTime).Milliseconds()
 
 		// Emit the metrics, regardless of the result
-		mi.emitResolutionMetrics(
+		mi.emitLookupMetrics(
 			mi.vu.Context(),
-			sinceResolutionStart,
-			queryStr,
-			recordTypeStr,
-			nameserver,
-			resolveErr,
+			sinceLookupStart,
+			nameStr,
+			lookupErr,
 		)
 
-		// Handle the resolution failure only now that we have emitted the metrics
-		if resolveErr != nil {
-			reject(resolveErr)
+		// Handle the lookup failure only now that we have emitted the metrics
+		if lookupErr != nil {
+			reject(lookupErr)
 			return
 		}
 
-		resolve(fetchedIPs)
+		resolve(endpoints)
 	}()
 
 	return promise
 }
 
-// Lookup resolves a domain name to an IP address using the default system nameservers.
-func (mi *ModuleInstance) Lookup(hostname sobek.Value) *sobek.Promise {
+// LookupAddr performs a reverse lookup of an IP address using the default system
+// nameservers, and returns the hostnames it maps to.
+func (mi *ModuleInstance) LookupAddr(addr sobek.Value) *sobek.Promise {
 	promise, resolve, reject := promises.New(mi.vu)
 
 	if mi.vu.State() == nil {
-		reject(fmt.Errorf("lookup can not be used in the init context"))
+		reject(errors.New("lookupAddr can not be used in the init context"))
 		return promise
 	}
 
-	var hostnameStr string
-	if err := mi.vu.Runtime().ExportTo(hostname, &hostnameStr); err != nil {
-		reject(fmt.Errorf("hostname must be a string; got %T instead", hostname))
+	var addrStr string
+	if err := mi.vu.Runtime().ExportTo(addr, &addrStr); err != nil {
+		reject(fmt.Errorf("address must be a string; got %v instead", addr))
 		return promise
 	}
 
+	if net.ParseIP(addrStr) == nil {
+		reject(fmt.Errorf("address must be a valid IP address; got %s instead", addrStr))
+		return promise
+	}
+
+	// Lookups are subject to the same network restrictions as the rest of k6
+	lookuper := newRestrictedLookuper(mi.dnsClient, mi.vu.State().Options)
+
 	go func() {
 		// Start the timer for the lookup
 		lookupStartTime := time.Now()
 
 		// Perform the lookup
-		ips, lookupErr := mi.dnsClient.Lookup(mi.vu.Context(), hostnameStr)
+		hostnames, lookupErr := lookuper.LookupAddr(mi.vu.Context(), addrStr)
 
 		// Stop the timer for the lookup
 		sinceLookupStart := time.Since(lookupStartTime).Milliseconds()
@@ -159,7 +647,7 @@ func (mi *ModuleInstance) Lookup(hostname sobek.Value) *sobek.Promise {
 		mi.emitLookupMetrics(
 			mi.vu.Context(),
 			sinceLookupStart,
-			hostnameStr,
+			addrStr,
 			lookupErr,
 		)
 
@@ -169,16 +657,129 @@ func (mi *ModuleInstance) Lookup(hostname sobek.Value) *sobek.Promise {
 			return
 		}
 
-		resolve(ips)
+		resolve(hostnames)
 	}()
 
 	return promise
 }
 
+// throw throws the error in the JS runtime, as is, so that it can be inspected
+// the same way as the errors promises are rejected with.
+func (mi *ModuleInstance) throw(err error) {
+	panic(mi.vu.Runtime().ToValue(err))
+}
+
+// exportDomainName exports the JS value holding a domain name, converting it to its
+// ASCII form if it is an internationalized domain name. The argName is used to
+// produce meaningful error messages.
+func exportDomainName(rt *sobek.Runtime, value sobek.Value, argName string) (string, error) {
+	var name string
+	if err := rt.ExportTo(value, &name); err != nil {
+		return "", fmt.Errorf("%s must be a string; got %v instead", argName, value)
+	}
+
+	return ToASCII(name)
+}
+
+// exportNameserver exports the JS value holding a nameserver address, in the
+// `ip[:port]` format, and parses it into a Nameserver.
+func exportNameserver(rt *sobek.Runtime, value sobek.Value) (Nameserver, error) {
+	var addr string
+	if err := rt.ExportTo(value, &addr); err != nil {
+		return Nameserver{}, fmt.Errorf("nameserver must be a string; got %v instead", value)
+	}
+
+	nameserver, err := parseNameserverAddr(addr)
+	if err != nil {
+		return Nameserver{}, fmt.Errorf("parsing nameserver address failed: %w", err)
+	}
+
+	return nameserver, nil
+}
+
+// withAbortSignal returns a context derived from the VU's context, which is
+// cancelled as soon as the provided AbortSignal-like object is aborted. The
+// signal can be nil, in which case the context is only cancelled along with
+// the VU's context.
+//
+// It interacts with the runtime, and thus must be called from the event loop.
+func (mi *ModuleInstance) withAbortSignal(signal *sobek.Object) (context.Context, context.CancelFunc, error) {
+	mi.closeOnTeardown()
+
+	// Functions not taking a nameserver still query with the configured options
+	if _, err := mi.config(); err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := context.WithCancel(mi.vu.Context())
+	if signal == nil {
+		return ctx, cancel, nil
+	}
+
+	if aborted := signal.Get("aborted"); aborted != nil && aborted.ToBoolean() {
+		cancel()
+		return nil, nil, newExchangeError(context.Canceled, "the signal was aborted before the resolution started")
+	}
+
+	addEventListener, ok := sobek.AssertFunction(signal.Get("addEventListener"))
+	if !ok {
+		cancel()
+		return nil, nil, errors.New("signal option must be an AbortSignal, implementing addEventListener")
+	}
+
+	rt := mi.vu.Runtime()
+	onAbort := func() { cancel() }
+	if _, err := addEventListener(signal, rt.ToValue("abort"), rt.ToValue(onAbort)); err != nil {
+		cancel()
+		return nil, nil, fmt.Errorf("registering the signal's abort listener failed: %w", err)
+	}
+
+	return ctx, cancel, nil
+}
+
+// closeOnTeardown closes the persistent connections of the VU's client once the
+// VU's context is done, at the end of its scenario or of the test, rather than
+// leaving them open until they are idle for long enough. The connections shared
+// between the VUs are closed once all the VUs using them are done.
+func (mi *ModuleInstance) closeOnTeardown() {
+	mi.teardownMu.Lock()
+	defer mi.teardownMu.Unlock()
+
+	ctx := mi.vu.Context()
+	if mi.vu.State() == nil || ctx == mi.teardown {
+		return
+	}
+
+	mi.teardown = ctx
+	releaseShared := mi.sharedConns.acquire()
+
+	logger := mi.vu.State().Logger
+	context.AfterFunc(ctx, func() {
+		if err := errors.Join(mi.dnsClient.Close(), releaseShared()); err != nil {
+			logger.WithError(err).Warn("closing the connections to DNS nameservers failed")
+		}
+	})
+}
+
+// Summary returns the aggregated outcome of the DNS resolutions performed so far
+// by all the VUs: the number of queries, failures, and response codes, as well as
+// latency percentiles, overall and per nameserver.
+//
+// It is meant to be used from the script's handleSummary function.
+func (mi *ModuleInstance) Summary() map[string]interface{} {
+	return mi.summary.export()
+}
+
+// TextSummary returns a human-readable rendering of the Summary, suitable for
+// being appended to k6's own end-of-test summary.
+func (mi *ModuleInstance) TextSummary() string {
+	return mi.summary.text()
+}
+
 // registerMetrics registers the metrics for the module instance.
 func registerMetrics(registry *metrics.Registry) (*moduleInstanceMetrics, error) {
 	var err error
-	m := &moduleInstanceMetrics{}
+	m := &moduleInstanceMetrics{registry: registry, durationByType: map[string]*metrics.Metric{}}
 
 	m.DNSResolutions, err = registry.NewMetric("dns_resolutions", metrics.Counter)
 	if err != nil {
@@ -195,6 +796,126 @@ func registerMetrics(registry *metrics.Registry) (*moduleInstanceMetrics, error)
 		return nil, fmt.Errorf("failed registering dns_resolution_failed metric: %w", err)
 	}
 
+	m.DNSResponseBytes, err = registry.NewMetric("dns_response_bytes", metrics.Trend, metrics.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed registering dns_response_bytes metric: %w", err)
+	}
+
+	m.DNSAnswerCount, err = registry.NewMetric("dns_answer_count", metrics.Trend)
+	if err != nil {
+		return nil, fmt.Errorf("failed registering dns_answer_count metric: %w", err)
+	}
+
+	m.DNSRetransmissions, err = registry.NewMetric("dns_retransmissions", metrics.Counter)
+	if err != nil {
+		return nil, fmt.Errorf("failed registering dns_retransmissions metric: %w", err)
+	}
+
+	m.DNSTimeouts, err = registry.NewMetric("dns_timeouts", metrics.Counter)
+	if err != nil {
+		return nil, fmt.Errorf("failed registering dns_timeouts metric: %w", err)
+	}
+
+	m.DNSOpenConnections, err = registry.NewMetric("dns_open_connections", metrics.Gauge)
+	if err != nil {
+		return nil, fmt.Errorf("failed registering dns_open_connections metric: %w", err)
+	}
+
+	m.DNSConnectionReuse, err = registry.NewMetric("dns_connection_reuse", metrics.Rate)
+	if err != nil {
+		return nil, fmt.Errorf("failed registering dns_connection_reuse metric: %w", err)
+	}
+
+	m.DNSIDMismatch, err = registry.NewMetric("dns_id_mismatch", metrics.Counter)
+	if err != nil {
+		return nil, fmt.Errorf("failed registering dns_id_mismatch metric: %w", err)
+	}
+
+	m.DNSDuplicateResponses, err = registry.NewMetric("dns_duplicate_responses", metrics.Counter)
+	if err != nil {
+		return nil, fmt.Errorf("failed registering dns_duplicate_responses metric: %w", err)
+	}
+
+	m.DNSCaseMismatch, err = registry.NewMetric("dns_case_mismatch", metrics.Rate)
+	if err != nil {
+		return nil, fmt.Errorf("failed registering dns_case_mismatch metric: %w", err)
+	}
+
+	m.DNSUnrelatedAnswers, err = registry.NewMetric("dns_unrelated_answers", metrics.Rate)
+	if err != nil {
+		return nil, fmt.Errorf("failed registering dns_unrelated_answers metric: %w", err)
+	}
+
+	m.DNSSourcePorts, err = registry.NewMetric("dns_source_ports", metrics.Gauge)
+	if err != nil {
+		return nil, fmt.Errorf("failed registering dns_source_ports metric: %w", err)
+	}
+
+	m.DNSDoHCacheHits, err = registry.NewMetric("dns_doh_cache_hits", metrics.Rate)
+	if err != nil {
+		return nil, fmt.Errorf("failed registering dns_doh_cache_hits metric: %w", err)
+	}
+
+	m.DNSPacketLoss, err = registry.NewMetric("dns_packet_loss", metrics.Rate)
+	if err != nil {
+		return nil, fmt.Errorf("failed registering dns_packet_loss metric: %w", err)
+	}
+
+	m.DNSEDNSDowngrades, err = registry.NewMetric("dns_edns_downgrades", metrics.Counter)
+	if err != nil {
+		return nil, fmt.Errorf("failed registering dns_edns_downgrades metric: %w", err)
+	}
+
+	m.DNSSignatureExpiry, err = registry.NewMetric("dns_signature_expiry", metrics.Gauge, metrics.Time)
+	if err != nil {
+		return nil, fmt.Errorf("failed registering dns_signature_expiry metric: %w", err)
+	}
+
+	m.DNSPropagationDuration, err = registry.NewMetric("dns_propagation_duration", metrics.Trend, metrics.Time)
+	if err != nil {
+		return nil, fmt.Errorf("failed registering dns_propagation_duration metric: %w", err)
+	}
+
+	m.DNSConsistency, err = registry.NewMetric("dns_consistency", metrics.Rate)
+	if err != nil {
+		return nil, fmt.Errorf("failed registering dns_consistency metric: %w", err)
+	}
+
+	m.DNSAnswerMismatch, err = registry.NewMetric("dns_answer_mismatch", metrics.Rate)
+	if err != nil {
+		return nil, fmt.Errorf("failed registering dns_answer_mismatch metric: %w", err)
+	}
+
+	m.DNSGeoMismatch, err = registry.NewMetric("dns_geo_mismatch", metrics.Rate)
+	if err != nil {
+		return nil, fmt.Errorf("failed registering dns_geo_mismatch metric: %w", err)
+	}
+
+	m.DNSBenchmarkQueries, err = registry.NewMetric("dns_benchmark_queries", metrics.Counter)
+	if err != nil {
+		return nil, fmt.Errorf("failed registering dns_benchmark_queries metric: %w", err)
+	}
+
+	m.DNSDoHHTTPStatus, err = registry.NewMetric("dns_doh_http_status", metrics.Counter)
+	if err != nil {
+		return nil, fmt.Errorf("failed registering dns_doh_http_status metric: %w", err)
+	}
+
+	m.DNSTTLDrift, err = registry.NewMetric("dns_ttl_drift", metrics.Rate)
+	if err != nil {
+		return nil, fmt.Errorf("failed registering dns_ttl_drift metric: %w", err)
+	}
+
+	m.DNSCanaryMismatch, err = registry.NewMetric("dns_canary_mismatch", metrics.Rate)
+	if err != nil {
+		return nil, fmt.Errorf("failed registering dns_canary_mismatch metric: %w", err)
+	}
+
+	m.DNSAmplificationFactor, err = registry.NewMetric("dns_amplification_factor", metrics.Trend)
+	if err != nil {
+		return nil, fmt.Errorf("failed registering dns_amplification_factor metric: %w", err)
+	}
+
 	m.DNSLookups, err = registry.NewMetric("dns_lookups", metrics.Counter)
 	if err != nil {
 		return nil, fmt.Errorf("failed registering dns_lookups metric: %w", err)
@@ -220,6 +941,7 @@ func (mi *ModuleInstance) emitResolutionMetrics(
 	query,
 	recordType string,
 	nameserver Nameserver,
+	response *Response,
 	resolutionErr error,
 ) {
 	state := mi.vu.State()
@@ -229,6 +951,18 @@ func (mi *ModuleInstance) emitResolutionMetrics(
 	tags = tags.With("recordType", recordType)
 	tags = tags.With("nameserver", nameserver.Addr())
 
+	if response != nil && response.Rcode != "" {
+		tags = tags.With("rcode", response.Rcode)
+	}
+
+	if response != nil && response.Protocol != "" {
+		tags = tags.With("protocol", response.Protocol)
+	}
+
+	if response != nil && response.HTTPVersion != "" {
+		tags = tags.With("httpVersion", response.HTTPVersion)
+	}
+
 	now := time.Now()
 
 	// Increment the DNS lookups counter
@@ -253,6 +987,22 @@ func (mi *ModuleInstance) emitResolutionMetrics(
 		Metadata: nil,
 	})
 
+	// Emit the duration to the trend of the record type as well, if configured to,
+	// so that the end-of-test summary breaks it down by record type
+	if recordType != "" && mi.durationPerType() {
+		if metric, err := mi.metrics.resolutionDurationOf(recordType); err == nil {
+			metrics.PushIfNotDone(ctx, state.Samples, metrics.Sample{
+				TimeSeries: metrics.TimeSeries{
+					Metric: metric,
+					Tags:   tags,
+				},
+				Time:     now,
+				Value:    float64(duration),
+				Metadata: nil,
+			})
+		}
+	}
+
 	var failed float64
 	if resolutionErr != nil {
 		failed = 1
@@ -268,6 +1018,230 @@ func (mi *ModuleInstance) emitResolutionMetrics(
 		Value:    failed,
 		Metadata: nil,
 	})
+
+	// The remaining metrics are only known if the nameserver was queried
+	if response == nil {
+		return
+	}
+
+	// Emit the DNS retransmissions count
+	metrics.PushIfNotDone(ctx, state.Samples, metrics.Sample{
+		TimeSeries: metrics.TimeSeries{
+			Metric: mi.metrics.DNSRetransmissions,
+			Tags:   tags,
+		},
+		Time:     now,
+		Value:    float64(response.Attempts - 1),
+		Metadata: nil,
+	})
+
+	// Emit the DNS timeouts count
+	metrics.PushIfNotDone(ctx, state.Samples, metrics.Sample{
+		TimeSeries: metrics.TimeSeries{
+			Metric: mi.metrics.DNSTimeouts,
+			Tags:   tags,
+		},
+		Time:     now,
+		Value:    float64(response.Timeouts),
+		Metadata: nil,
+	})
+
+	// Emit the number of DNS connections open when the query was sent
+	metrics.PushIfNotDone(ctx, state.Samples, metrics.Sample{
+		TimeSeries: metrics.TimeSeries{
+			Metric: mi.metrics.DNSOpenConnections,
+			Tags:   tags,
+		},
+		Time:     now,
+		Value:    float64(response.OpenConnections),
+		Metadata: nil,
+	})
+
+	// Emit the DNS connection reuse rate, one sample per attempt
+	for attempt := 0; attempt < response.Attempts; attempt++ {
+		var reused float64
+		if attempt >= response.Attempts-response.ReusedConnections {
+			reused = 1
+		}
+
+		metrics.PushIfNotDone(ctx, state.Samples, metrics.Sample{
+			TimeSeries: metrics.TimeSeries{
+				Metric: mi.metrics.DNSConnectionReuse,
+				Tags:   tags,
+			},
+			Time:     now,
+			Value:    reused,
+			Metadata: nil,
+		})
+	}
+
+	// Emit the DNS packet loss rate, one sample per datagram sent, as the attempts
+	// which timed out are the ones which went unanswered
+	if response.Protocol == protocolUDP {
+		for attempt := 0; attempt < response.Attempts; attempt++ {
+			var lost float64
+			if attempt < response.Timeouts {
+				lost = 1
+			}
+
+			metrics.PushIfNotDone(ctx, state.Samples, metrics.Sample{
+				TimeSeries: metrics.TimeSeries{
+					Metric: mi.metrics.DNSPacketLoss,
+					Tags:   tags,
+				},
+				Time:     now,
+				Value:    lost,
+				Metadata: nil,
+			})
+		}
+	}
+
+	// Emit the number of DNS responses dropped because of a mismatched ID
+	metrics.PushIfNotDone(ctx, state.Samples, metrics.Sample{
+		TimeSeries: metrics.TimeSeries{
+			Metric: mi.metrics.DNSIDMismatch,
+			Tags:   tags,
+		},
+		Time:     now,
+		Value:    float64(response.IDMismatches),
+		Metadata: nil,
+	})
+
+	// Emit the number of duplicate DNS responses received
+	metrics.PushIfNotDone(ctx, state.Samples, metrics.Sample{
+		TimeSeries: metrics.TimeSeries{
+			Metric: mi.metrics.DNSDuplicateResponses,
+			Tags:   tags,
+		},
+		Time:     now,
+		Value:    float64(response.DuplicateResponses),
+		Metadata: nil,
+	})
+
+	// Emit the HTTP status of the last DoH response, distinguishing HTTP failures
+	// from DNS ones
+	if response.HTTPStatus != 0 {
+		metrics.PushIfNotDone(ctx, state.Samples, metrics.Sample{
+			TimeSeries: metrics.TimeSeries{
+				Metric: mi.metrics.DNSDoHHTTPStatus,
+				Tags:   tags.With("status", strconv.Itoa(response.HTTPStatus)),
+			},
+			Time:     now,
+			Value:    1,
+			Metadata: nil,
+		})
+	}
+
+	// Emit whether the DoH response was served out of an HTTP cache, as told by its
+	// Age header
+	if response.HTTPCache != nil {
+		var hit float64
+		if response.HTTPCache.Age != nil {
+			hit = 1
+		}
+
+		metrics.PushIfNotDone(ctx, state.Samples, metrics.Sample{
+			TimeSeries: metrics.TimeSeries{
+				Metric: mi.metrics.DNSDoHCacheHits,
+				Tags:   tags,
+			},
+			Time:     now,
+			Value:    hit,
+			Metadata: nil,
+		})
+	}
+
+	// Emit the number of distinct ports DNS queries were sent from over UDP so far
+	if addr, ok := response.localAddr.(*net.UDPAddr); ok && response.Protocol == protocolUDP {
+		metrics.PushIfNotDone(ctx, state.Samples, metrics.Sample{
+			TimeSeries: metrics.TimeSeries{
+				Metric: mi.metrics.DNSSourcePorts,
+				Tags:   tags,
+			},
+			Time:     now,
+			Value:    float64(mi.sourcePorts.add(uint16(addr.Port))), //nolint:gosec // ports fit in 16 bits
+			Metadata: nil,
+		})
+	}
+
+	// Emit the DNS queries sent again without EDNS, distinguishing why
+	if response.EDNSDowngrade != "" {
+		metrics.PushIfNotDone(ctx, state.Samples, metrics.Sample{
+			TimeSeries: metrics.TimeSeries{
+				Metric: mi.metrics.DNSEDNSDowngrades,
+				Tags:   tags.With("reason", response.EDNSDowngrade),
+			},
+			Time:     now,
+			Value:    1,
+			Metadata: nil,
+		})
+	}
+
+	// The response size and answer count are only known if we received a response
+	if response.msg == nil {
+		return
+	}
+
+	// Emit the DNS response size
+	metrics.PushIfNotDone(ctx, state.Samples, metrics.Sample{
+		TimeSeries: metrics.TimeSeries{
+			Metric: mi.metrics.DNSResponseBytes,
+			Tags:   tags,
+		},
+		Time:     now,
+		Value:    float64(response.Size),
+		Metadata: nil,
+	})
+
+	// Emit the DNS answer count
+	metrics.PushIfNotDone(ctx, state.Samples, metrics.Sample{
+		TimeSeries: metrics.TimeSeries{
+			Metric: mi.metrics.DNSAnswerCount,
+			Tags:   tags,
+		},
+		Time:     now,
+		Value:    float64(response.AnswerCount),
+		Metadata: nil,
+	})
+
+	if response.AnswerNamesVerified {
+		var unrelated float64
+		if response.UnrelatedAnswers > 0 {
+			unrelated = 1
+		}
+
+		// Emit the DNS unrelated answers rate
+		metrics.PushIfNotDone(ctx, state.Samples, metrics.Sample{
+			TimeSeries: metrics.TimeSeries{
+				Metric: mi.metrics.DNSUnrelatedAnswers,
+				Tags:   tags,
+			},
+			Time:     now,
+			Value:    unrelated,
+			Metadata: nil,
+		})
+	}
+
+	// The case mismatch is only known if the case of the query name was randomized
+	if !response.CaseRandomized {
+		return
+	}
+
+	var caseMismatch float64
+	if response.CaseMismatch {
+		caseMismatch = 1
+	}
+
+	// Emit the DNS case mismatch rate
+	metrics.PushIfNotDone(ctx, state.Samples, metrics.Sample{
+		TimeSeries: metrics.TimeSeries{
+			Metric: mi.metrics.DNSCaseMismatch,
+			Tags:   tags,
+		},
+		Time:     now,
+		Value:    caseMismatch,
+		Metadata: nil,
+	})
 }
 
 // emitLookupMetrics emits the metrics specific to DNS lookup operations.
@@ -334,6 +1308,98 @@ type moduleInstanceMetrics struct {
 	// DNSResolutionFailed is a Rate metric tracking the rate of failed DNS resolutions.
 	DNSResolutionFailed *metrics.Metric
 
+	// DNSResponseBytes is a trend metric tracking the size of DNS responses, in bytes.
+	DNSResponseBytes *metrics.Metric
+
+	// DNSAnswerCount is a trend metric tracking the number of records in DNS responses' answer section.
+	DNSAnswerCount *metrics.Metric
+
+	// DNSRetransmissions is a counter metric tracking the number of DNS queries retransmitted after timing out.
+	DNSRetransmissions *metrics.Metric
+
+	// DNSTimeouts is a counter metric tracking the number of DNS query attempts which timed out.
+	DNSTimeouts *metrics.Metric
+
+	// DNSOpenConnections is a gauge metric tracking the number of connections to nameservers open
+	// when DNS queries are sent.
+	DNSOpenConnections *metrics.Metric
+
+	// DNSConnectionReuse is a Rate metric tracking the rate of DNS query attempts sent over an
+	// already open connection.
+	DNSConnectionReuse *metrics.Metric
+
+	// DNSIDMismatch is a counter metric tracking the number of DNS responses dropped because
+	// their ID did not match the query's.
+	DNSIDMismatch *metrics.Metric
+
+	// DNSDuplicateResponses is a counter metric tracking the number of duplicate DNS responses
+	// received for retransmitted queries.
+	DNSDuplicateResponses *metrics.Metric
+
+	// DNSCaseMismatch is a Rate metric tracking the rate of DNS responses which did not echo
+	// the randomized case of the query name exactly.
+	DNSCaseMismatch *metrics.Metric
+
+	// DNSUnrelatedAnswers is a Rate metric tracking the rate of DNS responses holding answer
+	// records unrelated to the query name.
+	DNSUnrelatedAnswers *metrics.Metric
+
+	// DNSSourcePorts is a gauge metric tracking the number of distinct ports DNS queries
+	// were sent from over UDP, by all the VUs.
+	DNSSourcePorts *metrics.Metric
+
+	// DNSDoHCacheHits is a Rate metric tracking the rate of DoH responses served out of
+	// an HTTP cache, as told by their Age header.
+	DNSDoHCacheHits *metrics.Metric
+
+	// DNSPacketLoss is a Rate metric tracking the rate of DNS query attempts sent over UDP
+	// which went unanswered, by nameserver.
+	DNSPacketLoss *metrics.Metric
+
+	// DNSEDNSDowngrades is a counter metric tracking the number of DNS queries sent again
+	// without EDNS once their OPT record was rejected, by the response code rejecting it.
+	DNSEDNSDowngrades *metrics.Metric
+
+	// DNSSignatureExpiry is a gauge metric tracking the duration until the earliest signature
+	// of a zone expires.
+	DNSSignatureExpiry *metrics.Metric
+
+	// DNSPropagationDuration is a trend metric tracking the duration nameservers took to
+	// converge to an expected state.
+	DNSPropagationDuration *metrics.Metric
+
+	// DNSConsistency is a Rate metric tracking the rate of nameservers answering with the
+	// records most nameservers agreed on.
+	DNSConsistency *metrics.Metric
+
+	// DNSAnswerMismatch is a Rate metric tracking the rate of failed assertions about the
+	// answers of DNS resolutions.
+	DNSAnswerMismatch *metrics.Metric
+
+	// DNSGeoMismatch is a Rate metric tracking the rate of DNS resolutions whose IP addresses
+	// fell outside the expected prefixes.
+	DNSGeoMismatch *metrics.Metric
+
+	// DNSBenchmarkQueries is a counter metric tracking the number of queries sent by
+	// benchmarks, by outcome.
+	DNSBenchmarkQueries *metrics.Metric
+
+	// DNSDoHHTTPStatus is a counter metric tracking the number of HTTP responses of DoH
+	// nameservers, by status code.
+	DNSDoHHTTPStatus *metrics.Metric
+
+	// DNSTTLDrift is a Rate metric tracking the rate of repeated resolutions whose TTL
+	// did not count down as a cache's should have.
+	DNSTTLDrift *metrics.Metric
+
+	// DNSCanaryMismatch is a Rate metric tracking the rate of UDP resolutions whose answers
+	// differ from those of a trusted resolver queried over an authenticated channel.
+	DNSCanaryMismatch *metrics.Metric
+
+	// DNSAmplificationFactor is a trend metric tracking the ratio of the size of
+	// responses to the size of the queries they answer.
+	DNSAmplificationFactor *metrics.Metric
+
 	// DNSLookups is a counter metric tracking the total number of DNS lookups.
 	DNSLookups *metrics.Metric
 
@@ -342,4 +1408,39 @@ type moduleInstanceMetrics struct {
 
 	// DNSLookupFailed is a Rate metric tracking the rate of failed DNS lookups.
 	DNSLookupFailed *metrics.Metric
+
+	// registry holds the registry the metrics are registered in, in which the
+	// trend metrics tracking the duration of the resolutions of each record type
+	// are registered as they are first needed.
+	registry *metrics.Registry
+
+	// durationByType holds the trend metrics tracking the duration of the
+	// resolutions of each record type, by record type.
+	durationByType   map[string]*metrics.Metric
+	durationByTypeMu sync.Mutex
+}
+
+// resolutionDurationOf returns the trend metric tracking the duration of the
+// resolutions of the record type, e.g. dns_resolution_duration_txt for TXT,
+// registering it if needed.
+func (m *moduleInstanceMetrics) resolutionDurationOf(recordType string) (*metrics.Metric, error) {
+	m.durationByTypeMu.Lock()
+	defer m.durationByTypeMu.Unlock()
+
+	if metric, ok := m.durationByType[recordType]; ok {
+		return metric, nil
+	}
+
+	// Metric names only hold letters, digits and underscores, which rules out the
+	// dashes of some record types, such as NSAP-PTR
+	name := "dns_resolution_duration_" + strings.ToLower(strings.ReplaceAll(recordType, "-", "_"))
+
+	metric, err := m.registry.NewMetric(name, metrics.Trend, metrics.Time)
+	if err != nil {
+		return nil, fmt.Errorf("failed registering %s metric: %w", name, err)
+	}
+
+	m.durationByType[recordType] = metric
+
+	return metric, nil
 }
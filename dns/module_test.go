@@ -6,9 +6,11 @@ import (
 	"net"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
 
 	"github.com/docker/go-connections/nat"
+	"github.com/miekg/dns"
 
 	"github.com/testcontainers/testcontainers-go/wait"
 
@@ -16,6 +18,7 @@ import (
 	"go.k6.io/k6/metrics"
 
 	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/types"
 
 	"github.com/stretchr/testify/assert"
 
@@ -75,6 +78,19 @@ func TestClient_Resolve(t *testing.T) {
 		assert.Error(t, err)
 	})
 
+	t.Run("Resolving synchronously in the init context should fail", func(t *testing.T) {
+		t.Parallel()
+
+		runtime, err := newConfiguredRuntime(t)
+		require.NoError(t, err)
+
+		_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+			dns.resolveSync("k6.io", "A", "1.1.1.1:53");
+		`))
+
+		assert.Error(t, err)
+	})
+
 	t.Run("Resolving existing A records against cloudflare nameserver should succeed", func(t *testing.T) {
 		t.Parallel()
 
@@ -313,6 +329,19 @@ func TestClient_Lookup(t *testing.T) {
 		assert.Error(t, err)
 	})
 
+	t.Run("Synchronous lookup fails in the init context", func(t *testing.T) {
+		t.Parallel()
+
+		runtime, err := newConfiguredRuntime(t)
+		require.NoError(t, err)
+
+		_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+			dns.lookupSync("k6.io");
+		`))
+
+		assert.Error(t, err)
+	})
+
 	t.Run("Lookup returns the system's default resolver results", func(t *testing.T) {
 		t.Parallel()
 
@@ -346,6 +375,173 @@ const initGlobals = `
 	globalThis.dns = require("k6/x/dns");
 `
 
+func TestModuleInstance_Lookup_restrictions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		script  string
+		wantErr string
+	}{
+		{
+			name:    "Lookups of blocked hostnames fail",
+			script:  `await dns.lookup("api.blocked.test");`,
+			wantErr: "hostname api.blocked.test is blocked by the *.blocked.test pattern",
+		},
+		{
+			name:    "Synchronous lookups of blocked hostnames fail",
+			script:  `dns.lookupSync("api.blocked.test");`,
+			wantErr: "hostname api.blocked.test is blocked by the *.blocked.test pattern",
+		},
+		{
+			name:    "Service lookups of blocked names fail",
+			script:  `await dns.lookupService("_http._tcp.api.blocked.test");`,
+			wantErr: "hostname _http._tcp.api.blocked.test is blocked by the *.blocked.test pattern",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			blockedHostnames, err := types.NewNullHostnameTrie([]string{"*.blocked.test"})
+			require.NoError(t, err)
+
+			runtime, err := newConfiguredRuntime(t)
+			require.NoError(t, err)
+
+			runtime.MoveToVUContext(&lib.State{
+				Options:        lib.Options{BlockedHostnames: blockedHostnames},
+				BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+				Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+				Samples:        make(chan metrics.SampleContainer, 1024),
+			})
+
+			_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(tt.script))
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestModuleInstance_Resolve_responseBytes(t *testing.T) {
+	t.Parallel()
+
+	// The nameserver compresses its responses, whose records all share the same
+	// owner name, and tells the size of the ones it sent
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	var sent, uncompressed atomic.Int64
+	server := &dns.Server{PacketConn: conn, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		response := new(dns.Msg)
+		response.SetReply(req)
+
+		for i := 1; i <= 8; i++ {
+			rr, _ := dns.NewRR(fmt.Sprintf("%s 60 IN A 203.0.113.%d", req.Question[0].Name, i))
+			response.Answer = append(response.Answer, rr)
+		}
+
+		uncompressed.Store(int64(response.Len()))
+
+		response.Compress = true
+		packed, _ := response.Pack()
+		sent.Store(int64(len(packed)))
+
+		_, _ = w.Write(packed)
+	})}
+	go func() { _ = server.ActivateAndServe() }()
+	t.Cleanup(func() { _ = server.Shutdown() })
+
+	runtime, err := newConfiguredRuntime(t)
+	require.NoError(t, err)
+	require.NoError(t, runtime.VU.Runtime().Set("nameserver", conn.LocalAddr().String()))
+
+	samples := make(chan metrics.SampleContainer, 1024)
+	runtime.MoveToVUContext(&lib.State{
+		BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+		Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+		Samples:        samples,
+	})
+
+	_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+		await dns.resolve("a.rather.long.name.k6.test", "A", nameserver);
+	`))
+	require.NoError(t, err)
+
+	values := map[string][]float64{}
+	for len(samples) > 0 {
+		for _, sample := range (<-samples).GetSamples() {
+			values[sample.Metric.Name] = append(values[sample.Metric.Name], sample.Value)
+		}
+	}
+
+	// The size is the one of the datagram received, not of the unpacked message
+	require.Less(t, sent.Load(), uncompressed.Load())
+	assert.Equal(t, []float64{float64(sent.Load())}, values["dns_response_bytes"])
+	assert.Equal(t, []float64{8}, values["dns_answer_count"])
+}
+
+func TestModuleInstance_Resolve_connectionMetrics(t *testing.T) {
+	t.Parallel()
+
+	handler := dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		response := new(dns.Msg)
+		response.SetReply(req)
+
+		rr, _ := dns.NewRR(req.Question[0].Name + " 60 IN A 203.0.113.1")
+		response.Answer = append(response.Answer, rr)
+
+		_ = w.WriteMsg(response)
+	})
+
+	packetConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	udpServer := &dns.Server{PacketConn: packetConn, Handler: handler}
+	tcpServer := &dns.Server{Listener: listener, Handler: handler}
+	for _, server := range []*dns.Server{udpServer, tcpServer} {
+		server := server
+		go func() { _ = server.ActivateAndServe() }()
+		t.Cleanup(func() { _ = server.Shutdown() })
+	}
+
+	runtime, err := newConfiguredRuntime(t)
+	require.NoError(t, err)
+	require.NoError(t, runtime.VU.Runtime().Set("udpNameserver", packetConn.LocalAddr().String()))
+	require.NoError(t, runtime.VU.Runtime().Set("tcpNameserver", listener.Addr().String()))
+
+	samples := make(chan metrics.SampleContainer, 1024)
+	runtime.MoveToVUContext(&lib.State{
+		BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+		Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+		Samples:        samples,
+	})
+
+	// The TCP connection is kept open between the two TCP queries, while the UDP
+	// socket is closed once its query is answered
+	_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+		await dns.resolve("k6.test", "A", tcpNameserver, { protocol: "tcp" });
+		await dns.resolve("k6.test", "A", udpNameserver);
+		await dns.resolve("k6.test", "A", tcpNameserver, { protocol: "tcp" });
+	`))
+	require.NoError(t, err)
+
+	values := map[string][]float64{}
+	for len(samples) > 0 {
+		for _, sample := range (<-samples).GetSamples() {
+			values[sample.Metric.Name] = append(values[sample.Metric.Name], sample.Value)
+		}
+	}
+
+	assert.Equal(t, []float64{1, 2, 1}, values["dns_open_connections"])
+	assert.Equal(t, []float64{0, 0, 1}, values["dns_connection_reuse"])
+}
+
 func newConfiguredRuntime(t testing.TB) (*modulestest.Runtime, error) {
 	runtime := modulestest.NewRuntime(t)
 
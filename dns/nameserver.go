@@ -14,17 +14,39 @@ type Nameserver struct {
 
 	// Port is the port of the nameserver.
 	Port uint16
+
+	// Socket is the path of the Unix domain socket of the nameserver, such as a
+	// local stub daemon, in which case IP and Port are unused.
+	Socket string
 }
 
+// unixScheme is the prefix of the addresses of nameservers listening on a Unix
+// domain socket, e.g. unix:///run/dns.sock.
+const unixScheme = "unix://"
+
 // Addr returns the address of the nameserver as a string.
 func (n Nameserver) Addr() string {
+	if n.Socket != "" {
+		return unixScheme + n.Socket
+	}
+
 	return n.IP.String() + ":" + strconv.Itoa(int(n.Port))
 }
 
 // ParseNameserverAddr parses a nameserver address string into an IP and a port.
 //
 // It expects the `addr` to be in the format `ip` or `ip[:port]`. Where `ip` can be an IPv4 or an IPv6 address.
+// Addresses in the `unix:///path` format designate a Unix domain socket instead.
 func parseNameserverAddr(addr string) (Nameserver, error) {
+	if strings.HasPrefix(addr, unixScheme) {
+		socket := strings.TrimPrefix(addr, unixScheme)
+		if !strings.HasPrefix(socket, "/") {
+			return Nameserver{}, fmt.Errorf("nameserver socket path must be absolute: %s", addr)
+		}
+
+		return Nameserver{Socket: socket}, nil
+	}
+
 	hostStr, port, err := parseHostAndPort(addr)
 	if err != nil {
 		return Nameserver{}, err
@@ -36,7 +58,7 @@ func parseNameserverAddr(addr string) (Nameserver, error) {
 		return Nameserver{}, fmt.Errorf("invalid nameserver IP address: %s", hostStr)
 	}
 
-	return Nameserver{ip, port}, nil
+	return Nameserver{IP: ip, Port: port}, nil
 }
 
 func parseHostAndPort(addr string) (string, uint16, error) {
@@ -3,9 +3,14 @@ package dns
 import (
 	"fmt"
 	"net"
+	"path/filepath"
 	"testing"
 
+	"github.com/miekg/dns"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
 )
 
 func Test_parseNameserverAddr(t *testing.T) {
@@ -98,3 +103,75 @@ func Test_parseNameserverAddr(t *testing.T) {
 		})
 	}
 }
+
+func Test_parseNameserverAddr_unix(t *testing.T) {
+	t.Parallel()
+
+	nameserver, err := parseNameserverAddr("unix:///run/dns.sock")
+	require.NoError(t, err)
+	assert.Equal(t, Nameserver{Socket: "/run/dns.sock"}, nameserver)
+	assert.Equal(t, "unix:///run/dns.sock", nameserver.Addr())
+
+	_, err = parseNameserverAddr("unix://run/dns.sock")
+	assert.Error(t, err, "relative socket paths should be rejected")
+}
+
+func TestModuleInstance_Resolve_unixSocket(t *testing.T) {
+	t.Parallel()
+
+	socket := filepath.Join(t.TempDir(), "dns.sock")
+
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+
+	server := &dns.Server{Listener: listener, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		response := new(dns.Msg)
+		response.SetReply(req)
+		response.Answer = append(response.Answer, &dns.A{
+			Hdr: dns.RR_Header{Name: req.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+			A:   net.ParseIP("203.0.113.1"),
+		})
+		_ = w.WriteMsg(response)
+	})}
+	go func() { _ = server.ActivateAndServe() }()
+	t.Cleanup(func() { _ = server.Shutdown() })
+
+	runtime, err := newConfiguredRuntime(t)
+	require.NoError(t, err)
+	require.NoError(t, runtime.VU.Runtime().Set("address", "unix://"+socket))
+
+	runtime.MoveToVUContext(&lib.State{
+		BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+		Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+		Samples:        make(chan metrics.SampleContainer, 1024),
+	})
+
+	_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+		// Queries are sent over the socket as a stream, whichever the protocol
+		for (const protocol of [undefined, "udp", "tcp"]) {
+			const result = await dns.resolve("k6.test", "A", address, { protocol, throw: false });
+			if (result.error !== null || result.answers[0] !== "203.0.113.1") {
+				throw new Error("unexpected result: " + JSON.stringify(result));
+			}
+
+			if (result.server.protocol !== "unix" || result.server.address !== address) {
+				throw new Error("unexpected server: " + JSON.stringify(result.server));
+			}
+		}
+
+		const response = await dns.sendMessage(dns.newMessage().addQuestion("k6.test", "A"), address);
+		if (response.answers[0].data !== "203.0.113.1") {
+			throw new Error("unexpected response: " + JSON.stringify(response));
+		}
+
+		try {
+			await dns.resolve("k6.test", "A", address, { protocol: "dot" });
+			throw new Error("expected DoT to be rejected");
+		} catch (e) {
+			if (!String(e).includes("can not be used with Unix domain socket nameservers")) {
+				throw e;
+			}
+		}
+	`))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,135 @@
+package dns
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/grafana/sobek"
+	"github.com/miekg/dns"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/promises"
+)
+
+// notifyOptions holds the options that can be passed to the notify function.
+type notifyOptions struct {
+	resolveOptions
+
+	// Serial holds the serial number of the zone's SOA record to announce, if any.
+	Serial *uint32
+}
+
+// parseNotifyOptions parses the options object passed to the notify function.
+//
+// It accepts the same options as the resolve function, along with the serial option.
+func parseNotifyOptions(rt *sobek.Runtime, value sobek.Value) (notifyOptions, error) {
+	resolveOpts, err := parseResolveOptions(rt, value)
+	if err != nil {
+		return notifyOptions{}, err
+	}
+
+	opts := notifyOptions{resolveOptions: resolveOpts}
+
+	if common.IsNullish(value) {
+		return opts, nil
+	}
+
+	if v := value.ToObject(rt).Get("serial"); !common.IsNullish(v) {
+		var serial int64
+		if err := rt.ExportTo(v, &serial); err != nil || serial < 0 || serial > math.MaxUint32 {
+			return opts, fmt.Errorf("serial option must be an integer between 0 and %d; got %v instead", uint32(math.MaxUint32), v)
+		}
+
+		s := uint32(serial)
+		opts.Serial = &s
+	}
+
+	return opts, nil
+}
+
+// newNotifyMessage creates a NOTIFY message for the zone, as defined by RFC 1996,
+// announcing the serial number of its SOA record, if any.
+func newNotifyMessage(zone string, serial *uint32) *dns.Msg {
+	msg := new(dns.Msg)
+	msg.SetNotify(dns.Fqdn(zone))
+
+	// Secondaries may use the announced serial as a hint to avoid querying the
+	// primary when they already hold the zone's latest version.
+	if serial != nil {
+		msg.Answer = append(msg.Answer, &dns.SOA{
+			Hdr:    dns.RR_Header{Name: dns.Fqdn(zone), Rrtype: dns.TypeSOA, Class: dns.ClassINET},
+			Ns:     ".",
+			Mbox:   ".",
+			Serial: *serial,
+		})
+	}
+
+	return msg
+}
+
+// Notify sends a NOTIFY message for the zone to the nameserver, usually a secondary
+// of the zone, announcing that the zone changed, and resolves to the message received
+// in response.
+//
+// Unless the throw option is disabled, the promise is rejected when the response
+// holds a response code other than NOERROR, e.g. when the nameserver is not a
+// secondary of the zone.
+func (mi *ModuleInstance) Notify(zone, nameserverAddr, options sobek.Value) *sobek.Promise {
+	promise, resolve, reject := promises.New(mi.vu)
+
+	if mi.vu.State() == nil {
+		reject(errors.New("notify can not be used in the init context"))
+		return promise
+	}
+
+	zoneStr, err := exportDomainName(mi.vu.Runtime(), zone, "zone")
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	nameserver, err := mi.exportNameserver(nameserverAddr)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	opts, err := parseNotifyOptions(mi.vu.Runtime(), options)
+	if err != nil {
+		reject(fmt.Errorf("invalid notify options: %w", err))
+		return promise
+	}
+
+	ctx, cancel, err := mi.withAbortSignal(opts.Signal)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer cancel()
+
+		msg := newNotifyMessage(zoneStr, opts.Serial)
+
+		response, duration, err := mi.sendMessage(ctx, msg, nameserver, opts.QueryOptions)
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		if opts.Throw && response.msg.Rcode != dns.RcodeSuccess {
+			reject(withNameserver(newDNSError(response.msg.Rcode, "DNS notify failed"), nameserver))
+			return
+		}
+
+		result := newMessageResult(response.msg, duration)
+		result.Size = response.Size
+		if opts.Raw {
+			result.Raw = newRawMessages(response)
+		}
+
+		resolve(result)
+	}()
+
+	return promise
+}
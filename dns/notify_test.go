@@ -0,0 +1,94 @@
+package dns
+
+import (
+	"testing"
+
+	"github.com/grafana/sobek"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_newNotifyMessage(t *testing.T) {
+	t.Parallel()
+
+	t.Run("without serial", func(t *testing.T) {
+		t.Parallel()
+
+		msg := newNotifyMessage("k6.io", nil)
+
+		assert.Equal(t, dns.OpcodeNotify, msg.Opcode)
+		assert.True(t, msg.Authoritative)
+		assert.Equal(t, []dns.Question{{Name: "k6.io.", Qtype: dns.TypeSOA, Qclass: dns.ClassINET}}, msg.Question)
+		assert.Empty(t, msg.Answer)
+	})
+
+	t.Run("with serial", func(t *testing.T) {
+		t.Parallel()
+
+		serial := uint32(2024060101)
+		msg := newNotifyMessage("k6.io", &serial)
+
+		require.Len(t, msg.Answer, 1)
+		soa, ok := msg.Answer[0].(*dns.SOA)
+		require.True(t, ok)
+		assert.Equal(t, "k6.io.", soa.Hdr.Name)
+		assert.Equal(t, serial, soa.Serial)
+
+		_, err := msg.Pack()
+		assert.NoError(t, err)
+	})
+}
+
+func Test_parseNotifyOptions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		options    string
+		wantSerial *uint32
+		wantErr    bool
+	}{
+		{
+			name:    "undefined options",
+			options: `undefined`,
+		},
+		{
+			name:       "serial",
+			options:    `({serial: 4294967295})`,
+			wantSerial: func() *uint32 { s := uint32(4294967295); return &s }(),
+		},
+		{
+			name:    "out of range serial",
+			options: `({serial: 4294967296})`,
+			wantErr: true,
+		},
+		{
+			name:    "negative serial",
+			options: `({serial: -1})`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rt := sobek.New()
+			value, err := rt.RunString(tt.options)
+			require.NoError(t, err)
+
+			got, err := parseNotifyOptions(rt, value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.True(t, got.Throw)
+			assert.Equal(t, tt.wantSerial, got.Serial)
+		})
+	}
+}
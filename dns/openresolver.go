@@ -0,0 +1,212 @@
+package dns
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/grafana/sobek"
+	"github.com/miekg/dns"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/promises"
+)
+
+// defaultOpenResolverName is the external name the probeOpenResolver function
+// queries, unless specified otherwise.
+const defaultOpenResolverName = "example.com"
+
+// openResolverOptions holds the options that can be passed to the
+// probeOpenResolver function.
+type openResolverOptions struct {
+	resolveOptions
+
+	// Name holds the external name queried, which the probed server should not be
+	// authoritative for.
+	Name string
+}
+
+// parseOpenResolverOptions parses the options object passed to the
+// probeOpenResolver function.
+//
+// It accepts the same options as the resolve function, along with the name
+// option. Unlike the resolve function, a random label is prepended to the name
+// unless the randomLabel option is false, so that the response can not come
+// from a cache.
+func parseOpenResolverOptions(rt *sobek.Runtime, value sobek.Value) (openResolverOptions, error) {
+	resolveOpts, err := parseResolveOptions(rt, value)
+	if err != nil {
+		return openResolverOptions{}, err
+	}
+
+	opts := openResolverOptions{resolveOptions: resolveOpts, Name: defaultOpenResolverName}
+
+	// Probing for recursion only makes sense with the recursion desired flag set
+	opts.NoRecursion = false
+
+	if common.IsNullish(value) {
+		opts.RandomLabel = true
+		return opts, nil
+	}
+
+	params := value.ToObject(rt)
+
+	if v := params.Get("randomLabel"); common.IsNullish(v) {
+		opts.RandomLabel = true
+	}
+
+	if v := params.Get("name"); !common.IsNullish(v) {
+		name, err := exportDomainName(rt, v, "name option")
+		if err != nil {
+			return opts, err
+		}
+
+		opts.Name = name
+	}
+
+	return opts, nil
+}
+
+// openResolverResult is the object the probeOpenResolver function resolves to.
+type openResolverResult struct {
+	// Nameserver holds the address of the probed server.
+	Nameserver string `js:"nameserver"`
+
+	// Name holds the name which was queried, including its random label.
+	Name string `js:"name"`
+
+	// Open indicates whether the server provided recursion, by resolving the
+	// external name.
+	Open bool `js:"open"`
+
+	// RecursionAvailable indicates whether the server set the recursion available
+	// flag of its response.
+	RecursionAvailable bool `js:"recursionAvailable"`
+
+	// Rcode holds the response code of the response, e.g. "REFUSED", or is empty
+	// if no response was received.
+	Rcode string `js:"rcode"`
+
+	// Answers holds the number of records of the answer section of the response.
+	Answers int `js:"answers"`
+
+	// Reason describes why the server is not considered open, e.g. "answered with
+	// REFUSED", or is empty if it is.
+	Reason string `js:"reason"`
+
+	// Error holds the error querying the server failed with, if any.
+	Error *Error `js:"error"`
+
+	// RTT holds the round-trip time of the query, in milliseconds.
+	RTT float64 `js:"rtt"`
+}
+
+// newOpenResolverResult creates an openResolverResult out of the response the
+// server sent to the recursive query for the name, if any.
+//
+// The server is considered open when it resolved the name: either by answering
+// with records, or with a negative answer holding the SOA record of the zone of
+// the name. Authoritative answers and referrals do not involve recursion.
+func newOpenResolverResult(nameserver, name string, msg *dns.Msg, rtt time.Duration, err error) *openResolverResult {
+	result := &openResolverResult{
+		Nameserver: nameserver,
+		Name:       name,
+		Error:      asError(err),
+		RTT:        float64(rtt) / float64(time.Millisecond),
+	}
+
+	if msg == nil {
+		result.Reason = "no response"
+		return result
+	}
+
+	result.RecursionAvailable = msg.RecursionAvailable
+	result.Rcode = dns.RcodeToString[msg.Rcode]
+	result.Answers = len(msg.Answer)
+
+	negative := false
+	for _, rr := range msg.Ns {
+		if _, ok := rr.(*dns.SOA); ok {
+			negative = true
+			break
+		}
+	}
+
+	switch {
+	case msg.Rcode != dns.RcodeSuccess && msg.Rcode != dns.RcodeNameError:
+		result.Reason = "answered with " + result.Rcode
+	case msg.Authoritative:
+		result.Reason = "answered authoritatively"
+	case len(msg.Answer) == 0 && !negative:
+		result.Reason = "answered with a referral"
+	default:
+		result.Open = true
+	}
+
+	return result
+}
+
+// ProbeOpenResolver sends a recursive query for an external name to the server,
+// and resolves to whether the server provided recursion, for auditing servers
+// which must not be open resolvers.
+func (mi *ModuleInstance) ProbeOpenResolver(nameserverAddr, options sobek.Value) *sobek.Promise {
+	promise, resolve, reject := promises.New(mi.vu)
+
+	if mi.vu.State() == nil {
+		reject(errors.New("probeOpenResolver can not be used in the init context"))
+		return promise
+	}
+
+	nameserver, err := mi.exportNameserver(nameserverAddr)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	opts, err := parseOpenResolverOptions(mi.vu.Runtime(), options)
+	if err != nil {
+		reject(fmt.Errorf("invalid probeOpenResolver options: %w", err))
+		return promise
+	}
+
+	ctx, cancel, err := mi.withAbortSignal(opts.Signal)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer cancel()
+
+		name := opts.Name
+		if opts.RandomLabel {
+			label, err := randomLabel("", defaultRandomLabelEntropy)
+			if err != nil {
+				reject(err)
+				return
+			}
+
+			name = label + "." + name
+		}
+
+		msg := new(dns.Msg)
+		msg.SetQuestion(dns.Fqdn(name), dns.TypeA)
+		msg.RecursionDesired = true
+
+		// An unresponsive server is not an open resolver, which is reported rather
+		// than rejected, so that fleets of servers can be audited in a loop
+		response, rtt, err := mi.sendMessage(ctx, msg, nameserver, opts.QueryOptions)
+		if ctx.Err() != nil {
+			reject(err)
+			return
+		}
+
+		var reply *dns.Msg
+		if response != nil {
+			reply = response.msg
+		}
+
+		resolve(newOpenResolverResult(nameserver.Addr(), name, reply, rtt, err))
+	}()
+
+	return promise
+}
@@ -0,0 +1,220 @@
+package dns
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+)
+
+func Test_newOpenResolverResult(t *testing.T) {
+	t.Parallel()
+
+	// response returns a response with the rcode, flags and records, records of
+	// the SOA type going to the authority section
+	response := func(rcode int, authoritative, recursionAvailable bool, records ...string) *dns.Msg {
+		t.Helper()
+
+		msg := &dns.Msg{MsgHdr: dns.MsgHdr{
+			Rcode:              rcode,
+			Authoritative:      authoritative,
+			RecursionAvailable: recursionAvailable,
+		}}
+		for _, record := range records {
+			rr, err := dns.NewRR(record)
+			require.NoError(t, err)
+
+			switch rr.(type) {
+			case *dns.SOA, *dns.NS:
+				msg.Ns = append(msg.Ns, rr)
+			default:
+				msg.Answer = append(msg.Answer, rr)
+			}
+		}
+
+		return msg
+	}
+
+	tests := []struct {
+		name       string
+		msg        *dns.Msg
+		err        error
+		wantOpen   bool
+		wantRA     bool
+		wantRcode  string
+		wantReason string
+	}{
+		{
+			name:      "resolved records",
+			msg:       response(dns.RcodeSuccess, false, true, "example.com. 60 IN A 93.184.215.14"),
+			wantOpen:  true,
+			wantRA:    true,
+			wantRcode: "NOERROR",
+		},
+		{
+			name: "negative answer",
+			msg: response(dns.RcodeNameError, false, true,
+				"example.com. 60 IN SOA ns.icann.org. noc.dns.icann.org. 42 7200 3600 1209600 3600"),
+			wantOpen:  true,
+			wantRA:    true,
+			wantRcode: "NXDOMAIN",
+		},
+		{
+			name:       "refused query",
+			msg:        response(dns.RcodeRefused, false, false),
+			wantRcode:  "REFUSED",
+			wantReason: "answered with REFUSED",
+		},
+		{
+			name: "authoritative answer",
+			msg: response(dns.RcodeNameError, true, false,
+				"example.com. 60 IN SOA ns.icann.org. noc.dns.icann.org. 42 7200 3600 1209600 3600"),
+			wantRcode:  "NXDOMAIN",
+			wantReason: "answered authoritatively",
+		},
+		{
+			name:       "referral to the root",
+			msg:        response(dns.RcodeSuccess, false, true, ". 60 IN NS a.root-servers.net."),
+			wantRA:     true,
+			wantRcode:  "NOERROR",
+			wantReason: "answered with a referral",
+		},
+		{
+			name:       "no response",
+			err:        errors.New("i/o timeout"),
+			wantReason: "no response",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			result := newOpenResolverResult("192.0.2.1:53", "k6.example.com", tt.msg, 5*time.Millisecond, tt.err)
+
+			assert.Equal(t, tt.wantOpen, result.Open)
+			assert.Equal(t, tt.wantRA, result.RecursionAvailable)
+			assert.Equal(t, tt.wantRcode, result.Rcode)
+			assert.Equal(t, tt.wantReason, result.Reason)
+			assert.Equal(t, tt.err != nil, result.Error != nil)
+			assert.InDelta(t, 5, result.RTT, 0.001)
+		})
+	}
+}
+
+func TestModuleInstance_ProbeOpenResolver(t *testing.T) {
+	t.Parallel()
+
+	serve := func(handler dns.HandlerFunc) string {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		server := &dns.Server{PacketConn: conn, Handler: handler}
+		go func() { _ = server.ActivateAndServe() }()
+		t.Cleanup(func() { _ = server.Shutdown() })
+
+		return conn.LocalAddr().String()
+	}
+
+	// The open resolver answers any recursive query, as if it had resolved it
+	open := serve(func(w dns.ResponseWriter, req *dns.Msg) {
+		response := new(dns.Msg)
+		response.SetReply(req)
+		response.RecursionAvailable = true
+
+		if req.RecursionDesired {
+			rr, _ := dns.NewRR(req.Question[0].Name + " 60 IN A 192.0.2.1")
+			response.Answer = append(response.Answer, rr)
+		}
+
+		_ = w.WriteMsg(response)
+	})
+
+	closed := serve(func(w dns.ResponseWriter, req *dns.Msg) {
+		response := new(dns.Msg)
+		response.SetRcode(req, dns.RcodeRefused)
+
+		_ = w.WriteMsg(response)
+	})
+
+	tests := []struct {
+		name    string
+		script  string
+		wantErr string
+	}{
+		{
+			name: "Servers providing recursion should be reported as open",
+			script: `
+				const result = await dns.probeOpenResolver(open);
+				if (!result.open || !result.recursionAvailable || result.rcode !== "NOERROR" || result.answers !== 1) {
+					throw new Error("unexpected result: " + JSON.stringify(result));
+				}
+
+				if (!/^[a-z0-9]{12}\.example\.com$/.test(result.name)) {
+					throw new Error("expected a random name under example.com; got " + result.name);
+				}
+			`,
+		},
+		{
+			name: "Servers refusing recursion should not be reported as open",
+			script: `
+				const result = await dns.probeOpenResolver(closed, { name: "k6.io", randomLabel: false });
+				if (result.open || result.reason !== "answered with REFUSED" || result.name !== "k6.io") {
+					throw new Error("unexpected result: " + JSON.stringify(result));
+				}
+			`,
+		},
+		{
+			name: "Authoritative servers should not be reported as open",
+			script: `
+				const server = dns.startServer(["k6.test. 60 IN A 203.0.113.1"]);
+				const result = await dns.probeOpenResolver(server.address, { name: "k6.test", randomLabel: false });
+				server.close();
+
+				if (result.open || result.reason !== "answered authoritatively" || result.answers !== 1) {
+					throw new Error("unexpected result: " + JSON.stringify(result));
+				}
+			`,
+		},
+		{
+			name:    "Invalid nameservers should fail",
+			script:  `await dns.probeOpenResolver("not-an-address");`,
+			wantErr: "parsing nameserver address failed",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			runtime, err := newConfiguredRuntime(t)
+			require.NoError(t, err)
+			require.NoError(t, runtime.VU.Runtime().Set("open", open))
+			require.NoError(t, runtime.VU.Runtime().Set("closed", closed))
+
+			runtime.MoveToVUContext(&lib.State{
+				BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+				Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+				Samples:        make(chan metrics.SampleContainer, 1024),
+			})
+
+			_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(tt.script))
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,367 @@
+package dns
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/grafana/sobek"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/lib/types"
+)
+
+// resolveOptions holds the options that can be passed to the resolve function.
+type resolveOptions struct {
+	QueryOptions
+
+	// Throw indicates whether the resolve function should reject its promise on
+	// failure. When false, it instead resolves to a result object holding the error.
+	Throw bool
+
+	// NXDomainAsEmpty indicates whether a NXDOMAIN response should be treated as
+	// a successful resolution with no answers, rather than as an error.
+	NXDomainAsEmpty bool
+
+	// RequireAuthoritative indicates whether responses lacking the authoritative
+	// answer flag should be treated as errors.
+	RequireAuthoritative bool
+
+	// Signal holds an AbortSignal-like object, which allows aborting the resolution.
+	Signal *sobek.Object
+
+	// Raw indicates whether the wire format of the exchanged messages should be
+	// included in the results.
+	Raw bool
+
+	// Sections indicates whether the authority and additional sections of the
+	// responses should be included in the results.
+	Sections bool
+
+	// MaxAnswers holds the maximum number of answers, and of records, the results
+	// hold, or zero if there is no such limit.
+	MaxAnswers int
+}
+
+// parseResolveOptions parses the options object passed to the resolve function.
+//
+// Undefined or null options result in the default options being returned.
+func parseResolveOptions(rt *sobek.Runtime, value sobek.Value) (resolveOptions, error) {
+	opts := resolveOptions{Throw: true}
+
+	if common.IsNullish(value) {
+		return opts, nil
+	}
+
+	params := value.ToObject(rt)
+
+	if v := params.Get("timeout"); !common.IsNullish(v) {
+		timeout, err := types.GetDurationValue(v.Export())
+		if err != nil {
+			return opts, fmt.Errorf("timeout option is invalid; reason: %w", err)
+		}
+
+		if timeout < 0 {
+			return opts, fmt.Errorf("timeout option must be a positive duration; got %v instead", v)
+		}
+
+		opts.Timeout = timeout
+		opts.explicit |= explicitTimeout
+	}
+
+	if v := params.Get("retries"); !common.IsNullish(v) {
+		var retries int64
+		if err := rt.ExportTo(v, &retries); err != nil || retries < 0 {
+			return opts, fmt.Errorf("retries option must be a positive integer; got %v instead", v)
+		}
+
+		opts.Retries = int(retries)
+		opts.explicit |= explicitRetries
+	}
+
+	if v := params.Get("followCname"); !common.IsNullish(v) {
+		opts.FollowCNAME = v.ToBoolean()
+	}
+
+	if v := params.Get("maxDepth"); !common.IsNullish(v) {
+		var maxDepth int64
+		if err := rt.ExportTo(v, &maxDepth); err != nil || maxDepth < 1 {
+			return opts, fmt.Errorf("maxDepth option must be a strictly positive integer; got %v instead", v)
+		}
+
+		opts.MaxCNAMEDepth = int(maxDepth)
+	}
+
+	if v := params.Get("throw"); !common.IsNullish(v) {
+		opts.Throw = v.ToBoolean()
+	}
+
+	if v := params.Get("nxdomainAsEmpty"); !common.IsNullish(v) {
+		opts.NXDomainAsEmpty = v.ToBoolean()
+	}
+
+	if v := params.Get("requireAuthoritative"); !common.IsNullish(v) {
+		opts.RequireAuthoritative = v.ToBoolean()
+	}
+
+	if v := params.Get("recursionDesired"); !common.IsNullish(v) {
+		opts.NoRecursion = !v.ToBoolean()
+	}
+
+	if v := params.Get("randomizeCase"); !common.IsNullish(v) {
+		opts.RandomizeCase = v.ToBoolean()
+	}
+
+	if v := params.Get("verifyAnswerNames"); !common.IsNullish(v) {
+		opts.VerifyAnswerNames = v.ToBoolean()
+	}
+
+	if v := params.Get("randomLabel"); !common.IsNullish(v) {
+		opts.RandomLabel = v.ToBoolean()
+	}
+
+	if v := params.Get("raw"); !common.IsNullish(v) {
+		opts.Raw = v.ToBoolean()
+	}
+
+	if v := params.Get("sections"); !common.IsNullish(v) {
+		opts.Sections = v.ToBoolean()
+	}
+
+	if v := params.Get("maxAnswers"); !common.IsNullish(v) {
+		var maxAnswers int64
+		if err := rt.ExportTo(v, &maxAnswers); err != nil || maxAnswers < 1 {
+			return opts, fmt.Errorf("maxAnswers option must be a strictly positive integer; got %v instead", v)
+		}
+
+		opts.MaxAnswers = int(maxAnswers)
+	}
+
+	if v := params.Get("debug"); !common.IsNullish(v) {
+		sample, err := parseDebugSample(v.Export())
+		if err != nil {
+			return opts, err
+		}
+
+		opts.Debug = &sample
+	}
+
+	if v := params.Get("tsig"); !common.IsNullish(v) {
+		key, err := parseTSIGKey(v)
+		if err != nil {
+			return opts, fmt.Errorf("tsig option is invalid; reason: %w", err)
+		}
+
+		opts.TSIG = key
+	}
+
+	if v := params.Get("ednsSize"); !common.IsNullish(v) {
+		size, negotiate, err := parseEDNSSize(v.Export())
+		if err != nil {
+			return opts, err
+		}
+
+		opts.EDNSSize, opts.NegotiateEDNSSize = size, negotiate
+	}
+
+	if v := params.Get("ednsFallback"); !common.IsNullish(v) {
+		opts.EDNSFallback = v.ToBoolean()
+	}
+
+	if v := params.Get("clientSubnet"); !common.IsNullish(v) {
+		subnet, err := parseClientSubnet(v.String())
+		if err != nil {
+			return opts, fmt.Errorf("clientSubnet option is invalid; reason: %w", err)
+		}
+
+		opts.ClientSubnet = subnet
+	}
+
+	if err := parseTransportOptions(rt, params, &opts.QueryOptions); err != nil {
+		return opts, err
+	}
+
+	if v := params.Get("signal"); !common.IsNullish(v) {
+		signal, ok := v.(*sobek.Object)
+		if !ok {
+			return opts, fmt.Errorf("signal option must be an AbortSignal; got %v instead", v)
+		}
+
+		opts.Signal = signal
+	}
+
+	return opts, nil
+}
+
+// parseTransportOptions parses the options describing the protocol queries are
+// sent over, and the persistent connections of TCP and DoT, into the QueryOptions.
+func parseTransportOptions(rt *sobek.Runtime, params *sobek.Object, opts *QueryOptions) error {
+	if v := params.Get("protocol"); !common.IsNullish(v) {
+		switch protocol := strings.ToLower(v.String()); protocol {
+		case protocolUDP, protocolTCP, protocolDoT, protocolDoH:
+			opts.Protocol = protocol
+		default:
+			return fmt.Errorf("protocol option must be one of 'udp', 'tcp', 'dot' or 'doh'; got %v instead", v)
+		}
+	}
+
+	if v := params.Get("sourcePort"); !common.IsNullish(v) {
+		port, random, err := parseSourcePort(v.Export())
+		if err != nil {
+			return err
+		}
+
+		opts.SourcePort, opts.RandomizeSourcePort = port, random
+	}
+
+	if v := params.Get("networkFamily"); !common.IsNullish(v) {
+		family, ok := addressFamilyString(v.String())
+		if !ok {
+			return fmt.Errorf("networkFamily option must be one of 'any', 'ipv4' or 'ipv6'; got %v instead", v)
+		}
+
+		opts.NetworkFamily = family
+		opts.explicit |= explicitNetworkFamily
+	}
+
+	if v := params.Get("tlsServerName"); !common.IsNullish(v) {
+		opts.TLSServerName = v.String()
+		opts.explicit |= explicitTLSServerName
+	}
+
+	if v := params.Get("dohPath"); !common.IsNullish(v) {
+		if path := v.String(); strings.HasPrefix(path, "/") {
+			opts.DoHPath = path
+		} else {
+			return fmt.Errorf("dohPath option must be an absolute path; got %v instead", v)
+		}
+	}
+
+	if v := params.Get("dohMethod"); !common.IsNullish(v) {
+		switch method := strings.ToUpper(v.String()); method {
+		case http.MethodGet, http.MethodPost:
+			opts.DoHMethod = method
+		default:
+			return fmt.Errorf("dohMethod option must be one of 'GET' or 'POST'; got %v instead", v)
+		}
+	}
+
+	if v := params.Get("httpVersion"); !common.IsNullish(v) {
+		switch version := v.String(); version {
+		case httpVersion1, httpVersion2:
+			opts.HTTPVersion = version
+		default:
+			return fmt.Errorf("httpVersion option must be one of '1.1' or '2'; got %v instead", v)
+		}
+	}
+
+	if v := params.Get("headers"); !common.IsNullish(v) {
+		var headers map[string]string
+		if err := rt.ExportTo(v, &headers); err != nil {
+			return fmt.Errorf("headers option must be an object of strings; got %v instead", v)
+		}
+
+		opts.DoHHeaders = make(http.Header, len(headers))
+		for name, value := range headers {
+			opts.DoHHeaders.Set(name, value)
+		}
+	}
+
+	if v := params.Get("poolSize"); !common.IsNullish(v) {
+		var poolSize int64
+		if err := rt.ExportTo(v, &poolSize); err != nil || poolSize < 1 {
+			return fmt.Errorf("poolSize option must be a strictly positive integer; got %v instead", v)
+		}
+
+		opts.PoolSize = int(poolSize)
+	}
+
+	if v := params.Get("idleTimeout"); !common.IsNullish(v) {
+		idleTimeout, err := types.GetDurationValue(v.Export())
+		if err != nil {
+			return fmt.Errorf("idleTimeout option is invalid; reason: %w", err)
+		}
+
+		if idleTimeout <= 0 {
+			return fmt.Errorf("idleTimeout option must be a strictly positive duration; got %v instead", v)
+		}
+
+		opts.IdleTimeout = idleTimeout
+	}
+
+	return nil
+}
+
+// parseTSIGKey parses a TSIG key object, holding the name, secret, and optionally
+// the algorithm of the key, which defaults to "hmac-sha256".
+func parseTSIGKey(value sobek.Value) (*TSIGKey, error) {
+	params, ok := value.(*sobek.Object)
+	if !ok {
+		return nil, fmt.Errorf("TSIG key must be an object; got %v instead", value)
+	}
+
+	algorithm := "hmac-sha256"
+	if v := params.Get("algorithm"); !common.IsNullish(v) {
+		algorithm = v.String()
+	}
+
+	var name, secret string
+	if v := params.Get("name"); !common.IsNullish(v) {
+		name = v.String()
+	}
+
+	if v := params.Get("secret"); !common.IsNullish(v) {
+		secret = v.String()
+	}
+
+	return NewTSIGKey(name, algorithm, secret)
+}
+
+// lookupOptions holds the options that can be passed to the lookup function.
+type lookupOptions struct {
+	LookupOptions
+
+	// All indicates whether the lookup function should resolve to all the
+	// addresses found, or only to the first one.
+	All bool
+}
+
+// parseLookupOptions parses the options object passed to the lookup function.
+//
+// Undefined or null options result in the default options being returned.
+func parseLookupOptions(rt *sobek.Runtime, value sobek.Value) (lookupOptions, error) {
+	opts := lookupOptions{All: true}
+
+	if common.IsNullish(value) {
+		return opts, nil
+	}
+
+	params := value.ToObject(rt)
+
+	if v := params.Get("family"); !common.IsNullish(v) {
+		family, ok := addressFamilyString(v.String())
+		if !ok {
+			return opts, fmt.Errorf("family option must be one of 'any', 'ipv4' or 'ipv6'; got %v instead", v)
+		}
+
+		opts.Family = family
+	}
+
+	if v := params.Get("order"); !common.IsNullish(v) {
+		switch v.String() {
+		case "rfc6724":
+			opts.Order = AddressOrderSystem
+		case "ipv4first":
+			opts.Order = AddressOrderIPv4First
+		case "ipv6first":
+			opts.Order = AddressOrderIPv6First
+		default:
+			return opts, fmt.Errorf("order option must be one of 'rfc6724', 'ipv4first' or 'ipv6first'; got %v instead", v)
+		}
+	}
+
+	if v := params.Get("all"); !common.IsNullish(v) {
+		opts.All = v.ToBoolean()
+	}
+
+	return opts, nil
+}
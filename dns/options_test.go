@@ -0,0 +1,352 @@
+package dns
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/grafana/sobek"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseResolveOptions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		options string
+		want    resolveOptions
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "undefined options",
+			options: `undefined`,
+			want:    resolveOptions{Throw: true},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "timeout as a duration string",
+			options: `({timeout: "2s"})`,
+			want:    resolveOptions{QueryOptions: QueryOptions{Timeout: 2 * time.Second, explicit: explicitTimeout}, Throw: true},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "timeout as milliseconds",
+			options: `({timeout: 500})`,
+			want:    resolveOptions{QueryOptions: QueryOptions{Timeout: 500 * time.Millisecond, explicit: explicitTimeout}, Throw: true},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "retries",
+			options: `({retries: 3})`,
+			want:    resolveOptions{QueryOptions: QueryOptions{Retries: 3, explicit: explicitRetries}, Throw: true},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "throw disabled",
+			options: `({throw: false})`,
+			want:    resolveOptions{Throw: false},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "nxdomain as empty",
+			options: `({nxdomainAsEmpty: true})`,
+			want:    resolveOptions{Throw: true, NXDomainAsEmpty: true},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "follow CNAME records",
+			options: `({followCname: true, maxDepth: 4})`,
+			want:    resolveOptions{QueryOptions: QueryOptions{FollowCNAME: true, MaxCNAMEDepth: 4}, Throw: true},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "zero max depth",
+			options: `({followCname: true, maxDepth: 0})`,
+			wantErr: assert.Error,
+		},
+		{
+			name:    "recursion not desired",
+			options: `({recursionDesired: false})`,
+			want:    resolveOptions{QueryOptions: QueryOptions{NoRecursion: true}, Throw: true},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "randomized case",
+			options: `({randomizeCase: true})`,
+			want:    resolveOptions{QueryOptions: QueryOptions{RandomizeCase: true}, Throw: true},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "authoritative answers",
+			options: `({requireAuthoritative: true})`,
+			want:    resolveOptions{Throw: true, RequireAuthoritative: true},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "verified answer names",
+			options: `({verifyAnswerNames: true})`,
+			want:    resolveOptions{QueryOptions: QueryOptions{VerifyAnswerNames: true}, Throw: true},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "network family",
+			options: `({networkFamily: "ipv6"})`,
+			want:    resolveOptions{QueryOptions: QueryOptions{NetworkFamily: AddressFamilyIPv6, explicit: explicitNetworkFamily}, Throw: true},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "invalid network family",
+			options: `({networkFamily: "ipx"})`,
+			wantErr: assert.Error,
+		},
+		{
+			name:    "negotiated EDNS size",
+			options: `({ednsSize: "auto"})`,
+			want:    resolveOptions{QueryOptions: QueryOptions{NegotiateEDNSSize: true}, Throw: true},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "EDNS fallback",
+			options: `({ednsSize: 1232, ednsFallback: true})`,
+			want:    resolveOptions{QueryOptions: QueryOptions{EDNSSize: 1232, EDNSFallback: true}, Throw: true},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "EDNS size",
+			options: `({ednsSize: 1232})`,
+			want:    resolveOptions{QueryOptions: QueryOptions{EDNSSize: 1232}, Throw: true},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "too small EDNS size",
+			options: `({ednsSize: 256})`,
+			wantErr: assert.Error,
+		},
+		{
+			name:    "raw messages",
+			options: `({raw: true})`,
+			want:    resolveOptions{Throw: true, Raw: true},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "authority and additional sections",
+			options: `({sections: true})`,
+			want:    resolveOptions{Throw: true, Sections: true},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "TSIG key with default algorithm",
+			options: `({tsig: {name: "k6-key", secret: "c2VjcmV0"}})`,
+			want: resolveOptions{
+				QueryOptions: QueryOptions{TSIG: &TSIGKey{Name: "k6-key.", Algorithm: dns.HmacSHA256, Secret: "c2VjcmV0"}},
+				Throw:        true,
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "TSIG key without secret",
+			options: `({tsig: {name: "k6-key"}})`,
+			wantErr: assert.Error,
+		},
+		{
+			name:    "client subnet",
+			options: `({clientSubnet: "198.51.100.0/24"})`,
+			want: resolveOptions{
+				QueryOptions: QueryOptions{ClientSubnet: &net.IPNet{
+					IP:   net.ParseIP("198.51.100.0").To4(),
+					Mask: net.CIDRMask(24, 32),
+				}},
+				Throw: true,
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "DoT with a pool of connections",
+			options: `({protocol: "DoT", tlsServerName: "dns.k6.test", poolSize: 4, idleTimeout: "30s"})`,
+			want: resolveOptions{
+				QueryOptions: QueryOptions{
+					Protocol:      protocolDoT,
+					TLSServerName: "dns.k6.test",
+					PoolSize:      4,
+					IdleTimeout:   30 * time.Second,
+					explicit:      explicitTLSServerName,
+				},
+				Throw: true,
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "DoH with custom headers",
+			options: `({protocol: "doh", dohPath: "/resolve", dohMethod: "get", httpVersion: "2", headers: {authorization: "Bearer token"}})`,
+			want: resolveOptions{
+				QueryOptions: QueryOptions{
+					Protocol:    protocolDoH,
+					DoHPath:     "/resolve",
+					DoHHeaders:  http.Header{"Authorization": {"Bearer token"}},
+					DoHMethod:   http.MethodGet,
+					HTTPVersion: httpVersion2,
+				},
+				Throw: true,
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "DoH over the unsupported HTTP/3",
+			options: `({protocol: "doh", httpVersion: "3"})`,
+			wantErr: assert.Error,
+		},
+		{
+			name:    "unsupported DoH method",
+			options: `({protocol: "doh", dohMethod: "PUT"})`,
+			wantErr: assert.Error,
+		},
+		{
+			name:    "relative DoH path",
+			options: `({protocol: "doh", dohPath: "resolve"})`,
+			wantErr: assert.Error,
+		},
+		{
+			name:    "unsupported protocol",
+			options: `({protocol: "quic"})`,
+			wantErr: assert.Error,
+		},
+		{
+			name:    "empty pool",
+			options: `({protocol: "tcp", poolSize: 0})`,
+			wantErr: assert.Error,
+		},
+		{
+			name:    "invalid client subnet",
+			options: `({clientSubnet: "europe"})`,
+			wantErr: assert.Error,
+		},
+		{
+			name:    "debug sample",
+			options: `({debug: 0.25})`,
+			want: resolveOptions{
+				QueryOptions: QueryOptions{Debug: func() *float64 { v := 0.25; return &v }()},
+				Throw:        true,
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "maximum number of answers",
+			options: `({maxAnswers: 10})`,
+			want:    resolveOptions{MaxAnswers: 10, Throw: true},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "zero maximum number of answers",
+			options: `({maxAnswers: 0})`,
+			wantErr: assert.Error,
+		},
+		{
+			name:    "out of range debug sample",
+			options: `({debug: 2})`,
+			wantErr: assert.Error,
+		},
+		{
+			name:    "negative retries",
+			options: `({retries: -1})`,
+			wantErr: assert.Error,
+		},
+		{
+			name:    "invalid timeout",
+			options: `({timeout: "soon"})`,
+			wantErr: assert.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rt := sobek.New()
+			value, err := rt.RunString(tt.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := parseResolveOptions(rt, value)
+			if !tt.wantErr(t, err, fmt.Sprintf("parseResolveOptions(%v)", tt.options)) {
+				return
+			}
+
+			if err == nil {
+				assert.Equalf(t, tt.want, got, "parseResolveOptions(%v)", tt.options)
+			}
+		})
+	}
+}
+
+func Test_parseLookupOptions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		options string
+		want    lookupOptions
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "undefined options",
+			options: `undefined`,
+			want:    lookupOptions{All: true},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "ipv6 family with ipv6 first order",
+			options: `({family: "ipv6", order: "ipv6first"})`,
+			want: lookupOptions{
+				LookupOptions: LookupOptions{Family: AddressFamilyIPv6, Order: AddressOrderIPv6First},
+				All:           true,
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "single address",
+			options: `({family: "ipv4", all: false})`,
+			want:    lookupOptions{LookupOptions: LookupOptions{Family: AddressFamilyIPv4}},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "invalid family",
+			options: `({family: "ipx"})`,
+			wantErr: assert.Error,
+		},
+		{
+			name:    "invalid order",
+			options: `({order: "random"})`,
+			wantErr: assert.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rt := sobek.New()
+			value, err := rt.RunString(tt.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := parseLookupOptions(rt, value)
+			if !tt.wantErr(t, err, fmt.Sprintf("parseLookupOptions(%v)", tt.options)) {
+				return
+			}
+
+			if err == nil {
+				assert.Equalf(t, tt.want, got, "parseLookupOptions(%v)", tt.options)
+			}
+		})
+	}
+}
@@ -0,0 +1,455 @@
+package dns
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/grafana/sobek"
+	"github.com/miekg/dns"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/promises"
+)
+
+// Magic numbers of the global header of pcap files, as read in little-endian
+// order, and of the section header block of pcapng files.
+const (
+	pcapMagicMicroseconds = 0xa1b2c3d4
+	pcapMagicNanoseconds  = 0xa1b23c4d
+	pcapngMagic           = 0x0a0d0d0a
+)
+
+// Link-layer header types of pcap files, as defined by tcpdump.org.
+const (
+	linkTypeNull     = 0
+	linkTypeEthernet = 1
+	linkTypeRaw      = 101
+	linkTypeLoop     = 108
+	linkTypeLinuxSLL = 113
+	linkTypeIPv4     = 228
+	linkTypeIPv6     = 229
+)
+
+// Sizes of the headers found in pcap files.
+const (
+	pcapHeaderSize       = 24
+	pcapRecordHeaderSize = 16
+	ethernetHeaderSize   = 14
+	linuxSLLHeaderSize   = 16
+	ipv6HeaderSize       = 40
+	udpHeaderSize        = 8
+)
+
+// Capture holds the DNS queries of a pcap file, along with the time they were
+// captured at. A file is loaded once, and its Capture is shared, read-only, by all
+// the VUs.
+type Capture struct {
+	// Count holds the number of queries.
+	Count int `js:"count"`
+
+	// Duration holds the duration between the first and the last query, in
+	// milliseconds.
+	Duration float64 `js:"duration"`
+
+	queries []capturedQuery
+}
+
+// capturedQuery is a DNS query of a Capture.
+type capturedQuery struct {
+	// offset holds the duration between the first query and this one
+	offset time.Duration
+
+	// msg holds the wire format of the query
+	msg []byte
+}
+
+// LoadPcap loads the DNS queries sent over UDP to port 53 found in the pcap file.
+// It can only be used in the init context, and each file is only read and parsed
+// once, its queries being shared by all the VUs.
+func (mi *ModuleInstance) LoadPcap(path string, options sobek.Value) (*Capture, error) {
+	var port uint16 = 53
+	if !common.IsNullish(options) {
+		if v := options.ToObject(mi.vu.Runtime()).Get("port"); !common.IsNullish(v) {
+			var p int64
+			if err := mi.vu.Runtime().ExportTo(v, &p); err != nil || p < 1 || p > math.MaxUint16 {
+				return nil, fmt.Errorf("invalid loadPcap options: port option must be a port number; got %v instead", v)
+			}
+
+			port = uint16(p)
+		}
+	}
+
+	path, read, err := mi.initFile("loadPcap", path)
+	if err != nil {
+		return nil, err
+	}
+
+	key := fmt.Sprintf("%s:%d", path, port)
+	capture, err := mi.captures.load(key, read, func(data []byte) (*Capture, error) {
+		return parsePcap(data, port)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("loading the capture %s failed; reason: %w", path, err)
+	}
+
+	return capture, nil
+}
+
+// parsePcap parses the DNS queries sent over UDP to the port out of the pcap
+// data. Packets which are not such queries, including fragmented ones, are skipped.
+func parsePcap(data []byte, port uint16) (*Capture, error) {
+	if len(data) >= 4 && binary.LittleEndian.Uint32(data) == pcapngMagic {
+		return nil, errors.New("pcapng files are not supported; convert them with `editcap -F pcap`")
+	}
+
+	if len(data) < pcapHeaderSize {
+		return nil, errors.New("not a pcap file")
+	}
+
+	var order binary.ByteOrder
+	var resolution time.Duration
+
+	switch {
+	case binary.LittleEndian.Uint32(data) == pcapMagicMicroseconds:
+		order, resolution = binary.LittleEndian, time.Microsecond
+	case binary.LittleEndian.Uint32(data) == pcapMagicNanoseconds:
+		order, resolution = binary.LittleEndian, time.Nanosecond
+	case binary.BigEndian.Uint32(data) == pcapMagicMicroseconds:
+		order, resolution = binary.BigEndian, time.Microsecond
+	case binary.BigEndian.Uint32(data) == pcapMagicNanoseconds:
+		order, resolution = binary.BigEndian, time.Nanosecond
+	default:
+		return nil, errors.New("not a pcap file")
+	}
+
+	linkType := order.Uint32(data[20:24])
+
+	capture := &Capture{queries: []capturedQuery{}}
+
+	var first time.Duration
+	for offset := pcapHeaderSize; offset+pcapRecordHeaderSize <= len(data); {
+		header := data[offset : offset+pcapRecordHeaderSize]
+		length := int(order.Uint32(header[8:12]))
+
+		offset += pcapRecordHeaderSize
+		if length > len(data)-offset {
+			return nil, fmt.Errorf("truncated packet at offset %d", offset)
+		}
+
+		packet := data[offset : offset+length]
+		offset += length
+
+		payload, ok := udpPayload(packet, linkType, port)
+		if !ok || !isDNSQuery(payload) {
+			continue
+		}
+
+		timestamp := time.Duration(order.Uint32(header[0:4]))*time.Second +
+			time.Duration(order.Uint32(header[4:8]))*resolution
+
+		if len(capture.queries) == 0 {
+			first = timestamp
+		}
+
+		capture.queries = append(capture.queries, capturedQuery{
+			offset: max(timestamp-first, 0),
+			msg:    append([]byte(nil), payload...),
+		})
+	}
+
+	if len(capture.queries) == 0 {
+		return nil, fmt.Errorf("no DNS query sent over UDP to port %d was captured", port)
+	}
+
+	capture.Count = len(capture.queries)
+	capture.Duration = float64(capture.queries[len(capture.queries)-1].offset) / float64(time.Millisecond)
+
+	return capture, nil
+}
+
+// udpPayload returns the payload of the packet of the link type, if it is an
+// unfragmented UDP datagram sent to the port over IPv4 or IPv6.
+func udpPayload(packet []byte, linkType uint32, port uint16) ([]byte, bool) {
+	var ip []byte
+	var etherType uint16
+
+	switch linkType {
+	case linkTypeEthernet:
+		if len(packet) < ethernetHeaderSize {
+			return nil, false
+		}
+
+		etherType, ip = binary.BigEndian.Uint16(packet[12:14]), packet[ethernetHeaderSize:]
+
+		// 802.1Q VLAN tags precede the actual ether type
+		for etherType == 0x8100 && len(ip) >= 4 {
+			etherType, ip = binary.BigEndian.Uint16(ip[2:4]), ip[4:]
+		}
+	case linkTypeLinuxSLL:
+		if len(packet) < linuxSLLHeaderSize {
+			return nil, false
+		}
+
+		etherType, ip = binary.BigEndian.Uint16(packet[14:16]), packet[linuxSLLHeaderSize:]
+	case linkTypeNull, linkTypeLoop:
+		// The address family is in the host byte order of the capturing machine
+		if len(packet) < 4 {
+			return nil, false
+		}
+
+		ip = packet[4:]
+	case linkTypeRaw, linkTypeIPv4, linkTypeIPv6:
+		ip = packet
+	default:
+		return nil, false
+	}
+
+	if len(ip) == 0 || (etherType != 0 && etherType != 0x0800 && etherType != 0x86dd) {
+		return nil, false
+	}
+
+	var udp []byte
+	switch ip[0] >> 4 {
+	case 4:
+		headerLength := int(ip[0]&0x0f) * 4
+		if len(ip) < 20 || headerLength < 20 || len(ip) < headerLength || ip[9] != 17 {
+			return nil, false
+		}
+
+		// Fragments, including the first one, can not be replayed on their own
+		if binary.BigEndian.Uint16(ip[6:8])&0x3fff != 0 {
+			return nil, false
+		}
+
+		totalLength := int(binary.BigEndian.Uint16(ip[2:4]))
+		if totalLength < headerLength || totalLength > len(ip) {
+			return nil, false
+		}
+
+		udp = ip[headerLength:totalLength]
+	case 6:
+		if len(ip) < ipv6HeaderSize || ip[6] != 17 {
+			return nil, false
+		}
+
+		payloadLength := int(binary.BigEndian.Uint16(ip[4:6]))
+		if payloadLength > len(ip)-ipv6HeaderSize {
+			return nil, false
+		}
+
+		udp = ip[ipv6HeaderSize : ipv6HeaderSize+payloadLength]
+	default:
+		return nil, false
+	}
+
+	if len(udp) < udpHeaderSize || binary.BigEndian.Uint16(udp[2:4]) != port {
+		return nil, false
+	}
+
+	length := int(binary.BigEndian.Uint16(udp[4:6]))
+	if length < udpHeaderSize || length > len(udp) {
+		return nil, false
+	}
+
+	return udp[udpHeaderSize:length], true
+}
+
+// isDNSQuery returns whether the payload is a valid DNS query holding a question.
+func isDNSQuery(payload []byte) bool {
+	if len(payload) < headerSize || payload[2]&0x80 != 0 {
+		return false
+	}
+
+	msg := new(dns.Msg)
+
+	return msg.Unpack(payload) == nil && len(msg.Question) > 0
+}
+
+// replayOptions holds the options that can be passed to the replay function.
+type replayOptions struct {
+	resolveOptions
+
+	// Speed holds the factor the timing of the capture is scaled by, e.g. 2 to
+	// replay it twice as fast.
+	Speed float64
+
+	// QPS holds the number of queries to send per second, regardless of the
+	// timing of the capture, if set.
+	QPS float64
+
+	// MaxOutstanding holds the maximum number of queries outstanding at once,
+	// beyond which the queries due are skipped rather than sent.
+	MaxOutstanding int
+}
+
+// parseReplayOptions parses the options object passed to the replay function.
+func parseReplayOptions(rt *sobek.Runtime, value sobek.Value) (replayOptions, error) {
+	resolveOpts, err := parseResolveOptions(rt, value)
+	if err != nil {
+		return replayOptions{}, err
+	}
+
+	opts := replayOptions{
+		resolveOptions: resolveOpts,
+		Speed:          1,
+		MaxOutstanding: defaultMaxOutstanding,
+	}
+
+	if common.IsNullish(value) {
+		return opts, nil
+	}
+
+	params := value.ToObject(rt)
+
+	for _, rate := range []struct {
+		option string
+		value  *float64
+	}{
+		{option: "speed", value: &opts.Speed},
+		{option: "qps", value: &opts.QPS},
+	} {
+		v := params.Get(rate.option)
+		if common.IsNullish(v) {
+			continue
+		}
+
+		var r float64
+		if err := rt.ExportTo(v, &r); err != nil || math.IsNaN(r) || math.IsInf(r, 0) || r <= 0 {
+			return opts, fmt.Errorf("%s option must be a strictly positive number; got %v instead", rate.option, v)
+		}
+
+		*rate.value = r
+	}
+
+	if v := params.Get("maxOutstanding"); !common.IsNullish(v) {
+		var maxOutstanding int64
+		if err := rt.ExportTo(v, &maxOutstanding); err != nil || maxOutstanding < 1 {
+			return opts, fmt.Errorf("maxOutstanding option must be a strictly positive integer; got %v instead", v)
+		}
+
+		opts.MaxOutstanding = int(maxOutstanding)
+	}
+
+	return opts, nil
+}
+
+// Replay sends the queries of the capture loaded by LoadPcap to the nameserver,
+// at the time they were captured at, scaled by the speed option, or at a constant
+// rate if the qps option is set. It resolves to the statistics of the replay.
+//
+// Queries are sent as they were captured, with a new ID, and emit the same
+// metrics as sendMessage.
+func (mi *ModuleInstance) Replay(capture, nameserverAddr, options sobek.Value) *sobek.Promise {
+	promise, resolve, reject := promises.New(mi.vu)
+
+	if mi.vu.State() == nil {
+		reject(errors.New("replay can not be used in the init context"))
+		return promise
+	}
+
+	loaded, ok := exportCapture(capture)
+	if !ok {
+		reject(fmt.Errorf("capture must be loaded with loadPcap(); got %v instead", capture))
+		return promise
+	}
+
+	nameserver, err := mi.exportNameserver(nameserverAddr)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	opts, err := parseReplayOptions(mi.vu.Runtime(), options)
+	if err != nil {
+		reject(fmt.Errorf("invalid replay options: %w", err))
+		return promise
+	}
+
+	ctx, cancel, err := mi.withAbortSignal(opts.Signal)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer cancel()
+
+		resolve(mi.replay(ctx, loaded, nameserver, opts))
+	}()
+
+	return promise
+}
+
+// exportCapture exports the capture loaded by LoadPcap from the JS runtime.
+func exportCapture(value sobek.Value) (*Capture, bool) {
+	if common.IsNullish(value) {
+		return nil, false
+	}
+
+	capture, ok := value.Export().(*Capture)
+
+	return capture, ok && capture != nil
+}
+
+// replay sends the queries of the capture to the nameserver, each at the time it
+// is due, and waits for the outstanding ones to be answered.
+func (mi *ModuleInstance) replay(
+	ctx context.Context,
+	capture *Capture,
+	nameserver Nameserver,
+	opts replayOptions,
+) *constantRateResult {
+	count := int64(len(capture.queries))
+
+	// The capture spans from its first query until the one which would follow its
+	// last one, given the average interval between queries
+	span := time.Duration(float64(capture.queries[count-1].offset) / opts.Speed)
+	if count > 1 {
+		span += span / time.Duration(count-1)
+	}
+
+	due := func(i int64) (time.Duration, bool) {
+		if i >= count {
+			return 0, false
+		}
+
+		return time.Duration(float64(capture.queries[i].offset) / opts.Speed), true
+	}
+
+	if opts.QPS > 0 {
+		interval := time.Duration(float64(time.Second) / opts.QPS)
+		span = time.Duration(count) * interval
+
+		due = func(i int64) (time.Duration, bool) {
+			return time.Duration(i) * interval, i < count
+		}
+	}
+
+	s := schedule{
+		due: due,
+		window: func(scheduled int64) time.Duration {
+			return time.Duration(float64(span) * float64(scheduled) / float64(count))
+		},
+		maxOutstanding: opts.MaxOutstanding,
+	}
+
+	result := mi.runSchedule(ctx, s, func(ctx context.Context, i int64) (*Response, time.Duration, error) {
+		msg := new(dns.Msg)
+		if err := msg.Unpack(capture.queries[i].msg); err != nil {
+			return nil, 0, err
+		}
+
+		msg.Id = dns.Id()
+
+		return mi.sendMessage(ctx, msg, nameserver, opts.QueryOptions)
+	})
+
+	if span > 0 {
+		result.TargetQPS = float64(count) / span.Seconds()
+	}
+
+	return result
+}
@@ -0,0 +1,241 @@
+package dns
+
+import (
+	"encoding/binary"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/fsext"
+	"go.k6.io/k6/metrics"
+)
+
+func TestReplay(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Captured queries should be replayed with their timing", func(t *testing.T) {
+		t.Parallel()
+
+		runtime, err := newConfiguredRuntime(t)
+		require.NoError(t, err)
+
+		fs := fsext.NewMemMapFs()
+		require.NoError(t, fsext.WriteFile(fs, "/scripts/dns.pcap", newTestPcap(t, linkTypeEthernet, []testPacket{
+			{at: 0, query: newTestQuery(t, "k6.test.", dns.TypeA), port: 53},
+			{at: 100 * time.Millisecond, query: newTestQuery(t, "missing.k6.test.", dns.TypeA), port: 53},
+			{at: 200 * time.Millisecond, query: newTestQuery(t, "k6.test.", dns.TypeAAAA), port: 53},
+		}), 0o644))
+
+		runtime.VU.InitEnvField.FileSystems = map[string]fsext.Fs{"file": fs}
+		runtime.VU.InitEnvField.CWD = &url.URL{Scheme: "file", Path: "/scripts/"}
+
+		_, err = runtime.VU.Runtime().RunString(`
+			const server = dns.startServer(["k6.test. 60 IN A 203.0.113.1"]);
+			const capture = dns.loadPcap("./dns.pcap");
+
+			if (capture.count !== 3 || capture.duration !== 200) {
+				throw "expected 3 queries captured over 200ms; got " + capture.count + " over " + capture.duration;
+			}
+		`)
+		require.NoError(t, err)
+
+		runtime.MoveToVUContext(&lib.State{
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+			Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+			Samples:        make(chan metrics.SampleContainer, 1024),
+		})
+
+		_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+			const start = Date.now();
+			const result = await dns.replay(capture, server.address, { speed: 2 });
+			const elapsed = Date.now() - start;
+
+			if (result.sent !== 3 || result.succeeded !== 3) {
+				throw "expected the 3 queries to be replayed; got " + JSON.stringify(result);
+			}
+
+			if (result.rcodes.NOERROR !== 2 || result.rcodes.NXDOMAIN !== 1) {
+				throw "expected the replayed queries to be answered; got " + JSON.stringify(result.rcodes);
+			}
+
+			// The capture lasts 200ms, which are replayed twice as fast
+			if (elapsed < 95 || elapsed > 190) {
+				throw "expected the replay to last about 100ms; took " + elapsed + "ms";
+			}
+
+			const log = server.log();
+			if (log[1].name !== "missing.k6.test" || log[2].type !== "AAAA") {
+				throw "expected queries to be replayed in order; got " + JSON.stringify(log);
+			}
+		`))
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("Loading captures outside of the init context should fail", func(t *testing.T) {
+		t.Parallel()
+
+		runtime, err := newConfiguredRuntime(t)
+		require.NoError(t, err)
+
+		runtime.MoveToVUContext(&lib.State{
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+			Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+			Samples:        make(chan metrics.SampleContainer, 1024),
+		})
+
+		_, err = runtime.VU.Runtime().RunString(`dns.loadPcap("./dns.pcap")`)
+
+		assert.Error(t, err)
+	})
+}
+
+func Test_parsePcap(t *testing.T) {
+	t.Parallel()
+
+	query := newTestQuery(t, "k6.test.", dns.TypeA)
+
+	response := new(dns.Msg)
+	require.NoError(t, response.Unpack(query))
+	response.Response = true
+	packedResponse, err := response.Pack()
+	require.NoError(t, err)
+
+	t.Run("queries sent to the port should be parsed", func(t *testing.T) {
+		t.Parallel()
+
+		for _, linkType := range []uint32{linkTypeEthernet, linkTypeRaw, linkTypeLinuxSLL} {
+			capture, err := parsePcap(newTestPcap(t, linkType, []testPacket{
+				{at: time.Second, query: query, port: 53},
+				{at: time.Second + time.Millisecond, query: packedResponse, port: 53},
+				{at: 2 * time.Second, query: query, port: 5353},
+				{at: 3 * time.Second, query: query, port: 53, ipv6: true},
+			}), 53)
+			require.NoError(t, err)
+
+			require.Len(t, capture.queries, 2)
+			assert.Equal(t, query, capture.queries[0].msg)
+			assert.Equal(t, time.Duration(0), capture.queries[0].offset)
+			assert.Equal(t, 2*time.Second, capture.queries[1].offset)
+			assert.InDelta(t, 2000, capture.Duration, 0.001)
+		}
+	})
+
+	t.Run("captures without queries should fail", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := parsePcap(newTestPcap(t, linkTypeEthernet, []testPacket{
+			{at: time.Second, query: query, port: 5353},
+		}), 53)
+		assert.Error(t, err)
+	})
+
+	t.Run("pcapng files should fail", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := parsePcap([]byte{0x0a, 0x0d, 0x0d, 0x0a, 0, 0, 0, 0}, 53)
+		assert.ErrorContains(t, err, "pcapng")
+	})
+
+	t.Run("truncated files should fail", func(t *testing.T) {
+		t.Parallel()
+
+		data := newTestPcap(t, linkTypeEthernet, []testPacket{{at: time.Second, query: query, port: 53}})
+
+		_, err := parsePcap(data[:len(data)-1], 53)
+		assert.Error(t, err)
+	})
+}
+
+// testPacket describes a packet of a pcap file written by newTestPcap.
+type testPacket struct {
+	at    time.Duration
+	query []byte
+	port  uint16
+	ipv6  bool
+}
+
+// newTestQuery returns the wire format of a query for the name and type.
+func newTestQuery(t *testing.T, name string, qtype uint16) []byte {
+	t.Helper()
+
+	msg := new(dns.Msg)
+	msg.SetQuestion(name, qtype)
+
+	packed, err := msg.Pack()
+	require.NoError(t, err)
+
+	return packed
+}
+
+// newTestPcap writes a pcap file, with nanosecond timestamps, holding the packets
+// as UDP datagrams sent over the link type.
+func newTestPcap(t *testing.T, linkType uint32, packets []testPacket) []byte {
+	t.Helper()
+
+	header := make([]byte, pcapHeaderSize)
+	binary.LittleEndian.PutUint32(header[0:4], pcapMagicNanoseconds)
+	binary.LittleEndian.PutUint16(header[4:6], 2)
+	binary.LittleEndian.PutUint16(header[6:8], 4)
+	binary.LittleEndian.PutUint32(header[16:20], 65535)
+	binary.LittleEndian.PutUint32(header[20:24], linkType)
+
+	data := header
+	for _, packet := range packets {
+		udp := make([]byte, udpHeaderSize, udpHeaderSize+len(packet.query))
+		binary.BigEndian.PutUint16(udp[0:2], 41000)
+		binary.BigEndian.PutUint16(udp[2:4], packet.port)
+		binary.BigEndian.PutUint16(udp[4:6], uint16(udpHeaderSize+len(packet.query)))
+		udp = append(udp, packet.query...)
+
+		var ip []byte
+		etherType := uint16(0x0800)
+		if packet.ipv6 {
+			etherType = 0x86dd
+			ip = make([]byte, ipv6HeaderSize)
+			ip[0] = 6 << 4
+			binary.BigEndian.PutUint16(ip[4:6], uint16(len(udp)))
+			ip[6] = 17
+			copy(ip[8:24], net.ParseIP("2001:db8::1"))
+			copy(ip[24:40], net.ParseIP("2001:db8::53"))
+		} else {
+			ip = make([]byte, 20)
+			ip[0] = 4<<4 | 5
+			binary.BigEndian.PutUint16(ip[2:4], uint16(20+len(udp)))
+			ip[8] = 64
+			ip[9] = 17
+			copy(ip[12:16], net.IPv4(192, 0, 2, 1).To4())
+			copy(ip[16:20], net.IPv4(192, 0, 2, 53).To4())
+		}
+
+		ip = append(ip, udp...)
+
+		var frame []byte
+		switch linkType {
+		case linkTypeEthernet:
+			frame = make([]byte, ethernetHeaderSize)
+			binary.BigEndian.PutUint16(frame[12:14], etherType)
+		case linkTypeLinuxSLL:
+			frame = make([]byte, linuxSLLHeaderSize)
+			binary.BigEndian.PutUint16(frame[14:16], etherType)
+		}
+
+		frame = append(frame, ip...)
+
+		record := make([]byte, pcapRecordHeaderSize)
+		binary.LittleEndian.PutUint32(record[0:4], uint32(packet.at/time.Second))
+		binary.LittleEndian.PutUint32(record[4:8], uint32(packet.at%time.Second))
+		binary.LittleEndian.PutUint32(record[8:12], uint32(len(frame)))
+		binary.LittleEndian.PutUint32(record[12:16], uint32(len(frame)))
+
+		data = append(data, record...)
+		data = append(data, frame...)
+	}
+
+	return data
+}
@@ -0,0 +1,605 @@
+package dns
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Protocols queries can be sent over.
+const (
+	protocolUDP = "udp"
+	protocolTCP = "tcp"
+	protocolDoT = "dot"
+	protocolDoH = "doh"
+
+	// protocolUnix is the protocol of the queries sent to nameservers listening on
+	// a Unix domain socket, framed as over TCP.
+	protocolUnix = "unix"
+)
+
+// Defaults of the persistent connections queries are sent over with TCP and DoT.
+const (
+	defaultPoolSize    = 1
+	defaultIdleTimeout = 10 * time.Second
+)
+
+// errConnClosed is returned when a pooled connection was closed while a query was
+// sent over it.
+var errConnClosed = errors.New("connection closed")
+
+// connPool holds persistent connections to nameservers, over which queries sent
+// with TCP and DoT are pipelined, as defined by RFC 7766: multiple queries can be
+// outstanding over a connection, and responses are matched to them by ID.
+type connPool struct {
+	mu    sync.Mutex
+	conns map[poolKey][]*pipelinedConn
+
+	// dialing holds the number of connections being dialed, which count towards
+	// the size of the pool
+	dialing map[poolKey]int
+
+	// dialed is closed, and replaced, whenever a connection was dialed, waking up
+	// the queries waiting for one
+	dialed chan struct{}
+}
+
+// poolKey identifies the connections to a nameserver over a protocol.
+type poolKey struct {
+	protocol   string
+	addr       string
+	serverName string
+}
+
+// newConnPool creates an empty connPool.
+func newConnPool() *connPool {
+	return &connPool{
+		conns:   make(map[poolKey][]*pipelinedConn),
+		dialing: make(map[poolKey]int),
+		dialed:  make(chan struct{}),
+	}
+}
+
+// acquire returns a connection of the pool the query of the ID can be sent over,
+// along with the channel its response is handed over to, and whether it was
+// already open. A new connection is dialed when all the connections have queries
+// outstanding, as long as the pool holds less than poolSize of them, or waited for
+// if all of them are still being dialed.
+//
+// The ID is reserved on the connection before the pool's lock is released, so
+// that concurrent queries of the same ID are never sent over the same connection.
+func (p *connPool) acquire(
+	ctx context.Context,
+	key poolKey,
+	id uint16,
+	poolSize int,
+	idleTimeout time.Duration,
+	dial func(ctx context.Context) (*dns.Conn, error),
+) (*pipelinedConn, chan pipelinedResponse, bool, error) {
+	for {
+		p.mu.Lock()
+
+		var best *pipelinedConn
+		for _, conn := range p.conns[key] {
+			if conn.isClosed() || conn.isPending(id) {
+				continue
+			}
+
+			if best == nil || conn.outstanding() < best.outstanding() {
+				best = conn
+			}
+		}
+
+		full := len(p.conns[key])+p.dialing[key] >= poolSize
+		if best != nil && (best.outstanding() == 0 || full) {
+			responses, ok := best.reserve(id)
+			p.mu.Unlock()
+
+			// The connection was closed since it was picked, and another one is
+			if !ok {
+				continue
+			}
+
+			return best, responses, true, nil
+		}
+
+		if best != nil || !full || p.dialing[key] == 0 {
+			break
+		}
+
+		// The pool is full of connections being dialed, one of which is waited for
+		dialed := p.dialed
+		p.mu.Unlock()
+
+		select {
+		case <-dialed:
+		case <-ctx.Done():
+			return nil, nil, false, ctx.Err()
+		}
+	}
+
+	p.dialing[key]++
+	p.mu.Unlock()
+
+	// Dialing, and the TLS handshake, happen without holding the pool's lock
+	conn, err := dial(ctx)
+
+	p.mu.Lock()
+	p.dialing[key]--
+	if p.dialing[key] == 0 {
+		delete(p.dialing, key)
+	}
+
+	close(p.dialed)
+	p.dialed = make(chan struct{})
+
+	if err != nil {
+		p.mu.Unlock()
+		return nil, nil, false, err
+	}
+
+	pipelined := newPipelinedConn(conn, idleTimeout, func(closed *pipelinedConn) {
+		p.remove(key, closed)
+	})
+
+	// A new connection has no query outstanding, so the reservation always succeeds
+	responses, _ := pipelined.reserve(id)
+
+	p.conns[key] = append(p.conns[key], pipelined)
+	p.mu.Unlock()
+
+	go pipelined.readLoop()
+
+	return pipelined, responses, false, nil
+}
+
+// remove removes the closed connection from the pool.
+func (p *connPool) remove(key poolKey, closed *pipelinedConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	conns := p.conns[key]
+	for i, conn := range conns {
+		if conn == closed {
+			p.conns[key] = append(conns[:i:i], conns[i+1:]...)
+			break
+		}
+	}
+
+	if len(p.conns[key]) == 0 {
+		delete(p.conns, key)
+	}
+}
+
+// pipelinedConn is a persistent connection to a nameserver, over which multiple
+// queries can be outstanding.
+type pipelinedConn struct {
+	conn        *dns.Conn
+	idleTimeout time.Duration
+	onClose     func(conn *pipelinedConn)
+
+	// writeMu serializes the writing of queries to the connection
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	pending map[uint16]chan pipelinedResponse
+	closed  bool
+
+	// tls describes the TLS connection of DoT connections, once described
+	tlsOnce sync.Once
+	tls     *TLSInfo
+}
+
+// pipelinedResponse holds the wire format of the response to a query sent over a
+// pipelinedConn, or the error reading it failed with.
+type pipelinedResponse struct {
+	raw []byte
+	err error
+}
+
+// newPipelinedConn creates a pipelinedConn over the connection, which closes once
+// it was idle for the idle timeout, calling onClose.
+func newPipelinedConn(conn *dns.Conn, idleTimeout time.Duration, onClose func(conn *pipelinedConn)) *pipelinedConn {
+	return &pipelinedConn{
+		conn:        conn,
+		idleTimeout: idleTimeout,
+		onClose:     onClose,
+		pending:     make(map[uint16]chan pipelinedResponse),
+	}
+}
+
+// tlsInfo returns the TLSInfo of the connection, or nil if it is not secured
+// with TLS. It is only described once, as it remains the same for the lifetime
+// of the connection.
+func (c *pipelinedConn) tlsInfo() *TLSInfo {
+	c.tlsOnce.Do(func() {
+		c.tls = connTLSInfo(c.conn.Conn)
+	})
+
+	return c.tls
+}
+
+// isClosed returns whether the connection is closed.
+func (c *pipelinedConn) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.closed
+}
+
+// isPending returns whether a query of the ID is outstanding over the connection.
+func (c *pipelinedConn) isPending(id uint16) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, pending := c.pending[id]
+
+	return pending
+}
+
+// reserve reserves the ID for a query about to be sent over the connection, and
+// returns the channel its response is handed over to. It returns false if the
+// connection is closed, or a query of the ID is already outstanding over it.
+func (c *pipelinedConn) reserve(id uint16) (chan pipelinedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, pending := c.pending[id]; pending || c.closed {
+		return nil, false
+	}
+
+	responses := make(chan pipelinedResponse, 1)
+	c.pending[id] = responses
+
+	return responses, true
+}
+
+// outstanding returns the number of queries outstanding over the connection.
+func (c *pipelinedConn) outstanding() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.pending)
+}
+
+// exchange writes the packed query of the ID, which was reserved along with the
+// responses channel, to the connection, and waits for the response to it until the
+// context is done. It returns the response's wire format.
+func (c *pipelinedConn) exchange(
+	ctx context.Context,
+	id uint16,
+	responses chan pipelinedResponse,
+	packed []byte,
+) ([]byte, error) {
+	// Only the reservation of this query is released, as the ID might have been
+	// reserved again once its response was received
+	defer func() {
+		c.mu.Lock()
+		if c.pending[id] == responses {
+			delete(c.pending, id)
+		}
+		c.mu.Unlock()
+	}()
+
+	if c.isClosed() {
+		return nil, errConnClosed
+	}
+
+	c.writeMu.Lock()
+	deadline, _ := ctx.Deadline()
+	_ = c.conn.SetWriteDeadline(deadline)
+
+	// Writing is interrupted as soon as the context is done, which is waited for
+	// before releasing the connection, lest it interrupts the next query's writing
+	interrupted := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		_ = c.conn.SetWriteDeadline(time.Now())
+		close(interrupted)
+	})
+
+	_, err := c.conn.Write(packed)
+	if !stop() {
+		<-interrupted
+	}
+	c.writeMu.Unlock()
+
+	if err != nil {
+		_ = c.close(err)
+		return nil, err
+	}
+
+	select {
+	case response := <-responses:
+		return response.raw, response.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// readLoop reads the responses received over the connection, and hands them over
+// to the queries waiting for them, until the connection fails, or is idle for
+// longer than its idle timeout.
+func (c *pipelinedConn) readLoop() {
+	for {
+		// Reading only times out when no query is outstanding
+		c.mu.Lock()
+		if len(c.pending) == 0 {
+			_ = c.conn.SetReadDeadline(time.Now().Add(c.idleTimeout))
+		} else {
+			_ = c.conn.SetReadDeadline(time.Time{})
+		}
+		c.mu.Unlock()
+
+		msg, buf, err := readMsg(c.conn.Conn, 0)
+		if err != nil {
+			if isTimeout(err) && c.outstanding() > 0 {
+				continue
+			}
+
+			_ = c.close(err)
+
+			return
+		}
+
+		id := msgID(msg)
+
+		c.mu.Lock()
+		responses, ok := c.pending[id]
+		delete(c.pending, id)
+		c.mu.Unlock()
+
+		// Responses to queries which are no longer waited for are dropped
+		if ok {
+			responses <- pipelinedResponse{raw: append([]byte(nil), msg...)}
+		}
+
+		putBuffer(buf)
+	}
+}
+
+// close closes the connection, failing the queries outstanding over it with the
+// error. It returns the error closing the connection failed with, if any, unless
+// it was already closed.
+func (c *pipelinedConn) close(err error) error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+
+	c.closed = true
+	for id, responses := range c.pending {
+		responses <- pipelinedResponse{err: err}
+		delete(c.pending, id)
+	}
+	c.mu.Unlock()
+
+	closeErr := c.conn.Close()
+	c.onClose(c)
+
+	if errors.Is(closeErr, net.ErrClosed) {
+		return nil
+	}
+
+	return closeErr
+}
+
+// closeAll closes all the connections of the pool, failing the queries
+// outstanding over them. It returns the errors closing them failed with, if any.
+func (p *connPool) closeAll() error {
+	p.mu.Lock()
+	var conns []*pipelinedConn
+	for _, keyConns := range p.conns {
+		conns = append(conns, keyConns...)
+	}
+	p.mu.Unlock()
+
+	errs := make([]error, 0, len(conns))
+	for _, conn := range conns {
+		errs = append(errs, conn.close(errConnClosed))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Close closes the persistent connections the client sends queries over with TCP
+// and DoT, failing the queries outstanding over them, along with the idle
+// connections of DoH. The client remains usable, and opens new connections for
+// the queries sent afterwards.
+//
+// The connections shared with other clients are left open.
+func (r *Client) Close() error {
+	return r.conns.close()
+}
+
+// exchangeStream sends the packed message to the nameserver over a pooled TCP or
+// DoT connection, and retransmits it up to opts.Retries times if the attempts time
+// out. It returns the response along with its wire format.
+func (r *Client) exchangeStream(
+	ctx context.Context,
+	id uint16,
+	packed []byte,
+	nameserver Nameserver,
+	opts QueryOptions,
+	result *Response,
+) (*dns.Msg, []byte, error) {
+	key := poolKey{protocol: opts.Protocol, addr: nameserver.Addr()}
+	switch {
+	case nameserver.Socket != "":
+		key = poolKey{protocol: protocolUnix, addr: nameserver.Socket}
+	case opts.Protocol == protocolDoT:
+		key.serverName = r.tlsServerName(nameserver, opts)
+	}
+
+	poolSize := opts.PoolSize
+	if poolSize <= 0 {
+		poolSize = defaultPoolSize
+	}
+
+	idleTimeout := opts.IdleTimeout
+	if idleTimeout <= 0 {
+		idleTimeout = defaultIdleTimeout
+	}
+
+	dial := func(ctx context.Context) (*dns.Conn, error) {
+		return r.dialStream(ctx, key, opts.Timeout)
+	}
+
+	for {
+		conn, responses, reused, err := r.connections().pool.acquire(ctx, key, id, poolSize, idleTimeout, dial)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if reused {
+			result.ReusedConnections++
+		}
+
+		result.OpenConnections = r.openConnections.Load()
+
+		result.localAddr = conn.conn.LocalAddr()
+		result.TLS = conn.tlsInfo()
+
+		raw, err := attemptStream(ctx, conn, id, responses, packed, opts.Timeout)
+
+		// Connections might be closed by the nameserver, or for being idle, as they
+		// are reused, in which case the query is sent over a new connection instead
+		if err != nil && reused && !isTimeout(err) && ctx.Err() == nil {
+			result.ReusedConnections--
+			continue
+		}
+
+		result.Attempts++
+		if err == nil {
+			response := new(dns.Msg)
+			if err := response.Unpack(raw); err != nil {
+				return nil, nil, err
+			}
+
+			return response, raw, nil
+		}
+
+		if !isTimeout(err) {
+			return nil, nil, err
+		}
+
+		result.Timeouts++
+		if result.Attempts > opts.Retries || ctx.Err() != nil {
+			return nil, nil, err
+		}
+	}
+}
+
+// attemptStream sends the packed query over the pipelined connection once, and
+// waits for the response to it, bounding the exchange to the provided timeout if it
+// is greater than zero, or to defaultAttemptTimeout otherwise.
+func attemptStream(
+	ctx context.Context,
+	conn *pipelinedConn,
+	id uint16,
+	responses chan pipelinedResponse,
+	packed []byte,
+	timeout time.Duration,
+) ([]byte, error) {
+	if timeout <= 0 {
+		timeout = defaultAttemptTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	raw, err := conn.exchange(ctx, id, responses, packed)
+	if err != nil && errors.Is(ctx.Err(), context.Canceled) {
+		return nil, ctx.Err()
+	}
+
+	return raw, err
+}
+
+// dialStream opens a TCP connection to the nameserver, secured with TLS for DoT,
+// or a connection to its Unix domain socket, bounding the dial and handshake to
+// the timeout, and accounts for it in the client's open connections count until
+// it is closed.
+func (r *Client) dialStream(ctx context.Context, key poolKey, timeout time.Duration) (*dns.Conn, error) {
+	if timeout <= 0 {
+		timeout = defaultAttemptTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	network := "tcp"
+	if key.protocol == protocolUnix {
+		network = "unix"
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, key.addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if key.protocol == protocolDoT {
+		config := r.newTLSConfig()
+		config.ServerName = key.serverName
+
+		tlsConn := tls.Client(conn, config)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+
+		conn = tlsConn
+	}
+
+	r.openConnections.Add(1)
+
+	return &dns.Conn{Conn: &countedConn{Conn: conn, openConnections: r.openConnections}}, nil
+}
+
+// tlsServerName returns the name the certificate of the nameserver is verified
+// against over DoT: opts.TLSServerName if set, its IP address otherwise.
+func (r *Client) tlsServerName(nameserver Nameserver, opts QueryOptions) string {
+	if opts.TLSServerName != "" {
+		return opts.TLSServerName
+	}
+
+	return nameserver.IP.String()
+}
+
+// newTLSConfig returns the TLS configuration DoT connections are established with.
+func (r *Client) newTLSConfig() *tls.Config {
+	if r.tlsConfig != nil {
+		if config := r.tlsConfig(); config != nil {
+			return config
+		}
+	}
+
+	return &tls.Config{MinVersion: tls.VersionTLS12} //nolint:gosec
+}
+
+// countedConn is a connection accounted for in a client's open connections count
+// until it is closed.
+type countedConn struct {
+	net.Conn
+
+	openConnections *atomic.Int64
+	closeOnce       sync.Once
+}
+
+// Close closes the connection, and accounts for it in the client's open
+// connections count.
+func (c *countedConn) Close() error {
+	err := c.Conn.Close()
+	c.closeOnce.Do(func() {
+		c.openConnections.Add(-1)
+	})
+
+	return err
+}
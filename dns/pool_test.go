@@ -0,0 +1,355 @@
+package dns
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/js/compiler"
+	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+)
+
+func TestClient_exchangeStream(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Queries over TCP should be pipelined over a persistent connection", func(t *testing.T) {
+		t.Parallel()
+
+		faults := noServerFaults()
+		faults.Delay = 50 * time.Millisecond
+
+		server := newServer(faults, 1)
+		_, err := server.AddRecord("k6.test. 60 IN A 203.0.113.1")
+		require.NoError(t, err)
+		require.NoError(t, server.listen(defaultServerAddress))
+		t.Cleanup(server.Close)
+
+		nameserver, err := parseNameserverAddr(server.Address)
+		require.NoError(t, err)
+
+		client := NewDNSClient()
+		opts := QueryOptions{Protocol: protocolTCP}
+
+		var wg sync.WaitGroup
+		responses := make([]*Response, 5)
+		errs := make([]error, 5)
+		for i := range responses {
+			wg.Add(1)
+
+			go func(i int) {
+				defer wg.Done()
+
+				responses[i], errs[i] = client.Query(context.Background(), "k6.test", "A", nameserver, opts)
+			}(i)
+		}
+
+		wg.Wait()
+
+		reused := 0
+		for i, response := range responses {
+			require.NoError(t, errs[i])
+			assert.Equal(t, []string{"203.0.113.1"}, response.Answers)
+			assert.Equal(t, protocolTCP, response.Protocol)
+
+			reused += response.ReusedConnections
+		}
+
+		// All the queries were outstanding over the same connection at once
+		assert.Equal(t, 4, reused)
+		assert.Equal(t, int64(1), client.openConnections.Load())
+
+		sources := map[string]bool{}
+		for _, query := range server.Log() {
+			sources[query.Source] = true
+		}
+
+		assert.Len(t, sources, 1)
+	})
+
+	t.Run("Concurrent queries of the same ID should not share a connection", func(t *testing.T) {
+		t.Parallel()
+
+		faults := noServerFaults()
+		faults.Delay = 50 * time.Millisecond
+
+		server := newServer(faults, 1)
+		for _, record := range []string{"a.k6.test. 60 IN A 203.0.113.1", "b.k6.test. 60 IN A 203.0.113.2"} {
+			_, err := server.AddRecord(record)
+			require.NoError(t, err)
+		}
+		require.NoError(t, server.listen(defaultServerAddress))
+		t.Cleanup(server.Close)
+
+		dial := func(context.Context) (*dns.Conn, error) {
+			return dns.DialTimeout("tcp", server.Address, time.Second)
+		}
+
+		// The ID is reserved on the first connection as soon as it is acquired
+		pool := newConnPool()
+		t.Cleanup(func() { _ = pool.closeAll() })
+
+		key := poolKey{protocol: protocolTCP, addr: server.Address}
+		first, firstResponses, _, err := pool.acquire(context.Background(), key, 42, 1, time.Second, dial)
+		require.NoError(t, err)
+
+		second, secondResponses, _, err := pool.acquire(context.Background(), key, 42, 1, time.Second, dial)
+		require.NoError(t, err)
+		assert.NotSame(t, first, second)
+
+		// Both queries are outstanding at once, and answered with their own response
+		names := []string{"a.k6.test.", "b.k6.test."}
+		conns := []*pipelinedConn{first, second}
+		reserved := []chan pipelinedResponse{firstResponses, secondResponses}
+
+		var wg sync.WaitGroup
+		raws := make([][]byte, len(names))
+		errs := make([]error, len(names))
+		for i, name := range names {
+			wg.Add(1)
+
+			go func(i int, name string) {
+				defer wg.Done()
+
+				msg := new(dns.Msg)
+				msg.SetQuestion(name, dns.TypeA)
+				msg.Id = 42
+
+				packed, err := msg.Pack()
+				require.NoError(t, err)
+
+				raws[i], errs[i] = conns[i].exchange(context.Background(), 42, reserved[i], packed)
+			}(i, name)
+		}
+
+		wg.Wait()
+
+		for i, name := range names {
+			require.NoError(t, errs[i])
+
+			response := new(dns.Msg)
+			require.NoError(t, response.Unpack(raws[i]))
+			require.Len(t, response.Question, 1)
+			assert.Equal(t, name, response.Question[0].Name)
+
+			// The reservations are released once the queries are done
+			assert.False(t, conns[i].isPending(42))
+		}
+	})
+
+	t.Run("Idle connections should be closed", func(t *testing.T) {
+		t.Parallel()
+
+		server := newServer(noServerFaults(), 1)
+		_, err := server.AddRecord("k6.test. 60 IN A 203.0.113.1")
+		require.NoError(t, err)
+		require.NoError(t, server.listen(defaultServerAddress))
+		t.Cleanup(server.Close)
+
+		nameserver, err := parseNameserverAddr(server.Address)
+		require.NoError(t, err)
+
+		client := NewDNSClient()
+		opts := QueryOptions{Protocol: protocolTCP, IdleTimeout: 50 * time.Millisecond}
+
+		_, err = client.Query(context.Background(), "k6.test", "A", nameserver, opts)
+		require.NoError(t, err)
+
+		assert.Eventually(t, func() bool {
+			return client.openConnections.Load() == 0
+		}, time.Second, 10*time.Millisecond)
+
+		// Once closed, a new connection is dialed
+		response, err := client.Query(context.Background(), "k6.test", "A", nameserver, opts)
+		require.NoError(t, err)
+		assert.Zero(t, response.ReusedConnections)
+	})
+
+	t.Run("Closing the client should close its connections", func(t *testing.T) {
+		t.Parallel()
+
+		server := newServer(noServerFaults(), 1)
+		_, err := server.AddRecord("k6.test. 60 IN A 203.0.113.1")
+		require.NoError(t, err)
+		require.NoError(t, server.listen(defaultServerAddress))
+		t.Cleanup(server.Close)
+
+		nameserver, err := parseNameserverAddr(server.Address)
+		require.NoError(t, err)
+
+		client := NewDNSClient()
+		opts := QueryOptions{Protocol: protocolTCP}
+
+		_, err = client.Query(context.Background(), "k6.test", "A", nameserver, opts)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), client.openConnections.Load())
+
+		require.NoError(t, client.Close())
+		assert.Zero(t, client.openConnections.Load())
+
+		// Once closed, the client dials a new connection
+		response, err := client.Query(context.Background(), "k6.test", "A", nameserver, opts)
+		require.NoError(t, err)
+		assert.Zero(t, response.ReusedConnections)
+		assert.NoError(t, client.Close())
+	})
+
+	t.Run("Queries over DoT should verify the nameserver's certificate", func(t *testing.T) {
+		t.Parallel()
+
+		cert, roots := newTestTLSCertificate(t, "dot.k6.test")
+
+		listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		})
+		require.NoError(t, err)
+
+		server := newServer(noServerFaults(), 1)
+		_, err = server.AddRecord("k6.test. 60 IN A 203.0.113.1")
+		require.NoError(t, err)
+
+		dotServer := &dns.Server{Listener: listener, Net: "tcp-tls", Handler: server}
+		go func() { _ = dotServer.ActivateAndServe() }()
+		t.Cleanup(func() { _ = dotServer.Shutdown() })
+
+		nameserver, err := parseNameserverAddr(listener.Addr().String())
+		require.NoError(t, err)
+
+		client := NewDNSClient()
+		client.tlsConfig = func() *tls.Config {
+			return &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}
+		}
+
+		opts := QueryOptions{Protocol: protocolDoT, TLSServerName: "dot.k6.test"}
+
+		response, err := client.Query(context.Background(), "k6.test", "A", nameserver, opts)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"203.0.113.1"}, response.Answers)
+		assert.Equal(t, protocolDoT, response.Protocol)
+
+		// The TLS connection is described along with the response
+		require.NotNil(t, response.TLS)
+		assert.Equal(t, "TLS 1.3", response.TLS.Version)
+		assert.Equal(t, "dot.k6.test", response.TLS.ServerName)
+		require.Len(t, response.TLS.Certificates, 1)
+		assert.Equal(t, []string{"dot.k6.test"}, response.TLS.Certificates[0].DNSNames)
+		assert.Equal(t, []string{"192.0.2.1"}, response.TLS.Certificates[0].IPAddresses)
+		assert.Equal(t, "CN=dot.k6.test", response.TLS.Certificates[0].Subject)
+		assert.Len(t, response.TLS.Certificates[0].Fingerprint, 64)
+
+		// The certificate is not valid for the IP address of the nameserver
+		_, err = client.Query(context.Background(), "k6.test", "A", nameserver, QueryOptions{Protocol: protocolDoT})
+		assert.Error(t, err)
+	})
+}
+
+// newTestTLSCertificate creates a self-signed TLS certificate for the DNS name,
+// along with a pool of roots trusting it.
+func newTestTLSCertificate(t *testing.T, name string) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{name},
+		IPAddresses:           []net.IP{net.IPv4(192, 0, 2, 1)},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(leaf)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, roots
+}
+
+func Test_pipelinedConn_exchange(t *testing.T) {
+	t.Parallel()
+
+	// Writing to a pipe blocks until its other end reads
+	client, server := net.Pipe()
+	t.Cleanup(func() { _ = server.Close() })
+
+	conn := newPipelinedConn(&dns.Conn{Conn: client}, time.Second, func(*pipelinedConn) {})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	responses, ok := conn.reserve(1)
+	require.True(t, ok)
+
+	_, err := conn.exchange(ctx, 1, responses, newTestQuery(t, "k6.test.", dns.TypeA))
+
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.True(t, conn.isClosed())
+}
+
+func TestModuleInstance_closeOnTeardown(t *testing.T) {
+	t.Parallel()
+
+	rootModule := New()
+
+	runtime := modulestest.NewRuntime(t)
+	require.NoError(t, runtime.SetupModuleSystem(
+		map[string]interface{}{"k6/x/dns": rootModule},
+		nil,
+		compiler.New(runtime.VU.InitEnv().Logger),
+	))
+
+	_, err := runtime.VU.Runtime().RunString(initGlobals + `
+		const server = dns.startServer(["k6.test. 60 IN A 203.0.113.1"]);
+	`)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(runtime.VU.CtxField)
+	defer cancel()
+
+	runtime.VU.CtxField = ctx
+	runtime.MoveToVUContext(&lib.State{
+		BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+		Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+		Samples:        make(chan metrics.SampleContainer, 1024),
+	})
+
+	_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+		await dns.resolve("k6.test", "A", server.address, { protocol: "tcp" });
+	`))
+	require.NoError(t, err)
+	require.Equal(t, int64(1), rootModule.openConnections.Load())
+
+	// The connection is closed once the VU's context is done, before being idle
+	cancel()
+
+	assert.Eventually(t, func() bool {
+		return rootModule.openConnections.Load() == 0
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,262 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/grafana/sobek"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/promises"
+)
+
+const (
+	// defaultProfileQueries is the number of queries a profile sends, unless
+	// specified otherwise.
+	defaultProfileQueries = 200
+
+	// defaultProfileConcurrency is the number of queries a profile keeps in
+	// flight, unless specified otherwise, low enough for the latency not to
+	// include the time queries wait in the queues of the nameserver.
+	defaultProfileConcurrency = 1
+
+	// defaultProfileWarmup is the number of queries a profile sends before the
+	// ones it measures, unless specified otherwise, so that the caches along the
+	// way are primed.
+	defaultProfileWarmup = 10
+
+	// defaultProfileName and defaultProfileType describe the query a profile
+	// sends, unless specified otherwise, which any nameserver answers.
+	defaultProfileName = "."
+	defaultProfileType = "NS"
+)
+
+// profileOptions holds the options that can be passed to the profile function.
+type profileOptions struct {
+	benchmarkOptions
+
+	// Warmup holds the number of queries sent before the measured ones.
+	Warmup int64
+
+	// Query holds the query sent repeatedly.
+	Query batchQuery
+
+	// RecursionDesired indicates whether the recursion desired flag of the query
+	// is set.
+	RecursionDesired bool
+}
+
+// parseProfileOptions parses the options object passed to the profile function.
+//
+// It accepts the duration, timeout, concurrency and signal options of the
+// benchmark function, along with the queries, warmup, name, type and
+// recursionDesired options. Unlike benchmarks, profiles end after the number of
+// queries or the duration, whichever comes first.
+func parseProfileOptions(rt *sobek.Runtime, value sobek.Value) (profileOptions, error) {
+	benchmarkOpts, err := parseBenchmarkOptions(rt, value)
+	if err != nil {
+		return profileOptions{}, err
+	}
+
+	opts := profileOptions{
+		benchmarkOptions: benchmarkOpts,
+		Warmup:           defaultProfileWarmup,
+		Query:            batchQuery{Name: defaultProfileName, Type: defaultProfileType},
+		RecursionDesired: true,
+	}
+	opts.Count = defaultProfileQueries
+	opts.Concurrency = defaultProfileConcurrency
+
+	if common.IsNullish(value) {
+		return opts, nil
+	}
+
+	params := value.ToObject(rt)
+
+	if v := params.Get("concurrency"); !common.IsNullish(v) {
+		opts.Concurrency = benchmarkOpts.Concurrency
+	}
+
+	if v := params.Get("queries"); !common.IsNullish(v) {
+		if err := rt.ExportTo(v, &opts.Count); err != nil || opts.Count < 1 {
+			return opts, fmt.Errorf("queries option must be a strictly positive integer; got %v instead", v)
+		}
+	}
+
+	if v := params.Get("warmup"); !common.IsNullish(v) {
+		if err := rt.ExportTo(v, &opts.Warmup); err != nil || opts.Warmup < 0 {
+			return opts, fmt.Errorf("warmup option must be a positive integer; got %v instead", v)
+		}
+	}
+
+	if v := params.Get("name"); !common.IsNullish(v) {
+		name, err := exportDomainName(rt, v, "name option")
+		if err != nil {
+			return opts, err
+		}
+
+		opts.Query.Name = name
+	}
+
+	if v := params.Get("type"); !common.IsNullish(v) {
+		opts.Query.Type = strings.ToUpper(v.String())
+	}
+
+	if v := params.Get("recursionDesired"); !common.IsNullish(v) {
+		opts.RecursionDesired = v.ToBoolean()
+	}
+
+	return opts, nil
+}
+
+// profileResult is the object the profile function resolves to.
+type profileResult struct {
+	// Nameserver holds the address of the profiled nameserver.
+	Nameserver string `js:"nameserver"`
+
+	// Sent holds the number of measured queries which were sent, and either
+	// answered, lost or failed, before the profile ended.
+	Sent int64 `js:"sent"`
+
+	// Received holds the number of measured queries which were answered.
+	Received int64 `js:"received"`
+
+	// Lost holds the number of measured queries which were not answered in time.
+	Lost int64 `js:"lost"`
+
+	// Errors holds the number of measured queries which could not be sent, or
+	// whose response could not be read.
+	Errors int64 `js:"errors"`
+
+	// Loss holds the ratio of the sent queries which were lost, between 0 and 1.
+	Loss float64 `js:"loss"`
+
+	// Rcodes holds the number of responses, by response code name.
+	Rcodes map[string]int64 `js:"rcodes"`
+
+	// Duration holds the duration of the measured queries, in milliseconds.
+	Duration float64 `js:"duration"`
+
+	// P50, P95 and P99 hold the percentiles of the latency of the answered
+	// queries, in milliseconds.
+	P50 float64 `js:"p50"`
+	P95 float64 `js:"p95"`
+	P99 float64 `js:"p99"`
+
+	// Latency holds the distribution of the latency of the answered queries.
+	Latency benchmarkLatency `js:"latency"`
+}
+
+// newProfileResult creates a profileResult out of the result of the benchmark of
+// the measured queries.
+func newProfileResult(nameserver Nameserver, result *benchmarkResult) *profileResult {
+	profile := &profileResult{
+		Nameserver: nameserver.Addr(),
+		Sent:       result.Sent,
+		Received:   result.Received,
+		Lost:       result.Lost,
+		Errors:     result.Errors,
+		Rcodes:     result.Rcodes,
+		Duration:   result.Duration,
+		P50:        result.Latency.Med,
+		P95:        result.Latency.P95,
+		P99:        result.Latency.P99,
+		Latency:    result.Latency,
+	}
+
+	if result.Sent > 0 {
+		profile.Loss = float64(result.Lost) / float64(result.Sent)
+	}
+
+	return profile
+}
+
+// Profile sends a calibrated workload of queries to the nameserver, a few at a
+// time after warming it up, and resolves to the percentiles of their latency and
+// their loss, as a quick capacity pre-check before the main test.
+func (mi *ModuleInstance) Profile(nameserverAddr, options sobek.Value) *sobek.Promise {
+	promise, resolve, reject := promises.New(mi.vu)
+
+	if mi.vu.State() == nil {
+		reject(errors.New("profile can not be used in the init context"))
+		return promise
+	}
+
+	nameserver, err := mi.exportNameserver(nameserverAddr)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	opts, err := parseProfileOptions(mi.vu.Runtime(), options)
+	if err != nil {
+		reject(fmt.Errorf("invalid profile options: %w", err))
+		return promise
+	}
+
+	packed, err := packQueries([]batchQuery{opts.Query}, opts.RecursionDesired)
+	if err != nil {
+		reject(fmt.Errorf("invalid profile options: %w", err))
+		return promise
+	}
+
+	ctx, cancel, err := mi.withAbortSignal(opts.Signal)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer cancel()
+
+		result, err := mi.profile(ctx, packed, nameserver, opts)
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(result)
+	}()
+
+	return promise
+}
+
+// profile warms the nameserver up with the packed queries, without accounting for
+// them, then measures the latency and loss of the next ones, and emits their
+// metrics.
+func (mi *ModuleInstance) profile(
+	ctx context.Context,
+	packed *PackedQueries,
+	nameserver Nameserver,
+	opts profileOptions,
+) (*profileResult, error) {
+	if opts.Warmup > 0 {
+		warmup := opts.benchmarkOptions
+		warmup.Count = opts.Warmup
+
+		if _, err := runBenchmark(ctx, packed, nameserver, warmup); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Benchmarks with a number of queries run until all are sent, which the
+	// duration bounds
+	measureCtx, cancel := context.WithTimeout(ctx, opts.Duration)
+	defer cancel()
+
+	result, err := mi.benchmark(measureCtx, packed, nameserver, opts.benchmarkOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return newProfileResult(nameserver, result), nil
+}
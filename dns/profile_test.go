@@ -0,0 +1,98 @@
+package dns
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+)
+
+func TestModuleInstance_Profile(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		script  string
+		wantErr string
+	}{
+		{
+			name: "Profiling a nameserver should report the percentiles of its latency",
+			script: `
+				const result = await dns.profile(server.address, { queries: 50, warmup: 5, name: "k6.test", type: "a" });
+				if (result.sent !== 50 || result.received !== 50 || result.loss !== 0 || result.rcodes.NOERROR !== 50) {
+					throw new Error("unexpected result: " + JSON.stringify(result));
+				}
+
+				if (result.p50 <= 0 || result.p50 > result.p95 || result.p95 > result.p99 || result.p99 > result.latency.max) {
+					throw new Error("unexpected percentiles: " + JSON.stringify(result));
+				}
+
+				if (server.queries() !== 55) {
+					throw new Error("expected the server to receive 55 queries, including the warm-up; got " + server.queries());
+				}
+			`,
+		},
+		{
+			name: "Profiling a nameserver dropping queries should report their loss",
+			script: `
+				server.setFaults({ dropRate: 1 });
+				const result = await dns.profile(server.address, { queries: 4, warmup: 0, concurrency: 2, timeout: "50ms" });
+				if (result.sent !== 4 || result.lost !== 4 || result.loss !== 1 || result.p99 !== 0) {
+					throw new Error("unexpected result: " + JSON.stringify(result));
+				}
+			`,
+		},
+		{
+			name: "Profiles should end after their duration",
+			script: `
+				server.setFaults({ dropRate: 1 });
+				const result = await dns.profile(server.address, { queries: 1000, warmup: 0, duration: "100ms", timeout: "30ms" });
+				if (result.sent >= 1000 || result.lost !== result.sent) {
+					throw new Error("unexpected result: " + JSON.stringify(result));
+				}
+			`,
+		},
+		{
+			name:    "Invalid options should fail",
+			script:  `await dns.profile(server.address, { queries: 0 });`,
+			wantErr: "queries option must be a strictly positive integer",
+		},
+		{
+			name:    "Invalid record types should fail",
+			script:  `await dns.profile(server.address, { type: "NOPE" });`,
+			wantErr: "invalid profile options",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			runtime, err := newConfiguredRuntime(t)
+			require.NoError(t, err)
+
+			_, err = runtime.VU.Runtime().RunString(`
+				const server = dns.startServer(["k6.test. 60 IN A 203.0.113.1"]);
+			`)
+			require.NoError(t, err)
+
+			runtime.MoveToVUContext(&lib.State{
+				BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+				Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+				Samples:        make(chan metrics.SampleContainer, 1024),
+			})
+
+			_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(tt.script))
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/grafana/sobek"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/promises"
+)
+
+// defaultPublicResolvers holds the addresses of the public resolvers the
+// checkPropagation function queries when none are explicitly provided, by name.
+var defaultPublicResolvers = map[string]string{ //nolint:gochecknoglobals
+	"cloudflare": "1.1.1.1:53",
+	"google":     "8.8.8.8:53",
+	"quad9":      "9.9.9.9:53",
+	"opendns":    "208.67.222.222:53",
+	"controld":   "76.76.2.0:53",
+	"he":         "74.82.42.42:53",
+}
+
+// publicResolvers returns a copy of the curated public resolvers, suitable for
+// being exposed to, and modified by, a VU.
+func publicResolvers() map[string]string {
+	resolvers := make(map[string]string, len(defaultPublicResolvers))
+	for name, addr := range defaultPublicResolvers {
+		resolvers[name] = addr
+	}
+
+	return resolvers
+}
+
+// checkPropagationOptions holds the options that can be passed to the
+// checkPropagation function.
+type checkPropagationOptions struct {
+	resolveOptions
+
+	// Resolvers holds the resolvers to query, by name.
+	Resolvers map[string]Nameserver
+
+	// Match holds how the answers are compared to the expected values, one of
+	// recordMatchExact or recordMatchContains.
+	Match string
+}
+
+// parseCheckPropagationOptions parses the options object passed to the
+// checkPropagation function.
+//
+// It accepts the same options as the resolve function, along with the resolvers
+// and match options.
+func parseCheckPropagationOptions(rt *sobek.Runtime, value sobek.Value) (checkPropagationOptions, error) {
+	resolveOpts, err := parseResolveOptions(rt, value)
+	if err != nil {
+		return checkPropagationOptions{}, err
+	}
+
+	opts := checkPropagationOptions{
+		resolveOptions: resolveOpts,
+		Match:          recordMatchExact,
+	}
+
+	// Names which do not exist yet, or anymore, simply hold no records
+	opts.NXDomainAsEmpty = true
+
+	resolvers := defaultPublicResolvers
+
+	if !common.IsNullish(value) {
+		if v := value.ToObject(rt).Get("resolvers"); !common.IsNullish(v) {
+			if err := rt.ExportTo(v, &resolvers); err != nil || len(resolvers) == 0 {
+				return opts, fmt.Errorf("resolvers option must be a non-empty object mapping names to addresses; got %v instead", v)
+			}
+		}
+
+		if v := value.ToObject(rt).Get("match"); !common.IsNullish(v) {
+			if opts.Match, err = parseRecordMatch(v); err != nil {
+				return opts, err
+			}
+		}
+	}
+
+	opts.Resolvers = make(map[string]Nameserver, len(resolvers))
+	for name, addr := range resolvers {
+		nameserver, err := parseNameserverAddr(addr)
+		if err != nil {
+			return opts, fmt.Errorf("parsing the address of resolver %s failed: %w", name, err)
+		}
+
+		opts.Resolvers[name] = nameserver
+	}
+
+	return opts, nil
+}
+
+// checkPropagationResult is the object the checkPropagation function resolves to.
+type checkPropagationResult struct {
+	// Name holds the name which was resolved.
+	Name string `js:"name"`
+
+	// Type holds the record type which was resolved.
+	Type string `js:"type"`
+
+	// Propagated indicates whether the answers of all the resolvers matched the
+	// expected values.
+	Propagated bool `js:"propagated"`
+
+	// Resolvers holds the outcome of the check for each resolver, by name.
+	Resolvers map[string]*resolverPropagation `js:"resolvers"`
+}
+
+// resolverPropagation holds the outcome of the propagation check against a single
+// resolver.
+type resolverPropagation struct {
+	// Nameserver holds the address of the resolver.
+	Nameserver string `js:"nameserver"`
+
+	// Passed indicates whether the answers of the resolver matched the expected values.
+	Passed bool `js:"passed"`
+
+	// Answers holds the answers of the resolver.
+	Answers []string `js:"answers"`
+
+	// RTT holds the duration of the resolution, in milliseconds.
+	RTT float64 `js:"rtt"`
+
+	// Error holds the error the resolution failed with, if any.
+	Error *Error `js:"error"`
+}
+
+// CheckPropagation resolves a domain name against a set of public resolvers
+// concurrently, and resolves to whether the answers of each of them match the
+// expected values.
+//
+// The promise is not rejected when answers do not match, or when resolutions fail,
+// which is instead reported in the result.
+func (mi *ModuleInstance) CheckPropagation(query, recordType, expected, options sobek.Value) *sobek.Promise {
+	promise, resolve, reject := promises.New(mi.vu)
+
+	if mi.vu.State() == nil {
+		reject(errors.New("checkPropagation can not be used in the init context"))
+		return promise
+	}
+
+	queryStr, err := exportDomainName(mi.vu.Runtime(), query, "query")
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	var recordTypeStr string
+	if err := mi.vu.Runtime().ExportTo(recordType, &recordTypeStr); err != nil {
+		reject(fmt.Errorf("recordType must be a string; got %v instead", recordType))
+		return promise
+	}
+
+	if _, err := RecordTypeString(recordTypeStr); err != nil {
+		reject(fmt.Errorf("%w, %s is an invalid DNS record type", ErrUnsupportedRecordType, recordTypeStr))
+		return promise
+	}
+
+	var expectedValues []string
+	if err := mi.vu.Runtime().ExportTo(expected, &expectedValues); err != nil || expectedValues == nil {
+		reject(fmt.Errorf("expected must be an array of record data; got %v instead", expected))
+		return promise
+	}
+
+	opts, err := parseCheckPropagationOptions(mi.vu.Runtime(), options)
+	if err != nil {
+		reject(fmt.Errorf("invalid checkPropagation options: %w", err))
+		return promise
+	}
+
+	ctx, cancel, err := mi.withAbortSignal(opts.Signal)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer cancel()
+
+		resolve(mi.checkPropagation(ctx, queryStr, recordTypeStr, expectedValues, opts))
+	}()
+
+	return promise
+}
+
+// checkPropagation resolves the query against each of the resolvers concurrently,
+// and compares their answers to the expected values.
+func (mi *ModuleInstance) checkPropagation(
+	ctx context.Context,
+	query, recordType string,
+	expected []string,
+	opts checkPropagationOptions,
+) *checkPropagationResult {
+	result := &checkPropagationResult{
+		Name:       query,
+		Type:       recordType,
+		Propagated: true,
+		Resolvers:  make(map[string]*resolverPropagation, len(opts.Resolvers)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for name, nameserver := range opts.Resolvers {
+		wg.Add(1)
+
+		go func(name string, nameserver Nameserver) {
+			defer wg.Done()
+
+			response, duration, err := mi.resolveQuery(ctx, query, recordType, nameserver, opts.resolveOptions)
+
+			check := &resolverPropagation{
+				Nameserver: nameserver.Addr(),
+				Answers:    []string{},
+				RTT:        float64(duration) / float64(time.Millisecond),
+				Error:      asError(err),
+			}
+
+			if err == nil && response.Answers != nil {
+				check.Answers = response.Answers
+			}
+
+			check.Passed = err == nil && answersMatch(check.Answers, expected, opts.Match)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			result.Resolvers[name] = check
+			result.Propagated = result.Propagated && check.Passed
+		}(name, nameserver)
+	}
+
+	wg.Wait()
+
+	return result
+}
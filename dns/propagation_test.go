@@ -0,0 +1,81 @@
+package dns
+
+import (
+	"net"
+	"testing"
+
+	"github.com/grafana/sobek"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseCheckPropagationOptions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		options       string
+		wantResolvers map[string]Nameserver
+		wantMatch     string
+		wantErr       bool
+	}{
+		{
+			name:      "public resolvers by default",
+			options:   `undefined`,
+			wantMatch: recordMatchExact,
+		},
+		{
+			name:    "overridden resolvers",
+			options: `({resolvers: {primary: "192.0.2.53", secondary: "192.0.2.54:5353"}, match: "contains"})`,
+			wantResolvers: map[string]Nameserver{
+				"primary":   {IP: net.ParseIP("192.0.2.53"), Port: 53},
+				"secondary": {IP: net.ParseIP("192.0.2.54"), Port: 5353},
+			},
+			wantMatch: recordMatchContains,
+		},
+		{
+			name:    "no resolvers",
+			options: `({resolvers: {}})`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid resolver address",
+			options: `({resolvers: {primary: "ns.k6.io"}})`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid match",
+			options: `({match: "some"})`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rt := sobek.New()
+			value, err := rt.RunString(tt.options)
+			require.NoError(t, err)
+
+			got, err := parseCheckPropagationOptions(rt, value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantMatch, got.Match)
+			assert.True(t, got.NXDomainAsEmpty)
+
+			if tt.wantResolvers == nil {
+				assert.Len(t, got.Resolvers, len(defaultPublicResolvers))
+				return
+			}
+
+			assert.Equal(t, tt.wantResolvers, got.Resolvers)
+		})
+	}
+}
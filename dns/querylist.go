@@ -0,0 +1,231 @@
+package dns
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// QueryList holds queries loaded from a file, along with their weight. A file is
+// loaded once, and its QueryList is shared, read-only, by all the VUs.
+type QueryList struct {
+	// Count holds the number of queries.
+	Count int `js:"count"`
+
+	// TotalWeight holds the sum of the weights of the queries.
+	TotalWeight float64 `js:"totalWeight"`
+
+	queries []listedQuery
+
+	// cumulative holds the cumulative weights of the queries, in order
+	cumulative []float64
+}
+
+// listedQuery describes a query of a QueryList.
+type listedQuery struct {
+	// Name holds the domain name to resolve.
+	Name string `js:"name"`
+
+	// Type holds the record type to resolve.
+	Type string `js:"type"`
+
+	// Weight holds the weight of the query, relative to the other queries of the list.
+	Weight float64 `js:"weight"`
+}
+
+// Get returns the query at the index of the list.
+func (l *QueryList) Get(index int) (listedQuery, error) {
+	if index < 0 || index >= len(l.queries) {
+		return listedQuery{}, fmt.Errorf("index must be between 0 and %d; got %d instead", len(l.queries)-1, index)
+	}
+
+	return l.queries[index], nil
+}
+
+// Pick returns a query of the list at random, each query being picked with a
+// probability proportional to its weight.
+func (l *QueryList) Pick() listedQuery {
+	r := rand.Float64() * l.TotalWeight //nolint:gosec
+
+	i := sort.Search(len(l.cumulative), func(i int) bool {
+		return l.cumulative[i] > r
+	})
+
+	return l.queries[min(i, len(l.queries)-1)]
+}
+
+// batch returns the queries of the list, in order, regardless of their weight.
+func (l *QueryList) batch() []batchQuery {
+	batch := make([]batchQuery, len(l.queries))
+	for i, query := range l.queries {
+		batch[i] = batchQuery{Name: query.Name, Type: query.Type}
+	}
+
+	return batch
+}
+
+// LoadQueryList loads the queries listed by the file, in the CSV or JSON format as
+// told by its extension. It can only be used in the init context, and each file is
+// only read and parsed once, its queries being shared by all the VUs.
+func (mi *ModuleInstance) LoadQueryList(path string) (*QueryList, error) {
+	path, read, err := mi.initFile("loadQueryList", path)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := mi.queryLists.load(path, read, func(data []byte) (*QueryList, error) {
+		return parseQueryList(data, filepath.Ext(path))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("loading the query list %s failed; reason: %w", path, err)
+	}
+
+	return list, nil
+}
+
+// parseQueryList parses the queries listed by the data, in the format told by the
+// extension: either ".csv" or ".json".
+func parseQueryList(data []byte, extension string) (*QueryList, error) {
+	var queries []listedQuery
+	var err error
+
+	switch strings.ToLower(extension) {
+	case ".csv":
+		queries, err = parseCSVQueryList(data)
+	case ".json":
+		queries, err = parseJSONQueryList(data)
+	default:
+		return nil, fmt.Errorf("query lists must be .csv or .json files; got %q instead", extension)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(queries) == 0 {
+		return nil, errors.New("query lists must hold at least one query")
+	}
+
+	list := &QueryList{
+		Count:      len(queries),
+		queries:    queries,
+		cumulative: make([]float64, len(queries)),
+	}
+
+	for i, query := range queries {
+		list.TotalWeight += query.Weight
+		list.cumulative[i] = list.TotalWeight
+	}
+
+	return list, nil
+}
+
+// parseCSVQueryList parses the rows of the CSV data, holding the name, and
+// optionally the type and weight, of a query each. An optional header row, whose
+// first column is "name", is skipped, as are rows starting with '#'.
+func parseCSVQueryList(data []byte) ([]listedQuery, error) {
+	reader := csv.NewReader(strings.NewReader(string(data)))
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var queries []listedQuery
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return queries, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		if len(queries) == 0 && strings.EqualFold(row[0], "name") {
+			continue
+		}
+
+		var recordType, weight string
+		if len(row) > 1 {
+			recordType = row[1]
+		}
+
+		if len(row) > 2 {
+			weight = row[2]
+		}
+
+		query, err := newListedQuery(row[0], recordType, weight)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		queries = append(queries, query)
+	}
+}
+
+// parseJSONQueryList parses the JSON data, an array of {name, type, weight}
+// objects, whose type and weight are optional.
+func parseJSONQueryList(data []byte) ([]listedQuery, error) {
+	var rows []struct {
+		Name   string          `json:"name"`
+		Type   string          `json:"type"`
+		Weight json.RawMessage `json:"weight"`
+	}
+
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, fmt.Errorf("query lists must be arrays of {name, type, weight} objects; reason: %w", err)
+	}
+
+	queries := make([]listedQuery, 0, len(rows))
+	for i, row := range rows {
+		query, err := newListedQuery(row.Name, row.Type, string(row.Weight))
+		if err != nil {
+			return nil, fmt.Errorf("query %d: %w", i, err)
+		}
+
+		queries = append(queries, query)
+	}
+
+	return queries, nil
+}
+
+// newListedQuery creates the listedQuery of the name, type and weight, the latter
+// two defaulting to "A" and 1 when empty.
+func newListedQuery(name, recordType, weight string) (listedQuery, error) {
+	query := listedQuery{
+		Name:   strings.TrimSuffix(strings.TrimSpace(name), "."),
+		Type:   strings.ToUpper(strings.TrimSpace(recordType)),
+		Weight: 1,
+	}
+
+	if query.Name == "" {
+		return query, errors.New("name must be provided")
+	}
+
+	if query.Type == "" {
+		query.Type = RecordTypeA.String()
+	}
+
+	if _, err := RecordTypeString(query.Type); err != nil {
+		return query, fmt.Errorf("%w, %s is an invalid DNS record type", ErrUnsupportedRecordType, query.Type)
+	}
+
+	if weight = strings.TrimSpace(weight); weight != "" && weight != "null" {
+		w, err := strconv.ParseFloat(weight, 64)
+		if err != nil || math.IsNaN(w) || math.IsInf(w, 0) || w <= 0 {
+			return query, fmt.Errorf("weight must be a strictly positive number; got %s instead", weight)
+		}
+
+		query.Weight = w
+	}
+
+	return query, nil
+}
@@ -0,0 +1,180 @@
+package dns
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/fsext"
+	"go.k6.io/k6/metrics"
+)
+
+func TestLoadQueryList(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Loaded query lists should drive resolutions", func(t *testing.T) {
+		t.Parallel()
+
+		runtime, err := newConfiguredRuntime(t)
+		require.NoError(t, err)
+
+		fs := fsext.NewMemMapFs()
+		require.NoError(t, fsext.WriteFile(fs, "/scripts/queries.csv", []byte(
+			"name,type,weight\nk6.test,A,3\nmissing.k6.test,AAAA,1\n",
+		), 0o644))
+
+		runtime.VU.InitEnvField.FileSystems = map[string]fsext.Fs{"file": fs}
+		runtime.VU.InitEnvField.CWD = &url.URL{Scheme: "file", Path: "/scripts/"}
+
+		_, err = runtime.VU.Runtime().RunString(`
+			const server = dns.startServer(["k6.test. 60 IN A 203.0.113.1"]);
+			const queries = dns.loadQueryList("./queries.csv");
+
+			if (queries.count !== 2 || queries.totalWeight !== 4) {
+				throw "expected 2 queries of a total weight of 4; got " + queries.count + " and " + queries.totalWeight;
+			}
+
+			const second = queries.get(1);
+			if (second.name !== "missing.k6.test" || second.type !== "AAAA" || second.weight !== 1) {
+				throw "unexpected second query " + JSON.stringify(second);
+			}
+
+			if (dns.loadQueryList("/scripts/queries.csv") !== queries) {
+				throw "expected query lists to be loaded once";
+			}
+		`)
+		require.NoError(t, err)
+
+		runtime.MoveToVUContext(&lib.State{
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+			Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+			Samples:        make(chan metrics.SampleContainer, 1024),
+		})
+
+		_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+			const picked = queries.pick();
+			if (picked.name !== "k6.test" && picked.name !== "missing.k6.test") {
+				throw "unexpected picked query " + JSON.stringify(picked);
+			}
+
+			const results = await dns.resolveBatch(queries, server.address, { throw: false });
+			if (results.length !== 2 || results[0].answers[0] !== "203.0.113.1") {
+				throw "expected the queries to be resolved in order; got " + JSON.stringify(results);
+			}
+		`))
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("Loading query lists outside of the init context should fail", func(t *testing.T) {
+		t.Parallel()
+
+		runtime, err := newConfiguredRuntime(t)
+		require.NoError(t, err)
+
+		runtime.MoveToVUContext(&lib.State{
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+			Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+			Samples:        make(chan metrics.SampleContainer, 1024),
+		})
+
+		_, err = runtime.VU.Runtime().RunString(`dns.loadQueryList("./queries.csv")`)
+
+		assert.Error(t, err)
+	})
+}
+
+func Test_parseQueryList(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		data      string
+		extension string
+		want      []listedQuery
+		wantErr   assert.ErrorAssertionFunc
+	}{
+		{
+			name:      "csv without header",
+			data:      "# production sample\nk6.io\ngrafana.com., mx, 2.5\n",
+			extension: ".csv",
+			want: []listedQuery{
+				{Name: "k6.io", Type: "A", Weight: 1},
+				{Name: "grafana.com", Type: "MX", Weight: 2.5},
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:      "json",
+			data:      `[{"name": "k6.io", "type": "AAAA", "weight": 10}, {"name": "grafana.com"}]`,
+			extension: ".JSON",
+			want: []listedQuery{
+				{Name: "k6.io", Type: "AAAA", Weight: 10},
+				{Name: "grafana.com", Type: "A", Weight: 1},
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:      "invalid weight",
+			data:      "k6.io,A,0\n",
+			extension: ".csv",
+			wantErr:   assert.Error,
+		},
+		{
+			name:      "invalid type",
+			data:      `[{"name": "k6.io", "type": "BOGUS"}]`,
+			extension: ".json",
+			wantErr:   assert.Error,
+		},
+		{
+			name:      "missing name",
+			data:      `[{"type": "A"}]`,
+			extension: ".json",
+			wantErr:   assert.Error,
+		},
+		{
+			name:      "empty list",
+			data:      "name,type,weight\n",
+			extension: ".csv",
+			wantErr:   assert.Error,
+		},
+		{
+			name:      "unsupported format",
+			data:      "k6.io\n",
+			extension: ".txt",
+			wantErr:   assert.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseQueryList([]byte(tt.data), tt.extension)
+			tt.wantErr(t, err)
+
+			if err == nil {
+				assert.Equal(t, tt.want, got.queries)
+			}
+		})
+	}
+}
+
+func TestQueryList_Pick(t *testing.T) {
+	t.Parallel()
+
+	list, err := parseQueryList([]byte("k6.io,A,9\ngrafana.com,A,1\n"), ".csv")
+	require.NoError(t, err)
+
+	picked := map[string]int{}
+	for i := 0; i < 10000; i++ {
+		picked[list.Pick().Name]++
+	}
+
+	assert.InDelta(t, 9000, picked["k6.io"], 300)
+	assert.InDelta(t, 1000, picked["grafana.com"], 300)
+}
@@ -0,0 +1,26 @@
+package dns
+
+import (
+	"crypto/rand"
+)
+
+// randomizeCase randomizes the case of the ASCII letters of the name, as described
+// by the DNS 0x20 technique, adding entropy to queries which a spoofed response
+// would have to guess.
+func randomizeCase(name string) string {
+	random := make([]byte, len(name))
+	if _, err := rand.Read(random); err != nil {
+		return name
+	}
+
+	randomized := []byte(name)
+	for i, c := range randomized {
+		isLetter := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		if isLetter && random[i]&1 == 1 {
+			// Flipping the 0x20 bit of an ASCII letter switches its case
+			randomized[i] = c ^ 0x20
+		}
+	}
+
+	return string(randomized)
+}
@@ -0,0 +1,37 @@
+package dns
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_randomizeCase(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		in   string
+	}{
+		{name: "lowercase name", in: "www.k6.io."},
+		{name: "mixed case name", in: "WwW.K6.io."},
+		{name: "name without letters", in: "1.2.3.4.in-addr.arpa."},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := randomizeCase(tt.in)
+			assert.Len(t, got, len(tt.in))
+			assert.True(t, strings.EqualFold(tt.in, got), "randomizeCase(%q) = %q", tt.in, got)
+		})
+	}
+
+	// With enough letters, the case of at least one of them is bound to change
+	long := strings.Repeat("a", 64) + "."
+	assert.NotEqual(t, long, randomizeCase(long))
+}
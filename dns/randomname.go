@@ -0,0 +1,97 @@
+package dns
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strings"
+
+	"github.com/grafana/sobek"
+	"github.com/miekg/dns"
+	"go.k6.io/k6/js/common"
+)
+
+// randomLabelAlphabet holds the characters random labels are made of, which are
+// valid in hostnames and unaffected by case-insensitive comparisons.
+const randomLabelAlphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+// defaultRandomLabelEntropy is the number of random characters of random labels,
+// when none is provided, which makes collisions between queries unlikely.
+const defaultRandomLabelEntropy = 12
+
+// maxLabelLength is the maximum length of a label of a domain name.
+const maxLabelLength = 63
+
+// randomLabel returns a label made of entropy random characters, prepended with
+// the prefix.
+func randomLabel(prefix string, entropy int) (string, error) {
+	random := make([]byte, entropy)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("generating a random label failed: %w", err)
+	}
+
+	var b strings.Builder
+	b.Grow(len(prefix) + entropy)
+	b.WriteString(prefix)
+
+	for _, r := range random {
+		// The bias of the modulo is negligible for cache-busting purposes
+		b.WriteByte(randomLabelAlphabet[int(r)%len(randomLabelAlphabet)])
+	}
+
+	return b.String(), nil
+}
+
+// RandomName returns a domain name made of a random label, optionally prepended
+// with a prefix and followed by a zone, so that resolving it misses the caches
+// of recursive resolvers, and reaches the authoritative nameservers of the zone.
+func (mi *ModuleInstance) RandomName(options sobek.Value) (string, error) {
+	rt := mi.vu.Runtime()
+
+	prefix, zone, entropy := "", "", defaultRandomLabelEntropy
+
+	if !common.IsNullish(options) {
+		params := options.ToObject(rt)
+
+		if v := params.Get("prefix"); !common.IsNullish(v) {
+			prefix = v.String()
+		}
+
+		if v := params.Get("zone"); !common.IsNullish(v) {
+			var err error
+			if zone, err = exportDomainName(rt, v, "zone"); err != nil {
+				return "", err
+			}
+		}
+
+		if v := params.Get("entropy"); !common.IsNullish(v) {
+			var value int64
+			if err := rt.ExportTo(v, &value); err != nil || value < 1 {
+				return "", fmt.Errorf("entropy option must be a positive number of characters; got %v instead", v)
+			}
+
+			entropy = int(value)
+		}
+	}
+
+	if len(prefix)+entropy > maxLabelLength {
+		return "", fmt.Errorf(
+			"random label can not be longer than %d characters; got a %d characters prefix and an entropy of %d",
+			maxLabelLength, len(prefix), entropy,
+		)
+	}
+
+	name, err := randomLabel(prefix, entropy)
+	if err != nil {
+		return "", err
+	}
+
+	if zone = strings.Trim(zone, "."); zone != "" {
+		name += "." + zone
+	}
+
+	if _, ok := dns.IsDomainName(name); !ok {
+		return "", fmt.Errorf("random name %q is not a valid domain name", name)
+	}
+
+	return name, nil
+}
@@ -0,0 +1,86 @@
+package dns
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+)
+
+func Test_randomLabel(t *testing.T) {
+	t.Parallel()
+
+	first, err := randomLabel("k6-", 16)
+	require.NoError(t, err)
+
+	second, err := randomLabel("k6-", 16)
+	require.NoError(t, err)
+
+	assert.Regexp(t, `^k6-[a-z0-9]{16}$`, first)
+	assert.NotEqual(t, first, second)
+}
+
+func TestModuleInstance_RandomName(t *testing.T) {
+	t.Parallel()
+
+	runtime, err := newConfiguredRuntime(t)
+	require.NoError(t, err)
+
+	_, err = runtime.VU.Runtime().RunString(`
+		const server = dns.startServer(["k6.test. 60 IN A 203.0.113.1"]);
+	`)
+	require.NoError(t, err)
+
+	samples := make(chan metrics.SampleContainer, 1024)
+	runtime.MoveToVUContext(&lib.State{
+		BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+		Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+		Samples:        samples,
+	})
+
+	_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+		const name = dns.randomName({ prefix: "k6-", zone: "k6.test.", entropy: 8 });
+		if (!/^k6-[a-z0-9]{8}\.k6\.test$/.test(name)) {
+			throw new Error("unexpected random name: " + name);
+		}
+
+		if (!/^[a-z0-9]{12}$/.test(dns.randomName())) {
+			throw new Error("unexpected default random name: " + dns.randomName());
+		}
+
+		try {
+			dns.randomName({ prefix: "k6-", entropy: 61 });
+			throw new Error("expected labels longer than 63 characters to be rejected");
+		} catch (e) {
+			if (!String(e).includes("can not be longer")) {
+				throw e;
+			}
+		}
+
+		const result = await dns.resolve("k6.test", "A", server.address, { randomLabel: true, throw: false });
+		if (!/^[a-z0-9]{12}\.k6\.test$/.test(result.name) || result.rcode !== "NXDOMAIN") {
+			throw new Error("unexpected result: " + JSON.stringify(result));
+		}
+
+		const [query] = server.log();
+		if (query.name !== result.name) {
+			throw new Error("expected the random name to be queried; got " + query.name);
+		}
+	`))
+	assert.NoError(t, err)
+
+	close(samples)
+
+	queries := map[string]bool{}
+	for container := range samples {
+		for _, sample := range container.GetSamples() {
+			if query, ok := sample.Tags.Get("query"); ok {
+				queries[query] = true
+			}
+		}
+	}
+
+	assert.Equal(t, map[string]bool{"k6.test": true}, queries)
+}
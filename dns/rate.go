@@ -0,0 +1,366 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/grafana/sobek"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/promises"
+	"go.k6.io/k6/lib/types"
+)
+
+// defaultMaxOutstanding is the maximum number of queries a constant rate run
+// keeps outstanding at once, unless specified otherwise.
+const defaultMaxOutstanding = 1000
+
+// constantRateOptions holds the options that can be passed to the constantRate
+// function.
+type constantRateOptions struct {
+	resolveOptions
+
+	// QPS holds the number of queries to send per second.
+	QPS float64
+
+	// Duration holds the duration of the run, unless Count is set.
+	Duration time.Duration
+
+	// Count holds the number of queries to send, if set.
+	Count int64
+
+	// MaxOutstanding holds the maximum number of queries outstanding at once,
+	// beyond which the queries due are skipped rather than sent.
+	MaxOutstanding int
+}
+
+// parseConstantRateOptions parses the options object passed to the constantRate
+// function, which must at least hold the qps option.
+func parseConstantRateOptions(rt *sobek.Runtime, value sobek.Value) (constantRateOptions, error) {
+	if common.IsNullish(value) {
+		return constantRateOptions{}, errors.New("qps option must be provided")
+	}
+
+	resolveOpts, err := parseResolveOptions(rt, value)
+	if err != nil {
+		return constantRateOptions{}, err
+	}
+
+	opts := constantRateOptions{
+		resolveOptions: resolveOpts,
+		Duration:       defaultBenchmarkDuration,
+		MaxOutstanding: defaultMaxOutstanding,
+	}
+
+	params := value.ToObject(rt)
+
+	v := params.Get("qps")
+	if common.IsNullish(v) {
+		return opts, errors.New("qps option must be provided")
+	}
+
+	if err := rt.ExportTo(v, &opts.QPS); err != nil || math.IsNaN(opts.QPS) || math.IsInf(opts.QPS, 0) || opts.QPS <= 0 {
+		return opts, fmt.Errorf("qps option must be a strictly positive number; got %v instead", v)
+	}
+
+	if v := params.Get("duration"); !common.IsNullish(v) {
+		d, err := types.GetDurationValue(v.Export())
+		if err != nil {
+			return opts, fmt.Errorf("duration option is invalid; reason: %w", err)
+		}
+
+		if d <= 0 {
+			return opts, fmt.Errorf("duration option must be a strictly positive duration; got %v instead", v)
+		}
+
+		opts.Duration = d
+	}
+
+	if v := params.Get("count"); !common.IsNullish(v) {
+		if err := rt.ExportTo(v, &opts.Count); err != nil || opts.Count < 1 {
+			return opts, fmt.Errorf("count option must be a strictly positive integer; got %v instead", v)
+		}
+	}
+
+	if v := params.Get("maxOutstanding"); !common.IsNullish(v) {
+		var maxOutstanding int64
+		if err := rt.ExportTo(v, &maxOutstanding); err != nil || maxOutstanding < 1 {
+			return opts, fmt.Errorf("maxOutstanding option must be a strictly positive integer; got %v instead", v)
+		}
+
+		opts.MaxOutstanding = int(maxOutstanding)
+	}
+
+	return opts, nil
+}
+
+// constantRateResult is the object the constantRate function resolves to.
+type constantRateResult struct {
+	// TargetQPS holds the number of queries per second which were to be sent.
+	TargetQPS float64 `js:"targetQps"`
+
+	// AchievedQPS holds the number of queries per second which were sent.
+	AchievedQPS float64 `js:"achievedQps"`
+
+	// Sent holds the number of queries which were sent.
+	Sent int64 `js:"sent"`
+
+	// Succeeded holds the number of queries which resolved successfully.
+	Succeeded int64 `js:"succeeded"`
+
+	// Failed holds the number of queries which failed, including the timed out ones.
+	Failed int64 `js:"failed"`
+
+	// Timeouts holds the number of queries which timed out.
+	Timeouts int64 `js:"timeouts"`
+
+	// Skipped holds the number of queries which were not sent, as MaxOutstanding
+	// queries were outstanding when they were due.
+	Skipped int64 `js:"skipped"`
+
+	// Rcodes holds the number of responses, by response code name.
+	Rcodes map[string]int64 `js:"rcodes"`
+
+	// Duration holds the duration of the run, in milliseconds, until the last
+	// query was answered.
+	Duration float64 `js:"duration"`
+
+	// MaxLag holds the longest delay a query was sent with past the time it was
+	// due, in milliseconds.
+	MaxLag float64 `js:"maxLag"`
+
+	// Latency holds the distribution of the duration of the answered queries.
+	Latency benchmarkLatency `js:"latency"`
+}
+
+// ConstantRate resolves the queries, an array of {name, type} objects, in turn
+// against the nameserver, sending them at a constant rate for a duration or a
+// number of queries, regardless of how fast the nameserver answers them. It
+// resolves to the statistics of the run, including the achieved rate.
+//
+// As opposed to benchmark, each query is a full resolution, emitting the same
+// metrics as resolve.
+func (mi *ModuleInstance) ConstantRate(queries, nameserverAddr, options sobek.Value) *sobek.Promise {
+	promise, resolve, reject := promises.New(mi.vu)
+
+	if mi.vu.State() == nil {
+		reject(errors.New("constantRate can not be used in the init context"))
+		return promise
+	}
+
+	batch, err := exportBatchQueries(mi.vu.Runtime(), queries)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	if len(batch) == 0 {
+		reject(errors.New("queries must hold at least one query"))
+		return promise
+	}
+
+	for _, query := range batch {
+		if _, err := RecordTypeString(query.Type); err != nil {
+			reject(fmt.Errorf("%w, %s is an invalid DNS record type", ErrUnsupportedRecordType, query.Type))
+			return promise
+		}
+	}
+
+	nameserver, err := mi.exportNameserver(nameserverAddr)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	opts, err := parseConstantRateOptions(mi.vu.Runtime(), options)
+	if err != nil {
+		reject(fmt.Errorf("invalid constantRate options: %w", err))
+		return promise
+	}
+
+	ctx, cancel, err := mi.withAbortSignal(opts.Signal)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer cancel()
+
+		resolve(mi.constantRate(ctx, batch, nameserver, opts))
+	}()
+
+	return promise
+}
+
+// constantRateStats holds the statistics of a constant rate run, safe for
+// concurrent use.
+type constantRateStats struct {
+	mu        sync.Mutex
+	succeeded int64
+	failed    int64
+	timeouts  int64
+	rcodes    map[string]int64
+	latency   latencyHistogram
+}
+
+// record accounts for the outcome of a query resolved in the duration.
+func (s *constantRateStats) record(response *Response, duration time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err != nil {
+		s.failed++
+
+		var dnsErr *Error
+		if errors.As(err, &dnsErr) && dnsErr.Kind == Timeout {
+			s.timeouts++
+		}
+	} else {
+		s.succeeded++
+	}
+
+	if response != nil && response.Rcode != "" {
+		s.rcodes[response.Rcode]++
+		s.latency.record(duration)
+	}
+}
+
+// constantRate sends the queries of the batch to the nameserver, in turn, each
+// at the time it is due for the rate to be opts.QPS, and waits for the outstanding
+// ones to be answered.
+func (mi *ModuleInstance) constantRate(
+	ctx context.Context,
+	batch []batchQuery,
+	nameserver Nameserver,
+	opts constantRateOptions,
+) *constantRateResult {
+	interval := time.Duration(float64(time.Second) / opts.QPS)
+
+	s := schedule{
+		due: func(i int64) (time.Duration, bool) {
+			offset := time.Duration(i) * interval
+			if opts.Count > 0 {
+				return offset, i < opts.Count
+			}
+
+			return offset, offset < opts.Duration
+		},
+		window: func(scheduled int64) time.Duration {
+			return time.Duration(scheduled) * interval
+		},
+		maxOutstanding: opts.MaxOutstanding,
+	}
+
+	result := mi.runSchedule(ctx, s, func(ctx context.Context, i int64) (*Response, time.Duration, error) {
+		query := batch[i%int64(len(batch))]
+		return mi.resolveQuery(ctx, query.Name, query.Type, nameserver, opts.resolveOptions)
+	})
+
+	result.TargetQPS = opts.QPS
+
+	return result
+}
+
+// schedule describes when the queries of an open-loop run are due.
+type schedule struct {
+	// due returns the offset from the start of the run the query of the index is
+	// due at, and whether it is part of the run at all.
+	due func(i int64) (time.Duration, bool)
+
+	// window returns the duration the number of queries are scheduled over, which
+	// the achieved rate is measured against.
+	window func(scheduled int64) time.Duration
+
+	// maxOutstanding holds the maximum number of queries outstanding at once,
+	// beyond which the queries due are skipped rather than sent.
+	maxOutstanding int
+}
+
+// runSchedule sends each query of the schedule with send at the time it is due,
+// regardless of how fast the previous ones were answered, and waits for the
+// outstanding ones to be answered.
+//
+// Queries are due at fixed offsets from the start of the run, so that a query
+// sent late does not delay the following ones, which are sent as soon as possible
+// instead, until the run caught up with the schedule.
+func (mi *ModuleInstance) runSchedule(
+	ctx context.Context,
+	s schedule,
+	send func(ctx context.Context, i int64) (*Response, time.Duration, error),
+) *constantRateResult {
+	stats := &constantRateStats{rcodes: make(map[string]int64)}
+	result := &constantRateResult{}
+
+	outstanding := make(chan struct{}, s.maxOutstanding)
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	<-timer.C
+
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	var maxLag time.Duration
+
+	for i := int64(0); ctx.Err() == nil; i++ {
+		offset, ok := s.due(i)
+		if !ok {
+			break
+		}
+
+		due := start.Add(offset)
+		if wait := time.Until(due); wait > 0 {
+			timer.Reset(wait)
+
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				continue
+			}
+		}
+
+		maxLag = max(maxLag, time.Since(due))
+
+		select {
+		case outstanding <- struct{}{}:
+		default:
+			result.Skipped++
+			continue
+		}
+
+		result.Sent++
+
+		wg.Add(1)
+
+		go func(i int64) {
+			defer wg.Done()
+			defer func() { <-outstanding }()
+
+			response, duration, err := send(ctx, i)
+			stats.record(response, duration, err)
+		}(i)
+	}
+
+	// The achieved rate is measured over the time the queries were due in, or
+	// sent in if they were sent late
+	if scheduled := result.Sent + result.Skipped; scheduled > 0 {
+		window := max(time.Since(start), s.window(scheduled))
+		result.AchievedQPS = float64(result.Sent) / window.Seconds()
+	}
+
+	wg.Wait()
+
+	result.Succeeded = stats.succeeded
+	result.Failed = stats.failed
+	result.Timeouts = stats.timeouts
+	result.Rcodes = stats.rcodes
+	result.Duration = float64(time.Since(start)) / float64(time.Millisecond)
+	result.MaxLag = float64(maxLag) / float64(time.Millisecond)
+	result.Latency = stats.latency.summary()
+
+	return result
+}
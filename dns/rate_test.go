@@ -0,0 +1,145 @@
+package dns
+
+import (
+	"testing"
+
+	"github.com/grafana/sobek"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+)
+
+func TestConstantRate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Queries should be sent at the target rate", func(t *testing.T) {
+		t.Parallel()
+
+		runtime, err := newConfiguredRuntime(t)
+		require.NoError(t, err)
+
+		_, err = runtime.VU.Runtime().RunString(`
+			const server = dns.startServer(["k6.test. 60 IN A 203.0.113.1"]);
+		`)
+		require.NoError(t, err)
+
+		runtime.MoveToVUContext(&lib.State{
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+			Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+			Samples:        make(chan metrics.SampleContainer, 1024),
+		})
+
+		_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+			const queries = [
+				{ name: "k6.test", type: "A" },
+				{ name: "missing.k6.test", type: "A" },
+			];
+
+			const start = Date.now();
+			const result = await dns.constantRate(queries, server.address, { qps: 200, count: 40 });
+			const elapsed = Date.now() - start;
+
+			if (result.sent !== 40 || result.succeeded !== 20 || result.failed !== 20 || result.skipped !== 0) {
+				throw "expected 40 queries to be sent in turn; got " + JSON.stringify(result);
+			}
+
+			if (result.rcodes.NOERROR !== 20 || result.rcodes.NXDOMAIN !== 20) {
+				throw "expected queries to be answered; got " + JSON.stringify(result.rcodes);
+			}
+
+			// The last query is due after 39 intervals of 5ms
+			if (elapsed < 190) {
+				throw "expected queries to be paced; took " + elapsed + "ms";
+			}
+
+			if (result.targetQps !== 200 || result.achievedQps <= 0 || result.achievedQps > 200) {
+				throw "expected the achieved rate to be at most the target rate; got " + result.achievedQps;
+			}
+
+			if (server.queries() !== 40) {
+				throw "expected the server to receive 40 queries; got " + server.queries();
+			}
+		`))
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("Queries due with too many queries outstanding should be skipped", func(t *testing.T) {
+		t.Parallel()
+
+		runtime, err := newConfiguredRuntime(t)
+		require.NoError(t, err)
+
+		_, err = runtime.VU.Runtime().RunString(`
+			const server = dns.startServer(["k6.test. 60 IN A 203.0.113.1"], { delay: "200ms" });
+		`)
+		require.NoError(t, err)
+
+		runtime.MoveToVUContext(&lib.State{
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+			Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+			Samples:        make(chan metrics.SampleContainer, 1024),
+		})
+
+		_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+			const result = await dns.constantRate([{ name: "k6.test", type: "A" }], server.address, {
+				qps: 100,
+				count: 10,
+				maxOutstanding: 2,
+			});
+
+			if (result.sent !== 2 || result.skipped !== 8 || result.succeeded !== 2) {
+				throw "expected 2 queries to be sent, and the others to be skipped; got " + JSON.stringify(result);
+			}
+		`))
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("Sending queries in the init context should fail", func(t *testing.T) {
+		t.Parallel()
+
+		runtime, err := newConfiguredRuntime(t)
+		require.NoError(t, err)
+
+		_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+			await dns.constantRate([{ name: "k6.test", type: "A" }], "127.0.0.1:53", { qps: 10 });
+		`))
+
+		assert.Error(t, err)
+	})
+}
+
+func Test_parseConstantRateOptions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		options string
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{name: "rate and duration", options: `({qps: 500, duration: "30s", timeout: "1s"})`, wantErr: assert.NoError},
+		{name: "rate and count", options: `({qps: 0.5, count: 10, maxOutstanding: 10})`, wantErr: assert.NoError},
+		{name: "undefined options", options: `undefined`, wantErr: assert.Error},
+		{name: "missing rate", options: `({duration: "30s"})`, wantErr: assert.Error},
+		{name: "zero rate", options: `({qps: 0})`, wantErr: assert.Error},
+		{name: "zero count", options: `({qps: 10, count: 0})`, wantErr: assert.Error},
+		{name: "zero max outstanding", options: `({qps: 10, maxOutstanding: 0})`, wantErr: assert.Error},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rt := sobek.New()
+			options, err := rt.RunString(tt.options)
+			require.NoError(t, err)
+
+			_, err = parseConstantRateOptions(rt, options)
+			tt.wantErr(t, err)
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package dns
+
+import (
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// Record represents a DNS resource record.
+type Record struct {
+	// Name holds the owner name of the record, without its trailing dot.
+	Name string `js:"name"`
+
+	// Type holds the type of the record, e.g. "A" or "MX".
+	Type string `js:"type"`
+
+	// TTL holds the time to live of the record, in seconds.
+	TTL uint32 `js:"ttl"`
+
+	// Data holds the record's data, in presentation format. A and AAAA records' data
+	// holds the IP address, the character strings of TXT records are concatenated,
+	// and the names held by CNAME, NS and PTR records are stripped of their
+	// trailing dot.
+	Data string `js:"data"`
+}
+
+// newRecord creates a Record out of a resource record of the dns package.
+func newRecord(rr dns.RR) Record {
+	header := rr.Header()
+
+	return Record{
+		Name: strings.TrimSuffix(header.Name, "."),
+		Type: dns.TypeToString[header.Rrtype],
+		TTL:  header.Ttl,
+		Data: recordData(rr),
+	}
+}
+
+// recordData returns the data of the resource record, in presentation format.
+func recordData(rr dns.RR) string {
+	switch t := rr.(type) {
+	case *dns.A:
+		return t.A.String()
+	case *dns.AAAA:
+		return t.AAAA.String()
+	case *dns.TXT:
+		return strings.Join(t.Txt, "")
+	case *dns.CNAME:
+		return strings.TrimSuffix(t.Target, ".")
+	case *dns.NS:
+		return strings.TrimSuffix(t.Ns, ".")
+	case *dns.PTR:
+		return strings.TrimSuffix(t.Ptr, ".")
+	default:
+		return strings.TrimPrefix(rr.String(), rr.Header().String())
+	}
+}
@@ -0,0 +1,58 @@
+package dns
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_newRecord(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		rr   string
+		want Record
+	}{
+		{
+			name: "A record",
+			rr:   "k6.test. 60 IN A " + primaryTestIPv4,
+			want: Record{Name: testDomain, Type: "A", TTL: 60, Data: primaryTestIPv4},
+		},
+		{
+			name: "AAAA record",
+			rr:   "k6.test. 60 IN AAAA " + primaryTestIPv6,
+			want: Record{Name: testDomain, Type: "AAAA", TTL: 60, Data: primaryTestIPv6},
+		},
+		{
+			name: "CNAME record",
+			rr:   "www.k6.test. 300 IN CNAME k6.test.",
+			want: Record{Name: "www.k6.test", Type: "CNAME", TTL: 300, Data: testDomain},
+		},
+		{
+			name: "MX record",
+			rr:   "k6.test. 3600 IN MX 10 mail.k6.test.",
+			want: Record{Name: testDomain, Type: "MX", TTL: 3600, Data: "10 mail.k6.test."},
+		},
+		{
+			name: "TXT record with multiple character strings",
+			rr:   `k6.test. 60 IN TXT "v=spf1 " "-all"`,
+			want: Record{Name: testDomain, Type: "TXT", TTL: 60, Data: "v=spf1 -all"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rr, err := dns.NewRR(tt.rr)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.want, newRecord(rr))
+		})
+	}
+}
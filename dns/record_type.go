@@ -10,12 +10,19 @@ import "github.com/miekg/dns"
 // The currently supported values are:
 // - A
 // - AAAA
+// - CAA
+// - CNAME
+// - MX
+// - NS
+// - PTR
+// - SOA
+// - SRV
+// - TXT
 //
 // The supported values are the ones that are most likely to be
-// used by the users of this extension and package, as they are
-// those returning IP addresses. Other record types could be
-// supported later on, as long as we extend our resolver's logic
-// to support them.
+// used by the users of this extension and package. Other record
+// types could be supported later on, as long as we extend our
+// resolver's logic to support them.
 //
 // We use a custom type to restrict the set of values, and to
 // avoid leaking the underlying dns package's types to the
@@ -30,6 +37,14 @@ type RecordType uint16
 // Note that the RecordType enum values are explicitly typed to allow enumer
 // to detect them.
 const (
-	RecordTypeA    RecordType = RecordType(dns.TypeA)
-	RecordTypeAAAA RecordType = RecordType(dns.TypeAAAA)
+	RecordTypeA     RecordType = RecordType(dns.TypeA)
+	RecordTypeNS    RecordType = RecordType(dns.TypeNS)
+	RecordTypeCNAME RecordType = RecordType(dns.TypeCNAME)
+	RecordTypeSOA   RecordType = RecordType(dns.TypeSOA)
+	RecordTypePTR   RecordType = RecordType(dns.TypePTR)
+	RecordTypeMX    RecordType = RecordType(dns.TypeMX)
+	RecordTypeTXT   RecordType = RecordType(dns.TypeTXT)
+	RecordTypeAAAA  RecordType = RecordType(dns.TypeAAAA)
+	RecordTypeSRV   RecordType = RecordType(dns.TypeSRV)
+	RecordTypeCAA   RecordType = RecordType(dns.TypeCAA)
 )
@@ -7,31 +7,62 @@ import (
 )
 
 const (
-	_RecordTypeName_0 = "A"
-	_RecordTypeName_1 = "AAAA"
+	_RecordTypeName_0 = "ANS"
+	_RecordTypeName_1 = "CNAMESOA"
+	_RecordTypeName_2 = "PTR"
+	_RecordTypeName_3 = "MXTXT"
+	_RecordTypeName_4 = "AAAA"
+	_RecordTypeName_5 = "SRV"
+	_RecordTypeName_6 = "CAA"
 )
 
 var (
-	_RecordTypeIndex_0 = [...]uint8{0, 1}
-	_RecordTypeIndex_1 = [...]uint8{0, 4}
+	_RecordTypeIndex_0 = [...]uint8{0, 1, 3}
+	_RecordTypeIndex_1 = [...]uint8{0, 5, 8}
+	_RecordTypeIndex_2 = [...]uint8{0, 3}
+	_RecordTypeIndex_3 = [...]uint8{0, 2, 5}
+	_RecordTypeIndex_4 = [...]uint8{0, 4}
+	_RecordTypeIndex_5 = [...]uint8{0, 3}
+	_RecordTypeIndex_6 = [...]uint8{0, 3}
 )
 
 func (i RecordType) String() string {
 	switch {
-	case i == 1:
-		return _RecordTypeName_0
+	case 1 <= i && i <= 2:
+		i -= 1
+		return _RecordTypeName_0[_RecordTypeIndex_0[i]:_RecordTypeIndex_0[i+1]]
+	case 5 <= i && i <= 6:
+		i -= 5
+		return _RecordTypeName_1[_RecordTypeIndex_1[i]:_RecordTypeIndex_1[i+1]]
+	case i == 12:
+		return _RecordTypeName_2
+	case 15 <= i && i <= 16:
+		i -= 15
+		return _RecordTypeName_3[_RecordTypeIndex_3[i]:_RecordTypeIndex_3[i+1]]
 	case i == 28:
-		return _RecordTypeName_1
+		return _RecordTypeName_4
+	case i == 33:
+		return _RecordTypeName_5
+	case i == 257:
+		return _RecordTypeName_6
 	default:
 		return fmt.Sprintf("RecordType(%d)", i)
 	}
 }
 
-var _RecordTypeValues = []RecordType{1, 28}
+var _RecordTypeValues = []RecordType{1, 2, 5, 6, 12, 15, 16, 28, 33, 257}
 
 var _RecordTypeNameToValueMap = map[string]RecordType{
 	_RecordTypeName_0[0:1]: 1,
-	_RecordTypeName_1[0:4]: 28,
+	_RecordTypeName_0[1:3]: 2,
+	_RecordTypeName_1[0:5]: 5,
+	_RecordTypeName_1[5:8]: 6,
+	_RecordTypeName_2[0:3]: 12,
+	_RecordTypeName_3[0:2]: 15,
+	_RecordTypeName_3[2:5]: 16,
+	_RecordTypeName_4[0:4]: 28,
+	_RecordTypeName_5[0:3]: 33,
+	_RecordTypeName_6[0:3]: 257,
 }
 
 // RecordTypeString retrieves an enum value from the enum constants string name.
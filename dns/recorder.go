@@ -0,0 +1,548 @@
+package dns
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/sobek"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/lib"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Formats traffic can be recorded in.
+const (
+	recordFormatPcap   = "pcap"
+	recordFormatDnstap = "dnstap"
+)
+
+// exchangeRecord describes a query sent to a nameserver, and the response to it,
+// if any, as recorded by a trafficRecorder.
+type exchangeRecord struct {
+	protocol string
+	local    netip.AddrPort
+	remote   netip.AddrPort
+	query    []byte
+	response []byte
+	sent     time.Time
+	received time.Time
+}
+
+// newExchangeRecord creates the exchangeRecord of the query sent to the
+// nameserver, as described by the Response.
+func newExchangeRecord(nameserver Nameserver, result *Response) exchangeRecord {
+	remoteIP, _ := netip.AddrFromSlice(nameserver.IP)
+	remoteIP = remoteIP.Unmap()
+
+	var local netip.AddrPort
+	switch addr := result.localAddr.(type) {
+	case *net.UDPAddr:
+		local = addr.AddrPort()
+	case *net.TCPAddr:
+		local = addr.AddrPort()
+	}
+
+	// Wildcard addresses, or addresses of another family, do not tell which address
+	// the query was sent from
+	localIP := local.Addr().Unmap()
+	if !localIP.IsValid() || localIP.Is4() != remoteIP.Is4() {
+		localIP = netip.IPv4Unspecified()
+		if remoteIP.Is6() {
+			localIP = netip.IPv6Unspecified()
+		}
+	}
+
+	return exchangeRecord{
+		protocol: result.Protocol,
+		local:    netip.AddrPortFrom(localIP, local.Port()),
+		remote:   netip.AddrPortFrom(remoteIP, nameserver.Port),
+		query:    result.RawRequest,
+		response: result.RawResponse,
+		sent:     result.sentAt,
+		received: result.receivedAt,
+	}
+}
+
+// trafficRecorder writes the queries sent by the VUs, and the responses to them, to
+// a file, each exchange being written at once as it completes. It is safe for
+// concurrent use.
+type trafficRecorder struct {
+	format string
+
+	mu     sync.Mutex
+	writer io.Writer
+	encode func(record exchangeRecord) []byte
+
+	// closer closes the file the recorder writes to, if set.
+	closer io.Closer
+}
+
+// newTrafficRecorder creates a trafficRecorder writing to the writer in the format,
+// and writes the header of the format.
+func newTrafficRecorder(writer io.Writer, format string) (*trafficRecorder, error) {
+	recorder := &trafficRecorder{format: format, writer: writer}
+
+	var header []byte
+	switch format {
+	case recordFormatPcap:
+		header, recorder.encode = pcapFileHeader(), encodePcapRecord
+	case recordFormatDnstap:
+		header, recorder.encode = frameStreamsStart(), encodeDnstapRecord
+	default:
+		return nil, fmt.Errorf("format must be one of %q or %q; got %q instead", recordFormatPcap, recordFormatDnstap, format)
+	}
+
+	if _, err := writer.Write(header); err != nil {
+		return nil, err
+	}
+
+	return recorder, nil
+}
+
+// record writes the exchange. Failing to write it is not reported to the
+// script, as recording is only a side effect of sending queries.
+func (r *trafficRecorder) record(record exchangeRecord) {
+	data := r.encode(record)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, _ = r.writer.Write(data)
+}
+
+// trafficRecorders holds the trafficRecorders opened by all the VUs, by path, so
+// that each file is only created once, and shared by all the VUs recording to it.
+type trafficRecorders struct {
+	mu        sync.Mutex
+	recorders map[string]*trafficRecorder
+}
+
+// newTrafficRecorders creates an empty trafficRecorders.
+func newTrafficRecorders() *trafficRecorders {
+	return &trafficRecorders{recorders: make(map[string]*trafficRecorder)}
+}
+
+// open returns the trafficRecorder of the file at the path, creating the file, or
+// truncating it, if it was not already opened.
+func (r *trafficRecorders) open(path, format string) (*trafficRecorder, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if recorder, ok := r.recorders[path]; ok {
+		if recorder.format != format {
+			return nil, fmt.Errorf("%s is already recorded to in the %s format", path, recorder.format)
+		}
+
+		return recorder, nil
+	}
+
+	file, err := os.Create(path) //nolint:gosec
+	if err != nil {
+		return nil, err
+	}
+
+	recorder, err := newTrafficRecorder(file, format)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	recorder.closer = file
+	r.recorders[path] = recorder
+
+	return recorder, nil
+}
+
+// close closes the files of the recorders, once the trailer of their format is
+// written. The exchanges recorded afterwards are not written anymore.
+func (r *trafficRecorders) close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var errs []error
+	for path, recorder := range r.recorders {
+		if err := recorder.close(); err != nil {
+			errs = append(errs, fmt.Errorf("closing %s failed: %w", path, err))
+		}
+
+		delete(r.recorders, path)
+	}
+
+	return errors.Join(errs...)
+}
+
+// close writes the trailer of the format, if any, and closes the file.
+func (r *trafficRecorder) close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var err error
+	if r.format == recordFormatDnstap {
+		_, err = r.writer.Write(frameStreamsStop())
+	}
+
+	r.writer = io.Discard
+	if r.closer != nil {
+		err = errors.Join(err, r.closer.Close())
+	}
+
+	return err
+}
+
+// recordTrafficOptions holds the options that can be passed to the recordTraffic
+// function.
+type recordTrafficOptions struct {
+	// Format holds the format of the file, either "pcap" or "dnstap".
+	Format string
+
+	// Scenarios holds the names of the scenarios whose traffic is recorded, or
+	// none if the traffic of all the scenarios is.
+	Scenarios []string
+}
+
+// parseRecordTrafficOptions parses the options object passed to the recordTraffic
+// function, the format defaulting to the one told by the extension of the path.
+func parseRecordTrafficOptions(rt *sobek.Runtime, path string, value sobek.Value) (recordTrafficOptions, error) {
+	opts := recordTrafficOptions{Format: recordFormatPcap}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".dnstap", ".fstrm":
+		opts.Format = recordFormatDnstap
+	}
+
+	if common.IsNullish(value) {
+		return opts, nil
+	}
+
+	params := value.ToObject(rt)
+
+	if v := params.Get("format"); !common.IsNullish(v) {
+		opts.Format = strings.ToLower(v.String())
+		if opts.Format != recordFormatPcap && opts.Format != recordFormatDnstap {
+			return opts, fmt.Errorf("format option must be one of %q or %q; got %q instead", recordFormatPcap, recordFormatDnstap, v)
+		}
+	}
+
+	if v := params.Get("scenarios"); !common.IsNullish(v) {
+		if err := rt.ExportTo(v, &opts.Scenarios); err != nil {
+			return opts, fmt.Errorf("scenarios option must be an array of scenario names; reason: %w", err)
+		}
+	}
+
+	return opts, nil
+}
+
+// RecordTraffic records the queries the VU sends, and the responses to them, to
+// the file at the path, relative to the working directory of k6, in the pcap or
+// dnstap format. It can only be used in the init context, and each file is created
+// once, the traffic of all the VUs recording to it being written to it.
+//
+// The traffic of all the scenarios is recorded, unless the scenarios option
+// lists the ones whose traffic is.
+func (mi *ModuleInstance) RecordTraffic(path string, options sobek.Value) error {
+	if mi.vu.InitEnv() == nil {
+		return errors.New("recordTraffic can only be used in the init context")
+	}
+
+	if path == "" {
+		return errors.New("path argument must be provided")
+	}
+
+	opts, err := parseRecordTrafficOptions(mi.vu.Runtime(), path, options)
+	if err != nil {
+		return fmt.Errorf("invalid recordTraffic options: %w", err)
+	}
+
+	recorder, err := mi.recorders.open(path, opts.Format)
+	if err != nil {
+		return fmt.Errorf("recording traffic to %s failed; reason: %w", path, err)
+	}
+
+	scenarios := make(map[string]bool, len(opts.Scenarios))
+	for _, name := range opts.Scenarios {
+		scenarios[name] = true
+	}
+
+	mi.dnsClient.recorder = func(nameserver Nameserver, result *Response) {
+		if len(scenarios) > 0 {
+			scenario := lib.GetScenarioState(mi.vu.Context())
+			if scenario == nil || !scenarios[scenario.Name] {
+				return
+			}
+		}
+
+		recorder.record(newExchangeRecord(nameserver, result))
+	}
+
+	return nil
+}
+
+// pcapFileHeader returns the global header of the pcap files traffic is recorded
+// to, whose packets are raw IP packets, timestamped to the nanosecond.
+func pcapFileHeader() []byte {
+	header := make([]byte, pcapHeaderSize)
+	binary.LittleEndian.PutUint32(header[0:4], pcapMagicNanoseconds)
+	binary.LittleEndian.PutUint16(header[4:6], 2)
+	binary.LittleEndian.PutUint16(header[6:8], 4)
+	binary.LittleEndian.PutUint32(header[16:20], pcapSnapLength)
+	binary.LittleEndian.PutUint32(header[20:24], linkTypeRaw)
+
+	return header
+}
+
+// pcapSnapLength is the maximum size of the packets of the pcap files traffic
+// is recorded to, as commonly used by tcpdump.
+const pcapSnapLength = 262144
+
+// encodePcapRecord encodes the query, and the response to it, if any, as pcap
+// packets.
+//
+// Messages are encoded as UDP datagrams between the addresses and ports they were
+// exchanged between, regardless of the protocol they were sent over, so that
+// Wireshark decodes them the same. Messages exchanged over TCP or DoT can be told
+// apart by the nameserver's port.
+func encodePcapRecord(record exchangeRecord) []byte {
+	data := appendPcapPacket(nil, record.sent, record.local, record.remote, record.query)
+
+	if record.response != nil {
+		data = appendPcapPacket(data, record.received, record.remote, record.local, record.response)
+	}
+
+	return data
+}
+
+// appendPcapPacket appends the pcap packet of the UDP datagram holding the
+// payload, sent from the source to the destination at the time, to the data.
+// Payloads too large for a UDP datagram are truncated.
+func appendPcapPacket(data []byte, at time.Time, src, dst netip.AddrPort, payload []byte) []byte {
+	payload = payload[:min(len(payload), 0xffff-ipv4HeaderSize-udpHeaderSize)]
+
+	udp := make([]byte, udpHeaderSize, udpHeaderSize+len(payload))
+	binary.BigEndian.PutUint16(udp[0:2], src.Port())
+	binary.BigEndian.PutUint16(udp[2:4], dst.Port())
+	binary.BigEndian.PutUint16(udp[4:6], uint16(udpHeaderSize+len(payload))) //nolint:gosec
+	udp = append(udp, payload...)
+
+	// The checksum of UDP datagrams covers a pseudo header holding the addresses,
+	// protocol and length of the datagram
+	pseudo := append(src.Addr().AsSlice(), dst.Addr().AsSlice()...)
+	pseudo = append(pseudo, 0, 17)
+	pseudo = binary.BigEndian.AppendUint16(pseudo, uint16(len(udp))) //nolint:gosec
+
+	sum := checksum(udp, sum16(pseudo))
+	if sum == 0 {
+		sum = 0xffff
+	}
+
+	binary.BigEndian.PutUint16(udp[6:8], sum)
+
+	var ip []byte
+	if dst.Addr().Is4() {
+		ip = make([]byte, ipv4HeaderSize, ipv4HeaderSize+len(udp))
+		ip[0] = 4<<4 | ipv4HeaderSize/4
+		binary.BigEndian.PutUint16(ip[2:4], uint16(ipv4HeaderSize+len(udp))) //nolint:gosec
+		ip[8] = 64
+		ip[9] = 17
+		copy(ip[12:16], src.Addr().AsSlice())
+		copy(ip[16:20], dst.Addr().AsSlice())
+		binary.BigEndian.PutUint16(ip[10:12], checksum(ip, 0))
+	} else {
+		ip = make([]byte, ipv6HeaderSize, ipv6HeaderSize+len(udp))
+		ip[0] = 6 << 4
+		binary.BigEndian.PutUint16(ip[4:6], uint16(len(udp))) //nolint:gosec
+		ip[6] = 17
+		ip[7] = 64
+		copy(ip[8:24], src.Addr().AsSlice())
+		copy(ip[24:40], dst.Addr().AsSlice())
+	}
+
+	ip = append(ip, udp...)
+
+	record := make([]byte, pcapRecordHeaderSize)
+	binary.LittleEndian.PutUint32(record[0:4], uint32(at.Unix()))       //nolint:gosec
+	binary.LittleEndian.PutUint32(record[4:8], uint32(at.Nanosecond())) //nolint:gosec
+	binary.LittleEndian.PutUint32(record[8:12], uint32(len(ip)))        //nolint:gosec
+	binary.LittleEndian.PutUint32(record[12:16], uint32(len(ip)))       //nolint:gosec
+
+	return append(append(data, record...), ip...)
+}
+
+// ipv4HeaderSize is the size of the header of IPv4 packets without options.
+const ipv4HeaderSize = 20
+
+// sum16 returns the one's complement sum of the data, as 16-bit words.
+func sum16(data []byte) uint32 {
+	var sum uint32
+	for i := 0; i+1 < len(data); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(data[i:]))
+	}
+
+	if len(data)%2 == 1 {
+		sum += uint32(data[len(data)-1]) << 8
+	}
+
+	return sum
+}
+
+// checksum returns the internet checksum of the data, starting from the initial
+// sum.
+func checksum(data []byte, initial uint32) uint16 {
+	sum := initial + sum16(data)
+	for sum > 0xffff {
+		sum = sum>>16 + sum&0xffff
+	}
+
+	return ^uint16(sum)
+}
+
+// Frame Streams control frame types and fields, as defined by the Frame Streams
+// protocol, along with the content type of dnstap.
+const (
+	frameStreamsControlStart     = 0x02
+	frameStreamsControlStop      = 0x03
+	frameStreamsFieldContentType = 0x01
+	dnstapContentType            = "protobuf:dnstap.Dnstap"
+	dnstapIdentity               = "xk6-dns"
+)
+
+// frameStreamsStart returns the start control frame of the Frame Streams files
+// traffic is recorded to, telling their content type is dnstap.
+func frameStreamsStart() []byte {
+	var control []byte
+	control = binary.BigEndian.AppendUint32(control, frameStreamsControlStart)
+	control = binary.BigEndian.AppendUint32(control, frameStreamsFieldContentType)
+	control = binary.BigEndian.AppendUint32(control, uint32(len(dnstapContentType)))
+	control = append(control, dnstapContentType...)
+
+	// Control frames are escaped with a zero length, as data frames can not be empty
+	frame := binary.BigEndian.AppendUint32(nil, 0)
+	frame = binary.BigEndian.AppendUint32(frame, uint32(len(control))) //nolint:gosec
+
+	return append(frame, control...)
+}
+
+// frameStreamsStop returns the stop control frame ending the Frame Streams files
+// traffic is recorded to.
+func frameStreamsStop() []byte {
+	frame := binary.BigEndian.AppendUint32(nil, 0)
+	frame = binary.BigEndian.AppendUint32(frame, 4)
+
+	return binary.BigEndian.AppendUint32(frame, frameStreamsControlStop)
+}
+
+// Fields and values of the dnstap protobuf messages, as defined by dnstap.proto.
+const (
+	dnstapFieldIdentity = 1
+	dnstapFieldMessage  = 14
+	dnstapFieldType     = 15
+	dnstapTypeMessage   = 1
+
+	messageFieldType             = 1
+	messageFieldSocketFamily     = 2
+	messageFieldSocketProtocol   = 3
+	messageFieldQueryAddress     = 4
+	messageFieldResponseAddress  = 5
+	messageFieldQueryPort        = 6
+	messageFieldResponsePort     = 7
+	messageFieldQueryTimeSec     = 8
+	messageFieldQueryTimeNsec    = 9
+	messageFieldQueryMessage     = 10
+	messageFieldResponseTimeSec  = 12
+	messageFieldResponseTimeNsec = 13
+	messageFieldResponseMessage  = 14
+
+	messageTypeToolQuery    = 11
+	messageTypeToolResponse = 12
+
+	socketFamilyINET  = 1
+	socketFamilyINET6 = 2
+
+	socketProtocolUDP = 1
+	socketProtocolTCP = 2
+	socketProtocolDOT = 3
+	socketProtocolDOH = 4
+)
+
+// encodeDnstapRecord encodes the query, and the response to it, if any, as
+// Frame Streams data frames of TOOL_QUERY and TOOL_RESPONSE dnstap messages.
+func encodeDnstapRecord(record exchangeRecord) []byte {
+	data := appendDnstapFrame(nil, record, messageTypeToolQuery)
+
+	if record.response != nil {
+		data = appendDnstapFrame(data, record, messageTypeToolResponse)
+	}
+
+	return data
+}
+
+// appendDnstapFrame appends the data frame of the dnstap message of the type,
+// describing the record, to the data.
+func appendDnstapFrame(data []byte, record exchangeRecord, messageType uint64) []byte {
+	family := uint64(socketFamilyINET)
+	if record.remote.Addr().Is6() {
+		family = socketFamilyINET6
+	}
+
+	protocol := uint64(socketProtocolUDP)
+	switch record.protocol {
+	case protocolTCP:
+		protocol = socketProtocolTCP
+	case protocolDoT:
+		protocol = socketProtocolDOT
+	case protocolDoH:
+		protocol = socketProtocolDOH
+	}
+
+	var message []byte
+	message = protowire.AppendTag(message, messageFieldType, protowire.VarintType)
+	message = protowire.AppendVarint(message, messageType)
+	message = protowire.AppendTag(message, messageFieldSocketFamily, protowire.VarintType)
+	message = protowire.AppendVarint(message, family)
+	message = protowire.AppendTag(message, messageFieldSocketProtocol, protowire.VarintType)
+	message = protowire.AppendVarint(message, protocol)
+	message = protowire.AppendTag(message, messageFieldQueryAddress, protowire.BytesType)
+	message = protowire.AppendBytes(message, record.local.Addr().AsSlice())
+	message = protowire.AppendTag(message, messageFieldResponseAddress, protowire.BytesType)
+	message = protowire.AppendBytes(message, record.remote.Addr().AsSlice())
+	message = protowire.AppendTag(message, messageFieldQueryPort, protowire.VarintType)
+	message = protowire.AppendVarint(message, uint64(record.local.Port()))
+	message = protowire.AppendTag(message, messageFieldResponsePort, protowire.VarintType)
+	message = protowire.AppendVarint(message, uint64(record.remote.Port()))
+	message = protowire.AppendTag(message, messageFieldQueryTimeSec, protowire.VarintType)
+	message = protowire.AppendVarint(message, uint64(record.sent.Unix())) //nolint:gosec
+	message = protowire.AppendTag(message, messageFieldQueryTimeNsec, protowire.Fixed32Type)
+	message = protowire.AppendFixed32(message, uint32(record.sent.Nanosecond())) //nolint:gosec
+
+	if messageType == messageTypeToolQuery {
+		message = protowire.AppendTag(message, messageFieldQueryMessage, protowire.BytesType)
+		message = protowire.AppendBytes(message, record.query)
+	} else {
+		message = protowire.AppendTag(message, messageFieldResponseTimeSec, protowire.VarintType)
+		message = protowire.AppendVarint(message, uint64(record.received.Unix())) //nolint:gosec
+		message = protowire.AppendTag(message, messageFieldResponseTimeNsec, protowire.Fixed32Type)
+		message = protowire.AppendFixed32(message, uint32(record.received.Nanosecond())) //nolint:gosec
+		message = protowire.AppendTag(message, messageFieldResponseMessage, protowire.BytesType)
+		message = protowire.AppendBytes(message, record.response)
+	}
+
+	var dnstap []byte
+	dnstap = protowire.AppendTag(dnstap, dnstapFieldIdentity, protowire.BytesType)
+	dnstap = protowire.AppendString(dnstap, dnstapIdentity)
+	dnstap = protowire.AppendTag(dnstap, dnstapFieldType, protowire.VarintType)
+	dnstap = protowire.AppendVarint(dnstap, dnstapTypeMessage)
+	dnstap = protowire.AppendTag(dnstap, dnstapFieldMessage, protowire.BytesType)
+	dnstap = protowire.AppendBytes(dnstap, message)
+
+	data = binary.BigEndian.AppendUint32(data, uint32(len(dnstap))) //nolint:gosec
+
+	return append(data, dnstap...)
+}
@@ -0,0 +1,275 @@
+package dns
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestRecordTraffic(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Queries of the scenarios not recorded should not be written to the file", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+
+		runtime, err := newConfiguredRuntime(t)
+		require.NoError(t, err)
+
+		_, err = runtime.VU.Runtime().RunString(fmt.Sprintf(`
+			const server = dns.startServer(["k6.test. 60 IN A 203.0.113.1"]);
+
+			dns.recordTraffic(%q);
+			dns.recordTraffic(%q, { scenarios: ["other"] });
+		`, filepath.Join(dir, "all.pcap"), filepath.Join(dir, "other.dnstap")))
+		require.NoError(t, err)
+
+		runtime.VU.CtxField = lib.WithScenarioState(runtime.VU.CtxField, &lib.ScenarioState{Name: "default"})
+		runtime.MoveToVUContext(&lib.State{
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+			Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+			Samples:        make(chan metrics.SampleContainer, 1024),
+		})
+
+		_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+			await dns.resolve("k6.test", "A", server.address);
+		`))
+		require.NoError(t, err)
+
+		// The last call replaced the recording of the VU, which excludes the scenario
+		data, err := os.ReadFile(filepath.Join(dir, "all.pcap")) //nolint:forbidigo
+		require.NoError(t, err)
+		assert.Equal(t, pcapFileHeader(), data)
+
+		data, err = os.ReadFile(filepath.Join(dir, "other.dnstap")) //nolint:forbidigo
+		require.NoError(t, err)
+		assert.Equal(t, frameStreamsStart(), data)
+	})
+
+	t.Run("Queries and responses should be written to the file", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "dns.pcap")
+
+		runtime, err := newConfiguredRuntime(t)
+		require.NoError(t, err)
+
+		_, err = runtime.VU.Runtime().RunString(fmt.Sprintf(`
+			const server = dns.startServer(["k6.test. 60 IN A 203.0.113.1"]);
+
+			dns.recordTraffic(%q, { scenarios: ["default"] });
+		`, path))
+		require.NoError(t, err)
+
+		runtime.VU.CtxField = lib.WithScenarioState(runtime.VU.CtxField, &lib.ScenarioState{Name: "default"})
+		runtime.MoveToVUContext(&lib.State{
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+			Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+			Samples:        make(chan metrics.SampleContainer, 1024),
+		})
+
+		_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+			await dns.resolve("k6.test", "A", server.address);
+			await dns.resolve("missing.k6.test", "A", server.address, { throw: false });
+		`))
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(path) //nolint:forbidigo
+		require.NoError(t, err)
+
+		// Recorded queries are sent to the port of the in-process server
+		port := binary.BigEndian.Uint16(data[pcapHeaderSize+pcapRecordHeaderSize+ipv4HeaderSize+2:])
+
+		capture, err := parsePcap(data, port)
+		require.NoError(t, err)
+		require.Len(t, capture.queries, 2)
+
+		query := new(dns.Msg)
+		require.NoError(t, query.Unpack(capture.queries[1].msg))
+		assert.Equal(t, "missing.k6.test.", query.Question[0].Name)
+	})
+
+	t.Run("Recording traffic outside of the init context should fail", func(t *testing.T) {
+		t.Parallel()
+
+		runtime, err := newConfiguredRuntime(t)
+		require.NoError(t, err)
+
+		runtime.MoveToVUContext(&lib.State{
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+			Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+			Samples:        make(chan metrics.SampleContainer, 1024),
+		})
+
+		_, err = runtime.VU.Runtime().RunString(`dns.recordTraffic("dns.pcap")`)
+
+		assert.ErrorContains(t, err, "can only be used in the init context")
+	})
+
+	t.Run("Recording a file in another format should fail", func(t *testing.T) {
+		t.Parallel()
+
+		runtime, err := newConfiguredRuntime(t)
+		require.NoError(t, err)
+
+		_, err = runtime.VU.Runtime().RunString(fmt.Sprintf(`
+			dns.recordTraffic(%[1]q);
+			dns.recordTraffic(%[1]q, { format: "dnstap" });
+		`, filepath.Join(t.TempDir(), "dns.pcap")))
+
+		assert.ErrorContains(t, err, "already recorded to in the pcap format")
+	})
+}
+
+func Test_encodePcapRecord(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		local  netip.AddrPort
+		remote netip.AddrPort
+	}{
+		{
+			name:   "IPv4",
+			local:  netip.MustParseAddrPort("192.0.2.1:41000"),
+			remote: netip.MustParseAddrPort("192.0.2.53:53"),
+		},
+		{
+			name:   "IPv6",
+			local:  netip.MustParseAddrPort("[2001:db8::1]:41000"),
+			remote: netip.MustParseAddrPort("[2001:db8::53]:53"),
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			query := newTestQuery(t, "k6.test.", dns.TypeA)
+			response := append([]byte{}, query...)
+			response[2] |= 0x80
+
+			sent := time.Unix(1700000000, 500)
+			data := append(pcapFileHeader(), encodePcapRecord(exchangeRecord{
+				protocol: protocolUDP,
+				local:    tt.local,
+				remote:   tt.remote,
+				query:    query,
+				response: response,
+				sent:     sent,
+				received: sent.Add(time.Millisecond),
+			})...)
+
+			capture, err := parsePcap(data, 53)
+			require.NoError(t, err)
+			require.Len(t, capture.queries, 1)
+			assert.Equal(t, query, capture.queries[0].msg)
+
+			// The response is sent back to the port the query was sent from
+			packet := data[pcapHeaderSize+pcapRecordHeaderSize+binary.LittleEndian.Uint32(data[pcapHeaderSize+8:]):]
+			payload, ok := udpPayload(packet[pcapRecordHeaderSize:], linkTypeRaw, 41000)
+			require.True(t, ok)
+			assert.Equal(t, response, payload)
+			assert.Equal(t, uint32(1000500), binary.LittleEndian.Uint32(packet[4:8]))
+
+			// Datagrams hold valid checksums
+			ip := packet[pcapRecordHeaderSize:]
+			udp := ip[len(ip)-udpHeaderSize-len(response):]
+			if tt.remote.Addr().Is4() {
+				assert.Zero(t, checksum(ip[:ipv4HeaderSize], 0))
+			}
+
+			pseudo := append(tt.remote.Addr().AsSlice(), tt.local.Addr().AsSlice()...)
+			pseudo = binary.BigEndian.AppendUint32(append(pseudo, 0, 17), uint32(len(udp)))
+			assert.Zero(t, checksum(udp, sum16(pseudo)))
+		})
+	}
+}
+
+func Test_encodeDnstapRecord(t *testing.T) {
+	t.Parallel()
+
+	query := newTestQuery(t, "k6.test.", dns.TypeA)
+
+	data := append(frameStreamsStart(), encodeDnstapRecord(exchangeRecord{
+		protocol: protocolDoT,
+		local:    netip.MustParseAddrPort("192.0.2.1:41000"),
+		remote:   netip.MustParseAddrPort("192.0.2.53:853"),
+		query:    query,
+		response: query,
+		sent:     time.Unix(1700000000, 0),
+		received: time.Unix(1700000001, 0),
+	})...)
+
+	// The start control frame tells the content type
+	require.Zero(t, binary.BigEndian.Uint32(data[0:4]))
+	control := data[8 : 8+binary.BigEndian.Uint32(data[4:8])]
+	assert.Equal(t, uint32(frameStreamsControlStart), binary.BigEndian.Uint32(control[0:4]))
+	assert.Equal(t, dnstapContentType, string(control[12:]))
+
+	data = data[8+len(control):]
+
+	var messageTypes []uint64
+	for len(data) > 0 {
+		frame := data[4 : 4+binary.BigEndian.Uint32(data[0:4])]
+		data = data[4+len(frame):]
+
+		fields := decodeTestProtobuf(t, frame)
+		assert.Equal(t, dnstapIdentity, string(fields[dnstapFieldIdentity].([]byte)))
+		assert.Equal(t, uint64(dnstapTypeMessage), fields[dnstapFieldType])
+
+		message := decodeTestProtobuf(t, fields[dnstapFieldMessage].([]byte))
+		assert.Equal(t, uint64(socketProtocolDOT), message[messageFieldSocketProtocol])
+		assert.Equal(t, uint64(853), message[messageFieldResponsePort])
+		assert.Equal(t, []byte{192, 0, 2, 1}, message[messageFieldQueryAddress])
+
+		messageTypes = append(messageTypes, message[messageFieldType].(uint64))
+	}
+
+	assert.Equal(t, []uint64{messageTypeToolQuery, messageTypeToolResponse}, messageTypes)
+}
+
+// decodeTestProtobuf decodes the fields of the protobuf message, by number.
+func decodeTestProtobuf(t *testing.T, data []byte) map[protowire.Number]any {
+	t.Helper()
+
+	fields := make(map[protowire.Number]any)
+	for len(data) > 0 {
+		number, wireType, n := protowire.ConsumeTag(data)
+		require.GreaterOrEqual(t, n, 0)
+		data = data[n:]
+
+		switch wireType {
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(data)
+			require.GreaterOrEqual(t, n, 0)
+			fields[number], data = v, data[n:]
+		case protowire.Fixed32Type:
+			v, n := protowire.ConsumeFixed32(data)
+			require.GreaterOrEqual(t, n, 0)
+			fields[number], data = v, data[n:]
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(data)
+			require.GreaterOrEqual(t, n, 0)
+			fields[number], data = v, data[n:]
+		default:
+			require.Failf(t, "unexpected wire type", "%v", wireType)
+		}
+	}
+
+	return fields
+}
@@ -0,0 +1,126 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"go.k6.io/k6/js/modules"
+)
+
+// Querier is the interface that wraps the Query method.
+//
+// Query resolves a domain name using the given nameserver, and returns the
+// resulting Response.
+type Querier interface {
+	Query(ctx context.Context, query, recordType string, nameserver Nameserver, opts QueryOptions) (*Response, error)
+}
+
+// errResolverInitContext is returned when a VUResolver is used in the init context.
+var errResolverInitContext = errors.New("resolving DNS names can not be done in the init context")
+
+// defaultModule returns the RootModule registered as the k6/x/dns module.
+var defaultModule = sync.OnceValue(New) //nolint:gochecknoglobals
+
+// Default returns the RootModule registered as the k6/x/dns module, whose
+// connections, limits and end-of-test summary are shared with the VUResolvers
+// created by NewVUResolver.
+func Default() *RootModule {
+	return defaultModule()
+}
+
+// VUResolver resolves DNS names on behalf of a VU, as the k6/x/dns module does, so
+// that other extensions can embed it rather than reimplementing k6's integration:
+// it emits the module's metrics, accounts for the resolutions in its end-of-test
+// summary, honors k6's TLS options and network restrictions, and sends queries
+// within the limits set with the configure function.
+//
+// It can only be used once the VU is running, as opposed to the init context.
+type VUResolver struct {
+	mi *ModuleInstance
+}
+
+// Ensure our VUResolver implements the Resolver interface
+var _ Resolver = &VUResolver{}
+
+// Ensure our VUResolver implements the Querier interface
+var _ Querier = &VUResolver{}
+
+// Ensure our VUResolver implements the Lookuper interface
+var _ Lookuper = &VUResolver{}
+
+// NewVUResolver creates a VUResolver for the VU, sharing the state of the
+// k6/x/dns module. It is meant to be called from the NewModuleInstance method of
+// the embedding extension.
+func NewVUResolver(vu modules.VU) *VUResolver {
+	return Default().NewVUResolver(vu)
+}
+
+// NewVUResolver creates a VUResolver for the VU, sharing the state of the root
+// module, along with the client of the VU's module instance, and thus its
+// connections, transports and interceptors.
+func (rm *RootModule) NewVUResolver(vu modules.VU) *VUResolver {
+	return &VUResolver{mi: rm.instance(vu)}
+}
+
+// Resolve resolves a domain name to the data of the records of the given type,
+// e.g. IP addresses for A and AAAA records, using the given nameserver.
+func (r *VUResolver) Resolve(ctx context.Context, query, recordType string, nameserver Nameserver) ([]string, error) {
+	response, err := r.Query(ctx, query, recordType, nameserver, QueryOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Answers, nil
+}
+
+// Query resolves a domain name using the given nameserver, and returns the
+// resulting Response, as Client.Query does.
+func (r *VUResolver) Query(
+	ctx context.Context,
+	query, recordType string,
+	nameserver Nameserver,
+	opts QueryOptions,
+) (*Response, error) {
+	if r.mi.vu.State() == nil {
+		return nil, errResolverInitContext
+	}
+
+	r.mi.closeOnTeardown()
+
+	response, _, err := r.mi.resolveQuery(ctx, query, recordType, nameserver, resolveOptions{QueryOptions: opts})
+
+	return response, err
+}
+
+// Lookup resolves a domain name to IP addresses using the system's default
+// resolver.
+func (r *VUResolver) Lookup(ctx context.Context, hostname string, opts LookupOptions) ([]string, error) {
+	if r.mi.vu.State() == nil {
+		return nil, errResolverInitContext
+	}
+
+	lookuper := newRestrictedLookuper(r.mi.dnsClient, r.mi.vu.State().Options)
+
+	result, err := r.mi.lookup(ctx, lookuper, hostname, lookupOptions{LookupOptions: opts, All: true})
+	if err != nil {
+		return nil, err
+	}
+
+	ips, _ := result.([]string)
+
+	return ips, nil
+}
+
+// RegisterTransport registers the transport the resolver's queries are sent over
+// when their Protocol option is the protocol, as Client.RegisterTransport does.
+func (r *VUResolver) RegisterTransport(protocol string, transport Transport) error {
+	return r.mi.dnsClient.RegisterTransport(protocol, transport)
+}
+
+// AddInterceptor adds an interceptor the messages sent by the resolver go through,
+// as Client.AddInterceptor does. As the resolver shares its client with the VU's
+// module instance, the queries of the VU's script go through it too.
+func (r *VUResolver) AddInterceptor(interceptor Interceptor) {
+	r.mi.dnsClient.AddInterceptor(interceptor)
+}
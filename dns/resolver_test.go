@@ -0,0 +1,167 @@
+package dns
+
+import (
+	"context"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/js/compiler"
+	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+)
+
+func TestVUResolver(t *testing.T) {
+	t.Parallel()
+
+	server := newServer(noServerFaults(), 1)
+	_, err := server.AddRecord("k6.test. 60 IN A 203.0.113.1")
+	require.NoError(t, err)
+	require.NoError(t, server.listen(defaultServerAddress))
+	t.Cleanup(server.Close)
+
+	nameserver, err := parseNameserverAddr(server.Address)
+	require.NoError(t, err)
+
+	t.Run("Resolutions should emit the module's metrics", func(t *testing.T) {
+		t.Parallel()
+
+		rootModule := New()
+		runtime := modulestest.NewRuntime(t)
+		resolver := rootModule.NewVUResolver(runtime.VU)
+
+		samples := make(chan metrics.SampleContainer, 1024)
+		runtime.MoveToVUContext(&lib.State{
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+			Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+			Samples:        samples,
+		})
+
+		answers, err := resolver.Resolve(context.Background(), "k6.test", "A", nameserver)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"203.0.113.1"}, answers)
+
+		response, err := resolver.Query(context.Background(), "k6.test", "A", nameserver, QueryOptions{Protocol: protocolTCP})
+		require.NoError(t, err)
+		assert.Equal(t, protocolTCP, response.Protocol)
+
+		// The TCP connection is kept open, and accounted for by the root module
+		assert.Equal(t, int64(1), rootModule.openConnections.Load())
+
+		resolutions := 0
+		for len(samples) > 0 {
+			for _, sample := range (<-samples).GetSamples() {
+				if sample.Metric.Name == "dns_resolutions" {
+					resolutions++
+				}
+			}
+		}
+
+		assert.Equal(t, 2, resolutions)
+	})
+
+	t.Run("Connections should be shared with the VU's script", func(t *testing.T) {
+		t.Parallel()
+
+		rootModule := New()
+		runtime := newResolverRuntime(t, rootModule)
+		resolver := rootModule.NewVUResolver(runtime.VU)
+		require.NoError(t, runtime.VU.Runtime().Set("nameserver", server.Address))
+
+		samples := make(chan metrics.SampleContainer, 1024)
+		runtime.MoveToVUContext(&lib.State{
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+			Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+			Samples:        samples,
+		})
+
+		_, err := resolver.Query(context.Background(), "k6.test", "A", nameserver, QueryOptions{Protocol: protocolTCP})
+		require.NoError(t, err)
+
+		_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+			await dns.resolve("k6.test", "A", nameserver, { protocol: "tcp" });
+		`))
+		require.NoError(t, err)
+
+		// The script's query is sent over the connection the resolver opened
+		assert.Equal(t, int64(1), rootModule.openConnections.Load())
+
+		var reuse []float64
+		for len(samples) > 0 {
+			for _, sample := range (<-samples).GetSamples() {
+				if sample.Metric.Name == "dns_connection_reuse" {
+					reuse = append(reuse, sample.Value)
+				}
+			}
+		}
+
+		assert.Equal(t, []float64{0, 1}, reuse)
+	})
+
+	t.Run("Interceptors should see the queries of the VU's script", func(t *testing.T) {
+		t.Parallel()
+
+		rootModule := New()
+		runtime := newResolverRuntime(t, rootModule)
+		resolver := rootModule.NewVUResolver(runtime.VU)
+		require.NoError(t, runtime.VU.Runtime().Set("nameserver", server.Address))
+
+		var intercepted []string
+		resolver.AddInterceptor(func(
+			ctx context.Context,
+			msg *dns.Msg,
+			nameserver Nameserver,
+			opts QueryOptions,
+			next SendFunc,
+		) (*Response, error) {
+			intercepted = append(intercepted, msg.Question[0].Name)
+
+			return next(ctx, msg, nameserver, opts)
+		})
+
+		runtime.MoveToVUContext(&lib.State{
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+			Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+			Samples:        make(chan metrics.SampleContainer, 1024),
+		})
+
+		_, err := runtime.RunOnEventLoop(wrapInAsyncLambda(`
+			await dns.resolve("k6.test", "A", nameserver);
+		`))
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"k6.test."}, intercepted)
+	})
+
+	t.Run("Resolving in the init context should fail", func(t *testing.T) {
+		t.Parallel()
+
+		resolver := New().NewVUResolver(modulestest.NewRuntime(t).VU)
+
+		_, err := resolver.Resolve(context.Background(), "k6.test", "A", nameserver)
+
+		assert.ErrorIs(t, err, errResolverInitContext)
+	})
+}
+
+// newResolverRuntime returns a runtime whose k6/x/dns module is the root module,
+// so that the VUResolvers created out of it share the VU's module instance.
+func newResolverRuntime(t *testing.T, rootModule *RootModule) *modulestest.Runtime {
+	t.Helper()
+
+	runtime := modulestest.NewRuntime(t)
+
+	err := runtime.SetupModuleSystem(
+		map[string]interface{}{"k6/x/dns": rootModule},
+		nil,
+		compiler.New(runtime.VU.InitEnv().Logger),
+	)
+	require.NoError(t, err)
+
+	_, err = runtime.VU.Runtime().RunString(initGlobals)
+	require.NoError(t, err)
+
+	return runtime
+}
@@ -0,0 +1,187 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/types"
+)
+
+// restrictedLookuper is a Lookuper enforcing k6's network restrictions on top of
+// another Lookuper.
+//
+// It refuses to look up the hostnames matching k6's blockHostnames option, and
+// filters out the IP addresses matching k6's blacklistIPs option from the results.
+type restrictedLookuper struct {
+	lookuper Lookuper
+
+	// addrLookuper is used to perform reverse lookups, if supported.
+	addrLookuper AddrLookuper
+
+	// blockedHostnames holds the hostnames patterns lookups are forbidden for.
+	blockedHostnames *types.HostnameTrie
+
+	// blacklistedIPs holds the IP ranges filtered out of the lookups' results.
+	blacklistedIPs []*lib.IPNet
+
+	// srvResolver is used to look up the SRV records of services.
+	srvResolver *net.Resolver
+}
+
+// Ensure our restrictedLookuper implements the Lookuper interface
+var _ Lookuper = &restrictedLookuper{}
+
+// Ensure our restrictedLookuper implements the ServiceLookuper interface
+var _ ServiceLookuper = &restrictedLookuper{}
+
+// Ensure our restrictedLookuper implements the AddrLookuper interface
+var _ AddrLookuper = &restrictedLookuper{}
+
+// newRestrictedLookuper creates a new restrictedLookuper enforcing the network
+// restrictions defined in the provided k6 options.
+//
+// If the provided Lookuper also implements the AddrLookuper interface, it is used
+// to perform reverse lookups.
+func newRestrictedLookuper(lookuper Lookuper, options lib.Options) *restrictedLookuper {
+	addrLookuper, _ := lookuper.(AddrLookuper)
+
+	return &restrictedLookuper{
+		lookuper:         lookuper,
+		addrLookuper:     addrLookuper,
+		blockedHostnames: options.BlockedHostnames.Trie,
+		blacklistedIPs:   options.BlacklistIPs,
+		srvResolver:      net.DefaultResolver,
+	}
+}
+
+// Lookup resolves a domain name to a slice of IP addresses using the underlying
+// Lookuper, while enforcing k6's network restrictions.
+func (l *restrictedLookuper) Lookup(ctx context.Context, hostname string, opts LookupOptions) ([]string, error) {
+	if err := l.checkHostname(hostname); err != nil {
+		return nil, err
+	}
+
+	ips, err := l.lookuper.Lookup(ctx, hostname, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.filterIPs(hostname, ips)
+}
+
+// LookupService looks up the endpoints of the service name, while enforcing k6's
+// network restrictions on both the service name and the endpoints' targets.
+func (l *restrictedLookuper) LookupService(
+	ctx context.Context,
+	name string,
+	opts LookupOptions,
+) ([]ServiceEndpoint, error) {
+	if err := l.checkHostname(name); err != nil {
+		return nil, err
+	}
+
+	return lookupService(ctx, l.srvResolver, name, opts, l)
+}
+
+// LookupAddr performs a reverse lookup of the IP address using the underlying
+// AddrLookuper, while enforcing k6's network restrictions.
+//
+// Reverse lookups of blacklisted IP addresses fail with a BlacklistedIP error,
+// and the hostnames matching the blocked hostnames patterns are filtered out of
+// the results.
+func (l *restrictedLookuper) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	if l.addrLookuper == nil {
+		return nil, errors.New("reverse lookups are not supported by the underlying lookuper")
+	}
+
+	if l.isBlacklisted(net.ParseIP(addr)) {
+		return nil, &Error{
+			Name:    BlacklistedIP.String(),
+			Message: fmt.Sprintf("IP address %s is in a blacklisted range", addr),
+			Kind:    BlacklistedIP,
+		}
+	}
+
+	hostnames, err := l.addrLookuper.LookupAddr(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := make([]string, 0, len(hostnames))
+	for _, hostname := range hostnames {
+		if l.checkHostname(hostname) == nil {
+			allowed = append(allowed, hostname)
+		}
+	}
+
+	if len(allowed) == 0 && len(hostnames) > 0 {
+		return nil, &Error{
+			Name:    BlockedHostname.String(),
+			Message: fmt.Sprintf("all the hostnames %s maps to are blocked", addr),
+			Kind:    BlockedHostname,
+		}
+	}
+
+	return allowed, nil
+}
+
+// checkHostname returns a BlockedHostname error if the hostname matches one of the
+// blocked hostnames patterns.
+func (l *restrictedLookuper) checkHostname(hostname string) error {
+	if l.blockedHostnames == nil {
+		return nil
+	}
+
+	if match, blocked := l.blockedHostnames.Contains(hostname); blocked {
+		return &Error{
+			Name:    BlockedHostname.String(),
+			Message: fmt.Sprintf("hostname %s is blocked by the %s pattern", hostname, match),
+			Kind:    BlockedHostname,
+		}
+	}
+
+	return nil
+}
+
+// filterIPs removes the blacklisted IP addresses from the provided ones. It returns a
+// BlacklistedIP error if all of them were blacklisted.
+func (l *restrictedLookuper) filterIPs(hostname string, ips []string) ([]string, error) {
+	if len(l.blacklistedIPs) == 0 {
+		return ips, nil
+	}
+
+	allowed := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		if !l.isBlacklisted(net.ParseIP(ip)) {
+			allowed = append(allowed, ip)
+		}
+	}
+
+	if len(allowed) == 0 && len(ips) > 0 {
+		return nil, &Error{
+			Name:    BlacklistedIP.String(),
+			Message: fmt.Sprintf("all the IP addresses %s resolves to are in a blacklisted range", hostname),
+			Kind:    BlacklistedIP,
+		}
+	}
+
+	return allowed, nil
+}
+
+// isBlacklisted returns true if the IP address is in one of the blacklisted ranges.
+func (l *restrictedLookuper) isBlacklisted(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+
+	for _, ipnet := range l.blacklistedIPs {
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
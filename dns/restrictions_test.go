@@ -0,0 +1,155 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/types"
+)
+
+func TestRestrictedLookuper_Lookup(t *testing.T) {
+	t.Parallel()
+
+	blockedHostnames, err := types.NewNullHostnameTrie([]string{"*.blocked.test"})
+	require.NoError(t, err)
+
+	blacklistedIPs, err := lib.ParseCIDR("203.0.113.0/24")
+	require.NoError(t, err)
+
+	options := lib.Options{
+		BlockedHostnames: blockedHostnames,
+		BlacklistIPs:     []*lib.IPNet{blacklistedIPs},
+	}
+
+	lookuper := newRestrictedLookuper(staticLookuper{
+		"k6.test":          {primaryTestIPv4, "198.51.100.1"},
+		"blacklisted.test": {primaryTestIPv4, secondaryTestIPv4},
+		"sub.blocked.test": {"198.51.100.1"},
+	}, options)
+
+	t.Run("blacklisted IPs are filtered out", func(t *testing.T) {
+		t.Parallel()
+
+		gotIPs, err := lookuper.Lookup(context.Background(), "k6.test", LookupOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"198.51.100.1"}, gotIPs)
+	})
+
+	t.Run("blocked hostnames fail", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := lookuper.Lookup(context.Background(), "sub.blocked.test", LookupOptions{})
+
+		var dnsErr *Error
+		require.True(t, errors.As(err, &dnsErr))
+		assert.Equal(t, BlockedHostname, dnsErr.Kind)
+	})
+
+	t.Run("reverse lookups of blacklisted IPs fail", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := lookuper.LookupAddr(context.Background(), primaryTestIPv4)
+
+		var dnsErr *Error
+		require.True(t, errors.As(err, &dnsErr))
+		assert.Equal(t, BlacklistedIP, dnsErr.Kind)
+	})
+
+	t.Run("blocked hostnames are filtered out of reverse lookups", func(t *testing.T) {
+		t.Parallel()
+
+		gotHostnames, err := lookuper.LookupAddr(context.Background(), "198.51.100.1")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"k6.test"}, gotHostnames)
+	})
+
+	t.Run("hostnames resolving to blacklisted IPs only fail", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := lookuper.Lookup(context.Background(), "blacklisted.test", LookupOptions{})
+
+		var dnsErr *Error
+		require.True(t, errors.As(err, &dnsErr))
+		assert.Equal(t, BlacklistedIP, dnsErr.Kind)
+	})
+}
+
+func TestRestrictedLookuper_LookupService(t *testing.T) {
+	t.Parallel()
+
+	blockedHostnames, err := types.NewNullHostnameTrie([]string{"*.blocked.test"})
+	require.NoError(t, err)
+
+	lookuper := newRestrictedLookuper(staticLookuper{
+		"k6.test":          {"198.51.100.1"},
+		"api.blocked.test": {"198.51.100.2"},
+	}, lib.Options{BlockedHostnames: blockedHostnames})
+	lookuper.srvResolver = newSRVResolver(t,
+		"_http._tcp.k6.test. 60 IN SRV 10 0 80 api.blocked.test.",
+		"_http._tcp.k6.test. 60 IN SRV 20 0 80 k6.test.",
+		"_http._tcp.blocked.test. 60 IN SRV 10 0 80 k6.test.",
+		"_http._tcp.only.test. 60 IN SRV 10 0 80 api.blocked.test.",
+	)
+
+	t.Run("blocked targets are omitted", func(t *testing.T) {
+		t.Parallel()
+
+		endpoints, err := lookuper.LookupService(context.Background(), "_http._tcp.k6.test", LookupOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, []ServiceEndpoint{
+			{Target: "k6.test", Port: 80, Priority: 20, Addresses: []string{"198.51.100.1"}},
+		}, endpoints)
+	})
+
+	t.Run("services whose targets are all blocked fail", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := lookuper.LookupService(context.Background(), "_http._tcp.only.test", LookupOptions{})
+
+		var dnsErr *Error
+		require.True(t, errors.As(err, &dnsErr))
+		assert.Equal(t, BlockedHostname, dnsErr.Kind)
+	})
+
+	t.Run("blocked service names fail", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := lookuper.LookupService(context.Background(), "_http._tcp.blocked.test", LookupOptions{})
+
+		var dnsErr *Error
+		require.True(t, errors.As(err, &dnsErr))
+		assert.Equal(t, BlockedHostname, dnsErr.Kind)
+	})
+}
+
+// staticLookuper is a Lookuper resolving hostnames from a static map.
+type staticLookuper map[string][]string
+
+func (l staticLookuper) Lookup(_ context.Context, hostname string, _ LookupOptions) ([]string, error) {
+	ips, ok := l[hostname]
+	if !ok {
+		return nil, newDNSError(3, "no such host")
+	}
+
+	return ips, nil
+}
+
+func (l staticLookuper) LookupAddr(_ context.Context, addr string) ([]string, error) {
+	var hostnames []string
+	for hostname, ips := range l {
+		for _, ip := range ips {
+			if ip == addr {
+				hostnames = append(hostnames, hostname)
+			}
+		}
+	}
+
+	sort.Strings(hostnames)
+
+	return hostnames, nil
+}
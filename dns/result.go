@@ -0,0 +1,231 @@
+package dns
+
+import (
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// resolveResult is the object the resolve function resolves to when it is
+// instructed not to throw on failure.
+type resolveResult struct {
+	// Name holds the domain name which was resolved, including the random label
+	// prepended to it, if any.
+	Name string `js:"name"`
+
+	// Type holds the record type which was resolved.
+	Type string `js:"type"`
+
+	// Answers holds the data of the records of the resolved type found in the answer
+	// section of the response, e.g. IP addresses.
+	Answers []string `js:"answers"`
+
+	// Records holds all the records found in the answer section of the response,
+	// along with their TTL, including those not matching the resolved type, such
+	// as CNAME records.
+	Records []Record `js:"records"`
+
+	// AnswerCount holds the number of answers found in the response, before they
+	// were cut to the maxAnswers option.
+	AnswerCount int `js:"answerCount"`
+
+	// AnswersTruncated indicates whether the answers, or the records, were cut to
+	// the maxAnswers option.
+	AnswersTruncated bool `js:"answersTruncated"`
+
+	// Authority holds the records found in the authority section of the response,
+	// such as the NS records of delegations, if requested. It is empty otherwise.
+	Authority []Record `js:"authority"`
+
+	// Additional holds the records found in the additional section of the response,
+	// such as glue records, if requested, leaving out the EDNS0 OPT and TSIG
+	// pseudo-records. It is empty otherwise.
+	Additional []Record `js:"additional"`
+
+	// Error holds the error the resolution failed with, if any.
+	Error *Error `js:"error"`
+
+	// Rcode holds the name of the response code returned by the nameserver, if any.
+	Rcode string `js:"rcode"`
+
+	// Flags holds the header flags of the response returned by the nameserver, by
+	// name, e.g. "aa" for authoritative answers. It is empty if no response was received.
+	Flags map[string]bool `js:"flags"`
+
+	// RTT holds the duration of the resolution, in milliseconds.
+	RTT float64 `js:"rtt"`
+
+	// Chain holds the names the CNAME records which were followed pointed to,
+	// in order. It is empty unless CNAME records were followed.
+	Chain []string `js:"chain"`
+
+	// Raw holds the wire format of the exchanged messages, if requested.
+	Raw *rawMessages `js:"raw"`
+
+	// TLS describes the TLS connection the query was sent over, with DoT and DoH,
+	// or is nil otherwise.
+	TLS *TLSInfo `js:"tls"`
+
+	// HTTPCache describes the caching of the response by HTTP caches, with DoH, or
+	// is nil otherwise.
+	HTTPCache *HTTPCacheInfo `js:"httpCache"`
+
+	// Server describes the nameserver which answered the query, or is nil if none
+	// did.
+	Server *serverInfo `js:"server"`
+}
+
+// serverInfo describes the nameserver which answered a query, and how, so that
+// behavior can be attributed to it.
+type serverInfo struct {
+	// Address holds the address of the nameserver.
+	Address string `js:"address"`
+
+	// Protocol holds the protocol the query was answered over.
+	Protocol string `js:"protocol"`
+
+	// Attempt holds the number of the attempt the nameserver answered, starting
+	// from 1.
+	Attempt int `js:"attempt"`
+
+	// EDNSDowngrade holds the response code, either FORMERR or BADVERS, which led
+	// the query to be sent again without EDNS, or is empty if it was not.
+	EDNSDowngrade string `js:"ednsDowngrade"`
+}
+
+// newServerInfo creates a serverInfo out of a Response. It returns nil if no
+// nameserver answered.
+func newServerInfo(response *Response) *serverInfo {
+	if response == nil || response.Attempt == 0 {
+		return nil
+	}
+
+	return &serverInfo{
+		Address:       response.Nameserver,
+		Protocol:      response.Protocol,
+		Attempt:       response.Attempt,
+		EDNSDowngrade: response.EDNSDowngrade,
+	}
+}
+
+// rawMessages holds the wire format of the messages exchanged with a nameserver,
+// hex encoded.
+type rawMessages struct {
+	// Request holds the message sent to the nameserver.
+	Request string `js:"request"`
+
+	// Response holds the message received from the nameserver, or an empty
+	// string if none was received.
+	Response string `js:"response"`
+}
+
+// newRawMessages creates a rawMessages out of a Response. It returns nil if the
+// nameserver was not queried.
+func newRawMessages(response *Response) *rawMessages {
+	if response == nil || response.RawRequest == nil {
+		return nil
+	}
+
+	return &rawMessages{
+		Request:  hex.EncodeToString(response.RawRequest),
+		Response: hex.EncodeToString(response.RawResponse),
+	}
+}
+
+// newResolveResult creates a resolveResult out of the outcome of a resolution.
+func newResolveResult(
+	name, recordType string,
+	response *Response,
+	resolveErr error,
+	duration time.Duration,
+) *resolveResult {
+	result := &resolveResult{
+		Name:    name,
+		Type:    recordType,
+		Answers: []string{},
+		Records: []Record{},
+		Chain:   []string{},
+		Error:   asError(resolveErr),
+		RTT:     float64(duration) / float64(time.Millisecond),
+	}
+
+	if response != nil {
+		if response.QueryName != "" {
+			result.Name = response.QueryName
+		}
+
+		result.Rcode = response.Rcode
+		result.TLS = response.TLS
+		result.HTTPCache = response.HTTPCache
+		result.Server = newServerInfo(response)
+
+		if response.msg != nil {
+			result.Flags = messageFlags(response.msg)
+		}
+
+		if response.CNAMEChain != nil {
+			result.Chain = response.CNAMEChain
+		}
+
+		if resolveErr == nil && response.Answers != nil {
+			result.Answers = response.Answers
+		}
+
+		if resolveErr == nil && response.Records != nil {
+			result.Records = response.Records
+		}
+	}
+
+	result.AnswerCount = len(result.Answers)
+
+	return result
+}
+
+// includeSections includes the authority and additional sections of the response
+// in the result, if one was received.
+func (r *resolveResult) includeSections(response *Response) {
+	if response == nil || response.msg == nil {
+		return
+	}
+
+	r.Authority = newRecords(response.msg.Ns)
+	r.Additional = additionalRecords(response.msg)
+}
+
+// limitAnswers cuts the answers and the records of the result to maxAnswers each,
+// unless it is zero, so that names holding hundreds of records, such as large
+// round-robin sets, do not weigh on the memory of the script.
+func (r *resolveResult) limitAnswers(maxAnswers int) {
+	if maxAnswers <= 0 {
+		return
+	}
+
+	if len(r.Answers) > maxAnswers {
+		r.Answers = append([]string{}, r.Answers[:maxAnswers]...)
+		r.AnswersTruncated = true
+	}
+
+	if len(r.Records) > maxAnswers {
+		r.Records = append([]Record{}, r.Records[:maxAnswers]...)
+		r.AnswersTruncated = true
+	}
+}
+
+// asError converts any error into an Error, so that it can be consistently
+// inspected from the JS runtime. It returns nil if err is nil.
+func asError(err error) *Error {
+	if err == nil {
+		return nil
+	}
+
+	var dnsErr *Error
+	if errors.As(err, &dnsErr) {
+		return dnsErr
+	}
+
+	return &Error{
+		Name:    "Error",
+		Message: err.Error(),
+		err:     err,
+	}
+}
@@ -0,0 +1,157 @@
+package dns
+
+import (
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+)
+
+func Test_newRawMessages(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		response *Response
+		want     *rawMessages
+	}{
+		{
+			name:     "nameserver not queried",
+			response: nil,
+			want:     nil,
+		},
+		{
+			name:     "no response received",
+			response: &Response{RawRequest: []byte{0x12, 0x34}},
+			want:     &rawMessages{Request: "1234", Response: ""},
+		},
+		{
+			name:     "response received",
+			response: &Response{RawRequest: []byte{0x12, 0x34}, RawResponse: []byte{0xab, 0xcd}},
+			want:     &rawMessages{Request: "1234", Response: "abcd"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.want, newRawMessages(tt.response))
+		})
+	}
+}
+
+func Test_resolveResult_limitAnswers(t *testing.T) {
+	t.Parallel()
+
+	newResult := func() *resolveResult {
+		return &resolveResult{
+			Answers: []string{"203.0.113.1", "203.0.113.2", "203.0.113.3"},
+			Records: []Record{
+				{Name: "www.k6.test", Type: "CNAME", TTL: 60, Data: "k6.test"},
+				{Name: "k6.test", Type: "A", TTL: 60, Data: "203.0.113.1"},
+				{Name: "k6.test", Type: "A", TTL: 60, Data: "203.0.113.2"},
+				{Name: "k6.test", Type: "A", TTL: 60, Data: "203.0.113.3"},
+			},
+			AnswerCount: 3,
+		}
+	}
+
+	tests := []struct {
+		name          string
+		maxAnswers    int
+		wantAnswers   int
+		wantRecords   int
+		wantTruncated bool
+	}{
+		{name: "no limit", maxAnswers: 0, wantAnswers: 3, wantRecords: 4},
+		{name: "limit above the records", maxAnswers: 4, wantAnswers: 3, wantRecords: 4},
+		{name: "limit cutting the records only", maxAnswers: 3, wantAnswers: 3, wantRecords: 3, wantTruncated: true},
+		{name: "limit cutting both", maxAnswers: 1, wantAnswers: 1, wantRecords: 1, wantTruncated: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			result := newResult()
+			result.limitAnswers(tt.maxAnswers)
+
+			assert.Len(t, result.Answers, tt.wantAnswers)
+			assert.Len(t, result.Records, tt.wantRecords)
+			assert.Equal(t, tt.wantTruncated, result.AnswersTruncated)
+			assert.Equal(t, 3, result.AnswerCount)
+			assert.Equal(t, "203.0.113.1", result.Answers[0])
+		})
+	}
+}
+
+func TestModuleInstance_Resolve_sections(t *testing.T) {
+	t.Parallel()
+
+	// The nameserver refers every query to the nameservers of k6.test, along with
+	// their glue records
+	handler := dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		response := new(dns.Msg)
+		response.SetReply(req)
+		response.Ns = append(response.Ns, &dns.NS{
+			Hdr: dns.RR_Header{Name: "k6.test.", Rrtype: dns.TypeNS, Class: dns.ClassINET, Ttl: 3600},
+			Ns:  "ns1.k6.test.",
+		})
+		response.Extra = append(response.Extra, &dns.A{
+			Hdr: dns.RR_Header{Name: "ns1.k6.test.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 3600},
+			A:   net.ParseIP("192.0.2.53"),
+		})
+		response.SetEdns0(dns.DefaultMsgSize, false)
+		_ = w.WriteMsg(response)
+	})
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := &dns.Server{PacketConn: conn, Handler: handler}
+	go func() { _ = server.ActivateAndServe() }()
+	t.Cleanup(func() { _ = server.Shutdown() })
+
+	runtime, err := newConfiguredRuntime(t)
+	require.NoError(t, err)
+	require.NoError(t, runtime.VU.Runtime().Set("address", conn.LocalAddr().String()))
+
+	runtime.MoveToVUContext(&lib.State{
+		BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+		Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+		Samples:        make(chan metrics.SampleContainer, 1024),
+	})
+
+	_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+		const referral = await dns.resolve("www.k6.test", "A", address, { throw: false, sections: true });
+		if (referral.error !== null || referral.answers.length !== 0) {
+			throw new Error("unexpected referral: " + JSON.stringify(referral));
+		}
+
+		const ns = referral.authority;
+		if (ns.length !== 1 || ns[0].name !== "k6.test" || ns[0].type !== "NS" || ns[0].data !== "ns1.k6.test") {
+			throw new Error("unexpected authority section: " + JSON.stringify(ns));
+		}
+
+		// The EDNS0 OPT pseudo-record is left out
+		const glue = referral.additional;
+		if (glue.length !== 1 || glue[0].name !== "ns1.k6.test" || glue[0].data !== "192.0.2.53") {
+			throw new Error("unexpected additional section: " + JSON.stringify(glue));
+		}
+
+		const result = await dns.resolve("www.k6.test", "A", address, { throw: false });
+		if (result.authority.length !== 0 || result.additional.length !== 0) {
+			throw new Error("expected the sections to be left out by default: " + JSON.stringify(result));
+		}
+	`))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,370 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/grafana/sobek"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/promises"
+	"go.k6.io/k6/lib/types"
+)
+
+const (
+	// defaultRRLStartQPS is the rate the probeRRL function starts sending queries
+	// at, unless specified otherwise.
+	defaultRRLStartQPS = 10
+
+	// defaultRRLMaxQPS is the rate the probeRRL function stops ramping up at,
+	// unless specified otherwise.
+	defaultRRLMaxQPS = 1000
+
+	// defaultRRLFactor is the factor the probeRRL function multiplies the rate by
+	// at each step, unless specified otherwise.
+	defaultRRLFactor = 2
+
+	// defaultRRLStepDuration is the duration of each step of the probeRRL
+	// function, unless specified otherwise.
+	defaultRRLStepDuration = 2 * time.Second
+
+	// defaultRRLLimitedRatio is the ratio of dropped or truncated responses of a
+	// step beyond which the probeRRL function considers the nameserver to be
+	// limiting the rate of its responses, unless specified otherwise.
+	defaultRRLLimitedRatio = 0.1
+)
+
+// probeRRLOptions holds the options that can be passed to the probeRRL function.
+type probeRRLOptions struct {
+	resolveOptions
+
+	// StartQPS holds the rate of the first step, in queries per second.
+	StartQPS float64
+
+	// MaxQPS holds the rate beyond which the ramp stops, in queries per second.
+	MaxQPS float64
+
+	// Factor holds the factor the rate is multiplied by at each step.
+	Factor float64
+
+	// StepDuration holds the duration each rate is sent at.
+	StepDuration time.Duration
+
+	// LimitedRatio holds the ratio of dropped or truncated responses of a step
+	// beyond which the nameserver is considered to be limiting its responses.
+	LimitedRatio float64
+
+	// MaxOutstanding holds the maximum number of queries outstanding at once,
+	// beyond which the queries due are skipped rather than sent.
+	MaxOutstanding int
+}
+
+// parseProbeRRLOptions parses the options object passed to the probeRRL function.
+//
+// It accepts the same options as the resolve function, along with the startQps,
+// maxQps, factor, stepDuration, limitedRatio and maxOutstanding options.
+func parseProbeRRLOptions(rt *sobek.Runtime, value sobek.Value) (probeRRLOptions, error) {
+	resolveOpts, err := parseResolveOptions(rt, value)
+	if err != nil {
+		return probeRRLOptions{}, err
+	}
+
+	opts := probeRRLOptions{
+		resolveOptions: resolveOpts,
+		StartQPS:       defaultRRLStartQPS,
+		MaxQPS:         defaultRRLMaxQPS,
+		Factor:         defaultRRLFactor,
+		StepDuration:   defaultRRLStepDuration,
+		LimitedRatio:   defaultRRLLimitedRatio,
+		MaxOutstanding: defaultMaxOutstanding,
+	}
+
+	// Rate-limited responses are truncated or dropped, which is exactly what is
+	// measured, and a TCP fallback would not be rate-limited
+	opts.Protocol = protocolUDP
+
+	if common.IsNullish(value) {
+		return opts, nil
+	}
+
+	params := value.ToObject(rt)
+
+	for _, rate := range []struct {
+		option string
+		value  *float64
+	}{
+		{option: "startQps", value: &opts.StartQPS},
+		{option: "maxQps", value: &opts.MaxQPS},
+	} {
+		v := params.Get(rate.option)
+		if common.IsNullish(v) {
+			continue
+		}
+
+		if err := rt.ExportTo(v, rate.value); err != nil ||
+			math.IsNaN(*rate.value) || math.IsInf(*rate.value, 0) || *rate.value <= 0 {
+			return opts, fmt.Errorf("%s option must be a strictly positive number; got %v instead", rate.option, v)
+		}
+	}
+
+	if opts.MaxQPS < opts.StartQPS {
+		return opts, fmt.Errorf("maxQps option must be at least startQps; got %v instead", opts.MaxQPS)
+	}
+
+	if v := params.Get("factor"); !common.IsNullish(v) {
+		if err := rt.ExportTo(v, &opts.Factor); err != nil || math.IsNaN(opts.Factor) ||
+			math.IsInf(opts.Factor, 0) || opts.Factor <= 1 {
+			return opts, fmt.Errorf("factor option must be a number greater than 1; got %v instead", v)
+		}
+	}
+
+	if v := params.Get("stepDuration"); !common.IsNullish(v) {
+		d, err := types.GetDurationValue(v.Export())
+		if err != nil {
+			return opts, fmt.Errorf("stepDuration option is invalid; reason: %w", err)
+		}
+
+		if d <= 0 {
+			return opts, fmt.Errorf("stepDuration option must be a strictly positive duration; got %v instead", v)
+		}
+
+		opts.StepDuration = d
+	}
+
+	if v := params.Get("limitedRatio"); !common.IsNullish(v) {
+		if err := rt.ExportTo(v, &opts.LimitedRatio); err != nil || math.IsNaN(opts.LimitedRatio) ||
+			opts.LimitedRatio <= 0 || opts.LimitedRatio > 1 {
+			return opts, fmt.Errorf("limitedRatio option must be a number between 0 excluded and 1; got %v instead", v)
+		}
+	}
+
+	if v := params.Get("maxOutstanding"); !common.IsNullish(v) {
+		var maxOutstanding int64
+		if err := rt.ExportTo(v, &maxOutstanding); err != nil || maxOutstanding < 1 {
+			return opts, fmt.Errorf("maxOutstanding option must be a strictly positive integer; got %v instead", v)
+		}
+
+		opts.MaxOutstanding = int(maxOutstanding)
+	}
+
+	return opts, nil
+}
+
+// probeRRLResult is the object the probeRRL function resolves to.
+type probeRRLResult struct {
+	// Name holds the name which was resolved.
+	Name string `js:"name"`
+
+	// Type holds the record type which was resolved.
+	Type string `js:"type"`
+
+	// Limited indicates whether the nameserver was observed limiting the rate of
+	// its responses.
+	Limited bool `js:"limited"`
+
+	// Threshold holds the rate of the first step the nameserver limited its
+	// responses at, in queries per second, or nil if it did not.
+	Threshold interface{} `js:"threshold"`
+
+	// MaxUnlimitedQPS holds the rate of the last step the nameserver did not limit
+	// its responses at, in queries per second, or nil if it limited them from the
+	// first step.
+	MaxUnlimitedQPS interface{} `js:"maxUnlimitedQps"`
+
+	// Slip holds the number of rate-limited responses per truncated one, at the
+	// first step the nameserver limited its responses at, or nil if it did not
+	// truncate any, e.g. 2 for nameservers truncating every other limited response.
+	Slip interface{} `js:"slip"`
+
+	// Steps holds the statistics of each step of the ramp, in order.
+	Steps []*rrlStep `js:"steps"`
+}
+
+// rrlStep holds the statistics of a step of the ramp of the probeRRL function.
+type rrlStep struct {
+	// QPS holds the rate queries were to be sent at, in queries per second.
+	QPS float64 `js:"qps"`
+
+	// AchievedQPS holds the rate queries were sent at, in queries per second.
+	AchievedQPS float64 `js:"achievedQps"`
+
+	// Sent holds the number of queries which were sent.
+	Sent int64 `js:"sent"`
+
+	// Answered holds the number of queries which were answered with a full,
+	// non-truncated, response.
+	Answered int64 `js:"answered"`
+
+	// Truncated holds the number of queries which were answered with a truncated
+	// response.
+	Truncated int64 `js:"truncated"`
+
+	// Dropped holds the number of queries which were not answered in time.
+	Dropped int64 `js:"dropped"`
+
+	// Rcodes holds the number of responses, by response code name.
+	Rcodes map[string]int64 `js:"rcodes"`
+
+	// LimitedRatio holds the ratio of the queries sent which were truncated or
+	// dropped.
+	LimitedRatio float64 `js:"limitedRatio"`
+
+	// Limited indicates whether the limited ratio reached the limitedRatio option.
+	Limited bool `js:"limited"`
+}
+
+// ProbeRRL sends identical queries to the nameserver at increasing rates, until
+// it starts dropping or truncating its responses, as Response Rate Limiting (RRL)
+// implementations do, and resolves to the rate it did at.
+//
+// The promise is not rejected when queries fail, which is instead reported in the
+// statistics of the steps.
+func (mi *ModuleInstance) ProbeRRL(query, recordType, nameserverAddr, options sobek.Value) *sobek.Promise {
+	promise, resolve, reject := promises.New(mi.vu)
+
+	if mi.vu.State() == nil {
+		reject(errors.New("probeRRL can not be used in the init context"))
+		return promise
+	}
+
+	queryStr, err := exportDomainName(mi.vu.Runtime(), query, "query")
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	var recordTypeStr string
+	if err := mi.vu.Runtime().ExportTo(recordType, &recordTypeStr); err != nil {
+		reject(fmt.Errorf("recordType must be a string; got %v instead", recordType))
+		return promise
+	}
+
+	if _, err := RecordTypeString(recordTypeStr); err != nil {
+		reject(fmt.Errorf("%w, %s is an invalid DNS record type", ErrUnsupportedRecordType, recordTypeStr))
+		return promise
+	}
+
+	nameserver, err := mi.exportNameserver(nameserverAddr)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	opts, err := parseProbeRRLOptions(mi.vu.Runtime(), options)
+	if err != nil {
+		reject(fmt.Errorf("invalid probeRRL options: %w", err))
+		return promise
+	}
+
+	ctx, cancel, err := mi.withAbortSignal(opts.Signal)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer cancel()
+
+		resolve(mi.probeRRL(ctx, queryStr, recordTypeStr, nameserver, opts))
+	}()
+
+	return promise
+}
+
+// probeRRL sends the query at each rate of the ramp in turn, for opts.StepDuration,
+// and stops at the first rate the nameserver limits its responses at.
+func (mi *ModuleInstance) probeRRL(
+	ctx context.Context,
+	query, recordType string,
+	nameserver Nameserver,
+	opts probeRRLOptions,
+) *probeRRLResult {
+	result := &probeRRLResult{
+		Name:  query,
+		Type:  recordType,
+		Steps: []*rrlStep{},
+	}
+
+	// The rates are compared with some leeway, so that the maximum rate is not
+	// skipped because of rounding errors
+	for qps := opts.StartQPS; qps <= opts.MaxQPS*(1+1e-9) && ctx.Err() == nil; qps *= opts.Factor {
+		step := mi.runRRLStep(ctx, query, recordType, nameserver, qps, opts)
+		result.Steps = append(result.Steps, step)
+
+		if !step.Limited {
+			result.MaxUnlimitedQPS = qps
+			continue
+		}
+
+		result.Limited = true
+		result.Threshold = qps
+
+		if step.Truncated > 0 {
+			result.Slip = float64(step.Truncated+step.Dropped) / float64(step.Truncated)
+		}
+
+		break
+	}
+
+	return result
+}
+
+// runRRLStep sends the query at the rate for opts.StepDuration, and returns the
+// statistics of the responses.
+func (mi *ModuleInstance) runRRLStep(
+	ctx context.Context,
+	query, recordType string,
+	nameserver Nameserver,
+	qps float64,
+	opts probeRRLOptions,
+) *rrlStep {
+	interval := time.Duration(float64(time.Second) / qps)
+
+	s := schedule{
+		due: func(i int64) (time.Duration, bool) {
+			offset := time.Duration(i) * interval
+			return offset, offset < opts.StepDuration
+		},
+		window: func(scheduled int64) time.Duration {
+			return time.Duration(scheduled) * interval
+		},
+		maxOutstanding: opts.MaxOutstanding,
+	}
+
+	var truncated atomic.Int64
+
+	run := mi.runSchedule(ctx, s, func(ctx context.Context, _ int64) (*Response, time.Duration, error) {
+		response, duration, err := mi.resolveQuery(ctx, query, recordType, nameserver, opts.resolveOptions)
+		if response != nil && response.msg != nil && response.msg.Truncated {
+			truncated.Add(1)
+		}
+
+		return response, duration, err
+	})
+
+	var responses int64
+	for _, count := range run.Rcodes {
+		responses += count
+	}
+
+	step := &rrlStep{
+		QPS:         qps,
+		AchievedQPS: run.AchievedQPS,
+		Sent:        run.Sent,
+		Answered:    responses - truncated.Load(),
+		Truncated:   truncated.Load(),
+		Dropped:     run.Timeouts,
+		Rcodes:      run.Rcodes,
+	}
+
+	if step.Sent > 0 {
+		step.LimitedRatio = float64(step.Truncated+step.Dropped) / float64(step.Sent)
+	}
+
+	step.Limited = step.Sent > 0 && step.LimitedRatio >= opts.LimitedRatio
+
+	return step
+}
@@ -0,0 +1,162 @@
+package dns
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+)
+
+func TestProbeRRL(t *testing.T) {
+	t.Parallel()
+
+	t.Run("The rate responses start being limited at should be reported", func(t *testing.T) {
+		t.Parallel()
+
+		address := startRRLServer(t, 5, 100*time.Millisecond)
+
+		runtime, err := newConfiguredRuntime(t)
+		require.NoError(t, err)
+		require.NoError(t, runtime.VU.Runtime().Set("address", address))
+
+		runtime.MoveToVUContext(&lib.State{
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+			Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+			Samples:        make(chan metrics.SampleContainer, 4096),
+		})
+
+		_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+			const result = await dns.probeRRL("k6.test", "A", address, {
+				startQps: 20,
+				maxQps: 1280,
+				factor: 4,
+				stepDuration: "500ms",
+				timeout: "200ms",
+			});
+
+			if (!result.limited || result.threshold !== 80 || result.maxUnlimitedQps !== 20 || result.steps.length !== 2) {
+				throw new Error("unexpected result: " + JSON.stringify(result));
+			}
+
+			const [unlimited, limited] = result.steps;
+			if (unlimited.limited || unlimited.answered !== unlimited.sent || unlimited.truncated !== 0) {
+				throw new Error("unexpected unlimited step: " + JSON.stringify(unlimited));
+			}
+
+			if (!limited.limited || limited.truncated === 0 || limited.dropped === 0 || result.slip < 1.5 || result.slip > 2.5) {
+				throw new Error("unexpected limited step: " + JSON.stringify(result));
+			}
+		`))
+		assert.NoError(t, err)
+	})
+
+	t.Run("Nameservers which never limit their responses should be reported", func(t *testing.T) {
+		t.Parallel()
+
+		runtime, err := newConfiguredRuntime(t)
+		require.NoError(t, err)
+
+		_, err = runtime.VU.Runtime().RunString(`
+			const server = dns.startServer(["k6.test. 60 IN A 203.0.113.1"]);
+		`)
+		require.NoError(t, err)
+
+		runtime.MoveToVUContext(&lib.State{
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+			Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+			Samples:        make(chan metrics.SampleContainer, 1024),
+		})
+
+		_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+			const result = await dns.probeRRL("k6.test", "A", server.address, {
+				startQps: 20,
+				maxQps: 80,
+				stepDuration: "200ms",
+			});
+
+			const rates = result.steps.map((step) => step.qps);
+			if (result.limited || result.threshold !== null || result.slip !== null || rates.join() !== "20,40,80") {
+				throw new Error("unexpected result: " + JSON.stringify(result));
+			}
+		`))
+		assert.NoError(t, err)
+	})
+
+	t.Run("Invalid ramps should be rejected", func(t *testing.T) {
+		t.Parallel()
+
+		runtime, err := newConfiguredRuntime(t)
+		require.NoError(t, err)
+
+		runtime.MoveToVUContext(&lib.State{
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+			Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+			Samples:        make(chan metrics.SampleContainer, 1024),
+		})
+
+		_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+			await dns.probeRRL("k6.test", "A", "127.0.0.1:53", { factor: 1 });
+		`))
+		assert.ErrorContains(t, err, "factor option must be a number greater than 1")
+	})
+}
+
+// startRRLServer starts a nameserver limiting the rate of its responses as RRL
+// implementations do, beyond limit responses within the window: it truncates
+// every other limited response and drops the others. It returns the address of
+// the nameserver.
+func startRRLServer(t *testing.T, limit int, window time.Duration) string {
+	t.Helper()
+
+	var mu sync.Mutex
+	var received []time.Time
+	limited := 0
+
+	handler := dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		mu.Lock()
+		now := time.Now()
+		for len(received) > 0 && now.Sub(received[0]) > window {
+			received = received[1:]
+		}
+
+		received = append(received, now)
+		slipped := len(received) > limit
+		if slipped {
+			limited++
+		}
+
+		drop := slipped && limited%2 == 0
+		mu.Unlock()
+
+		if drop {
+			return
+		}
+
+		response := new(dns.Msg)
+		response.SetReply(req)
+
+		if slipped {
+			response.Truncated = true
+		} else {
+			rr, _ := dns.NewRR(req.Question[0].Name + " 60 IN A 192.0.2.1")
+			response.Answer = append(response.Answer, rr)
+		}
+
+		_ = w.WriteMsg(response)
+	})
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := &dns.Server{PacketConn: conn, Handler: handler}
+	go func() { _ = server.ActivateAndServe() }()
+	t.Cleanup(func() { _ = server.Shutdown() })
+
+	return conn.LocalAddr().String()
+}
@@ -0,0 +1,497 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/sobek"
+	"github.com/miekg/dns"
+	"go.k6.io/k6/js/common"
+)
+
+// defaultServerAddress is the address servers listen on unless instructed otherwise,
+// letting the system pick an available port.
+const defaultServerAddress = "127.0.0.1:0"
+
+// defaultServerLogLimit is the default number of queries a server keeps in its log.
+const defaultServerLogLimit = 10000
+
+// maxServerCNAMEChain is the maximum number of CNAME records a server follows
+// within its own records when answering a query.
+const maxServerCNAMEChain = 8
+
+// Server is an in-process DNS server answering queries, over both UDP and TCP, out
+// of records which can be changed while it runs, allowing resolutions to be tested
+// without depending on external nameservers.
+//
+// Its methods return the server itself, so that calls can be chained.
+type Server struct {
+	// Address holds the address the server listens on, e.g. "127.0.0.1:53535",
+	// suitable for being passed as a nameserver.
+	Address string `js:"address"`
+
+	mu      sync.Mutex
+	records map[string][]dns.RR
+	rcodes  map[string]int
+	queries map[string]int
+	total   int
+
+	log      []serverQuery
+	logLimit int
+
+	faults serverFaults
+	rng    *rand.Rand
+	rt     *sobek.Runtime
+
+	servers   []*dns.Server
+	closeOnce sync.Once
+}
+
+// serverOptions holds the options that can be passed to the startServer function.
+type serverOptions struct {
+	// Address holds the address the server should listen on.
+	Address string
+
+	// Faults holds the faults the server should inject into its answers.
+	Faults serverFaults
+
+	// Seed holds the seed of the random source the faults are injected with.
+	Seed int64
+
+	// LogLimit holds the number of queries the server keeps in its log.
+	LogLimit int
+}
+
+// serverQuery describes a query received by a Server, as kept in its log.
+type serverQuery struct {
+	// ID holds the ID of the query.
+	ID uint16 `js:"id"`
+
+	// Name holds the name the query is about, without its trailing dot.
+	Name string `js:"name"`
+
+	// Type holds the record type the query is about, e.g. "A".
+	Type string `js:"type"`
+
+	// Class holds the class the query is about, e.g. "IN".
+	Class string `js:"class"`
+
+	// Source holds the address the query was sent from.
+	Source string `js:"source"`
+
+	// Protocol holds the protocol the query was sent over, either "udp" or "tcp".
+	Protocol string `js:"protocol"`
+
+	// Flags holds the header flags of the query, by name.
+	Flags map[string]bool `js:"flags"`
+
+	// EDNS holds the EDNS0 parameters of the query, if it has an OPT record.
+	EDNS *messageEDNS `js:"edns"`
+
+	// Time holds the time the query was received at, in milliseconds since the
+	// Unix epoch.
+	Time int64 `js:"time"`
+}
+
+// parseServerOptions parses the options object passed to the startServer function.
+func parseServerOptions(rt *sobek.Runtime, value sobek.Value) (serverOptions, error) {
+	opts := serverOptions{
+		Address:  defaultServerAddress,
+		Faults:   noServerFaults(),
+		Seed:     time.Now().UnixNano(),
+		LogLimit: defaultServerLogLimit,
+	}
+
+	if common.IsNullish(value) {
+		return opts, nil
+	}
+
+	params := value.ToObject(rt)
+
+	if v := params.Get("address"); !common.IsNullish(v) {
+		if _, _, err := net.SplitHostPort(v.String()); err != nil {
+			return opts, fmt.Errorf("address option must be in the host:port form; got %v instead", v)
+		}
+
+		opts.Address = v.String()
+	}
+
+	if v := params.Get("seed"); !common.IsNullish(v) {
+		if err := rt.ExportTo(v, &opts.Seed); err != nil {
+			return opts, fmt.Errorf("seed option must be an integer; got %v instead", v)
+		}
+	}
+
+	if v := params.Get("logLimit"); !common.IsNullish(v) {
+		var limit int64
+		if err := rt.ExportTo(v, &limit); err != nil || limit < 0 {
+			return opts, fmt.Errorf("logLimit option must be a positive integer; got %v instead", v)
+		}
+
+		opts.LogLimit = int(limit)
+	}
+
+	faults, err := parseServerFaults(rt, params)
+	if err != nil {
+		return opts, err
+	}
+
+	opts.Faults = faults
+
+	return opts, nil
+}
+
+// StartServer starts a Server answering out of the records, in presentation format
+// (e.g. "k6.io. 60 IN A 192.0.2.1").
+//
+// Servers started by VU code are closed once the VU stops running, and those started
+// in the init context once the test ends, unless they were closed beforehand.
+func (mi *ModuleInstance) StartServer(records, options sobek.Value) (*Server, error) {
+	var rrs []string
+	if !common.IsNullish(records) {
+		if err := mi.vu.Runtime().ExportTo(records, &rrs); err != nil {
+			return nil, fmt.Errorf("records must be an array of strings; got %v instead", records)
+		}
+	}
+
+	opts, err := parseServerOptions(mi.vu.Runtime(), options)
+	if err != nil {
+		return nil, fmt.Errorf("invalid startServer options: %w", err)
+	}
+
+	server := newServer(opts.Faults, opts.Seed)
+	server.rt = mi.vu.Runtime()
+	server.logLimit = opts.LogLimit
+	for _, record := range rrs {
+		if _, err := server.AddRecord(record); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := server.listen(opts.Address); err != nil {
+		return nil, fmt.Errorf("starting the DNS server failed; reason: %w", err)
+	}
+
+	if mi.vu.State() != nil {
+		context.AfterFunc(mi.vu.Context(), server.Close)
+	}
+
+	return server, nil
+}
+
+// newServer creates a Server without records, which does not listen yet, injecting
+// the faults using a random source of the seed.
+func newServer(faults serverFaults, seed int64) *Server {
+	return &Server{
+		records:  make(map[string][]dns.RR),
+		rcodes:   make(map[string]int),
+		queries:  make(map[string]int),
+		logLimit: defaultServerLogLimit,
+		faults:   faults,
+		rng:      rand.New(rand.NewSource(seed)), //nolint:gosec
+	}
+}
+
+// serverName returns the key the records and response codes of the name are held
+// by, regardless of the name's case.
+func serverName(name string) string {
+	return strings.ToLower(dns.Fqdn(name))
+}
+
+// AddRecord adds a record, in presentation format, to the server.
+func (s *Server) AddRecord(record string) (*Server, error) {
+	rr, err := parseRecord(record)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name := serverName(rr.Header().Name)
+	s.records[name] = append(s.records[name], rr)
+
+	return s, nil
+}
+
+// RemoveRecords removes the records of the record type for the name from the
+// server, or all the records for the name if no record type is provided.
+func (s *Server) RemoveRecords(name string, recordType sobek.Value) (*Server, error) {
+	var rrtype uint16
+	if !common.IsNullish(recordType) {
+		t, ok := dns.StringToType[strings.ToUpper(recordType.String())]
+		if !ok {
+			return nil, fmt.Errorf("unknown record type %q", recordType.String())
+		}
+
+		rrtype = t
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := serverName(name)
+	if rrtype == 0 {
+		delete(s.records, key)
+		return s, nil
+	}
+
+	kept := make([]dns.RR, 0, len(s.records[key]))
+	for _, rr := range s.records[key] {
+		if rr.Header().Rrtype != rrtype {
+			kept = append(kept, rr)
+		}
+	}
+
+	s.records[key] = kept
+
+	return s, nil
+}
+
+// SetRcode instructs the server to answer all the queries for the name with the
+// response code, e.g. "SERVFAIL", regardless of its records. Setting "NOERROR"
+// restores answering out of the records.
+func (s *Server) SetRcode(name, rcode string) (*Server, error) {
+	value, ok := dns.StringToRcode[strings.ToUpper(rcode)]
+	if !ok {
+		return nil, fmt.Errorf("unknown response code %q", rcode)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if value == dns.RcodeSuccess {
+		delete(s.rcodes, serverName(name))
+	} else {
+		s.rcodes[serverName(name)] = value
+	}
+
+	return s, nil
+}
+
+// Queries returns the number of queries the server received for the name, or
+// overall if no name is provided.
+func (s *Server) Queries(name sobek.Value) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if common.IsNullish(name) {
+		return s.total
+	}
+
+	return s.queries[serverName(name.String())]
+}
+
+// Log returns the queries the server received, oldest first, up to its log limit,
+// beyond which the oldest queries are forgotten.
+func (s *Server) Log() []serverQuery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]serverQuery{}, s.log...)
+}
+
+// ClearLog forgets the queries the server received so far, along with their
+// numbers.
+func (s *Server) ClearLog() *Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.log = nil
+	s.queries = make(map[string]int)
+	s.total = 0
+
+	return s
+}
+
+// logQuery records the query, received from the source address, in the log.
+func (s *Server) logQuery(req *dns.Msg, source net.Addr) {
+	entry := serverQuery{
+		ID:       req.Id,
+		Source:   source.String(),
+		Protocol: "tcp",
+		Flags:    messageFlags(req),
+		Time:     time.Now().UnixMilli(),
+	}
+
+	if isUDP(source) {
+		entry.Protocol = "udp"
+	}
+
+	if len(req.Question) > 0 {
+		entry.Name = strings.TrimSuffix(req.Question[0].Name, ".")
+		entry.Type = dns.TypeToString[req.Question[0].Qtype]
+		entry.Class = dns.ClassToString[req.Question[0].Qclass]
+	}
+
+	if opt := req.IsEdns0(); opt != nil {
+		entry.EDNS = newMessageEDNS(opt)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.logLimit == 0 {
+		return
+	}
+
+	// Reslicing, rather than shifting, keeps forgetting the oldest query cheap, as
+	// appending eventually moves the log to a new array
+	if len(s.log) == s.logLimit {
+		s.log = s.log[1:]
+	}
+
+	s.log = append(s.log, entry)
+}
+
+// Close stops the server. Closing a closed server does nothing.
+func (s *Server) Close() {
+	s.closeOnce.Do(func() {
+		for _, server := range s.servers {
+			_ = server.Shutdown()
+		}
+	})
+}
+
+// listen starts serving on the address, over UDP and, on the same port, TCP.
+func (s *Server) listen(address string) error {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	// When the system picks the port, it might be available over UDP but not TCP
+	const attempts = 5
+
+	for attempt := 1; ; attempt++ {
+		packetConn, err := net.ListenPacket("udp", address)
+		if err != nil {
+			return err
+		}
+
+		udpPort := packetConn.LocalAddr().(*net.UDPAddr).Port //nolint:forcetypeassert
+
+		listener, err := net.Listen("tcp", net.JoinHostPort(host, fmt.Sprint(udpPort)))
+		if err != nil {
+			_ = packetConn.Close()
+
+			if port == "0" && attempt < attempts {
+				continue
+			}
+
+			return err
+		}
+
+		s.Address = net.JoinHostPort(host, fmt.Sprint(udpPort))
+		s.servers = []*dns.Server{
+			{PacketConn: packetConn, Handler: s},
+			{Listener: listener, Handler: s},
+		}
+
+		break
+	}
+
+	// Shutting servers down before they started would leave them serving
+	var started sync.WaitGroup
+	for _, server := range s.servers {
+		started.Add(1)
+		server.NotifyStartedFunc = started.Done
+
+		go func(server *dns.Server) {
+			_ = server.ActivateAndServe()
+		}(server)
+	}
+
+	started.Wait()
+
+	return nil
+}
+
+// ServeDNS implements the dns.Handler interface.
+func (s *Server) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	s.logQuery(req, w.RemoteAddr())
+
+	response := s.answer(req)
+
+	drop, delay := s.injectFaults(response, isUDP(w.RemoteAddr()))
+	if drop {
+		// Over TCP, dropping the response amounts to closing the connection
+		if !isUDP(w.RemoteAddr()) {
+			_ = w.Close()
+		}
+
+		return
+	}
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+
+	_ = w.WriteMsg(response)
+}
+
+// answer builds the response to the query out of the server's records, following
+// CNAME records within them.
+func (s *Server) answer(req *dns.Msg) *dns.Msg {
+	response := new(dns.Msg)
+	response.SetReply(req)
+	response.Authoritative = true
+
+	if len(req.Question) != 1 {
+		response.Rcode = dns.RcodeFormatError
+		return response
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	question := req.Question[0]
+	name := serverName(question.Name)
+
+	s.total++
+	s.queries[name]++
+
+	if rcode, ok := s.rcodes[name]; ok {
+		response.Rcode = rcode
+		return response
+	}
+
+	if len(s.records[name]) == 0 {
+		response.Rcode = dns.RcodeNameError
+		return response
+	}
+
+	for depth := 0; len(s.records[name]) > 0; depth++ {
+		var cname *dns.CNAME
+
+		found := false
+		for _, rr := range s.records[name] {
+			switch {
+			case rr.Header().Rrtype == question.Qtype || question.Qtype == dns.TypeANY:
+				response.Answer = append(response.Answer, dns.Copy(rr))
+				found = true
+			case rr.Header().Rrtype == dns.TypeCNAME:
+				cname, _ = rr.(*dns.CNAME)
+			}
+		}
+
+		if found || cname == nil {
+			break
+		}
+
+		if depth == maxServerCNAMEChain {
+			response.Rcode = dns.RcodeServerFailure
+			break
+		}
+
+		response.Answer = append(response.Answer, dns.Copy(cname))
+		name = serverName(cname.Target)
+	}
+
+	return response
+}
@@ -0,0 +1,226 @@
+package dns
+
+import (
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+)
+
+func TestServer(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Resolving against a started server should succeed", func(t *testing.T) {
+		t.Parallel()
+
+		runtime, err := newConfiguredRuntime(t)
+		require.NoError(t, err)
+
+		runtime.MoveToVUContext(&lib.State{
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+			Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+			Samples:        make(chan metrics.SampleContainer, 1024),
+		})
+
+		_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+			const server = dns.startServer(["k6.test. 60 IN A 203.0.113.1"]);
+
+			const ips = await dns.resolve("k6.test", "A", server.address);
+			if (ips.length !== 1 || ips[0] !== "203.0.113.1") {
+				throw "expected k6.test to resolve to 203.0.113.1; got " + ips;
+			}
+
+			server.removeRecords("k6.test", "A").setRcode("k6.test", "SERVFAIL");
+
+			const result = await dns.resolve("k6.test", "A", server.address, { throw: false });
+			if (result.rcode !== "SERVFAIL") {
+				throw "expected a SERVFAIL response code; got " + result.rcode;
+			}
+
+			if (server.queries("k6.test") !== 2) {
+				throw "expected the server to receive 2 queries; got " + server.queries("k6.test");
+			}
+
+			server.close();
+		`))
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("Injecting faults into a started server should succeed", func(t *testing.T) {
+		t.Parallel()
+
+		runtime, err := newConfiguredRuntime(t)
+		require.NoError(t, err)
+
+		runtime.MoveToVUContext(&lib.State{
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+			Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+			Samples:        make(chan metrics.SampleContainer, 1024),
+		})
+
+		_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+			const server = dns.startServer(["k6.test. 60 IN A 203.0.113.1"], { rcode: "SERVFAIL", seed: 1 });
+
+			const result = await dns.resolve("k6.test", "A", server.address, { throw: false });
+			if (result.rcode !== "SERVFAIL") {
+				throw "expected a SERVFAIL response code; got " + result.rcode;
+			}
+
+			if (result.server.address !== server.address || result.server.protocol !== "udp" || result.server.attempt !== 1) {
+				throw "expected the server to be described as having answered; got " + JSON.stringify(result.server);
+			}
+
+			server.setFaults({ dropRate: 1 });
+
+			const dropped = await dns.resolve("k6.test", "A", server.address, { throw: false, timeout: "50ms" });
+			if (dropped.error === null) {
+				throw "expected the resolution of a dropped query to fail";
+			}
+
+			if (dropped.server !== null) {
+				throw "expected no server to be described as having answered; got " + JSON.stringify(dropped.server);
+			}
+
+			server.setFaults();
+
+			const ips = await dns.resolve("k6.test", "A", server.address);
+			if (ips.length !== 1) {
+				throw "expected k6.test to resolve once faults are cleared; got " + ips;
+			}
+
+			server.close();
+		`))
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("Reading the log of a started server should succeed", func(t *testing.T) {
+		t.Parallel()
+
+		runtime, err := newConfiguredRuntime(t)
+		require.NoError(t, err)
+
+		runtime.MoveToVUContext(&lib.State{
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+			Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+			Samples:        make(chan metrics.SampleContainer, 1024),
+		})
+
+		_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+			const server = dns.startServer(["k6.test. 60 IN A 203.0.113.1"]);
+
+			await dns.resolve("k6.test", "A", server.address, { clientSubnet: "198.51.100.0/24" });
+
+			const log = server.log();
+			if (log.length !== 1 || log[0].name !== "k6.test" || log[0].type !== "A" || log[0].protocol !== "udp") {
+				throw "expected the log to hold the A query for k6.test; got " + JSON.stringify(log);
+			}
+
+			if (log[0].edns === null || log[0].edns.options.length !== 1 || log[0].edns.options[0].name !== "ECS") {
+				throw "expected the logged query to hold a client subnet option; got " + JSON.stringify(log[0].edns);
+			}
+
+			if (server.clearLog().log().length !== 0 || server.queries() !== 0) {
+				throw "expected the log to be cleared";
+			}
+
+			server.close();
+		`))
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("Starting a server with invalid records should fail", func(t *testing.T) {
+		t.Parallel()
+
+		runtime, err := newConfiguredRuntime(t)
+		require.NoError(t, err)
+
+		_, err = runtime.RunOnEventLoop(`dns.startServer(["k6.test. 60 IN A not-an-ip"]);`)
+
+		assert.Error(t, err)
+	})
+}
+
+func TestServer_answer(t *testing.T) {
+	t.Parallel()
+
+	server := newServer(noServerFaults(), 1)
+	for _, record := range []string{
+		"k6.test. 60 IN A 203.0.113.1",
+		"k6.test. 60 IN A 203.0.113.11",
+		"k6.test. 60 IN TXT \"v=spf1 -all\"",
+		"www.k6.test. 60 IN CNAME cdn.k6.test.",
+		"cdn.k6.test. 60 IN CNAME K6.test.",
+		"loop.k6.test. 60 IN CNAME loop.k6.test.",
+	} {
+		_, err := server.AddRecord(record)
+		require.NoError(t, err)
+	}
+
+	tests := []struct {
+		name        string
+		query       string
+		qtype       uint16
+		wantRcode   int
+		wantAnswers int
+	}{
+		{name: "existing records", query: "k6.test.", qtype: dns.TypeA, wantAnswers: 2},
+		{name: "existing records regardless of case", query: "K6.Test.", qtype: dns.TypeTXT, wantAnswers: 1},
+		{name: "any records", query: "k6.test.", qtype: dns.TypeANY, wantAnswers: 3},
+		{name: "no records of the type", query: "k6.test.", qtype: dns.TypeAAAA},
+		{name: "CNAME chain", query: "www.k6.test.", qtype: dns.TypeA, wantAnswers: 4},
+		{name: "CNAME record", query: "www.k6.test.", qtype: dns.TypeCNAME, wantAnswers: 1},
+		{name: "CNAME loop", query: "loop.k6.test.", qtype: dns.TypeA, wantRcode: dns.RcodeServerFailure, wantAnswers: 8},
+		{name: "non-existing name", query: "missing.k6.test.", qtype: dns.TypeA, wantRcode: dns.RcodeNameError},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := new(dns.Msg)
+			req.SetQuestion(tt.query, tt.qtype)
+
+			response := server.answer(req)
+
+			assert.Equal(t, tt.wantRcode, response.Rcode)
+			assert.Len(t, response.Answer, tt.wantAnswers)
+			assert.True(t, response.Authoritative)
+		})
+	}
+}
+
+func TestServer_logQuery(t *testing.T) {
+	t.Parallel()
+
+	server := newServer(noServerFaults(), 1)
+	server.logLimit = 2
+
+	for _, name := range []string{"a.k6.test.", "b.k6.test.", "c.k6.test."} {
+		req := new(dns.Msg)
+		req.SetQuestion(name, dns.TypeAAAA)
+		req.SetEdns0(1232, true)
+
+		server.logQuery(req, &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 53000})
+	}
+
+	log := server.Log()
+	require.Len(t, log, 2)
+
+	assert.Equal(t, "b.k6.test", log[0].Name)
+	assert.Equal(t, "c.k6.test", log[1].Name)
+	assert.Equal(t, "AAAA", log[1].Type)
+	assert.Equal(t, "IN", log[1].Class)
+	assert.Equal(t, "tcp", log[1].Protocol)
+	assert.Equal(t, "127.0.0.1:53000", log[1].Source)
+	assert.True(t, log[1].Flags["rd"])
+	assert.Equal(t, &messageEDNS{UDPSize: 1232, DNSSECOK: true, Options: []ednsOption{}}, log[1].EDNS)
+}
@@ -0,0 +1,177 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.k6.io/k6/event"
+	"go.k6.io/k6/js/modules"
+)
+
+// testEndGracePeriod bounds the time the queries still outstanding at the end of
+// the test are waited for, before being cancelled.
+const testEndGracePeriod = 5 * time.Second
+
+// outstandingQueries tracks the queries being sent by a client, so that they can
+// be waited for, and cancelled, when it shuts down. Its zero value is ready to use.
+type outstandingQueries struct {
+	mu      sync.Mutex
+	nextID  uint64
+	cancels map[uint64]context.CancelFunc
+
+	// idle is closed, and reset, once no query is outstanding anymore, if anyone
+	// waits for it
+	idle chan struct{}
+}
+
+// track accounts for a query being sent with the context, until the returned
+// function is called. The query is sent with the returned context, which is
+// cancelled along with the others by cancelAll.
+func (q *outstandingQueries) track(ctx context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.cancels == nil {
+		q.cancels = make(map[uint64]context.CancelFunc)
+	}
+
+	id := q.nextID
+	q.nextID++
+	q.cancels[id] = cancel
+
+	return ctx, func() {
+		cancel()
+
+		q.mu.Lock()
+		defer q.mu.Unlock()
+
+		delete(q.cancels, id)
+		if len(q.cancels) == 0 && q.idle != nil {
+			close(q.idle)
+			q.idle = nil
+		}
+	}
+}
+
+// drained returns a channel which is closed once no query is outstanding.
+func (q *outstandingQueries) drained() <-chan struct{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.cancels) == 0 {
+		done := make(chan struct{})
+		close(done)
+
+		return done
+	}
+
+	if q.idle == nil {
+		q.idle = make(chan struct{})
+	}
+
+	return q.idle
+}
+
+// cancelAll cancels the outstanding queries.
+func (q *outstandingQueries) cancelAll() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, cancel := range q.cancels {
+		cancel()
+	}
+}
+
+// Shutdown waits for the outstanding queries of the client to complete, until the
+// context is done, at which point those which did not are cancelled, and then
+// closes its persistent connections as Close does. As with Close, the client
+// remains usable afterwards.
+func (r *Client) Shutdown(ctx context.Context) error {
+	select {
+	case <-r.outstanding.drained():
+		return r.Close()
+	case <-ctx.Done():
+	}
+
+	r.outstanding.cancelAll()
+
+	// Closing the connections fails the queries outstanding over them at once
+	err := r.Close()
+	<-r.outstanding.drained()
+
+	return err
+}
+
+// register registers the client of a VU, so that it is shut down along with the
+// other resources of the module at the end of the test.
+func (rm *RootModule) register(vu modules.VU, client *Client) {
+	rm.clientsMu.Lock()
+	rm.clients = append(rm.clients, client)
+	rm.clientsMu.Unlock()
+
+	events := vu.Events().Global
+	if events == nil || vu.InitEnv() == nil {
+		return
+	}
+
+	logger := vu.InitEnv().Logger
+	rm.testEndOnce.Do(func() {
+		id, ch := events.Subscribe(event.TestEnd)
+
+		go func() {
+			evt, ok := <-ch
+			if !ok {
+				return
+			}
+
+			rm.shutdown(logger)
+			evt.Done()
+			events.Unsubscribe(id)
+		}()
+	})
+}
+
+// shutdown gracefully tears the module down at the end of the test: it waits for
+// the queries outstanding in all the VUs for up to testEndGracePeriod, cancels
+// those still outstanding then, closes all the connections to nameservers and the
+// files traffic is recorded to, and logs the exchanges which went unlogged since
+// the last one.
+func (rm *RootModule) shutdown(logger logrus.FieldLogger) {
+	ctx, cancel := context.WithTimeout(context.Background(), testEndGracePeriod)
+	defer cancel()
+
+	rm.clientsMu.Lock()
+	clients := append([]*Client(nil), rm.clients...)
+	rm.clientsMu.Unlock()
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(clients))
+	for i, client := range clients {
+		wg.Add(1)
+		go func(i int, client *Client) {
+			defer wg.Done()
+
+			errs[i] = client.Shutdown(ctx)
+		}(i, client)
+	}
+	wg.Wait()
+
+	errs = append(errs, rm.sharedConns.close())
+	if err := errors.Join(errs...); err != nil {
+		logger.WithError(err).Warn("closing the connections to DNS nameservers failed")
+	}
+
+	if err := rm.recorders.close(); err != nil {
+		logger.WithError(err).Warn("closing the files DNS traffic is recorded to failed")
+	}
+
+	if suppressed := rm.debugLog.suppressed.Swap(0); suppressed > 0 {
+		logger.WithField("suppressed", suppressed).
+			Info("DNS exchanges sampled by the debug option went unlogged, as they exceeded its rate limit")
+	}
+}
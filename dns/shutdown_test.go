@@ -0,0 +1,164 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/event"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/compiler"
+	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+)
+
+func TestClient_Shutdown(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		answerAfter time.Duration
+		gracePeriod time.Duration
+		wantErr     bool
+	}{
+		{
+			name:        "Outstanding queries should complete within the grace period",
+			answerAfter: 100 * time.Millisecond,
+			gracePeriod: 5 * time.Second,
+		},
+		{
+			name:        "Queries outstanding past the grace period should be cancelled",
+			answerAfter: time.Minute,
+			gracePeriod: 50 * time.Millisecond,
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			received := make(chan struct{}, 1)
+			released := make(chan struct{})
+
+			handler := dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+				received <- struct{}{}
+
+				select {
+				case <-time.After(tt.answerAfter):
+				case <-released:
+					return
+				}
+
+				response := new(dns.Msg)
+				response.SetReply(req)
+				_ = w.WriteMsg(response)
+			})
+
+			conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+			require.NoError(t, err)
+
+			server := &dns.Server{PacketConn: conn, Handler: handler}
+			go func() { _ = server.ActivateAndServe() }()
+			t.Cleanup(func() { _ = server.Shutdown() })
+
+			// Cleanups run in reverse order, so the handler returns before the server
+			// shuts down
+			t.Cleanup(func() { close(released) })
+
+			nameserver, err := parseNameserverAddr(conn.LocalAddr().String())
+			require.NoError(t, err)
+
+			client := NewDNSClient()
+
+			queryErr := make(chan error, 1)
+			go func() {
+				_, err := client.Query(context.Background(), "k6.test", "A", nameserver, QueryOptions{Timeout: time.Minute})
+				queryErr <- err
+			}()
+
+			<-received
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.gracePeriod)
+			defer cancel()
+
+			start := time.Now()
+			require.NoError(t, client.Shutdown(ctx))
+			assert.Less(t, time.Since(start), 5*time.Second)
+
+			// Shutting down returns once the query did
+			select {
+			case err := <-queryErr:
+				if tt.wantErr {
+					assert.ErrorIs(t, err, context.Canceled)
+				} else {
+					assert.NoError(t, err)
+				}
+			default:
+				t.Fatal("expected the query to have returned")
+			}
+		})
+	}
+}
+
+func TestRootModule_shutdown(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "dns.dnstap")
+
+	rootModule := New()
+
+	runtime := modulestest.NewRuntime(t)
+	events := event.NewEventSystem(10, runtime.VU.InitEnv().Logger)
+	runtime.VU.EventsField = common.Events{Global: events, Local: event.NewEventSystem(10, runtime.VU.InitEnv().Logger)}
+	require.NoError(t, runtime.SetupModuleSystem(
+		map[string]interface{}{"k6/x/dns": rootModule},
+		nil,
+		compiler.New(runtime.VU.InitEnv().Logger),
+	))
+
+	_, err := runtime.VU.Runtime().RunString(initGlobals + fmt.Sprintf(`
+		const server = dns.startServer(["k6.test. 60 IN A 203.0.113.1"]);
+
+		dns.recordTraffic(%q);
+	`, path))
+	require.NoError(t, err)
+
+	runtime.MoveToVUContext(&lib.State{
+		BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+		Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+		Samples:        make(chan metrics.SampleContainer, 1024),
+	})
+
+	_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+		await dns.resolve("k6.test", "A", server.address, { protocol: "tcp" });
+	`))
+	require.NoError(t, err)
+	require.Equal(t, int64(1), rootModule.openConnections.Load())
+
+	// The connections are closed at the end of the test, even though the VU's
+	// context is not done
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, events.Emit(&event.Event{Type: event.TestEnd})(ctx))
+
+	assert.Eventually(t, func() bool {
+		return rootModule.openConnections.Load() == 0
+	}, time.Second, 10*time.Millisecond)
+
+	data, err := os.ReadFile(path) //nolint:forbidigo
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(data, frameStreamsStart()))
+	assert.True(t, bytes.HasSuffix(data, frameStreamsStop()))
+}
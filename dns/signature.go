@@ -0,0 +1,287 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grafana/sobek"
+	"github.com/miekg/dns"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/promises"
+	"go.k6.io/k6/metrics"
+)
+
+// defaultSignatureTypes holds the record types whose signatures are checked by
+// signatureExpiry, unless specified otherwise.
+var defaultSignatureTypes = []uint16{dns.TypeSOA, dns.TypeDNSKEY, dns.TypeNS} //nolint:gochecknoglobals
+
+// serialHalfRange is half the range of the 32 bits RRSIG timestamps, as defined
+// by the serial number arithmetic of RFC 1982.
+const serialHalfRange = 1 << 31
+
+// rrsigTime converts a timestamp of an RRSIG record to the time it designates.
+//
+// As RRSIG timestamps wrap around every 136 years, RFC 4034 specifies they are to
+// be interpreted as the time closest to the current time.
+func rrsigTime(timestamp uint32, now time.Time) time.Time {
+	seconds := int64(timestamp)
+	for seconds-now.Unix() > serialHalfRange {
+		seconds -= 2 * serialHalfRange
+	}
+
+	for now.Unix()-seconds > serialHalfRange {
+		seconds += 2 * serialHalfRange
+	}
+
+	return time.Unix(seconds, 0).UTC()
+}
+
+// signatureExpiryResult is the object the signatureExpiry function resolves to.
+type signatureExpiryResult struct {
+	// Zone holds the zone the signatures were queried for.
+	Zone string `js:"zone"`
+
+	// Expiration holds the time the earliest signature expires at, as a Unix
+	// timestamp in milliseconds, or nil if no signature was found.
+	Expiration *float64 `js:"expiration"`
+
+	// ExpiresIn holds the duration until the earliest signature expires, in
+	// milliseconds, or nil if no signature was found. It is negative for
+	// signatures which already expired.
+	ExpiresIn *float64 `js:"expiresIn"`
+
+	// Signatures holds the signatures found, by order of expiration.
+	Signatures []signatureResult `js:"signatures"`
+
+	// Unsigned holds the record types for which no signature was found.
+	Unsigned []string `js:"unsigned"`
+}
+
+// signatureResult describes an RRSIG record.
+type signatureResult struct {
+	// Type holds the record type the signature covers, e.g. "SOA".
+	Type string `js:"type"`
+
+	// Signer holds the name of the zone which signed the records.
+	Signer string `js:"signer"`
+
+	// KeyTag holds the key tag of the DNSKEY which signed the records.
+	KeyTag uint16 `js:"keyTag"`
+
+	// Algorithm holds the name of the algorithm of the signature, e.g. "ECDSAP256SHA256".
+	Algorithm string `js:"algorithm"`
+
+	// Inception holds the time the signature is valid from, as a Unix timestamp in milliseconds.
+	Inception float64 `js:"inception"`
+
+	// Expiration holds the time the signature expires at, as a Unix timestamp in milliseconds.
+	Expiration float64 `js:"expiration"`
+
+	// ExpiresIn holds the duration until the signature expires, in milliseconds.
+	ExpiresIn float64 `js:"expiresIn"`
+}
+
+// newSignatureExpiryResult creates a signatureExpiryResult out of the responses
+// received for each of the record types of the zone.
+func newSignatureExpiryResult(zone string, types []uint16, responses []*dns.Msg, now time.Time) *signatureExpiryResult {
+	result := &signatureExpiryResult{
+		Zone:       zone,
+		Signatures: []signatureResult{},
+		Unsigned:   []string{},
+	}
+
+	for i, qtype := range types {
+		signed := false
+
+		for _, rr := range responses[i].Answer {
+			rrsig, ok := rr.(*dns.RRSIG)
+			if !ok || rrsig.TypeCovered != qtype {
+				continue
+			}
+
+			inception := rrsigTime(rrsig.Inception, now)
+			expiration := rrsigTime(rrsig.Expiration, now)
+
+			result.Signatures = append(result.Signatures, signatureResult{
+				Type:       dns.TypeToString[qtype],
+				Signer:     strings.TrimSuffix(rrsig.SignerName, "."),
+				KeyTag:     rrsig.KeyTag,
+				Algorithm:  dns.AlgorithmToString[rrsig.Algorithm],
+				Inception:  float64(inception.UnixMilli()),
+				Expiration: float64(expiration.UnixMilli()),
+				ExpiresIn:  float64(expiration.Sub(now)) / float64(time.Millisecond),
+			})
+			signed = true
+		}
+
+		if !signed {
+			result.Unsigned = append(result.Unsigned, dns.TypeToString[qtype])
+		}
+	}
+
+	// Order the signatures by expiration, the earliest first
+	sort.SliceStable(result.Signatures, func(i, j int) bool {
+		return result.Signatures[i].Expiration < result.Signatures[j].Expiration
+	})
+
+	if len(result.Signatures) > 0 {
+		result.Expiration = &result.Signatures[0].Expiration
+		result.ExpiresIn = &result.Signatures[0].ExpiresIn
+	}
+
+	return result
+}
+
+// signatureExpiryOptions holds the options that can be passed to the signatureExpiry function.
+type signatureExpiryOptions struct {
+	resolveOptions
+
+	// Types holds the record types whose signatures are checked.
+	Types []uint16
+}
+
+// parseSignatureExpiryOptions parses the options object passed to the signatureExpiry
+// function.
+//
+// It accepts the same options as the resolve function, along with the types option.
+func parseSignatureExpiryOptions(rt *sobek.Runtime, value sobek.Value) (signatureExpiryOptions, error) {
+	resolveOpts, err := parseResolveOptions(rt, value)
+	if err != nil {
+		return signatureExpiryOptions{}, err
+	}
+
+	opts := signatureExpiryOptions{
+		resolveOptions: resolveOpts,
+		Types:          defaultSignatureTypes,
+	}
+
+	if common.IsNullish(value) {
+		return opts, nil
+	}
+
+	v := value.ToObject(rt).Get("types")
+	if common.IsNullish(v) {
+		return opts, nil
+	}
+
+	var types []string
+	if err := rt.ExportTo(v, &types); err != nil || len(types) == 0 {
+		return opts, fmt.Errorf("types option must be a non-empty array of record types; got %v instead", v)
+	}
+
+	opts.Types = make([]uint16, 0, len(types))
+	for _, name := range types {
+		qtype, ok := dns.StringToType[strings.ToUpper(name)]
+		if !ok {
+			return opts, fmt.Errorf("unknown record type %q", name)
+		}
+
+		opts.Types = append(opts.Types, qtype)
+	}
+
+	return opts, nil
+}
+
+// SignatureExpiry queries the nameserver for the signatures of the zone's records,
+// and resolves to the earliest time one of them expires at.
+func (mi *ModuleInstance) SignatureExpiry(zone, nameserverAddr, options sobek.Value) *sobek.Promise {
+	promise, resolve, reject := promises.New(mi.vu)
+
+	if mi.vu.State() == nil {
+		reject(errors.New("signatureExpiry can not be used in the init context"))
+		return promise
+	}
+
+	zoneStr, err := exportDomainName(mi.vu.Runtime(), zone, "zone")
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	nameserver, err := mi.exportNameserver(nameserverAddr)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	opts, err := parseSignatureExpiryOptions(mi.vu.Runtime(), options)
+	if err != nil {
+		reject(fmt.Errorf("invalid signatureExpiry options: %w", err))
+		return promise
+	}
+
+	ctx, cancel, err := mi.withAbortSignal(opts.Signal)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer cancel()
+
+		responses := make([]*dns.Msg, 0, len(opts.Types))
+		for _, qtype := range opts.Types {
+			// Request the signatures by setting the DNSSEC OK bit
+			msg := new(dns.Msg)
+			msg.SetQuestion(dns.Fqdn(zoneStr), qtype)
+			msg.RecursionDesired = !opts.NoRecursion
+			msg.SetEdns0(dns.DefaultMsgSize, true)
+
+			response, _, err := mi.sendMessage(ctx, msg, nameserver, opts.QueryOptions)
+			if err != nil {
+				reject(err)
+				return
+			}
+
+			if response.msg.Rcode != dns.RcodeSuccess {
+				reject(withNameserver(
+					newDNSError(response.msg.Rcode, "querying the "+dns.TypeToString[qtype]+" records failed"),
+					nameserver,
+				))
+				return
+			}
+
+			responses = append(responses, response.msg)
+		}
+
+		result := newSignatureExpiryResult(zoneStr, opts.Types, responses, time.Now())
+		mi.emitSignatureExpiryMetrics(mi.vu.Context(), zoneStr, nameserver, result)
+
+		resolve(result)
+	}()
+
+	return promise
+}
+
+// emitSignatureExpiryMetrics emits the remaining validity of the earliest signature
+// of the zone, if any.
+func (mi *ModuleInstance) emitSignatureExpiryMetrics(
+	ctx context.Context,
+	zone string,
+	nameserver Nameserver,
+	result *signatureExpiryResult,
+) {
+	if result.ExpiresIn == nil {
+		return
+	}
+
+	state := mi.vu.State()
+
+	tags := state.Tags.GetCurrentValues().Tags
+	tags = tags.With("zone", zone)
+	tags = tags.With("nameserver", nameserver.Addr())
+
+	metrics.PushIfNotDone(ctx, state.Samples, metrics.Sample{
+		TimeSeries: metrics.TimeSeries{
+			Metric: mi.metrics.DNSSignatureExpiry,
+			Tags:   tags,
+		},
+		Time:     time.Now(),
+		Value:    *result.ExpiresIn,
+		Metadata: nil,
+	})
+}
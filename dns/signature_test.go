@@ -0,0 +1,103 @@
+package dns
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_rrsigTime(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		now       time.Time
+		timestamp uint32
+		want      time.Time
+	}{
+		{
+			name:      "past",
+			now:       now,
+			timestamp: uint32(now.Add(-time.Hour).Unix()),
+			want:      now.Add(-time.Hour),
+		},
+		{
+			name:      "future",
+			now:       now,
+			timestamp: uint32(now.Add(30 * 24 * time.Hour).Unix()),
+			want:      now.Add(30 * 24 * time.Hour),
+		},
+		{
+			name:      "after wrapping around",
+			now:       time.Date(2106, 2, 1, 0, 0, 0, 0, time.UTC),
+			timestamp: uint32(time.Date(2106, 3, 1, 0, 0, 0, 0, time.UTC).Unix()),
+			want:      time.Date(2106, 3, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.want, rrsigTime(tt.timestamp, tt.now))
+		})
+	}
+}
+
+func Test_newSignatureExpiryResult(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	rrsig := func(record string) dns.RR {
+		t.Helper()
+
+		rr, err := dns.NewRR(record)
+		require.NoError(t, err)
+
+		return rr
+	}
+
+	responses := []*dns.Msg{
+		{Answer: []dns.RR{
+			rrsig("k6.io. 60 IN SOA ns.k6.io. hostmaster.k6.io. 1 60 60 60 60"),
+			rrsig("k6.io. 60 IN RRSIG SOA 13 2 60 20240615000000 20240525000000 1234 k6.io. dGVzdA=="),
+		}},
+		{Answer: []dns.RR{
+			rrsig("k6.io. 60 IN RRSIG DNSKEY 13 2 60 20240603000000 20240525000000 4321 k6.io. dGVzdA=="),
+			rrsig("k6.io. 60 IN RRSIG SOA 13 2 60 20240601000000 20240525000000 1234 k6.io. dGVzdA=="),
+		}},
+		{Answer: []dns.RR{
+			rrsig("k6.io. 60 IN NS ns.k6.io."),
+		}},
+	}
+
+	got := newSignatureExpiryResult("k6.io", defaultSignatureTypes, responses, now)
+
+	assert.Equal(t, "k6.io", got.Zone)
+	assert.Equal(t, []string{"NS"}, got.Unsigned)
+
+	require.Len(t, got.Signatures, 2)
+	assert.Equal(t, "DNSKEY", got.Signatures[0].Type)
+	assert.Equal(t, uint16(4321), got.Signatures[0].KeyTag)
+	assert.Equal(t, "ECDSAP256SHA256", got.Signatures[0].Algorithm)
+	assert.Equal(t, "k6.io", got.Signatures[0].Signer)
+	assert.Equal(t, "SOA", got.Signatures[1].Type)
+
+	require.NotNil(t, got.ExpiresIn)
+	assert.InDelta(t, float64(2*24*time.Hour/time.Millisecond), *got.ExpiresIn, 0.001)
+	require.NotNil(t, got.Expiration)
+	assert.InDelta(t, float64(now.Add(2*24*time.Hour).UnixMilli()), *got.Expiration, 0.001)
+
+	unsigned := newSignatureExpiryResult("k6.io", []uint16{dns.TypeNS}, responses[2:], now)
+	assert.Nil(t, unsigned.Expiration)
+	assert.Nil(t, unsigned.ExpiresIn)
+	assert.Empty(t, unsigned.Signatures)
+}
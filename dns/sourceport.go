@@ -0,0 +1,131 @@
+package dns
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/miekg/dns"
+)
+
+// Source port modes of the queries sent over UDP, as set by the sourcePort option.
+const (
+	// sourcePortOS has the operating system assign an ephemeral port to each query.
+	sourcePortOS = "os"
+
+	// sourcePortRandom has each query sent from a port picked uniformly at random.
+	sourcePortRandom = "random"
+)
+
+// minRandomSourcePort is the lowest port picked at random, excluding the
+// well-known ports.
+const minRandomSourcePort = 1024
+
+// maxRandomSourcePortAttempts bounds the number of ports picked at random a query
+// attempts to bind to, as they might already be in use.
+const maxRandomSourcePortAttempts = 16
+
+// parseSourcePort parses the value of the sourcePort option: either "os", "random",
+// or a fixed port. It returns the fixed port, if any, and whether the port is picked
+// at random.
+func parseSourcePort(value interface{}) (int, bool, error) {
+	switch v := value.(type) {
+	case string:
+		switch strings.ToLower(v) {
+		case sourcePortOS:
+			return 0, false, nil
+		case sourcePortRandom:
+			return 0, true, nil
+		}
+	case int64:
+		if v >= 1 && v <= 65535 {
+			return int(v), false, nil
+		}
+	case float64:
+		if v >= 1 && v <= 65535 && v == float64(int(v)) {
+			return int(v), false, nil
+		}
+	}
+
+	return 0, false, fmt.Errorf(
+		"sourcePort option must be one of 'os', 'random', or a port between 1 and 65535; got %v instead", value,
+	)
+}
+
+// dialSourcePort opens a connection to the nameserver over UDP from the source
+// port the options tell: the fixed one, one picked at random, or one assigned by
+// the operating system.
+func (r *Client) dialSourcePort(ctx context.Context, nameserver Nameserver, opts QueryOptions) (*dns.Conn, error) {
+	client := r.client
+
+	if opts.SourcePort > 0 {
+		client.Dialer = &net.Dialer{LocalAddr: &net.UDPAddr{Port: opts.SourcePort}}
+	}
+
+	if !opts.RandomizeSourcePort {
+		return client.DialContext(ctx, nameserver.Addr())
+	}
+
+	var err error
+	for attempt := 0; attempt < maxRandomSourcePortAttempts; attempt++ {
+		var port int
+		if port, err = randomSourcePort(); err != nil {
+			return nil, err
+		}
+
+		client.Dialer = &net.Dialer{LocalAddr: &net.UDPAddr{Port: port}}
+
+		var conn *dns.Conn
+		if conn, err = client.DialContext(ctx, nameserver.Addr()); !errors.Is(err, syscall.EADDRINUSE) {
+			return conn, err
+		}
+	}
+
+	return nil, fmt.Errorf("no port picked at random was available; last attempt: %w", err)
+}
+
+// randomSourcePort returns a port picked uniformly at random from
+// minRandomSourcePort up, using a cryptographically secure
+// source of randomness as port randomization is a defense against spoofing.
+func randomSourcePort() (int, error) {
+	var b [2]byte
+
+	// Rejecting the ports below the bound keeps the distribution uniform
+	for {
+		if _, err := rand.Read(b[:]); err != nil {
+			return 0, err
+		}
+
+		if port := int(binary.BigEndian.Uint16(b[:])); port >= minRandomSourcePort {
+			return port, nil
+		}
+	}
+}
+
+// portSet holds a set of ports, and counts them. It is safe for concurrent use.
+type portSet struct {
+	words [65536 / 64]atomic.Uint64
+	count atomic.Int64
+}
+
+// add adds the port to the set, and returns the number of distinct ports it holds.
+func (s *portSet) add(port uint16) int64 {
+	word, bit := &s.words[port/64], uint64(1)<<(port%64)
+
+	for {
+		old := word.Load()
+		if old&bit != 0 {
+			return s.count.Load()
+		}
+
+		if word.CompareAndSwap(old, old|bit) {
+			return s.count.Add(1)
+		}
+	}
+}
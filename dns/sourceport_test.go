@@ -0,0 +1,118 @@
+package dns
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+)
+
+func Test_parseSourcePort(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		value      interface{}
+		wantPort   int
+		wantRandom bool
+		wantErr    bool
+	}{
+		{name: "operating system", value: "os"},
+		{name: "random", value: "Random", wantRandom: true},
+		{name: "fixed", value: int64(5353), wantPort: 5353},
+		{name: "fixed as a float", value: float64(5353), wantPort: 5353},
+		{name: "zero", value: int64(0), wantErr: true},
+		{name: "too large", value: int64(65536), wantErr: true},
+		{name: "fractional", value: 53.5, wantErr: true},
+		{name: "unknown mode", value: "sequential", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			port, random, err := parseSourcePort(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantPort, port)
+			assert.Equal(t, tt.wantRandom, random)
+		})
+	}
+}
+
+func Test_portSet(t *testing.T) {
+	t.Parallel()
+
+	var ports portSet
+	assert.Equal(t, int64(1), ports.add(53))
+	assert.Equal(t, int64(1), ports.add(53))
+	assert.Equal(t, int64(2), ports.add(65535))
+	assert.Equal(t, int64(3), ports.add(0))
+	assert.Equal(t, int64(3), ports.add(65535))
+}
+
+func TestModuleInstance_Resolve_sourcePort(t *testing.T) {
+	t.Parallel()
+
+	// Pick a port which is likely to remain available
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	require.NoError(t, conn.Close())
+
+	runtime, err := newConfiguredRuntime(t)
+	require.NoError(t, err)
+	require.NoError(t, runtime.VU.Runtime().Set("port", port))
+
+	_, err = runtime.VU.Runtime().RunString(`
+		const server = dns.startServer(["k6.test. 60 IN A 203.0.113.1"]);
+	`)
+	require.NoError(t, err)
+
+	samples := make(chan metrics.SampleContainer, 1024)
+	runtime.MoveToVUContext(&lib.State{
+		BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+		Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+		Samples:        samples,
+	})
+
+	_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+		for (let i = 0; i < 2; i++) {
+			await dns.resolve("k6.test", "A", server.address, { sourcePort: port });
+		}
+
+		for (let i = 0; i < 3; i++) {
+			await dns.resolve("k6.test", "A", server.address, { sourcePort: "random" });
+		}
+
+		const ports = server.log().map((query) => Number(query.source.split(":").pop()));
+		if (ports[0] !== port || ports[1] !== port) {
+			throw new Error("expected the queries to be sent from port " + port + "; got " + ports);
+		}
+
+		if (ports.slice(2).some((p) => p < 1024 || p === port)) {
+			throw new Error("unexpected random ports: " + ports);
+		}
+	`))
+	require.NoError(t, err)
+
+	var values []float64
+	for len(samples) > 0 {
+		for _, sample := range (<-samples).GetSamples() {
+			if sample.Metric.Name == "dns_source_ports" {
+				values = append(values, sample.Value)
+			}
+		}
+	}
+
+	assert.Equal(t, []float64{1, 1, 2, 3, 4}, values)
+}
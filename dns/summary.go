@@ -0,0 +1,210 @@
+package dns
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// noResponseRcode is the key under which resolutions that did not receive any
+// response from the nameserver are accounted for in the summary's response codes.
+const noResponseRcode = "NORESPONSE"
+
+// summary aggregates the outcome of the DNS resolutions performed by all the
+// VUs, so that DNS-specific results can be reported at the end of the test.
+//
+// It is safe for concurrent use.
+type summary struct {
+	mu sync.Mutex
+
+	// total holds the aggregated results of all the resolutions.
+	total *resolutionsSummary
+
+	// nameservers holds the aggregated results of the resolutions, per nameserver address.
+	nameservers map[string]*resolutionsSummary
+}
+
+// resolutionsSummary holds the aggregated results of a set of DNS resolutions.
+type resolutionsSummary struct {
+	queries uint64
+	failed  uint64
+	rcodes  map[string]uint64
+
+	// latency holds the durations of the resolutions, in a histogram whose size
+	// does not grow with the number of resolutions over long tests.
+	latency latencyHistogram
+
+	// datagrams and lost hold the number of query attempts sent over UDP, and
+	// the number of those which went unanswered.
+	datagrams uint64
+	lost      uint64
+}
+
+func newSummary() *summary {
+	return &summary{
+		total:       newResolutionsSummary(),
+		nameservers: make(map[string]*resolutionsSummary),
+	}
+}
+
+func newResolutionsSummary() *resolutionsSummary {
+	return &resolutionsSummary{
+		rcodes: make(map[string]uint64),
+	}
+}
+
+// record accounts for a DNS resolution performed against the given nameserver.
+func (s *summary) record(nameserver string, duration time.Duration, response *Response, failed bool) {
+	rcode := noResponseRcode
+	if response != nil && response.Rcode != "" {
+		rcode = response.Rcode
+	}
+
+	var datagrams, lost uint64
+	if response != nil && response.Protocol == protocolUDP {
+		datagrams = uint64(response.Attempts) //nolint:gosec // attempts are never negative
+		lost = uint64(response.Timeouts)      //nolint:gosec // timeouts are never negative
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	perNameserver, ok := s.nameservers[nameserver]
+	if !ok {
+		perNameserver = newResolutionsSummary()
+		s.nameservers[nameserver] = perNameserver
+	}
+
+	s.total.record(duration, rcode, failed, datagrams, lost)
+	perNameserver.record(duration, rcode, failed, datagrams, lost)
+}
+
+func (rs *resolutionsSummary) record(duration time.Duration, rcode string, failed bool, datagrams, lost uint64) {
+	rs.queries++
+	rs.datagrams += datagrams
+	rs.lost += lost
+	if failed {
+		rs.failed++
+	}
+
+	rs.rcodes[rcode]++
+	rs.latency.record(duration)
+}
+
+// export returns the summary as a plain structure, suitable for being handed
+// over to the JS runtime.
+func (s *summary) export() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	nameservers := make(map[string]interface{}, len(s.nameservers))
+	for addr, rs := range s.nameservers {
+		nameservers[addr] = rs.export()
+	}
+
+	exported := s.total.export()
+	exported["nameservers"] = nameservers
+
+	return exported
+}
+
+func (rs *resolutionsSummary) export() map[string]interface{} {
+	rcodes := make(map[string]interface{}, len(rs.rcodes))
+	for rcode, count := range rs.rcodes {
+		rcodes[rcode] = count
+	}
+
+	var errorRate float64
+	if rs.queries > 0 {
+		errorRate = float64(rs.failed) / float64(rs.queries)
+	}
+
+	var packetLoss float64
+	if rs.datagrams > 0 {
+		packetLoss = float64(rs.lost) / float64(rs.datagrams)
+	}
+
+	latency := map[string]interface{}{}
+	if rs.latency.count > 0 {
+		latency = map[string]interface{}{
+			"min":   rs.latency.min,
+			"avg":   rs.latency.mean(),
+			"med":   rs.latency.percentile(50),
+			"max":   rs.latency.max,
+			"p(90)": rs.latency.percentile(90),
+			"p(95)": rs.latency.percentile(95),
+			"p(99)": rs.latency.percentile(99),
+		}
+	}
+
+	return map[string]interface{}{
+		"queries":    rs.queries,
+		"failed":     rs.failed,
+		"errorRate":  errorRate,
+		"packetLoss": packetLoss,
+		"rcodes":     rcodes,
+		"latency":    latency,
+	}
+}
+
+// text returns a human-readable rendering of the summary, in the spirit of
+// k6's own end-of-test summary.
+func (s *summary) text() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var sb strings.Builder
+
+	sb.WriteString("     DNS\n")
+	s.total.writeText(&sb, "all nameservers")
+
+	addrs := make([]string, 0, len(s.nameservers))
+	for addr := range s.nameservers {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+
+	for _, addr := range addrs {
+		s.nameservers[addr].writeText(&sb, addr)
+	}
+
+	return sb.String()
+}
+
+func (rs *resolutionsSummary) writeText(sb *strings.Builder, title string) {
+	var errorRate float64
+	if rs.queries > 0 {
+		errorRate = float64(rs.failed) / float64(rs.queries) * 100
+	}
+
+	fmt.Fprintf(sb, "     %s: queries=%d failed=%d (%.2f%%)\n", title, rs.queries, rs.failed, errorRate)
+
+	rcodes := make([]string, 0, len(rs.rcodes))
+	for rcode := range rs.rcodes {
+		rcodes = append(rcodes, rcode)
+	}
+	sort.Strings(rcodes)
+
+	for _, rcode := range rcodes {
+		fmt.Fprintf(sb, "       rcode %s: %d\n", rcode, rs.rcodes[rcode])
+	}
+
+	if rs.latency.count > 0 {
+		fmt.Fprintf(
+			sb,
+			"       latency: avg=%.2fms min=%.2fms med=%.2fms max=%.2fms p(90)=%.2fms p(95)=%.2fms p(99)=%.2fms\n",
+			rs.latency.mean(), rs.latency.min, rs.latency.percentile(50), rs.latency.max,
+			rs.latency.percentile(90), rs.latency.percentile(95), rs.latency.percentile(99),
+		)
+	}
+
+	if rs.datagrams > 0 {
+		fmt.Fprintf(
+			sb,
+			"       packet loss: %.2f%% (%d of %d datagrams unanswered)\n",
+			float64(rs.lost)/float64(rs.datagrams)*100, rs.lost, rs.datagrams,
+		)
+	}
+}
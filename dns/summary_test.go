@@ -0,0 +1,96 @@
+package dns
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+)
+
+func TestSummary(t *testing.T) {
+	t.Parallel()
+
+	s := newSummary()
+	s.record("1.1.1.1:53", 10*time.Millisecond, &Response{Rcode: "NOERROR"}, false)
+	s.record("1.1.1.1:53", 30*time.Millisecond, &Response{Rcode: "NXDOMAIN"}, true)
+	s.record("8.8.8.8:53", 20*time.Millisecond, nil, true)
+	s.record("9.9.9.9:53", 40*time.Millisecond, &Response{Rcode: "NOERROR", Protocol: protocolUDP, Attempts: 4, Timeouts: 3}, false)
+	s.record("9.9.9.9:53", 50*time.Millisecond, &Response{Rcode: "NOERROR", Protocol: protocolTCP, Attempts: 2, Timeouts: 1}, false)
+
+	exported := s.export()
+
+	assert.Equal(t, uint64(5), exported["queries"])
+	assert.Equal(t, uint64(2), exported["failed"])
+	assert.InDelta(t, 2.0/5.0, exported["errorRate"], 0.0001)
+	assert.Equal(t, 0.75, exported["packetLoss"])
+	assert.Equal(t, map[string]interface{}{
+		"NOERROR":       uint64(3),
+		"NXDOMAIN":      uint64(1),
+		noResponseRcode: uint64(1),
+	}, exported["rcodes"])
+
+	nameservers, ok := exported["nameservers"].(map[string]interface{})
+	require.True(t, ok)
+	require.Len(t, nameservers, 3)
+
+	cloudflare, ok := nameservers["1.1.1.1:53"].(map[string]interface{})
+	require.True(t, ok)
+	assert.Equal(t, uint64(2), cloudflare["queries"])
+	assert.Equal(t, 0.5, cloudflare["errorRate"])
+	assert.Equal(t, 0.0, cloudflare["packetLoss"])
+
+	latency, ok := cloudflare["latency"].(map[string]interface{})
+	require.True(t, ok)
+	assert.Equal(t, 10.0, latency["min"])
+	assert.Equal(t, 30.0, latency["max"])
+	assert.Equal(t, 20.0, latency["avg"])
+	assert.InEpsilon(t, 10.0, latency["med"], 0.01)
+	assert.InEpsilon(t, 30.0, latency["p(99)"], 0.01)
+
+	assert.Contains(t, s.text(), "8.8.8.8:53: queries=1 failed=1 (100.00%)")
+	assert.Contains(t, s.text(), "packet loss: 75.00% (3 of 4 datagrams unanswered)")
+}
+
+func TestModuleInstance_Resolve_packetLoss(t *testing.T) {
+	t.Parallel()
+
+	runtime, err := newConfiguredRuntime(t)
+	require.NoError(t, err)
+
+	samples := make(chan metrics.SampleContainer, 1024)
+	runtime.MoveToVUContext(&lib.State{
+		BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+		Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+		Samples:        samples,
+	})
+
+	_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+		const server = dns.startServer(["k6.test. 60 IN A 203.0.113.1"]);
+
+		server.setFaults({ dropRate: 1 });
+		await dns.resolve("k6.test", "A", server.address, { throw: false, timeout: "50ms", retries: 2 });
+
+		server.setFaults();
+		await dns.resolve("k6.test", "A", server.address, { retries: 2 });
+
+		// Packet loss is only tracked over UDP
+		await dns.resolve("k6.test", "A", server.address, { protocol: "tcp" });
+
+		server.close();
+	`))
+	require.NoError(t, err)
+
+	var values []float64
+	for len(samples) > 0 {
+		for _, sample := range (<-samples).GetSamples() {
+			if sample.Metric.Name == "dns_packet_loss" {
+				values = append(values, sample.Value)
+			}
+		}
+	}
+
+	assert.Equal(t, []float64{1, 1, 1, 0}, values)
+}
@@ -0,0 +1,103 @@
+package dns
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"net"
+)
+
+// TLSInfo describes the TLS connection a query was sent over, with DoT and DoH,
+// for encrypted DNS endpoints to be audited.
+type TLSInfo struct {
+	// Version holds the negotiated version of TLS, e.g. "TLS 1.3".
+	Version string `js:"version"`
+
+	// CipherSuite holds the name of the negotiated cipher suite, e.g.
+	// "TLS_AES_128_GCM_SHA256".
+	CipherSuite string `js:"cipherSuite"`
+
+	// ServerName holds the name the certificate of the nameserver was verified
+	// against.
+	ServerName string `js:"serverName"`
+
+	// ALPN holds the negotiated application protocol, e.g. "h2", if any.
+	ALPN string `js:"alpn"`
+
+	// Resumed indicates whether the session was resumed from an earlier one.
+	Resumed bool `js:"resumed"`
+
+	// Certificates holds the certificate chain presented by the nameserver, leaf
+	// certificate first.
+	Certificates []CertificateInfo `js:"certificates"`
+}
+
+// CertificateInfo summarizes a certificate presented by a nameserver.
+type CertificateInfo struct {
+	// Subject and Issuer hold the distinguished names of the subject and issuer
+	// of the certificate.
+	Subject string `js:"subject"`
+	Issuer  string `js:"issuer"`
+
+	// NotBefore and NotAfter hold the validity period of the certificate, in
+	// milliseconds since the Unix epoch.
+	NotBefore int64 `js:"notBefore"`
+	NotAfter  int64 `js:"notAfter"`
+
+	// DNSNames and IPAddresses hold the subject alternative names of the
+	// certificate.
+	DNSNames    []string `js:"dnsNames"`
+	IPAddresses []string `js:"ipAddresses"`
+
+	// Fingerprint holds the hex encoded SHA-256 digest of the certificate.
+	Fingerprint string `js:"fingerprint"`
+}
+
+// newTLSInfo creates a TLSInfo out of the state of a TLS connection.
+func newTLSInfo(state tls.ConnectionState) *TLSInfo {
+	info := &TLSInfo{
+		Version:      tls.VersionName(state.Version),
+		CipherSuite:  tls.CipherSuiteName(state.CipherSuite),
+		ServerName:   state.ServerName,
+		ALPN:         state.NegotiatedProtocol,
+		Resumed:      state.DidResume,
+		Certificates: make([]CertificateInfo, 0, len(state.PeerCertificates)),
+	}
+
+	for _, cert := range state.PeerCertificates {
+		fingerprint := sha256.Sum256(cert.Raw)
+
+		summary := CertificateInfo{
+			Subject:     cert.Subject.String(),
+			Issuer:      cert.Issuer.String(),
+			NotBefore:   cert.NotBefore.UnixMilli(),
+			NotAfter:    cert.NotAfter.UnixMilli(),
+			DNSNames:    append([]string{}, cert.DNSNames...),
+			IPAddresses: make([]string, 0, len(cert.IPAddresses)),
+			Fingerprint: hex.EncodeToString(fingerprint[:]),
+		}
+
+		for _, ip := range cert.IPAddresses {
+			summary.IPAddresses = append(summary.IPAddresses, ip.String())
+		}
+
+		info.Certificates = append(info.Certificates, summary)
+	}
+
+	return info
+}
+
+// connTLSInfo returns the TLSInfo of the connection, or nil if it is not secured
+// with TLS.
+func connTLSInfo(conn net.Conn) *TLSInfo {
+	if counted, ok := conn.(*countedConn); ok {
+		conn = counted.Conn
+	}
+
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return nil
+	}
+
+	return newTLSInfo(tlsConn.ConnectionState())
+}
@@ -0,0 +1,532 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/grafana/sobek"
+	"github.com/miekg/dns"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/promises"
+)
+
+// defaultMaxTraceQueries is the default maximum number of queries sent while
+// tracing the resolution of a query.
+const defaultMaxTraceQueries = 32
+
+// rootNameservers holds the IPv4 addresses of the root nameservers, from which
+// traces start unless instructed otherwise.
+var rootNameservers = []string{ //nolint:gochecknoglobals
+	"198.41.0.4",     // a.root-servers.net
+	"170.247.170.2",  // b.root-servers.net
+	"192.33.4.12",    // c.root-servers.net
+	"199.7.91.13",    // d.root-servers.net
+	"192.203.230.10", // e.root-servers.net
+	"192.5.5.241",    // f.root-servers.net
+	"192.112.36.4",   // g.root-servers.net
+	"198.97.190.53",  // h.root-servers.net
+	"192.36.148.17",  // i.root-servers.net
+	"192.58.128.30",  // j.root-servers.net
+	"193.0.14.129",   // k.root-servers.net
+	"199.7.83.42",    // l.root-servers.net
+	"202.12.27.33",   // m.root-servers.net
+}
+
+// TraceOptions holds the options influencing how a query is traced.
+type TraceOptions struct {
+	QueryOptions
+
+	// Roots holds the nameservers traces start from. When empty, the root
+	// nameservers are used.
+	Roots []Nameserver
+
+	// QNameMinimization indicates whether the names queried while following
+	// referrals should be minimized, as described by RFC 9156, so that each
+	// nameserver is only sent the labels it needs to refer to the next zone.
+	QNameMinimization bool
+
+	// MaxQueries holds the maximum number of queries sent while tracing. When
+	// zero, defaultMaxTraceQueries is used.
+	MaxQueries int
+}
+
+// TraceStep describes a query sent while tracing the resolution of a query.
+type TraceStep struct {
+	// Zone holds the zone the queried nameserver was referred to for, e.g. "."
+	// for the root nameservers.
+	Zone string
+
+	// Nameserver holds the nameserver which was queried.
+	Nameserver Nameserver
+
+	// Query holds the name which was queried, which differs from the traced
+	// query when minimized.
+	Query string
+
+	// Type holds the record type which was queried.
+	Type string
+
+	// Duration holds the duration of the query.
+	Duration time.Duration
+
+	// Response holds the response to the query, if the nameserver was queried.
+	Response *Response
+
+	// Err holds the error the query failed with, if any.
+	Err error
+
+	// Referral holds the zone the nameserver referred to in its response, if any.
+	Referral string
+}
+
+// Trace iteratively resolves a domain name, starting from the root nameservers
+// and following the referrals they return, as a recursive resolver would. It
+// returns the final Response along with the queries which were sent to get it.
+//
+// Queries are sent with the recursion desired flag cleared. The nameservers a
+// zone is referred to are queried on the same port as the referring nameserver,
+// using the addresses found in the referral's additional section, or the system's
+// default resolver when the referral holds no glue.
+func (r *Client) Trace(
+	ctx context.Context,
+	query, recordType string,
+	opts TraceOptions,
+) (*Response, []TraceStep, error) {
+	servers := opts.Roots
+	if len(servers) == 0 {
+		for _, root := range rootNameservers {
+			servers = append(servers, Nameserver{IP: net.ParseIP(root), Port: 53})
+		}
+	}
+
+	maxQueries := opts.MaxQueries
+	if maxQueries <= 0 {
+		maxQueries = defaultMaxTraceQueries
+	}
+
+	queryOpts := opts.QueryOptions
+	queryOpts.NoRecursion = true
+	queryOpts.FollowCNAME = false
+
+	target := dns.Fqdn(query)
+	labels := dns.SplitDomainName(target)
+	zone := "."
+	depth := 0
+
+	var steps []TraceStep
+	for len(steps) < maxQueries {
+		// When minimizing, only reveal one more label than the current zone holds
+		qname, qtype := target, recordType
+		if opts.QNameMinimization {
+			depth++
+			if depth < len(labels) {
+				qname = dns.Fqdn(strings.Join(labels[len(labels)-depth:], "."))
+				qtype = RecordTypeA.String()
+			}
+		}
+
+		response, server, err := r.queryAny(ctx, zone, qname, qtype, servers, queryOpts, &steps, maxQueries)
+		if err != nil {
+			return response, steps, err
+		}
+
+		child, targets := findReferral(response.msg, zone, qname)
+		if child != "" {
+			steps[len(steps)-1].Referral = child
+
+			servers, err = r.referredNameservers(ctx, response.msg, targets, server.Port)
+			if err != nil {
+				return response, steps, fmt.Errorf("following the referral to %s failed: %w", child, err)
+			}
+
+			zone = child
+			depth = dns.CountLabel(child)
+
+			continue
+		}
+
+		// A minimized name which is not a zone cut: reveal one more label
+		if qname != target {
+			continue
+		}
+
+		return response, steps, nil
+	}
+
+	return nil, steps, fmt.Errorf("tracing %s exceeded the maximum of %d queries", query, maxQueries)
+}
+
+// queryAny sends the query to each of the servers in turn, until one of them
+// responds with anything but a SERVFAIL or REFUSED response code. Each query is
+// recorded in steps.
+func (r *Client) queryAny(
+	ctx context.Context,
+	zone, qname, qtype string,
+	servers []Nameserver,
+	opts QueryOptions,
+	steps *[]TraceStep,
+	maxQueries int,
+) (*Response, Nameserver, error) {
+	var errs []error
+	for _, server := range servers {
+		if len(*steps) >= maxQueries || ctx.Err() != nil {
+			break
+		}
+
+		start := time.Now()
+		response, err := r.query(ctx, strings.TrimSuffix(qname, "."), qtype, server, opts)
+		*steps = append(*steps, TraceStep{
+			Zone:       zone,
+			Nameserver: server,
+			Query:      strings.TrimSuffix(qname, "."),
+			Type:       qtype,
+			Duration:   time.Since(start),
+			Response:   response,
+			Err:        err,
+		})
+
+		// Only move on to the next server if this one did not respond, or is
+		// unable to answer, as a lame nameserver would be
+		if response != nil && response.msg != nil &&
+			response.msg.Rcode != dns.RcodeServerFailure && response.msg.Rcode != dns.RcodeRefused {
+			return response, server, err
+		}
+
+		errs = append(errs, err)
+	}
+
+	if len(errs) == 0 {
+		errs = append(errs, ctx.Err())
+	}
+
+	return nil, Nameserver{}, errors.Join(errs...)
+}
+
+// findReferral returns the zone the message refers to, along with the names of
+// its nameservers, if the message is a referral from zone to a zone holding qname.
+func findReferral(msg *dns.Msg, zone, qname string) (string, []string) {
+	if len(msg.Answer) > 0 {
+		return "", nil
+	}
+
+	var child string
+	var targets []string
+	for _, rr := range msg.Ns {
+		ns, ok := rr.(*dns.NS)
+		if !ok {
+			continue
+		}
+
+		owner := dns.Fqdn(ns.Hdr.Name)
+		if strings.EqualFold(owner, zone) || !dns.IsSubDomain(zone, owner) || !dns.IsSubDomain(owner, qname) {
+			continue
+		}
+
+		if child == "" {
+			child = owner
+		}
+
+		if strings.EqualFold(owner, child) {
+			targets = append(targets, ns.Ns)
+		}
+	}
+
+	return child, targets
+}
+
+// referredNameservers returns the nameservers named by targets, on the given port.
+//
+// Their addresses are taken from the glue records found in the message's additional
+// section, IPv4 addresses first. If the message holds no glue, the targets are
+// looked up using the system's default resolver instead.
+func (r *Client) referredNameservers(
+	ctx context.Context,
+	msg *dns.Msg,
+	targets []string,
+	port uint16,
+) ([]Nameserver, error) {
+	isTarget := make(map[string]bool, len(targets))
+	for _, target := range targets {
+		isTarget[strings.ToLower(dns.Fqdn(target))] = true
+	}
+
+	var v4, v6 []Nameserver
+	for _, rr := range msg.Extra {
+		if !isTarget[strings.ToLower(rr.Header().Name)] {
+			continue
+		}
+
+		switch t := rr.(type) {
+		case *dns.A:
+			v4 = append(v4, Nameserver{IP: t.A, Port: port})
+		case *dns.AAAA:
+			v6 = append(v6, Nameserver{IP: t.AAAA, Port: port})
+		}
+	}
+
+	if servers := append(v4, v6...); len(servers) > 0 { //nolint:gocritic
+		return servers, nil
+	}
+
+	var servers []Nameserver
+	var errs []error
+	for _, target := range targets {
+		ips, err := r.Lookup(ctx, strings.TrimSuffix(target, "."), LookupOptions{Order: AddressOrderIPv4First})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		for _, ip := range ips {
+			servers = append(servers, Nameserver{IP: net.ParseIP(ip), Port: port})
+		}
+	}
+
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("no address found for the nameservers: %w", errors.Join(errs...))
+	}
+
+	return servers, nil
+}
+
+// traceResult is the object the trace function resolves to.
+type traceResult struct {
+	// Name holds the domain name which was traced.
+	Name string `js:"name"`
+
+	// Type holds the record type which was traced.
+	Type string `js:"type"`
+
+	// Answers holds the data of the records of the traced type found in the answer
+	// section of the final response.
+	Answers []string `js:"answers"`
+
+	// Error holds the error the trace failed with, if any.
+	Error *Error `js:"error"`
+
+	// Rcode holds the name of the response code of the final response, if any.
+	Rcode string `js:"rcode"`
+
+	// Flags holds the header flags of the final response, by name.
+	Flags map[string]bool `js:"flags"`
+
+	// RTT holds the duration of the whole trace, in milliseconds.
+	RTT float64 `js:"rtt"`
+
+	// Steps holds the queries which were sent while tracing, in order.
+	Steps []traceStep `js:"steps"`
+}
+
+// traceStep describes a query sent while tracing.
+type traceStep struct {
+	// Zone holds the zone the queried nameserver was referred to for.
+	Zone string `js:"zone"`
+
+	// Nameserver holds the address of the queried nameserver.
+	Nameserver string `js:"nameserver"`
+
+	// Query holds the name which was queried.
+	Query string `js:"query"`
+
+	// Type holds the record type which was queried.
+	Type string `js:"type"`
+
+	// Rcode holds the name of the response code returned by the nameserver, if any.
+	Rcode string `js:"rcode"`
+
+	// Referral holds the zone the nameserver referred to, if any.
+	Referral string `js:"referral"`
+
+	// Error holds the error the query failed with, if any.
+	Error *Error `js:"error"`
+
+	// RTT holds the duration of the query, in milliseconds.
+	RTT float64 `js:"rtt"`
+}
+
+// newTraceResult creates a traceResult out of the outcome of a trace.
+func newTraceResult(
+	name, recordType string,
+	response *Response,
+	traceErr error,
+	duration time.Duration,
+	steps []TraceStep,
+) *traceResult {
+	final := newResolveResult(name, recordType, response, traceErr, duration)
+
+	result := &traceResult{
+		Name:    final.Name,
+		Type:    final.Type,
+		Answers: final.Answers,
+		Error:   final.Error,
+		Rcode:   final.Rcode,
+		Flags:   final.Flags,
+		RTT:     final.RTT,
+		Steps:   make([]traceStep, 0, len(steps)),
+	}
+
+	for _, step := range steps {
+		var rcode string
+		if step.Response != nil {
+			rcode = step.Response.Rcode
+		}
+
+		result.Steps = append(result.Steps, traceStep{
+			Zone:       step.Zone,
+			Nameserver: step.Nameserver.Addr(),
+			Query:      step.Query,
+			Type:       step.Type,
+			Rcode:      rcode,
+			Referral:   step.Referral,
+			Error:      asError(step.Err),
+			RTT:        float64(step.Duration) / float64(time.Millisecond),
+		})
+	}
+
+	return result
+}
+
+// traceOptions holds the options that can be passed to the trace function.
+type traceOptions struct {
+	resolveOptions
+
+	TraceOptions
+}
+
+// parseTraceOptions parses the options object passed to the trace function.
+//
+// It accepts the same options as the resolve function, along with the roots,
+// qnameMinimization and maxQueries options.
+func parseTraceOptions(rt *sobek.Runtime, value sobek.Value) (traceOptions, error) {
+	resolveOpts, err := parseResolveOptions(rt, value)
+	if err != nil {
+		return traceOptions{}, err
+	}
+
+	opts := traceOptions{
+		resolveOptions: resolveOpts,
+		TraceOptions:   TraceOptions{QueryOptions: resolveOpts.QueryOptions},
+	}
+
+	if common.IsNullish(value) {
+		return opts, nil
+	}
+
+	params := value.ToObject(rt)
+
+	if v := params.Get("roots"); !common.IsNullish(v) {
+		var roots []string
+		if err := rt.ExportTo(v, &roots); err != nil || len(roots) == 0 {
+			return opts, fmt.Errorf("roots option must be a non-empty array of nameserver addresses; got %v instead", v)
+		}
+
+		for _, root := range roots {
+			nameserver, err := parseNameserverAddr(root)
+			if err != nil {
+				return opts, fmt.Errorf("roots option is invalid; reason: %w", err)
+			}
+
+			opts.Roots = append(opts.Roots, nameserver)
+		}
+	}
+
+	if v := params.Get("qnameMinimization"); !common.IsNullish(v) {
+		opts.QNameMinimization = v.ToBoolean()
+	}
+
+	if v := params.Get("maxQueries"); !common.IsNullish(v) {
+		var maxQueries int64
+		if err := rt.ExportTo(v, &maxQueries); err != nil || maxQueries < 1 {
+			return opts, fmt.Errorf("maxQueries option must be a strictly positive integer; got %v instead", v)
+		}
+
+		opts.MaxQueries = int(maxQueries)
+	}
+
+	return opts, nil
+}
+
+// Trace iteratively resolves a domain name, starting from the root nameservers,
+// and resolves to the final answer along with the queries sent to get it.
+func (mi *ModuleInstance) Trace(query, recordType, options sobek.Value) *sobek.Promise {
+	promise, resolve, reject := promises.New(mi.vu)
+
+	if mi.vu.State() == nil {
+		reject(errors.New("trace can not be used in the init context"))
+		return promise
+	}
+
+	queryStr, err := exportDomainName(mi.vu.Runtime(), query, "query")
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	var recordTypeStr string
+	if err := mi.vu.Runtime().ExportTo(recordType, &recordTypeStr); err != nil {
+		reject(fmt.Errorf("recordType must be a string; got %v instead", recordType))
+		return promise
+	}
+
+	opts, err := parseTraceOptions(mi.vu.Runtime(), options)
+	if err != nil {
+		reject(fmt.Errorf("invalid trace options: %w", err))
+		return promise
+	}
+
+	ctx, cancel, err := mi.withAbortSignal(opts.Signal)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer cancel()
+
+		// Start timer for the trace
+		startTime := time.Now()
+
+		// Trace the query
+		response, steps, traceErr := mi.dnsClient.Trace(ctx, queryStr, recordTypeStr, opts.TraceOptions)
+
+		// Stop the timer for the trace
+		duration := time.Since(startTime)
+
+		// Emit the metrics of each query which was sent, regardless of the result
+		mi.emitTraceMetrics(steps)
+
+		result := newTraceResult(queryStr, recordTypeStr, response, traceErr, duration, steps)
+
+		// Handle the trace failure only now that we have emitted the metrics
+		if traceErr != nil && opts.Throw {
+			reject(traceErr)
+			return
+		}
+
+		resolve(result)
+	}()
+
+	return promise
+}
+
+// emitTraceMetrics emits the metrics of each query sent while tracing, and accounts
+// for them in the end-of-test summary.
+func (mi *ModuleInstance) emitTraceMetrics(steps []TraceStep) {
+	for _, step := range steps {
+		mi.emitResolutionMetrics(
+			mi.vu.Context(),
+			step.Duration.Milliseconds(),
+			step.Query,
+			step.Type,
+			step.Nameserver,
+			step.Response,
+			step.Err,
+		)
+
+		mi.summary.record(step.Nameserver.Addr(), step.Duration, step.Response, step.Err != nil)
+	}
+}
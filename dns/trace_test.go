@@ -0,0 +1,151 @@
+package dns
+
+import (
+	"testing"
+
+	"github.com/grafana/sobek"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_findReferral(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		answer      []string
+		authority   []string
+		zone        string
+		qname       string
+		wantChild   string
+		wantTargets []string
+	}{
+		{
+			name:        "referral to a child zone",
+			authority:   []string{"io. 60 IN NS a.nic.io.", "io. 60 IN NS b.nic.io."},
+			zone:        ".",
+			qname:       "k6.io.",
+			wantChild:   "io.",
+			wantTargets: []string{"a.nic.io.", "b.nic.io."},
+		},
+		{
+			name:      "answer",
+			answer:    []string{"k6.io. 60 IN A 1.2.3.4"},
+			authority: []string{"k6.io. 60 IN NS ns.k6.io."},
+			zone:      "io.",
+			qname:     "k6.io.",
+		},
+		{
+			name:      "nameservers of the current zone",
+			authority: []string{"io. 60 IN NS a.nic.io."},
+			zone:      "io.",
+			qname:     "k6.io.",
+		},
+		{
+			name:      "nameservers of an unrelated zone",
+			authority: []string{"grafana.com. 60 IN NS ns.grafana.com."},
+			zone:      ".",
+			qname:     "k6.io.",
+		},
+		{
+			name:      "no data",
+			authority: []string{"k6.io. 60 IN SOA ns.k6.io. hostmaster.k6.io. 1 60 60 60 60"},
+			zone:      "k6.io.",
+			qname:     "k6.io.",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			msg := &dns.Msg{}
+			for _, record := range tt.answer {
+				rr, err := dns.NewRR(record)
+				require.NoError(t, err)
+				msg.Answer = append(msg.Answer, rr)
+			}
+
+			for _, record := range tt.authority {
+				rr, err := dns.NewRR(record)
+				require.NoError(t, err)
+				msg.Ns = append(msg.Ns, rr)
+			}
+
+			gotChild, gotTargets := findReferral(msg, tt.zone, tt.qname)
+			assert.Equal(t, tt.wantChild, gotChild)
+			assert.Equal(t, tt.wantTargets, gotTargets)
+		})
+	}
+}
+
+func Test_parseTraceOptions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		options string
+		check   func(t *testing.T, opts traceOptions)
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "undefined options",
+			options: `undefined`,
+			check: func(t *testing.T, opts traceOptions) {
+				assert.True(t, opts.Throw)
+				assert.Empty(t, opts.Roots)
+				assert.False(t, opts.QNameMinimization)
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "roots and qname minimization",
+			options: `({roots: ["192.0.2.1", "192.0.2.2:5353"], qnameMinimization: true, maxQueries: 8})`,
+			check: func(t *testing.T, opts traceOptions) {
+				require.Len(t, opts.Roots, 2)
+				assert.Equal(t, "192.0.2.1:53", opts.Roots[0].Addr())
+				assert.Equal(t, "192.0.2.2:5353", opts.Roots[1].Addr())
+				assert.True(t, opts.QNameMinimization)
+				assert.Equal(t, 8, opts.MaxQueries)
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "empty roots",
+			options: `({roots: []})`,
+			wantErr: assert.Error,
+		},
+		{
+			name:    "invalid root",
+			options: `({roots: ["a.root-servers.net"]})`,
+			wantErr: assert.Error,
+		},
+		{
+			name:    "zero max queries",
+			options: `({maxQueries: 0})`,
+			wantErr: assert.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rt := sobek.New()
+			value, err := rt.RunString(tt.options)
+			require.NoError(t, err)
+
+			got, err := parseTraceOptions(rt, value)
+			if !tt.wantErr(t, err) || err != nil {
+				return
+			}
+
+			tt.check(t, got)
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package dns
+
+import (
+	"context"
+
+	"go.k6.io/k6/lib"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// tracerName is the name of the tracer the spans of DNS operations are created
+// with, as the instrumentation scope of the spans.
+const tracerName = "k6/x/dns"
+
+// Attributes of the spans of DNS operations.
+const (
+	attrQuestionName = attribute.Key("dns.question.name")
+	attrQuestionType = attribute.Key("dns.question.type")
+	attrNameserver   = attribute.Key("dns.nameserver")
+	attrTransport    = attribute.Key("dns.transport")
+	attrRcode        = attribute.Key("dns.rcode")
+	attrAttempt      = attribute.Key("dns.attempt")
+	attrAnswers      = attribute.Key("dns.answers")
+)
+
+// startSpan starts a span for a DNS operation, as a child of the span of the
+// context if any, using the tracer provider of k6, which only records spans when
+// tracing is enabled, e.g. with the --traces-output flag.
+//
+// It does not interact with the runtime, and thus can be called from any goroutine.
+func (mi *ModuleInstance) startSpan(
+	ctx context.Context,
+	name string,
+	attrs ...attribute.KeyValue,
+) (context.Context, trace.Span) {
+	var provider lib.TracerProvider = noop.NewTracerProvider()
+	if state := mi.vu.State(); state != nil && state.TracerProvider != nil {
+		provider = state.TracerProvider
+	}
+
+	return provider.Tracer(tracerName).Start(
+		ctx,
+		name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+// endExchangeSpan records the outcome of an exchange with a nameserver in its span,
+// and ends it.
+func endExchangeSpan(span trace.Span, transport string, response *Response, err error) {
+	if response != nil {
+		if response.Protocol != "" {
+			transport = response.Protocol
+		}
+
+		if response.Rcode != "" {
+			span.SetAttributes(attrRcode.String(response.Rcode))
+		}
+
+		if response.Attempt > 0 {
+			span.SetAttributes(attrAttempt.Int(response.Attempt))
+		}
+
+		if err == nil {
+			span.SetAttributes(attrAnswers.Int(len(response.Answers)))
+		}
+	}
+
+	if transport == "" {
+		transport = protocolUDP
+	}
+
+	span.SetAttributes(attrTransport.String(transport))
+
+	endSpan(span, err)
+}
+
+// endSpan records the error the operation failed with in its span, if any, and
+// ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
@@ -0,0 +1,82 @@
+package dns
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestModuleInstance_startSpan(t *testing.T) {
+	t.Parallel()
+
+	runtime, err := newConfiguredRuntime(t)
+	require.NoError(t, err)
+
+	_, err = runtime.VU.Runtime().RunString(`
+		const server = dns.startServer(["k6.test. 60 IN A 203.0.113.1"]);
+	`)
+	require.NoError(t, err)
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	t.Cleanup(func() { _ = provider.Shutdown(context.Background()) })
+
+	runtime.MoveToVUContext(&lib.State{
+		BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+		Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+		Samples:        make(chan metrics.SampleContainer, 1024),
+		TracerProvider: provider,
+	})
+
+	_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+		await dns.resolve("k6.test", "A", server.address, { protocol: "tcp" });
+		await dns.resolve("missing.k6.test", "A", server.address, { throw: false });
+		await dns.sendMessage(dns.newMessage().addQuestion("k6.test", "A"), server.address);
+	`))
+	require.NoError(t, err)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 3)
+
+	attributes := func(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+		values := make(map[attribute.Key]attribute.Value, len(span.Attributes))
+		for _, attr := range span.Attributes {
+			values[attr.Key] = attr.Value
+		}
+
+		return values
+	}
+
+	resolved := spans[0]
+	assert.Equal(t, "dns.resolve", resolved.Name)
+	assert.Equal(t, trace.SpanKindClient, resolved.SpanKind)
+	assert.Equal(t, tracerName, resolved.InstrumentationLibrary.Name)
+	assert.Equal(t, codes.Unset, resolved.Status.Code)
+
+	attrs := attributes(resolved)
+	assert.Equal(t, "k6.test", attrs[attrQuestionName].AsString())
+	assert.Equal(t, "A", attrs[attrQuestionType].AsString())
+	assert.NotEmpty(t, attrs[attrNameserver].AsString())
+	assert.Equal(t, protocolTCP, attrs[attrTransport].AsString())
+	assert.Equal(t, "NOERROR", attrs[attrRcode].AsString())
+	assert.Equal(t, int64(1), attrs[attrAnswers].AsInt64())
+
+	failed := spans[1]
+	assert.Equal(t, codes.Error, failed.Status.Code)
+	assert.Equal(t, "NXDOMAIN", attributes(failed)[attrRcode].AsString())
+	assert.Equal(t, protocolUDP, attributes(failed)[attrTransport].AsString())
+	assert.NotEmpty(t, failed.Events, "the error should be recorded as an event")
+
+	sent := spans[2]
+	assert.Equal(t, "dns.sendMessage", sent.Name)
+	assert.Equal(t, "k6.test", attributes(sent)[attrQuestionName].AsString())
+}
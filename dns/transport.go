@@ -0,0 +1,128 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// Transport is the interface that wraps the Exchange method.
+//
+// Exchange sends the message to the nameserver, and returns the response to it,
+// until the context is done. It is responsible for matching the response to the
+// message, which it must not modify.
+type Transport interface {
+	Exchange(ctx context.Context, msg *dns.Msg, nameserver Nameserver) (*dns.Msg, error)
+}
+
+// TransportFunc is an adapter allowing the use of ordinary functions as
+// Transports.
+type TransportFunc func(ctx context.Context, msg *dns.Msg, nameserver Nameserver) (*dns.Msg, error)
+
+// Exchange calls f(ctx, msg, nameserver).
+func (f TransportFunc) Exchange(ctx context.Context, msg *dns.Msg, nameserver Nameserver) (*dns.Msg, error) {
+	return f(ctx, msg, nameserver)
+}
+
+// RegisterTransport registers the transport queries are sent over when their
+// Protocol option is the protocol, e.g. an in-house protocol, or a wrapper
+// instrumenting another client. Registering a protocol again replaces its
+// transport, and the built-in protocols can not be replaced.
+//
+// Queries sent over registered transports are retransmitted, signed, and
+// limited the same as the queries sent over the built-in protocols.
+func (r *Client) RegisterTransport(protocol string, transport Transport) error {
+	protocol = strings.ToLower(protocol)
+
+	switch protocol {
+	case "":
+		return errors.New("protocol must be provided")
+	case protocolUDP, protocolTCP, protocolDoT, protocolDoH:
+		return fmt.Errorf("the %s protocol is built in, and can not be replaced", protocol)
+	}
+
+	if transport == nil {
+		return errors.New("transport must be provided")
+	}
+
+	r.transportsMu.Lock()
+	defer r.transportsMu.Unlock()
+
+	if r.transports == nil {
+		r.transports = make(map[string]Transport)
+	}
+
+	r.transports[protocol] = transport
+
+	return nil
+}
+
+// transport returns the transport registered for the protocol, if any.
+func (r *Client) transport(protocol string) (Transport, bool) {
+	r.transportsMu.RLock()
+	defer r.transportsMu.RUnlock()
+
+	transport, ok := r.transports[strings.ToLower(protocol)]
+
+	return transport, ok
+}
+
+// exchangeTransport sends the packed message to the nameserver over the transport,
+// and retransmits it up to opts.Retries times if the attempts time out. It returns
+// the response along with its wire format, as packed again.
+func (r *Client) exchangeTransport(
+	ctx context.Context,
+	transport Transport,
+	packed []byte,
+	nameserver Nameserver,
+	opts QueryOptions,
+	result *Response,
+) (*dns.Msg, []byte, error) {
+	// The message is handed over as it was packed, and signed if need be
+	message := new(dns.Msg)
+	if err := message.Unpack(packed); err != nil {
+		return nil, nil, err
+	}
+
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = defaultAttemptTimeout
+	}
+
+	for {
+		result.Attempts++
+
+		attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+		response, err := transport.Exchange(attemptCtx, message, nameserver)
+		cancel()
+
+		if err == nil {
+			if response == nil || response.Id != message.Id {
+				return nil, nil, dns.ErrId
+			}
+
+			raw, err := response.Pack()
+			if err != nil {
+				return nil, nil, err
+			}
+
+			return response, raw, nil
+		}
+
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return nil, nil, ctx.Err()
+		}
+
+		if !isTimeout(err) {
+			return nil, nil, err
+		}
+
+		result.Timeouts++
+		if result.Attempts > opts.Retries || ctx.Err() != nil {
+			return nil, nil, err
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package dns
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_RegisterTransport(t *testing.T) {
+	t.Parallel()
+
+	nameserver := Nameserver{IP: net.ParseIP("192.0.2.53"), Port: 53}
+
+	// answer answers the message with an A record, as a nameserver would
+	answer := func(msg *dns.Msg) *dns.Msg {
+		response := new(dns.Msg)
+		response.SetReply(msg)
+		response.Answer = append(response.Answer, &dns.A{
+			Hdr: dns.RR_Header{Name: msg.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+			A:   net.ParseIP("203.0.113.1"),
+		})
+
+		return response
+	}
+
+	t.Run("Queries should be sent over the registered transport", func(t *testing.T) {
+		t.Parallel()
+
+		var exchanged Nameserver
+
+		client := NewDNSClient()
+		require.NoError(t, client.RegisterTransport("Custom", TransportFunc(
+			func(_ context.Context, msg *dns.Msg, nameserver Nameserver) (*dns.Msg, error) {
+				exchanged = nameserver
+				return answer(msg), nil
+			},
+		)))
+
+		response, err := client.Query(context.Background(), "k6.test", "A", nameserver, QueryOptions{Protocol: "custom"})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"203.0.113.1"}, response.Answers)
+		assert.Equal(t, "custom", response.Protocol)
+		assert.Equal(t, 1, response.Attempts)
+		assert.NotEmpty(t, response.RawResponse)
+		assert.Equal(t, nameserver, exchanged)
+	})
+
+	t.Run("Timed out attempts should be retransmitted", func(t *testing.T) {
+		t.Parallel()
+
+		var attempts atomic.Int32
+
+		client := NewDNSClient()
+		require.NoError(t, client.RegisterTransport("custom", TransportFunc(
+			func(ctx context.Context, msg *dns.Msg, _ Nameserver) (*dns.Msg, error) {
+				if attempts.Add(1) == 1 {
+					<-ctx.Done()
+					return nil, ctx.Err()
+				}
+
+				return answer(msg), nil
+			},
+		)))
+
+		opts := QueryOptions{Protocol: "custom", Timeout: 20 * time.Millisecond, Retries: 1}
+
+		response, err := client.Query(context.Background(), "k6.test", "A", nameserver, opts)
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, response.Attempts)
+		assert.Equal(t, 1, response.Timeouts)
+	})
+
+	t.Run("Responses with a mismatched ID should fail", func(t *testing.T) {
+		t.Parallel()
+
+		client := NewDNSClient()
+		require.NoError(t, client.RegisterTransport("custom", TransportFunc(
+			func(_ context.Context, msg *dns.Msg, _ Nameserver) (*dns.Msg, error) {
+				response := answer(msg)
+				response.Id++
+
+				return response, nil
+			},
+		)))
+
+		_, err := client.Query(context.Background(), "k6.test", "A", nameserver, QueryOptions{Protocol: "custom"})
+
+		assert.Error(t, err)
+	})
+
+	t.Run("Built-in protocols should not be replaced", func(t *testing.T) {
+		t.Parallel()
+
+		client := NewDNSClient()
+		err := client.RegisterTransport("UDP", TransportFunc(
+			func(_ context.Context, msg *dns.Msg, _ Nameserver) (*dns.Msg, error) {
+				return answer(msg), nil
+			},
+		))
+
+		assert.ErrorContains(t, err, "built in")
+	})
+}
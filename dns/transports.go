@@ -0,0 +1,234 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/grafana/sobek"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/promises"
+)
+
+// comparedProtocols lists the protocols the compareTransports function can query
+// over, in the order they are queried.
+var comparedProtocols = []string{ //nolint:gochecknoglobals
+	protocolUDP,
+	protocolTCP,
+	protocolDoT,
+	protocolDoH,
+}
+
+// compareTransportsOptions holds the options of the compareTransports function.
+type compareTransportsOptions struct {
+	resolveOptions
+
+	// Endpoints maps the protocols to query over to the nameserver addresses to
+	// query over them. UDP and TCP default to the nameserver passed to the
+	// function, while DoT and DoH are only queried when configured.
+	Endpoints map[string]Nameserver
+}
+
+// parseCompareTransportsOptions parses the options object passed to the
+// compareTransports function.
+func parseCompareTransportsOptions(
+	rt *sobek.Runtime,
+	value sobek.Value,
+	nameserver Nameserver,
+) (compareTransportsOptions, error) {
+	resolveOpts, err := parseResolveOptions(rt, value)
+	if err != nil {
+		return compareTransportsOptions{}, err
+	}
+
+	opts := compareTransportsOptions{
+		resolveOptions: resolveOpts,
+		Endpoints: map[string]Nameserver{
+			protocolUDP: nameserver,
+			protocolTCP: nameserver,
+		},
+	}
+
+	if common.IsNullish(value) {
+		return opts, nil
+	}
+
+	v := value.ToObject(rt).Get("endpoints")
+	if common.IsNullish(v) {
+		return opts, nil
+	}
+
+	var endpoints map[string]string
+	if err := rt.ExportTo(v, &endpoints); err != nil {
+		return opts, fmt.Errorf("endpoints option must be an object mapping protocols to addresses; got %v instead", v)
+	}
+
+	for protocol, addr := range endpoints {
+		switch protocol {
+		case protocolUDP, protocolTCP, protocolDoT, protocolDoH:
+		default:
+			return opts, fmt.Errorf("endpoints option holds unsupported protocol %q; must be one of udp, tcp, dot or doh", protocol)
+		}
+
+		endpoint, err := parseNameserverAddr(addr)
+		if err != nil {
+			return opts, fmt.Errorf("parsing the %s endpoint address failed: %w", protocol, err)
+		}
+
+		opts.Endpoints[protocol] = endpoint
+	}
+
+	return opts, nil
+}
+
+// compareTransportsResult is the object the compareTransports function resolves
+// to.
+type compareTransportsResult struct {
+	// Name holds the name which was resolved.
+	Name string `js:"name"`
+
+	// Type holds the record type which was resolved.
+	Type string `js:"type"`
+
+	// Fastest holds the protocol of the fastest successful resolution, or an empty
+	// string if they all failed.
+	Fastest string `js:"fastest"`
+
+	// Transports holds the outcome of the resolution over each protocol, in the
+	// order they were queried.
+	Transports []*transportResult `js:"transports"`
+}
+
+// transportResult holds the outcome of the resolution over a single protocol of
+// a comparison.
+type transportResult struct {
+	// Protocol holds the protocol the query was sent over.
+	Protocol string `js:"protocol"`
+
+	// Nameserver holds the address of the nameserver the query was sent to.
+	Nameserver string `js:"nameserver"`
+
+	// Answers holds the answers of the nameserver.
+	Answers []string `js:"answers"`
+
+	// Rcode holds the name of the response code returned by the nameserver, if any.
+	Rcode string `js:"rcode"`
+
+	// RTT holds the duration of the resolution, in milliseconds.
+	RTT float64 `js:"rtt"`
+
+	// Error holds the error the resolution failed with, if any.
+	Error *Error `js:"error"`
+}
+
+// CompareTransports resolves a domain name over UDP, TCP, and DoT and DoH where
+// configured, and resolves to the latency of each of them.
+//
+// The promise is not rejected when resolutions fail, which is instead reported in
+// the result.
+func (mi *ModuleInstance) CompareTransports(query, recordType, nameserverAddr, options sobek.Value) *sobek.Promise {
+	promise, resolve, reject := promises.New(mi.vu)
+
+	if mi.vu.State() == nil {
+		reject(errors.New("compareTransports can not be used in the init context"))
+		return promise
+	}
+
+	queryStr, err := exportDomainName(mi.vu.Runtime(), query, "query")
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	var recordTypeStr string
+	if err := mi.vu.Runtime().ExportTo(recordType, &recordTypeStr); err != nil {
+		reject(fmt.Errorf("recordType must be a string; got %v instead", recordType))
+		return promise
+	}
+
+	if _, err := RecordTypeString(recordTypeStr); err != nil {
+		reject(fmt.Errorf("%w, %s is an invalid DNS record type", ErrUnsupportedRecordType, recordTypeStr))
+		return promise
+	}
+
+	nameserver, err := mi.exportNameserver(nameserverAddr)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	opts, err := parseCompareTransportsOptions(mi.vu.Runtime(), options, nameserver)
+	if err != nil {
+		reject(fmt.Errorf("invalid compareTransports options: %w", err))
+		return promise
+	}
+
+	// Names which do not exist simply hold no records
+	opts.NXDomainAsEmpty = true
+
+	ctx, cancel, err := mi.withAbortSignal(opts.Signal)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer cancel()
+
+		resolve(mi.compareTransports(ctx, queryStr, recordTypeStr, opts))
+	}()
+
+	return promise
+}
+
+// compareTransports resolves the query over each of the configured protocols in
+// turn, so that they do not compete with each other for the network.
+func (mi *ModuleInstance) compareTransports(
+	ctx context.Context,
+	query, recordType string,
+	opts compareTransportsOptions,
+) *compareTransportsResult {
+	result := &compareTransportsResult{
+		Name:       query,
+		Type:       recordType,
+		Transports: make([]*transportResult, 0, len(opts.Endpoints)),
+	}
+
+	var fastest time.Duration
+	for _, protocol := range comparedProtocols {
+		nameserver, ok := opts.Endpoints[protocol]
+		if !ok {
+			continue
+		}
+
+		resolveOpts := opts.resolveOptions
+		resolveOpts.Protocol = protocol
+
+		response, duration, err := mi.resolveQuery(ctx, query, recordType, nameserver, resolveOpts)
+
+		transport := &transportResult{
+			Protocol:   protocol,
+			Nameserver: nameserver.Addr(),
+			Answers:    []string{},
+			RTT:        float64(duration) / float64(time.Millisecond),
+			Error:      asError(err),
+		}
+
+		if response != nil {
+			transport.Rcode = response.Rcode
+			if err == nil && response.Answers != nil {
+				transport.Answers = response.Answers
+			}
+		}
+
+		if err == nil && (result.Fastest == "" || duration < fastest) {
+			result.Fastest = protocol
+			fastest = duration
+		}
+
+		result.Transports = append(result.Transports, transport)
+	}
+
+	return result
+}
@@ -0,0 +1,130 @@
+package dns
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+)
+
+func TestCompareTransports(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Queries should be sent over UDP and TCP by default", func(t *testing.T) {
+		t.Parallel()
+
+		runtime, err := newConfiguredRuntime(t)
+		require.NoError(t, err)
+
+		_, err = runtime.VU.Runtime().RunString(`
+			const server = dns.startServer(["k6.test. 60 IN A 203.0.113.1"]);
+		`)
+		require.NoError(t, err)
+
+		samples := make(chan metrics.SampleContainer, 1024)
+		runtime.MoveToVUContext(&lib.State{
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+			Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+			Samples:        samples,
+		})
+
+		_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+			const result = await dns.compareTransports("k6.test", "A", server.address);
+
+			const protocols = result.transports.map((transport) => transport.protocol);
+			if (protocols.join() !== "udp,tcp") {
+				throw new Error("unexpected protocols: " + JSON.stringify(result));
+			}
+
+			for (const transport of result.transports) {
+				if (transport.error !== null || transport.answers.join() !== "203.0.113.1" ||
+					transport.nameserver !== server.address) {
+					throw new Error("unexpected transport result: " + JSON.stringify(transport));
+				}
+			}
+
+			if (!protocols.includes(result.fastest)) {
+				throw new Error("unexpected fastest protocol: " + result.fastest);
+			}
+
+			const logged = server.log().map((entry) => entry.protocol);
+			if (logged.join() !== "udp,tcp") {
+				throw new Error("unexpected queries: " + JSON.stringify(server.log()));
+			}
+		`))
+		assert.NoError(t, err)
+
+		close(samples)
+
+		protocols := map[string]bool{}
+		for container := range samples {
+			for _, sample := range container.GetSamples() {
+				if sample.Metric.Name != "dns_resolutions" {
+					continue
+				}
+
+				protocol, _ := sample.Tags.Get("protocol")
+				protocols[protocol] = true
+			}
+		}
+
+		assert.Equal(t, map[string]bool{"udp": true, "tcp": true}, protocols)
+	})
+
+	t.Run("Failed transports should be reported without being the fastest", func(t *testing.T) {
+		t.Parallel()
+
+		runtime, err := newConfiguredRuntime(t)
+		require.NoError(t, err)
+
+		_, err = runtime.VU.Runtime().RunString(`
+			const server = dns.startServer(["k6.test. 60 IN A 203.0.113.1"]);
+		`)
+		require.NoError(t, err)
+
+		runtime.MoveToVUContext(&lib.State{
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+			Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+			Samples:        make(chan metrics.SampleContainer, 1024),
+		})
+
+		_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+			// The test server does not speak TLS
+			const result = await dns.compareTransports("k6.test", "A", server.address, {
+				endpoints: { dot: server.address },
+				timeout: "500ms",
+				retries: 0,
+			});
+
+			const [udp, tcp, dot] = result.transports;
+			if (result.transports.length !== 3 || dot.protocol !== "dot" || dot.error === null) {
+				throw new Error("unexpected transports: " + JSON.stringify(result));
+			}
+
+			if (result.fastest !== "udp" && result.fastest !== "tcp") {
+				throw new Error("unexpected fastest protocol: " + result.fastest);
+			}
+		`))
+		assert.NoError(t, err)
+	})
+
+	t.Run("Unsupported endpoint protocols should be rejected", func(t *testing.T) {
+		t.Parallel()
+
+		runtime, err := newConfiguredRuntime(t)
+		require.NoError(t, err)
+
+		runtime.MoveToVUContext(&lib.State{
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+			Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+			Samples:        make(chan metrics.SampleContainer, 1024),
+		})
+
+		_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+			await dns.compareTransports("k6.test", "A", "192.0.2.1:53", { endpoints: { doq: "192.0.2.1:853" } });
+		`))
+		assert.ErrorContains(t, err, "unsupported protocol")
+	})
+}
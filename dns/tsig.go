@@ -0,0 +1,113 @@
+package dns
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// tsigFudge holds the clock skew allowed between the client and the nameserver
+// when verifying TSIG signatures, in seconds, as recommended by RFC 8945.
+const tsigFudge = 300
+
+// tsigAlgorithms maps the names of the supported TSIG algorithms to their
+// identifier in the dns package.
+var tsigAlgorithms = map[string]string{ //nolint:gochecknoglobals
+	"hmac-md5":    dns.HmacMD5,
+	"hmac-sha1":   dns.HmacSHA1,
+	"hmac-sha224": dns.HmacSHA224,
+	"hmac-sha256": dns.HmacSHA256,
+	"hmac-sha384": dns.HmacSHA384,
+	"hmac-sha512": dns.HmacSHA512,
+}
+
+// TSIGKey is a shared secret key used to authenticate DNS messages using TSIG, as
+// defined by RFC 8945, such as the keys BIND, Knot or PowerDNS use to authorize
+// updates.
+type TSIGKey struct {
+	// Name holds the fully qualified name of the key, e.g. "k6-key.".
+	Name string
+
+	// Algorithm holds the identifier of the HMAC algorithm of the key in the dns
+	// package, e.g. dns.HmacSHA256.
+	Algorithm string
+
+	// Secret holds the base64 encoded secret of the key.
+	Secret string
+}
+
+// NewTSIGKey creates a new TSIGKey, named after the name, for the algorithm, one of
+// "hmac-md5", "hmac-sha1", "hmac-sha224", "hmac-sha256", "hmac-sha384" or
+// "hmac-sha512", and the base64 encoded secret.
+func NewTSIGKey(name, algorithm, secret string) (*TSIGKey, error) {
+	asciiName, err := ToASCII(name)
+	if err != nil || asciiName == "" {
+		return nil, fmt.Errorf("TSIG key name %q is invalid", name)
+	}
+
+	identifier, ok := tsigAlgorithms[strings.ToLower(algorithm)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported TSIG algorithm %q", algorithm)
+	}
+
+	if _, err := base64.StdEncoding.DecodeString(secret); err != nil || secret == "" {
+		return nil, errors.New("TSIG key secret must be a non-empty base64 encoded string")
+	}
+
+	return &TSIGKey{
+		Name:      strings.ToLower(dns.Fqdn(asciiName)),
+		Algorithm: identifier,
+		Secret:    secret,
+	}, nil
+}
+
+// sign returns the wire format of the message signed with the key, along with the
+// signature, which the signature of the response is computed from.
+func (k *TSIGKey) sign(message *dns.Msg, now time.Time) ([]byte, string, error) {
+	// Sign a copy of the message, so that the signature is not part of the message
+	signed := message.Copy()
+	signed.SetTsig(k.Name, k.Algorithm, tsigFudge, now.Unix())
+
+	return dns.TsigGenerate(signed, k.Secret, "", false)
+}
+
+// verify verifies the signature of the response to a message signed with the key,
+// holding the requestMAC signature.
+//
+// It returns an Error if the nameserver rejected the signature of the message, or
+// if the response's signature is missing or invalid.
+func (k *TSIGKey) verify(response *dns.Msg, raw []byte, requestMAC string) error {
+	tsig := response.IsTsig()
+
+	// Nameservers rejecting the signature of a message report why in the TSIG
+	// record of their response, which they do not sign.
+	if tsig != nil && tsig.Error != dns.RcodeSuccess {
+		return newTSIGError(int(tsig.Error), "nameserver rejected the TSIG signature of the message")
+	}
+
+	if err := dns.TsigVerify(raw, k.Secret, requestMAC, false); err != nil {
+		if errors.Is(err, dns.ErrTime) {
+			return newTSIGError(dns.RcodeBadTime, "verifying the TSIG signature of the response failed: "+err.Error())
+		}
+
+		return newTSIGError(dns.RcodeBadSig, "verifying the TSIG signature of the response failed: "+err.Error())
+	}
+
+	return nil
+}
+
+// newTSIGError creates a new Error from a TSIG error code and a message.
+func newTSIGError(code int, message string) *Error {
+	err := newDNSError(code, message)
+
+	// BADSIG shares its code with BADVERS, which only applies to OPT records
+	if code == dns.RcodeBadSig {
+		err.Name = "BadSig"
+	}
+
+	return err
+}
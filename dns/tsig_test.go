@@ -0,0 +1,148 @@
+package dns
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTSIGKey(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		keyName   string
+		algorithm string
+		secret    string
+		want      *TSIGKey
+		wantErr   bool
+	}{
+		{
+			name:      "valid key",
+			keyName:   "K6-Key",
+			algorithm: "HMAC-SHA512",
+			secret:    "c2VjcmV0",
+			want:      &TSIGKey{Name: "k6-key.", Algorithm: dns.HmacSHA512, Secret: "c2VjcmV0"},
+		},
+		{
+			name:      "empty name",
+			algorithm: "hmac-sha256",
+			secret:    "c2VjcmV0",
+			wantErr:   true,
+		},
+		{
+			name:      "unsupported algorithm",
+			keyName:   "k6-key",
+			algorithm: "gss-tsig",
+			secret:    "c2VjcmV0",
+			wantErr:   true,
+		},
+		{
+			name:      "secret not base64 encoded",
+			keyName:   "k6-key",
+			algorithm: "hmac-sha256",
+			secret:    "not base64!",
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := NewTSIGKey(tt.keyName, tt.algorithm, tt.secret)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestTSIGKey_verify(t *testing.T) {
+	t.Parallel()
+
+	key, err := NewTSIGKey("k6-key", "hmac-sha256", "c2VjcmV0")
+	require.NoError(t, err)
+
+	query := new(dns.Msg)
+	query.SetQuestion("k6.io.", dns.TypeA)
+
+	packed, requestMAC, err := key.sign(query, time.Now())
+	require.NoError(t, err)
+	assert.Nil(t, query.IsTsig(), "signing should not alter the message")
+
+	signed := new(dns.Msg)
+	require.NoError(t, signed.Unpack(packed))
+	require.NotNil(t, signed.IsTsig())
+	require.NoError(t, dns.TsigVerify(packed, key.Secret, "", false))
+
+	// respond signs the response to the signed query with the secret, and returns
+	// it along with its wire format.
+	respond := func(t *testing.T, secret string, tsigError uint16) (*dns.Msg, []byte) {
+		t.Helper()
+
+		response := new(dns.Msg)
+		response.SetReply(signed)
+		response.SetTsig(key.Name, key.Algorithm, tsigFudge, time.Now().Unix())
+		response.IsTsig().Error = tsigError
+
+		raw, _, err := dns.TsigGenerate(response, secret, requestMAC, false)
+		require.NoError(t, err)
+
+		unpacked := new(dns.Msg)
+		require.NoError(t, unpacked.Unpack(raw))
+
+		return unpacked, raw
+	}
+
+	t.Run("valid signature", func(t *testing.T) {
+		t.Parallel()
+
+		response, raw := respond(t, key.Secret, dns.RcodeSuccess)
+		assert.NoError(t, key.verify(response, raw, requestMAC))
+	})
+
+	t.Run("invalid signature", func(t *testing.T) {
+		t.Parallel()
+
+		response, raw := respond(t, "b3RoZXI=", dns.RcodeSuccess)
+		err := key.verify(response, raw, requestMAC)
+
+		var dnsErr *Error
+		require.ErrorAs(t, err, &dnsErr)
+		assert.Equal(t, BadSig, dnsErr.Kind)
+		assert.Equal(t, "BadSig", dnsErr.Name)
+	})
+
+	t.Run("key rejected by the nameserver", func(t *testing.T) {
+		t.Parallel()
+
+		response, raw := respond(t, key.Secret, dns.RcodeBadKey)
+		err := key.verify(response, raw, requestMAC)
+
+		var dnsErr *Error
+		require.ErrorAs(t, err, &dnsErr)
+		assert.Equal(t, BadKey, dnsErr.Kind)
+	})
+
+	t.Run("unsigned response", func(t *testing.T) {
+		t.Parallel()
+
+		response := new(dns.Msg)
+		response.SetReply(signed)
+
+		raw, err := response.Pack()
+		require.NoError(t, err)
+
+		assert.Error(t, key.verify(response, raw, requestMAC))
+	})
+}
@@ -0,0 +1,387 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/grafana/sobek"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/promises"
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/metrics"
+)
+
+const (
+	// defaultTTLSamples is the default number of times monitorTTL resolves a record.
+	defaultTTLSamples = 5
+
+	// defaultTTLTolerance is the default number of seconds the TTL of a record may
+	// deviate from the expected countdown by, covering the rounding of TTLs to
+	// whole seconds.
+	defaultTTLTolerance = 1
+)
+
+// Kinds of TTL changes between two resolutions of a record.
+const (
+	// ttlChangeFirst marks the first resolution, which has nothing to compare to.
+	ttlChangeFirst = "first"
+
+	// ttlChangeDecrement marks a TTL which counted down as expected.
+	ttlChangeDecrement = "decrement"
+
+	// ttlChangeReset marks a TTL which increased once the previous one expired,
+	// as the record was fetched anew.
+	ttlChangeReset = "reset"
+
+	// ttlChangeEarlyReset marks a TTL which increased before the previous one
+	// expired, e.g. because of a cache eviction or of a load balancer spreading
+	// queries across caches.
+	ttlChangeEarlyReset = "earlyReset"
+
+	// ttlChangeFrozen marks a TTL which did not count down at all.
+	ttlChangeFrozen = "frozen"
+
+	// ttlChangeDrift marks a TTL which counted down faster or slower than time.
+	ttlChangeDrift = "drift"
+
+	// ttlChangeFailed marks a resolution which failed, or held no records.
+	ttlChangeFailed = "failed"
+)
+
+// monitorTTLOptions holds the options that can be passed to the monitorTTL function.
+type monitorTTLOptions struct {
+	resolveOptions
+
+	// Count holds the number of times the record is resolved.
+	Count int
+
+	// Interval holds the duration between two resolutions.
+	Interval time.Duration
+
+	// Tolerance holds the number of seconds the TTL may deviate from the expected
+	// countdown by.
+	Tolerance float64
+}
+
+// parseMonitorTTLOptions parses the options object passed to the monitorTTL
+// function.
+//
+// It accepts the same options as the resolve function, along with the count,
+// interval and tolerance options.
+func parseMonitorTTLOptions(rt *sobek.Runtime, value sobek.Value) (monitorTTLOptions, error) {
+	resolveOpts, err := parseResolveOptions(rt, value)
+	if err != nil {
+		return monitorTTLOptions{}, err
+	}
+
+	opts := monitorTTLOptions{
+		resolveOptions: resolveOpts,
+		Count:          defaultTTLSamples,
+		Interval:       defaultWaitInterval,
+		Tolerance:      defaultTTLTolerance,
+	}
+
+	if common.IsNullish(value) {
+		return opts, nil
+	}
+
+	params := value.ToObject(rt)
+
+	if v := params.Get("count"); !common.IsNullish(v) {
+		var count int64
+		if err := rt.ExportTo(v, &count); err != nil || count < 2 {
+			return opts, fmt.Errorf("count option must be an integer of at least 2; got %v instead", v)
+		}
+
+		opts.Count = int(count)
+	}
+
+	if v := params.Get("interval"); !common.IsNullish(v) {
+		interval, err := types.GetDurationValue(v.Export())
+		if err != nil {
+			return opts, fmt.Errorf("interval option is invalid; reason: %w", err)
+		}
+
+		if interval <= 0 {
+			return opts, fmt.Errorf("interval option must be a strictly positive duration; got %v instead", v)
+		}
+
+		opts.Interval = interval
+	}
+
+	if v := params.Get("tolerance"); !common.IsNullish(v) {
+		tolerance := v.ToFloat()
+		if math.IsNaN(tolerance) || tolerance < 0 {
+			return opts, fmt.Errorf("tolerance option must be a positive number of seconds; got %v instead", v)
+		}
+
+		opts.Tolerance = tolerance
+	}
+
+	return opts, nil
+}
+
+// monitorTTLResult is the object the monitorTTL function resolves to.
+type monitorTTLResult struct {
+	// Name holds the name which was resolved.
+	Name string `js:"name"`
+
+	// Type holds the record type which was resolved.
+	Type string `js:"type"`
+
+	// Consistent indicates whether the TTL counted down as expected between every
+	// two successful resolutions, resets included.
+	Consistent bool `js:"consistent"`
+
+	// Monotonic indicates whether the TTL never increased, except once the
+	// previous one expired.
+	Monotonic bool `js:"monotonic"`
+
+	// Resets holds the number of times the TTL was reset once the previous one
+	// expired.
+	Resets int `js:"resets"`
+
+	// Anomalies holds the number of TTL changes which were neither decrements nor
+	// resets.
+	Anomalies int `js:"anomalies"`
+
+	// Samples holds the outcome of each resolution, in order.
+	Samples []*ttlSample `js:"samples"`
+}
+
+// ttlSample describes the outcome of a resolution of a monitored record.
+type ttlSample struct {
+	// Time holds the time the response was received at, as a Unix timestamp in
+	// milliseconds.
+	Time float64 `js:"time"`
+
+	// TTL holds the lowest TTL of the records of the resolved type, in seconds, or
+	// nil if the resolution failed or held no records.
+	TTL interface{} `js:"ttl"`
+
+	// Expected holds the TTL expected from the previous resolution, in seconds, or
+	// nil if there is no previous successful resolution.
+	Expected interface{} `js:"expected"`
+
+	// Change holds the kind of change of the TTL since the previous successful
+	// resolution, e.g. "decrement" or "earlyReset".
+	Change string `js:"change"`
+
+	// RTT holds the duration of the resolution, in milliseconds.
+	RTT float64 `js:"rtt"`
+
+	// Error holds the error the resolution failed with, if any.
+	Error *Error `js:"error"`
+}
+
+// MonitorTTL resolves a record repeatedly, and resolves to whether its TTL counts
+// down monotonically and is reset once expired, as a cache's should, which
+// allows detecting broken caches in resolver fleets.
+//
+// The promise is not rejected when resolutions fail, which is instead reported in
+// the result.
+func (mi *ModuleInstance) MonitorTTL(query, recordType, nameserverAddr, options sobek.Value) *sobek.Promise {
+	promise, resolve, reject := promises.New(mi.vu)
+
+	if mi.vu.State() == nil {
+		reject(errors.New("monitorTTL can not be used in the init context"))
+		return promise
+	}
+
+	queryStr, err := exportDomainName(mi.vu.Runtime(), query, "query")
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	var recordTypeStr string
+	if err := mi.vu.Runtime().ExportTo(recordType, &recordTypeStr); err != nil {
+		reject(fmt.Errorf("recordType must be a string; got %v instead", recordType))
+		return promise
+	}
+
+	if _, err := RecordTypeString(recordTypeStr); err != nil {
+		reject(fmt.Errorf("%w, %s is an invalid DNS record type", ErrUnsupportedRecordType, recordTypeStr))
+		return promise
+	}
+
+	nameserver, err := mi.exportNameserver(nameserverAddr)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	opts, err := parseMonitorTTLOptions(mi.vu.Runtime(), options)
+	if err != nil {
+		reject(fmt.Errorf("invalid monitorTTL options: %w", err))
+		return promise
+	}
+
+	ctx, cancel, err := mi.withAbortSignal(opts.Signal)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer cancel()
+
+		resolve(mi.monitorTTL(ctx, queryStr, recordTypeStr, nameserver, opts))
+	}()
+
+	return promise
+}
+
+// monitorTTL resolves the record opts.Count times, opts.Interval apart, and
+// classifies the change of its TTL between every two successful resolutions.
+func (mi *ModuleInstance) monitorTTL(
+	ctx context.Context,
+	query, recordType string,
+	nameserver Nameserver,
+	opts monitorTTLOptions,
+) *monitorTTLResult {
+	result := &monitorTTLResult{
+		Name:       query,
+		Type:       recordType,
+		Consistent: true,
+		Monotonic:  true,
+		Samples:    make([]*ttlSample, 0, opts.Count),
+	}
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+
+	var previousTTL float64
+	var previousTime time.Time
+
+	for i := 0; i < opts.Count; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return result
+			case <-ticker.C:
+			}
+		}
+
+		response, duration, err := mi.resolveQuery(ctx, query, recordType, nameserver, opts.resolveOptions)
+		now := time.Now()
+
+		sample := &ttlSample{
+			Time:   float64(now.UnixMilli()),
+			Change: ttlChangeFailed,
+			RTT:    float64(duration) / float64(time.Millisecond),
+			Error:  asError(err),
+		}
+		result.Samples = append(result.Samples, sample)
+
+		ttl, ok := lowestTTL(response, recordType)
+		if err != nil || !ok {
+			continue
+		}
+
+		sample.TTL = ttl
+
+		if previousTime.IsZero() {
+			sample.Change = ttlChangeFirst
+		} else {
+			expected := previousTTL - now.Sub(previousTime).Seconds()
+			sample.Expected = math.Max(0, math.Round(expected))
+			sample.Change = classifyTTLChange(previousTTL, expected, float64(ttl), opts.Tolerance)
+
+			switch sample.Change {
+			case ttlChangeReset:
+				result.Resets++
+			case ttlChangeEarlyReset:
+				result.Monotonic = false
+				fallthrough
+			case ttlChangeFrozen, ttlChangeDrift:
+				result.Anomalies++
+				result.Consistent = false
+			}
+
+			anomalous := sample.Change != ttlChangeDecrement && sample.Change != ttlChangeReset
+			mi.emitTTLDriftMetrics(mi.vu.Context(), query, recordType, nameserver, anomalous)
+		}
+
+		previousTTL = float64(ttl)
+		previousTime = now
+	}
+
+	return result
+}
+
+// classifyTTLChange returns the kind of change from the previous TTL to the
+// current one, given the TTL expected from the time elapsed in between.
+func classifyTTLChange(previous, expected, current, tolerance float64) string {
+	switch {
+	case math.Abs(current-expected) <= tolerance:
+		return ttlChangeDecrement
+	case current > previous && expected <= tolerance:
+		return ttlChangeReset
+	case current > previous:
+		return ttlChangeEarlyReset
+	case current == previous:
+		return ttlChangeFrozen
+	default:
+		return ttlChangeDrift
+	}
+}
+
+// lowestTTL returns the lowest TTL of the records of the record type found in
+// the response, which is the one caches count down to their expiration.
+func lowestTTL(response *Response, recordType string) (uint32, bool) {
+	if response == nil {
+		return 0, false
+	}
+
+	var lowest uint32
+	found := false
+
+	for _, record := range response.Records {
+		if !strings.EqualFold(record.Type, recordType) {
+			continue
+		}
+
+		if !found || record.TTL < lowest {
+			lowest = record.TTL
+			found = true
+		}
+	}
+
+	return lowest, found
+}
+
+// emitTTLDriftMetrics emits whether the TTL of the record did not count down as
+// a cache's should have.
+func (mi *ModuleInstance) emitTTLDriftMetrics(
+	ctx context.Context,
+	query, recordType string,
+	nameserver Nameserver,
+	drifted bool,
+) {
+	state := mi.vu.State()
+
+	tags := state.Tags.GetCurrentValues().Tags
+	tags = tags.With("query", query)
+	tags = tags.With("recordType", recordType)
+	tags = tags.With("nameserver", nameserver.Addr())
+
+	var value float64
+	if drifted {
+		value = 1
+	}
+
+	metrics.PushIfNotDone(ctx, state.Samples, metrics.Sample{
+		TimeSeries: metrics.TimeSeries{
+			Metric: mi.metrics.DNSTTLDrift,
+			Tags:   tags,
+		},
+		Time:     time.Now(),
+		Value:    value,
+		Metadata: nil,
+	})
+}
@@ -0,0 +1,119 @@
+package dns
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+)
+
+func TestMonitorTTL(t *testing.T) {
+	t.Parallel()
+
+	t.Run("TTLs which do not count down should be reported", func(t *testing.T) {
+		t.Parallel()
+
+		runtime, err := newConfiguredRuntime(t)
+		require.NoError(t, err)
+
+		_, err = runtime.VU.Runtime().RunString(`
+			const server = dns.startServer(["k6.test. 60 IN A 203.0.113.1"]);
+		`)
+		require.NoError(t, err)
+
+		runtime.MoveToVUContext(&lib.State{
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+			Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+			Samples:        make(chan metrics.SampleContainer, 1024),
+		})
+
+		_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+			const lenient = await dns.monitorTTL("k6.test", "A", server.address, { count: 3, interval: "50ms" });
+			if (!lenient.consistent || lenient.samples.length !== 3 || lenient.samples[0].change !== "first" ||
+				lenient.samples[1].change !== "decrement" || lenient.samples[1].ttl !== 60) {
+				throw new Error("unexpected lenient result: " + JSON.stringify(lenient));
+			}
+
+			const strict = await dns.monitorTTL("k6.test", "A", server.address, {
+				count: 2,
+				interval: "50ms",
+				tolerance: 0,
+			});
+			if (strict.consistent || strict.anomalies !== 1 || !strict.monotonic || strict.samples[1].change !== "frozen") {
+				throw new Error("unexpected strict result: " + JSON.stringify(strict));
+			}
+		`))
+		assert.NoError(t, err)
+	})
+
+	t.Run("TTLs which increase before expiring should be reported", func(t *testing.T) {
+		t.Parallel()
+
+		ttls := []string{"60", "300", "10"}
+		var served atomic.Int32
+
+		handler := dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			response := new(dns.Msg)
+			response.SetReply(req)
+
+			ttl := ttls[int(served.Add(1)-1)%len(ttls)]
+			rr, _ := dns.NewRR(req.Question[0].Name + " " + ttl + " IN A 192.0.2.1")
+			response.Answer = append(response.Answer, rr)
+			_ = w.WriteMsg(response)
+		})
+
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		server := &dns.Server{PacketConn: conn, Handler: handler}
+		go func() { _ = server.ActivateAndServe() }()
+		t.Cleanup(func() { _ = server.Shutdown() })
+
+		runtime, err := newConfiguredRuntime(t)
+		require.NoError(t, err)
+		require.NoError(t, runtime.VU.Runtime().Set("address", conn.LocalAddr().String()))
+
+		runtime.MoveToVUContext(&lib.State{
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+			Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+			Samples:        make(chan metrics.SampleContainer, 1024),
+		})
+
+		_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+			const result = await dns.monitorTTL("k6.test", "A", address, { count: 3, interval: "50ms" });
+
+			const changes = result.samples.map((s) => s.change).join();
+			if (changes !== "first,earlyReset,drift" || result.monotonic || result.consistent || result.anomalies !== 2) {
+				throw new Error("unexpected result: " + JSON.stringify(result));
+			}
+
+			if (result.samples[2].expected !== 300) {
+				throw new Error("unexpected expected TTL: " + result.samples[2].expected);
+			}
+		`))
+		assert.NoError(t, err)
+	})
+
+	t.Run("Invalid options should be rejected", func(t *testing.T) {
+		t.Parallel()
+
+		runtime, err := newConfiguredRuntime(t)
+		require.NoError(t, err)
+
+		runtime.MoveToVUContext(&lib.State{
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+			Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+			Samples:        make(chan metrics.SampleContainer, 1024),
+		})
+
+		_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+			await dns.monitorTTL("k6.test", "A", "127.0.0.1:53", { count: 1 });
+		`))
+		assert.ErrorContains(t, err, "count option must be an integer of at least 2")
+	})
+}
@@ -0,0 +1,237 @@
+package dns
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/grafana/sobek"
+	"github.com/miekg/dns"
+	"go.k6.io/k6/js/promises"
+)
+
+// Update is a dynamic DNS update message under construction, as defined by RFC 2136,
+// holding the prerequisites the zone must satisfy, and the changes to apply to it.
+//
+// Its methods return the update itself, so that calls can be chained.
+type Update struct {
+	msg dns.Msg
+}
+
+// NewUpdate creates a new Update for the zone.
+func NewUpdate(zone string) (*Update, error) {
+	name, err := updateName(zone)
+	if err != nil {
+		return nil, fmt.Errorf("zone %q is invalid; reason: %w", zone, err)
+	}
+
+	update := &Update{}
+	update.msg.SetUpdate(name)
+
+	return update, nil
+}
+
+// Add adds a record, in presentation format (e.g. "www.k6.io. 60 IN A 1.2.3.4"),
+// to the zone.
+func (u *Update) Add(record string) (*Update, error) {
+	rr, err := parseRecord(record)
+	if err != nil {
+		return nil, err
+	}
+
+	u.msg.Insert([]dns.RR{rr})
+
+	return u, nil
+}
+
+// Delete deletes a record, in presentation format, from the zone. The TTL of the
+// record is ignored.
+func (u *Update) Delete(record string) (*Update, error) {
+	rr, err := parseRecord(record)
+	if err != nil {
+		return nil, err
+	}
+
+	u.msg.Remove([]dns.RR{rr})
+
+	return u, nil
+}
+
+// DeleteRRset deletes all the records of the record type for the name from the zone.
+func (u *Update) DeleteRRset(name, recordType string) (*Update, error) {
+	rr, err := updateRRset(name, recordType)
+	if err != nil {
+		return nil, err
+	}
+
+	u.msg.RemoveRRset([]dns.RR{rr})
+
+	return u, nil
+}
+
+// DeleteName deletes all the records for the name from the zone.
+func (u *Update) DeleteName(name string) (*Update, error) {
+	rr, err := updateRRset(name, "ANY")
+	if err != nil {
+		return nil, err
+	}
+
+	u.msg.RemoveName([]dns.RR{rr})
+
+	return u, nil
+}
+
+// RequireName adds a prerequisite for the name to be in use in the zone, that is,
+// to own at least one record, or, if inUse is false, not to be in use.
+func (u *Update) RequireName(name string, inUse bool) (*Update, error) {
+	rr, err := updateRRset(name, "ANY")
+	if err != nil {
+		return nil, err
+	}
+
+	if inUse {
+		u.msg.NameUsed([]dns.RR{rr})
+	} else {
+		u.msg.NameNotUsed([]dns.RR{rr})
+	}
+
+	return u, nil
+}
+
+// RequireRRset adds a prerequisite for records of the record type to exist for
+// the name in the zone, or, if exists is false, not to exist.
+func (u *Update) RequireRRset(name, recordType string, exists bool) (*Update, error) {
+	rr, err := updateRRset(name, recordType)
+	if err != nil {
+		return nil, err
+	}
+
+	if exists {
+		u.msg.RRsetUsed([]dns.RR{rr})
+	} else {
+		u.msg.RRsetNotUsed([]dns.RR{rr})
+	}
+
+	return u, nil
+}
+
+// RequireRecord adds a prerequisite for a record, in presentation format, to exist
+// in the zone. The TTL of the record is ignored.
+func (u *Update) RequireRecord(record string) (*Update, error) {
+	rr, err := parseRecord(record)
+	if err != nil {
+		return nil, err
+	}
+
+	u.msg.Used([]dns.RR{rr})
+
+	return u, nil
+}
+
+// updateName converts the name to its ASCII, fully qualified, form.
+func updateName(name string) (string, error) {
+	asciiName, err := ToASCII(name)
+	if err != nil {
+		return "", err
+	}
+
+	return dns.Fqdn(asciiName), nil
+}
+
+// updateRRset creates a record without data, designating the RRset of the record
+// type for the name, for use in the prerequisite and update sections of an Update.
+func updateRRset(name, recordType string) (dns.RR, error) {
+	fqdn, err := updateName(name)
+	if err != nil {
+		return nil, fmt.Errorf("name %q is invalid; reason: %w", name, err)
+	}
+
+	rrtype, ok := dns.StringToType[strings.ToUpper(recordType)]
+	if !ok {
+		return nil, fmt.Errorf("unknown record type %q", recordType)
+	}
+
+	return &dns.ANY{Hdr: dns.RR_Header{Name: fqdn, Rrtype: rrtype, Class: dns.ClassINET}}, nil
+}
+
+// Update sends an update built with NewUpdate to the nameserver, and resolves to
+// the message received in response.
+//
+// Unless the throw option is disabled, the promise is rejected when the response
+// holds a response code other than NOERROR, e.g. when a prerequisite is not met.
+func (mi *ModuleInstance) Update(update, nameserverAddr, options sobek.Value) *sobek.Promise {
+	promise, resolve, reject := promises.New(mi.vu)
+
+	if mi.vu.State() == nil {
+		reject(errors.New("update can not be used in the init context"))
+		return promise
+	}
+
+	msg, ok := exportUpdate(update)
+	if !ok {
+		reject(fmt.Errorf("update must be built with newUpdate(); got %v instead", update))
+		return promise
+	}
+
+	nameserver, err := mi.exportNameserver(nameserverAddr)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	opts, err := parseResolveOptions(mi.vu.Runtime(), options)
+	if err != nil {
+		reject(fmt.Errorf("invalid update options: %w", err))
+		return promise
+	}
+
+	ctx, cancel, err := mi.withAbortSignal(opts.Signal)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer cancel()
+
+		response, duration, err := mi.sendMessage(ctx, msg, nameserver, opts.QueryOptions)
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		if opts.Throw && response.msg.Rcode != dns.RcodeSuccess {
+			reject(withNameserver(newDNSError(response.msg.Rcode, "DNS update failed"), nameserver))
+			return
+		}
+
+		result := newMessageResult(response.msg, duration)
+		result.Size = response.Size
+		if opts.Raw {
+			result.Raw = newRawMessages(response)
+		}
+
+		resolve(result)
+	}()
+
+	return promise
+}
+
+// exportUpdate exports the DNS message built by an Update from the JS runtime.
+func exportUpdate(value sobek.Value) (*dns.Msg, bool) {
+	if value == nil {
+		return nil, false
+	}
+
+	update, ok := value.Export().(*Update)
+	if !ok || update == nil {
+		return nil, false
+	}
+
+	// Copy the message, so that later changes to it do not affect this exchange, and
+	// use a new ID for each exchange, as the same update can be sent repeatedly
+	msg := update.msg.Copy()
+	msg.Id = dns.Id()
+
+	return msg, true
+}
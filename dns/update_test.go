@@ -0,0 +1,125 @@
+package dns
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		build   func(u *Update) (*Update, error)
+		check   func(t *testing.T, msg *dns.Msg)
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:  "defaults",
+			build: func(u *Update) (*Update, error) { return u, nil },
+			check: func(t *testing.T, msg *dns.Msg) {
+				assert.Equal(t, dns.OpcodeUpdate, msg.Opcode)
+				assert.Equal(t, []dns.Question{{Name: "k6.io.", Qtype: dns.TypeSOA, Qclass: dns.ClassINET}}, msg.Question)
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:  "add",
+			build: func(u *Update) (*Update, error) { return u.Add("www.k6.io. 60 IN A 1.2.3.4") },
+			check: func(t *testing.T, msg *dns.Msg) {
+				require.Len(t, msg.Ns, 1)
+				assert.Equal(t, "www.k6.io.\t60\tIN\tA\t1.2.3.4", msg.Ns[0].String())
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:  "delete",
+			build: func(u *Update) (*Update, error) { return u.Delete("www.k6.io. 60 IN A 1.2.3.4") },
+			check: func(t *testing.T, msg *dns.Msg) {
+				require.Len(t, msg.Ns, 1)
+				assert.Equal(t, dns.RR_Header{Name: "www.k6.io.", Rrtype: dns.TypeA, Class: dns.ClassNONE, Rdlength: 0}, *msg.Ns[0].Header())
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:  "delete RRset",
+			build: func(u *Update) (*Update, error) { return u.DeleteRRset("www.k6.io", "aaaa") },
+			check: func(t *testing.T, msg *dns.Msg) {
+				require.Len(t, msg.Ns, 1)
+				assert.Equal(t, dns.RR_Header{Name: "www.k6.io.", Rrtype: dns.TypeAAAA, Class: dns.ClassANY}, *msg.Ns[0].Header())
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:  "delete name",
+			build: func(u *Update) (*Update, error) { return u.DeleteName("www.k6.io") },
+			check: func(t *testing.T, msg *dns.Msg) {
+				require.Len(t, msg.Ns, 1)
+				assert.Equal(t, dns.RR_Header{Name: "www.k6.io.", Rrtype: dns.TypeANY, Class: dns.ClassANY}, *msg.Ns[0].Header())
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:  "name not in use",
+			build: func(u *Update) (*Update, error) { return u.RequireName("www.k6.io", false) },
+			check: func(t *testing.T, msg *dns.Msg) {
+				require.Len(t, msg.Answer, 1)
+				assert.Equal(t, dns.RR_Header{Name: "www.k6.io.", Rrtype: dns.TypeANY, Class: dns.ClassNONE}, *msg.Answer[0].Header())
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:  "RRset exists",
+			build: func(u *Update) (*Update, error) { return u.RequireRRset("www.k6.io", "A", true) },
+			check: func(t *testing.T, msg *dns.Msg) {
+				require.Len(t, msg.Answer, 1)
+				assert.Equal(t, dns.RR_Header{Name: "www.k6.io.", Rrtype: dns.TypeA, Class: dns.ClassANY}, *msg.Answer[0].Header())
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:  "record exists",
+			build: func(u *Update) (*Update, error) { return u.RequireRecord("www.k6.io. 60 IN A 1.2.3.4") },
+			check: func(t *testing.T, msg *dns.Msg) {
+				require.Len(t, msg.Answer, 1)
+				assert.Equal(t, uint32(0), msg.Answer[0].Header().Ttl)
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "invalid record",
+			build:   func(u *Update) (*Update, error) { return u.Add("www.k6.io. 60 IN A nope") },
+			wantErr: assert.Error,
+		},
+		{
+			name:    "unknown record type",
+			build:   func(u *Update) (*Update, error) { return u.DeleteRRset("www.k6.io", "BOGUS") },
+			wantErr: assert.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			update, err := NewUpdate("k6.io")
+			require.NoError(t, err)
+
+			got, err := tt.build(update)
+			if !tt.wantErr(t, err) || err != nil {
+				return
+			}
+
+			// Ensure the update can be sent over the wire
+			_, err = got.msg.Pack()
+			require.NoError(t, err)
+
+			tt.check(t, &got.msg)
+		})
+	}
+}
@@ -0,0 +1,603 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/sobek"
+	"github.com/miekg/dns"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/promises"
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/metrics"
+)
+
+const (
+	// defaultWaitTimeout is the default maximum duration the wait functions poll
+	// nameservers for.
+	defaultWaitTimeout = time.Minute
+
+	// defaultWaitInterval is the default duration between two polls of the wait
+	// functions.
+	defaultWaitInterval = time.Second
+)
+
+// waitOptions holds the options that can be passed to the wait functions.
+type waitOptions struct {
+	resolveOptions
+
+	// Timeout holds the maximum duration nameservers are polled for.
+	Timeout time.Duration
+
+	// Interval holds the duration between two polls of a nameserver.
+	Interval time.Duration
+}
+
+// parseWaitOptions parses the options object passed to the wait functions.
+//
+// It accepts the same options as the resolve function, except for the timeout
+// option, which bounds the whole wait rather than each query, along with the
+// interval option.
+func parseWaitOptions(rt *sobek.Runtime, value sobek.Value) (waitOptions, error) {
+	resolveOpts, err := parseResolveOptions(rt, value)
+	if err != nil {
+		return waitOptions{}, err
+	}
+
+	opts := waitOptions{
+		resolveOptions: resolveOpts,
+		Timeout:        defaultWaitTimeout,
+		Interval:       defaultWaitInterval,
+	}
+
+	// Queries use the default attempt timeout instead
+	if opts.QueryOptions.Timeout > 0 {
+		opts.Timeout = opts.QueryOptions.Timeout
+		opts.QueryOptions.Timeout = 0
+	}
+
+	if common.IsNullish(value) {
+		return opts, nil
+	}
+
+	if v := value.ToObject(rt).Get("interval"); !common.IsNullish(v) {
+		interval, err := types.GetDurationValue(v.Export())
+		if err != nil {
+			return opts, fmt.Errorf("interval option is invalid; reason: %w", err)
+		}
+
+		if interval <= 0 {
+			return opts, fmt.Errorf("interval option must be a strictly positive duration; got %v instead", v)
+		}
+
+		opts.Interval = interval
+	}
+
+	return opts, nil
+}
+
+// poll calls check every interval, until it returns true or the context is done.
+// It returns whether check returned true.
+func poll(ctx context.Context, interval time.Duration, check func() bool) bool {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if check() {
+			return true
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+// waitOver returns whether the wait bound to the context is over. Network timeouts
+// may fire at the context's deadline slightly before the context reports it is done,
+// hence the deadline is checked as well.
+func waitOver(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return true
+	}
+
+	deadline, ok := ctx.Deadline()
+
+	return ok && !time.Now().Before(deadline)
+}
+
+// exportNameservers exports the JS array holding nameserver addresses, in the
+// `ip[:port]` format, and parses them into Nameservers.
+func exportNameservers(rt *sobek.Runtime, value sobek.Value) ([]Nameserver, error) {
+	var addrs []string
+	if err := rt.ExportTo(value, &addrs); err != nil || len(addrs) == 0 {
+		return nil, fmt.Errorf("nameservers must be a non-empty array of addresses; got %v instead", value)
+	}
+
+	nameservers := make([]Nameserver, 0, len(addrs))
+	for _, addr := range addrs {
+		nameserver, err := parseNameserverAddr(addr)
+		if err != nil {
+			return nil, fmt.Errorf("parsing nameserver address failed: %w", err)
+		}
+
+		nameservers = append(nameservers, nameserver)
+	}
+
+	return nameservers, nil
+}
+
+// serialAtLeast returns whether the serial number is greater than or equal to the
+// target, according to the serial number arithmetic of RFC 1982.
+func serialAtLeast(serial, target uint32) bool {
+	return serial == target || int32(serial-target) > 0 //nolint:gosec
+}
+
+// waitForSerialResult is the object the waitForSerial function resolves to.
+type waitForSerialResult struct {
+	// Zone holds the zone whose SOA records were polled.
+	Zone string `js:"zone"`
+
+	// Serial holds the serial number the nameservers were waited for.
+	Serial uint32 `js:"serial"`
+
+	// Converged indicates whether all the nameservers reported the serial number
+	// before the timeout.
+	Converged bool `js:"converged"`
+
+	// Elapsed holds the duration until all the nameservers converged, or until the
+	// wait timed out, in milliseconds.
+	Elapsed float64 `js:"elapsed"`
+
+	// Nameservers holds the outcome of the wait for each nameserver, in order.
+	Nameservers []serialConvergence `js:"nameservers"`
+}
+
+// serialConvergence describes how a nameserver converged to a serial number.
+type serialConvergence struct {
+	// Nameserver holds the address of the nameserver.
+	Nameserver string `js:"nameserver"`
+
+	// Converged indicates whether the nameserver reported the serial number before
+	// the timeout.
+	Converged bool `js:"converged"`
+
+	// Serial holds the last serial number reported by the nameserver, or nil if it
+	// never reported one.
+	Serial *uint32 `js:"serial"`
+
+	// Elapsed holds the duration until the nameserver converged, in milliseconds,
+	// or nil if it did not.
+	Elapsed *float64 `js:"elapsed"`
+
+	// Polls holds the number of times the nameserver was queried.
+	Polls int `js:"polls"`
+
+	// Error holds the error the last query failed with, if any.
+	Error *Error `js:"error"`
+}
+
+// WaitForSerial polls the SOA record of the zone on each of the nameservers, until
+// they all report a serial number greater than or equal to the provided one, and
+// resolves to the time each of them took to converge.
+//
+// The promise is not rejected when nameservers fail to converge before the timeout,
+// which is instead reported in the result.
+func (mi *ModuleInstance) WaitForSerial(zone, serial, nameserverAddrs, options sobek.Value) *sobek.Promise {
+	promise, resolve, reject := promises.New(mi.vu)
+
+	if mi.vu.State() == nil {
+		reject(errors.New("waitForSerial can not be used in the init context"))
+		return promise
+	}
+
+	zoneStr, err := exportDomainName(mi.vu.Runtime(), zone, "zone")
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	var target int64
+	if err := mi.vu.Runtime().ExportTo(serial, &target); err != nil || target < 0 || target > math.MaxUint32 {
+		reject(fmt.Errorf("serial must be an integer between 0 and %d; got %v instead", uint32(math.MaxUint32), serial))
+		return promise
+	}
+
+	nameservers, err := exportNameservers(mi.vu.Runtime(), nameserverAddrs)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	opts, err := parseWaitOptions(mi.vu.Runtime(), options)
+	if err != nil {
+		reject(fmt.Errorf("invalid waitForSerial options: %w", err))
+		return promise
+	}
+
+	ctx, cancel, err := mi.withAbortSignal(opts.Signal)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer cancel()
+
+		resolve(mi.waitForSerial(ctx, zoneStr, uint32(target), nameservers, opts))
+	}()
+
+	return promise
+}
+
+// waitForSerial polls the nameservers concurrently until they all report the
+// serial number of the zone, or until opts.Timeout elapsed.
+func (mi *ModuleInstance) waitForSerial(
+	ctx context.Context,
+	zone string,
+	target uint32,
+	nameservers []Nameserver,
+	opts waitOptions,
+) *waitForSerialResult {
+	result := &waitForSerialResult{
+		Zone:        zone,
+		Serial:      target,
+		Converged:   true,
+		Nameservers: make([]serialConvergence, len(nameservers)),
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	for i, nameserver := range nameservers {
+		wg.Add(1)
+
+		go func(convergence *serialConvergence, nameserver Nameserver) {
+			defer wg.Done()
+
+			convergence.Nameserver = nameserver.Addr()
+			convergence.Converged = poll(ctx, opts.Interval, func() bool {
+				convergence.Polls++
+
+				serial, err := mi.querySerial(ctx, zone, nameserver, opts.QueryOptions)
+
+				// Queries interrupted by the end of the wait are not failures
+				if waitOver(ctx) {
+					return false
+				}
+
+				convergence.Error = asError(err)
+				if err != nil {
+					return false
+				}
+
+				convergence.Serial = &serial
+
+				return serialAtLeast(serial, target)
+			})
+
+			if convergence.Converged {
+				elapsed := time.Since(start)
+				convergence.Elapsed = durationMillis(elapsed)
+				mi.emitPropagationMetrics(mi.vu.Context(), nameserver, elapsed, map[string]string{"zone": zone})
+			}
+		}(&result.Nameservers[i], nameserver)
+	}
+
+	wg.Wait()
+
+	result.Elapsed = float64(time.Since(start)) / float64(time.Millisecond)
+	for _, convergence := range result.Nameservers {
+		result.Converged = result.Converged && convergence.Converged
+	}
+
+	return result
+}
+
+// querySerial queries the nameserver for the SOA record of the zone, and returns
+// its serial number.
+func (mi *ModuleInstance) querySerial(
+	ctx context.Context,
+	zone string,
+	nameserver Nameserver,
+	opts QueryOptions,
+) (uint32, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(zone), dns.TypeSOA)
+	msg.RecursionDesired = !opts.NoRecursion
+
+	response, _, err := mi.sendMessage(ctx, msg, nameserver, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	if response.msg.Rcode != dns.RcodeSuccess {
+		return 0, withNameserver(newDNSError(response.msg.Rcode, "querying the SOA record failed"), nameserver)
+	}
+
+	for _, rr := range response.msg.Answer {
+		if soa, ok := rr.(*dns.SOA); ok {
+			return soa.Serial, nil
+		}
+	}
+
+	return 0, fmt.Errorf("response of %s holds no SOA record for %s", nameserver.Addr(), zone)
+}
+
+// Record matching modes of the waitForRecord function.
+const (
+	// recordMatchExact requires the answers to be exactly the expected values.
+	recordMatchExact = "exact"
+
+	// recordMatchContains requires the answers to hold at least the expected values.
+	recordMatchContains = "contains"
+)
+
+// waitForRecordOptions holds the options that can be passed to the waitForRecord function.
+type waitForRecordOptions struct {
+	waitOptions
+
+	// Match holds how the answers are compared to the expected values, one of
+	// recordMatchExact or recordMatchContains.
+	Match string
+}
+
+// parseWaitForRecordOptions parses the options object passed to the waitForRecord
+// function.
+//
+// It accepts the same options as the waitForSerial function, along with the match
+// option.
+func parseWaitForRecordOptions(rt *sobek.Runtime, value sobek.Value) (waitForRecordOptions, error) {
+	waitOpts, err := parseWaitOptions(rt, value)
+	if err != nil {
+		return waitForRecordOptions{}, err
+	}
+
+	opts := waitForRecordOptions{
+		waitOptions: waitOpts,
+		Match:       recordMatchExact,
+	}
+
+	// Names which do not exist yet, or anymore, simply hold no records
+	opts.NXDomainAsEmpty = true
+
+	if common.IsNullish(value) {
+		return opts, nil
+	}
+
+	if v := value.ToObject(rt).Get("match"); !common.IsNullish(v) {
+		if opts.Match, err = parseRecordMatch(v); err != nil {
+			return opts, err
+		}
+	}
+
+	return opts, nil
+}
+
+// parseRecordMatch parses the match option, holding how answers are compared to
+// expected values.
+func parseRecordMatch(value sobek.Value) (string, error) {
+	switch value.String() {
+	case recordMatchExact, recordMatchContains:
+		return value.String(), nil
+	default:
+		return "", fmt.Errorf("match option must be one of 'exact' or 'contains'; got %v instead", value)
+	}
+}
+
+// normalizeAnswer returns the record data in a form suitable for comparisons,
+// regardless of its case and trailing dot.
+func normalizeAnswer(value string) string {
+	return strings.ToLower(strings.TrimSuffix(value, "."))
+}
+
+// answersMatch returns whether the answers match the expected values, compared
+// regardless of their order, case and trailing dot.
+//
+// In the exact mode, the answers must hold the expected values and nothing else,
+// while in the contains mode, they must hold at least the expected values.
+func answersMatch(answers, expected []string, mode string) bool {
+	found := make(map[string]bool, len(answers))
+	for _, answer := range answers {
+		found[normalizeAnswer(answer)] = true
+	}
+
+	wanted := make(map[string]bool, len(expected))
+	for _, value := range expected {
+		wanted[normalizeAnswer(value)] = true
+		if !found[normalizeAnswer(value)] {
+			return false
+		}
+	}
+
+	return mode == recordMatchContains || len(found) == len(wanted)
+}
+
+// waitForRecordResult is the object the waitForRecord function resolves to.
+type waitForRecordResult struct {
+	// Name holds the name which was resolved.
+	Name string `js:"name"`
+
+	// Type holds the record type which was resolved.
+	Type string `js:"type"`
+
+	// Matched indicates whether the answers matched the expected values before the
+	// timeout.
+	Matched bool `js:"matched"`
+
+	// Elapsed holds the duration until the answers matched, or until the wait timed
+	// out, in milliseconds.
+	Elapsed float64 `js:"elapsed"`
+
+	// Polls holds the number of times the name was resolved.
+	Polls int `js:"polls"`
+
+	// Answers holds the answers of the last successful resolution.
+	Answers []string `js:"answers"`
+
+	// Error holds the error the last resolution failed with, if any.
+	Error *Error `js:"error"`
+}
+
+// WaitForRecord resolves the query repeatedly against the nameserver, until its
+// answers match the expected values, and resolves to the time it took.
+//
+// The promise is not rejected when the answers fail to match before the timeout,
+// which is instead reported in the result.
+func (mi *ModuleInstance) WaitForRecord(query, recordType, nameserverAddr, expected, options sobek.Value) *sobek.Promise {
+	promise, resolve, reject := promises.New(mi.vu)
+
+	if mi.vu.State() == nil {
+		reject(errors.New("waitForRecord can not be used in the init context"))
+		return promise
+	}
+
+	queryStr, err := exportDomainName(mi.vu.Runtime(), query, "query")
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	var recordTypeStr string
+	if err := mi.vu.Runtime().ExportTo(recordType, &recordTypeStr); err != nil {
+		reject(fmt.Errorf("recordType must be a string; got %v instead", recordType))
+		return promise
+	}
+
+	if _, err := RecordTypeString(recordTypeStr); err != nil {
+		reject(fmt.Errorf("%w, %s is an invalid DNS record type", ErrUnsupportedRecordType, recordTypeStr))
+		return promise
+	}
+
+	nameserver, err := mi.exportNameserver(nameserverAddr)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	var expectedValues []string
+	if err := mi.vu.Runtime().ExportTo(expected, &expectedValues); err != nil || expectedValues == nil {
+		reject(fmt.Errorf("expected must be an array of record data; got %v instead", expected))
+		return promise
+	}
+
+	opts, err := parseWaitForRecordOptions(mi.vu.Runtime(), options)
+	if err != nil {
+		reject(fmt.Errorf("invalid waitForRecord options: %w", err))
+		return promise
+	}
+
+	ctx, cancel, err := mi.withAbortSignal(opts.Signal)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer cancel()
+
+		resolve(mi.waitForRecord(ctx, queryStr, recordTypeStr, nameserver, expectedValues, opts))
+	}()
+
+	return promise
+}
+
+// waitForRecord resolves the query repeatedly until its answers match the expected
+// values, or until opts.Timeout elapsed.
+func (mi *ModuleInstance) waitForRecord(
+	ctx context.Context,
+	query, recordType string,
+	nameserver Nameserver,
+	expected []string,
+	opts waitForRecordOptions,
+) *waitForRecordResult {
+	result := &waitForRecordResult{
+		Name:    query,
+		Type:    recordType,
+		Answers: []string{},
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	result.Matched = poll(ctx, opts.Interval, func() bool {
+		result.Polls++
+
+		response, _, err := mi.resolveQuery(ctx, query, recordType, nameserver, opts.resolveOptions)
+
+		// Resolutions interrupted by the end of the wait are not failures
+		if waitOver(ctx) {
+			return false
+		}
+
+		result.Error = asError(err)
+		if err != nil {
+			return false
+		}
+
+		result.Answers = response.Answers
+		if result.Answers == nil {
+			result.Answers = []string{}
+		}
+
+		return answersMatch(result.Answers, expected, opts.Match)
+	})
+
+	elapsed := time.Since(start)
+	result.Elapsed = float64(elapsed) / float64(time.Millisecond)
+
+	if result.Matched {
+		mi.emitPropagationMetrics(mi.vu.Context(), nameserver, elapsed, map[string]string{
+			"query":      query,
+			"recordType": recordType,
+		})
+	}
+
+	return result
+}
+
+// durationMillis returns the duration in milliseconds, as a pointer suitable for
+// optional result properties.
+func durationMillis(duration time.Duration) *float64 {
+	millis := float64(duration) / float64(time.Millisecond)
+	return &millis
+}
+
+// emitPropagationMetrics emits the duration a nameserver took to converge, tagged
+// with the nameserver and the provided tags describing what it converged to.
+func (mi *ModuleInstance) emitPropagationMetrics(
+	ctx context.Context,
+	nameserver Nameserver,
+	elapsed time.Duration,
+	extraTags map[string]string,
+) {
+	state := mi.vu.State()
+
+	tags := state.Tags.GetCurrentValues().Tags
+	for key, value := range extraTags {
+		tags = tags.With(key, value)
+	}
+	tags = tags.With("nameserver", nameserver.Addr())
+
+	metrics.PushIfNotDone(ctx, state.Samples, metrics.Sample{
+		TimeSeries: metrics.TimeSeries{
+			Metric: mi.metrics.DNSPropagationDuration,
+			Tags:   tags,
+		},
+		Time:     time.Now(),
+		Value:    float64(elapsed) / float64(time.Millisecond),
+		Metadata: nil,
+	})
+}
@@ -0,0 +1,220 @@
+package dns
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/sobek"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_serialAtLeast(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		serial uint32
+		target uint32
+		want   bool
+	}{
+		{name: "equal", serial: 2024060101, target: 2024060101, want: true},
+		{name: "greater", serial: 2024060102, target: 2024060101, want: true},
+		{name: "lower", serial: 2024060100, target: 2024060101, want: false},
+		{name: "greater after wrapping around", serial: 5, target: 4294967290, want: true},
+		{name: "lower before wrapping around", serial: 4294967290, target: 5, want: false},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.want, serialAtLeast(tt.serial, tt.target))
+		})
+	}
+}
+
+func Test_parseWaitOptions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		options      string
+		wantTimeout  time.Duration
+		wantInterval time.Duration
+		wantErr      bool
+	}{
+		{
+			name:         "undefined options",
+			options:      `undefined`,
+			wantTimeout:  defaultWaitTimeout,
+			wantInterval: defaultWaitInterval,
+		},
+		{
+			name:         "timeout and interval",
+			options:      `({timeout: "5m", interval: 500})`,
+			wantTimeout:  5 * time.Minute,
+			wantInterval: 500 * time.Millisecond,
+		},
+		{
+			name:    "zero interval",
+			options: `({interval: 0})`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid interval",
+			options: `({interval: "often"})`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rt := sobek.New()
+			value, err := rt.RunString(tt.options)
+			require.NoError(t, err)
+
+			got, err := parseWaitOptions(rt, value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantTimeout, got.Timeout)
+			assert.Equal(t, tt.wantInterval, got.Interval)
+			assert.Zero(t, got.QueryOptions.Timeout, "queries should use the default attempt timeout")
+		})
+	}
+}
+
+func Test_answersMatch(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		answers  []string
+		expected []string
+		mode     string
+		want     bool
+	}{
+		{
+			name:     "exact match regardless of order",
+			answers:  []string{"192.0.2.2", "192.0.2.1"},
+			expected: []string{"192.0.2.1", "192.0.2.2"},
+			mode:     recordMatchExact,
+			want:     true,
+		},
+		{
+			name:     "exact match regardless of case and trailing dot",
+			answers:  []string{"blue.k6.io"},
+			expected: []string{"Blue.K6.io."},
+			mode:     recordMatchExact,
+			want:     true,
+		},
+		{
+			name:     "exact match with extra answers",
+			answers:  []string{"192.0.2.1", "192.0.2.2"},
+			expected: []string{"192.0.2.1"},
+			mode:     recordMatchExact,
+			want:     false,
+		},
+		{
+			name:     "contains match with extra answers",
+			answers:  []string{"192.0.2.1", "192.0.2.2"},
+			expected: []string{"192.0.2.1"},
+			mode:     recordMatchContains,
+			want:     true,
+		},
+		{
+			name:     "missing answer",
+			answers:  []string{"192.0.2.1"},
+			expected: []string{"192.0.2.3"},
+			mode:     recordMatchContains,
+			want:     false,
+		},
+		{
+			name:     "no records expected",
+			answers:  []string{},
+			expected: []string{},
+			mode:     recordMatchExact,
+			want:     true,
+		},
+		{
+			name:     "no records expected but some found",
+			answers:  []string{"192.0.2.1"},
+			expected: []string{},
+			mode:     recordMatchExact,
+			want:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.want, answersMatch(tt.answers, tt.expected, tt.mode))
+		})
+	}
+}
+
+func Test_parseWaitForRecordOptions(t *testing.T) {
+	t.Parallel()
+
+	rt := sobek.New()
+
+	got, err := parseWaitForRecordOptions(rt, sobek.Undefined())
+	require.NoError(t, err)
+	assert.Equal(t, recordMatchExact, got.Match)
+	assert.True(t, got.NXDomainAsEmpty)
+
+	value, err := rt.RunString(`({match: "contains", interval: "2s"})`)
+	require.NoError(t, err)
+
+	got, err = parseWaitForRecordOptions(rt, value)
+	require.NoError(t, err)
+	assert.Equal(t, recordMatchContains, got.Match)
+	assert.Equal(t, 2*time.Second, got.Interval)
+
+	value, err = rt.RunString(`({match: "some"})`)
+	require.NoError(t, err)
+
+	_, err = parseWaitForRecordOptions(rt, value)
+	assert.Error(t, err)
+}
+
+func Test_poll(t *testing.T) {
+	t.Parallel()
+
+	t.Run("until check succeeds", func(t *testing.T) {
+		t.Parallel()
+
+		calls := 0
+		ok := poll(context.Background(), time.Millisecond, func() bool {
+			calls++
+			return calls == 3
+		})
+
+		assert.True(t, ok)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("until the context is done", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		ok := poll(ctx, time.Millisecond, func() bool { return false })
+		assert.False(t, ok)
+	})
+}
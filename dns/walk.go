@@ -0,0 +1,416 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/grafana/sobek"
+	"github.com/miekg/dns"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/promises"
+)
+
+// Methods zones can be walked with.
+const (
+	walkMethodNSEC  = "nsec"
+	walkMethodNSEC3 = "nsec3"
+)
+
+// defaultMaxWalkQueries is the maximum number of queries sent to walk a zone,
+// when none is provided.
+const defaultMaxWalkQueries = 1000
+
+// walkZoneOptions holds the options of the walkZone function.
+type walkZoneOptions struct {
+	resolveOptions
+
+	// MaxQueries holds the maximum number of queries sent to walk the zone.
+	MaxQueries int
+
+	// Labels holds the candidate labels whose NSEC3 hashes are matched against
+	// those of the zone, to recover the names of NSEC3-signed zones.
+	Labels []string
+}
+
+// parseWalkZoneOptions parses the options object passed to the walkZone function.
+func parseWalkZoneOptions(rt *sobek.Runtime, value sobek.Value) (walkZoneOptions, error) {
+	resolveOpts, err := parseResolveOptions(rt, value)
+	if err != nil {
+		return walkZoneOptions{}, err
+	}
+
+	opts := walkZoneOptions{
+		resolveOptions: resolveOpts,
+		MaxQueries:     defaultMaxWalkQueries,
+	}
+
+	if common.IsNullish(value) {
+		return opts, nil
+	}
+
+	params := value.ToObject(rt)
+
+	if v := params.Get("maxQueries"); !common.IsNullish(v) {
+		var maxQueries int64
+		if err := rt.ExportTo(v, &maxQueries); err != nil || maxQueries < 1 {
+			return opts, fmt.Errorf("maxQueries option must be a positive integer; got %v instead", v)
+		}
+
+		opts.MaxQueries = int(maxQueries)
+	}
+
+	if v := params.Get("labels"); !common.IsNullish(v) {
+		if err := rt.ExportTo(v, &opts.Labels); err != nil {
+			return opts, fmt.Errorf("labels option must be an array of labels; got %v instead", v)
+		}
+	}
+
+	return opts, nil
+}
+
+// walkZoneResult is the object the walkZone function resolves to.
+type walkZoneResult struct {
+	// Zone holds the zone which was walked.
+	Zone string `js:"zone"`
+
+	// Method holds the method the zone was walked with, either "nsec" or "nsec3".
+	Method string `js:"method"`
+
+	// Complete indicates whether the whole chain of the zone was walked, rather
+	// than the walk being cut short by the maximum number of queries.
+	Complete bool `js:"complete"`
+
+	// Names holds the names of the zone, along with the record types they hold:
+	// every name of NSEC-signed zones, and those matching the candidate labels
+	// for NSEC3-signed ones.
+	Names []walkedName `js:"names"`
+
+	// Hashes holds the NSEC3 records of NSEC3-signed zones, ordered by hash.
+	Hashes []walkedHash `js:"hashes"`
+
+	// NSEC3 holds the hashing parameters of NSEC3-signed zones, or nil otherwise.
+	NSEC3 interface{} `js:"nsec3"`
+
+	// Queries holds the number of queries sent to walk the zone.
+	Queries int `js:"queries"`
+}
+
+// walkedName describes a name found while walking a zone.
+type walkedName struct {
+	// Name holds the name, without its trailing dot.
+	Name string `js:"name"`
+
+	// Types holds the record types found at the name, e.g. "A" or "RRSIG".
+	Types []string `js:"types"`
+}
+
+// walkedHash describes an NSEC3 record found while walking a zone.
+type walkedHash struct {
+	// Hash holds the hash of the name owning the record.
+	Hash string `js:"hash"`
+
+	// Next holds the next hash of the chain.
+	Next string `js:"next"`
+
+	// Types holds the record types found at the hashed name.
+	Types []string `js:"types"`
+}
+
+// nsec3Params describes the hashing parameters of an NSEC3-signed zone.
+type nsec3Params struct {
+	// Algorithm holds the hash algorithm, 1 standing for SHA-1.
+	Algorithm uint8 `js:"algorithm"`
+
+	// Iterations holds the number of additional hashing iterations.
+	Iterations uint16 `js:"iterations"`
+
+	// Salt holds the hex encoded salt, or an empty string if there is none.
+	Salt string `js:"salt"`
+
+	// OptOut indicates whether the opt-out flag is set, in which case insecure
+	// delegations may be left out of the chain.
+	OptOut bool `js:"optOut"`
+}
+
+// WalkZone enumerates the names of a DNSSEC-signed zone by walking its NSEC
+// chain, or collects the hashes of its NSEC3 chain, so that exhaustive query sets
+// can be built for authoritative server stress tests.
+func (mi *ModuleInstance) WalkZone(zone, nameserverAddr, options sobek.Value) *sobek.Promise {
+	promise, resolve, reject := promises.New(mi.vu)
+
+	if mi.vu.State() == nil {
+		reject(errors.New("walkZone can not be used in the init context"))
+		return promise
+	}
+
+	zoneStr, err := exportDomainName(mi.vu.Runtime(), zone, "zone")
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	nameserver, err := mi.exportNameserver(nameserverAddr)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	opts, err := parseWalkZoneOptions(mi.vu.Runtime(), options)
+	if err != nil {
+		reject(fmt.Errorf("invalid walkZone options: %w", err))
+		return promise
+	}
+
+	ctx, cancel, err := mi.withAbortSignal(opts.Signal)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer cancel()
+
+		result, err := mi.walkZone(ctx, strings.TrimSuffix(zoneStr, "."), nameserver, opts)
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(result)
+	}()
+
+	return promise
+}
+
+// walkZone walks the NSEC chain of the zone from its apex, or falls back to
+// collecting the hashes of its NSEC3 chain if it is NSEC3-signed.
+func (mi *ModuleInstance) walkZone(
+	ctx context.Context,
+	zone string,
+	nameserver Nameserver,
+	opts walkZoneOptions,
+) (*walkZoneResult, error) {
+	result := &walkZoneResult{
+		Zone:   zone,
+		Names:  []walkedName{},
+		Hashes: []walkedHash{},
+	}
+
+	visited := map[string]bool{}
+	current := dns.Fqdn(zone)
+
+	for result.Queries < opts.MaxQueries {
+		response, err := mi.queryDenial(ctx, current, dns.TypeNSEC, nameserver, opts, result)
+		if err != nil {
+			return nil, err
+		}
+
+		nsec := findNSEC(response, current)
+		if nsec == nil {
+			if result.Method == "" && hasNSEC3(response) {
+				return mi.collectNSEC3(ctx, zone, nameserver, opts, result, response)
+			}
+
+			if result.Method == "" {
+				return nil, fmt.Errorf("zone %s does not appear to be signed with NSEC or NSEC3 records", zone)
+			}
+
+			// The chain can not be followed any further
+			return result, nil
+		}
+
+		result.Method = walkMethodNSEC
+		result.Names = append(result.Names, walkedName{
+			Name:  strings.TrimSuffix(nsec.Hdr.Name, "."),
+			Types: typeNames(nsec.TypeBitMap),
+		})
+		visited[strings.ToLower(current)] = true
+
+		next := dns.Fqdn(nsec.NextDomain)
+		if strings.EqualFold(next, dns.Fqdn(zone)) {
+			result.Complete = true
+			return result, nil
+		}
+
+		if visited[strings.ToLower(next)] || !dns.IsSubDomain(dns.Fqdn(zone), next) {
+			// The chain loops, or leaves the zone, and can not be trusted
+			return result, nil
+		}
+
+		current = next
+	}
+
+	return result, nil
+}
+
+// collectNSEC3 collects the NSEC3 records of the zone, by querying random names
+// of the zone until the hashes they are denied by form a closed chain.
+func (mi *ModuleInstance) collectNSEC3(
+	ctx context.Context,
+	zone string,
+	nameserver Nameserver,
+	opts walkZoneOptions,
+	result *walkZoneResult,
+	response *dns.Msg,
+) (*walkZoneResult, error) {
+	result.Method = walkMethodNSEC3
+
+	hashes := map[string]*dns.NSEC3{}
+	collect := func(msg *dns.Msg) {
+		for _, rr := range append(append([]dns.RR{}, msg.Answer...), msg.Ns...) {
+			if nsec3, ok := rr.(*dns.NSEC3); ok {
+				hash := strings.ToUpper(strings.SplitN(nsec3.Hdr.Name, ".", 2)[0])
+				hashes[hash] = nsec3
+			}
+		}
+	}
+
+	collect(response)
+
+	for !nsec3ChainClosed(hashes) && result.Queries < opts.MaxQueries {
+		label, err := randomLabel("", defaultRandomLabelEntropy)
+		if err != nil {
+			return nil, err
+		}
+
+		response, err := mi.queryDenial(ctx, label+"."+dns.Fqdn(zone), dns.TypeA, nameserver, opts, result)
+		if err != nil {
+			return nil, err
+		}
+
+		collect(response)
+	}
+
+	result.Complete = nsec3ChainClosed(hashes)
+
+	var params *dns.NSEC3
+	for hash, nsec3 := range hashes {
+		if params == nil {
+			params = nsec3
+		}
+
+		result.Hashes = append(result.Hashes, walkedHash{
+			Hash:  hash,
+			Next:  strings.ToUpper(nsec3.NextDomain),
+			Types: typeNames(nsec3.TypeBitMap),
+		})
+	}
+
+	sort.Slice(result.Hashes, func(i, j int) bool {
+		return result.Hashes[i].Hash < result.Hashes[j].Hash
+	})
+
+	if params == nil {
+		return result, nil
+	}
+
+	result.NSEC3 = &nsec3Params{
+		Algorithm:  params.Hash,
+		Iterations: params.Iterations,
+		Salt:       strings.ToLower(strings.TrimPrefix(params.Salt, "-")),
+		OptOut:     params.Flags&1 == 1,
+	}
+
+	// Recover the names whose hashes are found in the chain, the apex first
+	candidates := append([]string{""}, opts.Labels...)
+	for _, label := range candidates {
+		name := dns.Fqdn(zone)
+		if label != "" {
+			name = dns.Fqdn(strings.Trim(label, ".") + "." + zone)
+		}
+
+		hash := dns.HashName(name, params.Hash, params.Iterations, params.Salt)
+		if nsec3, ok := hashes[hash]; ok {
+			result.Names = append(result.Names, walkedName{
+				Name:  strings.TrimSuffix(name, "."),
+				Types: typeNames(nsec3.TypeBitMap),
+			})
+		}
+	}
+
+	return result, nil
+}
+
+// queryDenial queries the name for the record type with the DNSSEC OK bit set,
+// for the nameserver to prove what it holds, or does not, with NSEC or NSEC3
+// records.
+func (mi *ModuleInstance) queryDenial(
+	ctx context.Context,
+	name string,
+	qtype uint16,
+	nameserver Nameserver,
+	opts walkZoneOptions,
+	result *walkZoneResult,
+) (*dns.Msg, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(name, qtype)
+	msg.RecursionDesired = !opts.NoRecursion
+	msg.SetEdns0(dns.DefaultMsgSize, true)
+
+	result.Queries++
+
+	response, _, err := mi.sendMessage(ctx, msg, nameserver, opts.QueryOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	switch response.msg.Rcode {
+	case dns.RcodeSuccess, dns.RcodeNameError:
+		return response.msg, nil
+	default:
+		return nil, withNameserver(newDNSError(response.msg.Rcode, "walking zone failed"), nameserver)
+	}
+}
+
+// findNSEC returns the NSEC record owned by the name found in the answer or
+// authority section of the response, if any.
+func findNSEC(response *dns.Msg, name string) *dns.NSEC {
+	for _, rr := range append(append([]dns.RR{}, response.Answer...), response.Ns...) {
+		if nsec, ok := rr.(*dns.NSEC); ok && strings.EqualFold(nsec.Hdr.Name, name) {
+			return nsec
+		}
+	}
+
+	return nil
+}
+
+// hasNSEC3 returns whether the response holds NSEC3 records.
+func hasNSEC3(response *dns.Msg) bool {
+	for _, rr := range append(append([]dns.RR{}, response.Answer...), response.Ns...) {
+		if _, ok := rr.(*dns.NSEC3); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// nsec3ChainClosed returns whether the next hash of every NSEC3 record is owned
+// by another one, which means the whole chain was collected.
+func nsec3ChainClosed(hashes map[string]*dns.NSEC3) bool {
+	if len(hashes) == 0 {
+		return false
+	}
+
+	for _, nsec3 := range hashes {
+		if _, ok := hashes[strings.ToUpper(nsec3.NextDomain)]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// typeNames returns the names of the record types of an NSEC or NSEC3 type
+// bitmap.
+func typeNames(types []uint16) []string {
+	names := make([]string, 0, len(types))
+	for _, t := range types {
+		names = append(names, dns.Type(t).String())
+	}
+
+	return names
+}
@@ -0,0 +1,180 @@
+package dns
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+)
+
+func TestWalkZone(t *testing.T) {
+	t.Parallel()
+
+	t.Run("NSEC-signed zones should be walked from their apex", func(t *testing.T) {
+		t.Parallel()
+
+		runtime, err := newConfiguredRuntime(t)
+		require.NoError(t, err)
+
+		_, err = runtime.VU.Runtime().RunString(`
+			const server = dns.startServer([
+				'k6.test. 300 IN NSEC mail.k6.test. NS SOA RRSIG NSEC DNSKEY',
+				'mail.k6.test. 300 IN NSEC www.k6.test. A MX RRSIG NSEC',
+				'www.k6.test. 300 IN NSEC k6.test. A AAAA RRSIG NSEC',
+			]);
+		`)
+		require.NoError(t, err)
+
+		runtime.MoveToVUContext(&lib.State{
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+			Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+			Samples:        make(chan metrics.SampleContainer, 1024),
+		})
+
+		_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+			const result = await dns.walkZone("k6.test", server.address);
+
+			if (result.method !== "nsec" || !result.complete || result.queries !== 3 || result.nsec3 !== null) {
+				throw new Error("unexpected walk: " + JSON.stringify(result));
+			}
+
+			if (result.names.map((n) => n.name).join() !== "k6.test,mail.k6.test,www.k6.test" ||
+				result.names[1].types.join() !== "A,MX,RRSIG,NSEC") {
+				throw new Error("unexpected names: " + JSON.stringify(result.names));
+			}
+
+			const partial = await dns.walkZone("k6.test", server.address, { maxQueries: 2 });
+			if (partial.complete || partial.names.length !== 2) {
+				throw new Error("unexpected partial walk: " + JSON.stringify(partial));
+			}
+		`))
+		assert.NoError(t, err)
+	})
+
+	t.Run("NSEC3-signed zones should have their hashes collected", func(t *testing.T) {
+		t.Parallel()
+
+		address := startNSEC3Server(t, "k6.test.", "AB", []string{"k6.test.", "www.k6.test.", "mail.k6.test."})
+
+		runtime, err := newConfiguredRuntime(t)
+		require.NoError(t, err)
+		require.NoError(t, runtime.VU.Runtime().Set("address", address))
+
+		runtime.MoveToVUContext(&lib.State{
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+			Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+			Samples:        make(chan metrics.SampleContainer, 1024),
+		})
+
+		_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+			const result = await dns.walkZone("k6.test", address, { labels: ["www", "ftp", "mail"] });
+
+			if (result.method !== "nsec3" || !result.complete || result.hashes.length !== 3) {
+				throw new Error("unexpected walk: " + JSON.stringify(result));
+			}
+
+			if (result.nsec3.salt !== "ab" || result.nsec3.iterations !== 0 || result.nsec3.algorithm !== 1) {
+				throw new Error("unexpected NSEC3 parameters: " + JSON.stringify(result.nsec3));
+			}
+
+			if (result.names.map((n) => n.name).join() !== "k6.test,www.k6.test,mail.k6.test") {
+				throw new Error("unexpected names: " + JSON.stringify(result.names));
+			}
+		`))
+		assert.NoError(t, err)
+	})
+
+	t.Run("Unsigned zones should not be walked", func(t *testing.T) {
+		t.Parallel()
+
+		runtime, err := newConfiguredRuntime(t)
+		require.NoError(t, err)
+
+		_, err = runtime.VU.Runtime().RunString(`
+			const server = dns.startServer(['k6.test. 300 IN A 203.0.113.1']);
+		`)
+		require.NoError(t, err)
+
+		runtime.MoveToVUContext(&lib.State{
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+			Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+			Samples:        make(chan metrics.SampleContainer, 1024),
+		})
+
+		_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+			await dns.walkZone("k6.test", server.address);
+		`))
+		assert.ErrorContains(t, err, "does not appear to be signed")
+	})
+}
+
+// startNSEC3Server starts a nameserver serving the NSEC3 chain of the names of
+// the zone, hashed with the salt and no additional iterations, to prove the
+// names queried exist or not. It returns the address of the nameserver.
+func startNSEC3Server(t *testing.T, zone, salt string, names []string) string {
+	t.Helper()
+
+	hashes := make([]string, 0, len(names))
+	for _, name := range names {
+		hashes = append(hashes, dns.HashName(name, dns.SHA1, 0, salt))
+	}
+
+	sort.Strings(hashes)
+
+	chain := make([]*dns.NSEC3, 0, len(hashes))
+	for i, hash := range hashes {
+		chain = append(chain, &dns.NSEC3{
+			Hdr:        dns.RR_Header{Name: hash + "." + zone, Rrtype: dns.TypeNSEC3, Class: dns.ClassINET, Ttl: 300},
+			Hash:       dns.SHA1,
+			Salt:       salt,
+			SaltLength: uint8(len(salt) / 2),
+			NextDomain: hashes[(i+1)%len(hashes)],
+			HashLength: 20,
+			TypeBitMap: []uint16{dns.TypeA, dns.TypeRRSIG},
+		})
+	}
+
+	handler := dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		response := new(dns.Msg)
+		response.SetReply(req)
+
+		hash := dns.HashName(req.Question[0].Name, dns.SHA1, 0, salt)
+
+		// Existing names are proven to hold no records of the queried type, and
+		// others not to exist by the record whose range covers their hash
+		response.Rcode = dns.RcodeNameError
+		covering := chain[len(chain)-1]
+
+		for _, nsec3 := range chain {
+			owner := strings.SplitN(nsec3.Hdr.Name, ".", 2)[0]
+			if owner == hash {
+				response.Rcode = dns.RcodeSuccess
+				covering = nsec3
+
+				break
+			}
+
+			if owner < hash {
+				covering = nsec3
+			}
+		}
+
+		response.Ns = append(response.Ns, covering)
+		_ = w.WriteMsg(response)
+	})
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := &dns.Server{PacketConn: conn, Handler: handler}
+	go func() { _ = server.ActivateAndServe() }()
+	t.Cleanup(func() { _ = server.Shutdown() })
+
+	return conn.LocalAddr().String()
+}
@@ -0,0 +1,203 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/grafana/sobek"
+	"github.com/miekg/dns"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/promises"
+)
+
+// wordlistOptions holds the options that can be passed to the resolveWordlist
+// function.
+type wordlistOptions struct {
+	batchOptions
+
+	// Types holds the record types each name is queried for.
+	Types []string
+}
+
+// wordlistResult is the object the resolveWordlist function resolves to.
+type wordlistResult struct {
+	// Zone holds the zone the labels were prepended to.
+	Zone string `js:"zone"`
+
+	// Wildcard indicates whether the zone answers for names which do not exist,
+	// through wildcard records, in which case the names answered with the same
+	// records as a random name are left out.
+	Wildcard bool `js:"wildcard"`
+
+	// Names holds the results of the queries for the names which exist, in the
+	// order the labels were provided.
+	Names []*resolveResult `js:"names"`
+
+	// Queries holds the number of queries sent.
+	Queries int `js:"queries"`
+
+	// Failed holds the number of queries which failed for another reason than the
+	// name not existing, such as timeouts, and whose names might thus exist.
+	Failed int `js:"failed"`
+}
+
+// ResolveWordlist resolves the names made of each of the labels prepended to the
+// zone, with a bounded pool of workers, and resolves to those which exist.
+//
+// The promise is never rejected because of a failed query, the failures are
+// instead counted in the result.
+func (mi *ModuleInstance) ResolveWordlist(labels, zone, nameserverAddr, options sobek.Value) *sobek.Promise {
+	promise, resolve, reject := promises.New(mi.vu)
+
+	if mi.vu.State() == nil {
+		reject(errors.New("resolveWordlist can not be used in the init context"))
+		return promise
+	}
+
+	var labelList []string
+	if err := mi.vu.Runtime().ExportTo(labels, &labelList); err != nil || common.IsNullish(labels) {
+		reject(fmt.Errorf("labels must be an array of strings; got %v instead", labels))
+		return promise
+	}
+
+	zoneStr, err := exportDomainName(mi.vu.Runtime(), zone, "zone")
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	nameserver, err := mi.exportNameserver(nameserverAddr)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	opts, err := parseWordlistOptions(mi.vu.Runtime(), options)
+	if err != nil {
+		reject(fmt.Errorf("invalid resolveWordlist options: %w", err))
+		return promise
+	}
+
+	zoneStr = strings.Trim(zoneStr, ".")
+
+	batch := make([]batchQuery, 0, len(labelList)*len(opts.Types))
+	for _, label := range labelList {
+		name, err := ToASCII(strings.Trim(label, ".") + "." + zoneStr)
+		if err != nil {
+			reject(fmt.Errorf("invalid label %q: %w", label, err))
+			return promise
+		}
+
+		for _, recordType := range opts.Types {
+			batch = append(batch, batchQuery{Name: name, Type: recordType})
+		}
+	}
+
+	ctx, cancel, err := mi.withAbortSignal(opts.Signal)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer cancel()
+
+		resolve(mi.resolveWordlist(ctx, zoneStr, batch, nameserver, opts))
+	}()
+
+	return promise
+}
+
+// resolveWordlist resolves the batch of names of the zone, and keeps the results
+// of those which exist, and are not answered for by wildcard records.
+func (mi *ModuleInstance) resolveWordlist(
+	ctx context.Context,
+	zone string,
+	batch []batchQuery,
+	nameserver Nameserver,
+	opts wordlistOptions,
+) *wordlistResult {
+	result := &wordlistResult{
+		Zone:  zone,
+		Names: []*resolveResult{},
+	}
+
+	// Names which do not exist are answered for by wildcard records, if any, with
+	// the same records as a random name is
+	wildcards := make(map[string]string, len(opts.Types))
+	if label, err := randomLabel("", defaultRandomLabelEntropy); err == nil {
+		probes := make([]batchQuery, 0, len(opts.Types))
+		for _, recordType := range opts.Types {
+			probes = append(probes, batchQuery{Name: label + "." + zone, Type: recordType})
+		}
+
+		for _, probe := range mi.resolveBatch(ctx, probes, nameserver, opts.batchOptions) {
+			result.Queries++
+
+			if probe.Error == nil && probe.Rcode == dns.RcodeToString[dns.RcodeSuccess] {
+				result.Wildcard = true
+				wildcards[probe.Type] = answerSetKey(probe.Answers)
+			}
+		}
+	}
+
+	for _, resolved := range mi.resolveBatch(ctx, batch, nameserver, opts.batchOptions) {
+		result.Queries++
+
+		switch {
+		case resolved.Rcode == dns.RcodeToString[dns.RcodeNameError]:
+			continue
+		case resolved.Error != nil:
+			result.Failed++
+			continue
+		}
+
+		if key, ok := wildcards[resolved.Type]; ok && key == answerSetKey(resolved.Answers) {
+			continue
+		}
+
+		result.Names = append(result.Names, resolved)
+	}
+
+	return result
+}
+
+// parseWordlistOptions parses the options object passed to the resolveWordlist
+// function.
+//
+// It accepts the same options as the resolveBatch function, along with the types
+// option.
+func parseWordlistOptions(rt *sobek.Runtime, value sobek.Value) (wordlistOptions, error) {
+	batchOpts, err := parseBatchOptions(rt, value)
+	if err != nil {
+		return wordlistOptions{}, err
+	}
+
+	opts := wordlistOptions{
+		batchOptions: batchOpts,
+		Types:        []string{RecordTypeA.String()},
+	}
+
+	if common.IsNullish(value) {
+		return opts, nil
+	}
+
+	if v := value.ToObject(rt).Get("types"); !common.IsNullish(v) {
+		var types []string
+		if err := rt.ExportTo(v, &types); err != nil || len(types) == 0 {
+			return opts, fmt.Errorf("types option must be a non-empty array of record types; got %v instead", v)
+		}
+
+		for _, recordType := range types {
+			if _, err := RecordTypeString(recordType); err != nil {
+				return opts, fmt.Errorf("types option holds an unsupported record type %s", recordType)
+			}
+		}
+
+		opts.Types = types
+	}
+
+	return opts, nil
+}
@@ -0,0 +1,98 @@
+package dns
+
+import (
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+)
+
+func TestResolveWordlist(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Existing names should be resolved to", func(t *testing.T) {
+		t.Parallel()
+
+		runtime, err := newConfiguredRuntime(t)
+		require.NoError(t, err)
+
+		_, err = runtime.VU.Runtime().RunString(`
+			const server = dns.startServer([
+				"www.k6.test. 60 IN A 203.0.113.1",
+				"mail.k6.test. 60 IN AAAA 2001:db8::1",
+			]);
+		`)
+		require.NoError(t, err)
+
+		runtime.MoveToVUContext(&lib.State{
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+			Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+			Samples:        make(chan metrics.SampleContainer, 1024),
+		})
+
+		_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+			const result = await dns.resolveWordlist(["www", "ftp", "mail"], "k6.test", server.address, {
+				types: ["A", "AAAA"],
+				concurrency: 2,
+			});
+
+			if (result.wildcard || result.queries !== 8 || result.failed !== 0) {
+				throw new Error("unexpected result: " + JSON.stringify(result));
+			}
+
+			const names = result.names.map((r) => r.name + "/" + r.type + "=" + r.answers.join());
+			if (names.join() !== "www.k6.test/A=203.0.113.1,www.k6.test/AAAA=,mail.k6.test/A=,mail.k6.test/AAAA=2001:db8::1") {
+				throw new Error("unexpected names: " + names);
+			}
+		`))
+		assert.NoError(t, err)
+	})
+
+	t.Run("Names answered for by wildcard records should be left out", func(t *testing.T) {
+		t.Parallel()
+
+		handler := dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			response := new(dns.Msg)
+			response.SetReply(req)
+
+			ip := "192.0.2.1"
+			if req.Question[0].Name == "www.k6.test." {
+				ip = "192.0.2.2"
+			}
+
+			rr, _ := dns.NewRR(req.Question[0].Name + " 60 IN A " + ip)
+			response.Answer = append(response.Answer, rr)
+			_ = w.WriteMsg(response)
+		})
+
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		server := &dns.Server{PacketConn: conn, Handler: handler}
+		go func() { _ = server.ActivateAndServe() }()
+		t.Cleanup(func() { _ = server.Shutdown() })
+
+		runtime, err := newConfiguredRuntime(t)
+		require.NoError(t, err)
+		require.NoError(t, runtime.VU.Runtime().Set("address", conn.LocalAddr().String()))
+
+		runtime.MoveToVUContext(&lib.State{
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+			Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+			Samples:        make(chan metrics.SampleContainer, 1024),
+		})
+
+		_, err = runtime.RunOnEventLoop(wrapInAsyncLambda(`
+			const result = await dns.resolveWordlist(["www", "ftp"], "k6.test", address);
+
+			if (!result.wildcard || result.names.map((r) => r.name).join() !== "www.k6.test") {
+				throw new Error("unexpected result: " + JSON.stringify(result));
+			}
+		`))
+		assert.NoError(t, err)
+	})
+}
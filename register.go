@@ -9,5 +9,5 @@ import (
 // Register the extension on module initialization, available to
 // import from JS as "k6/x/dns".
 func init() {
-	modules.Register("k6/x/dns", new(dns.RootModule))
+	modules.Register("k6/x/dns", dns.Default())
 }